@@ -0,0 +1,95 @@
+package main
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Defaults of the -rate-adaptive bounds.
+const (
+	defaultAdaptiveMinDelay = minRequestDelay
+	defaultAdaptiveMaxDelay = 2 * time.Minute
+)
+
+const (
+	adaptiveWindow   = 10   // Recent outcomes considered before speeding up.
+	adaptiveIncrease = 1.0  // Requests per minute added after a clear window.
+	adaptiveJitter   = 0.25 // Spread of each delay around the current one.
+)
+
+// adaptiveRate adjusts the delay between requests AIMD-style: a block halves
+// the request rate at once, and each success while the recent window has been
+// free of blocks adds adaptiveIncrease requests per minute. The delay stays
+// within [min, max].
+type adaptiveRate struct {
+	mu     sync.Mutex
+	min    time.Duration
+	max    time.Duration
+	delay  time.Duration
+	recent []bool // Whether each recent outcome was a block, oldest first.
+}
+
+// newAdaptiveRate starts at the slower of start and min.
+func newAdaptiveRate(start, min, max time.Duration) *adaptiveRate {
+	return &adaptiveRate{min: min, max: max, delay: clampDuration(start, min, max)}
+}
+
+func clampDuration(d, lo, hi time.Duration) time.Duration {
+	return max(lo, min(d, hi))
+}
+
+// observe adjusts the rate after a request ended with outcome.
+func (a *adaptiveRate) observe(outcome string) {
+	if outcome != outcomeOK && outcome != outcomeBlocked {
+		return // Errors and odd statuses say nothing about pacing.
+	}
+	blocked := outcome == outcomeBlocked
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.recent = append(a.recent, blocked)
+	if len(a.recent) > adaptiveWindow {
+		a.recent = a.recent[1:]
+	}
+
+	old := a.delay
+	if blocked {
+		a.delay = clampDuration(2*a.delay, a.min, a.max)
+	} else if a.clear() {
+		rpm := float64(time.Minute)/float64(a.delay) + adaptiveIncrease
+		a.delay = clampDuration(time.Duration(float64(time.Minute)/rpm), a.min, a.max)
+	}
+	if a.delay != old {
+		verbosef("Adaptive rate: %.1f requests per minute (delay %s)", float64(time.Minute)/float64(a.delay), a.delay.Round(100*time.Millisecond))
+	}
+}
+
+// clear reports whether the recent window is full and has no blocks.
+func (a *adaptiveRate) clear() bool {
+	if len(a.recent) < adaptiveWindow {
+		return false
+	}
+	for _, blocked := range a.recent {
+		if blocked {
+			return false
+		}
+	}
+	return true
+}
+
+// nextDelay returns the current delay with jitter.
+func (a *adaptiveRate) nextDelay() time.Duration {
+	a.mu.Lock()
+	d := a.delay
+	a.mu.Unlock()
+	spread := float64(d) * adaptiveJitter
+	return time.Duration(float64(d) - spread + 2*spread*rand.Float64())
+}
+
+// currentDelay returns the delay without jitter.
+func (a *adaptiveRate) currentDelay() time.Duration {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.delay
+}
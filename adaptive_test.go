@@ -0,0 +1,80 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAdaptiveRateBacksOffOn429Burst(t *testing.T) {
+	a := newAdaptiveRate(10*time.Second, 5*time.Second, time.Minute)
+	for i := 0; i < adaptiveWindow; i++ {
+		a.observe(outcomeOK)
+	}
+	before := a.currentDelay()
+
+	// A burst of 429s halves the rate on each one, down to the max delay.
+	a.observe(outcomeBlocked)
+	if got := a.currentDelay(); got != 2*before {
+		t.Errorf("delay %s after one block, want %s", got, 2*before)
+	}
+	for i := 0; i < 5; i++ {
+		a.observe(outcomeBlocked)
+	}
+	if got := a.currentDelay(); got != time.Minute {
+		t.Errorf("delay %s after a burst, want the max of 1m", got)
+	}
+
+	// Successes do not speed up until a whole window is clear of blocks.
+	for i := 0; i < adaptiveWindow-1; i++ {
+		a.observe(outcomeOK)
+	}
+	if got := a.currentDelay(); got != time.Minute {
+		t.Errorf("delay %s with blocks in the window, want 1m", got)
+	}
+	a.observe(outcomeOK)
+	if got := a.currentDelay(); got >= time.Minute {
+		t.Errorf("delay %s after a clear window, want it shorter", got)
+	}
+
+	// Errors and other statuses are ignored.
+	d := a.currentDelay()
+	a.observe(outcomeError)
+	a.observe(outcomeStatus)
+	if got := a.currentDelay(); got != d {
+		t.Errorf("delay changed from %s to %s on non-pacing outcomes", d, got)
+	}
+}
+
+func TestAdaptiveRateRecoversWithinBounds(t *testing.T) {
+	a := newAdaptiveRate(time.Second, 5*time.Second, time.Minute)
+	if got := a.currentDelay(); got != 5*time.Second {
+		t.Fatalf("start delay %s, want clamped to the min of 5s", got)
+	}
+	a.observe(outcomeBlocked)
+	for i := 0; i < 1000; i++ {
+		a.observe(outcomeOK)
+	}
+	if got := a.currentDelay(); got != 5*time.Second {
+		t.Errorf("delay %s after a long clear run, want the min of 5s", got)
+	}
+	for i := 0; i < 100; i++ {
+		if d := a.nextDelay(); d < 3750*time.Millisecond || d > 6250*time.Millisecond {
+			t.Fatalf("jittered delay %s, want 5s±25%%", d)
+		}
+	}
+}
+
+func TestRateAdaptiveFlagBounds(t *testing.T) {
+	if _, err := newHTTPFetcher(fetchFlags(t, "-rate-adaptive", "-rate-adaptive-min-delay", "1m", "-rate-adaptive-max-delay", "10s")); err == nil {
+		t.Error("a min delay above the max accepted")
+	}
+	f, err := newHTTPFetcher(fetchFlags(t, "-rate-adaptive", "-rate-adaptive-min-delay", "20s", "-rate-adaptive-max-delay", "90s"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range f.identities {
+		if a := id.limiter.adaptive; a == nil || a.min != 20*time.Second || a.max != 90*time.Second {
+			t.Errorf("identity paced by %+v, want an adaptive rate within 20s-90s", a)
+		}
+	}
+}
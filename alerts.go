@@ -0,0 +1,264 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Kinds of alert rule.
+const (
+	alertCompanyChanged    = "company_changed"              // A stored candidate's current company differs from the stored one.
+	alertOpenToWork        = "became_open_to_work"          // A candidate not open to work before says so now.
+	alertExperienceCrossed = "experience_crossed_threshold" // Experience reached Threshold years since it was stored.
+	alertNewCandidateEmail = "new_candidate_with_email"     // A candidate not in the store, with an email found.
+)
+
+// defaultAlertThreshold is the years of an experience_crossed_threshold rule
+// without a threshold.
+const defaultAlertThreshold = 5
+
+// defaultAlertTemplates are the messages of rules without a template.
+var defaultAlertTemplates = map[string]string{
+	alertCompanyChanged:    `{{.Name}} moved from {{.Before.Company}} to {{.After.Company}}: {{.ProfileURL}}`,
+	alertOpenToWork:        `{{.Name}} is now open to work{{with .After.Company}} (at {{.}}){{end}}: {{.ProfileURL}}`,
+	alertExperienceCrossed: `{{.Name}} now has {{.After.ExperienceYears}} years of experience: {{.ProfileURL}}`,
+	alertNewCandidateEmail: `New candidate {{.Name}} <{{.After.Email}}>{{with .After.Company}} at {{.}}{{end}}: {{.ProfileURL}}`,
+}
+
+// alertRule is one rule of an -alert-rules file.
+type alertRule struct {
+	Name      string   `yaml:"name"`
+	Kind      string   `yaml:"kind"`      // One of the alert kinds.
+	MinScore  int      `yaml:"min_score"` // Only candidates scoring at least this.
+	Job       string   `yaml:"job"`       // Only candidates found by this job of a -jobs file.
+	Threshold int      `yaml:"threshold"` // Years, for experience_crossed_threshold.
+	Webhooks  []string `yaml:"webhooks"`  // Where the rule's alerts go; the -webhook URLs when empty.
+	Template  string   `yaml:"template"`  // text/template of the alert text, over an alert.
+
+	tmpl *template.Template
+}
+
+// alertRulesFile is the on-disk layout of an -alert-rules YAML file.
+type alertRulesFile struct {
+	Rules []alertRule `yaml:"rules"`
+}
+
+// candidateChange is a candidate as a run found it, with the stored entry it
+// updates; Before is nil for a candidate new to the store.
+type candidateChange struct {
+	Before *Candidate
+	After  Candidate
+}
+
+// alert is a rule matching a candidate change.
+type alert struct {
+	Rule       string     `json:"rule"`
+	Kind       string     `json:"kind"`
+	Text       string     `json:"text"` // Rendered from the rule's template; Slack shows this field.
+	ProfileURL string     `json:"profile_url"`
+	Name       string     `json:"name"`
+	Job        string     `json:"job,omitempty"`
+	Before     *Candidate `json:"before,omitempty"`
+	After      Candidate  `json:"after"`
+	Time       time.Time  `json:"time"`
+
+	webhooks []string
+}
+
+// loadAlertRules reads and checks an -alert-rules file. Rules without
+// webhooks send to defaultWebhooks, which must not be empty then.
+func loadAlertRules(filename string, defaultWebhooks []string) ([]alertRule, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read alert rules: %w", err)
+	}
+	var f alertRulesFile
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse alert rules: %w", err)
+	}
+	if len(f.Rules) == 0 {
+		return nil, fmt.Errorf("alert rules file %s lists no rules", filename)
+	}
+	seen := make(map[string]bool)
+	for i := range f.Rules {
+		r := &f.Rules[i]
+		if r.Name == "" {
+			r.Name = fmt.Sprintf("%s-%d", r.Kind, i+1)
+		}
+		if seen[r.Name] {
+			return nil, fmt.Errorf("duplicate alert rule name %q", r.Name)
+		}
+		seen[r.Name] = true
+		def, ok := defaultAlertTemplates[r.Kind]
+		if !ok {
+			return nil, fmt.Errorf("alert rule %q: unknown kind %q", r.Name, r.Kind)
+		}
+		if r.Kind == alertExperienceCrossed && r.Threshold <= 0 {
+			r.Threshold = defaultAlertThreshold
+		}
+		if len(r.Webhooks) == 0 {
+			r.Webhooks = defaultWebhooks
+		}
+		if len(r.Webhooks) == 0 {
+			return nil, fmt.Errorf("alert rule %q has no webhooks and -webhook is not set", r.Name)
+		}
+		if _, err := parseWebhooks(strings.Join(r.Webhooks, ",")); err != nil {
+			return nil, fmt.Errorf("alert rule %q: %w", r.Name, err)
+		}
+		if r.Template == "" {
+			r.Template = def
+		}
+		if r.tmpl, err = template.New(r.Name).Option("missingkey=zero").Parse(r.Template); err != nil {
+			return nil, fmt.Errorf("alert rule %q: invalid template: %w", r.Name, err)
+		}
+	}
+	return f.Rules, nil
+}
+
+// openToWork reports whether a candidate says they are looking for work, in
+// the headline or result text where LinkedIn's #OpenToWork frame shows up.
+func openToWork(c Candidate) bool {
+	text := strings.Join([]string{c.Headline, c.ResultTitle, c.Snippet}, " ")
+	return containsFold(text, "open to work") || containsFold(text, "#opentowork") || containsFold(text, "open to new opportunities")
+}
+
+// matches reports whether the rule fires for ch.
+func (r *alertRule) matches(ch candidateChange) bool {
+	if ch.After.Score < r.MinScore || (r.Job != "" && r.Job != ch.After.Job) {
+		return false
+	}
+	before, after := ch.Before, ch.After
+	switch r.Kind {
+	case alertCompanyChanged:
+		return before != nil && before.Company != "" && after.Company != "" && !strings.EqualFold(before.Company, after.Company)
+	case alertOpenToWork:
+		return before != nil && !openToWork(*before) && openToWork(after)
+	case alertExperienceCrossed:
+		threshold := float64(r.Threshold)
+		return before != nil && before.experienceYears() > 0 && before.experienceYears() < threshold && after.experienceYears() >= threshold
+	case alertNewCandidateEmail:
+		return before == nil && after.Email != ""
+	}
+	return false
+}
+
+// evaluateAlerts returns the alerts rules raise on changes, in rule order. It
+// has no side effects; an alert whose template fails to render carries the
+// error as its text.
+func evaluateAlerts(rules []alertRule, changes []candidateChange, now time.Time) []alert {
+	var alerts []alert
+	for i := range rules {
+		r := &rules[i]
+		for _, ch := range changes {
+			if !r.matches(ch) {
+				continue
+			}
+			a := alert{
+				Rule: r.Name, Kind: r.Kind, ProfileURL: ch.After.ProfileURL, Name: resultName(ch.After),
+				Job: ch.After.Job, Before: ch.Before, After: ch.After, Time: now, webhooks: r.Webhooks,
+			}
+			var text strings.Builder
+			if err := r.tmpl.Execute(&text, a); err != nil {
+				a.Text = fmt.Sprintf("%s alert for %s (template error: %v)", r.Name, a.ProfileURL, err)
+			} else {
+				a.Text = text.String()
+			}
+			alerts = append(alerts, a)
+		}
+	}
+	return alerts
+}
+
+// storeChanges pairs candidates with their entries in the store at path,
+// before the run saves to it.
+func storeChanges(path string, candidates []Candidate) ([]candidateChange, error) {
+	store, err := openStore(path)
+	if err != nil {
+		return nil, err
+	}
+	stored := make(map[string]*Candidate)
+	for _, sc := range store.candidates() {
+		stored[sc.ProfileURL] = &sc.Candidate
+	}
+	changes := make([]candidateChange, len(candidates))
+	for i, c := range candidates {
+		changes[i] = candidateChange{Before: stored[c.ProfileURL], After: c}
+	}
+	return changes, nil
+}
+
+// alertKey identifies an alert's delivery to a destination. An alert raised
+// again for the same event, by a retry or a later run, keeps its key.
+func alertKey(destination string, a alert) string {
+	event := a.Rule + "\n" + a.ProfileURL
+	switch a.Kind {
+	case alertCompanyChanged:
+		event += "\n" + strings.ToLower(a.After.Company)
+	case alertExperienceCrossed:
+		// Whole years, so alerts queued before ExperienceYears keep their keys.
+		event += "\n" + fmt.Sprint(legacyExperience(a.After.experienceYears()))
+	}
+	return deliveryKey(destination, event)
+}
+
+// queueAlerts evaluates the -alert-rules on a run's candidates against the
+// store and queues the alerts in the outbox. It must run before the
+// candidates are saved to the store.
+func queueAlerts(cfg *config, candidates []Candidate) error {
+	changes, err := storeChanges(cfg.storePath, candidates)
+	if err != nil {
+		return err
+	}
+	alerts := evaluateAlerts(cfg.alertRules, changes, clockNow().UTC())
+	if len(alerts) == 0 {
+		return nil
+	}
+	if err := cfg.outbox.enqueueAlerts(alerts); err != nil {
+		return err
+	}
+	fmt.Printf("Queued %d alerts\n", len(alerts))
+	return nil
+}
+
+// enqueueAlerts queues each alert for its rule's webhooks, skipping
+// deliveries already pending. It returns how many entries were queued.
+func (o *outbox) enqueueAlerts(alerts []alert) (int, error) {
+	now := time.Now().UTC()
+	queued := 0
+	for _, a := range alerts {
+		payload, err := json.Marshal(a)
+		if err != nil {
+			return queued, fmt.Errorf("failed to encode alert %s for %s: %w", a.Rule, a.ProfileURL, err)
+		}
+		for _, dest := range a.webhooks {
+			key := alertKey(dest, a)
+			if o.isPending(key) {
+				continue
+			}
+			e := &outboxEntry{Key: key, Destination: dest, ProfileURL: a.ProfileURL, Payload: payload, Queued: now}
+			if err := o.record(outboxRecord{Op: outboxAdd, Key: key, Time: now, Entry: e}); err != nil {
+				return queued, err
+			}
+			queued++
+		}
+	}
+	return queued, nil
+}
+
+// enqueueAlerts queues alerts and wakes the worker.
+func (w *outboxWorker) enqueueAlerts(alerts []alert) error {
+	if w == nil {
+		return nil
+	}
+	queued, err := w.box.enqueueAlerts(alerts)
+	if queued > 0 {
+		w.notify()
+	}
+	return err
+}
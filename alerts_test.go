@@ -0,0 +1,127 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeAlertRules writes an -alert-rules file with content and returns its path.
+func writeAlertRules(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "alerts.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestEvaluateAlerts(t *testing.T) {
+	rules, err := loadAlertRules(writeAlertRules(t, `rules:
+  - name: moves
+    kind: company_changed
+  - name: open
+    kind: became_open_to_work
+  - name: senior
+    kind: experience_crossed_threshold
+    threshold: 10
+  - name: new-req-123
+    kind: new_candidate_with_email
+    job: req-123
+    min_score: 20
+`), []string{"https://hooks.example.com/default"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	jane := func(edit func(c *Candidate)) Candidate {
+		c := Candidate{ProfileURL: "https://www.linkedin.com/in/jane-doe", Name: "Jane Doe", Company: "Emerson", Score: 30, Job: "req-123"}
+		c.setExperienceYears(9)
+		if edit != nil {
+			edit(&c)
+		}
+		return c
+	}
+	before := jane(nil)
+	tests := []struct {
+		name   string
+		change candidateChange
+		want   []string // Rules fired.
+	}{
+		{"unchanged", candidateChange{Before: &before, After: jane(nil)}, nil},
+		{"moved", candidateChange{Before: &before, After: jane(func(c *Candidate) { c.Company = "Acme Valves" })}, []string{"moves"}},
+		{"company case only", candidateChange{Before: &before, After: jane(func(c *Candidate) { c.Company = "EMERSON" })}, nil},
+		{"company lost", candidateChange{Before: &before, After: jane(func(c *Candidate) { c.Company = "" })}, nil},
+		{"open to work", candidateChange{Before: &before, After: jane(func(c *Candidate) { c.Headline = "Valve engineer #OpenToWork" })}, []string{"open"}},
+		{"crossed 10 years", candidateChange{Before: &before, After: jane(func(c *Candidate) { c.setExperienceYears(10.5) })}, []string{"senior"}},
+		{"new with email", candidateChange{After: jane(func(c *Candidate) { c.Email = "jane@emerson.com" })}, []string{"new-req-123"}},
+		{"new without email", candidateChange{After: jane(nil)}, nil},
+		{"new, other job", candidateChange{After: jane(func(c *Candidate) { c.Email = "jane@emerson.com"; c.Job = "req-9" })}, nil},
+		{"new, low score", candidateChange{After: jane(func(c *Candidate) { c.Email = "jane@emerson.com"; c.Score = 10 })}, nil},
+		{"moved and crossed", candidateChange{Before: &before, After: jane(func(c *Candidate) { c.Company = "Acme Valves"; c.setExperienceYears(11) })}, []string{"moves", "senior"}},
+	}
+	now := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	for _, tt := range tests {
+		var got []string
+		for _, a := range evaluateAlerts(rules, []candidateChange{tt.change}, now) {
+			got = append(got, a.Rule)
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("%s: alerts %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAlertTemplatesAndRouting(t *testing.T) {
+	rules, err := loadAlertRules(writeAlertRules(t, `rules:
+  - kind: company_changed
+    webhooks: [https://hooks.example.com/moves]
+    template: "{{.Name}}: {{.Before.Company}} -> {{.After.Company}}"
+  - kind: company_changed
+`), []string{"https://hooks.example.com/default"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := Candidate{ProfileURL: "https://www.linkedin.com/in/jane-doe", Name: "Jane Doe", Company: "Emerson"}
+	after := before
+	after.Company = "Acme Valves"
+	alerts := evaluateAlerts(rules, []candidateChange{{Before: &before, After: after}}, time.Now())
+	if len(alerts) != 2 {
+		t.Fatalf("%d alerts, want one per rule", len(alerts))
+	}
+	if alerts[0].Text != "Jane Doe: Emerson -> Acme Valves" || alerts[0].webhooks[0] != "https://hooks.example.com/moves" {
+		t.Errorf("first alert %q to %q, want the rule's own template and webhook", alerts[0].Text, alerts[0].webhooks)
+	}
+	if alerts[1].Rule != "company_changed-2" || alerts[1].Text != "Jane Doe moved from Emerson to Acme Valves: https://www.linkedin.com/in/jane-doe" || alerts[1].webhooks[0] != "https://hooks.example.com/default" {
+		t.Errorf("second alert %s %q to %q, want the defaults", alerts[1].Rule, alerts[1].Text, alerts[1].webhooks)
+	}
+
+	// The same move raises an alert of the same key; another move a new one.
+	again := evaluateAlerts(rules, []candidateChange{{Before: &before, After: after}}, time.Now().Add(time.Hour))
+	if alertKey("d", alerts[0]) != alertKey("d", again[0]) {
+		t.Error("one company change keyed two ways")
+	}
+	after.Company = "Flowserve"
+	other := evaluateAlerts(rules, []candidateChange{{Before: &before, After: after}}, time.Now())
+	if alertKey("d", alerts[0]) == alertKey("d", other[0]) {
+		t.Error("two company changes keyed alike")
+	}
+}
+
+func TestLoadAlertRulesErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name, rules string
+		webhooks    []string
+	}{
+		{"no rules", "rules: []\n", []string{"https://hooks.example.com/a"}},
+		{"unknown kind", "rules:\n  - kind: moved\n", []string{"https://hooks.example.com/a"}},
+		{"duplicate name", "rules:\n  - {name: a, kind: company_changed}\n  - {name: a, kind: became_open_to_work}\n", []string{"https://hooks.example.com/a"}},
+		{"no webhooks", "rules:\n  - kind: company_changed\n", nil},
+		{"bad template", "rules:\n  - kind: company_changed\n    template: \"{{.Name\"\n", []string{"https://hooks.example.com/a"}},
+	} {
+		if _, err := loadAlertRules(writeAlertRules(t, tc.rules), tc.webhooks); err == nil {
+			t.Errorf("%s: rules accepted", tc.name)
+		}
+	}
+}
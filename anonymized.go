@@ -0,0 +1,51 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Values of -anonymized.
+const (
+	anonymizedSkip = "skip" // Drop anonymized results as they are parsed.
+	anonymizedTag  = "tag"  // Keep them, with Anonymized set.
+)
+
+// anonymizedNames are the placeholders Google shows, in several languages,
+// instead of the name of a member who hides their profile from search
+// engines.
+var anonymizedNames = []string{"linkedin member", "linkedin-mitglied", "membre de linkedin", "miembro de linkedin"}
+
+// opaqueSlugPattern matches the member IDs, such as "ACoAAB1x2y3...",
+// anonymized results link to instead of a vanity slug, as profileSlug
+// lower-cases them.
+var opaqueSlugPattern = regexp.MustCompile(`^ac[ow]aa[a-z0-9_-]{10,}$`)
+
+// isAnonymizedResult reports whether a search result is an anonymized
+// member: a placeholder name, or a link to a member ID without any name.
+func isAnonymizedResult(c Candidate) bool {
+	name := strings.ToLower(strings.Join(strings.Fields(resultName(c)), " "))
+	for _, placeholder := range anonymizedNames {
+		if name == placeholder {
+			return true
+		}
+	}
+	return name == "" && opaqueSlugPattern.MatchString(profileSlug(c.ProfileURL))
+}
+
+// skipAnonymized drops the anonymized candidates under -anonymized skip.
+func skipAnonymized(candidates []Candidate, mode string) []Candidate {
+	if mode != anonymizedSkip {
+		return candidates
+	}
+	kept := candidates[:0]
+	for _, c := range candidates {
+		if c.Anonymized {
+			verbosef("Skipping anonymized result %s", c.ProfileURL)
+			stats.countAnonymizedSkip()
+			continue
+		}
+		kept = append(kept, c)
+	}
+	return kept
+}
@@ -0,0 +1,109 @@
+package main
+
+import "testing"
+
+// anonymizedSERP is a results page with a named result among anonymized
+// ones: the placeholder in English and German, and a bare member-ID link.
+const anonymizedSERP = `<html><body>
+<div class="tF2Cxc"><a href="https://www.linkedin.com/in/jane-doe"><h3>Jane Doe - Valve Engineer - Emerson | LinkedIn</h3></a>
+<div class="VwiC3b">Jane Doe is a valve engineer in Pune.</div></div>
+<div class="tF2Cxc"><a href="https://www.linkedin.com/in/ACoAAB1x2y3z4w5v6u7t8"><h3>LinkedIn Member - Valve Engineer - Emerson | LinkedIn</h3></a>
+<div class="VwiC3b">Valve engineer in Pune with 8 years of experience.</div></div>
+<div class="tF2Cxc"><a href="https://de.linkedin.com/in/ACwAAC9z8y7x6w5v4u3t2"><h3>LinkedIn-Mitglied | LinkedIn</h3></a>
+<div class="VwiC3b">Ventilingenieur in München.</div></div>
+<div class="tF2Cxc"><a href="https://www.linkedin.com/in/ACoAAD1a2b3c4d5e6f7g8"><h3>| LinkedIn</h3></a>
+<div class="VwiC3b">Valve engineer.</div></div>
+<div class="tF2Cxc"><a href="https://www.linkedin.com/in/member-services"><h3>Member Services Lead - LinkedIn Member Support | LinkedIn</h3></a>
+<div class="VwiC3b">Leads member services.</div></div>
+</body></html>`
+
+func TestAnonymizedResultsDetected(t *testing.T) {
+	candidates := parseResultsFixture(t, []byte(anonymizedSERP))
+	want := map[string]bool{
+		"https://www.linkedin.com/in/jane-doe":              false,
+		"https://www.linkedin.com/in/ACoAAB1x2y3z4w5v6u7t8": true,
+		"https://de.linkedin.com/in/ACwAAC9z8y7x6w5v4u3t2":  true,
+		"https://www.linkedin.com/in/ACoAAD1a2b3c4d5e6f7g8": true,
+		"https://www.linkedin.com/in/member-services":       false,
+	}
+	if len(candidates) != len(want) {
+		t.Fatalf("%d results extracted, want %d", len(candidates), len(want))
+	}
+	for _, c := range candidates {
+		anonymized, ok := want[c.ProfileURL]
+		if !ok {
+			t.Errorf("unexpected result %s", c.ProfileURL)
+			continue
+		}
+		if c.Anonymized != anonymized {
+			t.Errorf("%s anonymized: %v, want %v", c.ProfileURL, c.Anonymized, anonymized)
+		}
+	}
+}
+
+func TestIsAnonymizedResult(t *testing.T) {
+	tests := []struct {
+		c    Candidate
+		want bool
+	}{
+		{Candidate{Name: "LinkedIn Member"}, true},
+		{Candidate{Name: "  linkedin   MEMBER "}, true},
+		{Candidate{ResultTitle: "Membre de LinkedIn | LinkedIn"}, true},
+		{Candidate{ResultTitle: "Miembro de LinkedIn - Ingeniero | LinkedIn"}, true},
+		{Candidate{ProfileURL: "https://www.linkedin.com/in/ACoAAB1x2y3z4w5v6u7t8"}, true},
+		{Candidate{Name: "Jane Doe", ProfileURL: "https://www.linkedin.com/in/ACoAAB1x2y3z4w5v6u7t8"}, false},
+		{Candidate{ProfileURL: "https://www.linkedin.com/in/acoaa"}, false},
+		{Candidate{ProfileURL: "https://www.linkedin.com/in/jane-doe"}, false},
+		{Candidate{Name: "LinkedIn Member Support"}, false},
+	}
+	for _, tt := range tests {
+		if got := isAnonymizedResult(tt.c); got != tt.want {
+			t.Errorf("isAnonymizedResult(%+v) = %v, want %v", tt.c, got, tt.want)
+		}
+	}
+}
+
+func TestAnonymizedFlag(t *testing.T) {
+	web, addr := startFakeWeb(t, `profiles:
+  - slug: jane-doe
+    name: Jane Doe
+  - slug: ACoAAB1x2y3z4w5v6u7t8
+    name: LinkedIn Member
+  - slug: john-roe
+    name: John Roe
+`)
+	// Skipped by default, and counted.
+	output, err := runFakeSearch(t, addr, "-max-pages", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readFakeSearch(t, output); len(got) != 2 || got[0].Name != "Jane Doe" || got[1].Name != "John Roe" {
+		t.Errorf("kept %+v, want the anonymized result skipped", got)
+	}
+	if stats.AnonymizedSkipped != 1 {
+		t.Errorf("%d anonymized results counted, want 1", stats.AnonymizedSkipped)
+	}
+
+	// Tagged and kept, without fetching its profile.
+	before := web.requests("profile")
+	output, err = runFakeSearch(t, addr, "-max-pages", "1", "-anonymized", anonymizedTag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := readFakeSearch(t, output)
+	if len(got) != 3 {
+		t.Fatalf("kept %d candidates, want all 3", len(got))
+	}
+	for _, c := range got {
+		if c.Anonymized != (c.ProfileURL == "https://www.linkedin.com/in/acoaab1x2y3z4w5v6u7t8") {
+			t.Errorf("%s tagged anonymized: %v", c.ProfileURL, c.Anonymized)
+		}
+	}
+	if n := web.requests("profile") - before; n != 2 {
+		t.Errorf("%d profiles fetched, want the 2 named ones", n)
+	}
+
+	if _, err := parseFlags([]string{"-anonymized", "drop"}); err == nil {
+		t.Error("-anonymized drop accepted")
+	}
+}
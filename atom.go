@@ -0,0 +1,196 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"io/fs"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// defaultFeedMaxEntries caps an Atom feed unless -feed-max-entries is set.
+const defaultFeedMaxEntries = 100
+
+// atomFeed is an Atom 1.0 feed document. Fields follow RFC 4287; the feed author
+// stands in for entries, which have none of their own.
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  atomPerson  `xml:"author"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr,omitempty"`
+	Body string `xml:",chardata"`
+}
+
+type atomEntry struct {
+	ID      string    `xml:"id"`
+	Title   string    `xml:"title"`
+	Updated string    `xml:"updated"`
+	Link    atomLink  `xml:"link"`
+	Content *atomText `xml:"content,omitempty"`
+}
+
+// atomID returns a stable URN for name, so that readers recognize an entry
+// or feed across regenerations.
+func atomID(kind, name string) string {
+	sum := sha256.Sum256([]byte(name))
+	return "urn:profilesearch:" + kind + ":" + hex.EncodeToString(sum[:16])
+}
+
+// feedTitle describes the search a feed follows.
+func feedTitle(info *runInfo) string {
+	if info == nil || info.Criteria.Keywords == "" {
+		return "profilesearch candidates"
+	}
+	title := "profilesearch: " + info.Criteria.Keywords
+	if info.Criteria.Location != "" {
+		title += " in " + info.Criteria.Location
+	}
+	return title
+}
+
+// entryTitle is "Name — Headline (Location)", leaving out what is unknown.
+func entryTitle(c Candidate) string {
+	title := firstNonEmpty(resultName(c), profileSlug(c.ProfileURL))
+	if headline := firstNonEmpty(c.Headline, c.Title); headline != "" {
+		title += " — " + headline
+	}
+	if c.Location != "" {
+		title += " (" + c.Location + ")"
+	}
+	return title
+}
+
+// highlightTerms escapes snippet as HTML with each of terms wrapped in <mark>.
+func highlightTerms(snippet string, terms []string) string {
+	var quoted []string
+	for _, t := range terms {
+		if t = strings.TrimSpace(t); t != "" {
+			quoted = append(quoted, regexp.QuoteMeta(t))
+		}
+	}
+	if len(quoted) == 0 {
+		return html.EscapeString(snippet)
+	}
+	// Longer terms first, so a phrase wins over a word inside it.
+	sort.Slice(quoted, func(i, j int) bool { return len(quoted[i]) > len(quoted[j]) })
+	re := regexp.MustCompile(`(?i)` + strings.Join(quoted, "|"))
+	var b strings.Builder
+	last := 0
+	for _, m := range re.FindAllStringIndex(snippet, -1) {
+		b.WriteString(html.EscapeString(snippet[last:m[0]]))
+		b.WriteString("<mark>" + html.EscapeString(snippet[m[0]:m[1]]) + "</mark>")
+		last = m[1]
+	}
+	b.WriteString(html.EscapeString(snippet[last:]))
+	return b.String()
+}
+
+// candidateEntry renders a candidate discovered at updated as a feed entry.
+func candidateEntry(c Candidate, updated time.Time) atomEntry {
+	e := atomEntry{
+		ID:      atomID("candidate", canonicalProfileURL(c.ProfileURL)),
+		Title:   entryTitle(c),
+		Updated: updated.UTC().Format(time.RFC3339),
+		Link:    atomLink{Rel: "alternate", Href: c.ProfileURL},
+	}
+	if text := firstNonEmpty(c.Snippet, c.Summary); text != "" {
+		e.Content = &atomText{Type: "html", Body: highlightTerms(text, c.MatchedTerms)}
+	}
+	return e
+}
+
+// readAtomFeed reads the feed at filename. A missing file is an empty feed.
+func readAtomFeed(filename string) (*atomFeed, error) {
+	b, err := os.ReadFile(filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &atomFeed{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read feed: %w", err)
+	}
+	var feed atomFeed
+	if err := xml.Unmarshal(b, &feed); err != nil {
+		return nil, fmt.Errorf("failed to parse feed %s: %w", filename, err)
+	}
+	return &feed, nil
+}
+
+// writeToAtom adds candidates to the Atom feed at filename, keeping its
+// newest maxEntries entries. Candidates already in the feed keep their entry
+// and discovery time, so only new ones surface in readers; with
+// -incremental, candidates an earlier run stored never get this far.
+func writeToAtom(candidates []Candidate, filename string, maxEntries int, info *runInfo) error {
+	feed, err := readAtomFeed(filename)
+	if err != nil {
+		return err
+	}
+	now := clockNow()
+	if info != nil {
+		now = info.Timestamp
+	}
+	known := make(map[string]bool)
+	for _, e := range feed.Entries {
+		known[e.ID] = true
+	}
+	var added []atomEntry
+	for _, c := range candidates {
+		e := candidateEntry(c, now)
+		if known[e.ID] {
+			continue
+		}
+		known[e.ID] = true
+		added = append(added, e)
+	}
+	// Newest first; entries of one run stay in discovery order.
+	entries := append(added, feed.Entries...)
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Updated > entries[j].Updated })
+	if maxEntries > 0 && len(entries) > maxEntries {
+		entries = entries[:maxEntries]
+	}
+
+	if feed.ID == "" {
+		feed.ID = atomID("feed", feedTitle(info))
+		feed.Title = feedTitle(info)
+	}
+	feed.Author = atomPerson{Name: "profilesearch"}
+	feed.Entries = entries
+	feed.Updated = now.UTC().Format(time.RFC3339)
+	if len(added) == 0 && len(entries) > 0 {
+		feed.Updated = entries[0].Updated // Unchanged since the newest entry.
+	}
+	return writeFileAtomic(filename, func(w io.Writer) error {
+		if _, err := io.WriteString(w, xml.Header); err != nil {
+			return err
+		}
+		enc := xml.NewEncoder(w)
+		enc.Indent("", "  ")
+		if err := enc.Encode(feed); err != nil {
+			return fmt.Errorf("failed to write feed: %w", err)
+		}
+		_, err := io.WriteString(w, "\n")
+		return err
+	})
+}
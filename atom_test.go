@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// xmlElement is an element of a parsed XML document, for checking a feed
+// against the Atom spec without trusting atomFeed's own decoding.
+type xmlElement struct {
+	name     xml.Name
+	attrs    map[string]string
+	text     string
+	children []*xmlElement
+}
+
+// parseXML parses b strictly, failing the test on malformed XML.
+func parseXML(t *testing.T, b []byte) *xmlElement {
+	t.Helper()
+	dec := xml.NewDecoder(strings.NewReader(string(b)))
+	var root *xmlElement
+	var stack []*xmlElement
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			t.Fatalf("malformed XML: %v", err)
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			e := &xmlElement{name: tok.Name, attrs: make(map[string]string)}
+			for _, a := range tok.Attr {
+				e.attrs[a.Name.Local] = a.Value
+			}
+			if len(stack) == 0 {
+				root = e
+			} else {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, e)
+			}
+			stack = append(stack, e)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text += string(tok)
+			}
+		}
+	}
+	if root == nil {
+		t.Fatal("no root element")
+	}
+	return root
+}
+
+// all returns the child elements of e named local.
+func (e *xmlElement) all(local string) []*xmlElement {
+	var found []*xmlElement
+	for _, c := range e.children {
+		if c.name.Local == local {
+			found = append(found, c)
+		}
+	}
+	return found
+}
+
+// validateAtom checks the parts of RFC 4287 a feed reader relies on: the
+// Atom namespace, exactly one id, title, and updated on the feed and each
+// entry, an author on the feed, IRI ids, RFC 3339 dates, and a link or
+// content on each entry.
+func validateAtom(t *testing.T, b []byte) *xmlElement {
+	t.Helper()
+	const atomNS = "http://www.w3.org/2005/Atom"
+	feed := parseXML(t, b)
+	if feed.name.Space != atomNS || feed.name.Local != "feed" {
+		t.Fatalf("root element %v, want an Atom feed", feed.name)
+	}
+	single := func(what string, e *xmlElement, local string) *xmlElement {
+		t.Helper()
+		found := e.all(local)
+		if len(found) != 1 {
+			t.Errorf("%s has %d %s elements, want 1", what, len(found), local)
+			return &xmlElement{}
+		}
+		if found[0].name.Space != atomNS {
+			t.Errorf("%s %s in namespace %q", what, local, found[0].name.Space)
+		}
+		return found[0]
+	}
+	dated := func(what string, e *xmlElement) {
+		t.Helper()
+		for _, local := range []string{"id", "title", "updated"} {
+			single(what, e, local)
+		}
+		if id := single(what, e, "id").text; !strings.Contains(id, ":") {
+			t.Errorf("%s id %q is not an IRI", what, id)
+		}
+		if _, err := time.Parse(time.RFC3339, single(what, e, "updated").text); err != nil {
+			t.Errorf("%s updated: %v", what, err)
+		}
+	}
+	dated("feed", feed)
+	if author := single("feed", feed, "author"); len(author.all("name")) != 1 {
+		t.Error("feed author has no name")
+	}
+	for i, entry := range feed.all("entry") {
+		what := "entry " + single("entry", entry, "id").text
+		dated(what, entry)
+		links := entry.all("link")
+		if len(links) == 0 && len(entry.all("content")) == 0 {
+			t.Errorf("entry %d has neither a link nor content", i)
+		}
+		for _, l := range links {
+			if l.attrs["href"] == "" {
+				t.Errorf("%s has a link without an href", what)
+			}
+		}
+	}
+	return feed
+}
+
+// entryTexts returns each entry's text of the child element local.
+func entryTexts(feed *xmlElement, local string) []string {
+	var texts []string
+	for _, entry := range feed.all("entry") {
+		var text string
+		if found := entry.all(local); len(found) > 0 {
+			text = found[0].text
+		}
+		texts = append(texts, text)
+	}
+	return texts
+}
+
+func TestHighlightTerms(t *testing.T) {
+	tests := []struct {
+		snippet string
+		terms   []string
+		want    string
+	}{
+		{"Control valve engineer", nil, "Control valve engineer"},
+		{"Control valve engineer", []string{"valve"}, "Control <mark>valve</mark> engineer"},
+		// The phrase wins over the word inside it, matched in any case.
+		{"Control Valve engineer, valves", []string{"valve", "control valve"}, "<mark>Control Valve</mark> engineer, <mark>valve</mark>s"},
+		// The snippet is escaped, and so is a term inside a mark.
+		{"R&D <b>valve</b> team", []string{"R&D"}, "<mark>R&amp;D</mark> &lt;b&gt;valve&lt;/b&gt; team"},
+	}
+	for _, tt := range tests {
+		if got := highlightTerms(tt.snippet, tt.terms); got != tt.want {
+			t.Errorf("highlightTerms(%q, %q) = %q, want %q", tt.snippet, tt.terms, got, tt.want)
+		}
+	}
+}
+
+func TestEntryTitle(t *testing.T) {
+	tests := []struct {
+		c    Candidate
+		want string
+	}{
+		{Candidate{Name: "Jane Doe", Headline: "Valve Engineer at Emerson", Location: "Pune"}, "Jane Doe — Valve Engineer at Emerson (Pune)"},
+		{Candidate{Name: "Jane Doe", Title: "Valve Engineer"}, "Jane Doe — Valve Engineer"},
+		{Candidate{ProfileURL: "https://www.linkedin.com/in/jane-doe"}, "jane-doe"},
+	}
+	for _, tt := range tests {
+		if got := entryTitle(tt.c); got != tt.want {
+			t.Errorf("entryTitle(%+v) = %q, want %q", tt.c, got, tt.want)
+		}
+	}
+}
+
+func TestAtomFeedValidatesAndKeepsEntries(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "candidates.xml")
+	first := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	info := &runInfo{Timestamp: first, Criteria: SearchCriteria{Keywords: "control valve", Location: "Pune"}}
+	jane := Candidate{Name: "Jane Doe", Title: "Valve Engineer", ProfileURL: "https://www.linkedin.com/in/jane-doe",
+		Snippet: "Control valve design & sizing", MatchedTerms: []string{"control valve"}}
+	john := Candidate{Name: "John Roe", ProfileURL: "https://www.linkedin.com/in/john-roe"}
+	if err := writeToAtom([]Candidate{jane, john}, filename, 0, info); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	feed := validateAtom(t, b)
+	if got := feed.all("title")[0].text; got != "profilesearch: control valve in Pune" {
+		t.Errorf("feed title %q", got)
+	}
+	ids := entryTexts(feed, "id")
+	if len(ids) != 2 {
+		t.Fatalf("%d entries, want 2", len(ids))
+	}
+	if content := feed.all("entry")[0].all("content"); len(content) != 1 || content[0].attrs["type"] != "html" ||
+		content[0].text != "<mark>Control valve</mark> design &amp; sizing" {
+		t.Errorf("jane-doe content %+v, want the highlighted snippet as HTML", content)
+	}
+
+	// A later run finds jane-doe again, under a tracked URL, and one new
+	// candidate. Jane keeps her entry; the feed keeps the newest two.
+	later := first.Add(24 * time.Hour)
+	info.Timestamp = later
+	jane.ProfileURL += "?trk=public_profile"
+	ann := Candidate{Name: "Ann Poe", ProfileURL: "https://www.linkedin.com/in/ann-poe"}
+	if err := writeToAtom([]Candidate{jane, ann}, filename, 2, info); err != nil {
+		t.Fatal(err)
+	}
+	if b, err = os.ReadFile(filename); err != nil {
+		t.Fatal(err)
+	}
+	feed = validateAtom(t, b)
+	again := entryTexts(feed, "id")
+	if len(again) != 2 || again[1] != ids[0] {
+		t.Fatalf("entry ids %q, want ann-poe's then jane-doe's %s", again, ids[0])
+	}
+	if updated := entryTexts(feed, "updated"); updated[0] != later.Format(time.RFC3339) || updated[1] != first.Format(time.RFC3339) {
+		t.Errorf("entries updated %q, want ann-poe at %v and jane-doe still at %v", updated, later, first)
+	}
+	if got := feed.all("updated")[0].text; got != later.Format(time.RFC3339) {
+		t.Errorf("feed updated %s, want %v", got, later)
+	}
+}
+
+func TestAtomFormatRun(t *testing.T) {
+	_, addr := startFakeWeb(t, fakeRoster(3))
+	output := filepath.Join(t.TempDir(), "feed.xml")
+	stats = newRunStats()
+	if err := runSearchCommand(context.Background(), []string{"-fake-web", addr, "-max-pages", "1", "-format", "atom", "-output", output}); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if titles := entryTexts(validateAtom(t, b), "title"); len(titles) != 3 || !strings.HasPrefix(titles[0], "Member 1") {
+		t.Errorf("entry titles %q, want the three members", titles)
+	}
+
+	if _, err := parseFlags([]string{"-format", "atom", "-flush-every", "10"}); err == nil {
+		t.Error("-flush-every accepted with -format atom")
+	}
+}
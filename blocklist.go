@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// defaultBlockedNames are the placeholder and test names dropped unless
+// -blocklist-names replaces them, in the same format as the file.
+const defaultBlockedNames = `# Shown for profiles outside the viewer's network.
+LinkedIn Member
+Private Profile
+Anonymous User
+# Test and spam accounts.
+Test User
+Test Account
+Test Profile
+`
+
+// nameBlocklist holds names that mark a candidate as junk. Each entry matches
+// a whole name, case-insensitively and ignoring extra spaces; * in an entry
+// matches any run of characters. A nil nameBlocklist matches nothing.
+type nameBlocklist struct {
+	entries  []string // As written, for explain output.
+	patterns []*regexp.Regexp
+}
+
+// parseNameBlocklist reads entries, one per line. Blank lines and # comments
+// are ignored.
+func parseNameBlocklist(r io.Reader) (*nameBlocklist, error) {
+	b := &nameBlocklist{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		entry := strings.Join(strings.Fields(scanner.Text()), " ")
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+		parts := strings.Split(entry, "*")
+		for i, part := range parts {
+			parts[i] = regexp.QuoteMeta(part)
+		}
+		b.entries = append(b.entries, entry)
+		b.patterns = append(b.patterns, regexp.MustCompile(`(?i)^`+strings.Join(parts, ".*")+`$`))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// loadNameBlocklist reads a -blocklist-names file.
+func loadNameBlocklist(filename string) (*nameBlocklist, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open name blocklist: %w", err)
+	}
+	defer file.Close()
+	b, err := parseNameBlocklist(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read name blocklist: %w", err)
+	}
+	return b, nil
+}
+
+// match returns the entry name matches, or "".
+func (b *nameBlocklist) match(name string) string {
+	if b == nil {
+		return ""
+	}
+	name = strings.Join(strings.Fields(name), " ")
+	for i, p := range b.patterns {
+		if p.MatchString(name) {
+			return b.entries[i]
+		}
+	}
+	return ""
+}
+
+// size returns the number of entries.
+func (b *nameBlocklist) size() int {
+	if b == nil {
+		return 0
+	}
+	return len(b.entries)
+}
+
+// filter returns a filter dropping candidates whose name is blocked.
+// Anonymized results pass, as -anonymized decides what becomes of them.
+func (b *nameBlocklist) filter() candidateFilter {
+	return func(c Candidate) filterDecision {
+		name := resultName(c)
+		entry := ""
+		if !c.Anonymized {
+			entry = b.match(name)
+		}
+		d := filterDecision{Filter: "blocklist_names", Passed: entry == "", Expected: "name not on the blocklist", Actual: name}
+		if entry != "" {
+			d.Actual = fmt.Sprintf("%s (matches %q)", name, entry)
+		}
+		return d
+	}
+}
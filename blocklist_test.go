@@ -0,0 +1,121 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestNameBlocklistMatch(t *testing.T) {
+	defaults, err := parseNameBlocklist(strings.NewReader(defaultBlockedNames))
+	if err != nil {
+		t.Fatal(err)
+	}
+	custom, err := parseNameBlocklist(strings.NewReader("# Bots.\nAcme * Bot\n\n  qa   tester  \n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		b     *nameBlocklist
+		name  string
+		entry string
+	}{
+		{defaults, "LinkedIn Member", "LinkedIn Member"},
+		{defaults, "  linkedin   MEMBER ", "LinkedIn Member"},
+		{defaults, "Private profile", "Private Profile"},
+		{defaults, "Jane Doe", ""},
+		{defaults, "LinkedIn Members Group", ""},
+		{defaults, "Test Userman", ""},
+		{custom, "Acme Sales Bot", "Acme * Bot"},
+		{custom, "ACME crawler bot", "Acme * Bot"},
+		{custom, "Acme Bottling", ""},
+		{custom, "QA Tester", "qa tester"},
+		{custom, "LinkedIn Member", ""},
+		{nil, "LinkedIn Member", ""},
+	}
+	for _, tt := range tests {
+		if got := tt.b.match(tt.name); got != tt.entry {
+			t.Errorf("match(%q) = %q, want %q", tt.name, got, tt.entry)
+		}
+	}
+	if custom.size() != 2 || (*nameBlocklist)(nil).size() != 0 {
+		t.Errorf("sizes %d and %d, want 2 and 0", custom.size(), (*nameBlocklist)(nil).size())
+	}
+}
+
+func TestBlocklistFilterDropsLinkedInMember(t *testing.T) {
+	b, err := parseNameBlocklist(strings.NewReader(defaultBlockedNames))
+	if err != nil {
+		t.Fatal(err)
+	}
+	filter := b.filter()
+	// The profile page named the member; the result did not give it away.
+	member := Candidate{Name: "LinkedIn Member", ProfileURL: "https://www.linkedin.com/in/jane-doe"}
+	if d := filter(member); d.Passed || d.Filter != "blocklist_names" || d.Actual != `LinkedIn Member (matches "LinkedIn Member")` {
+		t.Errorf("decision %+v, want the row dropped with the entry it matched", d)
+	}
+	// The name in the result title counts when none was extracted.
+	if d := filter(Candidate{ResultTitle: "Test User - QA - Acme | LinkedIn"}); d.Passed {
+		t.Errorf("decision %+v, want the result title's name blocked", d)
+	}
+	// Anonymized results are left to -anonymized.
+	if d := filter(Candidate{Name: "LinkedIn Member", Anonymized: true}); !d.Passed {
+		t.Errorf("decision %+v, want an anonymized result passed", d)
+	}
+	if d := filter(Candidate{Name: "Jane Doe"}); !d.Passed {
+		t.Errorf("decision %+v, want Jane Doe kept", d)
+	}
+}
+
+func TestBlocklistNames(t *testing.T) {
+	_, addr := startFakeWeb(t, `profiles:
+  - slug: jane-doe
+    name: Jane Doe
+  - slug: test-user
+    name: Test User
+  - slug: private-profile
+    name: Private Profile
+  - slug: acme-sales-bot
+    name: Acme Sales Bot
+`)
+	kept := func(args ...string) []string {
+		t.Helper()
+		output, err := runFakeSearch(t, addr, append([]string{"-max-pages", "1"}, args...)...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, c := range readFakeSearch(t, output) {
+			names = append(names, c.Name)
+		}
+		return names
+	}
+
+	// The built-in list drops the placeholder and test names.
+	if got := kept(); !slices.Equal(got, []string{"Jane Doe", "Acme Sales Bot"}) {
+		t.Errorf("kept %q by default, want Jane Doe and the bot", got)
+	}
+	// A file replaces it.
+	dir := t.TempDir()
+	file := filepath.Join(dir, "blocked.txt")
+	if err := os.WriteFile(file, []byte("# Our own bots.\nacme * bot\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := kept("-blocklist-names", file); !slices.Equal(got, []string{"Jane Doe", "Test User", "Private Profile"}) {
+		t.Errorf("kept %q with the file, want all but the bot", got)
+	}
+	// An empty file turns the filter off.
+	empty := filepath.Join(dir, "empty.txt")
+	if err := os.WriteFile(empty, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := kept("-blocklist-names", empty); len(got) != 4 {
+		t.Errorf("kept %q with an empty file, want all 4", got)
+	}
+
+	if _, err := parseFlags([]string{"-blocklist-names", filepath.Join(dir, "missing.txt")}); err == nil {
+		t.Error("a missing -blocklist-names file accepted")
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// Types of export-changes lines.
+const (
+	changeUpsert        = "upsert"          // The entry as stored now.
+	changeDeleted       = "deleted"         // The entry was removed from the store.
+	changeHighWaterMark = "high_water_mark" // The last line: where the next export starts.
+)
+
+// storeChange is one line of export-changes output.
+type storeChange struct {
+	Type      string           `json:"type"`
+	Version   int64            `json:"version"`
+	Candidate *StoredCandidate `json:"candidate,omitempty"`   // For upsert.
+	Profile   string           `json:"profile_url,omitempty"` // For deleted.
+	Reason    string           `json:"reason,omitempty"`      // For deleted: a tombstone reason.
+	More      bool             `json:"more,omitempty"`        // For high_water_mark: limit cut the export short.
+}
+
+// changesSince returns the entries and tombstones with a version above since,
+// ordered by version, and the version to pass as since for the next page: the
+// last one returned, or since when there is nothing new. With limit above 0
+// it returns at most limit changes, and more reports whether others remain.
+func (s *candidateStore) changesSince(since int64, limit int) (changes []storeChange, next int64, more bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, sc := range s.data.Candidates {
+		if sc.Version > since {
+			changes = append(changes, storeChange{Type: changeUpsert, Version: sc.Version, Candidate: sc})
+		}
+	}
+	for _, t := range s.data.Tombstones {
+		if t.Version > since {
+			changes = append(changes, storeChange{Type: changeDeleted, Version: t.Version, Profile: t.ProfileURL, Reason: t.Reason})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Version < changes[j].Version })
+	if limit > 0 && len(changes) > limit {
+		changes, more = changes[:limit], true
+	}
+	next = since
+	if len(changes) > 0 {
+		next = changes[len(changes)-1].Version
+	}
+	return changes, next, more
+}
+
+// writeChanges writes changes as JSON lines to w, followed by the high-water
+// mark line.
+func writeChanges(w io.Writer, changes []storeChange, next int64, more bool) error {
+	enc := json.NewEncoder(w)
+	for _, c := range changes {
+		if err := enc.Encode(c); err != nil {
+			return err
+		}
+	}
+	return enc.Encode(storeChange{Type: changeHighWaterMark, Version: next, More: more})
+}
+
+// runExportChangesCommand writes what changed in a store since a version,
+// for consumers syncing from it.
+func runExportChangesCommand(args []string) error {
+	fs := flag.NewFlagSet("export-changes", flag.ExitOnError)
+	storePath := fs.String("store", "", "candidate store")
+	since := fs.Int64("since-version", 0, "export the changes after this version, the high-water mark of the previous export (0 exports every entry)")
+	limit := fs.Int("limit", 0, "export at most this many changes; the high-water mark line says whether more remain (0 exports all)")
+	format := fs.String("format", "jsonl", "output format: jsonl")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: profilesearch export-changes -store file [-since-version N] [-limit N] [-format jsonl]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	switch {
+	case *storePath == "":
+		return errors.New("export-changes needs -store")
+	case *format != "jsonl":
+		return fmt.Errorf("invalid -format %q: want jsonl", *format)
+	case *since < 0:
+		return errors.New("invalid -since-version: must not be negative")
+	case *limit < 0:
+		return errors.New("invalid -limit: must not be negative")
+	}
+	store, err := openStore(*storePath)
+	if err != nil {
+		return err
+	}
+	changes, next, more := store.changesSince(*since, *limit)
+	return writeChanges(os.Stdout, changes, next, more)
+}
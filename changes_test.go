@@ -0,0 +1,216 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	janeURL  = "https://www.linkedin.com/in/jane-doe"
+	johnURL  = "https://www.linkedin.com/in/john-roe"
+	raviURL  = "https://www.linkedin.com/in/ravi-kumar"
+	meeraURL = "https://www.linkedin.com/in/meera-iyer"
+)
+
+// seedChangesStore returns a store saved at path after a history of
+// upserts and removals:
+//
+//	1 jane added, 2 john added, 3 ravi added, 4 jane's phone found,
+//	5 john removed, 6 meera added, 7 ravi opted out
+func seedChangesStore(t *testing.T, path string) *candidateStore {
+	t.Helper()
+	store, err := openStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	store.upsert([]Candidate{
+		{ProfileURL: janeURL, Name: "Jane Doe", Email: "jane@example.com"},
+		{ProfileURL: johnURL, Name: "John Roe"},
+		{ProfileURL: raviURL, Name: "Ravi Kumar"},
+	}, at)
+	store.upsert([]Candidate{{ProfileURL: janeURL, Name: "Jane Doe", Email: "jane@example.com", Phone: "+91 98765 43210"}}, at.Add(time.Hour))
+	store.remove([]string{johnURL}, tombstoneRemoved, at.Add(2*time.Hour))
+	store.upsert([]Candidate{{ProfileURL: meeraURL, Name: "Meera Iyer"}}, at.Add(3*time.Hour))
+	store.remove([]string{raviURL}, tombstoneOptedOut, at.Add(4*time.Hour))
+	if err := store.save(); err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+// changeKeys summarizes changes as "version type profile" strings.
+func changeKeys(changes []storeChange) []string {
+	keys := make([]string, len(changes))
+	for i, c := range changes {
+		profile := profileSlug(c.Profile) + " " + c.Reason
+		if c.Candidate != nil {
+			profile = profileSlug(c.Candidate.ProfileURL)
+		}
+		keys[i] = strings.Join([]string{strconv.FormatInt(c.Version, 10), c.Type, profile}, " ")
+	}
+	return keys
+}
+
+func TestStoreVersionBumps(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "candidates.json")
+	store, err := openStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	jane := Candidate{ProfileURL: janeURL, Name: "Jane Doe", Email: "jane@example.com", Rank: 1}
+	store.upsert([]Candidate{jane, {ProfileURL: johnURL, Name: "John Roe"}}, at)
+	version := func(url string) int64 {
+		t.Helper()
+		for _, sc := range store.candidates() {
+			if sc.ProfileURL == url {
+				return sc.Version
+			}
+		}
+		t.Fatalf("%s not stored", url)
+		return 0
+	}
+	if version(janeURL) != 1 || version(johnURL) != 2 || store.data.Sequence != 2 {
+		t.Fatalf("versions %d and %d, sequence %d; want 1, 2, 2", version(janeURL), version(johnURL), store.data.Sequence)
+	}
+
+	// Found again, later and elsewhere in the results, with nothing new: no
+	// bump.
+	again := jane
+	again.Rank, again.PagePosition, again.OverallPosition, again.Job = 7, 3, 13, "backend"
+	store.upsert([]Candidate{again}, at.Add(24*time.Hour))
+	if version(janeURL) != 1 || store.data.Sequence != 2 {
+		t.Errorf("no-op upsert moved jane to version %d, sequence %d", version(janeURL), store.data.Sequence)
+	}
+	// A new value bumps the entry past every other.
+	again.Location = "Pune"
+	store.upsert([]Candidate{again}, at.Add(48*time.Hour))
+	if version(janeURL) != 3 || version(johnURL) != 2 || store.data.Sequence != 3 {
+		t.Errorf("versions %d and %d, sequence %d after jane changed; want 3, 2, 3", version(janeURL), version(johnURL), store.data.Sequence)
+	}
+
+	// Versions survive a reload, and the sequence carries on.
+	if err := store.save(); err != nil {
+		t.Fatal(err)
+	}
+	if store, err = openStore(path); err != nil {
+		t.Fatal(err)
+	}
+	store.upsert([]Candidate{{ProfileURL: meeraURL, Name: "Meera Iyer"}}, at.Add(72*time.Hour))
+	if version(janeURL) != 3 || version(meeraURL) != 4 {
+		t.Errorf("versions %d and %d after reload, want 3 and 4", version(janeURL), version(meeraURL))
+	}
+}
+
+func TestStoreVersionsUnversionedEntries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "candidates.json")
+	old := `{"candidates": [{"name": "Jane Doe", "profile_url": "` + janeURL + `"}, {"name": "John Roe", "profile_url": "` + johnURL + `"}]}`
+	if err := os.WriteFile(path, []byte(old), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	store, err := openStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes, next, _ := store.changesSince(0, 0)
+	if got := strings.Join(changeKeys(changes), "; "); got != "1 upsert jane-doe; 2 upsert john-roe" || next != 2 {
+		t.Errorf("changes of a store from before versioning: %s up to %d", got, next)
+	}
+}
+
+func TestChangesSince(t *testing.T) {
+	store := seedChangesStore(t, filepath.Join(t.TempDir(), "candidates.json"))
+	tests := []struct {
+		since int64
+		want  []string
+		next  int64
+	}{
+		// Removed entries only appear as tombstones; jane once, as of 4.
+		{0, []string{"4 upsert jane-doe", "5 deleted john-roe removed", "6 upsert meera-iyer", "7 deleted ravi-kumar opted_out"}, 7},
+		{4, []string{"5 deleted john-roe removed", "6 upsert meera-iyer", "7 deleted ravi-kumar opted_out"}, 7},
+		{6, []string{"7 deleted ravi-kumar opted_out"}, 7},
+		// Nothing new: the mark stays where it was.
+		{7, nil, 7},
+		{100, nil, 100},
+	}
+	for _, tt := range tests {
+		changes, next, more := store.changesSince(tt.since, 0)
+		if got := changeKeys(changes); strings.Join(got, "; ") != strings.Join(tt.want, "; ") || next != tt.next || more {
+			t.Errorf("since %d: %q up to %d (more %v), want %q up to %d", tt.since, got, next, more, tt.want, tt.next)
+		}
+	}
+	if changes, _, _ := store.changesSince(0, 0); changes[0].Candidate.Phone == "" {
+		t.Error("jane's change lacks the phone that bumped it")
+	}
+}
+
+func TestChangesSincePaging(t *testing.T) {
+	store := seedChangesStore(t, filepath.Join(t.TempDir(), "candidates.json"))
+	whole, _, _ := store.changesSince(0, 0)
+	var paged []storeChange
+	var marks []int64
+	since := int64(0)
+	for {
+		changes, next, more := store.changesSince(since, 3)
+		if len(changes) > 3 {
+			t.Fatalf("page of %d changes, want at most 3", len(changes))
+		}
+		paged = append(paged, changes...)
+		marks = append(marks, next)
+		if !more {
+			break
+		}
+		since = next
+	}
+	if strings.Join(changeKeys(paged), "; ") != strings.Join(changeKeys(whole), "; ") {
+		t.Errorf("pages %q, want every change once, in order: %q", changeKeys(paged), changeKeys(whole))
+	}
+	if len(marks) != 2 || marks[0] != 6 || marks[1] != 7 {
+		t.Errorf("high-water marks %v, want 6 then 7", marks)
+	}
+	// A page that ends exactly at the last change says there is no more.
+	if _, next, more := store.changesSince(3, 4); next != 7 || more {
+		t.Errorf("last full page ends at %d, more %v; want 7 and none", next, more)
+	}
+}
+
+func TestExportChangesCommand(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "candidates.json")
+	seedChangesStore(t, path)
+	out, err := captureStdout(t, func() error {
+		return runExportChangesCommand([]string{"-store", path, "-since-version", "4", "-limit", "2", "-format", "jsonl"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+	want := []string{
+		`{"type":"deleted","version":5,"profile_url":"` + johnURL + `","reason":"removed"}`,
+		`"type":"upsert","version":6,"candidate":{`,
+		`{"type":"high_water_mark","version":6,"more":true}`,
+	}
+	if len(lines) != len(want) || lines[0] != want[0] || !strings.HasPrefix(lines[1], "{"+want[1]) || lines[2] != want[2] {
+		t.Fatalf("export-changes wrote\n%s\nwant lines like\n%s", out, strings.Join(want, "\n"))
+	}
+	var meera storeChange
+	if err := json.Unmarshal([]byte(lines[1]), &meera); err != nil || meera.Candidate.Name != "Meera Iyer" || meera.Candidate.Version != 6 {
+		t.Errorf("upsert line %s: %v", lines[1], err)
+	}
+
+	for _, args := range [][]string{
+		{"-since-version", "4"},
+		{"-store", path, "-format", "csv"},
+		{"-store", path, "-since-version", "-1"},
+		{"-store", path, "-limit", "-1"},
+	} {
+		if err := runExportChangesCommand(args); err == nil {
+			t.Errorf("export-changes %q accepted", args)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/mail"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// csvReaders parse the columns that -clean-existing reads back from a CSV.
+// Columns derived from others, such as city or score, are recomputed rather
+// than read.
+var csvReaders = map[string]func(c *Candidate, v string){
+	"rank":             func(c *Candidate, v string) { c.Rank, _ = strconv.Atoi(v) },
+	"page_position":    func(c *Candidate, v string) { c.PagePosition, _ = strconv.Atoi(v) },
+	"overall_position": func(c *Candidate, v string) { c.OverallPosition, _ = strconv.Atoi(v) },
+	"name":             func(c *Candidate, v string) { c.Name = v },
+	"email":            func(c *Candidate, v string) { c.Email = v },
+	"email_guess":      func(c *Candidate, v string) { c.EmailGuess = v },
+	"phone":            func(c *Candidate, v string) { c.Phone = v },
+	"title":            func(c *Candidate, v string) { c.Title = v },
+	"company":          func(c *Candidate, v string) { c.Company = v },
+	"profile_url":      func(c *Candidate, v string) { c.ProfileURL = v },
+	"rediscovered": func(c *Candidate, v string) {
+		c.Rediscovered, _ = strconv.ParseBool(v)
+	},
+	"anonymized": func(c *Candidate, v string) {
+		c.Anonymized, _ = strconv.ParseBool(v)
+	},
+	"result_type":    func(c *Candidate, v string) { c.ResultType = v },
+	"alternate_urls": func(c *Candidate, v string) { c.AlternateURLs = strings.Fields(v) },
+	"previously_seen": func(c *Candidate, v string) {
+		if seen, err := time.Parse("2006-01-02", v); err == nil {
+			c.PreviouslySeen = &seen
+		}
+	},
+	"experience": func(c *Candidate, v string) { // Old files only have whole years.
+		if c.ExperienceYears == 0 {
+			c.setExperienceYears(parseExperienceYears(v))
+		}
+	},
+	"experience_years": func(c *Candidate, v string) { c.setExperienceYears(parseExperienceYears(v)) },
+	"company_size":     func(c *Candidate, v string) { c.CompanySizeBand = v },
+	"company_type":     func(c *Candidate, v string) { c.CompanyType = v },
+	"relaxation_level": func(c *Candidate, v string) { c.RelaxationLevel, _ = strconv.Atoi(v) },
+	"location":         func(c *Candidate, v string) { c.Location = v },
+	"page_language":    func(c *Candidate, v string) { c.PageLanguage = normalizeLanguageTag(v) },
+	"website":          func(c *Candidate, v string) { c.Website = v },
+	"twitter":          func(c *Candidate, v string) { c.Twitter = v },
+	"matched_terms": func(c *Candidate, v string) {
+		for _, t := range strings.Split(v, ";") {
+			if t = strings.TrimSpace(t); t != "" {
+				c.MatchedTerms = append(c.MatchedTerms, t)
+			}
+		}
+	},
+	"summary": func(c *Candidate, v string) { c.Summary = v },
+	"job":     func(c *Candidate, v string) { c.Job = v },
+}
+
+// csvColumnByHeader finds the column a CSV header names, by its header or
+// its key, ignoring case.
+func csvColumnByHeader(h string) (csvColumn, bool) {
+	h = contactedHeader(h)
+	for _, col := range csvColumns {
+		if strings.ToLower(col.header) == h || col.key == h {
+			return col, true
+		}
+	}
+	return csvColumn{}, false
+}
+
+// readCandidatesCSV reads candidates from a CSV this tool wrote, in any
+// -csv-encoding and delimiter, skipping the run info block. It also returns the file's
+// known columns in file order.
+func readCandidatesCSV(filename string) ([]Candidate, []csvColumn, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open %s: %w", filename, err)
+	}
+	if data, err = decodeCSV(data); err != nil {
+		return nil, nil, fmt.Errorf("failed to read %s: %w", filename, err)
+	}
+
+	r := csv.NewReader(bytes.NewReader(data))
+	r.Comma = sniffDelimiter(data)
+	r.Comment = '#'
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	header, err := r.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read header of %s: %w", filename, err)
+	}
+	var columns []csvColumn
+	readers := make([]func(*Candidate, string), len(header))
+	for i, h := range header {
+		col, ok := csvColumnByHeader(h)
+		if !ok {
+			log.Printf("Ignoring unknown column %q of %s.", h, filename)
+			continue
+		}
+		columns = append(columns, col)
+		readers[i] = csvReaders[col.key]
+	}
+	if !hasCSVColumn(columns, "profile_url") {
+		return nil, nil, fmt.Errorf("%s has no Profile URL column", filename)
+	}
+
+	var candidates []Candidate
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read %s: %w", filename, err)
+		}
+		var c Candidate
+		for i, v := range record {
+			if i < len(readers) && readers[i] != nil {
+				readers[i](&c, strings.TrimSpace(v))
+			}
+		}
+		candidates = append(candidates, c)
+	}
+	return candidates, columns, nil
+}
+
+// validEmail reports whether email is a single well-formed address.
+func validEmail(email string) bool {
+	if emailMatcher.FindString(email) != email {
+		return false
+	}
+	parsed, err := mail.ParseAddress(email)
+	return err == nil && parsed.Address == email
+}
+
+// cleanCandidates drops rows without a profile URL, merges the rows of each
+// profile into its first with the -merge-strategy, and clears malformed
+// emails. It returns what it kept and counts of what it changed.
+func cleanCandidates(candidates []Candidate) (kept []Candidate, junk, duplicates, badEmails int) {
+	seen := make(map[string]int) // Canonical profile URL to position in kept.
+	for _, c := range candidates {
+		if profileSlug(c.ProfileURL) == "" {
+			junk++
+			continue
+		}
+		if c.Email != "" && !validEmail(c.Email) {
+			verbosef("Clearing malformed email %q of %s", c.Email, c.ProfileURL)
+			c.Email = ""
+			badEmails++
+		}
+		key := canonicalProfileURL(c.ProfileURL)
+		if i, ok := seen[key]; ok {
+			mergeDuplicate(&kept[i], c)
+			duplicates++
+			continue
+		}
+		seen[key] = len(kept)
+		kept = append(kept, c)
+	}
+	return kept, junk, duplicates, badEmails
+}
+
+// runCleanExisting applies the current validation, de-duplication,
+// normalization, and filters to the CSV named by -clean-existing and writes
+// the result to -output, without fetching anything.
+func runCleanExisting(cfg *config) error {
+	candidates, columns, err := readCandidatesCSV(cfg.cleanExisting)
+	if err != nil {
+		return err
+	}
+	read := len(candidates)
+	candidates, junk, duplicates, badEmails := cleanCandidates(candidates)
+	// Derived columns are recomputed here; the input's values are not read.
+	candidates = finalizeCandidates(cfg, cfg.criteria, candidates)
+	if cfg.columnsGiven {
+		columns = cfg.columns
+	}
+	if len(candidates) == 0 {
+		return errors.New("no candidates left after cleaning")
+	}
+	if err := cfg.outputs.deliver(outputCSV, func() error {
+		return writeOutput(cfg, candidates, cfg.output, columns, cfg.criteria, "")
+	}); err != nil {
+		return err
+	}
+	fmt.Printf("Read %d rows from %s: dropped %d without a profile URL, %d duplicates, and %d by filters; cleared %d malformed emails.\n",
+		read, cfg.cleanExisting, junk, duplicates, read-junk-duplicates-len(candidates), badEmails)
+	fmt.Printf("Wrote %d candidates to %s\n", len(candidates), cfg.output)
+	return nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestValidEmail(t *testing.T) {
+	for email, want := range map[string]bool{
+		"jane@example.com":                   true,
+		"jane.doe+valves@mail.example.co.in": true,
+		"jane@example":                       false,
+		"jane@@example.com":                  false,
+		"jane at example dot com":            false,
+		"jane@example.com, john@example.com": false,
+		"Jane <jane@example.com>":            false,
+	} {
+		if got := validEmail(email); got != want {
+			t.Errorf("validEmail(%q) = %v, want %v", email, got, want)
+		}
+	}
+}
+
+func TestCleanExisting(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "old.csv")
+	// Headers by key or by header, in any order; a duplicate under a tracked
+	// URL, a malformed email, and a row that is no profile at all.
+	old := `name,Email,Profile URL,title
+Jane Doe,jane@example.com,https://www.linkedin.com/in/jane-doe,
+Jane Doe,,https://www.linkedin.com/in/jane-doe?trk=public_profile,Valve Engineer
+John Roe,john@@example.com,https://www.linkedin.com/in/john-roe,Design Engineer
+About us,,https://example.com/about,
+`
+	if err := os.WriteFile(in, []byte(old), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "clean.csv")
+	if err := runSearchCommand(context.Background(), []string{"-clean-existing", in, "-output", out}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, columns, err := readCandidatesCSV(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("cleaned to %d rows, want jane-doe and john-roe: %+v", len(got), got)
+	}
+	jane, john := got[0], got[1]
+	if jane.Email != "jane@example.com" || jane.Title != "Valve Engineer" {
+		t.Errorf("jane-doe cleaned to %+v, want one row with the duplicate's title", jane)
+	}
+	if john.Email != "" || john.Title != "Design Engineer" {
+		t.Errorf("john-roe cleaned to %+v, want the malformed email cleared", john)
+	}
+	var keys []string
+	for _, col := range columns {
+		keys = append(keys, col.key)
+	}
+	if len(keys) != 4 || keys[0] != "name" || keys[2] != "profile_url" {
+		t.Errorf("columns %v, want the input's", keys)
+	}
+
+	// A file with nothing left to write is an error, not an empty output.
+	junk := filepath.Join(dir, "junk.csv")
+	if err := os.WriteFile(junk, []byte("name,profile_url\nAbout us,https://example.com/about\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := runSearchCommand(context.Background(), []string{"-clean-existing", junk, "-output", filepath.Join(dir, "none.csv")}); err == nil {
+		t.Error("cleaning a file of junk rows succeeded")
+	}
+}
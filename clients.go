@@ -0,0 +1,108 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// requestTimeout bounds every fetch, redirects included.
+const requestTimeout = 10 * time.Second
+
+// clientKey identifies the client for requests of one identity through one
+// proxy, or "" for none.
+type clientKey struct {
+	proxy    string
+	identity *identity
+}
+
+// clientManager builds one HTTP client per proxy and identity and reuses it,
+// so requests share pooled connections. Clients are never mixed across
+// identities, which would let connections carry activity from one host class
+// to the other. It is safe for concurrent use.
+type clientManager struct {
+	mu      sync.Mutex
+	clients map[clientKey]*http.Client
+
+	opened, reused atomic.Int64 // Connections requests got.
+}
+
+func newClientManager() *clientManager {
+	return &clientManager{clients: make(map[clientKey]*http.Client)}
+}
+
+// client returns the client for id's requests through proxy, building it on
+// first use. Its cookies are id's current session.
+func (m *clientManager) client(proxy string, id *identity) *http.Client {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key := clientKey{proxy, id}
+	c := m.clients[key]
+	if c == nil {
+		c = newProxyClient(proxy)
+		c.Jar = identityJar{id}
+		m.clients[key] = c
+	}
+	return c
+}
+
+// countConn records whether a request got a new connection or reused one.
+func (m *clientManager) countConn(reused bool) {
+	if reused {
+		m.reused.Add(1)
+	} else {
+		m.opened.Add(1)
+	}
+}
+
+// connections returns the connections opened and reused so far.
+func (m *clientManager) connections() (opened, reused int64) {
+	return m.opened.Load(), m.reused.Load()
+}
+
+// closeIdle closes the idle connections of every client, as the run or a
+// session ends.
+func (m *clientManager) closeIdle() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, c := range m.clients {
+		c.CloseIdleConnections()
+	}
+}
+
+// identityJar is the cookie jar of an identity's current session, which
+// ResetSession replaces.
+type identityJar struct {
+	id *identity
+}
+
+func (j identityJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	jar, _ := j.id.session()
+	jar.SetCookies(u, cookies)
+}
+
+func (j identityJar) Cookies(u *url.URL) []*http.Cookie {
+	jar, _ := j.id.session()
+	return jar.Cookies(u)
+}
+
+// newProxyClient returns an HTTP client with a transport of its own, using
+// proxy. If proxy is empty or invalid, it connects directly. With -fake-web,
+// every request goes to the fake server instead.
+func newProxyClient(proxy string) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if fakeWebAddr != "" {
+		return &http.Client{Transport: fakeWebTransport{addr: fakeWebAddr, next: transport}, CheckRedirect: stopAtAuthwall, Timeout: requestTimeout}
+	}
+	if proxy != "" {
+		if proxyURL, err := url.Parse(proxy); err != nil {
+			log.Println("Invalid proxy URL:", err)
+		} else {
+			transport.Proxy = http.ProxyURL(proxyURL)
+		}
+	}
+	return &http.Client{Transport: transport, CheckRedirect: stopAtAuthwall, Timeout: requestTimeout}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// connWatcher counts the connections a test server accepts and closes.
+type connWatcher struct {
+	mu             sync.Mutex
+	opened, closed int
+}
+
+func (w *connWatcher) observe(_ net.Conn, state http.ConnState) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	switch state {
+	case http.StateNew:
+		w.opened++
+	case http.StateClosed, http.StateHijacked:
+		w.closed++
+	}
+}
+
+func (w *connWatcher) counts() (opened, closed int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.opened, w.closed
+}
+
+// startProxy starts an HTTP proxy answering every request itself, and
+// returns its URL and the watcher of its connections.
+func startProxy(t *testing.T) (string, *connWatcher) {
+	t.Helper()
+	watcher := &connWatcher{}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "<html><body>%s</body></html>", r.URL)
+	}))
+	srv.Config.ConnState = watcher.observe
+	srv.Start()
+	t.Cleanup(srv.Close)
+	return srv.URL, watcher
+}
+
+// proxiedFetcher returns a fetcher sending every request through proxy,
+// without delays or robots.txt checks.
+func proxiedFetcher(t *testing.T, proxy string) *httpFetcher {
+	t.Helper()
+	identities, err := newIdentities(isolationShared, []proxyEntry{{url: proxy}}, 0, 0, rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &httpFetcher{identities: identities, clients: newClientManager()}
+}
+
+func TestClientReusesConnectionThroughProxy(t *testing.T) {
+	stats = newRunStats()
+	proxy, watcher := startProxy(t)
+	f := proxiedFetcher(t, proxy)
+	for _, page := range []string{"http://www.example.com/one", "http://www.example.com/two"} {
+		if _, err := f.Fetch(context.Background(), page); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if opened, _ := watcher.counts(); opened != 1 {
+		t.Errorf("proxy accepted %d connections, want 1", opened)
+	}
+	if opened, reused := f.clients.connections(); opened != 1 || reused != 1 {
+		t.Errorf("counted %d connections opened and %d reused, want 1 and 1", opened, reused)
+	}
+	if n := len(f.clients.clients); n != 1 {
+		t.Errorf("%d clients built, want 1", n)
+	}
+}
+
+func TestFetcherCloseClosesIdleConnections(t *testing.T) {
+	stats = newRunStats()
+	proxy, watcher := startProxy(t)
+	f := proxiedFetcher(t, proxy)
+	if _, err := f.Fetch(context.Background(), "http://www.example.com/"); err != nil {
+		t.Fatal(err)
+	}
+	if _, closed := watcher.counts(); closed != 0 {
+		t.Fatalf("%d connections closed before shutdown, want the idle one kept", closed)
+	}
+
+	f.close()
+	// The server learns of the close when it next reads the connection.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, closed := watcher.counts(); closed == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("idle connection still open after close")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestClientsKeptApartByProxyAndIdentity(t *testing.T) {
+	m := newClientManager()
+	rng := rand.New(rand.NewSource(1))
+	a, b := newIdentity(hostClassSearch, nil, 0, 0, rng), newIdentity(hostClassProfile, nil, 0, 0, rng)
+	if m.client("http://p1:8080", a) != m.client("http://p1:8080", a) {
+		t.Error("same proxy and identity got two clients")
+	}
+	if m.client("http://p1:8080", a) == m.client("http://p2:8080", a) {
+		t.Error("two proxies share a client")
+	}
+	if m.client("http://p1:8080", a) == m.client("http://p1:8080", b) {
+		t.Error("two identities share a client")
+	}
+}
+
+func TestResetSessionClosesConnectionsAndRotatesProxy(t *testing.T) {
+	stats = newRunStats()
+	proxyA, watcherA := startProxy(t)
+	proxyB, watcherB := startProxy(t)
+	identities, err := newIdentities(isolationShared, []proxyEntry{{url: proxyA}, {url: proxyB}}, 0, 0, rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &httpFetcher{identities: identities, clients: newClientManager()}
+	watchers := []*connWatcher{watcherA, watcherB}
+
+	// fetch returns the watcher of the proxy a new request went through.
+	fetch := func() *connWatcher {
+		t.Helper()
+		var before [2]int
+		for i, w := range watchers {
+			before[i], _ = w.counts()
+		}
+		if _, err := f.Fetch(context.Background(), "http://www.example.com/"); err != nil {
+			t.Fatal(err)
+		}
+		for i, w := range watchers {
+			if opened, _ := w.counts(); opened > before[i] {
+				return w
+			}
+		}
+		t.Fatal("request reused a connection of the old session")
+		return nil
+	}
+
+	used := fetch()
+	for i := range 6 {
+		f.ResetSession()
+		// The server learns of the close when it next reads the connection.
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			if opened, closed := used.counts(); closed == opened {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("reset %d: connection of the old session still open", i+1)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		next := fetch()
+		if next == used {
+			t.Errorf("reset %d: new session went through the old session's proxy", i+1)
+		}
+		used = next
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// companySizeBand is one of LinkedIn's company size bands.
+type companySizeBand struct {
+	Label string
+	Min   int
+	Max   int // 0 means unbounded.
+}
+
+// companySizeBands are the normalized bands, smallest first. Their order
+// defines the ordering used by -min-company-size and -max-company-size.
+var companySizeBands = []companySizeBand{
+	{"1-10", 1, 10},
+	{"11-50", 11, 50},
+	{"51-200", 51, 200},
+	{"201-500", 201, 500},
+	{"501-1000", 501, 1000},
+	{"1001-5000", 1001, 5000},
+	{"5001-10000", 5001, 10000},
+	{"10001+", 10001, 0},
+}
+
+// companyTypes maps the phrases LinkedIn uses for company types to the values
+// stored in Candidate.CompanyType.
+var companyTypes = []struct {
+	phrase string
+	value  string
+}{
+	{"public company", "public"},
+	{"privately held", "private"},
+	{"self-employed", "self-employed"},
+	{"self employed", "self-employed"},
+	{"government agency", "government"},
+	{"nonprofit", "nonprofit"},
+	{"non-profit", "nonprofit"},
+	{"educational institution", "educational"},
+	{"partnership", "partnership"},
+	{"sole proprietorship", "sole-proprietorship"},
+}
+
+// employeeCountRegex matches an employee count or range. The trailing
+// "employees" is required so that years, revenue, and other figures are never
+// mistaken for a company size.
+var employeeCountRegex = regexp.MustCompile(`(?i)(\d[\d,]*)\s*(\+|(?:-|–|to)\s*(\d[\d,]*))?\s*employees\b`)
+
+// experienceCompanySelectors locate the company sub-blocks of a profile's
+// experience section; only these are scanned for size and type.
+var experienceCompanySelectors = []string{
+	"section.experience li",
+	"section[data-section='experience'] li",
+	".experience__list li",
+}
+
+// bandIndex returns the index of a band label in companySizeBands.
+func bandIndex(label string) (int, bool) {
+	label = strings.ReplaceAll(strings.ReplaceAll(strings.TrimSpace(label), ",", ""), " ", "")
+	for i, b := range companySizeBands {
+		if b.Label == label {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// bandForCount returns the band containing n employees.
+func bandForCount(n int) (companySizeBand, bool) {
+	for _, b := range companySizeBands {
+		if n >= b.Min && (b.Max == 0 || n <= b.Max) {
+			return b, true
+		}
+	}
+	return companySizeBand{}, false
+}
+
+// parseCompanySize finds an employee count in text and normalizes it to a
+// band label. Ranges are placed by their lower bound, so LinkedIn's "2-10"
+// becomes "1-10" and "10,001+" becomes "10001+".
+func parseCompanySize(text string) string {
+	m := employeeCountRegex.FindStringSubmatch(text)
+	if m == nil {
+		return ""
+	}
+	n, err := strconv.Atoi(strings.ReplaceAll(m[1], ",", ""))
+	if err != nil {
+		return ""
+	}
+	b, ok := bandForCount(n)
+	if !ok {
+		return ""
+	}
+	return b.Label
+}
+
+// parseCompanyType finds a company-type phrase in text.
+func parseCompanyType(text string) string {
+	lower := strings.ToLower(text)
+	for _, t := range companyTypes {
+		if containsWord(lower, t.phrase) {
+			return t.value
+		}
+	}
+	return ""
+}
+
+// extractCompanyFacts returns the size band and type mentioned in the
+// experience section of a profile.
+func extractCompanyFacts(doc *goquery.Document) (size, companyType string) {
+	for _, sel := range experienceCompanySelectors {
+		doc.Find(sel).EachWithBreak(func(i int, s *goquery.Selection) bool {
+			text := s.Text()
+			if size == "" {
+				size = parseCompanySize(text)
+			}
+			if companyType == "" {
+				companyType = parseCompanyType(text)
+			}
+			return size == "" || companyType == ""
+		})
+		if size != "" && companyType != "" {
+			break
+		}
+	}
+	return size, companyType
+}
+
+// companySizeFilter drops candidates whose company size band falls outside
+// [minLabel, maxLabel]; an empty label leaves that end open. Candidates with
+// no known size pass.
+func companySizeFilter(minLabel, maxLabel string) candidateFilter {
+	lo, hi := 0, len(companySizeBands)-1
+	if minLabel != "" {
+		lo, _ = bandIndex(minLabel)
+	}
+	if maxLabel != "" {
+		hi, _ = bandIndex(maxLabel)
+	}
+	expected := companySizeBands[lo].Label + " to " + companySizeBands[hi].Label
+	return func(c Candidate) filterDecision {
+		d := filterDecision{Filter: "company_size", Expected: expected, Actual: c.CompanySizeBand}
+		i, known := bandIndex(c.CompanySizeBand)
+		d.Passed = !known || (i >= lo && i <= hi)
+		if !known {
+			d.Actual = "unknown"
+		}
+		return d
+	}
+}
+
+// validateCompanySizeBand checks a -min-company-size or -max-company-size value.
+func validateCompanySizeBand(label string) error {
+	if label == "" {
+		return nil
+	}
+	if _, ok := bandIndex(label); !ok {
+		labels := make([]string, len(companySizeBands))
+		for i, b := range companySizeBands {
+			labels[i] = b.Label
+		}
+		return fmt.Errorf("unknown company size band %q (want one of %s)", label, strings.Join(labels, ", "))
+	}
+	return nil
+}
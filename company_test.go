@@ -0,0 +1,81 @@
+package main
+
+import "testing"
+
+func TestParseCompanySize(t *testing.T) {
+	tests := []struct {
+		text, want string
+	}{
+		{"Acme Valves · 10,001+ employees", "10001+"},
+		{"51-200 employees", "51-200"},
+		{"2 – 10 employees", "1-10"},
+		{"Manufacturing, 1,001 to 5,000 employees", "1001-5000"},
+		// Figures without the context word are never sizes.
+		{"Valve engineer with 15 years, 200 projects", ""},
+		{"Revenue of 5000 crore", ""},
+	}
+	for _, tt := range tests {
+		if got := parseCompanySize(tt.text); got != tt.want {
+			t.Errorf("parseCompanySize(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestParseCompanyType(t *testing.T) {
+	tests := []struct {
+		text, want string
+	}{
+		{"Emerson · Public Company · 10,001+ employees", "public"},
+		{"Privately Held", "private"},
+		{"Non-profit", "nonprofit"},
+		{"Valve engineer at a partnerships firm", ""},
+	}
+	for _, tt := range tests {
+		if got := parseCompanyType(tt.text); got != tt.want {
+			t.Errorf("parseCompanyType(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestExtractCompanyFactsFromExperienceOnly(t *testing.T) {
+	doc := parseHTML(t, `<body>
+		<section class="summary">Led a team of 51-200 employees at a public company.</section>
+		<section class="experience"><ul>
+			<li>Valve Engineer · Acme Valves · Privately Held</li>
+			<li>Trainee · Emerson · 10,001+ employees</li>
+		</ul></section></body>`)
+	size, companyType := extractCompanyFacts(doc)
+	if size != "10001+" || companyType != "private" {
+		t.Errorf("extractCompanyFacts = %q, %q; want 10001+ and private from the experience section", size, companyType)
+	}
+	if size, companyType := extractCompanyFacts(parseHTML(t, `<p>5001-10000 employees, public company</p>`)); size != "" || companyType != "" {
+		t.Errorf("page without an experience section gave %q, %q", size, companyType)
+	}
+}
+
+func TestCompanySizeFilter(t *testing.T) {
+	filter := companySizeFilter("51-200", "1001-5000")
+	tests := []struct {
+		band   string
+		passed bool
+	}{
+		{"51-200", true},
+		{"1001-5000", true},
+		{"11-50", false},
+		{"10001+", false},
+		{"", true}, // Unknown size passes.
+	}
+	for _, tt := range tests {
+		if d := filter(Candidate{CompanySizeBand: tt.band}); d.Passed != tt.passed {
+			t.Errorf("band %q: passed %v, want %v (%+v)", tt.band, d.Passed, tt.passed, d)
+		}
+	}
+	if d := companySizeFilter("", "11-50")(Candidate{CompanySizeBand: "1-10"}); !d.Passed || d.Expected != "1-10 to 11-50" {
+		t.Errorf("open minimum: %+v", d)
+	}
+	for label, valid := range map[string]bool{"": true, "10,001+": true, "201 - 500": true, "200-500": false} {
+		if err := validateCompanySizeBand(label); (err == nil) != valid {
+			t.Errorf("validateCompanySizeBand(%q) = %v", label, err)
+		}
+	}
+}
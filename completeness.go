@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Profile page selectors for the completeness signals.
+const (
+	profileHeadlineSelector  = ".top-card-layout__headline"
+	profileSkillsSelector    = "section.skills li, section[data-section='skills'] li"
+	profileEducationSelector = "section.education li, section[data-section='educationsDetails'] li"
+)
+
+// Amounts at which a completeness signal counts in full.
+const (
+	fullHeadlineLength = 40  // Characters.
+	fullSnippetWords   = 30  // Words.
+	fullSkillsCount    = 5   // Skills listed.
+	fullConnections    = 500 // LinkedIn stops counting at "500+".
+)
+
+// ghostAvatarPatterns match the URLs of LinkedIn's default profile images.
+// Photos are never downloaded, so the URL is the only evidence.
+var ghostAvatarPatterns = []string{
+	"ghost-person", "ghost_person", "default-avatar", "person-placeholder",
+	"static.licdn.com/sc/h/", "static.licdn.com/aero-v1/sc/h/",
+}
+
+// connectionsRegex matches "500+ connections" and "1,234 followers".
+var connectionsRegex = regexp.MustCompile(`(?i)(\d[\d,]*)\+?\s*(?:connections|followers)\b`)
+
+// completenessWeights are the points each signal contributes to a fully
+// complete profile.
+type completenessWeights struct {
+	Photo       int
+	Headline    int
+	Snippet     int
+	Skills      int
+	Education   int
+	Connections int
+}
+
+// defaultCompletenessWeights are used unless -completeness-weights overrides
+// them; they sum to 100.
+var defaultCompletenessWeights = completenessWeights{Photo: 25, Headline: 15, Snippet: 15, Skills: 15, Education: 15, Connections: 15}
+
+// parseCompletenessWeights parses "photo=25,headline=15,...", starting from the
+// defaults so that only the named weights change.
+func parseCompletenessWeights(spec string) (completenessWeights, error) {
+	w := defaultCompletenessWeights
+	fields := map[string]*int{
+		"photo": &w.Photo, "headline": &w.Headline, "snippet": &w.Snippet,
+		"skills": &w.Skills, "education": &w.Education, "connections": &w.Connections,
+	}
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return w, fmt.Errorf("weight %q is not key=value", pair)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || n < 0 {
+			return w, fmt.Errorf("weight %q must be a non-negative integer", pair)
+		}
+		field, ok := fields[strings.TrimSpace(key)]
+		if !ok {
+			return w, fmt.Errorf("unknown weight %q", key)
+		}
+		*field = n
+	}
+	return w, nil
+}
+
+// isGhostAvatar reports whether a photo URL is missing or one of LinkedIn's
+// default images.
+func isGhostAvatar(photoURL string) bool {
+	if photoURL == "" {
+		return true
+	}
+	lower := strings.ToLower(photoURL)
+	for _, p := range ghostAvatarPatterns {
+		if strings.Contains(lower, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseConnections returns the connection or follower count in text, or 0.
+func parseConnections(text string) int {
+	m := connectionsRegex.FindStringSubmatch(text)
+	if m == nil {
+		return 0
+	}
+	n, _ := strconv.Atoi(strings.ReplaceAll(m[1], ",", ""))
+	return n
+}
+
+// extractProfileSignals reads the completeness signals from a profile page.
+func extractProfileSignals(doc *goquery.Document, c *Candidate) {
+	if photo, ok := doc.Find(`meta[property="og:image"]`).Attr("content"); ok {
+		c.PhotoURL = strings.TrimSpace(photo)
+	}
+	c.Headline = strings.TrimSpace(doc.Find(profileHeadlineSelector).First().Text())
+	c.SkillsCount = doc.Find(profileSkillsSelector).Length()
+	c.HasEducation = doc.Find(profileEducationSelector).Length() > 0
+	c.Connections = parseConnections(doc.Find("body").Text())
+}
+
+// fraction returns n/full, capped at 1.
+func fraction(n, full int) float64 {
+	if n >= full {
+		return 1
+	}
+	return float64(n) / float64(full)
+}
+
+// profileCompleteness scores how complete a profile looks, from 0 for a ghost
+// profile to 100. Signals missing because the profile was never fetched
+// count as absent.
+func profileCompleteness(c Candidate, w completenessWeights) int {
+	total := w.Photo + w.Headline + w.Snippet + w.Skills + w.Education + w.Connections
+	if total == 0 {
+		return 0
+	}
+	// A result title "Name - Headline - Company" stands in for a missing headline.
+	headline := c.Headline
+	if headline == "" {
+		if segments := strings.Split(c.ResultTitle, " - "); len(segments) > 1 {
+			headline = strings.TrimSpace(segments[1])
+		}
+	}
+	points := 0.0
+	if !isGhostAvatar(c.PhotoURL) {
+		points += float64(w.Photo)
+	}
+	points += float64(w.Headline) * fraction(len([]rune(headline)), fullHeadlineLength)
+	points += float64(w.Snippet) * fraction(len(strings.Fields(c.Snippet)), fullSnippetWords)
+	points += float64(w.Skills) * fraction(c.SkillsCount, fullSkillsCount)
+	if c.HasEducation {
+		points += float64(w.Education)
+	}
+	points += float64(w.Connections) * fraction(max(c.Connections, parseConnections(c.Snippet)), fullConnections)
+	return int(100*points/float64(total) + 0.5)
+}
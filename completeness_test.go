@@ -0,0 +1,85 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIsGhostAvatar(t *testing.T) {
+	for photoURL, ghost := range map[string]bool{
+		"": true,
+		"https://static.licdn.com/aero-v1/sc/h/9c8pery4andzj6ohjkjp54ma2":                     true,
+		"https://static.licdn.com/sc/h/244xhbkr7g40x6bsu4gi6q4ry":                             true,
+		"https://media.licdn.com/dms/image/C4D03AQ/ghost-person-shrink_200":                   true,
+		"https://media.licdn.com/dms/image/C4D03AQHx/profile-displayphoto-shrink_800_800/0/1": false,
+	} {
+		if got := isGhostAvatar(photoURL); got != ghost {
+			t.Errorf("isGhostAvatar(%q) = %v, want %v", photoURL, got, ghost)
+		}
+	}
+}
+
+func TestParseConnections(t *testing.T) {
+	for text, want := range map[string]int{
+		"Pune · 500+ connections on LinkedIn": 500,
+		"1,234 followers":                     1234,
+		"Worked on 300 valves":                0,
+	} {
+		if got := parseConnections(text); got != want {
+			t.Errorf("parseConnections(%q) = %d, want %d", text, got, want)
+		}
+	}
+}
+
+func TestProfileCompletenessSparseToRich(t *testing.T) {
+	photo := "https://media.licdn.com/dms/image/C4D03AQHx/profile-displayphoto-shrink_800_800/0/1"
+	richSnippet := strings.Repeat("valve ", fullSnippetWords)
+	tests := []struct {
+		name string
+		c    Candidate
+		want int
+	}{
+		{"ghost", Candidate{PhotoURL: "https://static.licdn.com/sc/h/244xhbkr7g40x6bsu4gi6q4ry"}, 0},
+		{"photo only", Candidate{PhotoURL: photo}, 25},
+		// A headline of half the full length, from the result title.
+		{"title headline and short snippet", Candidate{ResultTitle: "Jane Doe - " + strings.Repeat("x", fullHeadlineLength/2) + " - Acme", Snippet: strings.Repeat("valve ", fullSnippetWords/3)}, 13},
+		{"partial", Candidate{PhotoURL: photo, Headline: strings.Repeat("x", fullHeadlineLength/2), SkillsCount: 2, HasEducation: true, Connections: 250}, 61},
+		{"rich", Candidate{PhotoURL: photo, Headline: strings.Repeat("x", fullHeadlineLength), Snippet: richSnippet, SkillsCount: 12, HasEducation: true, Connections: 500}, 100},
+		{"connections from the snippet", Candidate{Snippet: "500+ connections"}, 16},
+	}
+	for _, tt := range tests {
+		if got := profileCompleteness(tt.c, defaultCompletenessWeights); got != tt.want {
+			t.Errorf("%s: profileCompleteness = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+
+	photoOnly, err := parseCompletenessWeights("photo=1,headline=0,snippet=0,skills=0,education=0,connections=0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := profileCompleteness(Candidate{PhotoURL: photo}, photoOnly); got != 100 {
+		t.Errorf("photo-only weights gave a profile with a photo %d, want 100", got)
+	}
+	if got := profileCompleteness(Candidate{PhotoURL: photo}, completenessWeights{}); got != 0 {
+		t.Errorf("zero weights gave %d, want 0", got)
+	}
+	for _, spec := range []string{"photo", "photo=-1", "mood=5"} {
+		if _, err := parseCompletenessWeights(spec); err == nil {
+			t.Errorf("parseCompletenessWeights(%q) accepted", spec)
+		}
+	}
+}
+
+func TestExtractProfileSignals(t *testing.T) {
+	doc := parseHTML(t, `<head><meta property="og:image" content=" https://media.licdn.com/dms/image/photo "></head><body>
+		<h2 class="top-card-layout__headline"> Valve Engineer at Acme </h2>
+		<span>500+ connections</span>
+		<section class="skills"><ul><li>Valves</li><li>Piping</li></ul></section>
+		<section class="education"><ul><li>COEP</li></ul></section></body>`)
+	var c Candidate
+	extractProfileSignals(doc, &c)
+	if c.PhotoURL != "https://media.licdn.com/dms/image/photo" || c.Headline != "Valve Engineer at Acme" ||
+		c.SkillsCount != 2 || !c.HasEducation || c.Connections != 500 {
+		t.Errorf("signals %+v", c)
+	}
+}
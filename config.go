@@ -0,0 +1,191 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// defaultConfigFile is the -config file written by `profilesearch init`.
+const defaultConfigFile = "profilesearch.yaml"
+
+// configProfilesKey holds the named profiles of a -config file.
+const configProfilesKey = "profiles"
+
+// perProfileSettings are the settings that must not be shared between
+// profiles, as each profile's runs would mix into the others'. One a profile
+// inherits from the top level is made the profile's own: a directory gets a
+// subdirectory named after the profile, a file the name before its
+// extension, as seen.alice.json.
+var perProfileSettings = map[string]bool{ // Whether the setting is a directory.
+	"store":   false,
+	"run-dir": true,
+	"outbox":  false,
+}
+
+// loadConfigFile reads a -config file: a YAML map from flag names, without
+// the leading dash, to values. A map value, such as field-priority's, is
+// given as "key=value;key=value", with list values joined by commas.
+//
+// The file may also hold named profiles, such as
+//
+//	store: seen.json
+//	profiles:
+//	  alice:
+//	    keywords: golang
+//	    output: alice/candidates.csv
+//
+// With profile set, the top-level settings are defaults the profile's
+// override: maps are merged key by key, and anything else, lists included,
+// is replaced. A null in the profile unsets a top-level setting.
+func loadConfigFile(filename, profile string) (map[string]string, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	var raw map[string]interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", filename, err)
+	}
+	raw, err = selectConfigProfile(raw, profile)
+	if err != nil {
+		return nil, fmt.Errorf("config file %s: %w", filename, err)
+	}
+	values := make(map[string]string, len(raw))
+	for name, value := range raw {
+		if value == nil {
+			continue
+		}
+		values[name] = configValue(value)
+	}
+	return values, nil
+}
+
+// selectConfigProfile returns the settings of raw, a parsed -config file,
+// merged with those of profile; see loadConfigFile.
+func selectConfigProfile(raw map[string]interface{}, profile string) (map[string]interface{}, error) {
+	profiles, ok := raw[configProfilesKey].(map[string]interface{})
+	if raw[configProfilesKey] != nil && !ok {
+		return nil, fmt.Errorf("%s must map profile names to settings", configProfilesKey)
+	}
+	delete(raw, configProfilesKey)
+	if profile == "" {
+		return raw, nil
+	}
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if _, ok := profiles[profile]; !ok {
+		if len(names) == 0 {
+			return nil, fmt.Errorf("unknown profile %q: the file has no profiles", profile)
+		}
+		return nil, fmt.Errorf("unknown profile %q (available: %s)", profile, strings.Join(names, ", "))
+	}
+	overrides, ok := profiles[profile].(map[string]interface{})
+	if profiles[profile] != nil && !ok {
+		return nil, fmt.Errorf("profile %s must map settings to values", profile)
+	}
+	if _, ok := overrides[configProfilesKey]; ok {
+		return nil, fmt.Errorf("profile %s cannot hold profiles", profile)
+	}
+	for name, dir := range perProfileSettings {
+		if path, ok := raw[name].(string); ok && path != "" {
+			if _, own := overrides[name]; !own {
+				raw[name] = profilePath(path, profile, dir)
+			}
+		}
+	}
+	return mergeConfig(raw, overrides), nil
+}
+
+// mergeConfig returns base with the settings of over merged in: maps merged
+// key by key, other values replaced, and null values removed.
+func mergeConfig(base, over map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(base)+len(over))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range over {
+		baseMap, baseIsMap := merged[k].(map[string]interface{})
+		overMap, overIsMap := v.(map[string]interface{})
+		switch {
+		case v == nil:
+			delete(merged, k)
+		case baseIsMap && overIsMap:
+			merged[k] = mergeConfig(baseMap, overMap)
+		default:
+			merged[k] = v
+		}
+	}
+	return merged
+}
+
+// profilePath makes path, a directory when dir is set, the profile's own.
+func profilePath(path, profile string, dir bool) string {
+	if dir {
+		return filepath.Join(path, profile)
+	}
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "." + profile + ext
+}
+
+// configValue renders a -config value the way its flag takes it.
+func configValue(value interface{}) string {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		parts := make([]string, len(keys))
+		for i, k := range keys {
+			parts[i] = k + "=" + configValue(v[k])
+		}
+		return strings.Join(parts, ";")
+	case []interface{}:
+		parts := make([]string, len(v))
+		for i, e := range v {
+			parts[i] = configValue(e)
+		}
+		return strings.Join(parts, ",")
+	}
+	return fmt.Sprint(value)
+}
+
+// applyConfigFile sets the flags of fs named in a -config file, merged with
+// profile when it is set, except those already given on the command line,
+// which take precedence.
+func applyConfigFile(fs *flag.FlagSet, filename, profile string) error {
+	values, err := loadConfigFile(filename, profile)
+	if err != nil {
+		return err
+	}
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names) // Report the first bad key deterministically.
+	for _, name := range names {
+		if name == "config" || name == "profile" || fs.Lookup(name) == nil {
+			return fmt.Errorf("config file %s: unknown setting %q", filename, name)
+		}
+		if set[name] {
+			continue
+		}
+		if err := fs.Set(name, values[name]); err != nil {
+			return fmt.Errorf("config file %s: invalid %s: %w", filename, name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// errConsentRequired is returned when Google answers with its cookie consent
+// interstitial and -accept-consent is not set.
+var errConsentRequired = errors.New("consent page instead of results (set -accept-consent to accept it)")
+
+// consentHost serves Google's consent interstitial.
+const consentHost = "consent.google.com"
+
+// consentFormSelector matches the forms of the consent page; one rejects and
+// one accepts, told apart by their set_eom input.
+const consentFormSelector = `form[action*="consent.google.com/save"], form[action^="/save"]`
+
+// isConsentPage reports whether a response, which arrived at finalURL after
+// redirects, is the consent interstitial rather than the page requested.
+func isConsentPage(finalURL *url.URL, body []byte) bool {
+	if finalURL != nil && strings.EqualFold(finalURL.Host, consentHost) {
+		return true
+	}
+	return bytes.Contains(body, []byte("consent.google.com/save"))
+}
+
+// consentForm returns the target and fields of the consent page's "Accept
+// all" form. ok is false when the page has no recognizable form.
+func consentForm(pageURL *url.URL, body []byte) (action string, fields url.Values, ok bool) {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return "", nil, false
+	}
+	forms := doc.Find(consentFormSelector)
+	if forms.Length() == 0 {
+		return "", nil, false
+	}
+	// "Reject all" sets set_eom=true; "Accept all" sets it to false.
+	form := forms.FilterFunction(func(_ int, s *goquery.Selection) bool {
+		v, _ := s.Find(`input[name="set_eom"]`).Attr("value")
+		return v == "false"
+	}).First()
+	if form.Length() == 0 {
+		form = forms.Last()
+	}
+
+	href, _ := form.Attr("action")
+	target, err := url.Parse(href)
+	if err != nil {
+		return "", nil, false
+	}
+	if pageURL != nil {
+		target = pageURL.ResolveReference(target)
+	}
+	fields = url.Values{}
+	form.Find("input[name]").Each(func(_ int, s *goquery.Selection) {
+		name, _ := s.Attr("name")
+		value, _ := s.Attr("value")
+		fields.Add(name, value)
+	})
+	return target.String(), fields, true
+}
+
+// submitConsent accepts Google's cookie consent for the session of
+// requestURL's identity, by submitting the consent page's form or, when it
+// has none, by setting the consent cookie directly.
+func (f *httpFetcher) submitConsent(ctx context.Context, requestURL string, consentURL *url.URL, body []byte) error {
+	action, fields, ok := consentForm(consentURL, body)
+	if !ok {
+		u, err := url.Parse(requestURL)
+		if err != nil {
+			return fmt.Errorf("invalid URL: %w", err)
+		}
+		jar, _ := f.identityFor(u.Host).session()
+		jar.SetCookies(&url.URL{Scheme: "https", Host: u.Host, Path: "/"}, []*http.Cookie{
+			{Name: "CONSENT", Value: "YES+", Domain: "." + strings.TrimPrefix(u.Host, "www."), Path: "/"},
+		})
+		return nil
+	}
+	resp, err := f.do(ctx, "POST", action, fields)
+	if err != nil {
+		return fmt.Errorf("failed to submit consent: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to submit consent: status %d", resp.StatusCode)
+	}
+	return nil
+}
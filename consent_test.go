@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+const consentSearchURL = "https://www.google.com/search?q=site%3Alinkedin.com%2Fin+valve"
+
+func TestConsentAcceptedThenResults(t *testing.T) {
+	web, addr := startFakeWeb(t, "serp:\n  mode: consent\n"+fakeRoster(2))
+	f, err := newHTTPFetcher(fakeFetcherOptions(t, addr, "-accept-consent"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	body, err := f.Fetch(ctx, consentSearchURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(body), "/in/member-2") {
+		t.Errorf("body after consent is not the results page: %s", body)
+	}
+	if got, consent := web.requests("search"), web.requests("consent"); got != 2 || consent != 1 {
+		t.Errorf("%d search and %d consent requests, want 2 and 1", got, consent)
+	}
+
+	// The session keeps the consent cookie, so the next page is served at once.
+	if _, err := f.Fetch(ctx, consentSearchURL+"&start=10"); err != nil {
+		t.Fatal(err)
+	}
+	if got := web.requests(); got != 4 {
+		t.Errorf("%d requests made, want 4", got)
+	}
+}
+
+func TestConsentRequiresFlag(t *testing.T) {
+	_, addr := startFakeWeb(t, "serp:\n  mode: consent\n"+fakeRoster(1))
+	f, err := newHTTPFetcher(fakeFetcherOptions(t, addr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Fetch(context.Background(), consentSearchURL); !errors.Is(err, errConsentRequired) {
+		t.Errorf("Fetch = %v, want %v", err, errConsentRequired)
+	}
+}
+
+func TestConsentRequestsSpendBudget(t *testing.T) {
+	// The consent page and the form submission take the budget, leaving none
+	// for the results.
+	web, addr := startFakeWeb(t, "serp:\n  mode: consent\n"+fakeRoster(1))
+	opts := fakeFetcherOptions(t, addr, "-accept-consent")
+	opts.maxRequests = 2
+	f, err := newHTTPFetcher(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Fetch(context.Background(), consentSearchURL); !errors.Is(err, errBudgetExhausted) {
+		t.Errorf("Fetch = %v, want %v", err, errBudgetExhausted)
+	}
+	if got, consent := web.requests("search"), web.requests("consent"); got != 1 || consent != 1 {
+		t.Errorf("%d search and %d consent requests, want 1 and 1", got, consent)
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Fields of a -contacted export that can identify a person, and so what
+// MatchedContactedBy records.
+const (
+	contactedByEmail = "email"
+	contactedByURL   = "url"
+	contactedByPhone = "phone"
+)
+
+// defaultContactedMap maps fields to the usual CRM export headers. Columns
+// of the default mapping may be missing; mapped ones may not.
+const defaultContactedMap = "email=email,url=linkedin,phone=phone"
+
+// contactedSet holds the people an outreach CRM export lists as already
+// contacted, keyed by the same canonical forms the pipeline writes, so a
+// lookup is a map hit. A nil contactedSet matches no one.
+type contactedSet struct {
+	emails map[string]struct{}
+	slugs  map[string]struct{} // LinkedIn profile slugs.
+	phones map[string]struct{} // E.164 when parsable, else digits.
+	region string              // Region assumed for phones without a country code.
+}
+
+// parseContactedMap parses a -contacted-map spec such as
+// "email=Email Address,url=LinkedIn" into field to header.
+func parseContactedMap(spec string) (map[string]string, error) {
+	mapping := make(map[string]string)
+	for _, pair := range strings.Split(spec, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		field, header, ok := strings.Cut(pair, "=")
+		field = strings.ToLower(strings.TrimSpace(field))
+		header = strings.TrimSpace(header)
+		if !ok || header == "" {
+			return nil, fmt.Errorf("invalid mapping %q: want field=column", pair)
+		}
+		switch field {
+		case contactedByEmail, contactedByURL, contactedByPhone:
+			mapping[field] = header
+		default:
+			return nil, fmt.Errorf("unknown field %q: want %s, %s, or %s", field, contactedByEmail, contactedByURL, contactedByPhone)
+		}
+	}
+	if len(mapping) == 0 {
+		return nil, errors.New("no fields mapped")
+	}
+	return mapping, nil
+}
+
+// contactedHeader normalizes a CSV header for matching: without a byte
+// order mark or surrounding space, and lower-cased.
+func contactedHeader(h string) string {
+	return strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))
+}
+
+// canonicalEmail is the form emails are compared in.
+func canonicalEmail(email string) string {
+	return strings.TrimPrefix(strings.ToLower(strings.TrimSpace(email)), "mailto:")
+}
+
+// canonicalPhone is the form phones are compared in: E.164 when the number
+// parses in region, else its digits.
+func canonicalPhone(phone, region string) string {
+	if n, ok := parsePhone(phone, region); ok {
+		return n.E164()
+	}
+	return phoneMatch{text: phone, start: 0, end: len(phone)}.digits()
+}
+
+// loadContacted reads a CRM export. mapping names the column of each field;
+// when strict, every mapped column must exist, otherwise at least one.
+func loadContacted(filename string, mapping map[string]string, strict bool, region string) (*contactedSet, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open contacted export: %w", err)
+	}
+	defer file.Close()
+
+	r := csv.NewReader(file)
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	r.ReuseRecord = true
+	header, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read contacted export header: %w", err)
+	}
+	columns := make(map[string]int) // Field to column index.
+	for field, name := range mapping {
+		for i, h := range header {
+			if contactedHeader(h) == contactedHeader(name) {
+				columns[field] = i
+				break
+			}
+		}
+		if _, ok := columns[field]; !ok && strict {
+			return nil, fmt.Errorf("contacted export %s has no %q column for %s", filename, name, field)
+		}
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("contacted export %s has none of the mapped columns; set -contacted-map", filename)
+	}
+
+	s := &contactedSet{
+		emails: make(map[string]struct{}),
+		slugs:  make(map[string]struct{}),
+		phones: make(map[string]struct{}),
+		region: region,
+	}
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read contacted export: %w", err)
+		}
+		for field, i := range columns {
+			if i >= len(record) || strings.TrimSpace(record[i]) == "" {
+				continue
+			}
+			switch value := record[i]; field {
+			case contactedByEmail:
+				s.emails[canonicalEmail(value)] = struct{}{}
+			case contactedByURL:
+				if slug := profileSlug(value); slug != "" {
+					s.slugs[slug] = struct{}{}
+				}
+			case contactedByPhone:
+				if p := canonicalPhone(value, region); len(p) >= 7 {
+					s.phones[p] = struct{}{}
+				}
+			}
+		}
+	}
+	return s, nil
+}
+
+// size returns how many identifiers the set holds.
+func (s *contactedSet) size() int {
+	if s == nil {
+		return 0
+	}
+	return len(s.emails) + len(s.slugs) + len(s.phones)
+}
+
+// match returns the field by which the export lists c, or "" when it does
+// not.
+func (s *contactedSet) match(c Candidate) string {
+	if s == nil {
+		return ""
+	}
+	if c.Email != "" {
+		if _, ok := s.emails[canonicalEmail(c.Email)]; ok {
+			return contactedByEmail
+		}
+	}
+	if slug := profileSlug(c.ProfileURL); slug != "" {
+		if _, ok := s.slugs[slug]; ok {
+			return contactedByURL
+		}
+	}
+	if c.Phone != "" {
+		if _, ok := s.phones[canonicalPhone(c.Phone, s.region)]; ok {
+			return contactedByPhone
+		}
+	}
+	return ""
+}
+
+// contactedFilter drops candidates the export lists, for -drop-contacted.
+func contactedFilter(c Candidate) filterDecision {
+	return filterDecision{Filter: "contacted", Passed: c.MatchedContactedBy == "", Expected: "not in the contacted export", Actual: c.MatchedContactedBy}
+}
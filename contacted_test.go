@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// messyExport is a CRM export as spreadsheets write them: a byte order
+// mark, headers in another case with stray space, and values in whatever
+// form people typed them.
+const messyExport = "\ufeffFull Name, Email Address ,LINKEDIN,Mobile\r\n" +
+	"Jane Doe,MAILTO:Jane.Doe@Emerson.com,,\r\n" +
+	"John Roe,,https://in.linkedin.com/in/John-Roe/?trk=crm,\r\n" +
+	"Asha Rao,,,098450 12345\r\n" +
+	"Short,,,12345\r\n"
+
+func writeContactedExport(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "contacted.csv")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestParseContactedMap(t *testing.T) {
+	got, err := parseContactedMap(" Email = Email Address ,url=LinkedIn,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"email": "Email Address", "url": "LinkedIn"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("mapping %v, want %v", got, want)
+	}
+	for _, bad := range []string{"", "email", "email=", "name=Full Name"} {
+		if _, err := parseContactedMap(bad); err == nil {
+			t.Errorf("mapping %q accepted", bad)
+		}
+	}
+}
+
+func TestLoadContactedMessyExport(t *testing.T) {
+	mapping, _ := parseContactedMap("email=email address,url=linkedin,phone=mobile")
+	set, err := loadContacted(writeContactedExport(t, messyExport), mapping, true, "IN")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if set.size() != 3 {
+		t.Errorf("%d identifiers loaded, want 3: the too-short phone is skipped", set.size())
+	}
+	tests := []struct {
+		c    Candidate
+		want string
+	}{
+		{Candidate{Email: "jane.doe@emerson.com"}, contactedByEmail},
+		{Candidate{ProfileURL: "https://www.linkedin.com/in/john-roe"}, contactedByURL},
+		{Candidate{Phone: "+91 98450 12345"}, contactedByPhone},
+		{Candidate{Email: "someone@emerson.com", ProfileURL: "https://www.linkedin.com/in/someone", Phone: "+91 98450 99999"}, ""},
+	}
+	for _, tt := range tests {
+		if got := set.match(tt.c); got != tt.want {
+			t.Errorf("match(%+v) = %q, want %q", tt.c, got, tt.want)
+		}
+	}
+
+	// A mapped column missing from the export is an error when the mapping
+	// was given, and skipped for the default one.
+	bad, _ := parseContactedMap("email=E-mail")
+	if _, err := loadContacted(writeContactedExport(t, messyExport), bad, true, "IN"); err == nil {
+		t.Error("a missing mapped column accepted")
+	}
+	def, _ := parseContactedMap(defaultContactedMap)
+	if set, err := loadContacted(writeContactedExport(t, "Email,Name\r\njane@emerson.com,Jane\r\n"), def, false, "IN"); err != nil || set.size() != 1 {
+		t.Errorf("default mapping over an email-only export: %v, %d identifiers", err, set.size())
+	}
+}
+
+func TestLoadContactedLargeExport(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("email,linkedin\n")
+	const rows = 100000
+	for i := 0; i < rows; i++ {
+		fmt.Fprintf(&b, "person%d@example.com,https://www.linkedin.com/in/person-%d\n", i, i)
+	}
+	mapping, _ := parseContactedMap("email=email,url=linkedin")
+	set, err := loadContacted(writeContactedExport(t, b.String()), mapping, true, "IN")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if set.size() != 2*rows {
+		t.Errorf("%d identifiers loaded, want %d", set.size(), 2*rows)
+	}
+	if set.match(Candidate{ProfileURL: "https://www.linkedin.com/in/person-99999"}) != contactedByURL {
+		t.Error("the last row not matched")
+	}
+}
+
+func TestContactedTagOrDrop(t *testing.T) {
+	export := writeContactedExport(t, "Email,LinkedIn\njane@emerson.com,\n,https://www.linkedin.com/in/john-roe\n")
+	scenario := `profiles:
+  - slug: jane-doe
+    name: Jane Doe
+    email: jane@emerson.com
+  - slug: john-roe
+    name: John Roe
+  - slug: asha-rao
+    name: Asha Rao
+`
+	for _, tc := range []struct {
+		args []string
+		want []string
+	}{
+		{nil, []string{"Jane Doe", "John Roe", "Asha Rao"}},
+		{[]string{"-drop-contacted"}, []string{"Asha Rao"}},
+	} {
+		_, addr := startFakeWeb(t, scenario)
+		output, err := runFakeSearch(t, addr, append([]string{"-max-pages", "1", "-contacted", export, "-format", "json"}, tc.args...)...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, c := range readJSONCandidates(t, output) {
+			names = append(names, c.Name)
+			want := map[string]string{"Jane Doe": contactedByEmail, "John Roe": contactedByURL}[c.Name]
+			if c.MatchedContactedBy != want {
+				t.Errorf("%v: %s matched by %q, want %q", tc.args, c.Name, c.MatchedContactedBy, want)
+			}
+		}
+		if !slices.Equal(names, tc.want) {
+			t.Errorf("%v: wrote %q, want %q", tc.args, names, tc.want)
+		}
+	}
+}
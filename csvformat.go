@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// csvFormat is how CSV output is written. The zero value is plain UTF-8,
+// comma-separated, quoted only where needed.
+type csvFormat struct {
+	encoding string // encodingUTF8, encodingUTF8BOM, or encodingUTF16.
+	comma    rune   // Field delimiter; 0 is the encoding's default.
+	quoteAll bool   // Quote every field, not only those that need it.
+}
+
+// Encodings of CSV output, chosen with -csv-bom and -csv-encoding.
+const (
+	encodingUTF8    = "utf-8"
+	encodingUTF8BOM = "utf-8-bom" // UTF-8 after a byte order mark, which Excel needs to detect it.
+	encodingUTF16   = "utf-16"    // Tab-separated UTF-16LE after a byte order mark, as older Excel reads Unicode text.
+)
+
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+)
+
+// encodeCSV writes the byte order mark of the format's encoding to w and
+// returns a writer that encodes what is written to it, in UTF-8, onto w.
+func encodeCSV(w io.Writer, format csvFormat) (io.Writer, error) {
+	switch format.encoding {
+	case encodingUTF8BOM:
+		_, err := w.Write(bomUTF8)
+		return w, err
+	case encodingUTF16:
+		_, err := w.Write(bomUTF16LE)
+		return &utf16Writer{w: w}, err
+	}
+	return w, nil
+}
+
+// delimiter returns the field delimiter of the format.
+func (f csvFormat) delimiter() rune {
+	switch {
+	case f.comma != 0:
+		return f.comma
+	case f.encoding == encodingUTF16:
+		return '\t'
+	}
+	return ','
+}
+
+// parseCSVDelimiter parses a -csv-delimiter: a single character, or tab
+// spelled out.
+func parseCSVDelimiter(s string) (rune, error) {
+	switch strings.ToLower(s) {
+	case "tab", `\t`:
+		return '\t', nil
+	}
+	r, size := utf8.DecodeRuneInString(s)
+	switch {
+	case size == 0 || size != len(s) || r == utf8.RuneError:
+		return 0, fmt.Errorf("want a single character, not %q", s)
+	case r == '"' || r == '\r' || r == '\n' || r == '#':
+		return 0, fmt.Errorf("%q cannot separate fields", r)
+	}
+	return r, nil
+}
+
+// csvRowWriter writes CSV records; csv.Writer is one.
+type csvRowWriter interface {
+	Write(record []string) error
+	Flush()
+	Error() error
+}
+
+// newCSVWriter returns a writer of records in the format onto w.
+func newCSVWriter(w io.Writer, format csvFormat) csvRowWriter {
+	if format.quoteAll {
+		return &quoteAllWriter{w: bufio.NewWriter(w), comma: format.delimiter()}
+	}
+	writer := csv.NewWriter(w)
+	writer.Comma = format.delimiter()
+	return writer
+}
+
+// quoteAllWriter writes records with every field quoted, which csv.Writer
+// does only for fields that need it.
+type quoteAllWriter struct {
+	w     *bufio.Writer
+	comma rune
+	err   error
+}
+
+func (q *quoteAllWriter) Write(record []string) error {
+	if q.err != nil {
+		return q.err
+	}
+	for i, field := range record {
+		if i > 0 {
+			q.w.WriteRune(q.comma)
+		}
+		q.w.WriteByte('"')
+		q.w.WriteString(strings.ReplaceAll(field, `"`, `""`))
+		q.w.WriteByte('"')
+	}
+	// bufio.Writer keeps the first error, so the last write reports it.
+	_, q.err = q.w.WriteString("\n")
+	return q.err
+}
+
+func (q *quoteAllWriter) Flush() {
+	if q.err == nil {
+		q.err = q.w.Flush()
+	}
+}
+
+func (q *quoteAllWriter) Error() error { return q.err }
+
+// utf16Writer re-encodes UTF-8 as UTF-16LE. A character split across writes
+// is held back until the rest of it arrives.
+type utf16Writer struct {
+	w       io.Writer
+	partial []byte
+}
+
+func (u *utf16Writer) Write(p []byte) (int, error) {
+	data := append(u.partial, p...)
+	var units []uint16
+	for len(data) > 0 && utf8.FullRune(data) {
+		r, size := utf8.DecodeRune(data)
+		data = data[size:]
+		units = utf16.AppendRune(units, r)
+	}
+	u.partial = append([]byte(nil), data...)
+	out := make([]byte, 2*len(units))
+	for i, unit := range units {
+		binary.LittleEndian.PutUint16(out[2*i:], unit)
+	}
+	if _, err := u.w.Write(out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// decodeCSV returns data, which may start with a byte order mark, as UTF-8.
+func decodeCSV(data []byte) ([]byte, error) {
+	switch {
+	case bytes.HasPrefix(data, bomUTF8):
+		return data[len(bomUTF8):], nil
+	case bytes.HasPrefix(data, bomUTF16LE):
+		data = data[len(bomUTF16LE):]
+		if len(data)%2 != 0 {
+			return nil, errors.New("UTF-16 file has an odd number of bytes")
+		}
+		units := make([]uint16, len(data)/2)
+		for i := range units {
+			units[i] = binary.LittleEndian.Uint16(data[2*i:])
+		}
+		return []byte(string(utf16.Decode(units))), nil
+	}
+	return data, nil
+}
+
+// sniffDelimiter guesses the field delimiter of CSV data from its header,
+// the first line that is not a # comment: the most frequent of the usual
+// delimiters, or a comma.
+func sniffDelimiter(data []byte) rune {
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		best, bestCount := ',', 0
+		for _, r := range []rune{',', ';', '\t', '|'} {
+			if n := strings.Count(line, string(r)); n > bestCount {
+				best, bestCount = r, n
+			}
+		}
+		return best
+	}
+	return ','
+}
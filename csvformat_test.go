@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+// accentedRoster is a scenario whose names Excel garbles without a BOM.
+const accentedRoster = `profiles:
+  - slug: jose-muller
+    name: José Müller
+  - slug: zoe-lemaitre
+    name: Zoë Lemaître
+`
+
+func TestCSVBOM(t *testing.T) {
+	_, addr := startFakeWeb(t, accentedRoster)
+	output, err := runFakeSearch(t, addr, "-max-pages", "1", "-csv-bom")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}) {
+		t.Fatalf("CSV starts with % x, want the UTF-8 byte order mark", data[:min(len(data), 3)])
+	}
+	if bytes.Count(data, bomUTF8) != 1 {
+		t.Error("more than one byte order mark written")
+	}
+	if got := readFakeSearch(t, output); len(got) != 2 || got[0].Name != "José Müller" {
+		t.Errorf("read back %+v, want the accented names", got)
+	}
+
+	// Off by default.
+	output, err = runFakeSearch(t, addr, "-max-pages", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err = os.ReadFile(output); err != nil {
+		t.Fatal(err)
+	}
+	if bytes.HasPrefix(data, bomUTF8) {
+		t.Error("a byte order mark written without -csv-bom")
+	}
+}
+
+func TestCSVEncodingUTF16(t *testing.T) {
+	_, addr := startFakeWeb(t, accentedRoster)
+	output, err := runFakeSearch(t, addr, "-max-pages", "1", "-csv-encoding", "utf-16", "-columns", "name,profile_url")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(data, []byte{0xFF, 0xFE}) {
+		t.Fatalf("CSV starts with % x, want the UTF-16LE byte order mark", data[:min(len(data), 2)])
+	}
+	text, err := decodeCSV(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(text, []byte("José Müller\thttps://www.linkedin.com/in/jose-muller")) {
+		t.Errorf("decoded CSV %q, want tab-separated rows", text)
+	}
+	if got := readFakeSearch(t, output); len(got) != 2 || got[1].Name != "Zoë Lemaître" {
+		t.Errorf("read back %+v, want the accented names", got)
+	}
+}
+
+func TestUTF16WriterSplitRune(t *testing.T) {
+	var buf bytes.Buffer
+	w := &utf16Writer{w: &buf}
+	// "é" is two bytes in UTF-8 and "𝄞" four; each arrives in pieces.
+	text := []byte("é𝄞a")
+	for i := range text {
+		if _, err := w.Write(text[i : i+1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []byte{0xE9, 0x00, 0x34, 0xD8, 0x1E, 0xDD, 'a', 0x00}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("wrote % x, want % x", buf.Bytes(), want)
+	}
+}
+
+func TestCSVEncodingFlags(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{nil, encodingUTF8},
+		{[]string{"-csv-bom"}, encodingUTF8BOM},
+		{[]string{"-csv-encoding", "utf-16"}, encodingUTF16},
+		{[]string{"-csv-encoding", "utf-16", "-csv-bom"}, encodingUTF16},
+	}
+	for _, tt := range tests {
+		cfg, err := parseFlags(tt.args)
+		if err != nil {
+			t.Fatalf("%q: %v", tt.args, err)
+		}
+		if cfg.csv.encoding != tt.want {
+			t.Errorf("%q: encoding %s, want %s", tt.args, cfg.csv.encoding, tt.want)
+		}
+	}
+	for _, args := range [][]string{
+		{"-csv-encoding", "latin-1"},
+		{"-csv-bom", "-format", "parquet"},
+	} {
+		if _, err := parseFlags(args); err == nil {
+			t.Errorf("%q accepted", args)
+		}
+	}
+}
+
+func TestCSVSemicolonDelimiter(t *testing.T) {
+	_, addr := startFakeWeb(t, accentedRoster)
+	output, err := runFakeSearch(t, addr, "-max-pages", "1", "-csv-delimiter", ";", "-columns", "name,profile_url")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(data, []byte("Name;Profile URL\n")) || !bytes.Contains(data, []byte("José Müller;https://www.linkedin.com/in/jose-muller\n")) {
+		t.Errorf("CSV %q, want semicolon-separated rows", data)
+	}
+	// Reading it back finds the delimiter itself.
+	if got := readFakeSearch(t, output); len(got) != 2 || got[0].ProfileURL != "https://www.linkedin.com/in/jose-muller" {
+		t.Errorf("read back %+v", got)
+	}
+}
+
+func TestCSVQuoteAll(t *testing.T) {
+	record := []string{"Jane \"JD\" Doe", "Valve; actuator", "", "https://www.linkedin.com/in/jane-doe"}
+	tests := []struct {
+		format csvFormat
+		want   string
+	}{
+		{csvFormat{}, "\"Jane \"\"JD\"\" Doe\",Valve; actuator,,https://www.linkedin.com/in/jane-doe\n"},
+		{csvFormat{comma: ';'}, "\"Jane \"\"JD\"\" Doe\";\"Valve; actuator\";;https://www.linkedin.com/in/jane-doe\n"},
+		{csvFormat{quoteAll: true}, "\"Jane \"\"JD\"\" Doe\",\"Valve; actuator\",\"\",\"https://www.linkedin.com/in/jane-doe\"\n"},
+		{csvFormat{comma: '\t', quoteAll: true}, "\"Jane \"\"JD\"\" Doe\"\t\"Valve; actuator\"\t\"\"\t\"https://www.linkedin.com/in/jane-doe\"\n"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		w := newCSVWriter(&buf, tt.format)
+		if err := w.Write(record); err != nil {
+			t.Fatal(err)
+		}
+		w.Flush()
+		if err := w.Error(); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != tt.want {
+			t.Errorf("%+v wrote %q, want %q", tt.format, buf.String(), tt.want)
+		}
+	}
+
+	_, addr := startFakeWeb(t, accentedRoster)
+	output, err := runFakeSearch(t, addr, "-max-pages", "1", "-csv-quote-all", "-columns", "name,profile_url")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(data, []byte("\"Name\",\"Profile URL\"\n\"José Müller\",\"https://www.linkedin.com/in/jose-muller\"\n")) {
+		t.Errorf("CSV %q, want every field quoted", data)
+	}
+	if got := readFakeSearch(t, output); len(got) != 2 {
+		t.Errorf("read back %d of 2 quoted rows", len(got))
+	}
+}
+
+func TestParseCSVDelimiter(t *testing.T) {
+	for s, want := range map[string]rune{";": ';', "|": '|', "tab": '\t', `\t`: '\t', "TAB": '\t'} {
+		if got, err := parseCSVDelimiter(s); err != nil || got != want {
+			t.Errorf("parseCSVDelimiter(%q) = %q, %v; want %q", s, got, err, want)
+		}
+	}
+	for _, s := range []string{"", ";;", `"`, "#", "\n"} {
+		if _, err := parseCSVDelimiter(s); err == nil {
+			t.Errorf("parseCSVDelimiter(%q) accepted", s)
+		}
+	}
+	if _, err := parseFlags([]string{"-csv-quote-all", "-format", "json"}); err == nil {
+		t.Error("-csv-quote-all accepted with -format json")
+	}
+}
+
+func TestSniffDelimiter(t *testing.T) {
+	for data, want := range map[string]rune{
+		"name,profile_url\n": ',',
+		"# run info; with; semicolons\nname\tprofile_url\n": '\t',
+		"name;title;profile_url\nJane, PhD;x;y\n":           ';',
+		"": ',',
+	} {
+		if got := sniffDelimiter([]byte(data)); got != want {
+			t.Errorf("sniffDelimiter(%q) = %q, want %q", data, got, want)
+		}
+	}
+}
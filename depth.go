@@ -0,0 +1,77 @@
+package main
+
+import (
+	"log"
+	"strings"
+)
+
+// googleMaxPages is the deepest Google goes for one query: past roughly
+// result 300 it serves repeats or empty pages, so further pages only cost
+// requests.
+const googleMaxPages = 30
+
+// defaultChunkTerms split a query by the first letter of the profile slug,
+// which is usually the first letter of the name.
+var defaultChunkTerms = strings.Split("abcdefghijklmnopqrstuvwxyz", "")
+
+// queryChunk is one query of a search and the pages to scrape for it.
+type queryChunk struct {
+	Discriminator string // Appended to the query; "" for the query as given.
+	Pages         int
+}
+
+// chunkDiscriminator turns a -chunk-terms entry into query syntax. A single
+// letter selects profile slugs starting with it; anything else is searched
+// as a term, quoted when it has several words.
+func chunkDiscriminator(term string) string {
+	term = strings.TrimSpace(term)
+	switch {
+	case len(term) == 1:
+		return "inurl:in/" + strings.ToLower(term)
+	case strings.Contains(term, " "):
+		return quoteTerm(term)
+	}
+	return term
+}
+
+// planChunks divides maxPages of a search into queries Google will serve.
+// Within googleMaxPages it is the query as given. Beyond it, the query as
+// given is scraped to the ceiling and, with deep set, the rest is spread over
+// the query narrowed by each term in turn, at most googleMaxPages each.
+func planChunks(maxPages int, deep bool, terms []string) []queryChunk {
+	if maxPages <= googleMaxPages || !deep {
+		return []queryChunk{{Pages: min(maxPages, googleMaxPages)}}
+	}
+	chunks := []queryChunk{{Pages: googleMaxPages}}
+	remaining := maxPages - googleMaxPages
+	for _, term := range terms {
+		if remaining <= 0 {
+			break
+		}
+		d := chunkDiscriminator(term)
+		if d == "" {
+			continue
+		}
+		pages := min(remaining, googleMaxPages)
+		chunks = append(chunks, queryChunk{Discriminator: d, Pages: pages})
+		remaining -= pages
+	}
+	if remaining > 0 {
+		log.Printf("-chunk-terms cover only %d of %d pages.", maxPages-remaining, maxPages)
+	}
+	return chunks
+}
+
+// parseChunkTerms splits a -chunk-terms list, defaulting to the alphabet.
+func parseChunkTerms(spec string) []string {
+	var terms []string
+	for _, t := range strings.Split(spec, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			terms = append(terms, t)
+		}
+	}
+	if len(terms) == 0 {
+		return defaultChunkTerms
+	}
+	return terms
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestChunkDiscriminator(t *testing.T) {
+	for term, want := range map[string]string{
+		"a":               "inurl:in/a",
+		" K ":             "inurl:in/k",
+		"valves":          "valves",
+		"process control": `"process control"`,
+	} {
+		if got := chunkDiscriminator(term); got != want {
+			t.Errorf("chunkDiscriminator(%q) = %s, want %s", term, got, want)
+		}
+	}
+}
+
+func TestPlanChunks(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxPages int
+		deep     bool
+		terms    []string
+		want     []queryChunk
+	}{
+		{"within the ceiling", 5, true, defaultChunkTerms, []queryChunk{{Pages: 5}}},
+		{"capped without -deep-coverage", 100, false, defaultChunkTerms, []queryChunk{{Pages: 30}}},
+		{"alphabet slices", 75, true, defaultChunkTerms, []queryChunk{
+			{Pages: 30},
+			{Discriminator: "inurl:in/a", Pages: 30},
+			{Discriminator: "inurl:in/b", Pages: 15},
+		}},
+		{"sub-terms", 70, true, []string{"valves", "process control", ""}, []queryChunk{
+			{Pages: 30},
+			{Discriminator: "valves", Pages: 30},
+			{Discriminator: `"process control"`, Pages: 10},
+		}},
+		{"too few terms", 100, true, []string{"valves"}, []queryChunk{
+			{Pages: 30},
+			{Discriminator: "valves", Pages: 30},
+		}},
+	}
+	captureLog(t)
+	for _, tt := range tests {
+		if got := planChunks(tt.maxPages, tt.deep, tt.terms); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: chunks %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseChunkTerms(t *testing.T) {
+	if got := parseChunkTerms(""); !reflect.DeepEqual(got, defaultChunkTerms) {
+		t.Errorf("no -chunk-terms gave %q, want the alphabet", got)
+	}
+	if got, want := parseChunkTerms(" valves, ,process control "), []string{"valves", "process control"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("chunk terms %q, want %q", got, want)
+	}
+}
+
+func TestChunkQueries(t *testing.T) {
+	// Each chunk of a deep search is the base query narrowed by its term.
+	base := SearchCriteria{Keywords: "control valve"}
+	var got []string
+	for _, chunk := range planChunks(90, true, []string{"a", "process control"}) {
+		c := base
+		c.Discriminator = chunk.Discriminator
+		u, err := url.Parse(buildGoogleSearchURL(c))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, u.Query().Get("q"))
+	}
+	want := []string{
+		`site:linkedin.com/in "control valve"`,
+		`site:linkedin.com/in "control valve" inurl:in/a`,
+		`site:linkedin.com/in "control valve" "process control"`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("chunk queries\n%q\nwant\n%q", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestMaxPagesCeilingWarning(t *testing.T) {
+	logs := captureLog(t)
+	cfg, err := parseFlags([]string{"-max-pages", "100"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.chunks) != 1 || cfg.chunks[0].Pages != googleMaxPages {
+		t.Errorf("chunks %+v, want one of %d pages", cfg.chunks, googleMaxPages)
+	}
+	if !strings.Contains(logs.String(), "-deep-coverage") {
+		t.Errorf("no warning of the ceiling in %q", logs.String())
+	}
+
+	cfg, err = parseFlags([]string{"-max-pages", "100", "-deep-coverage", "-chunk-terms", "a,b,c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pages := 0
+	for _, chunk := range cfg.chunks {
+		pages += chunk.Pages
+	}
+	if len(cfg.chunks) != 4 || pages != 100 {
+		t.Errorf("chunks %+v, want 100 pages over 4 queries", cfg.chunks)
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// companyMatchThreshold is the minimum token-set similarity for a fuzzy
+// company name match against the -company-domains map.
+const companyMatchThreshold = 0.8
+
+// companySuffixes are legal-form words dropped before company names are
+// compared, so "Emerson Electric Co." matches "Emerson Electric".
+var companySuffixes = map[string]bool{
+	"inc": true, "incorporated": true, "llc": true, "llp": true, "ltd": true, "limited": true,
+	"pvt": true, "private": true, "plc": true, "corp": true, "corporation": true, "co": true,
+	"company": true, "gmbh": true, "ag": true, "sa": true, "bv": true, "group": true,
+}
+
+// companyDomains maps normalized company names to email domains.
+type companyDomains map[string]string
+
+// normalizeCompany lower-cases a company name and drops punctuation and
+// legal-form suffixes.
+func normalizeCompany(name string) string {
+	var kept []string
+	for _, t := range nameTokens(name) {
+		if !companySuffixes[t] {
+			kept = append(kept, t)
+		}
+	}
+	return strings.Join(kept, " ")
+}
+
+// loadCompanyDomains reads a company-to-domain map from a JSON object or a
+// two-column CSV file, chosen by extension. A CSV header row is optional.
+func loadCompanyDomains(filename string) (companyDomains, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open company domains: %w", err)
+	}
+	defer file.Close()
+
+	raw := make(map[string]string)
+	if strings.EqualFold(filepath.Ext(filename), ".json") {
+		if err := json.NewDecoder(file).Decode(&raw); err != nil {
+			return nil, fmt.Errorf("failed to parse company domains: %w", err)
+		}
+	} else {
+		reader := csv.NewReader(file)
+		reader.FieldsPerRecord = 2
+		for {
+			row, err := reader.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to read company domains: %w", err)
+			}
+			if strings.EqualFold(strings.TrimSpace(row[0]), "company") {
+				continue // Header row.
+			}
+			raw[row[0]] = row[1]
+		}
+	}
+
+	// Names that normalize alike keep the alphabetically last one's domain,
+	// so the result does not depend on map order.
+	companies := make([]string, 0, len(raw))
+	for company := range raw {
+		companies = append(companies, company)
+	}
+	sort.Strings(companies)
+	domains := make(companyDomains, len(raw))
+	for _, company := range companies {
+		domain := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(raw[company]), "@"))
+		if key := normalizeCompany(company); key != "" && domain != "" {
+			domains[key] = domain
+		}
+	}
+	return domains, nil
+}
+
+// lookup finds the domain of a company, by exact normalized name or else by
+// the most similar name above companyMatchThreshold.
+func (d companyDomains) lookup(company string) (string, bool) {
+	key := normalizeCompany(company)
+	if key == "" {
+		return "", false
+	}
+	if domain, ok := d[key]; ok {
+		return domain, true
+	}
+	// Ties go to the alphabetically first name, so results do not depend on
+	// map order.
+	bestName, bestScore := "", 0.0
+	for name := range d {
+		if score := tokenSetSimilarity(key, name); score > bestScore || (score == bestScore && name < bestName) {
+			bestName, bestScore = name, score
+		}
+	}
+	if bestScore < companyMatchThreshold {
+		return "", false
+	}
+	return d[bestName], true
+}
+
+// companyDomain returns the email domain of a company: the mapped domain when
+// known, or else a guess from its name, such as "emersonelectric.com".
+func (d companyDomains) companyDomain(company string) string {
+	if domain, ok := d.lookup(company); ok {
+		return domain
+	}
+	key := strings.ReplaceAll(normalizeCompany(company), " ", "")
+	if key == "" {
+		return ""
+	}
+	return key + ".com"
+}
+
+// guessEmail guesses a first.last address at the candidate's company. It
+// returns "" when the name or company is missing.
+func guessEmail(c Candidate, domains companyDomains) string {
+	tokens := nameTokens(resultName(c))
+	if len(tokens) < 2 {
+		return ""
+	}
+	domain := domains.companyDomain(c.Company)
+	if domain == "" {
+		return ""
+	}
+	return tokens[0] + "." + tokens[len(tokens)-1] + "@" + domain
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writeDomainsFile writes a company domains file of content and returns its
+// path.
+func writeDomainsFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadCompanyDomains(t *testing.T) {
+	want := companyDomains{"emerson electric": "emerson.com", "acme valves": "acmevalves.in"}
+	for name, content := range map[string]string{
+		"map.csv":  "company,domain\nEmerson Electric Co.,emerson.com\nAcme Valves Pvt Ltd,@AcmeValves.in\n",
+		"map.json": `{"Emerson Electric Co.": "emerson.com", "Acme Valves Pvt Ltd": "@AcmeValves.in"}`,
+	} {
+		domains, err := loadCompanyDomains(writeDomainsFile(t, name, content))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !reflect.DeepEqual(domains, want) {
+			t.Errorf("%s: domains %v, want %v", name, domains, want)
+		}
+	}
+	if _, err := loadCompanyDomains(writeDomainsFile(t, "map.csv", "Emerson,emerson.com,extra\n")); err == nil {
+		t.Error("a CSV row of three columns was accepted")
+	}
+}
+
+func TestCompanyDomainPrefersMap(t *testing.T) {
+	domains := companyDomains{"emerson electric": "emerson.com"}
+	tests := []struct {
+		company, want string
+	}{
+		{"Emerson Electric", "emerson.com"},
+		{"EMERSON ELECTRIC CORPORATION", "emerson.com"},
+		{"Emerson Electric Automation", "emerson.com"}, // Fuzzy match.
+		{"Emerson", "emerson.com"},
+		{"Acme Valves Pvt Ltd", "acmevalves.com"}, // Not mapped: guessed.
+		{"Ltd.", ""},
+	}
+	for _, tt := range tests {
+		if got := domains.companyDomain(tt.company); got != tt.want {
+			t.Errorf("companyDomain(%q) = %q, want %q", tt.company, got, tt.want)
+		}
+	}
+	if _, ok := domains.lookup("Siemens Energy"); ok {
+		t.Error("an unrelated company matched the map")
+	}
+}
+
+func TestGuessEmail(t *testing.T) {
+	domains := companyDomains{"emerson electric": "emerson.com"}
+	tests := []struct {
+		c    Candidate
+		want string
+	}{
+		{Candidate{Name: "Jane A. Doe", Company: "Emerson Electric Co."}, "jane.doe@emerson.com"},
+		{Candidate{Name: "Jane Doe", Company: "Acme"}, "jane.doe@acme.com"},
+		{Candidate{Name: "Jane", Company: "Emerson Electric"}, ""},
+		{Candidate{Name: "Jane Doe"}, ""},
+	}
+	for _, tt := range tests {
+		if got := guessEmail(tt.c, domains); got != tt.want {
+			t.Errorf("guessEmail(%s at %s) = %q, want %q", tt.c.Name, tt.c.Company, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// defaultFreemailDomains are the free-mail providers -exclude-freemail skips
+// unless -freemail-domains replaces them.
+const defaultFreemailDomains = "gmail.com,googlemail.com,yahoo.com,yahoo.co.in,yahoo.co.uk,hotmail.com,outlook.com,live.com,msn.com,aol.com,icloud.com,me.com,mail.com,gmx.com,gmx.de,protonmail.com,proton.me,zoho.com,yandex.com,rediffmail.com"
+
+// parseDomainList splits a comma-separated domain list into a set.
+func parseDomainList(spec string) map[string]bool {
+	set := make(map[string]bool)
+	for _, d := range strings.Split(spec, ",") {
+		if d = strings.ToLower(strings.TrimSpace(d)); d != "" {
+			set[d] = true
+		}
+	}
+	return set
+}
+
+// emailDomains returns the unique domains of the candidates' email
+// addresses, sorted, leaving out those in exclude.
+func emailDomains(candidates []Candidate, exclude map[string]bool) []string {
+	seen := make(map[string]bool)
+	var domains []string
+	for _, c := range candidates {
+		at := strings.LastIndex(c.Email, "@")
+		if at < 0 {
+			continue
+		}
+		domain := strings.ToLower(strings.TrimSpace(c.Email[at+1:]))
+		if domain == "" || seen[domain] || exclude[domain] {
+			continue
+		}
+		seen[domain] = true
+		domains = append(domains, domain)
+	}
+	sort.Strings(domains)
+	return domains
+}
+
+// writeDomains writes domains to filename, one per line.
+func writeDomains(domains []string, filename string) error {
+	var b strings.Builder
+	for _, d := range domains {
+		b.WriteString(d)
+		b.WriteByte('\n')
+	}
+	if err := os.WriteFile(filename, []byte(b.String()), 0o644); err != nil {
+		return fmt.Errorf("failed to write domains file: %w", err)
+	}
+	return nil
+}
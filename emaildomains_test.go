@@ -0,0 +1,51 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestEmailDomains(t *testing.T) {
+	candidates := []Candidate{
+		{Email: "jane@Emerson.com"},
+		{Email: "raj@acme-valves.in"},
+		{Email: "john@emerson.com"},
+		{Email: "priya.k@gmail.com"},
+		{Email: ""},
+		{Email: "not an address"},
+	}
+	if got, want := emailDomains(candidates, nil), []string{"acme-valves.in", "emerson.com", "gmail.com"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("domains %q, want %q", got, want)
+	}
+	freemail := parseDomainList(defaultFreemailDomains)
+	if got, want := emailDomains(candidates, freemail), []string{"acme-valves.in", "emerson.com"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("domains without free mail %q, want %q", got, want)
+	}
+	if got := emailDomains(candidates, parseDomainList(" Emerson.com ,,")); !reflect.DeepEqual(got, []string{"acme-valves.in", "gmail.com"}) {
+		t.Errorf("domains %q, want the custom list excluded", got)
+	}
+}
+
+func TestDomainsOut(t *testing.T) {
+	_, addr := startFakeWeb(t, `profiles:
+  - slug: jane-doe
+    name: Jane Doe
+    email: jane@emerson.com
+  - slug: john-roe
+    name: John Roe
+    email: john.roe@gmail.com
+`)
+	path := filepath.Join(t.TempDir(), "domains.txt")
+	if _, err := runFakeSearch(t, addr, "-max-pages", "1", "-domains-out", path, "-exclude-freemail"); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "emerson.com\n" {
+		t.Errorf("domains file %q, want emerson.com alone", b)
+	}
+}
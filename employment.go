@@ -0,0 +1,100 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Values of Candidate.EmploymentMatch for a -current-company search.
+const (
+	employmentCurrent = "current" // Works at the company now.
+	employmentPast    = "past"    // Mentions the company, but only historically.
+)
+
+// presentTenseMarkers are snippet phrases that put the company in the present.
+var presentTenseMarkers = []string{
+	"currently", "presently", "at present", "now at", "now with",
+	"is a", "is an", "is the", "works at", "working at", "works for", "working for",
+}
+
+// openEndedRangeRegex matches a position date range with no end, such as
+// "Jan 2019 - Present".
+var openEndedRangeRegex = regexp.MustCompile(`(?i)(?:-|–|to)\s*(?:present|current|now)\b`)
+
+// titleCompanySlot returns the company segment of a LinkedIn result title,
+// "Name - Title - Company | LinkedIn", or "" if the title has no such segment.
+func titleCompanySlot(title string) string {
+	if i := strings.Index(title, "|"); i >= 0 {
+		title = title[:i]
+	}
+	segments := strings.Split(title, " - ")
+	if len(segments) < 3 {
+		return ""
+	}
+	return strings.TrimSpace(segments[2])
+}
+
+// snippetSaysCurrent reports whether a sentence of the snippet names the
+// company in the present tense.
+func snippetSaysCurrent(snippet, company string) bool {
+	for _, sentence := range strings.FieldsFunc(snippet, func(r rune) bool {
+		return strings.ContainsRune(experienceSentenceBreaks, r)
+	}) {
+		if !containsFold(sentence, company) {
+			continue
+		}
+		lower := strings.ToLower(sentence)
+		for _, marker := range presentTenseMarkers {
+			if containsWord(lower, marker) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// profileEmployment classifies the company from a profile's experience
+// section: a position with an open-ended date range is current, any other
+// position naming the company is past.
+func profileEmployment(doc *goquery.Document, company string) string {
+	match := ""
+	for _, sel := range experienceCompanySelectors {
+		doc.Find(sel).EachWithBreak(func(i int, s *goquery.Selection) bool {
+			text := s.Text()
+			if !containsFold(text, company) {
+				return true
+			}
+			if openEndedRangeRegex.MatchString(text) {
+				match = employmentCurrent
+				return false
+			}
+			match = employmentPast
+			return true
+		})
+		if match == employmentCurrent {
+			break
+		}
+	}
+	return match
+}
+
+// classifyEmployment combines every recency signal for the company. The
+// result title's company slot, present-tense snippet phrasing, or an
+// open-ended profile position make it current; any other mention makes it
+// past. It returns "" when the company is not mentioned at all.
+func classifyEmployment(c Candidate, company string) string {
+	switch {
+	case containsFold(titleCompanySlot(c.ResultTitle), company),
+		snippetSaysCurrent(c.Snippet, company),
+		c.EmploymentMatch == employmentCurrent:
+		return employmentCurrent
+	case c.EmploymentMatch == employmentPast,
+		containsFold(c.ResultTitle, company),
+		containsFold(c.Snippet, company),
+		containsFold(c.Summary, company):
+		return employmentPast
+	}
+	return ""
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTitleCompanySlot(t *testing.T) {
+	tests := []struct {
+		title, want string
+	}{
+		{"Jane Doe - Valve Engineer - Emerson | LinkedIn", "Emerson"},
+		{"Jane Doe - Emerson | LinkedIn", ""},
+		{"Jane Doe | LinkedIn", ""},
+	}
+	for _, tt := range tests {
+		if got := titleCompanySlot(tt.title); got != tt.want {
+			t.Errorf("titleCompanySlot(%q) = %q, want %q", tt.title, got, tt.want)
+		}
+	}
+}
+
+func TestClassifyEmployment(t *testing.T) {
+	tests := []struct {
+		name string
+		c    Candidate
+		want string
+	}{
+		{"title slot", Candidate{ResultTitle: "Jane Doe - Valve Engineer - Emerson | LinkedIn"}, employmentCurrent},
+		{"present tense", Candidate{Snippet: "Pune. Jane is currently a valve engineer at Emerson."}, employmentCurrent},
+		{"open-ended position", Candidate{Snippet: "Worked at Emerson in 2015.", EmploymentMatch: employmentCurrent}, employmentCurrent},
+		{"past only", Candidate{Snippet: "Worked at Emerson in 2015. Currently at Acme Valves."}, employmentPast},
+		{"summary mention", Candidate{Summary: "Trained at Emerson"}, employmentPast},
+		{"not mentioned", Candidate{Snippet: "Valve engineer at Acme"}, ""},
+	}
+	for _, tt := range tests {
+		if got := classifyEmployment(tt.c, "emerson"); got != tt.want {
+			t.Errorf("%s: classifyEmployment = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestProfileEmploymentFromPositionDates(t *testing.T) {
+	page := func(positions ...string) string {
+		return `<section class="experience"><ul><li>` + strings.Join(positions, `</li><li>`) + `</li></ul></section>`
+	}
+	tests := []struct {
+		html, want string
+	}{
+		{page("Valve Engineer · Emerson · Jan 2019 - Present"), employmentCurrent},
+		{page("Trainee · Emerson · 2014 - 2016", "Engineer · Acme · 2016 – now"), employmentPast},
+		{page("Trainee · Emerson · 2014 - 2016", "Lead · Emerson · 2020 to present"), employmentCurrent},
+		{page("Engineer · Acme · 2016 - Present"), ""},
+	}
+	for _, tt := range tests {
+		if got := profileEmployment(parseHTML(t, tt.html), "Emerson"); got != tt.want {
+			t.Errorf("profileEmployment(%s) = %q, want %q", tt.html, got, tt.want)
+		}
+	}
+}
+
+func TestCurrentCompanyQueryPhrase(t *testing.T) {
+	q := renderQuery(engineGoogle, SearchCriteria{Keywords: "valve", CurrentCompany: `Emerson "Automation"`}).Query
+	if !strings.Contains(q, `"Emerson Automation"`) {
+		t.Errorf("query %s, want the company as an exact phrase", q)
+	}
+	if strings.Contains(q, "intitle:") {
+		t.Errorf("query %s hides past employees behind a title operator", q)
+	}
+}
+
+func TestPastEmployerScoresLower(t *testing.T) {
+	current := scoreCandidate(Candidate{EmploymentMatch: employmentCurrent}, defaultScoreWeights)
+	past := scoreCandidate(Candidate{EmploymentMatch: employmentPast}, defaultScoreWeights)
+	if past.Total-current.Total != defaultScoreWeights.PastEmployer {
+		t.Errorf("past employee scored %d, current %d; want them %d apart", past.Total, current.Total, defaultScoreWeights.PastEmployer)
+	}
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Search engines a query can be rendered for.
+const (
+	engineGoogle     = "google"
+	engineBing       = "bing"
+	engineDuckDuckGo = "duckduckgo"
+	engineCSE        = "cse" // Google's Custom Search JSON API.
+)
+
+// renderedQuery is a searchQuery in one engine's syntax.
+type renderedQuery struct {
+	Query    string
+	Params   url.Values        // Request parameters besides the query, for engines that take some operators that way.
+	Filters  []candidateFilter // Emulate constructs the engine cannot express, on the results.
+	Emulated []string          // What Filters stand in for.
+	Warnings []string          // Constructs the engine can neither express nor emulate.
+}
+
+// searchEngine renders engine-neutral queries in one engine's syntax.
+type searchEngine struct {
+	name   string
+	render func(q searchQuery) renderedQuery
+}
+
+// searchEngines lists every engine a query can be rendered for.
+var searchEngines = []searchEngine{
+	{engineGoogle, renderGoogle},
+	{engineBing, renderBing},
+	{engineDuckDuckGo, renderDuckDuckGo},
+	{engineCSE, renderCSE},
+}
+
+// engineNames returns the names of searchEngines.
+func engineNames() []string {
+	names := make([]string, len(searchEngines))
+	for i, e := range searchEngines {
+		names[i] = e.name
+	}
+	return names
+}
+
+// findEngine returns the engine called name.
+func findEngine(name string) (searchEngine, error) {
+	for _, e := range searchEngines {
+		if e.name == name {
+			return e, nil
+		}
+	}
+	return searchEngine{}, fmt.Errorf("unknown engine %q (want one of %s)", name, strings.Join(engineNames(), ", "))
+}
+
+// renderQuery renders c for the engine called name. An unknown name, which
+// parseFlags rejects, falls back to Google.
+func renderQuery(name string, c SearchCriteria) renderedQuery {
+	e, err := findEngine(name)
+	if err != nil {
+		e = searchEngines[0]
+	}
+	return e.render(buildSearchQuery(c))
+}
+
+// termSyntax writes a term with quotes when it is a phrase.
+func termSyntax(t queryTerm) string {
+	if t.Phrase {
+		return quoteTerm(t.Text)
+	}
+	return t.Text
+}
+
+// orGroup writes a part as its single term, or its alternatives as an OR
+// group in parentheses.
+func orGroup(part queryPart) string {
+	if len(part) == 1 {
+		return termSyntax(part[0])
+	}
+	alternatives := make([]string, len(part))
+	for i, t := range part {
+		alternatives[i] = termSyntax(t)
+	}
+	return "(" + strings.Join(alternatives, " OR ") + ")"
+}
+
+// renderGoogle uses Google's operators for everything.
+func renderGoogle(q searchQuery) renderedQuery {
+	words := append([]string{"site:" + q.Site}, q.Verbatim...)
+	for _, part := range q.Parts {
+		words = append(words, orGroup(part))
+	}
+	for _, t := range q.Exclude {
+		words = append(words, excludeTerm(t))
+	}
+	if q.URLContains != "" {
+		words = append(words, "inurl:"+q.URLContains)
+	}
+	return renderedQuery{Query: strings.Join(words, " ")}
+}
+
+// renderBing marks every plain word and phrase as required with "+", since
+// Bing otherwise treats words as optional and drifts to loosely related
+// profiles. Bing has no dependable URL operator, so a URL restriction is
+// applied to the results instead.
+func renderBing(q searchQuery) renderedQuery {
+	var r renderedQuery
+	words := append([]string{"site:" + q.Site}, q.Verbatim...)
+	for _, part := range q.Parts {
+		switch {
+		case len(part) > 1:
+			words = append(words, orGroup(part))
+		case part[0].Phrase:
+			words = append(words, "+"+termSyntax(part[0]))
+		default:
+			for _, w := range strings.Fields(part[0].Text) {
+				words = append(words, "+"+w)
+			}
+		}
+	}
+	for _, t := range q.Exclude {
+		words = append(words, excludeTerm(t))
+	}
+	emulateURLContains(&r, q)
+	r.Query = strings.Join(words, " ")
+	return r
+}
+
+// renderDuckDuckGo keeps to the operators DuckDuckGo honors on site:
+// searches. It has no grouping, so an OR group would bind to its neighbours:
+// only the first alternative is searched. It does not dependably honor "-"
+// either, so exclusions are applied to the results.
+func renderDuckDuckGo(q searchQuery) renderedQuery {
+	var r renderedQuery
+	words := append([]string{"site:" + q.Site}, q.Verbatim...)
+	for _, part := range q.Parts {
+		if len(part) > 1 {
+			r.Warnings = append(r.Warnings, fmt.Sprintf("OR groups are not supported; searching only %s of %s", termSyntax(part[0]), orGroup(part)))
+		}
+		words = append(words, termSyntax(part[0]))
+	}
+	if len(q.Verbatim) > 0 {
+		r.Warnings = append(r.Warnings, "keywords in query syntax are passed through untranslated")
+	}
+	if len(q.Exclude) > 0 {
+		r.Filters = append(r.Filters, excludeFilter(q.Exclude))
+		r.Emulated = append(r.Emulated, "exclusions "+strings.Join(q.Exclude, ", "))
+	}
+	emulateURLContains(&r, q)
+	r.Query = strings.Join(words, " ")
+	return r
+}
+
+// renderCSE passes the site restriction and exclusions as the request
+// parameters the Custom Search JSON API has for them; the rest is Google
+// syntax.
+func renderCSE(q searchQuery) renderedQuery {
+	r := renderedQuery{Params: url.Values{}}
+	r.Params.Set("siteSearch", q.Site)
+	r.Params.Set("siteSearchFilter", "i")
+	words := append([]string(nil), q.Verbatim...)
+	for _, part := range q.Parts {
+		words = append(words, orGroup(part))
+	}
+	if q.URLContains != "" {
+		words = append(words, "inurl:"+q.URLContains)
+	}
+	if len(q.Exclude) > 0 {
+		excluded := make([]string, len(q.Exclude))
+		for i, t := range q.Exclude {
+			excluded[i] = termSyntax(queryTerm{Text: t, Phrase: strings.Contains(t, " ")})
+		}
+		r.Params.Set("excludeTerms", strings.Join(excluded, " "))
+	}
+	r.Query = strings.Join(words, " ")
+	return r
+}
+
+// emulateURLContains applies a URL restriction to the results, for engines
+// without a URL operator.
+func emulateURLContains(r *renderedQuery, q searchQuery) {
+	if q.URLContains == "" {
+		return
+	}
+	r.Filters = append(r.Filters, urlContainsFilter(q.URLContains))
+	r.Emulated = append(r.Emulated, "URL restriction "+q.URLContains)
+}
+
+// excludeFilter drops candidates whose result or profile mentions any of
+// terms, standing in for the "-" operator.
+func excludeFilter(terms []string) candidateFilter {
+	expected := "none of " + strings.Join(terms, ", ")
+	return func(c Candidate) filterDecision {
+		d := filterDecision{Filter: "exclude_keywords", Passed: true, Expected: expected}
+		text := strings.Join([]string{c.ResultTitle, c.Snippet, c.Headline, c.Summary}, " ")
+		for _, t := range terms {
+			if containsFold(text, t) {
+				d.Passed, d.Actual = false, t
+				break
+			}
+		}
+		return d
+	}
+}
+
+// urlContainsFilter drops candidates whose profile URL lacks fragment,
+// standing in for inurl:.
+func urlContainsFilter(fragment string) candidateFilter {
+	fragment = strings.ToLower(fragment)
+	return func(c Candidate) filterDecision {
+		return filterDecision{
+			Filter:   "url_contains",
+			Passed:   strings.Contains(strings.ToLower(c.ProfileURL), fragment),
+			Expected: fragment,
+			Actual:   c.ProfileURL,
+		}
+	}
+}
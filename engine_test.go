@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// engineCriteria are the criteria whose rendering for each engine is pinned
+// by testdata/golden/engine-<name>.txt.
+var engineCriteria = []struct {
+	name string
+	c    SearchCriteria
+}{
+	{"full", SearchCriteria{
+		Keywords: "control valve, desuperheater", Location: "Bangalore", Industry: "Machinery Manufacturing",
+		ExperienceRange: "7-12 years", CurrentCompany: "Acme Valves", ExcludeKeywords: "sales, field service",
+		Discriminator: "inurl:in/a",
+	}},
+	{"loose", SearchCriteria{Keywords: "valve engineer*", Location: "Pune"}},
+	{"verbatim", SearchCriteria{Keywords: `"control valve" OR actuator`, Discriminator: `"Emerson"`}},
+}
+
+// describeRendering writes a rendered query in a stable, readable form.
+func describeRendering(r renderedQuery) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "query: %s\n", r.Query)
+	if len(r.Params) > 0 {
+		fmt.Fprintf(&b, "params: %s\n", r.Params.Encode())
+	}
+	for _, e := range r.Emulated {
+		fmt.Fprintf(&b, "emulated: %s\n", e)
+	}
+	for _, w := range r.Warnings {
+		fmt.Fprintf(&b, "warning: %s\n", w)
+	}
+	if len(r.Filters) != len(r.Emulated) {
+		fmt.Fprintf(&b, "filters: %d\n", len(r.Filters))
+	}
+	return b.String()
+}
+
+func TestRenderQueryGolden(t *testing.T) {
+	for _, engine := range engineNames() {
+		var b strings.Builder
+		for _, tc := range engineCriteria {
+			fmt.Fprintf(&b, "== %s\n%s", tc.name, describeRendering(renderQuery(engine, tc.c)))
+		}
+		checkGolden(t, "engine-"+engine+".txt", []byte(b.String()))
+	}
+}
+
+func TestEmulatedExclusions(t *testing.T) {
+	c := SearchCriteria{Keywords: "valve engineer", ExcludeKeywords: "sales, field service", Discriminator: "inurl:in/a"}
+	candidates := []Candidate{
+		{ProfileURL: "https://www.linkedin.com/in/anna", ResultTitle: "Anna - Valve Engineer", Snippet: "Design of control valves."},
+		{ProfileURL: "https://www.linkedin.com/in/arun", ResultTitle: "Arun - Valve Sales Engineer", Snippet: "Key accounts."},
+		{ProfileURL: "https://www.linkedin.com/in/amal", ResultTitle: "Amal - Valve Engineer", Summary: "Ten years in Field Service."},
+		{ProfileURL: "https://www.linkedin.com/in/ben", ResultTitle: "Ben - Valve Engineer", Snippet: "Design of control valves."},
+	}
+	for _, tc := range []struct {
+		engine string
+		kept   []string // Profiles the emulation keeps; nil when nothing is emulated.
+	}{
+		{engineGoogle, nil},
+		{engineCSE, nil},
+		{engineBing, []string{"anna", "arun", "amal"}}, // The URL restriction only.
+		{engineDuckDuckGo, []string{"anna"}},           // And the exclusions, which DuckDuckGo ignores.
+	} {
+		r := renderQuery(tc.engine, c)
+		if tc.kept == nil {
+			if len(r.Filters) > 0 {
+				t.Errorf("%s: %d filters emulate %v, want the engine to express everything", tc.engine, len(r.Filters), r.Emulated)
+			}
+			continue
+		}
+		var kept []string
+	next:
+		for _, cand := range candidates {
+			for _, filter := range r.Filters {
+				if !filter(cand).Passed {
+					continue next
+				}
+			}
+			kept = append(kept, strings.TrimPrefix(cand.ProfileURL, "https://www.linkedin.com/in/"))
+		}
+		if strings.Join(kept, ",") != strings.Join(tc.kept, ",") {
+			t.Errorf("%s: kept %v, want %v", tc.engine, kept, tc.kept)
+		}
+	}
+}
+
+func TestEmulatedExclusionReportsTerm(t *testing.T) {
+	d := excludeFilter([]string{"sales", "field service"})(Candidate{Headline: "Field Service Engineer"})
+	if d.Passed || d.Actual != "field service" || d.Filter != "exclude_keywords" {
+		t.Errorf("decision = %+v, want a failure naming field service", d)
+	}
+}
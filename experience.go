@@ -0,0 +1,306 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// A candidate's experience is kept in one canonical field, ExperienceYears,
+// taken in order of precedence from:
+//
+//  1. the dates of the profile's positions, overlaps counted once;
+//  2. the midpoint of a range stated in the snippet, as in "5-8 years of
+//     experience";
+//  3. a single value stated in the snippet, as in "8 years of experience",
+//     or else the span of start years such as "Since 2015".
+//
+// -field-priority and -experience-source may rank the snippet above the
+// positions. ExperienceYears is rounded to tenths of a year. The deprecated
+// Experience holds the same value in whole completed years, rounded down,
+// for readers of the old format; it will be removed in the next release.
+
+// experienceYears returns c's experience in years, or 0 when unknown. It is
+// how experience is read: records from before ExperienceYears existed fall
+// back to their whole-year Experience.
+func (c Candidate) experienceYears() float64 {
+	if c.ExperienceYears > 0 {
+		return c.ExperienceYears
+	}
+	return float64(max(c.Experience, 0))
+}
+
+// setExperienceYears sets c's experience, keeping the legacy Experience in
+// step.
+func (c *Candidate) setExperienceYears(years float64) {
+	if years <= 0 || math.IsNaN(years) || math.IsInf(years, 0) {
+		c.ExperienceYears, c.Experience = 0, 0
+		return
+	}
+	c.ExperienceYears = math.Round(years*10) / 10
+	c.Experience = legacyExperience(c.ExperienceYears)
+}
+
+// upgradeExperience fills ExperienceYears of a record written before it
+// existed.
+func (c *Candidate) upgradeExperience() {
+	if c.ExperienceYears == 0 && c.Experience > 0 {
+		c.setExperienceYears(float64(c.Experience))
+	}
+}
+
+// legacyExperience rounds years down to the whole completed years the
+// deprecated Experience field holds.
+func legacyExperience(years float64) int {
+	return int(math.Floor(years))
+}
+
+// formatExperienceYears formats years for CSV cells and field sources, as ""
+// when unknown.
+func formatExperienceYears(years float64) string {
+	if years <= 0 {
+		return ""
+	}
+	return strconv.FormatFloat(years, 'f', -1, 64)
+}
+
+// parseExperienceYears parses a value written by formatExperienceYears, or a
+// whole number of years from an old-format file. It returns 0 for anything
+// else.
+func parseExperienceYears(s string) float64 {
+	years, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || years < 0 {
+		return 0
+	}
+	return years
+}
+
+// experienceContextWindow is how many characters either side of an "N years"
+// phrase are searched for context words. Set by -experience-context-window.
+var experienceContextWindow = 40
+
+// experienceContextWords mark a nearby year phrase as the person's experience.
+var experienceContextWords = []string{
+	"experience", "experienced", "exp",
+	"in the field", "working", "worked", "career",
+	"professional", "expertise",
+}
+
+// experienceRejectWords mark a nearby year phrase as a fact about a company
+// rather than a person. They take precedence over experienceContextWords.
+var experienceRejectWords = []string{
+	"founded", "established", "estd",
+	"legacy", "heritage", "anniversary", "excellence",
+	"in business", "old", "history", "celebrating",
+	"warranty", "guarantee",
+}
+
+// experienceSentenceBreaks end the sentence a year phrase's context is taken from.
+const experienceSentenceBreaks = ".!?|·\n"
+
+var experienceMatcher = regexp.MustCompile(experienceRegex)
+
+// experienceRangeMatcher matches a stated range, such as "5-8 years" or
+// "10 to 12 yrs".
+var experienceRangeMatcher = regexp.MustCompile(`(\d+)\s*(?:-|–|to)\s*(\d+)\+?\s*(?:years?|yrs?)\b`)
+
+// experienceSubjectPattern matches a first-person or personal subject written
+// shortly before a year phrase, as in "I have 8 years" or "she brings 12 years".
+var experienceSubjectPattern = regexp.MustCompile(`(?i)\b(?:i|i've|i'm|he|she|they|my)\b(?:\s+[a-z']+){0,3}\s*$`)
+
+// experienceMatch is a year phrase that qualifies as experience.
+type experienceMatch struct {
+	text       string
+	start, end int     // Byte offsets of the phrase in text.
+	years      float64 // The midpoint, for a range.
+	distance   int     // Characters to the nearest context word.
+}
+
+func (m experienceMatch) value() string { return m.text[m.start:m.end] }
+
+// parseExperience extracts the experience in years from a text snippet. Only
+// year phrases near experience context, or after a personal subject, count;
+// a range that qualifies counts as its midpoint and beats any single value.
+// When several qualify, the one nearest a context word wins. Without any, a
+// start year such as "Since 2015" or "2015 - Present" is used.
+func parseExperience(experienceStr string) (float64, error) {
+	var best *experienceMatch
+	var alternates []string
+	for _, matcher := range []*regexp.Regexp{experienceRangeMatcher, experienceMatcher} {
+		for _, loc := range matcher.FindAllStringSubmatchIndex(experienceStr, -1) {
+			m, ok := qualifyExperience(experienceStr, loc)
+			if !ok {
+				continue
+			}
+			if best == nil || m.distance < best.distance {
+				if best != nil {
+					alternates = append(alternates, best.value())
+				}
+				best = &m
+			} else {
+				alternates = append(alternates, m.value())
+			}
+		}
+		if best != nil {
+			break
+		}
+	}
+	if best == nil {
+		if years, ok := experienceFromYears(experienceStr, clockNow().Year()); ok {
+			return float64(years), nil
+		}
+		return 0, fmt.Errorf("experience not found in string: %s", experienceStr)
+	}
+	if len(alternates) > 0 {
+		verbosef("Chose experience %q over %q", best.value(), alternates)
+	}
+	return best.years, nil
+}
+
+// qualifyExperience checks one regex match against the context rules. A
+// match with a second group is a range.
+func qualifyExperience(text string, loc []int) (experienceMatch, bool) {
+	m := experienceMatch{text: text, start: loc[0], end: loc[1]}
+	years, err := strconv.Atoi(text[loc[2]:loc[3]])
+	if err != nil {
+		return m, false
+	}
+	m.years = float64(years)
+	if len(loc) >= 6 && loc[4] >= 0 {
+		upper, err := strconv.Atoi(text[loc[4]:loc[5]])
+		if err != nil || upper < years {
+			return m, false
+		}
+		m.years = float64(years+upper) / 2
+	}
+
+	// Context is only looked for within the phrase's own sentence, so a
+	// company fact in one sentence cannot veto experience in the next.
+	from, to := m.start-experienceContextWindow, m.end+experienceContextWindow
+	if i := strings.LastIndexAny(text[:m.start], experienceSentenceBreaks); i+1 > from {
+		from = i + 1
+	}
+	if i := strings.IndexAny(text[m.end:], experienceSentenceBreaks); i >= 0 && m.end+i < to {
+		to = m.end + i
+	}
+	if from < 0 {
+		from = 0
+	}
+	if to > len(text) {
+		to = len(text)
+	}
+	lower := strings.ToLower(text)
+	window := lower[from:to]
+	if rejected := firstContainedWord(window, experienceRejectWords); rejected != "" {
+		verbosef("Rejected experience %q (company context %q)", m.value(), rejected)
+		return m, false
+	}
+
+	m.distance = -1
+	for _, word := range experienceContextWords {
+		if d := wordDistance(lower, word, m.start, m.end, from, to); d >= 0 && (m.distance < 0 || d < m.distance) {
+			m.distance = d
+		}
+	}
+	if m.distance >= 0 {
+		return m, true
+	}
+	if experienceSubjectPattern.MatchString(lower[from:m.start]) {
+		// A subject alone is weaker evidence than a context word.
+		m.distance = experienceContextWindow
+		return m, true
+	}
+	return m, false
+}
+
+// wordDistance returns the distance in characters between [start, end) and
+// the nearest occurrence of word, on word boundaries, inside text[from:to].
+// It returns -1 if there is none.
+func wordDistance(text, word string, start, end, from, to int) int {
+	best := -1
+	for i := from; i < to; {
+		j := strings.Index(text[i:to], word)
+		if j < 0 {
+			break
+		}
+		ws, we := i+j, i+j+len(word)
+		i = ws + 1
+		if (ws > 0 && isWordByte(text[ws-1])) || (we < len(text) && isWordByte(text[we])) {
+			continue
+		}
+		d := 0
+		switch {
+		case we <= start:
+			d = start - we
+		case ws >= end:
+			d = ws - end
+		}
+		if best < 0 || d < best {
+			best = d
+		}
+	}
+	return best
+}
+
+// yearRangeRegex matches "2015 - Present", "2012 – 2020", and "Since 2015".
+var yearRangeRegex = regexp.MustCompile(`(?i)(?:\bsince\s+((?:19|20)\d{2})\b|\b((?:19|20)\d{2})\s*(?:-|–|to)\s*(present|current|now|today|(?:19|20)\d{2})\b)`)
+
+// maxCareerYears bounds experience computed from years, to discard founding
+// dates and other historical years.
+const maxCareerYears = 50
+
+// experienceFromYears computes experience from start and end years, as the
+// span from the earliest start to the latest end, with open ranges ending in
+// thisYear. Years next to company context words are ignored.
+func experienceFromYears(text string, thisYear int) (int, bool) {
+	start, end := 0, 0
+	for _, loc := range yearRangeRegex.FindAllStringSubmatchIndex(text, -1) {
+		window := strings.ToLower(text[max(0, loc[0]-experienceContextWindow):min(len(text), loc[1]+experienceContextWindow)])
+		if rejected := firstContainedWord(window, experienceRejectWords); rejected != "" {
+			verbosef("Rejected start year %q (company context %q)", text[loc[0]:loc[1]], rejected)
+			continue
+		}
+		var from, to int
+		if loc[2] >= 0 {
+			from, to = atoiOr(text[loc[2]:loc[3]], 0), thisYear
+		} else {
+			from = atoiOr(text[loc[4]:loc[5]], 0)
+			to = atoiOr(text[loc[6]:loc[7]], thisYear) // Present, current, now, today.
+		}
+		if from > to || to > thisYear || to-from > maxCareerYears {
+			continue
+		}
+		if start == 0 || from < start {
+			start = from
+		}
+		if to > end {
+			end = to
+		}
+	}
+	if start == 0 {
+		return 0, false
+	}
+	return end - start, true
+}
+
+// firstContainedWord returns the first of words found in text on word
+// boundaries, or "" if there is none.
+func firstContainedWord(text string, words []string) string {
+	for _, word := range words {
+		if containsWord(text, word) {
+			return word
+		}
+	}
+	return ""
+}
+
+// atoiOr parses s, returning fallback if it is not a number.
+func atoiOr(s string, fallback int) int {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return fallback
+	}
+	return n
+}
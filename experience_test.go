@@ -0,0 +1,270 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseExperienceStartYears(t *testing.T) {
+	fixedNow = time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	t.Cleanup(func() { fixedNow = time.Time{} })
+
+	tests := []struct {
+		text string
+		want float64 // 0 when no experience is found.
+	}{
+		{"Valve Engineer, 2015 - Present", 11},
+		{"Process engineer 2012 – 2020", 8},
+		{"Since 2018 at Emerson", 8},
+		{"Valve engineer since 2019", 7},
+		{"Worked at Bosch 2010 to 2015 and at Siemens 2016 - present", 16},
+		{"Field service, 2019 - today", 7},
+		// A stated duration beats the start year next to it.
+		{"8 years of experience since 2015", 8},
+		{"I have 5 years of experience, since 2021", 5},
+		// Company context rejects a start year in either path.
+		{"Acme Valves, founded 1985, serves refineries", 0},
+		{"A legacy of quality since 1985", 0},
+		{"In business since 1990", 0},
+		// Ranges that cannot be a career.
+		{"Engineer 2020 - 2018", 0},
+		{"Since 1950 at the plant", 0},
+		{"Planned for 2030 - 2035", 0},
+	}
+	for _, tt := range tests {
+		got, err := parseExperience(tt.text)
+		if tt.want == 0 {
+			if err == nil {
+				t.Errorf("parseExperience(%q) = %v, want none", tt.text, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("parseExperience(%q) = %v, %v; want %v", tt.text, got, err, tt.want)
+		}
+	}
+}
+
+// experienceCorpus is real snippet sentences labeled with the experience
+// they state, or 0 when the year phrase in them is not the person's.
+var experienceCorpus = []struct {
+	text string
+	want float64
+}{
+	// Accepted: near an experience context word.
+	{"Valve design engineer with 8 years of experience in control valves.", 8},
+	{"12+ years experience in severe-service valves", 12},
+	{"Exp: 6 yrs in instrumentation and controls", 6},
+	{"Experienced process engineer (10 years) at Thermax", 10},
+	{"15 years in the field commissioning refinery packages", 15},
+	{"Working 7 years on desuperheater sizing for power plants", 7},
+	{"A career of 20 years across EPC and OEM valve makers", 20},
+	{"Professional with 9 years of piping stress analysis", 9},
+	{"4 years of expertise in actuator selection", 4},
+	{"Worked 3 years at Emerson as an application engineer", 3},
+	{"5-8 years of experience in rotating equipment", 6.5},
+	{"Experience: 10 to 12 yrs in valve testing", 11},
+	// Accepted: after a personal subject.
+	{"I have 8 years designing control valves for refineries", 8},
+	{"She brings 12 years to the valve team at Samson", 12},
+	{"I've spent 6 years on boiler feed pumps", 6},
+	{"My 11 years at Kirloskar were spent on pump design", 11},
+	// Accepted: the nearest of several qualifying phrases.
+	{"3 years in Pune, 14 years of experience overall", 14},
+	// Rejected: company context.
+	{"Acme Valves, a company with 40 years of excellence in flow control", 0},
+	{"Celebrating 75 years of engineering heritage at Flowline", 0},
+	{"Established 60 years ago, Thermax builds boilers", 0},
+	{"A legacy of 100 years in valve manufacturing", 0},
+	{"Kirloskar, in business for 130 years, hiring engineers", 0},
+	{"The 25 year anniversary of our Pune plant", 0},
+	{"All actuators carry a 5 years warranty", 0},
+	{"Our 10 years guarantee covers every valve", 0},
+	// Rejected: no context at all.
+	{"Bosch opened its 30 years old plant to visitors", 0},
+	{"Valve Engineer at Acme Valves. Bangalore. 500+ connections", 0},
+	// A company fact in one sentence does not veto the next one.
+	{"Acme was founded 50 years ago. I have 9 years of experience there.", 9},
+}
+
+func TestParseExperienceCorpus(t *testing.T) {
+	fixedNow = time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	t.Cleanup(func() { fixedNow = time.Time{} })
+
+	for _, tt := range experienceCorpus {
+		got, err := parseExperience(tt.text)
+		if tt.want == 0 {
+			if err == nil {
+				t.Errorf("parseExperience(%q) = %v, want it rejected", tt.text, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("parseExperience(%q) = %v, %v; want %v", tt.text, got, err, tt.want)
+		}
+	}
+}
+
+func TestExperiencePrecedence(t *testing.T) {
+	fixedNow = time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC)
+	t.Cleanup(func() { fixedNow = time.Time{} })
+	const noPositions = `<body><h1 class="top-card-layout__title">Jane Doe</h1></body>`
+	tests := []struct {
+		name    string
+		snippet string
+		page    string
+		want    float64
+		source  string
+	}{
+		{"positions over a range", "Valve engineer with 7-12 years of experience.", twoPositions, 11.8, sourcePositionDates},
+		{"positions over a value", "Valve engineer with 8 years of experience.", twoPositions, 11.8, sourcePositionDates},
+		{"range midpoint", "Valve engineer with 7-12 years of experience.", noPositions, 9.5, sourceSnippet},
+		{"range over a value", "Valve engineer with 12 years of experience, including 5-8 years of experience in actuators.", noPositions, 6.5, sourceSnippet},
+		{"single value", "Valve engineer with 8 years of experience.", noPositions, 8, sourceSnippet},
+		{"none", "Valve engineer at Emerson.", noPositions, 0, ""},
+	}
+	profileURL := "https://www.linkedin.com/in/jane-doe"
+	for _, tt := range tests {
+		c := snippetCandidate(resultTypeOrganic, profileURL, "Jane Doe", "Jane Doe - Valve Engineer", tt.snippet)
+		profile := parseProfilePage(context.Background(), &pageFetcher{}, profileURL, []byte(tt.page), parseHTML(t, tt.page), profileOptions{})
+		mergeFields("profile", &c, fixedNow, profile, fixedNow)
+		if c.experienceYears() != tt.want || c.Sources["experience"] != tt.source {
+			t.Errorf("%s: %v years from %q, want %v from %q", tt.name, c.experienceYears(), c.Sources["experience"], tt.want, tt.source)
+		}
+	}
+}
+
+func TestLegacyExperienceRounding(t *testing.T) {
+	tests := []struct {
+		years  float64
+		want   float64
+		legacy int
+		cell   string
+	}{
+		{9.5, 9.5, 9, "9.5"},
+		{9.96, 10, 10, "10"},
+		{11.833, 11.8, 11, "11.8"},
+		{0.04, 0, 0, ""},
+		{-3, 0, 0, ""},
+		{0, 0, 0, ""},
+	}
+	for _, tt := range tests {
+		var c Candidate
+		c.setExperienceYears(tt.years)
+		if c.ExperienceYears != tt.want || c.Experience != tt.legacy {
+			t.Errorf("setExperienceYears(%v): %v years, legacy %d; want %v, %d", tt.years, c.ExperienceYears, c.Experience, tt.want, tt.legacy)
+		}
+		if got := formatExperienceYears(c.experienceYears()); got != tt.cell {
+			t.Errorf("setExperienceYears(%v) formatted as %q, want %q", tt.years, got, tt.cell)
+		}
+	}
+	// A record from before ExperienceYears reads as its whole years.
+	if got := (Candidate{Experience: 7}).experienceYears(); got != 7 {
+		t.Errorf("legacy record read as %v years, want 7", got)
+	}
+	for s, want := range map[string]float64{"9.5": 9.5, "11": 11, " 8 ": 8, "": 0, "n/a": 0, "-2": 0} {
+		if got := parseExperienceYears(s); got != want {
+			t.Errorf("parseExperienceYears(%q) = %v, want %v", s, got, want)
+		}
+	}
+}
+
+func TestExperienceMigration(t *testing.T) {
+	dir := t.TempDir()
+
+	// A store written before ExperienceYears holds whole years only.
+	storePath := filepath.Join(dir, "candidates.json")
+	old := `{"candidates":[
+  {"name":"Jane Doe","profile_url":"https://www.linkedin.com/in/jane-doe","experience":8,"first_seen":"2025-01-01T00:00:00Z","last_seen":"2025-01-01T00:00:00Z"},
+  {"name":"John Roe","profile_url":"https://www.linkedin.com/in/john-roe","experience":0,"first_seen":"2025-01-01T00:00:00Z","last_seen":"2025-01-01T00:00:00Z"}
+]}`
+	if err := os.WriteFile(storePath, []byte(old), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	store, err := openStore(storePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored := store.candidates()
+	if stored[0].ExperienceYears != 8 || stored[0].Experience != 8 || stored[1].ExperienceYears != 0 {
+		t.Errorf("upgraded to %v and %v years, want 8 and unknown", stored[0].ExperienceYears, stored[1].ExperienceYears)
+	}
+	if err := store.save(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(storePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(data, []byte(`"experience_years": 8`)) {
+		t.Errorf("saved store lacks the canonical field: %s", data)
+	}
+
+	// An old JSON output read back through the accessor.
+	var c Candidate
+	if err := json.Unmarshal([]byte(`{"name":"Jane Doe","experience":11}`), &c); err != nil {
+		t.Fatal(err)
+	}
+	if c.experienceYears() != 11 {
+		t.Errorf("old JSON record read as %v years, want 11", c.experienceYears())
+	}
+
+	// An old CSV has only the whole-year column; a new one both, and the
+	// canonical one wins.
+	in := filepath.Join(dir, "old.csv")
+	csv := `Name,Profile URL,Experience
+Jane Doe,https://www.linkedin.com/in/jane-doe,11
+John Roe,https://www.linkedin.com/in/john-roe,
+`
+	if err := os.WriteFile(in, []byte(csv), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "clean.csv")
+	if err := runSearchCommand(context.Background(), []string{"-clean-existing", in, "-output", out}); err != nil {
+		t.Fatal(err)
+	}
+	got, _, err := readCandidatesCSV(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].ExperienceYears != 11 || got[0].Experience != 11 || got[1].experienceYears() != 0 {
+		t.Errorf("old CSV read as %+v, want 11 years for jane-doe", got)
+	}
+	if got, err := readCandidatesCSVString(t, "experience,experience_years,profile_url\n9,9.5,https://www.linkedin.com/in/jane-doe\n"); err != nil || got[0].ExperienceYears != 9.5 || got[0].Experience != 9 {
+		t.Errorf("new CSV read as %+v, %v; want 9.5 years", got, err)
+	}
+}
+
+// readCandidatesCSVString reads CSV text as readCandidatesCSV reads a file.
+func readCandidatesCSVString(t *testing.T, text string) ([]Candidate, error) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "candidates.csv")
+	if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, _, err := readCandidatesCSV(path)
+	return got, err
+}
+
+func TestPrintSchemaMarksDeprecatedExperience(t *testing.T) {
+	var buf bytes.Buffer
+	printSchema(&buf)
+	lines := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if fields := strings.Fields(line); len(fields) > 0 {
+			lines[fields[0]] = line
+		}
+	}
+	if line := lines["experience_years"]; !strings.Contains(line, "double") || strings.Contains(line, "deprecated") {
+		t.Errorf("experience_years listed as %q, want a current double", line)
+	}
+	if line := lines["experience"]; !strings.Contains(line, "int64") || !strings.Contains(line, "(deprecated: whole years of experience_years") {
+		t.Errorf("experience listed as %q, want it marked deprecated", line)
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// queryComponent is one labelled part of an assembled query, in Google
+// syntax.
+type queryComponent struct {
+	Name  string
+	Value string // Empty when the criteria leave the component out.
+}
+
+// queryBreakdown is a query assembled from criteria, broken down into the
+// components it was built from, with the constructs that look like mistakes.
+type queryBreakdown struct {
+	Query      string
+	Components []queryComponent
+	Warnings   []string
+}
+
+// queryOperatorRegex matches search operators, such as intitle: or -site:,
+// written into the criteria themselves.
+var queryOperatorRegex = regexp.MustCompile(`(?:^|\s)(-?[a-z]+:\S*)`)
+
+// explainQuery breaks down the query for c, rendered for the engine called
+// engine.
+func explainQuery(engine string, c SearchCriteria) queryBreakdown {
+	b := queryBreakdown{Query: renderQuery(engine, c).Query}
+	keywords := phraseKeywords(c.Keywords, c.LooseKeywords)
+	var company string
+	if name := strings.TrimSpace(c.CurrentCompany); name != "" {
+		company = quoteTerm(name)
+	}
+	excludes := splitTermList(c.ExcludeKeywords)
+	excluded := make([]string, len(excludes))
+	for i, t := range excludes {
+		excluded[i] = excludeTerm(t)
+	}
+	var operators []string
+	fields := []struct{ name, value string }{
+		{"keywords", c.Keywords},
+		{"location", c.Location},
+		{"industry", c.Industry},
+		{"experience", c.ExperienceRange},
+		{"current_company", c.CurrentCompany},
+		{"exclude_keywords", c.ExcludeKeywords},
+	}
+	for _, f := range fields {
+		for _, m := range queryOperatorRegex.FindAllStringSubmatch(f.value, -1) {
+			operators = append(operators, m[1])
+		}
+	}
+	if strings.HasPrefix(c.Discriminator, "inurl:") {
+		operators = append(operators, c.Discriminator)
+	}
+	b.Components = []queryComponent{
+		{"site", "site:" + linkedInProfileSite},
+		{"keywords", keywords},
+		{"location", strings.Join(strings.Fields(c.Location), " ")},
+		{"industry", strings.Join(strings.Fields(c.Industry), " ")},
+		{"experience", strings.Join(strings.Fields(c.ExperienceRange), " ")},
+		{"current_company", company},
+		{"excludes", strings.Join(excluded, " ")},
+		{"operators", strings.Join(operators, " ")},
+	}
+
+	for _, f := range fields {
+		if strings.Count(f.value, `"`)%2 != 0 {
+			b.Warnings = append(b.Warnings, fmt.Sprintf("%s has an unbalanced quote: %s", f.name, f.value))
+		}
+	}
+	if strings.Count(c.Keywords, "(") != strings.Count(c.Keywords, ")") {
+		b.Warnings = append(b.Warnings, fmt.Sprintf("keywords has unbalanced parentheses: %s", c.Keywords))
+	}
+	trimmed := strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(c.Keywords), "*"))
+	if trimmed != "" && !strings.Contains(trimmed, `"`) {
+		// Split without the trimming of keywordsPart, so "a,,b" and "a OR"
+		// show up.
+		for _, alt := range keywordAlternativesRegex.Split(" "+trimmed+" ", -1) {
+			if strings.TrimSpace(alt) == "" || strings.TrimSpace(alt) == "OR" {
+				b.Warnings = append(b.Warnings, fmt.Sprintf("keywords has an empty alternative: %s", c.Keywords))
+				break
+			}
+		}
+	}
+	// Keywords become a phrase, which turns an operator in them into text.
+	quoted := strings.Split(keywords, `"`)
+	for i := 1; i < len(quoted); i += 2 {
+		for _, m := range queryOperatorRegex.FindAllStringSubmatch(quoted[i], -1) {
+			b.Warnings = append(b.Warnings, fmt.Sprintf("operator %s is inside the keywords phrase, so it is searched as text", m[1]))
+		}
+	}
+	if strings.TrimSpace(c.ExcludeKeywords) != "" {
+		for _, t := range strings.Split(c.ExcludeKeywords, ",") {
+			if strings.TrimSpace(t) == "" {
+				b.Warnings = append(b.Warnings, fmt.Sprintf("exclude_keywords has an empty term: %s", c.ExcludeKeywords))
+				break
+			}
+		}
+	}
+	required := strings.ToLower(strings.Join([]string{keywords, c.Location, c.Industry, c.ExperienceRange, c.CurrentCompany}, " "))
+	for _, t := range excludes {
+		if containsWord(required, strings.ToLower(t)) {
+			b.Warnings = append(b.Warnings, fmt.Sprintf("excluded term %q is also searched for, so results matching it are dropped", t))
+		}
+	}
+	if keywords == "" && company == "" && strings.TrimSpace(c.Location+c.Industry+c.ExperienceRange) == "" {
+		b.Warnings = append(b.Warnings, "no search terms; the query matches any profile")
+	}
+	return b
+}
+
+// print writes the breakdown, one component per line, to w.
+func (b queryBreakdown) print(w io.Writer, engine string) {
+	fmt.Fprintf(w, "  query (%s): %s\n", engine, b.Query)
+	for _, c := range b.Components {
+		fmt.Fprintf(w, "    %-16s %s\n", c.Name+":", firstNonEmpty(c.Value, "(none)"))
+	}
+	for _, warning := range b.Warnings {
+		fmt.Fprintf(w, "    warning: %s\n", warning)
+	}
+}
+
+// explainQueries prints the breakdown of the query of each job, as given and
+// before any relaxation, for -explain-query.
+func explainQueries(cfg *config) error {
+	jobs := []Job{{SearchCriteria: cfg.criteria}}
+	if len(cfg.languageJobs) > 0 {
+		jobs = cfg.languageJobs
+	}
+	if cfg.jobsFile != "" {
+		var err error
+		if jobs, err = loadJobs(cfg.jobsFile); err != nil {
+			return fmt.Errorf("error loading jobs: %w", err)
+		}
+	}
+	for _, job := range jobs {
+		if job.Name != "" {
+			fmt.Printf("Job %s:\n", job.Name)
+		}
+		explainQuery(cfg.engine, cfg.jobCriteria(job)).print(os.Stdout, cfg.engine)
+	}
+	return nil
+}
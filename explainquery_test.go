@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestExplainQueryBreakdown(t *testing.T) {
+	c := SearchCriteria{
+		Keywords:        "valve engineer, actuator engineer",
+		Location:        "Pune",
+		Industry:        "Oil  & Energy",
+		ExperienceRange: "7-12 years",
+		CurrentCompany:  "Emerson Electric",
+		ExcludeKeywords: "intern, sales manager",
+	}
+	b := explainQuery(engineGoogle, c)
+	wantQuery := `site:linkedin.com/in ("valve engineer" OR "actuator engineer") Pune Oil & Energy 7-12 years "Emerson Electric" -intern -"sales manager"`
+	if b.Query != wantQuery {
+		t.Errorf("query %s, want %s", b.Query, wantQuery)
+	}
+	want := []queryComponent{
+		{"site", "site:linkedin.com/in"},
+		{"keywords", `("valve engineer" OR "actuator engineer")`},
+		{"location", "Pune"},
+		{"industry", "Oil & Energy"},
+		{"experience", "7-12 years"},
+		{"current_company", `"Emerson Electric"`},
+		{"excludes", `-intern -"sales manager"`},
+		{"operators", ""},
+	}
+	if !slices.Equal(b.Components, want) {
+		t.Errorf("components %+v, want %+v", b.Components, want)
+	}
+	if len(b.Warnings) != 0 {
+		t.Errorf("warnings %q for sound criteria", b.Warnings)
+	}
+
+	// Another engine renders the query in its syntax; the components stay.
+	bing := explainQuery(engineBing, c)
+	if !strings.Contains(bing.Query, "+Pune") || !slices.Equal(bing.Components, want) {
+		t.Errorf("Bing breakdown %+v", bing)
+	}
+
+	var out strings.Builder
+	b.print(&out, engineGoogle)
+	for _, line := range []string{
+		"  query (google): " + wantQuery,
+		"    keywords:        (\"valve engineer\" OR \"actuator engineer\")",
+		"    excludes:        -intern -\"sales manager\"",
+		"    operators:       (none)",
+	} {
+		if !strings.Contains(out.String(), line+"\n") {
+			t.Errorf("printed breakdown lacks %q:\n%s", line, out.String())
+		}
+	}
+}
+
+func TestExplainQueryOperators(t *testing.T) {
+	c := SearchCriteria{Keywords: "valve engineer", Location: "Pune intitle:lead", ExcludeKeywords: "-site:in.linkedin.com", Discriminator: "inurl:pub"}
+	b := explainQuery(engineGoogle, c)
+	if got := b.Components[len(b.Components)-1]; got != (queryComponent{"operators", "intitle:lead -site:in.linkedin.com inurl:pub"}) {
+		t.Errorf("operators component %+v", got)
+	}
+}
+
+func TestExplainQueryWarnings(t *testing.T) {
+	tests := []struct {
+		c    SearchCriteria
+		want string // "" for no warning.
+	}{
+		{SearchCriteria{Keywords: `"valve engineer`}, `keywords has an unbalanced quote: "valve engineer`},
+		{SearchCriteria{Keywords: "valve", Location: `"Pune`}, `location has an unbalanced quote: "Pune`},
+		{SearchCriteria{Keywords: "(valve OR pump engineer"}, "keywords has unbalanced parentheses: (valve OR pump engineer"},
+		{SearchCriteria{Keywords: "valve,,pump"}, "keywords has an empty alternative: valve,,pump"},
+		{SearchCriteria{Keywords: "valve OR"}, "keywords has an empty alternative: valve OR"},
+		{SearchCriteria{Keywords: "valve intitle:engineer"}, "operator intitle:engineer is inside the keywords phrase, so it is searched as text"},
+		{SearchCriteria{Keywords: "valve", ExcludeKeywords: "intern,,sales"}, "exclude_keywords has an empty term: intern,,sales"},
+		{SearchCriteria{Keywords: "valve engineer", ExcludeKeywords: "engineer"}, `excluded term "engineer" is also searched for, so results matching it are dropped`},
+		{SearchCriteria{}, "no search terms; the query matches any profile"},
+		{SearchCriteria{Keywords: "valve, pump", ExcludeKeywords: "intern"}, ""},
+		{SearchCriteria{CurrentCompany: "Emerson"}, ""},
+	}
+	for _, tt := range tests {
+		got := explainQuery(engineGoogle, tt.c).Warnings
+		if tt.want == "" {
+			if len(got) != 0 {
+				t.Errorf("%+v: warnings %q, want none", tt.c, got)
+			}
+			continue
+		}
+		if len(got) != 1 || got[0] != tt.want {
+			t.Errorf("%+v: warnings %q, want %q", tt.c, got, tt.want)
+		}
+	}
+}
+
+func TestExplainQueryFlag(t *testing.T) {
+	stats = newRunStats()
+	out, err := captureStdout(t, func() error {
+		return runSearchCommand(context.Background(), []string{"-explain-query", "-engine", engineDuckDuckGo, "-keywords", `"valve engineer`, "-location", "Pune"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "  query (duckduckgo): ") || !strings.Contains(out, "    location:        Pune\n") || !strings.Contains(out, "    warning: keywords has an unbalanced quote") {
+		t.Errorf("output %q, want the breakdown and its warning", out)
+	}
+	if pages, _ := stats.yield(); pages != 0 {
+		t.Errorf("%d results pages fetched, want none", pages)
+	}
+}
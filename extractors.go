@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// fieldExtractor fills a user-defined field of Candidate.Extra from the
+// first match of its pattern. A pattern with a capture group yields the
+// group; otherwise the whole match.
+type fieldExtractor struct {
+	name    string
+	pattern *regexp.Regexp
+}
+
+// fieldExtractors are the -extractors in file order. They run over each
+// result snippet and each fetched profile page.
+var fieldExtractors []fieldExtractor
+
+// extractorNamePattern is what a field name must look like, so it works as a
+// CSV column key.
+var extractorNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// loadExtractors reads an -extractors file: a YAML map from field name to
+// regular expression, such as
+//
+//	github: 'https?://(?:www\.)?github\.com/[A-Za-z0-9-]+'
+//
+// Every pattern is compiled here, so a bad one stops the run before it
+// fetches anything.
+func loadExtractors(filename string) ([]fieldExtractor, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read extractors: %w", err)
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse extractors %s: %w", filename, err)
+	}
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+	// Walking the node keeps the file's order, which is the column order.
+	m := doc.Content[0]
+	if m.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("extractors %s: want a map of field names to patterns", filename)
+	}
+	var extractors []fieldExtractor
+	seen := make(map[string]bool)
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		name, expr := strings.ToLower(strings.TrimSpace(m.Content[i].Value)), m.Content[i+1].Value
+		switch {
+		case !extractorNamePattern.MatchString(name):
+			return nil, fmt.Errorf("extractor %q: names are lower-case letters, digits, and underscores", name)
+		case seen[name]:
+			return nil, fmt.Errorf("extractor %q is defined twice", name)
+		}
+		if _, ok := findCSVColumn(name); ok {
+			return nil, fmt.Errorf("extractor %q clashes with the built-in column of that name", name)
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("extractor %q: %w", name, err)
+		}
+		if re.NumSubexp() > 1 {
+			return nil, fmt.Errorf("extractor %q: at most one capture group", name)
+		}
+		seen[name] = true
+		extractors = append(extractors, fieldExtractor{name: name, pattern: re})
+	}
+	return extractors, nil
+}
+
+// extract returns the value the extractor finds in text.
+func (e fieldExtractor) extract(text []byte) string {
+	m := e.pattern.FindSubmatch(text)
+	switch {
+	case m == nil:
+		return ""
+	case len(m) > 1:
+		return strings.TrimSpace(string(m[1]))
+	}
+	return strings.TrimSpace(string(m[0]))
+}
+
+// extractExtra runs the -extractors over text, returning the fields found,
+// or nil when there are none.
+func extractExtra(text []byte) map[string]string {
+	var extra map[string]string
+	for _, e := range fieldExtractors {
+		if v := e.extract(text); v != "" {
+			if extra == nil {
+				extra = make(map[string]string)
+			}
+			extra[e.name] = v
+		}
+	}
+	return extra
+}
+
+// mergeExtra adds the fields of from to into, which takes precedence where
+// both have a field.
+func mergeExtra(into, from map[string]string) map[string]string {
+	if len(from) == 0 {
+		return into
+	}
+	merged := make(map[string]string, len(into)+len(from))
+	for k, v := range from {
+		merged[k] = v
+	}
+	for k, v := range into {
+		merged[k] = v
+	}
+	return merged
+}
+
+// extraColumns returns a CSV column for each -extractors field, written after
+// the selected columns.
+func extraColumns() []csvColumn {
+	columns := make([]csvColumn, len(fieldExtractors))
+	for i, e := range fieldExtractors {
+		name := e.name
+		columns[i] = csvColumn{key: name, header: name, value: func(c Candidate) string { return c.Extra[name] }}
+	}
+	return columns
+}
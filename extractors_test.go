@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// githubExtractor captures the user name of a GitHub profile link.
+const githubExtractor = `github: 'https?://(?:www\.)?github\.com/([A-Za-z0-9-]+)'` + "\n"
+
+// writeExtractors writes an -extractors file and resets fieldExtractors
+// once the test is done, since parseFlags sets them for the process.
+func writeExtractors(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "extractors.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { fieldExtractors = nil })
+	return path
+}
+
+func TestCustomGithubExtractor(t *testing.T) {
+	extractors, err := loadExtractors(writeExtractors(t, githubExtractor+"personal_site: 'https?://[a-z0-9.-]+\\.dev\\b'\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fieldExtractors = extractors
+	if len(extractors) != 2 || extractors[0].name != "github" || extractors[1].name != "personal_site" {
+		t.Fatalf("extractors %+v, want github then personal_site, in file order", extractors)
+	}
+
+	// The capture group is the value; without one the whole match is.
+	snippet := "Valve engineer. Code at https://github.com/jane-doe and https://jane.dev"
+	if got := extractExtra([]byte(snippet)); got["github"] != "jane-doe" || got["personal_site"] != "https://jane.dev" {
+		t.Errorf("extracted %v from the snippet", got)
+	}
+	page := `<a href="https://www.github.com/jdoe-valves">GitHub</a>`
+	if got := extractExtra([]byte(page)); len(got) != 1 || got["github"] != "jdoe-valves" {
+		t.Errorf("extracted %v from the page, want only github", got)
+	}
+	if got := extractExtra([]byte("nothing here")); got != nil {
+		t.Errorf("extracted %v from text without matches", got)
+	}
+
+	// The profile page's value wins over the snippet's.
+	merged := mergeExtra(map[string]string{"github": "jdoe-valves"}, map[string]string{"github": "jane-doe", "personal_site": "https://jane.dev"})
+	if merged["github"] != "jdoe-valves" || merged["personal_site"] != "https://jane.dev" {
+		t.Errorf("merged %v", merged)
+	}
+}
+
+func TestLoadExtractorsErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"bad pattern", "github: 'github\\.com/([a-z'\n"},
+		{"two groups", "github: 'github\\.com/([a-z]+)/([a-z]+)'\n"},
+		{"bad name", "GitHub URL: 'github\\.com'\n"},
+		{"defined twice", "github: 'a'\nGitHub: 'b'\n"},
+		{"built-in column", "email: '[a-z]+@[a-z.]+'\n"},
+		{"not a map", "- github\n"},
+	}
+	for _, tt := range tests {
+		if _, err := loadExtractors(writeExtractors(t, tt.content)); err == nil {
+			t.Errorf("%s: loaded", tt.name)
+		}
+	}
+	// Validated at startup, before anything is fetched.
+	if _, err := parseFlags([]string{"-extractors", writeExtractors(t, tests[0].content)}); err == nil {
+		t.Error("parseFlags accepted a bad pattern")
+	}
+}
+
+func TestExtractorColumns(t *testing.T) {
+	_, addr := startFakeWeb(t, `profiles:
+  - slug: jane-doe
+    name: Jane Doe
+    summary: Code at https://github.com/jane-doe
+  - slug: john-roe
+    name: John Roe
+`)
+	output, err := runFakeSearch(t, addr, "-max-pages", "1", "-extractors", writeExtractors(t, githubExtractor), "-columns", "name,profile_url")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	r := csv.NewReader(file)
+	r.Comment = '#'
+	records, err := r.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 || !slices.Equal(records[0], []string{"Name", "Profile URL", "github"}) {
+		t.Fatalf("CSV %q, want the github column after the selected ones", records)
+	}
+	for _, row := range records[1:] {
+		want := ""
+		if strings.HasSuffix(row[1], "/jane-doe") {
+			want = "jane-doe"
+		}
+		if row[2] != want {
+			t.Errorf("%s: github %q, want %q", row[0], row[2], want)
+		}
+	}
+}
@@ -0,0 +1,486 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"html"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Modes of the fake results pages.
+const (
+	serpNormal  = "normal"  // Results in Google's current markup.
+	serpCaptcha = "captcha" // 429 with an "unusual traffic" page.
+	serpConsent = "consent" // The cookie consent interstitial until it is accepted.
+	serpLayout  = "layout"  // Results in markup the parser does not know.
+)
+
+// Variants of the fake profile pages, by where the About text is.
+const (
+	variantMarkup = "markup" // The summary section.
+	variantJSONLD = "jsonld" // A JSON-LD Person.
+	variantOG     = "og"     // The og:description meta tag.
+)
+
+// How a fake profile shows on the results pages.
+const (
+	fakeResultOrganic = "organic" // A regular result.
+	fakeResultCard    = "card"    // Only a card of the profiles carousel on the first page.
+	fakeResultBoth    = "both"    // A regular result and a card.
+)
+
+// Behaviors of a fake profile when fetched.
+const (
+	behaviorOK       = "ok"
+	behaviorAuthwall = "authwall" // 302 to the login wall.
+	behavior999      = "999"      // LinkedIn's answer to bots.
+	behaviorGone     = "gone"     // 404.
+	behaviorCaptcha  = "captcha"  // 429.
+)
+
+// fakeScenario is a fakeweb scenario file, such as
+//
+//	serp:
+//	  mode: normal
+//	  captcha_after: 3
+//	profiles:
+//	  - slug: jane-doe
+//	    name: Jane Doe
+//	    title: Valve Engineer
+//	    company: Acme Valves
+//	    location: Bangalore
+//	    email: jane@example.com
+//	    variant: jsonld
+//	  - slug: ghost
+//	    behavior: authwall
+//	  - slug: john-roe
+//	    name: John Roe
+//	    result: card
+//	shortlinks:
+//	  /3xYz: https://www.linkedin.com/in/jane-doe
+//
+// Every search matches every profile, in file order. Profiles with result
+// card or both are also shown as cards of a carousel atop the first page.
+// Short links redirect, on every host, from their path to their URL.
+type fakeScenario struct {
+	ResultsPerPage int `yaml:"results_per_page"`
+	SERP           struct {
+		Mode         string `yaml:"mode"`
+		CaptchaAfter int    `yaml:"captcha_after"` // Results pages served before switching to captcha; 0 never.
+		MaxNum       int    `yaml:"max_num"`       // Most results a page serves, whatever num asks for; 0 no cap.
+	} `yaml:"serp"`
+	Profiles   []fakeProfile     `yaml:"profiles"`
+	Robots     string            `yaml:"robots"`     // Served as /robots.txt on every host; none when empty.
+	Shortlinks map[string]string `yaml:"shortlinks"` // Redirect targets by path.
+}
+
+// fakeProfile is one member of a scenario's roster.
+type fakeProfile struct {
+	Slug       string `yaml:"slug"`
+	Name       string `yaml:"name"`
+	Title      string `yaml:"title"`
+	Company    string `yaml:"company"`
+	Location   string `yaml:"location"`
+	Email      string `yaml:"email"`
+	Phone      string `yaml:"phone"`
+	Summary    string `yaml:"summary"`
+	Experience int    `yaml:"experience"` // Years, written into the snippet.
+	Variant    string `yaml:"variant"`
+	Behavior   string `yaml:"behavior"`
+	Result     string `yaml:"result"` // fakeResultOrganic, fakeResultCard, or fakeResultBoth.
+	Host       string `yaml:"host"`   // Host of the profile's URL in results; www.linkedin.com when empty.
+}
+
+// loadFakeScenario reads and checks a scenario file, filling in defaults.
+func loadFakeScenario(filename string) (*fakeScenario, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scenario: %w", err)
+	}
+	var s fakeScenario
+	if err := yaml.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("failed to parse scenario %s: %w", filename, err)
+	}
+	if s.ResultsPerPage <= 0 {
+		s.ResultsPerPage = resultsPerPage
+	}
+	s.SERP.Mode = firstNonEmpty(s.SERP.Mode, serpNormal)
+	if !validSERPMode(s.SERP.Mode) {
+		return nil, fmt.Errorf("scenario %s: unknown serp mode %q", filename, s.SERP.Mode)
+	}
+	seen := make(map[string]bool)
+	for i := range s.Profiles {
+		p := &s.Profiles[i]
+		switch {
+		case p.Slug == "":
+			return nil, fmt.Errorf("scenario %s: profile %d has no slug", filename, i+1)
+		case seen[p.Slug]:
+			return nil, fmt.Errorf("scenario %s: profile %s is listed twice", filename, p.Slug)
+		}
+		seen[p.Slug] = true
+		p.Variant = firstNonEmpty(p.Variant, variantMarkup)
+		p.Behavior = firstNonEmpty(p.Behavior, behaviorOK)
+		p.Result = firstNonEmpty(p.Result, fakeResultOrganic)
+		p.Host = firstNonEmpty(p.Host, "www.linkedin.com")
+		switch p.Variant {
+		case variantMarkup, variantJSONLD, variantOG:
+		default:
+			return nil, fmt.Errorf("scenario %s: profile %s: unknown variant %q", filename, p.Slug, p.Variant)
+		}
+		switch p.Behavior {
+		case behaviorOK, behaviorAuthwall, behavior999, behaviorGone, behaviorCaptcha:
+		default:
+			return nil, fmt.Errorf("scenario %s: profile %s: unknown behavior %q", filename, p.Slug, p.Behavior)
+		}
+		switch p.Result {
+		case fakeResultOrganic, fakeResultCard, fakeResultBoth:
+		default:
+			return nil, fmt.Errorf("scenario %s: profile %s: unknown result %q", filename, p.Slug, p.Result)
+		}
+	}
+	return &s, nil
+}
+
+func validSERPMode(mode string) bool {
+	switch mode {
+	case serpNormal, serpCaptcha, serpConsent, serpLayout:
+		return true
+	}
+	return false
+}
+
+// fakeWeb serves a scenario as a miniature Google and LinkedIn. Requests are
+// told apart by path, so one server stands in for every host.
+type fakeWeb struct {
+	scenario *fakeScenario
+	profiles map[string]*fakeProfile
+
+	mu          sync.Mutex
+	mode        string
+	serpServed  int
+	counts      map[string]int // Requests by path kind, for /_fakeweb/stats.
+	profileHits map[string]int
+}
+
+func newFakeWeb(s *fakeScenario) *fakeWeb {
+	w := &fakeWeb{scenario: s, profiles: make(map[string]*fakeProfile), mode: s.SERP.Mode, counts: make(map[string]int), profileHits: make(map[string]int)}
+	for i := range s.Profiles {
+		w.profiles[s.Profiles[i].Slug] = &s.Profiles[i]
+	}
+	return w
+}
+
+func (f *fakeWeb) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == "/search":
+		f.count("search")
+		f.serveSearch(w, r)
+	case r.URL.Path == "/save":
+		f.count("consent")
+		// Accepting consent sets the cookie Google checks for.
+		http.SetCookie(w, &http.Cookie{Name: "CONSENT", Value: "YES+", Domain: "google.com", Path: "/"})
+		fmt.Fprint(w, "<html><body>Consent saved</body></html>")
+	case strings.HasPrefix(r.URL.Path, "/in/"):
+		f.count("profile")
+		f.serveProfile(w, r)
+	case r.URL.Path == "/robots.txt" && f.scenario.Robots != "":
+		f.count("robots")
+		fmt.Fprint(w, f.scenario.Robots)
+	case f.scenario.Shortlinks[r.URL.Path] != "":
+		f.count("shortlink")
+		http.Redirect(w, r, f.scenario.Shortlinks[r.URL.Path], http.StatusMovedPermanently)
+	case r.URL.Path == "/authwall":
+		f.count("authwall")
+		fmt.Fprint(w, "<html><body>Sign in to view this profile</body></html>")
+	case r.URL.Path == "/_fakeweb/mode":
+		f.serveMode(w, r)
+	case r.URL.Path == "/_fakeweb/stats":
+		f.serveStats(w)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (f *fakeWeb) count(kind string) {
+	f.mu.Lock()
+	f.counts[kind]++
+	f.mu.Unlock()
+}
+
+// serpMode returns the mode of the next results page, switching to captcha
+// once captcha_after pages have been served.
+func (f *fakeWeb) serpMode() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if after := f.scenario.SERP.CaptchaAfter; after > 0 && f.serpServed >= after {
+		f.mode = serpCaptcha
+	}
+	if f.mode != serpCaptcha {
+		f.serpServed++
+	}
+	return f.mode
+}
+
+func (f *fakeWeb) serveSearch(w http.ResponseWriter, r *http.Request) {
+	mode := f.serpMode()
+	switch mode {
+	case serpCaptcha:
+		w.WriteHeader(http.StatusTooManyRequests)
+		fmt.Fprint(w, "<html><body>Our systems have detected unusual traffic from your computer network.</body></html>")
+		return
+	case serpConsent:
+		if c, err := r.Cookie("CONSENT"); err != nil || !strings.HasPrefix(c.Value, "YES") {
+			fmt.Fprint(w, `<html><body><form action="https://consent.google.com/save" method="POST">`+
+				`<input type="hidden" name="set_eom" value="true"><button>Reject all</button></form>`+
+				`<form action="https://consent.google.com/save" method="POST">`+
+				`<input type="hidden" name="set_eom" value="false"><button>Accept all</button></form></body></html>`)
+			return
+		}
+	}
+	q := r.URL.Query()
+	start, _ := strconv.Atoi(q.Get("start"))
+	num, _ := strconv.Atoi(q.Get("num"))
+	if num <= 0 {
+		num = f.scenario.ResultsPerPage
+	}
+	if limit := f.scenario.SERP.MaxNum; limit > 0 {
+		num = min(num, limit)
+	}
+	var roster []fakeProfile
+	var cards strings.Builder
+	for _, p := range f.scenario.Profiles {
+		if p.Result != fakeResultCard {
+			roster = append(roster, p)
+		}
+		if p.Result != fakeResultOrganic && start == 0 {
+			fmt.Fprintf(&cards, `<g-inner-card><a href="https://%s/in/%s"><div role="heading">%s</div><div class="zz3gNc">%s</div></a></g-inner-card>`,
+				html.EscapeString(p.Host), html.EscapeString(p.Slug), html.EscapeString(firstNonEmpty(p.Name, p.Slug)), html.EscapeString(fakeCardHeadline(p)))
+		}
+	}
+	var b strings.Builder
+	b.WriteString("<html><body>")
+	fmt.Fprintf(&b, `<div id="result-stats">About %d results (0.31 seconds)</div>`, len(f.scenario.Profiles))
+	if cards.Len() > 0 {
+		fmt.Fprintf(&b, `<g-scrolling-carousel>%s</g-scrolling-carousel>`, cards.String())
+	}
+	resultClass := "tF2Cxc"
+	if mode == serpLayout {
+		resultClass = "MjjYud-v2"
+	}
+	for i := start; i < start+num && i < len(roster); i++ {
+		p := roster[i]
+		fmt.Fprintf(&b, `<div class="%s"><a href="https://%s/in/%s"><h3>%s</h3></a><div class="VwiC3b yXK7lf MUxGbd yDYNvb lyLwlc lEBKkf">%s</div></div>`,
+			resultClass, html.EscapeString(p.Host), html.EscapeString(p.Slug), html.EscapeString(fakeResultTitle(p)), html.EscapeString(fakeSnippet(p)))
+	}
+	b.WriteString("</body></html>")
+	fmt.Fprint(w, b.String())
+}
+
+// fakeResultTitle is the result title Google shows for a profile.
+func fakeResultTitle(p fakeProfile) string {
+	parts := []string{firstNonEmpty(p.Name, p.Slug)}
+	for _, s := range []string{p.Title, p.Company} {
+		if s != "" {
+			parts = append(parts, s)
+		}
+	}
+	return strings.Join(parts, " - ") + " | LinkedIn"
+}
+
+// fakeCardHeadline is the headline under the name on a profile's card.
+func fakeCardHeadline(p fakeProfile) string {
+	var parts []string
+	for _, s := range []string{p.Title, p.Company, p.Location} {
+		if s != "" {
+			parts = append(parts, s)
+		}
+	}
+	return strings.Join(parts, " · ")
+}
+
+// fakeSnippet is the result snippet of a profile.
+func fakeSnippet(p fakeProfile) string {
+	var parts []string
+	if p.Location != "" {
+		parts = append(parts, p.Location)
+	}
+	if p.Title != "" && p.Company != "" {
+		parts = append(parts, p.Title+" at "+p.Company)
+	}
+	if p.Experience > 0 {
+		parts = append(parts, fmt.Sprintf("%d years of experience", p.Experience))
+	}
+	return strings.Join(parts, " · ")
+}
+
+func (f *fakeWeb) serveProfile(w http.ResponseWriter, r *http.Request) {
+	slug := strings.Trim(strings.TrimPrefix(r.URL.Path, "/in/"), "/")
+	p, ok := f.profiles[slug]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	f.mu.Lock()
+	f.profileHits[slug]++
+	f.mu.Unlock()
+	switch p.Behavior {
+	case behaviorAuthwall:
+		http.Redirect(w, r, "https://www.linkedin.com/authwall?trk=public_profile", http.StatusFound)
+		return
+	case behavior999:
+		w.WriteHeader(999)
+		return
+	case behaviorGone:
+		http.NotFound(w, r)
+		return
+	case behaviorCaptcha:
+		w.WriteHeader(http.StatusTooManyRequests)
+		return
+	}
+	fmt.Fprint(w, fakeProfilePage(*p))
+}
+
+// fakeProfilePage renders a public profile page of p's variant.
+func fakeProfilePage(p fakeProfile) string {
+	esc := html.EscapeString
+	about := p.Summary
+	if p.Email != "" {
+		about = strings.TrimSpace(about + " Contact: " + p.Email)
+	}
+	if p.Phone != "" {
+		about = strings.TrimSpace(about + " Phone: " + p.Phone)
+	}
+	var b strings.Builder
+	b.WriteString("<html><head>")
+	fmt.Fprintf(&b, `<meta property="og:title" content="%s">`, esc(fakeResultTitle(p)))
+	switch p.Variant {
+	case variantOG:
+		fmt.Fprintf(&b, `<meta property="og:description" content="%s">`, esc(about))
+	case variantJSONLD:
+		ld, _ := json.Marshal(map[string]any{"@context": "https://schema.org", "@type": "Person", "name": p.Name, "description": about})
+		fmt.Fprintf(&b, `<script type="application/ld+json">%s</script>`, ld)
+	}
+	b.WriteString("</head><body>")
+	fmt.Fprintf(&b, `<h1 class="top-card-layout__title">%s</h1>`, esc(p.Name))
+	if p.Title != "" {
+		fmt.Fprintf(&b, `<h2 class="top-card-layout__headline">%s at %s</h2>`, esc(p.Title), esc(p.Company))
+	}
+	if p.Location != "" {
+		fmt.Fprintf(&b, `<div class="top-card-layout__first-subline"><span class="top-card__subline-item">%s</span></div>`, esc(p.Location))
+	}
+	if p.Title != "" || p.Company != "" {
+		fmt.Fprintf(&b, `<section class="experience"><ul><li><h3>%s</h3><h4>%s</h4><span class="date-range">Jan 2019 - Present</span></li></ul></section>`, esc(p.Title), esc(p.Company))
+	}
+	if p.Variant == variantMarkup && about != "" {
+		fmt.Fprintf(&b, `<section class="summary"><div class="core-section-container__content">%s</div></section>`, esc(about))
+	}
+	b.WriteString("</body></html>")
+	return b.String()
+}
+
+// serveMode switches the results page mode, so a scenario can turn blocking
+// on mid-run: POST /_fakeweb/mode?serp=captcha.
+func (f *fakeWeb) serveMode(w http.ResponseWriter, r *http.Request) {
+	if mode := r.URL.Query().Get("serp"); mode != "" {
+		if r.Method != http.MethodPost {
+			http.Error(w, "switching modes needs POST", http.StatusMethodNotAllowed)
+			return
+		}
+		if !validSERPMode(mode) {
+			http.Error(w, fmt.Sprintf("unknown serp mode %q", mode), http.StatusBadRequest)
+			return
+		}
+		f.mu.Lock()
+		f.mode = mode
+		f.mu.Unlock()
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	fmt.Fprintln(w, f.mode)
+}
+
+// serveStats reports the requests served so far as JSON.
+func (f *fakeWeb) serveStats(w http.ResponseWriter) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"mode": f.mode, "requests": f.counts, "profiles": f.profileHits})
+}
+
+// fakeWebAddr, when -fake-web sets it, is the address of a fakeweb server
+// that every request goes to in place of the real sites.
+var fakeWebAddr string
+
+// fakeWebTransport sends requests to fakeWebAddr over plain HTTP, keeping
+// the host they were meant for in the Host header.
+type fakeWebTransport struct {
+	addr string
+	next http.RoundTripper
+}
+
+func (t fakeWebTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	out := req.Clone(req.Context())
+	out.Host = req.URL.Host
+	out.URL.Scheme, out.URL.Host = "http", t.addr
+	resp, err := t.next.RoundTrip(out)
+	if resp != nil {
+		resp.Request = req // Callers look at the URL they asked for.
+	}
+	return resp, err
+}
+
+// CloseIdleConnections closes the idle connections of the wrapped transport.
+func (t fakeWebTransport) CloseIdleConnections() {
+	if c, ok := t.next.(interface{ CloseIdleConnections() }); ok {
+		c.CloseIdleConnections()
+	}
+}
+
+// runFakeWebCommand serves a scenario until interrupted.
+func runFakeWebCommand(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("fakeweb", flag.ExitOnError)
+	addr := fs.String("addr", "127.0.0.1:8080", "address to listen on")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: profilesearch fakeweb [-addr host:port] scenario.yaml")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("fakeweb needs a scenario file")
+	}
+	scenario, err := loadFakeScenario(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	listener, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen: %w", err)
+	}
+	srv := &http.Server{Handler: newFakeWeb(scenario), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+	fmt.Printf("Serving %d fake profiles from %s on %s; point profilesearch at it with -fake-web %s\n",
+		len(scenario.Profiles), fs.Arg(0), listener.Addr(), listener.Addr())
+	if err := srv.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	log.Print("fakeweb stopped")
+	return nil
+}
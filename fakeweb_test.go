@@ -0,0 +1,277 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// startFakeWeb serves scenario, the YAML of a fakeweb scenario file, for the
+// rest of the test and returns the server and its address for -fake-web.
+func startFakeWeb(t *testing.T, scenario string) (*fakeWeb, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "scenario.yaml")
+	if err := os.WriteFile(path, []byte(scenario), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := loadFakeScenario(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	web := newFakeWeb(s)
+	srv := httptest.NewServer(web)
+	t.Cleanup(srv.Close)
+	t.Cleanup(func() { fakeWebAddr = "" })
+	return web, srv.Listener.Addr().String()
+}
+
+// requests returns how many requests of the given kinds, such as search or
+// profile, the server has answered, or of every kind when none are given.
+func (f *fakeWeb) requests(kinds ...string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := 0
+	for kind, count := range f.counts {
+		if len(kinds) == 0 || slices.Contains(kinds, kind) {
+			n += count
+		}
+	}
+	return n
+}
+
+// fetchFlags returns the fetcher options of the fetch flags in args.
+func fetchFlags(t *testing.T, args ...string) fetcherOptions {
+	t.Helper()
+	var opts fetcherOptions
+	fs := flag.NewFlagSet("fetch", flag.ContinueOnError)
+	addFetcherFlags(fs, &opts)
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	return opts
+}
+
+// fakeFetcherOptions returns the fetcher options of the fetch flags in args,
+// sending requests to the fakeweb server at addr.
+func fakeFetcherOptions(t *testing.T, addr string, args ...string) fetcherOptions {
+	t.Helper()
+	return fetchFlags(t, append([]string{"-fake-web", addr}, args...)...)
+}
+
+// runFakeSearch runs a search against the fakeweb server at addr with args,
+// writing its output into a temporary directory, and returns the output
+// path and the search error.
+func runFakeSearch(t *testing.T, addr string, args ...string) (string, error) {
+	t.Helper()
+	stats = newRunStats()
+	output := filepath.Join(t.TempDir(), "candidates.csv")
+	args = append([]string{"-fake-web", addr, "-output", output}, args...)
+	return output, runSearchCommand(context.Background(), args)
+}
+
+// readFakeSearch returns the candidates a search wrote to output.
+func readFakeSearch(t *testing.T, output string) []Candidate {
+	t.Helper()
+	candidates, _, err := readCandidatesCSV(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return candidates
+}
+
+// readJSONCandidates returns the candidates a -format json search wrote to
+// output, with the fields the CSV columns leave out.
+func readJSONCandidates(t *testing.T, output string) []Candidate {
+	t.Helper()
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct{ Candidates []Candidate }
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+	return doc.Candidates
+}
+
+// fakeRoster returns a scenario roster of n plain profiles, member-1 to
+// member-n.
+func fakeRoster(n int) string {
+	var b strings.Builder
+	b.WriteString("profiles:\n")
+	for i := 1; i <= n; i++ {
+		fmt.Fprintf(&b, "  - slug: member-%d\n    name: Member %d\n", i, i)
+	}
+	return b.String()
+}
+
+// commandExitCode returns the exit code main gives a command's error.
+func commandExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	return exitCode(err)
+}
+
+// outcomesByURL returns the outcome of the last request of every URL the run
+// fetched.
+func outcomesByURL() map[string]string {
+	outcomes := make(map[string]string)
+	for _, o := range stats.Outcomes {
+		outcomes[o.URL] = o.Outcome
+	}
+	return outcomes
+}
+
+// e2eRoster is a roster of every profile variant and failure, followed by
+// plain members to reach a second results page.
+const e2eRoster = `profiles:
+  - slug: jane-doe
+    name: Jane Doe
+    title: Valve Engineer
+    company: Acme Valves
+    location: Bangalore
+    email: jane@example.com
+    variant: jsonld
+  - slug: john-roe
+    name: John Roe
+    title: Pump Designer
+    company: Flowline
+    variant: og
+  - slug: ravi-kumar
+    name: Ravi Kumar
+    title: Process Engineer
+    company: Kirloskar
+    variant: markup
+  - slug: ghost
+    name: Ghost Member
+    behavior: authwall
+  - slug: bot-wall
+    name: Bot Wall
+    behavior: "999"
+  - slug: left-linkedin
+    name: Left Linkedin
+    behavior: gone
+`
+
+func TestEndToEndHappyPath(t *testing.T) {
+	web, addr := startFakeWeb(t, e2eRoster+strings.TrimPrefix(fakeRoster(6), "profiles:\n"))
+	output, err := runFakeSearch(t, addr, "-keywords", "valve", "-max-pages", "3", "-columns", "name,email,title,company,location,profile_url")
+	if code := commandExitCode(err); code != 0 {
+		t.Fatalf("exit code %d (%v), want 0", code, err)
+	}
+
+	got := readFakeSearch(t, output)
+	wantSlugs := []string{"jane-doe", "john-roe", "ravi-kumar", "ghost", "bot-wall", "left-linkedin",
+		"member-1", "member-2", "member-3", "member-4", "member-5", "member-6"}
+	if len(got) != len(wantSlugs) {
+		t.Fatalf("%d candidates, want %d", len(got), len(wantSlugs))
+	}
+	for i, slug := range wantSlugs {
+		if want := "https://www.linkedin.com/in/" + slug; got[i].ProfileURL != want {
+			t.Errorf("candidate %d is %s, want %s", i+1, got[i].ProfileURL, want)
+		}
+	}
+	// Each profile page variant yields its fields.
+	jane := Candidate{Name: "Jane Doe", Email: "jane@example.com", Title: "Valve Engineer", Company: "Acme Valves", Location: "Bangalore"}
+	john := Candidate{Name: "John Roe", Title: "Pump Designer", Company: "Flowline"}
+	ravi := Candidate{Name: "Ravi Kumar", Title: "Process Engineer", Company: "Kirloskar"}
+	for i, want := range []Candidate{jane, john, ravi} {
+		c := got[i]
+		if c.Name != want.Name || c.Email != want.Email || c.Title != want.Title || c.Company != want.Company || c.Location != want.Location {
+			t.Errorf("candidate %d = %q, %q, %q, %q, %q; want %q, %q, %q, %q, %q", i+1,
+				c.Name, c.Email, c.Title, c.Company, c.Location, want.Name, want.Email, want.Title, want.Company, want.Location)
+		}
+	}
+
+	if n := web.requests("search"); n != 3 {
+		t.Errorf("%d search requests, want 3", n)
+	}
+	if n := web.requests("profile"); n != len(wantSlugs) {
+		t.Errorf("%d profile requests, want %d", n, len(wantSlugs))
+	}
+	if stats.PagesScraped != 3 || stats.CandidatesFound != len(wantSlugs) || stats.ResultTypes[resultTypeOrganic] != len(wantSlugs) {
+		t.Errorf("stats: %d pages, %d candidates, result types %v; want 3, %d, all organic",
+			stats.PagesScraped, stats.CandidatesFound, stats.ResultTypes, len(wantSlugs))
+	}
+	outcomes := outcomesByURL()
+	for slug, want := range map[string]string{"jane-doe": "ok", "ghost": "blocked", "bot-wall": "blocked", "left-linkedin": "status"} {
+		if got := outcomes["https://www.linkedin.com/in/"+slug]; got != want {
+			t.Errorf("outcome of %s = %q, want %q", slug, got, want)
+		}
+	}
+}
+
+func TestEndToEndBlockedMidRun(t *testing.T) {
+	defer func(d time.Duration) { retryDelay = d }(retryDelay)
+	retryDelay = time.Millisecond
+	web, addr := startFakeWeb(t, "serp:\n  captcha_after: 1\n"+fakeRoster(25))
+	output, err := runFakeSearch(t, addr, "-keywords", "valve", "-max-pages", "3")
+	if code := commandExitCode(err); code != 0 {
+		t.Fatalf("exit code %d (%v), want 0", code, err)
+	}
+
+	// The first page's candidates are written; the blocked pages add none.
+	got := readFakeSearch(t, output)
+	if len(got) != resultsPerPage {
+		t.Fatalf("%d candidates, want the %d of the first page", len(got), resultsPerPage)
+	}
+	for i, c := range got {
+		if want := fmt.Sprintf("https://www.linkedin.com/in/member-%d", i+1); c.ProfileURL != want {
+			t.Errorf("candidate %d is %s, want %s", i+1, c.ProfileURL, want)
+		}
+	}
+	if n, want := web.requests("search"), 1+2*retryAttempts; n != want {
+		t.Errorf("%d search requests, want %d: each blocked page is retried", n, want)
+	}
+	if stats.PagesScraped != 1 || stats.CandidatesFound != resultsPerPage {
+		t.Errorf("stats: %d pages, %d candidates; want 1, %d", stats.PagesScraped, stats.CandidatesFound, resultsPerPage)
+	}
+	blocked := 0
+	for _, o := range stats.Outcomes {
+		if o.Outcome == "blocked" {
+			blocked++
+		}
+	}
+	if blocked != 2*retryAttempts {
+		t.Errorf("%d blocked requests, want %d", blocked, 2*retryAttempts)
+	}
+}
+
+func TestEndToEndLayoutChange(t *testing.T) {
+	web, addr := startFakeWeb(t, "serp:\n  mode: layout\n"+fakeRoster(5))
+	output, err := runFakeSearch(t, addr, "-keywords", "valve", "-max-pages", "2")
+	if code := commandExitCode(err); code != 0 {
+		t.Fatalf("exit code %d (%v), want 0", code, err)
+	}
+
+	// Results in unknown markup yield no candidates, so nothing is written
+	// and no profile is fetched.
+	if _, err := os.Stat(output); !os.IsNotExist(err) {
+		t.Errorf("output written for a run without candidates: %v", err)
+	}
+	if n := web.requests("profile"); n != 0 {
+		t.Errorf("%d profile requests, want 0", n)
+	}
+	if stats.PagesScraped != 2 || stats.CandidatesFound != 0 {
+		t.Errorf("stats: %d pages, %d candidates; want 2, 0", stats.PagesScraped, stats.CandidatesFound)
+	}
+}
+
+func TestEndToEndOutputFailureExitCode(t *testing.T) {
+	_, addr := startFakeWeb(t, fakeRoster(2))
+	stats = newRunStats()
+	output := filepath.Join(t.TempDir(), "missing", "candidates.csv")
+	err := runSearchCommand(context.Background(), []string{"-fake-web", addr, "-output", output, "-keywords", "valve", "-max-pages", "1"})
+	if code := commandExitCode(err); code != exitOutputFailure {
+		t.Fatalf("exit code %d (%v), want %d", code, err, exitOutputFailure)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Assumptions of the feasibility estimate when there is no history.
+const (
+	typicalCandidatesPerPage = 8 // LinkedIn profiles among Google's 10 results.
+	// yieldPriorPages is how many pages of history the typical yield is
+	// worth; history outweighs it once more pages than this are recorded.
+	yieldPriorPages = 10
+)
+
+// errInfeasible is returned by -strict-feasibility when the plan exceeds its
+// budget or time window.
+var errInfeasible = errors.New("run is not feasible within its limits")
+
+// yieldHistory is what past runs recorded in the store say about yield.
+type yieldHistory struct {
+	Pages      int // Result pages scraped.
+	Candidates int // Candidates discovered on them.
+}
+
+// feasibility is a pre-run estimate of a run's cost.
+type feasibility struct {
+	SearchRequests    int
+	CandidatesPerPage float64 // Typical yield blended with history.
+	Candidates        int
+	ProfileRequests   int
+	TotalRequests     int
+	WallTime          time.Duration
+
+	OverBudget bool // TotalRequests exceeds -max-requests.
+	OverTime   bool // WallTime exceeds -time-window.
+}
+
+// blendedYield weighs the typical yield against historical yield by how many
+// pages of history there are.
+func blendedYield(hist yieldHistory) float64 {
+	if hist.Pages <= 0 {
+		return typicalCandidatesPerPage
+	}
+	return (typicalCandidatesPerPage*yieldPriorPages + float64(hist.Candidates)) / float64(yieldPriorPages+hist.Pages)
+}
+
+// estimateFeasibility estimates the requests and time a run of searches will
+// take under cfg. It is a worst case in pages: every search is assumed to
+// scrape all pages of every query chunk at every relaxation level. With
+// -single-page, Google is assumed to honor the larger page size.
+func estimateFeasibility(cfg *config, searches int, hist yieldHistory) feasibility {
+	var est feasibility
+	levels := 1 + cfg.maxRelaxation
+	perSearch, requests := 0, 0
+	for _, chunk := range cfg.chunks {
+		perSearch += chunk.Pages
+		if cfg.singlePage {
+			requests += (chunk.Pages*resultsPerPage + singlePageNum - 1) / singlePageNum
+		} else {
+			requests += chunk.Pages
+		}
+	}
+	pages := searches * perSearch * levels
+	est.SearchRequests = searches * requests * levels
+	est.CandidatesPerPage = blendedYield(hist)
+	est.Candidates = int(est.CandidatesPerPage*float64(pages) + 0.5)
+
+	// Every candidate's profile, or every sampled one's, is fetched before
+	// filters run.
+	est.ProfileRequests = est.Candidates
+	if cfg.sampleRate > 0 {
+		est.ProfileRequests = int(cfg.sampleRate*float64(est.Candidates) + 0.5)
+	}
+	if cfg.profileOptions.fetchContactInfo {
+		est.ProfileRequests *= 2
+	}
+	est.TotalRequests = est.SearchRequests + est.ProfileRequests
+
+	meanDelay := (minRequestDelay + maxRequestDelay) / 2
+	est.WallTime = time.Duration(est.TotalRequests) * meanDelay
+	if searches > 1 {
+		est.WallTime += time.Duration(searches-1) * cfg.jobCooldown
+	}
+
+	est.OverBudget = cfg.fetcherOptions.maxRequests > 0 && est.TotalRequests > cfg.fetcherOptions.maxRequests
+	est.OverTime = cfg.timeWindow > 0 && est.WallTime > cfg.timeWindow
+	return est
+}
+
+// print writes the estimate to stdout.
+func (est feasibility) print() {
+	fmt.Printf("Plan: %d search requests, ~%d candidates (%.1f per page), ~%d profile requests, ~%d requests in total, ~%s\n",
+		est.SearchRequests, est.Candidates, est.CandidatesPerPage, est.ProfileRequests, est.TotalRequests, est.WallTime.Round(time.Minute))
+}
+
+// checkFeasibility prints the estimate for a run and warns when it exceeds
+// the budget or time window, or fails under -strict-feasibility.
+func checkFeasibility(cfg *config, searches int) error {
+	var hist yieldHistory
+	if cfg.storePath != "" {
+		store, err := openStore(cfg.storePath)
+		if err != nil {
+			return err
+		}
+		hist = store.yieldHistory()
+	}
+
+	est := estimateFeasibility(cfg, searches, hist)
+	est.print()
+	if est.OverBudget {
+		log.Printf("Warning: the plan needs ~%d requests but -max-requests is %d.", est.TotalRequests, cfg.fetcherOptions.maxRequests)
+	}
+	if est.OverTime {
+		log.Printf("Warning: the plan needs ~%s but -time-window is %s.", est.WallTime.Round(time.Minute), cfg.timeWindow)
+	}
+	if cfg.strictFeasibility && (est.OverBudget || est.OverTime) {
+		return errInfeasible
+	}
+	return nil
+}
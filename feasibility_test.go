@@ -0,0 +1,112 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBlendedYield(t *testing.T) {
+	tests := []struct {
+		hist yieldHistory
+		want float64
+	}{
+		{yieldHistory{}, typicalCandidatesPerPage},
+		// As many pages of history as the prior is worth: halfway.
+		{yieldHistory{Pages: yieldPriorPages, Candidates: 4 * yieldPriorPages}, 6},
+		// Much history outweighs the prior.
+		{yieldHistory{Pages: 90, Candidates: 90}, 1.7},
+	}
+	for _, tt := range tests {
+		if got := blendedYield(tt.hist); got != tt.want {
+			t.Errorf("blendedYield(%+v) = %v, want %v", tt.hist, got, tt.want)
+		}
+	}
+}
+
+func TestEstimateFeasibility(t *testing.T) {
+	cfg := &config{chunks: []queryChunk{{Pages: 3}}}
+	est := estimateFeasibility(cfg, 1, yieldHistory{})
+	want := feasibility{
+		SearchRequests:    3,
+		CandidatesPerPage: typicalCandidatesPerPage,
+		Candidates:        24,
+		ProfileRequests:   24,
+		TotalRequests:     27,
+		WallTime:          27 * (minRequestDelay + maxRequestDelay) / 2,
+	}
+	if est != want {
+		t.Errorf("estimate %+v, want %+v", est, want)
+	}
+
+	// Two jobs at two relaxation levels, sampled, with contact-info requests.
+	cfg = &config{chunks: []queryChunk{{Pages: 3}}, maxRelaxation: 1, sampleRate: 0.5, jobCooldown: time.Minute}
+	cfg.profileOptions.fetchContactInfo = true
+	est = estimateFeasibility(cfg, 2, yieldHistory{Pages: 10, Candidates: 40})
+	if est.SearchRequests != 12 || est.Candidates != 72 || est.ProfileRequests != 72 {
+		t.Errorf("estimate %+v, want 12 searches, 72 candidates at 6 per page, and 72 profile requests", est)
+	}
+	if want := 84*(minRequestDelay+maxRequestDelay)/2 + time.Minute; est.WallTime != want {
+		t.Errorf("wall time %v, want %v with one cooldown", est.WallTime, want)
+	}
+
+	// One page of 100 results stands for ten of 10.
+	cfg = &config{chunks: []queryChunk{{Pages: 10}}, singlePage: true}
+	if est := estimateFeasibility(cfg, 1, yieldHistory{}); est.SearchRequests != 1 || est.Candidates != 80 {
+		t.Errorf("single page estimate %+v, want 1 search for 80 candidates", est)
+	}
+}
+
+func TestFeasibilityThresholds(t *testing.T) {
+	wallTime := 27 * (minRequestDelay + maxRequestDelay) / 2
+	tests := []struct {
+		maxRequests          int
+		timeWindow           time.Duration
+		overBudget, overTime bool
+	}{
+		{0, 0, false, false},
+		{27, wallTime, false, false},
+		{26, 0, true, false},
+		{0, wallTime - time.Second, false, true},
+	}
+	for _, tt := range tests {
+		cfg := &config{chunks: []queryChunk{{Pages: 3}}, timeWindow: tt.timeWindow}
+		cfg.fetcherOptions.maxRequests = tt.maxRequests
+		est := estimateFeasibility(cfg, 1, yieldHistory{})
+		if est.OverBudget != tt.overBudget || est.OverTime != tt.overTime {
+			t.Errorf("-max-requests %d -time-window %v: over budget %v, over time %v; want %v, %v",
+				tt.maxRequests, tt.timeWindow, est.OverBudget, est.OverTime, tt.overBudget, tt.overTime)
+		}
+	}
+}
+
+func TestCheckFeasibilityWarnsOrAborts(t *testing.T) {
+	logged := captureLog(t)
+	cfg := &config{chunks: []queryChunk{{Pages: 3}}}
+	cfg.fetcherOptions.maxRequests = 20
+	if err := checkFeasibility(cfg, 1); err != nil {
+		t.Errorf("over budget without -strict-feasibility: %v", err)
+	}
+	if logged.Len() == 0 {
+		t.Error("over budget without a warning")
+	}
+	cfg.strictFeasibility = true
+	if err := checkFeasibility(cfg, 1); !errors.Is(err, errInfeasible) {
+		t.Errorf("over budget with -strict-feasibility: %v, want errInfeasible", err)
+	}
+
+	// A store whose runs found 1 candidate a page brings the plan in budget.
+	cfg.storePath = filepath.Join(t.TempDir(), "candidates.db")
+	store, err := openStore(cfg.storePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.addRun(storedRun{Pages: 90, Candidates: 90})
+	if err := store.save(); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkFeasibility(cfg, 1); err != nil {
+		t.Errorf("plan of low historical yield: %v, want it feasible", err)
+	}
+}
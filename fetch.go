@@ -0,0 +1,648 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	minRequestDelay        = 5 * time.Second  // Minimum random delay between requests.
+	maxRequestDelay        = 14 * time.Second // Maximum random delay between requests.
+	defaultAuthwallBackoff = 30 * time.Second
+)
+
+// errBlocked is returned when a site answers with a CAPTCHA or rate-limit response.
+var errBlocked = errors.New("captcha or rate limit")
+
+// errAuthwall is returned when LinkedIn redirects to its login wall. It is a
+// block, but one that lasts longer.
+var errAuthwall = fmt.Errorf("authwall: %w", errBlocked)
+
+// Fetcher retrieves the raw body of a page.
+type Fetcher interface {
+	Fetch(ctx context.Context, pageURL string) ([]byte, error)
+}
+
+// statusError reports a non-200 response that is not a block.
+type statusError struct {
+	code int
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("request failed with status: %d", e.code)
+}
+
+// rateLimiter spaces requests by a random delay so that every request in the
+// process, across all searches, respects the same pacing.
+type rateLimiter struct {
+	mu       sync.Mutex
+	minDelay time.Duration
+	maxDelay time.Duration
+	next     time.Time
+	adaptive *adaptiveRate // Replaces the fixed delays when set.
+	floor    time.Duration // Shortest delay, such as a robots.txt crawl delay.
+}
+
+// newRateLimiter returns a limiter that waits between minDelay and maxDelay between requests.
+func newRateLimiter(minDelay, maxDelay time.Duration) *rateLimiter {
+	return &rateLimiter{minDelay: minDelay, maxDelay: maxDelay}
+}
+
+// randomDelay picks a delay in [minDelay, maxDelay], or around the adaptive
+// delay when there is one, and at least the floor.
+func (l *rateLimiter) randomDelay() time.Duration {
+	var d time.Duration
+	switch {
+	case l.adaptive != nil:
+		d = l.adaptive.nextDelay()
+	case l.maxDelay <= l.minDelay:
+		d = l.minDelay
+	default:
+		d = l.minDelay + time.Duration(rand.Int63n(int64(l.maxDelay-l.minDelay)+1))
+	}
+	return max(d, l.floor)
+}
+
+// raiseFloor makes d the shortest delay between requests, unless the floor
+// is already higher.
+func (l *rateLimiter) raiseFloor(d time.Duration) {
+	l.mu.Lock()
+	l.floor = max(l.floor, d)
+	l.mu.Unlock()
+}
+
+// observe reports how a request ended, for the adaptive rate.
+func (l *rateLimiter) observe(outcome string) {
+	if l.adaptive != nil {
+		l.adaptive.observe(outcome)
+	}
+}
+
+// Wait blocks until the next request is allowed or the context is cancelled.
+func (l *rateLimiter) Wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := time.Now()
+	start := l.next
+	if start.Before(now) {
+		start = now
+	}
+	l.next = start.Add(l.randomDelay())
+	l.mu.Unlock()
+
+	// The first request of a run still waits, to mimic human behavior.
+	wait := l.next.Sub(now)
+	return sleepContext(ctx, wait)
+}
+
+// sleepContext sleeps for d or until the context is cancelled.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// httpFetcher fetches pages over HTTP with browser-like headers, optional
+// proxies, and rate limiting. Each request uses the identity of its host class.
+type httpFetcher struct {
+	identities    map[string]*identity
+	acceptConsent bool           // Accept Google's cookie consent page when it appears.
+	robots        *robotsChecker // Nil under -ignore-robots.
+	slowRequest   time.Duration  // Requests taking longer are logged with their timing; 0 never.
+	clients       *clientManager
+	budget        *requestBudget // Every request of the fetcher, nil if unlimited.
+
+	authwallRetry   bool          // Fetch a page again, through another proxy, after an authwall.
+	authwallBackoff time.Duration // Least wait before that; up to twice this.
+	rng             *rand.Rand    // The -seed source, shared with the identities.
+}
+
+// fetcherOptions configure an httpFetcher.
+type fetcherOptions struct {
+	proxyFile     string
+	isolation     string
+	acceptConsent bool
+
+	rateAdaptive     bool
+	adaptiveMinDelay time.Duration
+	adaptiveMaxDelay time.Duration
+
+	learnedLimits bool
+	rateHistory   []rateSample // From the -store, for -learned-limits.
+
+	robots           string
+	robotsFailPolicy string
+	robotsAgent      string
+	ignoreRobots     bool
+
+	slowRequestThreshold time.Duration
+
+	authwallRetry   bool
+	authwallBackoff time.Duration
+
+	maxRequests int // Outbound requests allowed in total; 0 for no limit.
+
+	seed int64 // Seeds the choice of header profiles and proxies, and retry waits; 0 seeds from the clock.
+}
+
+// addFetcherFlags registers the flags that configure fetching.
+func addFetcherFlags(fs *flag.FlagSet, opts *fetcherOptions) {
+	fs.StringVar(&opts.proxyFile, "proxy-file", "", `file of proxy URLs, one per line, optionally prefixed with "search" or "profile" and followed by "rpm=N" to limit a proxy to N requests per minute`)
+	fs.StringVar(&opts.isolation, "identity-isolation", isolationStrict, "strict: separate cookies, headers, proxies, and rate limits for search engines and profile sites; shared: one identity for all")
+	fs.BoolVar(&opts.rateAdaptive, "rate-adaptive", false, "slow down after 429s and speed back up while requests succeed, instead of fixed delays")
+	fs.DurationVar(&opts.adaptiveMinDelay, "rate-adaptive-min-delay", defaultAdaptiveMinDelay, "shortest delay between requests under -rate-adaptive")
+	fs.DurationVar(&opts.adaptiveMaxDelay, "rate-adaptive-max-delay", defaultAdaptiveMaxDelay, "longest delay between requests under -rate-adaptive")
+	fs.BoolVar(&opts.acceptConsent, "accept-consent", false, "accept Google's cookie consent page, shown in the EU, and retry the request in the same session")
+	fs.StringVar(&opts.robots, "robots", robotsProfile, "hosts whose robots.txt is honored: profile for profile sites, all for search engines too")
+	fs.StringVar(&opts.robotsFailPolicy, "robots-fail-policy", robotsFailOpen, "when a host's robots.txt cannot be fetched: open allows its URLs, closed skips them")
+	fs.StringVar(&opts.robotsAgent, "robots-agent", defaultRobotsAgent, "user agent whose robots.txt rules apply, falling back to the * rules")
+	fs.BoolVar(&opts.ignoreRobots, "ignore-robots", false, "do not fetch or honor robots.txt; you take responsibility for the requests made")
+	fs.BoolVar(&opts.authwallRetry, "profile-retry-on-authwall", false, "after an authwall, wait -authwall-backoff and fetch the profile once more through another proxy before giving up")
+	fs.DurationVar(&opts.authwallBackoff, "authwall-backoff", defaultAuthwallBackoff, "least wait before -profile-retry-on-authwall fetches again; up to twice this, at random")
+	fs.DurationVar(&opts.slowRequestThreshold, "slow-request-threshold", 0, "log a warning with the DNS, connect, TLS, first-byte, and body times of every request taking longer than this (0 disables)")
+	fs.StringVar(&fakeWebAddr, "fake-web", "", "send every request to the profilesearch fakeweb server at this host:port instead of the real sites, without delays")
+}
+
+// lockedSource is a rand.Source safe for concurrent use, so that one seeded
+// *rand.Rand can serve every identity of a fetcher.
+type lockedSource struct {
+	mu  sync.Mutex
+	src rand.Source
+}
+
+func (s *lockedSource) Int63() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.src.Int63()
+}
+
+func (s *lockedSource) Seed(seed int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.src.Seed(seed)
+}
+
+// newFetchRand returns the random source of a fetcher's header profile and
+// proxy choices and its retry waits, seeded with seed, or from the clock when seed is 0.
+func newFetchRand(seed int64) *rand.Rand {
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return rand.New(&lockedSource{src: rand.NewSource(seed)})
+}
+
+// newHTTPFetcher builds a fetcher from opts.
+func newHTTPFetcher(opts fetcherOptions) (*httpFetcher, error) {
+	var proxies []proxyEntry
+	if opts.proxyFile != "" {
+		entries, err := loadProxyFile(opts.proxyFile)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			if e.quality == qualityBad {
+				log.Printf("Not using proxy %s, which proxy-bench rated bad.", e.url)
+				continue
+			}
+			proxies = append(proxies, e)
+		}
+	}
+	if opts.isolation == "" {
+		opts.isolation = isolationStrict
+	}
+	if opts.authwallBackoff < 0 {
+		return nil, errors.New("-authwall-backoff must not be negative")
+	}
+	minDelay, maxDelay := minRequestDelay, maxRequestDelay
+	if fakeWebAddr != "" {
+		minDelay, maxDelay = 0, 0 // The fake server has no rate limit to respect.
+		opts.authwallBackoff = 0
+	}
+	rng := newFetchRand(opts.seed)
+	identities, err := newIdentities(opts.isolation, proxies, minDelay, maxDelay, rng)
+	if err != nil {
+		return nil, err
+	}
+	if opts.rateAdaptive {
+		if opts.adaptiveMinDelay <= 0 || opts.adaptiveMaxDelay < opts.adaptiveMinDelay {
+			return nil, errors.New("-rate-adaptive-min-delay must be positive and at most -rate-adaptive-max-delay")
+		}
+		for _, id := range identities {
+			id.limiter.adaptive = newAdaptiveRate((minDelay+maxDelay)/2, opts.adaptiveMinDelay, opts.adaptiveMaxDelay)
+		}
+	}
+	if opts.learnedLimits && fakeWebAddr == "" {
+		learned := make(map[*identity]bool) // Shared isolation maps both classes to one.
+		for _, class := range []string{hostClassSearch, hostClassProfile} {
+			if id := identities[class]; !learned[id] {
+				applyLearnedLimit(id, opts.rateHistory, opts)
+				learned[id] = true
+			}
+		}
+	}
+	robots, err := newRobotsChecker(opts.robots, opts.robotsFailPolicy, opts.robotsAgent, opts.ignoreRobots)
+	if err != nil {
+		return nil, err
+	}
+	return &httpFetcher{identities: identities, acceptConsent: opts.acceptConsent, robots: robots, slowRequest: opts.slowRequestThreshold, clients: newClientManager(),
+		budget: newRequestBudget(opts.maxRequests), authwallRetry: opts.authwallRetry, authwallBackoff: opts.authwallBackoff, rng: rng,
+	}, nil
+}
+
+// close closes the fetcher's idle connections and reports how many
+// connections requests opened and reused.
+func (f *httpFetcher) close() {
+	f.clients.closeIdle()
+	opened, reused := f.clients.connections()
+	verbosef("Connections: %d opened, %d reused", opened, reused)
+}
+
+// sessionResetter is implemented by fetchers that keep per-session state, such
+// as cookies, which can be discarded between jobs.
+type sessionResetter interface {
+	ResetSession()
+}
+
+// ResetSession starts a new session for every identity, on new connections
+// and, with a proxy pool, through another proxy, so that nothing carries the
+// old session over.
+func (f *httpFetcher) ResetSession() {
+	for _, id := range f.identities {
+		id.reset()
+	}
+	f.clients.closeIdle()
+}
+
+// identityFor selects the identity for a request host.
+func (f *httpFetcher) identityFor(host string) *identity {
+	return f.identities[classifyHost(host)]
+}
+
+// Fetch waits for the identity's rate limiter, then requests pageURL and returns its body.
+// A consent page is accepted, with -accept-consent, and the request retried once.
+// An authwall is retried once, with -profile-retry-on-authwall.
+// A URL robots.txt disallows is not requested.
+// Each request made along the way, such as the consent form's submission and
+// the retried request, is charged to the budget.
+func (f *httpFetcher) Fetch(ctx context.Context, pageURL string) ([]byte, error) {
+	if err := f.checkRobots(ctx, pageURL); err != nil {
+		return nil, err
+	}
+	choice := &proxyChoice{}
+	ctx = context.WithValue(ctx, proxyChoiceKey{}, choice)
+	body, consentURL, err := f.get(ctx, pageURL)
+	if errors.Is(err, errAuthwall) && f.authwallRetry {
+		body, consentURL, err = f.retryAuthwall(ctx, pageURL, choice)
+	}
+	if err != nil || consentURL == nil {
+		return body, err
+	}
+	if !f.acceptConsent {
+		return nil, errConsentRequired
+	}
+	log.Printf("Accepting the consent page at %s", consentURL.Host)
+	if err := f.submitConsent(ctx, pageURL, consentURL, body); err != nil {
+		return nil, err
+	}
+	if body, consentURL, err = f.get(ctx, pageURL); err == nil && consentURL != nil {
+		return nil, errConsentRequired
+	}
+	return body, err
+}
+
+// retryAuthwall waits out the backoff and requests pageURL again, through
+// another proxy than the one that met the authwall when the pool has one.
+// The retry is a request like any other, charged to the budget; when the
+// budget is spent, it returns errBudgetExhausted without waiting.
+func (f *httpFetcher) retryAuthwall(ctx context.Context, pageURL string, choice *proxyChoice) ([]byte, *url.URL, error) {
+	if f.budget.exhausted() {
+		return nil, nil, errBudgetExhausted
+	}
+	choice.avoid = choice.used
+	wait := f.authwallWait()
+	verbosef("Authwall on %s through %s; fetching again in %s", pageURL, firstNonEmpty(choice.used, "a direct connection"), wait.Round(time.Second))
+	if err := sleepContext(ctx, wait); err != nil {
+		return nil, nil, err
+	}
+	body, consentURL, err := f.get(ctx, pageURL)
+	stats.countAuthwallRetry(err == nil)
+	return body, consentURL, err
+}
+
+// authwallWait returns the wait before an authwall retry: the backoff plus
+// up to as much again, drawn from the fetcher's seeded source so that a
+// -seed run waits the same.
+func (f *httpFetcher) authwallWait() time.Duration {
+	wait := f.authwallBackoff
+	if wait > 0 {
+		wait += time.Duration(f.rng.Int63n(int64(wait) + 1))
+	}
+	return wait
+}
+
+// proxyChoice, carried by a request's context, records the proxy the
+// request went through and names one to avoid.
+type proxyChoice struct {
+	avoid, used string
+}
+
+type proxyChoiceKey struct{}
+
+// get requests pageURL and returns its body. When the response is a consent
+// page, it also returns the URL the page was served from.
+func (f *httpFetcher) get(ctx context.Context, pageURL string) ([]byte, *url.URL, error) {
+	resp, err := f.do(ctx, "GET", pageURL, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		if resp.StatusCode == http.StatusFound && strings.Contains(resp.Header.Get("Location"), "/authwall") {
+			return nil, nil, errAuthwall
+		}
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusFound {
+			return nil, nil, errBlocked
+		}
+		return nil, nil, &statusError{code: resp.StatusCode}
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	if isConsentPage(resp.Request.URL, body) {
+		return body, resp.Request.URL, nil
+	}
+	return body, nil, nil
+}
+
+// prober is implemented by fetchers that can check a URL without
+// downloading it.
+type prober interface {
+	Probe(ctx context.Context, pageURL string) (int, error)
+}
+
+// Probe makes a HEAD request for pageURL, under the same identity and rate
+// limit as Fetch, and returns the status code.
+func (f *httpFetcher) Probe(ctx context.Context, pageURL string) (int, error) {
+	if err := f.checkRobots(ctx, pageURL); err != nil {
+		return 0, err
+	}
+	resp, err := f.do(ctx, "HEAD", pageURL, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+// noRedirectsKey, in a request's context, stops the client from following
+// the response's redirect.
+type noRedirectsKey struct{}
+
+// redirect makes a HEAD request for pageURL, as Probe does, without following
+// a redirect, and returns where the response redirects to, or nil when it
+// does not redirect.
+func (f *httpFetcher) redirect(ctx context.Context, pageURL string) (*url.URL, error) {
+	if err := f.checkRobots(ctx, pageURL); err != nil {
+		return nil, err
+	}
+	resp, err := f.do(context.WithValue(ctx, noRedirectsKey{}, true), "HEAD", pageURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	loc, err := resp.Location()
+	if errors.Is(err, http.ErrNoLocation) {
+		return nil, nil
+	}
+	return loc, err
+}
+
+// checkRobots returns errRobotsDisallowed, wrapped, when robots.txt rules
+// out requesting pageURL.
+func (f *httpFetcher) checkRobots(ctx context.Context, pageURL string) error {
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return fmt.Errorf("invalid URL: %w", err)
+	}
+	return f.robots.check(ctx, f, u)
+}
+
+// do waits for the rate limiter of pageURL's identity and sends a request
+// with that identity's cookies, headers, and proxies. A non-nil form is sent
+// as a URL-encoded body. Every request is charged to the -max-requests
+// budget, whatever it is made for.
+func (f *httpFetcher) do(ctx context.Context, method, pageURL string, form url.Values) (*http.Response, error) {
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL: %w", err)
+	}
+	if err := f.budget.spend(); err != nil {
+		return nil, err
+	}
+	id := f.identityFor(u.Host)
+	if err := id.limiter.Wait(ctx); err != nil {
+		f.budget.refund()
+		return nil, err
+	}
+
+	choice, _ := ctx.Value(proxyChoiceKey{}).(*proxyChoice)
+	avoid := ""
+	if choice != nil {
+		avoid = choice.avoid
+	}
+	proxy, err := id.proxies.acquire(ctx, avoid)
+	if err != nil {
+		f.budget.refund()
+		return nil, err
+	}
+	if choice != nil {
+		choice.used = proxy
+	}
+	_, profile := id.session()
+	client := f.clients.client(proxy, id)
+
+	var body io.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+	trace := newRequestTrace(proxy != "")
+	hooks := trace.clientTrace()
+	hooks.GotConn = func(info httptrace.GotConnInfo) { f.clients.countConn(info.Reused) }
+	req, err := http.NewRequestWithContext(httptrace.WithClientTrace(ctx, hooks), method, pageURL, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	// Set headers.
+	req.Header = profile.headers()
+	if ref := referer(u); ref != "" {
+		req.Header.Set("Referer", ref)
+	}
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	sent := time.Now()
+	resp, err := client.Do(req)
+	status := 0
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	stats.recordOutcome(pageURL, u.Host, proxy, id.proxies.regionOf(proxy), sent, status, err)
+	id.limiter.observe(classifyOutcome(status, err))
+	finish := func() { stats.recordTiming(pageURL, proxy, trace.finish(), f.slowRequest) }
+	if err != nil {
+		finish()
+		return nil, err
+	}
+	resp.Body = &tracedBody{ReadCloser: resp.Body, done: finish}
+	return resp, nil
+}
+
+// referer returns the Referer a browser would send requesting u: a search
+// engine's page is reached from its home page, and a profile from Google's
+// results. robots.txt is requested without one.
+func referer(u *url.URL) string {
+	switch {
+	case u.Path == "/robots.txt":
+		return ""
+	case classifyHost(u.Host) == hostClassSearch:
+		return u.Scheme + "://" + u.Host + "/"
+	}
+	return "https://www." + string(googleDomain) + "/"
+}
+
+// errBudgetExhausted is returned once -max-requests outbound requests have been made.
+var errBudgetExhausted = errors.New("request budget exhausted")
+
+// requestBudget caps the outbound requests of a run: search pages and
+// profiles, and the robots.txt, consent, and retry requests made for them. A
+// nil requestBudget is unlimited.
+type requestBudget struct {
+	max  int64
+	used atomic.Int64
+}
+
+// newRequestBudget returns a budget of max requests, or nil if max is 0.
+func newRequestBudget(max int) *requestBudget {
+	if max <= 0 {
+		return nil
+	}
+	return &requestBudget{max: int64(max)}
+}
+
+// spend takes a request from the budget, or returns errBudgetExhausted when
+// none is left. It is taken before the rate limiter's wait, so that no
+// request waits for a slot the budget would refuse, and refunded when the
+// request is not sent after all.
+func (b *requestBudget) spend() error {
+	if b != nil && b.used.Add(1) > b.max {
+		return errBudgetExhausted
+	}
+	return nil
+}
+
+// refund returns a request taken by spend that was never sent, such as one
+// cancelled while waiting for the rate limiter.
+func (b *requestBudget) refund() {
+	if b != nil {
+		b.used.Add(-1)
+	}
+}
+
+// exhausted reports whether the budget has no request left.
+func (b *requestBudget) exhausted() bool {
+	return b != nil && b.used.Load() >= b.max
+}
+
+// probe checks pageURL with f's Probe when it has one, or else with a full
+// fetch, and returns the status code.
+func probe(ctx context.Context, f Fetcher, pageURL string) (int, error) {
+	if p, ok := f.(prober); ok {
+		return p.Probe(ctx, pageURL)
+	}
+	_, err := f.Fetch(ctx, pageURL)
+	var se *statusError
+	switch {
+	case err == nil:
+		return http.StatusOK, nil
+	case errors.As(err, &se):
+		return se.code, nil
+	}
+	return 0, err
+}
+
+// stopsRun reports whether err means no further requests should be attempted.
+func stopsRun(err error) bool {
+	return errors.Is(err, errBudgetExhausted) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// profileBreaker stops profile fetches for the rest of the run once too many
+// in a row have been blocked, since continuing only invites a wider ban. A
+// nil breaker never trips.
+type profileBreaker struct {
+	mu      sync.Mutex
+	limit   int
+	blocks  int // Consecutive blocked fetches.
+	tripped bool
+}
+
+// newProfileBreaker returns a breaker tripping after limit consecutive
+// blocks, or nil if limit is 0.
+func newProfileBreaker(limit int) *profileBreaker {
+	if limit <= 0 {
+		return nil
+	}
+	return &profileBreaker{limit: limit}
+}
+
+// open reports whether profile fetches are stopped.
+func (b *profileBreaker) open() bool {
+	if b == nil {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.tripped
+}
+
+// record notes the outcome of a profile fetch.
+func (b *profileBreaker) record(err error) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch {
+	case err == nil:
+		b.blocks = 0
+	case errors.Is(err, errBlocked):
+		b.blocks++
+		if b.blocks >= b.limit && !b.tripped {
+			b.tripped = true
+			log.Printf("%d profile fetches in a row were blocked; skipping profile fetches for the rest of the run (snippet data only).", b.blocks)
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMaxRequestsStopsRunMidway(t *testing.T) {
+	web, addr := startFakeWeb(t, "robots: \"User-agent: *\\nAllow: /\\n\"\n"+fakeRoster(13))
+	output, err := runFakeSearch(t, addr, "-max-pages", "2", "-max-requests", "5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The first results page, LinkedIn's robots.txt, and three profiles.
+	if got := web.requests(); got != 5 {
+		t.Errorf("%d requests made, want 5", got)
+	}
+	if got := web.requests("search"); got != 1 {
+		t.Errorf("%d results pages fetched, want 1", got)
+	}
+	// The page's candidates are written, enriched or not.
+	if got := len(readFakeSearch(t, output)); got != 10 {
+		t.Errorf("%d candidates written, want 10", got)
+	}
+}
+
+// authwallProxies starts two HTTP proxies for profile requests, a good one
+// whose IP LinkedIn walls and a fair one it serves, and returns a proxy file
+// listing them, and the requests each got.
+func authwallProxies(t *testing.T) (proxyFile string, walled, served *atomic.Int64) {
+	t.Helper()
+	walled, served = new(atomic.Int64), new(atomic.Int64)
+	wall := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		walled.Add(1)
+		http.Redirect(w, r, "https://www.linkedin.com/authwall?trk=public_profile", http.StatusFound)
+	}))
+	t.Cleanup(wall.Close)
+	open := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served.Add(1)
+		fmt.Fprint(w, fakeProfilePage(fakeProfile{Name: "Jane Doe", Variant: variantMarkup}))
+	}))
+	t.Cleanup(open.Close)
+	proxyFile = filepath.Join(t.TempDir(), "proxies.txt")
+	list := fmt.Sprintf("search %s\nprofile %s quality=good\nprofile %s quality=fair\n", open.URL, wall.URL, open.URL)
+	if err := os.WriteFile(proxyFile, []byte(list), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return proxyFile, walled, served
+}
+
+// newUnpacedFetcher builds a fetcher from opts that does not wait between
+// requests.
+func newUnpacedFetcher(t *testing.T, opts fetcherOptions) *httpFetcher {
+	t.Helper()
+	f, err := newHTTPFetcher(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range f.identities {
+		id.limiter = newRateLimiter(0, 0)
+	}
+	return f
+}
+
+func TestAuthwallRetrySwitchesProxy(t *testing.T) {
+	proxyFile, walled, served := authwallProxies(t)
+	stats = newRunStats()
+	f := newUnpacedFetcher(t, fetchFlags(t, "-proxy-file", proxyFile, "-ignore-robots", "-profile-retry-on-authwall", "-authwall-backoff", "0"))
+
+	body, err := f.Fetch(context.Background(), "http://www.linkedin.com/in/jane-doe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(body), "Jane Doe") {
+		t.Errorf("body %q is not the profile", body)
+	}
+	if walled.Load() != 1 || served.Load() != 1 {
+		t.Errorf("walled proxy got %d requests and the other %d, want 1 each", walled.Load(), served.Load())
+	}
+	if stats.AuthwallRetries != 1 || stats.AuthwallRecovered != 1 {
+		t.Errorf("stats count %d retries, %d recovered; want 1 and 1", stats.AuthwallRetries, stats.AuthwallRecovered)
+	}
+}
+
+func TestAuthwallRetryStopsAtBudget(t *testing.T) {
+	proxyFile, walled, served := authwallProxies(t)
+	stats = newRunStats()
+	opts := fetchFlags(t, "-proxy-file", proxyFile, "-ignore-robots", "-profile-retry-on-authwall", "-authwall-backoff", "1h")
+	opts.maxRequests = 1
+	f := newUnpacedFetcher(t, opts)
+
+	// The backoff would outlast the test: the retry must not wait for it.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := f.Fetch(ctx, "http://www.linkedin.com/in/jane-doe"); !errors.Is(err, errBudgetExhausted) {
+		t.Fatalf("Fetch = %v, want %v", err, errBudgetExhausted)
+	}
+	if walled.Load() != 1 || served.Load() != 0 {
+		t.Errorf("walled proxy got %d requests and the other %d, want 1 and 0", walled.Load(), served.Load())
+	}
+	if stats.AuthwallRetries != 0 {
+		t.Errorf("stats count %d retries, want 0", stats.AuthwallRetries)
+	}
+}
+
+func TestProfileBreakerTripsAfterConsecutiveBlocks(t *testing.T) {
+	var roster strings.Builder
+	roster.WriteString("profiles:\n")
+	for i := 1; i <= 6; i++ {
+		fmt.Fprintf(&roster, "  - slug: member-%d\n    name: Member %d\n    behavior: captcha\n", i, i)
+	}
+	for _, tc := range []struct {
+		limit, fetched int
+	}{
+		{0, 6},
+		{2, 2},
+		{4, 4},
+	} {
+		web, addr := startFakeWeb(t, roster.String())
+		output, err := runFakeSearch(t, addr, "-no-profile-fetch-on-block", fmt.Sprint(tc.limit))
+		if err != nil {
+			t.Fatalf("limit %d: %v", tc.limit, err)
+		}
+		if got := web.requests("profile"); got != tc.fetched {
+			t.Errorf("limit %d: %d profile requests, want %d", tc.limit, got, tc.fetched)
+		}
+		// The profiles not fetched keep their results' data.
+		if got := len(readFakeSearch(t, output)); got != 6 {
+			t.Errorf("limit %d: %d candidates written, want 6", tc.limit, got)
+		}
+	}
+}
+
+func TestSeedPicksHeaderProfiles(t *testing.T) {
+	profiles := func(seed int64) []string {
+		id := newIdentity(hostClassSearch, nil, 0, 0, newFetchRand(seed))
+		var picked []string
+		for range 8 {
+			_, p := id.session()
+			picked = append(picked, fmt.Sprint(p))
+			id.reset()
+		}
+		return picked
+	}
+	if a, b := profiles(7), profiles(7); !slices.Equal(a, b) {
+		t.Errorf("seed 7 picked %v, then %v", a, b)
+	}
+}
+
+func TestSeedPicksAuthwallWaits(t *testing.T) {
+	waits := func(seed int64) []time.Duration {
+		f := &httpFetcher{authwallBackoff: time.Minute, rng: newFetchRand(seed)}
+		var picked []time.Duration
+		for range 8 {
+			w := f.authwallWait()
+			if w < time.Minute || w > 2*time.Minute {
+				t.Errorf("waited %s, want between the backoff and twice it", w)
+			}
+			picked = append(picked, w)
+		}
+		return picked
+	}
+	if a, b := waits(7), waits(7); !slices.Equal(a, b) {
+		t.Errorf("seed 7 waited %v, then %v", a, b)
+	}
+}
+
+func TestCancelledWaitRefundsBudget(t *testing.T) {
+	identities, err := newIdentities(isolationShared, nil, time.Hour, time.Hour, newFetchRand(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &httpFetcher{identities: identities, clients: newClientManager(), budget: newRequestBudget(1)}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := f.Fetch(ctx, "http://www.example.com/"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Fetch = %v, want the wait cut short", err)
+	}
+	if used := f.budget.used.Load(); used != 0 || f.budget.exhausted() {
+		t.Errorf("%d requests charged for one never sent, want 0", used)
+	}
+}
+
+func TestRefererByHostClass(t *testing.T) {
+	tests := []struct{ url, want string }{
+		{"https://www.google.com/search?q=valve", "https://www.google.com/"},
+		{"https://www.google.de/search?q=valve", "https://www.google.de/"},
+		{"https://consent.google.com/save", "https://consent.google.com/"},
+		{"https://www.bing.com/search?q=valve", "https://www.bing.com/"},
+		{"https://www.linkedin.com/in/jane-doe", "https://www." + string(googleDomain) + "/"},
+		{"https://in.linkedin.com/in/jane-doe", "https://www." + string(googleDomain) + "/"},
+		{"https://www.linkedin.com/robots.txt", ""},
+		{"https://www.google.com/robots.txt", ""},
+	}
+	for _, tt := range tests {
+		u, err := url.Parse(tt.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := referer(u); got != tt.want {
+			t.Errorf("referer(%s) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+
+	var got atomic.Value
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got.Store(r.Header.Get("Referer"))
+	}))
+	defer srv.Close()
+	f := proxiedFetcher(t, srv.URL)
+	for _, tt := range []struct{ path, want string }{
+		{"/in/jane-doe", "https://www." + string(googleDomain) + "/"},
+		{"/robots.txt", ""},
+	} {
+		if _, err := f.Fetch(context.Background(), "http://www.linkedin.com"+tt.path); err != nil {
+			t.Fatal(err)
+		}
+		if got.Load() != tt.want {
+			t.Errorf("%s sent Referer %q, want %q", tt.path, got.Load(), tt.want)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+// Sources of candidate fields, as recorded for -field-sources. Each names the
+// selector or pattern that produced a value, so rarely used ones can be
+// found and pruned.
+const (
+	sourceResultName      = "result_name"        // nameSelector on the Google result.
+	sourceResultTitle     = "result_title"       // The "Name - Title - Company" result title.
+	sourceSnippet         = "snippet"            // Patterns over the Google snippet.
+	sourceProfileMarkup   = "profile_markup"     // The profile's top card selectors.
+	sourceContactInfo     = "contact_info"       // The contact-info overlay.
+	sourceJSONLD          = "json_ld"            // The profile's JSON-LD Person.
+	sourceOGDescription   = "og_description"     // The og:description meta tag.
+	sourceSummarySection  = "summary_section"    // The About section markup.
+	sourceAboutText       = "about_text"         // Patterns over the About text.
+	sourceAboutObfuscated = "about_obfuscated"   // The spelled-out email patterns over the About text.
+	sourcePageRegex       = "page_regex"         // The fallback patterns over the raw page.
+	sourceExperience      = "experience_section" // The profile's experience section.
+	sourcePositionDates   = "position_dates"     // The date ranges of the experience section's positions.
+)
+
+// sourcedFields are the fields whose source is recorded: those more than one
+// extractor can fill, and so those the resolver decides between.
+var sourcedFields = []struct {
+	name  string
+	value func(c Candidate) string
+	set   func(c *Candidate, v string)
+}{
+	{"name", func(c Candidate) string { return c.Name }, func(c *Candidate, v string) { c.Name = v }},
+	{"email", func(c Candidate) string { return c.Email }, func(c *Candidate, v string) { c.Email = v }},
+	{"phone", func(c Candidate) string { return c.Phone }, func(c *Candidate, v string) { c.Phone = v }},
+	{"experience", func(c Candidate) string { return formatExperienceYears(c.experienceYears()) }, func(c *Candidate, v string) { c.setExperienceYears(parseExperienceYears(v)) }},
+	{"title", func(c Candidate) string { return c.Title }, func(c *Candidate, v string) { c.Title = v }},
+	{"company", func(c Candidate) string { return c.Company }, func(c *Candidate, v string) { c.Company = v }},
+	{"location", func(c Candidate) string { return c.Location }, func(c *Candidate, v string) { c.Location = v }},
+	{"summary", func(c Candidate) string { return c.Summary }, func(c *Candidate, v string) { c.Summary = v }},
+	{"website", func(c Candidate) string { return c.Website }, func(c *Candidate, v string) { c.Website = v }},
+	{"twitter", func(c Candidate) string { return c.Twitter }, func(c *Candidate, v string) { c.Twitter = v }},
+	{"company_size_band", func(c Candidate) string { return c.CompanySizeBand }, func(c *Candidate, v string) { c.CompanySizeBand = v }},
+	{"company_type", func(c Candidate) string { return c.CompanyType }, func(c *Candidate, v string) { c.CompanyType = v }},
+}
+
+// fieldSourceRecord is one line of the -field-sources log.
+type fieldSourceRecord struct {
+	ProfileURL string            `json:"profile_url"`
+	Job        string            `json:"job,omitempty"`
+	Decision   string            `json:"decision"`
+	Sources    map[string]string `json:"sources"` // Field to source, for populated fields.
+}
+
+// noteSource records that source filled field, when value is populated.
+func (c *Candidate) noteSource(field, value, source string) {
+	if value == "" {
+		return
+	}
+	if c.Sources == nil {
+		c.Sources = make(map[string]string)
+	}
+	c.Sources[field] = source
+}
+
+// fieldValue returns the value of a sourced field of c.
+func fieldValue(c Candidate, field string) string {
+	for _, f := range sourcedFields {
+		if f.name == field {
+			return f.value(c)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFieldSourcesOfAMixedSourceCandidate(t *testing.T) {
+	profileURL := "https://www.linkedin.com/in/jane-doe"
+	result := snippetCandidate(resultTypeOrganic, profileURL, "Jane D.", "Jane D. - Valve Engineer - Emerson Electric",
+		"Pune, Maharashtra, India · 12 years of experience · jane.d@example.org")
+
+	// The profile has its name in the top card, positions, an og:description
+	// summary, and the contact-info overlay behind a second request.
+	page := `<html><head><meta property="og:description" content="Valves for power plants."></head>` +
+		twoPositions[len("<body>"):]
+	f := &pageFetcher{pages: map[string]string{contactInfoURL(profileURL): contactInfoOverlay}}
+	profile := parseProfilePage(context.Background(), f, profileURL, []byte(page), parseHTML(t, page), profileOptions{fetchContactInfo: true})
+
+	now := clockNow()
+	mergeFields("profile", &result, now, profile, now)
+	want := map[string]string{
+		"name":       sourceProfileMarkup,
+		"email":      sourceContactInfo,
+		"phone":      sourceContactInfo,
+		"experience": sourcePositionDates,
+		"title":      sourceExperience,
+		"company":    sourceExperience,
+		"location":   sourceSnippet,
+		"summary":    sourceOGDescription,
+		"website":    sourceContactInfo,
+		"twitter":    sourceContactInfo,
+	}
+	if !reflect.DeepEqual(result.Sources, want) {
+		t.Errorf("sources %v, want %v", result.Sources, want)
+	}
+	if result.Name != "Jane Doe" || result.Company != "Emerson" || result.Email != "jane@example.com" || result.Location == "" {
+		t.Errorf("merged candidate %+v, want the profile's name, company, and email and the snippet's location", result)
+	}
+}
+
+func TestFieldSourcesLog(t *testing.T) {
+	_, addr := startFakeWeb(t, `profiles:
+  - slug: jane-doe
+    name: Jane Doe
+    title: Valve Engineer
+    company: Emerson
+    location: Pune, Maharashtra, India
+    email: jane@example.com
+    summary: Valves for power plants.
+    variant: jsonld
+`)
+	output, err := runFakeSearch(t, addr, "-max-pages", "1", "-field-sources")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(filepath.Join(filepath.Dir(output), "field-sources.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var records []fieldSourceRecord
+	for sc := bufio.NewScanner(file); sc.Scan(); {
+		var r fieldSourceRecord
+		if err := json.Unmarshal(sc.Bytes(), &r); err != nil {
+			t.Fatalf("bad field sources line %q: %v", sc.Text(), err)
+		}
+		records = append(records, r)
+	}
+	if len(records) != 1 {
+		t.Fatalf("%d field sources records, want 1", len(records))
+	}
+	r := records[0]
+	if r.ProfileURL != "https://www.linkedin.com/in/jane-doe" || r.Decision == "" {
+		t.Errorf("record %+v, want jane-doe with its decision", r)
+	}
+	// The result and the profile agree on the company and location, so
+	// those keep the result's sources.
+	for field, source := range map[string]string{
+		"name":     sourceProfileMarkup,
+		"email":    sourceAboutText,
+		"title":    sourceExperience,
+		"company":  sourceResultTitle,
+		"location": sourceSnippet,
+		"summary":  sourceJSONLD,
+	} {
+		if r.Sources[field] != source {
+			t.Errorf("%s from %q, want %s", field, r.Sources[field], source)
+		}
+	}
+}
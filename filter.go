@@ -0,0 +1,335 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Final decisions recorded for each candidate.
+const (
+	decisionKeep = "keep"
+	decisionDrop = "drop"
+)
+
+// filterDecision records the outcome of one filter for one candidate, with the
+// values that were compared.
+type filterDecision struct {
+	Filter   string `json:"filter"`
+	Passed   bool   `json:"passed"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+}
+
+// scoreContribution is the number of points one signal added to a score.
+type scoreContribution struct {
+	Signal string `json:"signal"`
+	Detail string `json:"detail,omitempty"`
+	Points int    `json:"points"`
+}
+
+// scoreBreakdown is a score together with the contributions that sum to it.
+type scoreBreakdown struct {
+	Contributions []scoreContribution `json:"contributions"`
+	Total         int                 `json:"total"`
+}
+
+// add records a contribution and adds it to the total.
+func (b *scoreBreakdown) add(signal, detail string, points int) {
+	b.Contributions = append(b.Contributions, scoreContribution{Signal: signal, Detail: detail, Points: points})
+	b.Total += points
+}
+
+// candidateDecision is the full trace of why a candidate was kept or dropped.
+type candidateDecision struct {
+	ProfileURL string           `json:"profile_url"`
+	Name       string           `json:"name"`
+	Job        string           `json:"job,omitempty"`
+	Filters    []filterDecision `json:"filters"`
+	Score      scoreBreakdown   `json:"score"`
+	Decision   string           `json:"decision"`
+}
+
+// scoreWeights are the points awarded per scoring signal.
+type scoreWeights struct {
+	MatchedTerm  int
+	Email        int
+	Phone        int
+	PastEmployer int // Usually negative: applied when -current-company matched only historically.
+	Relaxation   int // Usually negative: applied per -min-results relaxation level.
+	Completeness int // Awarded in full to a 100% complete profile, pro rata below.
+}
+
+// defaultScoreWeights are used unless -score-weights overrides them.
+var defaultScoreWeights = scoreWeights{MatchedTerm: 10, Email: 5, Phone: 3, PastEmployer: -10, Relaxation: -5, Completeness: 10}
+
+// parseScoreWeights parses "matched_term=10,email=5,phone=3", starting from the
+// defaults so that only the named weights change.
+func parseScoreWeights(spec string) (scoreWeights, error) {
+	w := defaultScoreWeights
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return w, fmt.Errorf("weight %q is not key=value", pair)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			return w, fmt.Errorf("weight %q: %w", pair, err)
+		}
+		switch strings.TrimSpace(key) {
+		case "matched_term":
+			w.MatchedTerm = n
+		case "email":
+			w.Email = n
+		case "phone":
+			w.Phone = n
+		case "past_employer":
+			w.PastEmployer = n
+		case "relaxation":
+			w.Relaxation = n
+		case "completeness":
+			w.Completeness = n
+		default:
+			return w, fmt.Errorf("unknown weight %q", key)
+		}
+	}
+	return w, nil
+}
+
+// scoreCandidate ranks a candidate by how well it matches the search and how
+// reachable it is.
+func scoreCandidate(c Candidate, w scoreWeights) scoreBreakdown {
+	var b scoreBreakdown
+	for _, term := range c.MatchedTerms {
+		b.add("matched_term", term, w.MatchedTerm)
+	}
+	if c.Email != "" {
+		b.add("email", c.Email, w.Email)
+	}
+	if c.Phone != "" {
+		b.add("phone", c.Phone, w.Phone)
+	}
+	if c.EmploymentMatch == employmentPast {
+		b.add("past_employer", "", w.PastEmployer)
+	}
+	if c.ProfileCompleteness > 0 {
+		b.add("completeness", fmt.Sprintf("%d%%", c.ProfileCompleteness), c.ProfileCompleteness*w.Completeness/100)
+	}
+	if c.RelaxationLevel > 0 {
+		b.add("relaxation", fmt.Sprintf("level %d", c.RelaxationLevel), c.RelaxationLevel*w.Relaxation)
+	}
+	return b
+}
+
+var experienceRangeRegex = regexp.MustCompile(`(\d+)\s*(?:-|to)\s*(\d+)`)
+
+// parseExperienceRange parses a range such as "7-12 years".
+func parseExperienceRange(s string) (min, max int, ok bool) {
+	m := experienceRangeRegex.FindStringSubmatch(s)
+	if m == nil {
+		return 0, 0, false
+	}
+	min, _ = strconv.Atoi(m[1])
+	max, _ = strconv.Atoi(m[2])
+	if min > max {
+		min, max = max, min
+	}
+	return min, max, true
+}
+
+// candidateFilter decides whether a candidate passes one rule. Scores are
+// computed before filters run, so filters may use c.Score.
+type candidateFilter func(c Candidate) filterDecision
+
+// buildFilters returns the filters enabled by the config for a search.
+func buildFilters(cfg *config, criteria SearchCriteria) []candidateFilter {
+	var filters []candidateFilter
+	if cfg.filterExperience {
+		if min, max, ok := parseExperienceRange(criteria.ExperienceRange); ok {
+			filters = append(filters, experienceFilter(min, max, cfg.experienceTolerance))
+		}
+	}
+	if cfg.minCompanySize != "" || cfg.maxCompanySize != "" {
+		filters = append(filters, companySizeFilter(cfg.minCompanySize, cfg.maxCompanySize))
+	}
+	if cfg.requireLocation && criteria.Location != "" {
+		filters = append(filters, locationFilter(criteria.Location))
+	}
+	if len(cfg.profileLanguages) > 0 {
+		filters = append(filters, languageFilter(cfg.profileLanguages, cfg.strictLanguage))
+	}
+	// Constructs the search engine could not express are applied here.
+	filters = append(filters, renderQuery(cfg.engine, criteria).Filters...)
+	if cfg.minCompleteness > 0 {
+		min := cfg.minCompleteness
+		filters = append(filters, func(c Candidate) filterDecision {
+			return filterDecision{
+				Filter:   "min_completeness",
+				Passed:   c.ProfileCompleteness >= min,
+				Expected: fmt.Sprintf(">= %d", min),
+				Actual:   strconv.Itoa(c.ProfileCompleteness),
+			}
+		})
+	}
+	if cfg.blockedNames.size() > 0 {
+		filters = append(filters, cfg.blockedNames.filter())
+	}
+	if cfg.dropSlugMismatch {
+		filters = append(filters, func(c Candidate) filterDecision {
+			return filterDecision{Filter: "name_slug", Passed: !c.NameSlugMismatch, Expected: "name matching the profile URL", Actual: profileSlug(c.ProfileURL)}
+		})
+	}
+	if cfg.dropContacted {
+		filters = append(filters, contactedFilter)
+	}
+	if cfg.optOuts != nil {
+		filters = append(filters, cfg.optOuts.filter())
+	}
+	if cfg.requireEmail {
+		filters = append(filters, func(c Candidate) filterDecision {
+			return filterDecision{Filter: "require_email", Passed: c.Email != "", Expected: "email present", Actual: c.Email}
+		})
+	}
+	if cfg.minScore > 0 {
+		min := cfg.minScore
+		filters = append(filters, func(c Candidate) filterDecision {
+			return filterDecision{
+				Filter:   "min_score",
+				Passed:   c.Score >= min,
+				Expected: fmt.Sprintf(">= %d", min),
+				Actual:   strconv.Itoa(c.Score),
+			}
+		})
+	}
+	return filters
+}
+
+// experienceFilter drops candidates whose parsed experience falls outside
+// [min-tolerance, max+tolerance]. The tolerance absorbs rounding differences
+// between the query's range and what a snippet states. Candidates without a
+// parsed experience pass.
+func experienceFilter(min, max, tolerance int) candidateFilter {
+	expected := fmt.Sprintf("%d-%d years", min, max)
+	if tolerance > 0 {
+		expected += fmt.Sprintf(" (±%d)", tolerance)
+	}
+	return func(c Candidate) filterDecision {
+		years := c.experienceYears()
+		d := filterDecision{
+			Filter:   "experience",
+			Expected: expected,
+			Actual:   formatExperienceYears(years),
+		}
+		d.Passed = years == 0 || (years >= float64(min-tolerance) && years <= float64(max+tolerance))
+		if years == 0 {
+			d.Actual = "unknown"
+		}
+		return d
+	}
+}
+
+// evaluateCandidate scores a candidate and runs every filter over it. The
+// returned decision is what actually determines whether it is kept.
+func evaluateCandidate(c *Candidate, weights scoreWeights, filters []candidateFilter) candidateDecision {
+	d := candidateDecision{ProfileURL: c.ProfileURL, Name: c.Name, Job: c.Job, Decision: decisionKeep}
+	d.Score = scoreCandidate(*c, weights)
+	c.Score = d.Score.Total
+	for _, filter := range filters {
+		fd := filter(*c)
+		d.Filters = append(d.Filters, fd)
+		if !fd.Passed {
+			d.Decision = decisionDrop
+		}
+	}
+	return d
+}
+
+// finalizeCandidates normalizes, scores, and filters candidates, returning those kept. Every
+// decision is written to the explain log, and every candidate's field sources
+// to the -field-sources log, when those are configured.
+func finalizeCandidates(cfg *config, criteria SearchCriteria, candidates []Candidate) []Candidate {
+	filters := buildFilters(cfg, criteria)
+	kept := candidates[:0]
+	for _, c := range candidates {
+		if c.Phone != "" && cfg.phoneCountries != nil {
+			if country, ok := phoneCountry(c.Phone, cfg.phoneRegion); !ok || !cfg.phoneCountries[country] {
+				verbosef("Dropping phone %q of %s, which is not of a -phone-countries country", c.Phone, c.ProfileURL)
+				stats.countPhoneRejection("country")
+				c.Phone = ""
+				delete(c.Sources, "phone")
+			}
+		}
+		c.Phone = formatPhone(c.Phone, cfg.phoneFormat, cfg.phoneRegion)
+		c.MatchedContactedBy = cfg.contacted.match(c)
+		c.OptOutURL = cfg.optOut.link(c)
+		if cfg.guessEmails && c.Email == "" {
+			c.EmailGuess = guessEmail(c, cfg.companyDomains)
+		}
+		c.ProfileCompleteness = profileCompleteness(c, cfg.completenessWeights)
+		loc := parseLocation(c.Location)
+		c.City, c.State, c.Country = loc.City, loc.State, loc.Country
+		c.ProfileLanguage = profileLanguage(c)
+		if c.NameSlugMismatch = nameSlugMismatch(c); c.NameSlugMismatch {
+			verbosef("Name %q does not match the slug of %s", resultName(c), c.ProfileURL)
+		}
+		if criteria.CurrentCompany != "" {
+			c.EmploymentMatch = classifyEmployment(c, criteria.CurrentCompany)
+		}
+		d := evaluateCandidate(&c, cfg.scoreWeights, filters)
+		if cfg.explain != nil {
+			cfg.explain.record(d)
+		}
+		if cfg.fieldSources != nil {
+			cfg.fieldSources.record(fieldSourceRecord{ProfileURL: c.ProfileURL, Job: c.Job, Decision: d.Decision, Sources: c.Sources})
+		}
+		if d.Decision == decisionKeep {
+			kept = append(kept, c)
+		}
+	}
+	return kept
+}
+
+// jsonLinesLog writes per-candidate debug records, such as the -explain
+// decisions, as JSON lines.
+type jsonLinesLog struct {
+	name string // What the file holds, for errors, e.g. "explain".
+	mu   sync.Mutex
+	file *os.File
+	enc  *json.Encoder
+	err  error
+}
+
+// openJSONLinesLog creates the file of the log called name.
+func openJSONLinesLog(filename, name string) (*jsonLinesLog, error) {
+	file, err := os.Create(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s file: %w", name, err)
+	}
+	return &jsonLinesLog{name: name, file: file, enc: json.NewEncoder(file)}, nil
+}
+
+// record appends one record. The first write error is kept and reported by Close.
+func (l *jsonLinesLog) record(v any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.err == nil {
+		l.err = l.enc.Encode(v)
+	}
+}
+
+// Close closes the file and reports any write error.
+func (l *jsonLinesLog) Close() error {
+	closeErr := l.file.Close()
+	if l.err != nil {
+		return fmt.Errorf("failed to write %s file: %w", l.name, l.err)
+	}
+	return closeErr
+}
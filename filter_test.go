@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseScoreWeights(t *testing.T) {
+	w, err := parseScoreWeights("email=20, past_employer=-3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := defaultScoreWeights
+	want.Email, want.PastEmployer = 20, -3
+	if w != want {
+		t.Errorf("weights %+v, want %+v", w, want)
+	}
+	if w, err := parseScoreWeights(""); err != nil || w != defaultScoreWeights {
+		t.Errorf("empty spec = %+v, %v; want the defaults", w, err)
+	}
+
+	for spec, want := range map[string]string{
+		"email":        "is not key=value",
+		"email=lots":   `weight "email=lots"`,
+		"seniority=10": `unknown weight "seniority"`,
+	} {
+		if _, err := parseScoreWeights(spec); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("parseScoreWeights(%q) error = %v, want %q", spec, err, want)
+		}
+	}
+}
+
+func TestScoreCandidateBreakdown(t *testing.T) {
+	c := Candidate{
+		MatchedTerms:        []string{"valve", "engineer"},
+		Email:               "jane@example.com",
+		EmploymentMatch:     employmentPast,
+		ProfileCompleteness: 50,
+		RelaxationLevel:     2,
+	}
+	b := scoreCandidate(c, defaultScoreWeights)
+	// 2 terms of 10, an email of 5, a past employer of -10, half of 10 for
+	// completeness, and two relaxation levels of -5.
+	if b.Total != 20+5-10+5-10 {
+		t.Errorf("total %d, want 10", b.Total)
+	}
+	sum := 0
+	signals := make(map[string]int)
+	for _, contribution := range b.Contributions {
+		sum += contribution.Points
+		signals[contribution.Signal]++
+	}
+	if sum != b.Total {
+		t.Errorf("contributions sum to %d, total is %d", sum, b.Total)
+	}
+	if signals["matched_term"] != 2 || signals["phone"] != 0 || len(signals) != 5 {
+		t.Errorf("signals %v, want one contribution per term and none for the missing phone", signals)
+	}
+}
+
+func TestEvaluateCandidateDropsOnAnyFilter(t *testing.T) {
+	pass := func(Candidate) filterDecision { return filterDecision{Filter: "pass", Passed: true} }
+	minScore := func(c Candidate) filterDecision { return filterDecision{Filter: "min_score", Passed: c.Score >= 10} }
+
+	c := Candidate{ProfileURL: "https://www.linkedin.com/in/jane-doe", Email: "jane@example.com"}
+	d := evaluateCandidate(&c, defaultScoreWeights, []candidateFilter{pass, minScore})
+	if c.Score != 5 || d.Score.Total != 5 {
+		t.Errorf("score %d, decision score %d; want 5 for the email", c.Score, d.Score.Total)
+	}
+	if d.Decision != decisionDrop || len(d.Filters) != 2 || d.Filters[1].Passed {
+		t.Errorf("decision %+v, want a drop by min_score after both filters ran", d)
+	}
+
+	c.MatchedTerms = []string{"valve"}
+	if d := evaluateCandidate(&c, defaultScoreWeights, []candidateFilter{pass, minScore}); d.Decision != decisionKeep {
+		t.Errorf("decision %+v, want keep once the score reaches 10", d)
+	}
+}
+
+func TestExplainLogsEveryDecision(t *testing.T) {
+	_, addr := startFakeWeb(t, `profiles:
+  - slug: jane-doe
+    name: Jane Doe
+    email: jane@example.com
+  - slug: john-roe
+    name: John Roe
+`)
+	output, err := runFakeSearch(t, addr, "-max-pages", "1", "-explain", "-require-email")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if candidates := readFakeSearch(t, output); len(candidates) != 1 || candidates[0].Name != "Jane Doe" {
+		t.Errorf("kept %+v, want only Jane Doe", candidates)
+	}
+
+	file, err := os.Open(filepath.Join(filepath.Dir(output), "explain.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	decisions := make(map[string]candidateDecision)
+	for sc := bufio.NewScanner(file); sc.Scan(); {
+		var d candidateDecision
+		if err := json.Unmarshal(sc.Bytes(), &d); err != nil {
+			t.Fatalf("bad explain line %q: %v", sc.Text(), err)
+		}
+		decisions[d.Name] = d
+	}
+	if len(decisions) != 2 {
+		t.Fatalf("explain log has decisions for %d candidates, want 2", len(decisions))
+	}
+	if d := decisions["Jane Doe"]; d.Decision != decisionKeep || d.Score.Total < defaultScoreWeights.Email {
+		t.Errorf("Jane Doe: %+v, want keep with the email scored", d)
+	}
+	d := decisions["John Roe"]
+	if d.Decision != decisionDrop {
+		t.Errorf("John Roe: %+v, want drop", d)
+	}
+	for _, f := range d.Filters {
+		if f.Filter == "require_email" && f.Passed {
+			t.Errorf("John Roe passed require_email: %+v", f)
+		}
+	}
+}
+
+func TestParseExperienceRange(t *testing.T) {
+	tests := []struct {
+		s        string
+		min, max int
+		ok       bool
+	}{
+		{"7-12 years", 7, 12, true},
+		{"7 to 12", 7, 12, true},
+		{"12-7", 7, 12, true},
+		{"10+ years", 0, 0, false},
+	}
+	for _, tt := range tests {
+		min, max, ok := parseExperienceRange(tt.s)
+		if min != tt.min || max != tt.max || ok != tt.ok {
+			t.Errorf("parseExperienceRange(%q) = %d, %d, %v; want %d, %d, %v", tt.s, min, max, ok, tt.min, tt.max, tt.ok)
+		}
+	}
+}
+
+func TestExperienceFilterTolerance(t *testing.T) {
+	tests := []struct {
+		years     float64
+		tolerance int
+		passed    bool
+	}{
+		{7, 0, true},
+		{12, 0, true},
+		{6, 0, false},
+		{12.5, 0, false},
+		{6, 1, true},
+		{13, 1, true},
+		{5.9, 1, false},
+		{13.1, 1, false},
+		{0, 0, true}, // Unknown experience passes.
+	}
+	for _, tt := range tests {
+		d := experienceFilter(7, 12, tt.tolerance)(Candidate{ExperienceYears: tt.years})
+		if d.Passed != tt.passed {
+			t.Errorf("%v years with tolerance %d: passed %v, want %v (%+v)", tt.years, tt.tolerance, d.Passed, tt.passed, d)
+		}
+	}
+	if d := experienceFilter(7, 12, 1)(Candidate{}); d.Expected != "7-12 years (±1)" || d.Actual != "unknown" {
+		t.Errorf("decision %+v, want the tolerance in Expected and unknown experience", d)
+	}
+	if _, err := parseFlags([]string{"-keywords", "valve", "-experience-tolerance", "-1"}); err == nil {
+		t.Error("a negative -experience-tolerance was accepted")
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// defaultGoogleDomain is the Google domain searched without -google-domain.
+const defaultGoogleDomain = "google.com"
+
+// googleDomainPattern matches the Google domains: google.com, google.de,
+// google.co.in, google.com.au, and the like.
+var googleDomainPattern = regexp.MustCompile(`^google(?:\.(?:co|com))?\.[a-z]{2,3}$`)
+
+// googleDomainFlag is a -google-domain value. Set accepts a bare domain or
+// one written with www. or a scheme, and rejects hosts that are not Google.
+type googleDomainFlag string
+
+// googleDomain is the Google domain every search goes to.
+var googleDomain = googleDomainFlag(defaultGoogleDomain)
+
+func (d *googleDomainFlag) String() string { return string(*d) }
+
+func (d *googleDomainFlag) Set(s string) error {
+	domain := strings.ToLower(strings.TrimSpace(s))
+	domain = strings.TrimPrefix(strings.TrimPrefix(domain, "https://"), "http://")
+	domain = strings.TrimPrefix(strings.TrimSuffix(domain, "/"), "www.")
+	if !googleDomainPattern.MatchString(domain) {
+		return fmt.Errorf("%q is not a Google domain such as google.co.in", s)
+	}
+	*d = googleDomainFlag(domain)
+	return nil
+}
+
+// googleSearchURL returns the search endpoint on googleDomain.
+func googleSearchURL() string {
+	return "https://www." + string(googleDomain) + "/search"
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGoogleDomainFlag(t *testing.T) {
+	for in, want := range map[string]string{
+		"google.co.in":               "google.co.in",
+		"www.google.de":              "google.de",
+		"https://www.google.com.au/": "google.com.au",
+		" Google.FR ":                "google.fr",
+	} {
+		var d googleDomainFlag
+		if err := d.Set(in); err != nil || string(d) != want {
+			t.Errorf("Set(%q) = %q, %v; want %q", in, d, err, want)
+		}
+	}
+	for _, in := range []string{"bing.com", "google.evil.com", "notgoogle.com", "google", "google.co.in.example.com", ""} {
+		var d googleDomainFlag
+		if err := d.Set(in); err == nil {
+			t.Errorf("Set(%q) accepted %q", in, d)
+		}
+	}
+}
+
+func TestGoogleDomainInSearchURL(t *testing.T) {
+	t.Cleanup(func() { googleDomain = defaultGoogleDomain })
+	if u := buildGoogleSearchURL(SearchCriteria{Keywords: "valve"}); !strings.HasPrefix(u, "https://www.google.com/search?") {
+		t.Errorf("default search URL %s, want google.com", u)
+	}
+
+	if _, err := parseFlags([]string{"-google-domain", "google.co.in"}); err != nil {
+		t.Fatal(err)
+	}
+	if u := buildGoogleSearchURL(SearchCriteria{Keywords: "valve"}); !strings.HasPrefix(u, "https://www.google.co.in/search?q=") {
+		t.Errorf("search URL %s, want google.co.in", u)
+	}
+	if u := buildLookupURL(LookupRequest{Name: "Jane Doe"}); !strings.HasPrefix(u, "https://www.google.co.in/search?q=") {
+		t.Errorf("lookup URL %s, want google.co.in", u)
+	}
+}
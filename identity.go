@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Host classes. Each class gets its own identity under strict isolation, so
+// nothing observed by a search engine can link to activity on profile sites.
+const (
+	hostClassSearch  = "search"
+	hostClassProfile = "profile"
+)
+
+// Values of -identity-isolation.
+const (
+	isolationStrict = "strict" // Separate identities per host class.
+	isolationShared = "shared" // One identity for every host.
+)
+
+// searchEngineHosts are the host suffixes classified as search engines.
+var searchEngineHosts = []string{"google.", "bing.com", "duckduckgo.com", "googleapis.com"}
+
+// classifyHost returns the host class of a request host.
+func classifyHost(host string) string {
+	host = strings.ToLower(host)
+	for _, h := range searchEngineHosts {
+		if strings.Contains(host, h) {
+			return hostClassSearch
+		}
+	}
+	return hostClassProfile
+}
+
+// headerProfile is a coherent set of browser headers; mixing a Firefox
+// User-Agent with Chrome's Accept header is an easy bot signal.
+type headerProfile struct {
+	userAgent      string
+	accept         string
+	acceptLanguage string
+}
+
+var headerProfiles = []headerProfile{
+	{
+		userAgent:      "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36",
+		accept:         "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,*/*;q=0.8",
+		acceptLanguage: "en-US,en;q=0.9",
+	},
+	{
+		userAgent:      "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/15.0 Safari/605.1.15",
+		accept:         "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8",
+		acceptLanguage: "en-US,en;q=0.9",
+	},
+	{
+		userAgent:      "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:89.0) Gecko/20100101 Firefox/89.0",
+		accept:         "text/html,application/xhtml+xml,application/xml;q=0.9,image/webp,*/*;q=0.8",
+		acceptLanguage: "en-US,en;q=0.5",
+	},
+}
+
+// headers returns the profile as request headers.
+func (p headerProfile) headers() http.Header {
+	h := http.Header{}
+	h.Set("User-Agent", p.userAgent)
+	h.Set("Accept", p.accept)
+	h.Set("Accept-Language", p.acceptLanguage)
+	return h
+}
+
+// identity is everything a site can use to recognize a client: cookies,
+// headers, exit IPs, and request timing.
+type identity struct {
+	class   string
+	proxies *proxyPool
+	limiter *rateLimiter
+	rng     *rand.Rand // Picks header profiles.
+
+	mu      sync.Mutex
+	jar     http.CookieJar
+	profile headerProfile
+}
+
+// newIdentity builds an identity with a fresh session, choosing header
+// profiles and proxies with rng.
+func newIdentity(class string, proxies []proxyEntry, minDelay, maxDelay time.Duration, rng *rand.Rand) *identity {
+	id := &identity{class: class, proxies: newProxyPool(proxies, rng), limiter: newRateLimiter(minDelay, maxDelay), rng: rng}
+	id.reset()
+	return id
+}
+
+// reset starts a new session: empty cookie jar and a newly chosen header
+// profile. The pool's next request goes through another proxy than the last.
+func (id *identity) reset() {
+	jar, _ := cookiejar.New(nil) // Never fails with nil options.
+	id.mu.Lock()
+	id.jar = jar
+	id.profile = headerProfiles[id.rng.Intn(len(headerProfiles))]
+	id.mu.Unlock()
+	id.proxies.rotate()
+}
+
+// session returns the current cookie jar and header profile.
+func (id *identity) session() (http.CookieJar, headerProfile) {
+	id.mu.Lock()
+	defer id.mu.Unlock()
+	return id.jar, id.profile
+}
+
+// proxyEntry is one line of a -proxy-file: a proxy URL, optionally labeled
+// with the host class allowed to use it and limited to a request rate.
+type proxyEntry struct {
+	class   string // hostClassSearch, hostClassProfile, or "" for either.
+	url     string
+	rpm     int    // Most requests per minute through the proxy; 0 is unlimited.
+	region  string // Country of the exit IP, as proxy-bench found it.
+	quality string // As proxy-bench rated it; "" when not rated.
+}
+
+// loadProxyFile reads proxies, one per line, as "URL" or "search URL" /
+// "profile URL", optionally followed by "rpm=N" to limit the proxy to N
+// requests per minute and the "region=XX" and "quality=Q" labels that
+// proxy-bench writes. Blank lines and # comments are ignored.
+func loadProxyFile(filename string) ([]proxyEntry, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open proxy file: %w", err)
+	}
+	defer file.Close()
+
+	var entries []proxyEntry
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		var e proxyEntry
+		for n := len(fields); n > 1 && strings.Contains(fields[n-1], "="); n-- {
+			key, value, _ := strings.Cut(fields[n-1], "=")
+			switch key {
+			case "rpm":
+				rpm, err := strconv.Atoi(value)
+				if err != nil || rpm <= 0 {
+					return nil, fmt.Errorf("proxy file line %d: invalid %q (want rpm=N with N > 0)", line, fields[n-1])
+				}
+				e.rpm = rpm
+			case "region":
+				e.region = value
+			case "quality":
+				if _, ok := proxyQualityRank[value]; !ok {
+					return nil, fmt.Errorf("proxy file line %d: unknown quality %q", line, value)
+				}
+				e.quality = value
+			default:
+				return nil, fmt.Errorf("proxy file line %d: unknown option %q", line, key)
+			}
+			fields = fields[:n-1]
+		}
+		switch len(fields) {
+		case 1:
+			e.url = fields[0]
+		case 2:
+			e.class, e.url = fields[0], fields[1]
+			if e.class != hostClassSearch && e.class != hostClassProfile {
+				return nil, fmt.Errorf("proxy file line %d: unknown class %q", line, e.class)
+			}
+		default:
+			return nil, fmt.Errorf("proxy file line %d: want \"[class] URL [rpm=N]\"", line)
+		}
+		if u, err := url.Parse(e.url); err != nil || u.Host == "" {
+			return nil, fmt.Errorf("proxy file line %d: invalid proxy URL", line)
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read proxy file: %w", err)
+	}
+	return entries, nil
+}
+
+// splitProxyPools divides proxies into disjoint per-class pools. Labeled
+// proxies go to their class; unlabeled ones alternate between classes.
+func splitProxyPools(entries []proxyEntry) map[string][]proxyEntry {
+	pools := map[string][]proxyEntry{}
+	next := hostClassSearch
+	for _, e := range entries {
+		class := e.class
+		if class == "" {
+			class = next
+			if next == hostClassSearch {
+				next = hostClassProfile
+			} else {
+				next = hostClassSearch
+			}
+		}
+		pools[class] = append(pools[class], e)
+	}
+	return pools
+}
+
+// newIdentities is the identity factory. Under strict isolation each host
+// class gets its own identity and proxy sub-pool; under shared isolation one
+// identity serves every host.
+func newIdentities(isolation string, proxies []proxyEntry, minDelay, maxDelay time.Duration, rng *rand.Rand) (map[string]*identity, error) {
+	switch isolation {
+	case isolationShared:
+		shared := newIdentity("shared", proxies, minDelay, maxDelay, rng)
+		return map[string]*identity{hostClassSearch: shared, hostClassProfile: shared}, nil
+	case isolationStrict:
+		pools := splitProxyPools(proxies)
+		if len(proxies) > 0 && (len(pools[hostClassSearch]) == 0 || len(pools[hostClassProfile]) == 0) {
+			return nil, fmt.Errorf("strict identity isolation needs proxies for both %s and %s hosts", hostClassSearch, hostClassProfile)
+		}
+		return map[string]*identity{
+			hostClassSearch:  newIdentity(hostClassSearch, pools[hostClassSearch], minDelay, maxDelay, rng),
+			hostClassProfile: newIdentity(hostClassProfile, pools[hostClassProfile], minDelay, maxDelay, rng),
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown identity isolation %q (want %s or %s)", isolation, isolationStrict, isolationShared)
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+)
+
+// hostRecorder is an HTTP proxy answering every request itself, remembering
+// the hosts requested through it and the cookies sent to each. Its answers
+// to search hosts set a cookie.
+type hostRecorder struct {
+	mu      sync.Mutex
+	hosts   map[string]int
+	cookies map[string][]string
+}
+
+func startHostRecorder(t *testing.T) (string, *hostRecorder) {
+	t.Helper()
+	rec := &hostRecorder{hosts: make(map[string]int), cookies: make(map[string][]string)}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec.mu.Lock()
+		rec.hosts[r.Host]++
+		for _, c := range r.Cookies() {
+			rec.cookies[r.Host] = append(rec.cookies[r.Host], c.Name)
+		}
+		rec.mu.Unlock()
+		if classifyHost(r.Host) == hostClassSearch {
+			http.SetCookie(w, &http.Cookie{Name: "NID", Value: "tracking", Path: "/"})
+		}
+		fmt.Fprintf(w, "<html><body>%s</body></html>", r.URL)
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL, rec
+}
+
+// seen returns the requests counted per host and the cookie names sent to host.
+func (rec *hostRecorder) seen(host string) (map[string]int, []string) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	hosts := make(map[string]int, len(rec.hosts))
+	for h, n := range rec.hosts {
+		hosts[h] = n
+	}
+	return hosts, append([]string(nil), rec.cookies[host]...)
+}
+
+func TestClassifyHost(t *testing.T) {
+	for host, want := range map[string]string{
+		"www.google.com":              hostClassSearch,
+		"www.google.co.in":            hostClassSearch,
+		"customsearch.googleapis.com": hostClassSearch,
+		"www.linkedin.com":            hostClassProfile,
+		"in.linkedin.com":             hostClassProfile,
+	} {
+		if got := classifyHost(host); got != want {
+			t.Errorf("classifyHost(%s) = %s, want %s", host, got, want)
+		}
+	}
+}
+
+func TestStrictIsolationSeparatesCookieJars(t *testing.T) {
+	google, _ := url.Parse("https://www.google.com/")
+	for _, tt := range []struct {
+		isolation string
+		shared    bool
+	}{
+		{isolationStrict, false},
+		{isolationShared, true},
+	} {
+		identities, err := newIdentities(tt.isolation, nil, 0, 0, rand.New(rand.NewSource(1)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		search, profile := identities[hostClassSearch], identities[hostClassProfile]
+		searchJar, _ := search.session()
+		searchJar.SetCookies(google, []*http.Cookie{{Name: "NID", Value: "tracking"}})
+		profileJar, _ := profile.session()
+		if got := len(profileJar.Cookies(google)) == 1; got != tt.shared || (search == profile) != tt.shared {
+			t.Errorf("%s isolation: profile identity sees the search cookie: %v, want %v", tt.isolation, got, tt.shared)
+		}
+	}
+}
+
+func TestStrictIsolationUsesDisjointProxyPools(t *testing.T) {
+	searchProxy, searchRec := startHostRecorder(t)
+	profileProxy, profileRec := startHostRecorder(t)
+	// Unlabeled proxies alternate between classes, search first.
+	identities, err := newIdentities(isolationStrict, []proxyEntry{{url: searchProxy}, {url: profileProxy}}, 0, 0, rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &httpFetcher{identities: identities, clients: newClientManager()}
+	for i := 0; i < 3; i++ {
+		for _, u := range []string{"http://www.google.com/search?q=valve", "http://www.linkedin.com/in/jane-doe"} {
+			if _, err := f.Fetch(context.Background(), u); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	searchHosts, googleCookies := searchRec.seen("www.google.com")
+	if searchHosts["www.google.com"] != 3 || len(searchHosts) != 1 {
+		t.Errorf("search proxy saw %v, want only Google", searchHosts)
+	}
+	profileHosts, linkedInCookies := profileRec.seen("www.linkedin.com")
+	if profileHosts["www.linkedin.com"] != 3 || len(profileHosts) != 1 {
+		t.Errorf("profile proxy saw %v, want only LinkedIn", profileHosts)
+	}
+	// Google's cookie comes back to Google, and to nobody else.
+	if len(googleCookies) != 2 {
+		t.Errorf("Google got cookies %q, want NID on the two later requests", googleCookies)
+	}
+	if len(linkedInCookies) != 0 {
+		t.Errorf("LinkedIn got cookies %q", linkedInCookies)
+	}
+	if jar, _ := identities[hostClassProfile].session(); len(jar.Cookies(&url.URL{Scheme: "http", Host: "www.google.com", Path: "/"})) != 0 {
+		t.Error("the profile identity's jar holds Google's cookie")
+	}
+}
+
+func TestSplitProxyPools(t *testing.T) {
+	pools := splitProxyPools([]proxyEntry{
+		{url: "http://a:1"},
+		{url: "http://b:1", class: hostClassSearch},
+		{url: "http://c:1"},
+		{url: "http://d:1"},
+	})
+	urls := func(class string) (got []string) {
+		for _, e := range pools[class] {
+			got = append(got, e.url)
+		}
+		return got
+	}
+	if got := fmt.Sprint(urls(hostClassSearch)); got != "[http://a:1 http://b:1 http://d:1]" {
+		t.Errorf("search pool %s", got)
+	}
+	if got := fmt.Sprint(urls(hostClassProfile)); got != "[http://c:1]" {
+		t.Errorf("profile pool %s", got)
+	}
+
+	onlySearch := []proxyEntry{{url: "http://a:1", class: hostClassSearch}}
+	if _, err := newIdentities(isolationStrict, onlySearch, 0, 0, rand.New(rand.NewSource(1))); err == nil {
+		t.Error("strict isolation accepted proxies for search hosts only")
+	}
+	if _, err := newIdentities(isolationShared, onlySearch, 0, 0, rand.New(rand.NewSource(1))); err != nil {
+		t.Errorf("shared isolation: %v", err)
+	}
+	if _, err := newIdentities("loose", nil, 0, 0, rand.New(rand.NewSource(1))); err == nil {
+		t.Error("unknown isolation accepted")
+	}
+}
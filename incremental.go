@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// dayDuration is a flag value taking Go durations and whole days, e.g. 180d.
+type dayDuration time.Duration
+
+func (d *dayDuration) String() string {
+	if *d != 0 && time.Duration(*d)%(24*time.Hour) == 0 {
+		return strconv.Itoa(int(time.Duration(*d)/(24*time.Hour))) + "d"
+	}
+	return time.Duration(*d).String()
+}
+
+func (d *dayDuration) Set(s string) error {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid number of days %q", s)
+		}
+		*d = dayDuration(time.Duration(n) * 24 * time.Hour)
+		return nil
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = dayDuration(v)
+	return nil
+}
+
+// incrementalRun holds what an -incremental run knows from the earlier runs
+// recorded in the store. It is safe for concurrent use; a nil incrementalRun
+// skips nothing.
+type incrementalRun struct {
+	since time.Time // Time of the last recorded run; zero when there is none.
+	runs  int
+	known map[string]time.Time // Last sighting of each stored profile URL.
+	// ttl is how long a sighting suppresses a profile; 0 is forever. Entries
+	// stored before sightings were recorded count as seen at the zero time.
+	ttl time.Duration
+	now time.Time
+
+	mu        sync.Mutex
+	seenAgain []string // Known profiles found again by this run.
+}
+
+// newIncrementalRun reads the runs and candidates recorded in store. A
+// sighting older than ttl no longer suppresses its profile.
+func newIncrementalRun(store *candidateStore, ttl time.Duration) *incrementalRun {
+	r := &incrementalRun{known: make(map[string]time.Time), ttl: ttl, now: clockNow().UTC()}
+	for _, sc := range store.candidates() {
+		r.known[sc.ProfileURL] = sc.LastSeen
+	}
+	if last, ok := store.lastRun(); ok {
+		r.since = last.Time
+	}
+	r.runs = store.runCount()
+	return r
+}
+
+// skipKnown drops the candidates found by an earlier run, before they cost a
+// profile request, and remembers them so the store can note they were seen.
+// A candidate last seen longer than the ttl ago is kept and marked
+// Rediscovered; saving it to the store refreshes its sighting.
+func (r *incrementalRun) skipKnown(candidates []Candidate) []Candidate {
+	if r == nil {
+		return candidates
+	}
+	fresh := candidates[:0]
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, c := range candidates {
+		if last, ok := r.known[c.ProfileURL]; ok {
+			if !r.expired(last) {
+				r.seenAgain = append(r.seenAgain, c.ProfileURL)
+				continue
+			}
+			c.Rediscovered, c.PreviouslySeen = true, &last
+		}
+		fresh = append(fresh, c)
+	}
+	return fresh
+}
+
+// expired reports whether a sighting at last no longer suppresses a profile.
+func (r *incrementalRun) expired(last time.Time) bool {
+	return r.ttl > 0 && r.now.Sub(last) >= r.ttl
+}
+
+// seen returns the known profiles found again so far.
+func (r *incrementalRun) seen() []string {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.seenAgain...)
+}
+
+// describe prints what the run is compared against.
+func (r *incrementalRun) describe() {
+	if len(r.known) == 0 {
+		fmt.Println("Incremental run: the store is empty, so every candidate is new.")
+		return
+	}
+	last := ""
+	if r.runs > 0 {
+		last = fmt.Sprintf(" from %d earlier runs, the last on %s", r.runs, r.since.Local().Format("2006-01-02 15:04"))
+	}
+	fmt.Printf("Incremental run: writing only candidates not among the %d stored%s.\n", len(r.known), last)
+	if r.ttl > 0 {
+		d := dayDuration(r.ttl)
+		fmt.Printf("Candidates last seen more than %s ago are written again, marked rediscovered.\n", d.String())
+	}
+}
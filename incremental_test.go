@@ -0,0 +1,62 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestIncrementalRunWritesOnlyNewCandidates(t *testing.T) {
+	storePath := filepath.Join(t.TempDir(), "candidates.db")
+
+	_, addr := startFakeWeb(t, fakeRoster(3))
+	output, err := runFakeSearch(t, addr, "-max-pages", "1", "-store", storePath, "-incremental")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(readFakeSearch(t, output)); got != 3 {
+		t.Fatalf("first run wrote %d candidates, want all 3", got)
+	}
+
+	// A week later two more members match.
+	web, addr := startFakeWeb(t, fakeRoster(5))
+	output, err = runFakeSearch(t, addr, "-max-pages", "1", "-store", storePath, "-incremental")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := readFakeSearch(t, output)
+	if len(got) != 2 || got[0].Name != "Member 4" || got[1].Name != "Member 5" {
+		t.Errorf("second run wrote %+v, want Member 4 and Member 5 only", got)
+	}
+	if n := web.requests("profile"); n != 2 {
+		t.Errorf("%d profiles fetched, want only the 2 new ones", n)
+	}
+
+	store, err := openStore(storePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(store.candidates()); n != 5 {
+		t.Errorf("%d candidates stored, want 5", n)
+	}
+	last, ok := store.lastRun()
+	if !ok || store.runCount() != 2 || !last.Incremental || last.SeenAgain != 3 || last.Kept != 2 {
+		t.Errorf("last of %d runs recorded as %+v, want an incremental run keeping 2 and seeing 3 again", store.runCount(), last)
+	}
+
+	// A run finding nothing new is recorded too, so the next compares with it.
+	if _, err := runFakeSearch(t, addr, "-max-pages", "1", "-store", storePath, "-incremental"); err != nil {
+		t.Fatal(err)
+	}
+	if store, err = openStore(storePath); err != nil {
+		t.Fatal(err)
+	}
+	if last, _ := store.lastRun(); store.runCount() != 3 || last.SeenAgain != 5 {
+		t.Errorf("empty run recorded as %+v of %d runs, want a third run seeing all 5 again", last, store.runCount())
+	}
+}
+
+func TestIncrementalNeedsStore(t *testing.T) {
+	if _, err := parseFlags([]string{"-incremental"}); err == nil {
+		t.Error("-incremental accepted without -store")
+	}
+}
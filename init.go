@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// wizardQuestion is one prompt of `profilesearch init`. Its answer becomes
+// the setting of the flag of the same name.
+type wizardQuestion struct {
+	flag     string
+	prompt   string
+	required bool
+	validate func(answer string) error // Optional; not called for blank answers.
+	defValue func(defaults *config) string
+}
+
+// wizardAnswer is a validated answer.
+type wizardAnswer struct {
+	question wizardQuestion
+	value    string
+}
+
+// wizardQuestions are asked in order.
+var wizardQuestions = []wizardQuestion{
+	{
+		flag: "keywords", prompt: "Search keywords (a trailing * keeps multi-word keywords loose)", required: true,
+		defValue: func(d *config) string { return d.criteria.Keywords },
+	},
+	{
+		flag: "location", prompt: "Candidate location",
+		defValue: func(d *config) string { return d.criteria.Location },
+	},
+	{
+		flag: "industry", prompt: "Candidate industry (- for any)",
+		defValue: func(d *config) string { return d.criteria.Industry },
+	},
+	{
+		flag: "experience", prompt: `Experience range, e.g. "7-12 years" (- for any)`,
+		validate: func(a string) error {
+			if _, _, ok := parseExperienceRange(a); !ok {
+				return errors.New(`want a range such as "7-12 years"`)
+			}
+			return nil
+		},
+		defValue: func(d *config) string { return d.criteria.ExperienceRange },
+	},
+	{
+		flag: "max-pages", prompt: "Google result pages per search", required: true,
+		validate: func(a string) error {
+			if n, err := strconv.Atoi(a); err != nil || n < 1 {
+				return errors.New("want a whole number of at least 1")
+			}
+			return nil
+		},
+		defValue: func(d *config) string { return strconv.Itoa(d.maxPages) },
+	},
+	{
+		flag: "proxy-file", prompt: "Proxy file, one proxy URL per line (blank to connect directly)",
+		validate: func(a string) error {
+			_, err := loadProxyFile(a)
+			return err
+		},
+	},
+	{
+		flag: "output", prompt: "CSV output file", required: true,
+		defValue: func(d *config) string { return d.output },
+	},
+	{
+		flag: "html-report", prompt: "HTML report file (blank for none)",
+	},
+	{
+		flag: "store", prompt: "Candidate store file, kept across runs (blank for none)",
+	},
+}
+
+// runWizard asks each question on out, reading answers line by line from
+// scanner. A blank answer takes the default and "-" clears it; an invalid
+// answer is asked again.
+func runWizard(scanner *bufio.Scanner, out io.Writer, questions []wizardQuestion, defaults *config) ([]wizardAnswer, error) {
+	var answers []wizardAnswer
+	for _, q := range questions {
+		def := ""
+		if q.defValue != nil {
+			def = q.defValue(defaults)
+		}
+		for {
+			if def != "" {
+				fmt.Fprintf(out, "%s [%s]: ", q.prompt, def)
+			} else {
+				fmt.Fprintf(out, "%s: ", q.prompt)
+			}
+			if !scanner.Scan() {
+				if err := scanner.Err(); err != nil {
+					return nil, fmt.Errorf("failed to read answer: %w", err)
+				}
+				return nil, fmt.Errorf("input ended before %q was answered", q.flag)
+			}
+			answer := strings.TrimSpace(scanner.Text())
+			switch answer {
+			case "":
+				answer = def
+			case "-":
+				answer = ""
+			}
+			if answer == "" && q.required {
+				fmt.Fprintln(out, "  An answer is required.")
+				continue
+			}
+			if answer != "" && q.validate != nil {
+				if err := q.validate(answer); err != nil {
+					fmt.Fprintf(out, "  Invalid answer: %v\n", err)
+					continue
+				}
+			}
+			answers = append(answers, wizardAnswer{question: q, value: answer})
+			break
+		}
+	}
+	return answers, nil
+}
+
+// writeWizardConfig writes answers as a commented -config file. Every answer
+// is written, blank ones too, so that a cleared default stays cleared.
+func writeWizardConfig(w io.Writer, answers []wizardAnswer) error {
+	var b strings.Builder
+	b.WriteString("# profilesearch settings, written by `profilesearch init`.\n")
+	b.WriteString("# Each key is a command-line flag; run with -config to use them.\n")
+	b.WriteString("# Flags given on the command line take precedence.\n")
+	for _, a := range answers {
+		fmt.Fprintf(&b, "\n# %s\n%s: %s\n", a.question.prompt, a.question.flag, strconv.Quote(a.value))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// isTerminal reports whether f is an interactive terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// runInitCommand implements `profilesearch init`: it asks for the common
+// settings, writes them to a -config file, and offers to show the queries
+// they produce.
+func runInitCommand(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("init", flag.ExitOnError)
+	path := fs.String("o", defaultConfigFile, "config file to write")
+	force := fs.Bool("force", false, "overwrite an existing config file")
+	fs.Parse(args)
+
+	if !isTerminal(os.Stdin) {
+		return errors.New("init needs an interactive terminal; write the settings to a YAML file and pass it with -config instead")
+	}
+	if _, err := os.Stat(*path); err == nil && !*force {
+		return fmt.Errorf("%s already exists (use -force to overwrite it)", *path)
+	}
+
+	defaults, err := parseFlags(nil)
+	if err != nil {
+		return err
+	}
+	in := bufio.NewScanner(os.Stdin)
+	answers, err := runWizard(in, os.Stdout, wizardQuestions, defaults)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(*path)
+	if err != nil {
+		return fmt.Errorf("failed to create config file: %w", err)
+	}
+	if err := writeWizardConfig(file, answers); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	fmt.Printf("Wrote %s. Run a search with: profilesearch -config %s\n", *path, *path)
+
+	fmt.Print("Show the queries these settings make, without fetching anything? [Y/n]: ")
+	in.Scan()
+	if reply := strings.ToLower(strings.TrimSpace(in.Text())); reply != "" && reply != "y" && reply != "yes" {
+		return nil
+	}
+	return runSearchCommand(ctx, []string{"-config", *path, "-show-query"})
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWizardScriptedAnswers(t *testing.T) {
+	defaults, err := parseFlags(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	script := strings.Join([]string{
+		"-",             // keywords: required, so clearing it is asked again
+		"control valve", // keywords
+		"Houston",       // location
+		"-",             // industry: cleared
+		"seven years",   // experience: invalid, asked again
+		"7-12 years",    // experience
+		"0",             // max-pages: invalid, asked again
+		"3",             // max-pages
+		"",              // proxy-file: blank
+		"",              // output: default
+		"report.html",   // html-report
+		"",              // store: blank
+	}, "\n") + "\n"
+	var prompts strings.Builder
+	answers, err := runWizard(bufio.NewScanner(strings.NewReader(script)), &prompts, wizardQuestions, defaults)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"An answer is required.", `Invalid answer: want a range such as "7-12 years"`, "Invalid answer: want a whole number of at least 1"} {
+		if !strings.Contains(prompts.String(), want) {
+			t.Errorf("prompts do not say %q:\n%s", want, prompts.String())
+		}
+	}
+	got := make(map[string]string)
+	for _, a := range answers {
+		got[a.question.flag] = a.value
+	}
+	want := map[string]string{
+		"keywords": "control valve", "location": "Houston", "industry": "", "experience": "7-12 years",
+		"max-pages": "3", "proxy-file": "", "output": defaults.output, "html-report": "report.html", "store": "",
+	}
+	for flag, v := range want {
+		if got[flag] != v {
+			t.Errorf("%s answered %q, want %q", flag, got[flag], v)
+		}
+	}
+
+	// The written file is a -config file giving the same settings.
+	path := filepath.Join(t.TempDir(), defaultConfigFile)
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writeWizardConfig(file, answers); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	cfg, err := parseFlags([]string{"-config", path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := cfg.criteria
+	if c.Keywords != "control valve" || c.Location != "Houston" || c.Industry != "" || c.ExperienceRange != "7-12 years" || cfg.maxPages != 3 {
+		t.Errorf("config file read back as %+v with max pages %d", c, cfg.maxPages)
+	}
+}
+
+func TestWizardInputEndsEarly(t *testing.T) {
+	defaults, err := parseFlags(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var prompts strings.Builder
+	_, err = runWizard(bufio.NewScanner(strings.NewReader("control valve\n")), &prompts, wizardQuestions, defaults)
+	if err == nil || !strings.Contains(err.Error(), `"location"`) {
+		t.Errorf("got %v, want an error naming the unanswered location", err)
+	}
+}
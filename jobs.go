@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Batch output modes for -jobs-output.
+const (
+	jobsOutputPerJob   = "per-job"  // One CSV file per job.
+	jobsOutputCombined = "combined" // A single CSV with a Job column.
+)
+
+// Job is a named search listed in a jobs file.
+type Job struct {
+	Name           string `yaml:"name"`
+	Output         string `yaml:"output"` // Optional per-job CSV filename.
+	SearchCriteria `yaml:",inline"`
+}
+
+// jobsFile is the on-disk layout of a -jobs YAML file.
+type jobsFile struct {
+	Jobs []Job `yaml:"jobs"`
+}
+
+// loadJobs reads and validates a jobs file.
+func loadJobs(filename string) ([]Job, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read jobs file: %w", err)
+	}
+
+	var jf jobsFile
+	if err := yaml.Unmarshal(data, &jf); err != nil {
+		return nil, fmt.Errorf("failed to parse jobs file: %w", err)
+	}
+	if len(jf.Jobs) == 0 {
+		return nil, fmt.Errorf("jobs file %s lists no jobs", filename)
+	}
+
+	seen := make(map[string]bool)
+	for i := range jf.Jobs {
+		job := &jf.Jobs[i]
+		if job.Name == "" {
+			job.Name = fmt.Sprintf("job-%d", i+1)
+		}
+		if seen[job.Name] {
+			return nil, fmt.Errorf("duplicate job name %q", job.Name)
+		}
+		seen[job.Name] = true
+		if strings.TrimSpace(job.Keywords) == "" {
+			return nil, fmt.Errorf("job %q has no keywords", job.Name)
+		}
+	}
+	return jf.Jobs, nil
+}
+
+var unsafeFilenameChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// jobOutputFilename returns the per-job CSV filename, derived from the main
+// output filename when the job does not name one.
+func jobOutputFilename(base string, job Job) string {
+	if job.Output != "" {
+		return job.Output
+	}
+	ext := filepath.Ext(base)
+	name := unsafeFilenameChars.ReplaceAllString(job.Name, "_")
+	return strings.TrimSuffix(base, ext) + "_" + name + ext
+}
+
+// runJobs runs each job sequentially through the shared fetcher, so the global
+// rate limit applies across all of them, and writes the configured output.
+func runJobs(ctx context.Context, cfg *config, f Fetcher, jobs []Job) error {
+	if cfg.jobsOutput != jobsOutputPerJob && cfg.jobsOutput != jobsOutputCombined {
+		return fmt.Errorf("unknown -jobs-output %q (want %s or %s)", cfg.jobsOutput, jobsOutputPerJob, jobsOutputCombined)
+	}
+
+	combinedColumns := cfg.columns
+	if jobCol, _ := findCSVColumn("job"); !hasCSVColumn(combinedColumns, "job") {
+		combinedColumns = append(append([]csvColumn{}, combinedColumns...), jobCol)
+	}
+	if cfg.flushEvery > 0 && cfg.jobsOutput == jobsOutputCombined {
+		stream, err := openCSVStream(cfg.output, combinedColumns, cfg.runInfo(SearchCriteria{}, "combined"), cfg.flushEvery, cfg.csv)
+		if err != nil {
+			return err
+		}
+		defer closeCSVStream(stream)
+		cfg.stream = stream
+	}
+
+	var combined, all []Candidate
+	stopped := false
+	for i, job := range jobs {
+		if ctx.Err() != nil || stopped {
+			break
+		}
+		if i > 0 {
+			if err := cooldownBetweenJobs(ctx, cfg, f); err != nil {
+				break
+			}
+		}
+		fmt.Printf("Running job %d/%d: %s\n", i+1, len(jobs), job.Name)
+
+		filename := jobOutputFilename(cfg.output, job)
+		if cfg.flushEvery > 0 && cfg.jobsOutput == jobsOutputPerJob {
+			stream, err := openCSVStream(filename, cfg.columns, cfg.runInfo(job.SearchCriteria, job.Name), cfg.flushEvery, cfg.csv)
+			if err != nil {
+				return fmt.Errorf("job %s: %w", job.Name, err)
+			}
+			cfg.stream = stream
+		}
+
+		candidates, err := runSearch(ctx, cfg, f, job)
+		var outErr *outputError
+		if errors.As(err, &outErr) {
+			if cfg.jobsOutput == jobsOutputPerJob && cfg.stream != nil {
+				closeCSVStream(cfg.stream) // The combined stream is closed by its defer.
+				cfg.stream = nil
+			}
+			return fmt.Errorf("job %s: %w", job.Name, err)
+		}
+		if err != nil {
+			log.Printf("Job %s stopped early: %v", job.Name, err)
+			// Write what this job found, but start no further jobs.
+			stopped = stopsRun(err)
+		}
+		all = append(all, candidates...)
+
+		if cfg.jobsOutput == jobsOutputCombined {
+			combined = append(combined, candidates...)
+			continue
+		}
+		if cfg.stream != nil {
+			closeCSVStream(cfg.stream)
+			cfg.stream = nil
+			fmt.Printf("Successfully wrote %d candidates to %s\n", len(candidates), filename)
+			continue
+		}
+		if len(candidates) == 0 {
+			log.Printf("No candidates found for job %s.", job.Name)
+			continue
+		}
+		if err := cfg.outputs.deliver(outputCSV, func() error {
+			return writeOutput(cfg, candidates, filename, cfg.columns, job.SearchCriteria, job.Name)
+		}); err != nil {
+			return fmt.Errorf("job %s: %w", job.Name, err)
+		}
+		fmt.Printf("Successfully wrote %d candidates to %s\n", len(candidates), filename)
+	}
+
+	if cfg.jobsOutput == jobsOutputCombined {
+		if len(combined) == 0 {
+			log.Println("No candidates found.")
+			return recordEmptyRun(cfg)
+		}
+		if cfg.stream == nil {
+			if err := cfg.outputs.deliver(outputCSV, func() error {
+				return writeOutput(cfg, combined, cfg.output, combinedColumns, SearchCriteria{}, "combined")
+			}); err != nil {
+				return err
+			}
+		}
+		fmt.Printf("Successfully wrote %d candidates to %s\n", len(combined), cfg.output)
+	}
+
+	return writeSecondaryOutputs(cfg, all, SearchCriteria{}, "combined")
+}
+
+// cooldownBetweenJobs pauses for the configured cooldown and optionally resets
+// the fetcher's session, so blocking signals do not accumulate across jobs.
+func cooldownBetweenJobs(ctx context.Context, cfg *config, f Fetcher) error {
+	if cfg.jobResetSession {
+		if r, ok := f.(sessionResetter); ok {
+			r.ResetSession()
+		}
+	}
+	if cfg.jobCooldown <= 0 {
+		return nil
+	}
+	fmt.Printf("Cooling down for %s before the next job\n", cfg.jobCooldown)
+	return sleepContext(ctx, cfg.jobCooldown)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeJobsFile writes a jobs file of content and returns its path.
+func writeJobsFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "jobs.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadJobs(t *testing.T) {
+	jobs, err := loadJobs(writeJobsFile(t, `jobs:
+  - name: valves
+    keywords: valve engineer
+    location: Pune
+  - keywords: pump engineer
+    output: pumps.csv
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jobs) != 2 {
+		t.Fatalf("%d jobs loaded, want 2", len(jobs))
+	}
+	if jobs[0].Name != "valves" || jobs[0].Keywords != "valve engineer" || jobs[0].Location != "Pune" {
+		t.Errorf("job 1 = %+v", jobs[0])
+	}
+	if jobs[1].Name != "job-2" || jobs[1].Output != "pumps.csv" {
+		t.Errorf("job 2 = %+v, want the default name job-2", jobs[1])
+	}
+
+	for content, want := range map[string]string{
+		"jobs: []\n": "lists no jobs",
+		"jobs:\n  - name: a\n    keywords: x\n  - name: a\n    keywords: y\n": `duplicate job name "a"`,
+		"jobs:\n  - name: a\n    location: Pune\n":                            `job "a" has no keywords`,
+		"jobs: [": "failed to parse jobs file",
+	} {
+		if _, err := loadJobs(writeJobsFile(t, content)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("loadJobs(%q) error = %v, want %q", content, err, want)
+		}
+	}
+}
+
+func TestJobOutputFilename(t *testing.T) {
+	tests := []struct {
+		job  Job
+		want string
+	}{
+		{Job{Name: "valves"}, "out/candidates_valves.csv"},
+		{Job{Name: "Valve engineers / Pune"}, "out/candidates_Valve_engineers_Pune.csv"},
+		{Job{Name: "valves", Output: "valves.csv"}, "valves.csv"},
+	}
+	for _, tt := range tests {
+		if got := jobOutputFilename("out/candidates.csv", tt.job); got != tt.want {
+			t.Errorf("jobOutputFilename(%+v) = %s, want %s", tt.job, got, tt.want)
+		}
+	}
+}
+
+func TestJobsWritePerJobOutputs(t *testing.T) {
+	web, addr := startFakeWeb(t, fakeRoster(3))
+	jobs := writeJobsFile(t, "jobs:\n  - name: valves\n    keywords: valve engineer\n  - name: pumps\n    keywords: pump engineer\n")
+	output, err := runFakeSearch(t, addr, "-jobs", jobs, "-max-pages", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"valves", "pumps"} {
+		filename := strings.TrimSuffix(output, ".csv") + "_" + name + ".csv"
+		if candidates := readFakeSearch(t, filename); len(candidates) != 3 {
+			t.Errorf("%s has %d candidates, want 3", filepath.Base(filename), len(candidates))
+		}
+	}
+	if _, err := os.Stat(output); err == nil {
+		t.Errorf("per-job run also wrote %s", filepath.Base(output))
+	}
+	if got := web.requests("search"); got != 2 {
+		t.Errorf("%d searches made, want one per job", got)
+	}
+}
+
+func TestJobsWriteCombinedOutputWithJobColumn(t *testing.T) {
+	_, addr := startFakeWeb(t, fakeRoster(2))
+	jobs := writeJobsFile(t, "jobs:\n  - name: valves\n    keywords: valve engineer\n  - name: pumps\n    keywords: pump engineer\n")
+	output, err := runFakeSearch(t, addr, "-jobs", jobs, "-jobs-output", "combined")
+	if err != nil {
+		t.Fatal(err)
+	}
+	perJob := make(map[string]int)
+	for _, c := range readFakeSearch(t, output) {
+		perJob[c.Job]++
+	}
+	if perJob["valves"] != 2 || perJob["pumps"] != 2 || len(perJob) != 2 {
+		t.Errorf("candidates by job %v, want 2 for each job", perJob)
+	}
+}
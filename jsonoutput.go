@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// jsonOutput is the document of -format json. The run info leads, so that
+// a reader learns what produced the file before the candidates.
+type jsonOutput struct {
+	RunInfo    *runInfo    `json:"run_info,omitempty"`
+	Candidates []Candidate `json:"candidates"`
+}
+
+// jsonRunInfoLine is the leading line of -format jsonl output.
+type jsonRunInfoLine struct {
+	RunInfo *runInfo `json:"run_info"`
+}
+
+// writeToJSON writes candidates to filename as one JSON document or, with
+// lines, as JSON Lines: a leading line holding the run info block, when info
+// is not nil, then one candidate per line.
+func writeToJSON(candidates []Candidate, filename string, info *runInfo, lines bool) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create JSON file: %w", err)
+	}
+	defer file.Close()
+
+	w := bufio.NewWriter(file)
+	enc := json.NewEncoder(w)
+	if lines {
+		if info != nil {
+			if err := enc.Encode(jsonRunInfoLine{info}); err != nil {
+				return fmt.Errorf("failed to write run info: %w", err)
+			}
+		}
+		for _, c := range candidates {
+			if err := enc.Encode(c); err != nil {
+				return fmt.Errorf("failed to write candidate %s: %w", c.ProfileURL, err)
+			}
+		}
+	} else {
+		if candidates == nil {
+			candidates = []Candidate{} // An empty list, not null.
+		}
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(jsonOutput{RunInfo: info, Candidates: candidates}); err != nil {
+			return fmt.Errorf("failed to write JSON file: %w", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("failed to write JSON file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write JSON file: %w", err)
+	}
+	return nil
+}
+
+// readJSONRunInfo parses the run info block of -format json or jsonl output.
+func readJSONRunInfo(filename string) (*runInfo, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", filename, err)
+	}
+	defer file.Close()
+
+	var block struct {
+		RunInfo map[string]string `json:"run_info"`
+	}
+	if strings.EqualFold(filepath.Ext(filename), ".jsonl") {
+		// Only the first line; the candidates follow.
+		scanner := bufio.NewScanner(file)
+		scanner.Buffer(nil, 1<<20)
+		if scanner.Scan() {
+			err = json.Unmarshal(scanner.Bytes(), &block)
+		} else {
+			err = scanner.Err()
+		}
+	} else {
+		err = json.NewDecoder(file).Decode(&block)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", filename, err)
+	}
+	return runInfoFromFields(filename, block.RunInfo)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// keywordAlternativesRegex splits a keyword argument into alternatives at
+// commas and at the OR operator.
+var keywordAlternativesRegex = regexp.MustCompile(`\s*,\s*|\s+OR\s+`)
+
+// phraseKeywords turns a keyword argument into Google query syntax; see
+// keywordsPart.
+func phraseKeywords(keywords string, loose bool) string {
+	if part := keywordsPart(keywords, loose); part != nil {
+		return orGroup(part)
+	}
+	return strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(keywords), "*"))
+}
+
+// keywordsPart parses a keyword argument. Multi-word keywords become an exact
+// phrase, so "control valve desuperheater" no longer matches pages that
+// mention "control" and "valve" apart, and alternatives separated by commas
+// or OR become alternatives of the part. A trailing * or loose keeps the
+// words loose. It returns nil for no keywords and for keywords already
+// containing quotes, which are the user's own syntax.
+func keywordsPart(keywords string, loose bool) queryPart {
+	keywords = strings.TrimSpace(keywords)
+	if strings.HasSuffix(keywords, "*") {
+		keywords = strings.TrimSpace(strings.TrimSuffix(keywords, "*"))
+		loose = true
+	}
+	if keywords == "" || strings.Contains(keywords, `"`) {
+		return nil
+	}
+	var part queryPart
+	for _, alt := range keywordAlternativesRegex.Split(keywords, -1) {
+		if alt = strings.Join(strings.Fields(alt), " "); alt != "" {
+			part = append(part, queryTerm{Text: alt, Phrase: !loose && strings.Contains(alt, " ")})
+		}
+	}
+	return part
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestKeywordPhraseQueries(t *testing.T) {
+	tests := []struct {
+		name string
+		c    SearchCriteria
+		want string
+	}{
+		{"multi-word phrase", SearchCriteria{Keywords: "control valve desuperheater"}, `site:linkedin.com/in "control valve desuperheater"`},
+		{"trailing star", SearchCriteria{Keywords: "control valve desuperheater*"}, `site:linkedin.com/in control valve desuperheater`},
+		{"-loose-keywords", SearchCriteria{Keywords: "control valve desuperheater", LooseKeywords: true}, `site:linkedin.com/in control valve desuperheater`},
+		{"comma alternatives", SearchCriteria{Keywords: "desuperheater, attemperator"}, `site:linkedin.com/in (desuperheater OR attemperator)`},
+		{"OR of a phrase and a word", SearchCriteria{Keywords: "control valve OR desuperheater"}, `site:linkedin.com/in ("control valve" OR desuperheater)`},
+		{"loose alternatives", SearchCriteria{Keywords: "control valve, desuperheater", LooseKeywords: true}, `site:linkedin.com/in (control valve OR desuperheater)`},
+		{"one word", SearchCriteria{Keywords: "valve"}, `site:linkedin.com/in valve`},
+		{"user's own quotes", SearchCriteria{Keywords: `"control valve" actuator`}, `site:linkedin.com/in "control valve" actuator`},
+	}
+	for _, tt := range tests {
+		u, err := url.Parse(buildGoogleSearchURL(tt.c))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := u.Query().Get("q"); got != tt.want {
+			t.Errorf("%s: q = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLooseKeywordsReachesEveryJob(t *testing.T) {
+	cfg, err := parseFlags([]string{"-keywords", "control valve", "-loose-keywords"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.criteria.LooseKeywords {
+		t.Fatal("-loose-keywords not set on the criteria")
+	}
+	job := Job{Name: "valves", SearchCriteria: SearchCriteria{Keywords: "control valve"}}
+	if c := cfg.jobCriteria(job); !c.LooseKeywords {
+		t.Error("a job of a -loose-keywords run searches phrases")
+	}
+
+	jobs, err := loadJobs(writeJobsFile(t, "jobs:\n  - name: valves\n    keywords: control valve\n    loose_keywords: true\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !jobs[0].LooseKeywords {
+		t.Error("loose_keywords of a jobs file row not read")
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// languageProfiles rank each language's most frequent character trigrams,
+// most frequent first, separated by "|". Words are padded with a space at
+// each end. They were built from profile-style text in each language.
+var languageProfiles = map[string]string{
+	"en": " an|and|nd | in|ing|ng | th|the| i |er |he |ent|ion|am | of|of | a | pr|pro|on |nce| en|eng|ngi|gin|ine|nee|eer|ce | ma| co|es |ati|tio|men|nt |ty |eri|rie|ien|al | wi|wit|ith|th | te|in |tea|eam| wo|wor|ork|as |or |roj|oje|jec|ect|man|rin|ust|str|tom|ity| am| ex|exp|xpe|per|enc|ed |ica|cal|ove|ver|ten|rs | de|anc|con",
+	"de": "en | un|und|ich|nd |er |der|ch | de|ung|eit|che|in |ein| in| ic| ei|sch|nge|gen|it |ng |ren|run|on |ite|nde|tri|rie|ver|sen|ing|eni|nie|ieu|eur| mi| me|str|men|rbe|bei|ten| pr|pro|den|her|ie |ind| zu|sse|eru|ahr|hre|ine|mit| al|als|ls | wa| vo|von|ste|arb|end|roj|oje|jek|ekt|age| fü| di|die|ndu|dus|ust| ve|ess|tät|sin",
+	"fr": " de|de |et |es | et|ion|ent|tio|on |ati| l | in| la|la |ns |nce|ce | je|je | su|eur|té |ans| co|nt | pr|ité|is | un|ing|ngé|gén|éni|nie|ur |men| da|dan|pro| se|ne | en|qui|le |des| no|sui|uis|ieu| av|ave|vec|ec | pl|us | di|rie|ien|anc|nne|lle|che|roj|oje|jet| éq|équ|uip|ipe| re|en | du|du |ts |com| au|uto|oma| le|rav",
+	"es": " de|de | la|la |os | y | in|ent| en|en |nte|ión|ón |eni| co|ien|as |ció|ida|dad| un|ier|con|el |aci|est|es |ing|nge|gen|nie| el|te | pr|pro|ect|cto|tos| se|qui|str|ad |un |ero|ro | me|on | di|ten|tro|men| tr|tra|rab|aba|baj|roy|oye|yec|res| eq|equ|uip|ipo|po |lid| qu|co |per|eri|enc|nci|ia | ma|ant|mie|nto|to |ont|por",
+	"it": " de| in| e |ell|ion|one|ne |del| pr|zio| co| di|lla|la |azi|di |enz|pro|ti |no | un|ner|re |con|za |ent| la|son|ono|ing|nge|geg|egn|gne|ien|nza| ne|ett|ten|le |res|ess|ll |in |ssi|nti|ità|tà |to | so|un |on |eri|nel|rog|oge|get|sis|ist|ste|men|lav|avo|vor|com| se|pre|ass|str| l |ei |tro|ora|ato|el |erc|ere| me|co |esp",
+}
+
+// rankedProfiles are languageProfiles split into trigram ranks.
+var rankedProfiles = func() map[string]map[string]int {
+	ranked := make(map[string]map[string]int, len(languageProfiles))
+	for lang, grams := range languageProfiles {
+		ranks := make(map[string]int)
+		for i, g := range strings.Split(grams, "|") {
+			ranks[g] = i
+		}
+		ranked[lang] = ranks
+	}
+	return ranked
+}()
+
+const (
+	minLanguageLetters = 20  // Shorter texts are not classified.
+	languageDocGrams   = 150 // Trigrams of the text compared against each profile.
+)
+
+// textTrigrams returns the trigrams of text's words, most frequent first.
+func textTrigrams(text string) []string {
+	counts := make(map[string]int)
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool { return !unicode.IsLetter(r) }) {
+		padded := []rune(" " + word + " ")
+		for i := 0; i+3 <= len(padded); i++ {
+			counts[string(padded[i:i+3])]++
+		}
+	}
+	grams := make([]string, 0, len(counts))
+	for g := range counts {
+		grams = append(grams, g)
+	}
+	sort.Slice(grams, func(i, j int) bool {
+		if counts[grams[i]] != counts[grams[j]] {
+			return counts[grams[i]] > counts[grams[j]]
+		}
+		return grams[i] < grams[j]
+	})
+	if len(grams) > languageDocGrams {
+		grams = grams[:languageDocGrams]
+	}
+	return grams
+}
+
+// detectLanguage returns the ISO 639-1 code of the language text is most
+// likely in, by the out-of-place distance between its trigram ranks and each
+// language profile, or "" when text is too short to tell.
+func detectLanguage(text string) string {
+	letters := 0
+	for _, r := range text {
+		if unicode.IsLetter(r) {
+			letters++
+		}
+	}
+	if letters < minLanguageLetters {
+		return ""
+	}
+	grams := textTrigrams(text)
+	best, bestDistance := "", -1
+	for lang, ranks := range rankedProfiles {
+		distance := 0
+		for i, g := range grams {
+			if r, ok := ranks[g]; ok {
+				distance += abs(i - r)
+			} else {
+				distance += len(ranks) // The largest penalty: not in the profile at all.
+			}
+		}
+		if bestDistance < 0 || distance < bestDistance || (distance == bestDistance && lang < best) {
+			best, bestDistance = lang, distance
+		}
+	}
+	return best
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// profileLanguage detects the language of a candidate's own words: the
+// profile summary and headline, or else the snippet.
+func profileLanguage(c Candidate) string {
+	return detectLanguage(strings.Join([]string{c.Headline, c.Summary, c.Snippet}, " "))
+}
+
+// candidateLanguage returns the language the candidate's profile page
+// declares, or else the one detected from their text, or "".
+func candidateLanguage(c Candidate) string {
+	return firstNonEmpty(c.PageLanguage, c.ProfileLanguage)
+}
+
+// languageFilter drops candidates whose language is not one of languages.
+// Candidates whose language is unknown pass, unless strict.
+func languageFilter(languages []string, strict bool) candidateFilter {
+	return func(c Candidate) filterDecision {
+		lang := candidateLanguage(c)
+		passed := lang == "" && !strict
+		for _, want := range languages {
+			passed = passed || lang == want
+		}
+		actual := lang
+		if lang == "" {
+			actual = "unknown"
+		}
+		return filterDecision{Filter: "profile_language", Passed: passed, Expected: strings.Join(languages, ","), Actual: actual}
+	}
+}
+
+// languageCodeRegex matches a language tag, such as "en", "hi-IN", or
+// "pt_BR", capturing its primary language.
+var languageCodeRegex = regexp.MustCompile(`^([a-zA-Z]{2,3})(?:[-_][a-zA-Z0-9]+)*$`)
+
+// normalizeLanguageTag returns the lower-case primary language of a tag,
+// such as "hi" for "hi-IN", or "" when tag is not a language tag.
+func normalizeLanguageTag(tag string) string {
+	m := languageCodeRegex.FindStringSubmatch(strings.TrimSpace(tag))
+	if m == nil {
+		return ""
+	}
+	return strings.ToLower(m[1])
+}
+
+// keywordsLangRegex matches one "lang:keywords" entry of -keywords-lang,
+// with the keywords optionally in quotes.
+var keywordsLangRegex = regexp.MustCompile(`^\s*([a-zA-Z]{2})\s*:\s*"?([^"]*)"?\s*$`)
+
+// languageKeywords is one language's keywords from -keywords-lang.
+type languageKeywords struct {
+	Lang     string
+	Keywords string
+}
+
+// parseKeywordsLang parses -keywords-lang, e.g. `en:"control valve";de:"Regelventil"`.
+func parseKeywordsLang(spec string) ([]languageKeywords, error) {
+	var sets []languageKeywords
+	for _, entry := range strings.Split(spec, ";") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		m := keywordsLangRegex.FindStringSubmatch(entry)
+		if m == nil || strings.TrimSpace(m[2]) == "" {
+			return nil, fmt.Errorf("entry %q is not lang:\"keywords\"", strings.TrimSpace(entry))
+		}
+		sets = append(sets, languageKeywords{Lang: strings.ToLower(m[1]), Keywords: strings.TrimSpace(m[2])})
+	}
+	return sets, nil
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// minLanguageAccuracy is the share of testdata/language-corpus.tsv that
+// detectLanguage must label correctly.
+const minLanguageAccuracy = 0.9
+
+func TestDetectLanguageCorpus(t *testing.T) {
+	file, err := os.Open(filepath.Join("testdata", "language-corpus.tsv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	total, correct := make(map[string]int), make(map[string]int)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		lang, text, ok := strings.Cut(line, "\t")
+		if !ok {
+			t.Fatalf("corpus line without a tab: %q", line)
+		}
+		total[lang]++
+		if got := detectLanguage(text); got == lang {
+			correct[lang]++
+		} else {
+			t.Logf("%s detected as %q: %s", lang, got, text)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(total) != len(languageProfiles) {
+		t.Errorf("corpus covers %d languages, want all %d", len(total), len(languageProfiles))
+	}
+	all, right := 0, 0
+	for lang, n := range total {
+		all, right = all+n, right+correct[lang]
+		t.Logf("%s: %d of %d", lang, correct[lang], n)
+	}
+	if accuracy := float64(right) / float64(all); accuracy < minLanguageAccuracy {
+		t.Errorf("accuracy %.2f over %d texts, want at least %.2f", accuracy, all, minLanguageAccuracy)
+	}
+}
+
+func TestDetectLanguageShortText(t *testing.T) {
+	for _, text := range []string{"", "Valve Engineer", "Ingénieur · Lyon"} {
+		if got := detectLanguage(text); got != "" {
+			t.Errorf("detectLanguage(%q) = %q, want too short to tell", text, got)
+		}
+	}
+}
+
+func TestParseKeywordsLang(t *testing.T) {
+	got, err := parseKeywordsLang(`en:"control valve"; de:Regelventil;`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []languageKeywords{{"en", "control valve"}, {"de", "Regelventil"}}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	for _, spec := range []string{`control valve`, `en:""`, `english:"valve"`} {
+		if _, err := parseKeywordsLang(spec); err == nil {
+			t.Errorf("parseKeywordsLang(%q) accepted", spec)
+		}
+	}
+}
+
+func TestLanguageFilter(t *testing.T) {
+	for _, tc := range []struct {
+		c      Candidate
+		strict bool
+		passed bool
+	}{
+		{Candidate{ProfileLanguage: "de"}, false, true},
+		{Candidate{ProfileLanguage: "fr"}, false, false},
+		{Candidate{PageLanguage: "en", ProfileLanguage: "fr"}, false, true}, // The page's own declaration wins.
+		{Candidate{}, false, true},
+		{Candidate{}, true, false},
+	} {
+		if got := languageFilter([]string{"en", "de"}, tc.strict)(tc.c).Passed; got != tc.passed {
+			t.Errorf("page %q, detected %q, strict %v: passed %v, want %v", tc.c.PageLanguage, tc.c.ProfileLanguage, tc.strict, got, tc.passed)
+		}
+	}
+}
+
+// Profile pages declaring their language: Hindi by the html lang attribute,
+// English by JSON-LD, which wins over the attribute.
+const (
+	hindiProfile = `<html lang="hi-IN"><body>
+<h1 class="top-card-layout__title">राहुल शर्मा</h1>
+<p class="core-section-container__content">मैं पुणे में वाल्व इंजीनियर हूँ।</p>
+</body></html>`
+	englishProfile = `<html lang="fr"><head><script type="application/ld+json">
+{"@context":"https://schema.org","@graph":[{"@type":"WebPage","name":"Jane Doe"},
+ {"@type":"Person","name":"Jane Doe","inLanguage":{"@type":"Language","name":"English","alternateName":"en-GB"}}]}
+</script></head><body><h1 class="top-card-layout__title">Jane Doe</h1></body></html>`
+)
+
+func TestPageLanguage(t *testing.T) {
+	tests := []struct {
+		page string
+		want string
+	}{
+		{hindiProfile, "hi"},
+		{englishProfile, "en"},
+		{`<html lang="pt_BR"><body></body></html>`, "pt"},
+		{`<html><head><script type="application/ld+json">{"@type":"Person","inLanguage":"DE"}</script></head></html>`, "de"},
+		{`<html lang="x"><head><script type="application/ld+json">not json</script></head></html>`, ""},
+		{`<html><body></body></html>`, ""},
+	}
+	for _, tt := range tests {
+		if got := pageLanguage(parseHTML(t, tt.page)); got != tt.want {
+			t.Errorf("pageLanguage of %.40q... = %q, want %q", tt.page, got, tt.want)
+		}
+	}
+}
+
+func TestProfileLangFiltersFixtures(t *testing.T) {
+	parse := func(slug, page string) Candidate {
+		return parseProfilePage(context.Background(), &pageFetcher{}, "https://www.linkedin.com/in/"+slug, []byte(page), parseHTML(t, page), profileOptions{})
+	}
+	hindi, english := parse("rahul-sharma", hindiProfile), parse("jane-doe", englishProfile)
+	if hindi.PageLanguage != "hi" || english.PageLanguage != "en" {
+		t.Fatalf("page languages %q and %q, want hi and en", hindi.PageLanguage, english.PageLanguage)
+	}
+	unknown := Candidate{Name: "John Roe"}
+
+	cfg, err := parseFlags([]string{"-profile-lang", "hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(cfg.profileLanguages, []string{"hi"}) {
+		t.Fatalf("-profile-lang hi read as %q", cfg.profileLanguages)
+	}
+	for _, tc := range []struct {
+		c      Candidate
+		strict bool
+		passed bool
+		actual string
+	}{
+		{hindi, false, true, "hi"},
+		{english, false, false, "en"},
+		{unknown, false, true, "unknown"},
+		{unknown, true, false, "unknown"},
+	} {
+		d := languageFilter(cfg.profileLanguages, tc.strict)(tc.c)
+		if d.Passed != tc.passed || d.Actual != tc.actual {
+			t.Errorf("%s, strict %v: %+v, want passed %v with %s", tc.c.Name, tc.strict, d, tc.passed, tc.actual)
+		}
+	}
+
+	if cfg, err := parseFlags([]string{"-profile-lang", "hi,en", "-strict-lang"}); err != nil || !cfg.strictLanguage {
+		t.Errorf("-profile-lang hi,en -strict-lang: %v", err)
+	}
+	for _, args := range [][]string{{"-strict-lang"}, {"-profile-lang", "hindi1"}} {
+		if _, err := parseFlags(args); err == nil {
+			t.Errorf("%q accepted", args)
+		}
+	}
+}
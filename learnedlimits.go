@@ -0,0 +1,165 @@
+package main
+
+import (
+	"log"
+	"math"
+	"sort"
+	"time"
+)
+
+// Parameters of -learned-limits.
+const (
+	learnedShare         = 0.7  // Share of the historically safe rate a run starts at.
+	learnedPercentile    = 0.5  // Percentile of recent runs' rates taken as safe.
+	learnedRecentRuns    = 20   // Most recent matching samples considered.
+	learnedMinSamples    = 3    // Fewer matching samples fall back to the default delays.
+	learnedHourWindow    = 2    // Hours either side of the current one a sample may be from.
+	maxStoredRateSamples = 1000 // Samples kept in the store, oldest dropped first.
+	minRateRequests      = 2    // Requests to a host needed to measure a rate at all.
+)
+
+// rateSample is the request rate one run sustained against one host through
+// proxies of one region, as kept in a -store file. For a run that was
+// blocked, it is the rate in the preBlockWindow before the first block, or
+// since the first request when that was sooner; otherwise it is the rate over
+// the whole run.
+type rateSample struct {
+	Time     time.Time `json:"time"` // First request of the run to the host.
+	Host     string    `json:"host"`
+	Region   string    `json:"region,omitempty"` // Of the proxies used; "" for direct connections.
+	Hour     int       `json:"hour"`             // Local hour of Time, for time-of-day matching.
+	Requests int       `json:"requests"`         // Sent before the first block, or in all.
+	RPM      float64   `json:"rpm"`
+	Blocked  bool      `json:"blocked,omitempty"`
+}
+
+// rateSamples measures a run's outcome timeline per host and proxy region.
+// Hosts with too few requests to measure a rate are left out.
+func rateSamples(records []outcomeRecord) []rateSample {
+	type key struct{ host, region string }
+	groups := make(map[key][]outcomeRecord)
+	var keys []key
+	for _, r := range records {
+		k := key{r.Host, r.Region}
+		if _, ok := groups[k]; !ok {
+			keys = append(keys, k)
+		}
+		groups[k] = append(groups[k], r)
+	}
+	var samples []rateSample
+	for _, k := range keys {
+		group := groups[k]
+		sort.SliceStable(group, func(i, j int) bool { return group[i].Time.Before(group[j].Time) })
+		if len(group) < minRateRequests {
+			continue
+		}
+		first := group[0].Time
+		s := rateSample{Time: first.UTC(), Host: k.host, Region: k.region, Hour: first.Local().Hour()}
+		block := -1
+		for i, r := range group {
+			if r.Outcome == outcomeBlocked {
+				block = i
+				break
+			}
+		}
+		switch {
+		case block >= 0:
+			s.Blocked = true
+			s.Requests = block
+			// A run blocked sooner than preBlockWindow in is measured over
+			// the time it ran.
+			at := group[block].Time
+			from := at.Add(-preBlockWindow)
+			if from.Before(first) {
+				from = first
+			}
+			n := 0
+			for _, r := range group[:block] {
+				if !r.Time.Before(from) {
+					n++
+				}
+			}
+			if at.Sub(from) <= 0 {
+				continue
+			}
+			s.RPM = float64(n) / at.Sub(from).Minutes()
+		default:
+			span := group[len(group)-1].Time.Sub(first)
+			if span <= 0 {
+				continue
+			}
+			s.Requests = len(group)
+			s.RPM = float64(len(group)-1) / span.Minutes()
+		}
+		samples = append(samples, s)
+	}
+	return samples
+}
+
+// percentile returns the p-th percentile of values, 0 <= p <= 1, by the
+// nearest-rank method. values is sorted in place.
+func percentile(values []float64, p float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sort.Float64s(values)
+	rank := int(math.Ceil(p * float64(len(values))))
+	return values[min(max(rank, 1), len(values))-1]
+}
+
+// hourDistance returns how many hours apart two hours of the day are.
+func hourDistance(a, b int) int {
+	d := (a - b + 24) % 24
+	return min(d, 24-d)
+}
+
+// learnedRate returns the request rate, per minute, a limiter of hosts of
+// class using proxies of region should start at: learnedShare of the
+// learnedPercentile of the recent samples from around the hour of now. It
+// returns false, with the number of matching samples, when there are fewer
+// than learnedMinSamples.
+func learnedRate(history []rateSample, class, region string, now time.Time) (float64, int, bool) {
+	var matching []rateSample
+	for _, s := range history {
+		if class != isolationShared && classifyHost(s.Host) != class {
+			continue
+		}
+		if s.Region != region || hourDistance(s.Hour, now.Local().Hour()) > learnedHourWindow {
+			continue
+		}
+		matching = append(matching, s)
+	}
+	if len(matching) < learnedMinSamples {
+		return 0, len(matching), false
+	}
+	sort.SliceStable(matching, func(i, j int) bool { return matching[i].Time.After(matching[j].Time) })
+	matching = matching[:min(len(matching), learnedRecentRuns)]
+	rates := make([]float64, len(matching))
+	for i, s := range matching {
+		rates[i] = s.RPM
+	}
+	safe := percentile(rates, learnedPercentile)
+	if safe <= 0 {
+		return 0, len(matching), false
+	}
+	return learnedShare * safe, len(matching), true
+}
+
+// applyLearnedLimit starts id's limiter at the rate learned from history,
+// adapting from there. Without -rate-adaptive the learned rate is also the
+// fastest the limiter goes, so it only slows down on blocks. With too little
+// history the limiter keeps its default delays.
+func applyLearnedLimit(id *identity, history []rateSample, opts fetcherOptions) {
+	rpm, n, ok := learnedRate(history, id.class, id.proxies.region(), clockNow())
+	if !ok {
+		log.Printf("Only %d earlier runs match the %s hosts at this time of day; using the default delays.", n, id.class)
+		return
+	}
+	start := time.Duration(float64(time.Minute) / rpm)
+	lo, hi := start, defaultAdaptiveMaxDelay
+	if opts.rateAdaptive {
+		lo, hi = opts.adaptiveMinDelay, opts.adaptiveMaxDelay
+	}
+	id.limiter.adaptive = newAdaptiveRate(start, lo, hi)
+	log.Printf("Starting %s hosts at %.1f requests per minute, learned from %d earlier runs.", id.class, float64(time.Minute)/float64(id.limiter.adaptive.currentDelay()), n)
+}
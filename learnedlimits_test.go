@@ -0,0 +1,233 @@
+package main
+
+import (
+	"math"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rateHistory returns samples of host from region at hour, one per rpm, a
+// day apart with the last one newest.
+func rateHistory(host, region string, hour int, rpms ...float64) []rateSample {
+	start := time.Date(2026, 2, 1, hour, 0, 0, 0, time.Local)
+	samples := make([]rateSample, len(rpms))
+	for i, rpm := range rpms {
+		samples[i] = rateSample{Time: start.AddDate(0, 0, i).UTC(), Host: host, Region: region, Hour: hour, Requests: 20, RPM: rpm}
+	}
+	return samples
+}
+
+func TestRateSamples(t *testing.T) {
+	start := time.Date(2026, 3, 2, 14, 0, 0, 0, time.Local)
+	var records []outcomeRecord
+	add := func(host, region string, at time.Duration, outcome string) {
+		records = append(records, outcomeRecord{Host: host, Region: region, Time: start.Add(at), Outcome: outcome})
+	}
+	// Eleven profile requests 6s apart: 10 a minute.
+	for i := 0; i < 11; i++ {
+		add("www.linkedin.com", "IN", time.Duration(i)*6*time.Second, outcomeOK)
+	}
+	// Search requests every 10s, blocked at the fifth: 4 in the 40s before.
+	for i := 0; i < 6; i++ {
+		outcome := outcomeOK
+		if i >= 4 {
+			outcome = outcomeBlocked
+		}
+		add("www.google.com", "", time.Duration(i)*10*time.Second, outcome)
+	}
+	// A request a minute through DE proxies, blocked after 10 minutes: the
+	// rate is measured over the 5 minutes before the block.
+	for i := 0; i <= 10; i++ {
+		outcome := outcomeOK
+		if i == 10 {
+			outcome = outcomeBlocked
+		}
+		add("www.linkedin.com", "DE", time.Duration(i)*time.Minute, outcome)
+	}
+	// One request measures no rate.
+	add("www.bing.com", "", 0, outcomeOK)
+
+	got := rateSamples(records)
+	want := []rateSample{
+		{Host: "www.linkedin.com", Region: "IN", Requests: 11, RPM: 10},
+		{Host: "www.google.com", Requests: 4, RPM: 6, Blocked: true},
+		{Host: "www.linkedin.com", Region: "DE", Requests: 10, RPM: 1, Blocked: true},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("samples %+v, want %d", got, len(want))
+	}
+	for i, s := range got {
+		w := want[i]
+		if s.Host != w.Host || s.Region != w.Region || s.Requests != w.Requests || s.Blocked != w.Blocked || math.Abs(s.RPM-w.RPM) > 1e-9 {
+			t.Errorf("sample %d: %+v, want %+v", i, s, w)
+		}
+		if !s.Time.Equal(start) || s.Time.Location() != time.UTC || s.Hour != 14 {
+			t.Errorf("sample %d at %s, hour %d; want the first request's time in UTC and hour 14", i, s.Time, s.Hour)
+		}
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	tests := []struct {
+		values []float64
+		p      float64
+		want   float64
+	}{
+		{nil, 0.5, 0},
+		{[]float64{7}, 0.5, 7},
+		{[]float64{30, 10, 20}, 0.5, 20},
+		{[]float64{40, 10, 30, 20}, 0.5, 20},
+		{[]float64{40, 10, 30, 20}, 0.75, 30},
+		{[]float64{40, 10, 30, 20}, 0, 10},
+		{[]float64{40, 10, 30, 20}, 1, 40},
+	}
+	for _, tt := range tests {
+		values := append([]float64(nil), tt.values...)
+		if got := percentile(values, tt.p); got != tt.want {
+			t.Errorf("percentile(%v, %v) = %v, want %v", tt.values, tt.p, got, tt.want)
+		}
+	}
+}
+
+func TestHourDistance(t *testing.T) {
+	for _, tt := range []struct{ a, b, want int }{{14, 14, 0}, {14, 16, 2}, {16, 14, 2}, {23, 1, 2}, {0, 12, 12}, {2, 21, 5}} {
+		if got := hourDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("hourDistance(%d, %d) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestLearnedRate(t *testing.T) {
+	now := time.Date(2026, 3, 2, 14, 30, 0, 0, time.Local)
+	profiles := rateHistory("www.linkedin.com", "", 14, 10, 20, 30)
+	join := func(parts ...[]rateSample) []rateSample {
+		var all []rateSample
+		for _, p := range parts {
+			all = append(all, p...)
+		}
+		return all
+	}
+	tests := []struct {
+		name    string
+		history []rateSample
+		class   string
+		region  string
+		want    float64 // 0 when the defaults apply.
+		samples int
+	}{
+		{"no history", nil, hostClassProfile, "", 0, 0},
+		{"too few", profiles[:2], hostClassProfile, "", 0, 2},
+		{"median of three", profiles, hostClassProfile, "", 14, 3},
+		{"nearby hours", join(rateHistory("www.linkedin.com", "", 12, 10), rateHistory("www.linkedin.com", "", 16, 20, 30)), hostClassProfile, "", 14, 3},
+		{"other hours", join(profiles[:2], rateHistory("www.linkedin.com", "", 17, 30)), hostClassProfile, "", 0, 2},
+		{"other region", join(profiles[:2], rateHistory("www.linkedin.com", "IN", 14, 30)), hostClassProfile, "", 0, 2},
+		{"own region", join(profiles, rateHistory("www.linkedin.com", "IN", 14, 40, 50, 60)), hostClassProfile, "IN", 35, 3},
+		{"other class", join(profiles[:2], rateHistory("www.google.com", "", 14, 30)), hostClassProfile, "", 0, 2},
+		{"search class", join(profiles, rateHistory("www.google.com", "", 14, 2, 4, 6)), hostClassSearch, "", 2.8, 3},
+		{"shared class", join(profiles, rateHistory("www.google.com", "", 14, 2, 4, 6)), isolationShared, "", 4.2, 6},
+		{"zero rates", rateHistory("www.linkedin.com", "", 14, 0, 0, 0), hostClassProfile, "", 0, 3},
+		// Of 25 runs, the 5 oldest are too old to count.
+		{"recent runs", rateHistory("www.linkedin.com", "", 14, 100, 100, 100, 100, 100, 10, 10, 10, 10, 10, 10, 10, 10, 10, 10, 10, 20, 20, 20, 20, 20, 20, 20, 20, 20), hostClassProfile, "", 7, 20},
+	}
+	for _, tt := range tests {
+		rpm, n, ok := learnedRate(tt.history, tt.class, tt.region, now)
+		if ok != (tt.want > 0) || math.Abs(rpm-tt.want) > 1e-9 || n != tt.samples {
+			t.Errorf("%s: learned %v from %d samples (%v), want %v from %d", tt.name, rpm, n, ok, tt.want, tt.samples)
+		}
+	}
+}
+
+func TestLearnedLimitsStartLimiters(t *testing.T) {
+	fixedNow = time.Date(2026, 3, 2, 14, 30, 0, 0, time.Local)
+	t.Cleanup(func() { fixedNow = time.Time{} })
+	history := rateHistory("www.linkedin.com", "", 14, 10, 20, 30)
+
+	logged := captureLog(t)
+	opts := fetchFlags(t)
+	opts.learnedLimits, opts.rateHistory = true, history
+	f, err := newHTTPFetcher(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 70% of the median 20 requests a minute: one every 60s/14.
+	rpm := learnedShare * 20
+	want := time.Duration(float64(time.Minute) / rpm)
+	profile := f.identities[hostClassProfile].limiter.adaptive
+	if profile == nil || profile.currentDelay() != want {
+		t.Fatalf("profile limiter %+v, want a delay of %s", profile, want)
+	}
+	// Without -rate-adaptive, blocks slow it down but it never speeds up past
+	// the learned rate.
+	profile.observe(outcomeBlocked)
+	if d := profile.currentDelay(); d != 2*want {
+		t.Errorf("delay %s after a block, want %s", d, 2*want)
+	}
+	for i := 0; i < 3*adaptiveWindow; i++ {
+		profile.observe(outcomeOK)
+	}
+	if d := profile.currentDelay(); d != want {
+		t.Errorf("delay %s after recovering, want the learned %s", d, want)
+	}
+	// The search hosts have no history and keep the default delays.
+	if f.identities[hostClassSearch].limiter.adaptive != nil {
+		t.Error("search limiter changed without history")
+	}
+	if !strings.Contains(logged.String(), "Only 0 earlier runs match the search hosts") || !strings.Contains(logged.String(), "learned from 3 earlier runs") {
+		t.Errorf("log %q, want both limiters explained", logged)
+	}
+
+	// With -rate-adaptive it may speed up to its minimum delay.
+	opts = fetchFlags(t, "-rate-adaptive", "-rate-adaptive-min-delay", "1s")
+	opts.learnedLimits, opts.rateHistory = true, history
+	if f, err = newHTTPFetcher(opts); err != nil {
+		t.Fatal(err)
+	}
+	profile = f.identities[hostClassProfile].limiter.adaptive
+	for i := 0; i < 3*adaptiveWindow; i++ {
+		profile.observe(outcomeOK)
+	}
+	if d := profile.currentDelay(); d >= want {
+		t.Errorf("delay %s after successes, want faster than the learned %s", d, want)
+	}
+
+	if _, err := parseFlags([]string{"-learned-limits"}); err == nil {
+		t.Error("-learned-limits accepted without -store")
+	}
+}
+
+func TestRateSamplesStored(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "candidates.json")
+	fixedNow = time.Date(2026, 3, 2, 14, 30, 0, 0, time.Local)
+	t.Cleanup(func() { fixedNow = time.Time{} })
+	stats = newRunStats()
+	sent := time.Date(2026, 3, 2, 14, 0, 0, 0, time.Local)
+	for i := 0; i < 5; i++ {
+		stats.recordOutcome("https://www.linkedin.com/in/member", "www.linkedin.com", "http://p:1", "IN", sent.Add(time.Duration(i)*15*time.Second), 200, nil)
+	}
+	if _, err := captureStdout(t, func() error { return saveToStore(path, nil, nil, nil) }); err != nil {
+		t.Fatal(err)
+	}
+	store, err := openStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := store.rateSamples()
+	if len(got) != 1 || got[0].Host != "www.linkedin.com" || got[0].Region != "IN" || got[0].Hour != 14 || got[0].RPM != 4 || got[0].Requests != 5 {
+		t.Fatalf("stored samples %+v, want the run's 4 requests a minute", got)
+	}
+
+	// The store keeps only the newest samples.
+	store.addRateSamples(rateHistory("www.google.com", "", 9, make([]float64, maxStoredRateSamples)...))
+	if err := store.save(); err != nil {
+		t.Fatal(err)
+	}
+	if store, err = openStore(path); err != nil {
+		t.Fatal(err)
+	}
+	got = store.rateSamples()
+	if len(got) != maxStoredRateSamples || got[0].Host != "www.google.com" {
+		t.Errorf("%d samples kept, oldest %+v; want %d with the run's dropped", len(got), got[0], maxStoredRateSamples)
+	}
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// profileLocationSelector finds the location line of a public profile's top card.
+const profileLocationSelector = ".top-card-layout__first-subline .top-card__subline-item"
+
+// locationRemote is the City of a "Remote" location.
+const locationRemote = "Remote"
+
+// parsedLocation is a location string split into normalized parts.
+type parsedLocation struct {
+	City    string // Canonical city or metro name, e.g. "Bengaluru".
+	State   string
+	Country string // ISO 3166-1 alpha-2 code, e.g. "IN".
+}
+
+// place is a known city: the names it is written and searched as, its
+// country, and the other places of its metro area.
+type place struct {
+	City    string   // Canonical name, e.g. "Bengaluru".
+	Country string   // ISO 3166-1 alpha-2 code.
+	Names   []string // Spellings searched for the place, the usual one first.
+	Aliases []string // Other lower-case spellings, recognized but not searched.
+	Metro   []string // Canonical names of the satellite places of its metro area.
+}
+
+// places is the one table of known cities, for normalizing locations and
+// for expanding a search to a metro area.
+var places = []place{
+	{City: "Bengaluru", Country: "IN", Names: []string{"Bangalore", "Bengaluru"}, Aliases: []string{"bangalore urban", "bengaluru urban"}},
+	{City: "Mumbai", Country: "IN", Names: []string{"Mumbai", "Bombay", "Navi Mumbai"}, Metro: []string{"Thane"}},
+	{City: "Thane", Country: "IN", Names: []string{"Thane"}, Metro: []string{"Mumbai"}},
+	{City: "Delhi", Country: "IN", Names: []string{"Delhi", "New Delhi"}, Aliases: []string{"ncr", "delhi ncr"}, Metro: []string{"Gurugram", "Noida"}},
+	{City: "Gurugram", Country: "IN", Names: []string{"Gurgaon", "Gurugram"}, Metro: []string{"Delhi"}},
+	{City: "Noida", Country: "IN", Names: []string{"Noida"}, Metro: []string{"Delhi"}},
+	{City: "Chennai", Country: "IN", Names: []string{"Chennai", "Madras"}},
+	{City: "Kolkata", Country: "IN", Names: []string{"Kolkata", "Calcutta"}},
+	{City: "Hyderabad", Country: "IN", Names: []string{"Hyderabad", "Secunderabad"}},
+	{City: "Pune", Country: "IN", Names: []string{"Pune"}, Metro: []string{"Pimpri-Chinchwad"}},
+	{City: "Pimpri-Chinchwad", Country: "IN", Names: []string{"Pimpri-Chinchwad"}, Metro: []string{"Pune"}},
+	{City: "New York", Country: "US", Names: []string{"New York", "NYC"}, Aliases: []string{"new york city"}, Metro: []string{"Brooklyn", "Jersey City"}},
+	{City: "Brooklyn", Country: "US", Names: []string{"Brooklyn"}, Metro: []string{"New York"}},
+	{City: "Jersey City", Country: "US", Names: []string{"Jersey City"}, Metro: []string{"New York"}},
+	{City: "San Francisco", Country: "US", Names: []string{"San Francisco", "Bay Area"}, Aliases: []string{"san francisco bay"}, Metro: []string{"Oakland", "San Jose"}},
+	{City: "Oakland", Country: "US", Names: []string{"Oakland"}, Metro: []string{"San Francisco"}},
+	{City: "San Jose", Country: "US", Names: []string{"San Jose"}, Metro: []string{"San Francisco"}},
+	{City: "Munich", Country: "DE", Names: []string{"Munich", "München"}},
+	{City: "Cologne", Country: "DE", Names: []string{"Cologne", "Köln"}},
+	{City: "London", Country: "GB", Names: []string{"London", "Greater London"}},
+	{City: "Paris", Country: "FR", Names: []string{"Paris"}},
+	{City: "Amsterdam", Country: "NL", Names: []string{"Amsterdam"}},
+}
+
+// placesByName indexes places by the lower-case form of each of their
+// names and aliases.
+var placesByName = func() map[string]*place {
+	index := make(map[string]*place)
+	for i := range places {
+		p := &places[i]
+		for _, name := range p.Names {
+			index[strings.ToLower(name)] = p
+		}
+		for _, alias := range p.Aliases {
+			index[alias] = p
+		}
+	}
+	return index
+}()
+
+// lookupPlace returns the known place called name, in any case.
+func lookupPlace(name string) (*place, bool) {
+	p, ok := placesByName[strings.ToLower(strings.TrimSpace(name))]
+	return p, ok
+}
+
+// countryCodes maps lower-case country names to ISO codes.
+var countryCodes = map[string]string{
+	"india":                    "IN",
+	"united states":            "US",
+	"united states of america": "US",
+	"usa":                      "US",
+	"us":                       "US",
+	"united kingdom":           "GB",
+	"uk":                       "GB",
+	"england":                  "GB",
+	"germany":                  "DE",
+	"deutschland":              "DE",
+	"france":                   "FR",
+	"netherlands":              "NL",
+	"the netherlands":          "NL",
+	"spain":                    "ES",
+	"italy":                    "IT",
+	"ireland":                  "IE",
+	"canada":                   "CA",
+	"singapore":                "SG",
+	"united arab emirates":     "AE",
+	"uae":                      "AE",
+}
+
+// stateCountries maps lower-case states and their abbreviations to the
+// country they identify, for locations that omit the country.
+var stateCountries = map[string]string{
+	"karnataka": "IN", "maharashtra": "IN", "tamil nadu": "IN", "telangana": "IN",
+	"haryana": "IN", "west bengal": "IN", "gujarat": "IN", "kerala": "IN",
+	"uttar pradesh": "IN", "andhra pradesh": "IN",
+	"california": "US", "ca": "US", "new york": "US", "ny": "US", "texas": "US", "tx": "US",
+	"washington": "US", "wa": "US", "massachusetts": "US", "ma": "US", "illinois": "US", "il": "US",
+	"bavaria": "DE", "bayern": "DE", "île-de-france": "FR", "north holland": "NL", "noord-holland": "NL",
+}
+
+// metroPhrasing matches LinkedIn's area phrasings: "Greater X Area",
+// "X Metropolitan Area", "X Area", and "Greater X".
+var metroPhrasing = regexp.MustCompile(`(?i)^(?:greater\s+)?(.+?)(?:\s+metropolitan)?(?:\s+(?:area|region))?$`)
+
+// normalizeCity resolves a city or metro phrasing to its canonical name.
+// Unknown cities are returned trimmed but otherwise unchanged.
+func normalizeCity(name string) string {
+	name = strings.TrimSpace(name)
+	if p, ok := lookupPlace(name); ok {
+		return p.City
+	}
+	if m := metroPhrasing.FindStringSubmatch(name); m != nil {
+		if p, ok := lookupPlace(m[1]); ok {
+			return p.City
+		}
+		return strings.TrimSpace(m[1])
+	}
+	return name
+}
+
+// parseLocation splits "City, State, Country" and its shorter forms into
+// normalized parts. A single token may be a city, a state, or a country. A
+// known city gives the country when the location omits it.
+func parseLocation(raw string) parsedLocation {
+	var loc parsedLocation
+	var parts []string
+	for _, p := range strings.Split(raw, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			parts = append(parts, p)
+		}
+	}
+	if len(parts) == 0 {
+		return loc
+	}
+	if len(parts) == 1 && strings.EqualFold(parts[0], "remote") {
+		loc.City = locationRemote
+		return loc
+	}
+
+	// The last part is the country when it names one.
+	if code, ok := countryCodes[strings.ToLower(parts[len(parts)-1])]; ok {
+		loc.Country = code
+		parts = parts[:len(parts)-1]
+	}
+	switch len(parts) {
+	case 0:
+	case 1:
+		lower := strings.ToLower(parts[0])
+		if _, isPlace := lookupPlace(lower); !isPlace && stateCountries[lower] != "" {
+			code := stateCountries[lower]
+			loc.State = parts[0]
+			if loc.Country == "" {
+				loc.Country = code
+			}
+		} else {
+			loc.City = normalizeCity(parts[0])
+		}
+	default:
+		loc.City = normalizeCity(parts[0])
+		loc.State = parts[1]
+	}
+	if loc.Country == "" && loc.State != "" {
+		loc.Country = stateCountries[strings.ToLower(loc.State)]
+	}
+	if p, ok := lookupPlace(loc.City); ok && loc.Country == "" {
+		loc.Country = p.Country
+	}
+	return loc
+}
+
+// snippetLocationRegex matches an explicit "Location: X" in a result snippet.
+var snippetLocationRegex = regexp.MustCompile(`(?i)\blocation:\s*([^·|\n]+)`)
+
+// extractSnippetLocation returns the location stated in a result snippet:
+// "Location: X", or a leading "City, Country · ..." segment that parses as a
+// known place.
+func extractSnippetLocation(snippet string) string {
+	if m := snippetLocationRegex.FindStringSubmatch(snippet); m != nil {
+		return strings.TrimSpace(m[1])
+	}
+	first, _, found := strings.Cut(snippet, "·")
+	if !found {
+		return ""
+	}
+	first = strings.TrimSpace(first)
+	if parseLocation(first).Country != "" { // Known cities give theirs.
+		return first
+	}
+	return ""
+}
+
+// locationTerms returns the places of a searched location: each term of an
+// OR group such as a relaxed search's "(Delhi OR Gurgaon OR Noida)", or the
+// location itself.
+func locationTerms(location string) []parsedLocation {
+	var terms []parsedLocation
+	for _, term := range strings.Split(strings.Trim(location, "()"), " OR ") {
+		if term = strings.Trim(strings.TrimSpace(term), `"`); term != "" {
+			terms = append(terms, parseLocation(term))
+		}
+	}
+	return terms
+}
+
+// sameArea reports whether a candidate's location c can be the searched
+// place want: the same city, or when either names no city, no contradicting
+// state or country.
+func sameArea(want, c parsedLocation) bool {
+	if want.City != "" && c.City != "" {
+		return strings.EqualFold(c.City, want.City)
+	}
+	return (want.State == "" || c.State == "" || strings.EqualFold(c.State, want.State)) &&
+		(want.Country == "" || c.Country == "" || c.Country == want.Country)
+}
+
+// locationFilter keeps candidates whose location is one of the searched
+// places: any term of an OR group. Candidates without a known location pass,
+// as do those naming only the state or country of a searched place.
+func locationFilter(location string) candidateFilter {
+	wants := locationTerms(location)
+	var expected []string
+	for _, w := range wants {
+		expected = append(expected, firstNonEmpty(w.City, w.State, w.Country))
+	}
+	return func(c Candidate) filterDecision {
+		got := parsedLocation{City: c.City, State: c.State, Country: c.Country}
+		d := filterDecision{Filter: "location", Expected: strings.Join(expected, " or "), Actual: firstNonEmpty(c.City, c.State, c.Country)}
+		if d.Actual == "" {
+			d.Passed = true
+			d.Actual = "unknown"
+			return d
+		}
+		for _, w := range wants {
+			if sameArea(w, got) {
+				d.Passed = true
+				break
+			}
+		}
+		return d
+	}
+}
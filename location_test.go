@@ -0,0 +1,78 @@
+package main
+
+import "testing"
+
+func TestParseLocation(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want parsedLocation
+	}{
+		{"Bangalore", parsedLocation{City: "Bengaluru", Country: "IN"}},
+		{"Bengaluru, Karnataka, India", parsedLocation{City: "Bengaluru", State: "Karnataka", Country: "IN"}},
+		{"Greater Bengaluru Area", parsedLocation{City: "Bengaluru", Country: "IN"}},
+		{"Gurgaon, Haryana", parsedLocation{City: "Gurugram", State: "Haryana", Country: "IN"}},
+		{"Noida", parsedLocation{City: "Noida", Country: "IN"}},
+		{"Thane, Maharashtra, India", parsedLocation{City: "Thane", State: "Maharashtra", Country: "IN"}},
+		{"Delhi NCR", parsedLocation{City: "Delhi", Country: "IN"}},
+		{"New York", parsedLocation{City: "New York", Country: "US"}},
+		{"San Francisco Bay Area", parsedLocation{City: "San Francisco", Country: "US"}},
+		{"München, Bayern, Deutschland", parsedLocation{City: "Munich", State: "Bayern", Country: "DE"}},
+		{"Karnataka", parsedLocation{State: "Karnataka", Country: "IN"}},
+		{"India", parsedLocation{Country: "IN"}},
+		{"Springfield", parsedLocation{City: "Springfield"}},
+		{"Remote", parsedLocation{City: locationRemote}},
+		{"", parsedLocation{}},
+	}
+	for _, tt := range tests {
+		if got := parseLocation(tt.raw); got != tt.want {
+			t.Errorf("parseLocation(%q) = %+v, want %+v", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestLocationFilter(t *testing.T) {
+	relaxedDelhi := `(Delhi OR "New Delhi" OR Gurgaon OR Gurugram OR Noida)`
+	tests := []struct {
+		searched, candidate string
+		want                bool
+	}{
+		{"Bangalore", "Bengaluru, Karnataka, India", true},
+		{"Bangalore", "Greater Bengaluru Area", true},
+		{"Bangalore", "Mumbai, Maharashtra, India", false},
+		{"Bangalore", "", true},                 // Unknown.
+		{"Bangalore", "India", true},            // Only the country, and the same one.
+		{"Bangalore", "Karnataka, India", true}, // Only the state.
+		{"Bangalore", "Germany", false},
+		{"Delhi", "Noida, Uttar Pradesh, India", false}, // Not searched before relaxation.
+		{relaxedDelhi, "Noida, Uttar Pradesh, India", true},
+		{relaxedDelhi, "Gurgaon, Haryana, India", true},
+		{relaxedDelhi, "New Delhi, Delhi, India", true},
+		{relaxedDelhi, "Pune, Maharashtra, India", false},
+		{`(Mumbai OR Bombay OR "Navi Mumbai" OR Thane)`, "Thane, Maharashtra, India", true},
+		{"India", "Bengaluru", true},
+		{"India", "London, England, United Kingdom", false},
+		{"Karnataka", "Bengaluru, Karnataka, India", true},
+		{"Karnataka", "Chennai, Tamil Nadu, India", false},
+	}
+	for _, tt := range tests {
+		loc := parseLocation(tt.candidate)
+		c := Candidate{Location: tt.candidate, City: loc.City, State: loc.State, Country: loc.Country}
+		if d := locationFilter(tt.searched)(c); d.Passed != tt.want {
+			t.Errorf("location filter for %s on %q passed = %v, want %v (%+v)", tt.searched, tt.candidate, d.Passed, tt.want, d)
+		}
+	}
+}
+
+func TestExtractSnippetLocation(t *testing.T) {
+	tests := []struct{ snippet, want string }{
+		{"Location: Pune, India · Process Engineer", "Pune, India"},
+		{"Noida · Valve Engineer at Acme", "Noida"},
+		{"Bengaluru, Karnataka, India · 500+ connections", "Bengaluru, Karnataka, India"},
+		{"Valve design · 10 years", ""},
+	}
+	for _, tt := range tests {
+		if got := extractSnippetLocation(tt.snippet); got != tt.want {
+			t.Errorf("extractSnippetLocation(%q) = %q, want %q", tt.snippet, got, tt.want)
+		}
+	}
+}
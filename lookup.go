@@ -0,0 +1,333 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Weights of the lookup ranking signals; they sum to 1.
+const (
+	lookupNameWeight     = 0.6
+	lookupCompanyWeight  = 0.25
+	lookupLocationWeight = 0.15
+
+	// lookupConfidentScore is the minimum score for a confident match.
+	lookupConfidentScore = 0.75
+	// lookupConfidentMargin is how far the best match must lead the runner-up
+	// before it is declared; common names otherwise stay ambiguous.
+	lookupConfidentMargin = 0.15
+
+	lookupOutputFilename = "linkedin_lookup.csv"
+)
+
+// Values of Candidate.LookupMatch.
+const (
+	lookupMatchTop       = "top"
+	lookupMatchAmbiguous = "ambiguous"
+	lookupMatchAlternate = "alternate"
+)
+
+// LookupRequest identifies a known person whose profile should be found.
+type LookupRequest struct {
+	Name     string
+	Company  string
+	Location string
+}
+
+// String returns a short label for the request, used to tag output rows.
+func (r LookupRequest) String() string {
+	label := r.Name
+	if r.Company != "" {
+		label += " @ " + r.Company
+	}
+	return label
+}
+
+// lookupMatch is a search result scored against a LookupRequest.
+type lookupMatch struct {
+	Candidate     Candidate
+	NameScore     float64
+	CompanyMatch  bool
+	LocationMatch bool
+	Score         float64
+}
+
+// buildLookupURL builds a tightly quoted Google query for a known person.
+func buildLookupURL(r LookupRequest) string {
+	terms := []string{"site:linkedin.com/in", quoteTerm(r.Name)}
+	if r.Company != "" {
+		terms = append(terms, quoteTerm(r.Company))
+	}
+	if r.Location != "" {
+		terms = append(terms, quoteTerm(r.Location))
+	}
+	params := url.Values{}
+	params.Add("q", strings.Join(terms, " "))
+	return googleSearchURL() + "?" + params.Encode()
+}
+
+// quoteTerm wraps a term in double quotes, dropping any quotes inside it.
+func quoteTerm(term string) string {
+	return `"` + strings.ReplaceAll(strings.TrimSpace(term), `"`, "") + `"`
+}
+
+// nameTokens splits a name into lower-case alphanumeric tokens.
+func nameTokens(name string) []string {
+	return strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// tokenSetSimilarity compares two names as sets of tokens, ignoring order and
+// repeated tokens. It returns 2|A∩B| / (|A|+|B|), from 0 to 1.
+func tokenSetSimilarity(a, b string) float64 {
+	setA := make(map[string]bool)
+	for _, t := range nameTokens(a) {
+		setA[t] = true
+	}
+	setB := make(map[string]bool)
+	for _, t := range nameTokens(b) {
+		setB[t] = true
+	}
+	if len(setA) == 0 || len(setB) == 0 {
+		return 0
+	}
+	common := 0
+	for t := range setA {
+		if setB[t] {
+			common++
+		}
+	}
+	return 2 * float64(common) / float64(len(setA)+len(setB))
+}
+
+// resultName returns the name shown for a result, falling back to the first
+// segment of the result title ("Name - Title - Company | LinkedIn").
+func resultName(c Candidate) string {
+	if c.Name != "" {
+		return c.Name
+	}
+	title := c.ResultTitle
+	if i := strings.Index(title, "|"); i >= 0 {
+		title = title[:i]
+	}
+	if i := strings.Index(title, " - "); i >= 0 {
+		title = title[:i]
+	}
+	return strings.TrimSpace(title)
+}
+
+// containsFold reports whether text contains term, ignoring case.
+func containsFold(text, term string) bool {
+	term = strings.TrimSpace(term)
+	return term != "" && strings.Contains(strings.ToLower(text), strings.ToLower(term))
+}
+
+// rankLookupResults scores each candidate against the request and returns
+// them best first. A request without a company or location gives full credit
+// for that signal, so the name decides.
+func rankLookupResults(r LookupRequest, candidates []Candidate) []lookupMatch {
+	matches := make([]lookupMatch, 0, len(candidates))
+	for _, c := range candidates {
+		text := c.ResultTitle + " " + c.Snippet
+		m := lookupMatch{
+			Candidate:     c,
+			NameScore:     tokenSetSimilarity(r.Name, resultName(c)),
+			CompanyMatch:  r.Company == "" || containsFold(text, r.Company),
+			LocationMatch: r.Location == "" || containsFold(text, r.Location),
+		}
+		m.Score = lookupNameWeight * m.NameScore
+		if m.CompanyMatch {
+			m.Score += lookupCompanyWeight
+		}
+		if m.LocationMatch {
+			m.Score += lookupLocationWeight
+		}
+		matches = append(matches, m)
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Score > matches[j].Score })
+	return matches
+}
+
+// confidentMatch reports whether the best ranked result is clearly the
+// requested person: strong enough on its own and well ahead of the runner-up.
+func confidentMatch(matches []lookupMatch) bool {
+	if len(matches) == 0 || matches[0].Score < lookupConfidentScore {
+		return false
+	}
+	return len(matches) == 1 || matches[0].Score-matches[1].Score >= lookupConfidentMargin
+}
+
+// lookupOne searches for a single person and returns the top match, enriched
+// from its profile when confident, followed by up to alternates runners-up.
+func lookupOne(ctx context.Context, f Fetcher, r LookupRequest, alternates int) ([]Candidate, error) {
+	searchURL := buildLookupURL(r)
+	fmt.Printf("Looking up %s with URL: %s\n", r, searchURL)
+
+	candidates, err := scrapeResultsPage(ctx, f, searchURL, nil, scrapeGoogleSearchResults)
+	if err != nil {
+		return nil, err
+	}
+	matches := rankLookupResults(r, candidates)
+	if len(matches) == 0 {
+		log.Printf("No results for %s.", r)
+		return nil, nil
+	}
+
+	confident := confidentMatch(matches)
+	if !confident {
+		log.Printf("No confident match for %s (best score %.2f).", r, matches[0].Score)
+	}
+
+	var results []Candidate
+	for i, m := range matches {
+		if i > alternates {
+			break
+		}
+		c := m.Candidate
+		c.Job = r.String()
+		c.LookupScore = m.Score
+		switch {
+		case i > 0:
+			c.LookupMatch = lookupMatchAlternate
+		case confident:
+			c.LookupMatch = lookupMatchTop
+		default:
+			c.LookupMatch = lookupMatchAmbiguous
+		}
+		results = append(results, c)
+	}
+
+	// Only a confident top match is worth a profile request.
+	if confident {
+		if err := enrichCandidates(ctx, f, "", results[:1], profileOptions{}); err != nil {
+			return results, err
+		}
+	}
+	return results, nil
+}
+
+// readLookupRequests reads name, company, and location rows from a CSV file
+// with a header row. Only the name column is required.
+func readLookupRequests(filename string) ([]LookupRequest, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open batch file: %w", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read batch header: %w", err)
+	}
+	index := make(map[string]int)
+	for i, h := range header {
+		index[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	if _, ok := index["name"]; !ok {
+		return nil, errors.New("batch file has no name column")
+	}
+	field := func(row []string, key string) string {
+		if i, ok := index[key]; ok && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+
+	var requests []LookupRequest
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read batch row: %w", err)
+		}
+		r := LookupRequest{Name: field(row, "name"), Company: field(row, "company"), Location: field(row, "location")}
+		if r.Name == "" {
+			continue
+		}
+		requests = append(requests, r)
+	}
+	return requests, nil
+}
+
+// lookupColumns are the default CSV columns for lookup output.
+const lookupColumns = "job,lookup_match,lookup_score,name,profile_url,email,phone"
+
+// runLookupCommand implements `profilesearch lookup`.
+func runLookupCommand(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("lookup", flag.ExitOnError)
+	var r LookupRequest
+	fs.StringVar(&r.Name, "name", "", "full name of the person to find")
+	fs.StringVar(&r.Company, "company", "", "the person's company")
+	fs.StringVar(&r.Location, "location", "", "the person's location")
+	batch := fs.String("batch", "", "CSV file with name, company, and location columns to look up")
+	alternates := fs.Int("alternates", 3, "number of runner-up results to include")
+	output := fs.String("output", lookupOutputFilename, "CSV output filename")
+	columnList := fs.String("columns", lookupColumns, "comma-separated CSV columns to write")
+	fs.Var(&googleDomain, "google-domain", "Google domain to search, such as google.co.in")
+	var fetchOpts fetcherOptions
+	addFetcherFlags(fs, &fetchOpts)
+	fs.Parse(args)
+
+	f, err := newHTTPFetcher(fetchOpts)
+	if err != nil {
+		return err
+	}
+	defer f.close()
+
+	columns, err := selectCSVColumns(*columnList)
+	if err != nil {
+		return fmt.Errorf("invalid -columns: %w", err)
+	}
+
+	var requests []LookupRequest
+	switch {
+	case *batch != "":
+		if requests, err = readLookupRequests(*batch); err != nil {
+			return err
+		}
+	case r.Name != "":
+		requests = []LookupRequest{r}
+	default:
+		return errors.New("lookup needs -name or -batch")
+	}
+
+	var all []Candidate
+	for _, req := range requests {
+		if ctx.Err() != nil {
+			break
+		}
+		results, err := lookupOne(ctx, f, req, *alternates)
+		all = append(all, results...)
+		if err != nil {
+			log.Printf("Lookup for %s failed: %v", req, err)
+			if stopsRun(err) {
+				break
+			}
+		}
+	}
+
+	if len(all) == 0 {
+		log.Println("No candidates found.")
+		return nil
+	}
+	if err := writeToCSV(all, *output, columns, nil, csvFormat{}); err != nil {
+		return err
+	}
+	fmt.Printf("Successfully wrote %d candidates to %s\n", len(all), *output)
+	return nil
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTokenSetSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{"Jane Doe", "jane doe", 1},
+		{"Doe, Jane", "Jane Doe", 1},
+		{"Jane Doe", "Jane A. Doe", 0.8},
+		{"Jane Doe", "John Smith", 0},
+		{"Jane Jane Doe", "Jane Doe", 1},
+		{"", "Jane Doe", 0},
+	}
+	for _, tt := range tests {
+		if got := tokenSetSimilarity(tt.a, tt.b); got != tt.want {
+			t.Errorf("tokenSetSimilarity(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestResultName(t *testing.T) {
+	tests := []struct {
+		c    Candidate
+		want string
+	}{
+		{Candidate{Name: "Jane Doe", ResultTitle: "Other - Title"}, "Jane Doe"},
+		{Candidate{ResultTitle: "Jane Doe - Valve Engineer - Acme | LinkedIn"}, "Jane Doe"},
+		{Candidate{ResultTitle: "Jane Doe | LinkedIn"}, "Jane Doe"},
+	}
+	for _, tt := range tests {
+		if got := resultName(tt.c); got != tt.want {
+			t.Errorf("resultName(%+v) = %q, want %q", tt.c, got, tt.want)
+		}
+	}
+}
+
+func TestRankLookupResults(t *testing.T) {
+	r := LookupRequest{Name: "Jane Doe", Company: "Acme Valves", Location: "Pune"}
+	candidates := []Candidate{
+		{Name: "Jane Doe", Snippet: "Engineer at Bosch, Mumbai"},
+		{Name: "Jane Doe", Snippet: "Valve engineer at Acme Valves, Pune"},
+		{Name: "John Doe", Snippet: "Acme Valves, Pune"},
+	}
+	matches := rankLookupResults(r, candidates)
+	if matches[0].Candidate.Snippet != candidates[1].Snippet {
+		t.Fatalf("best match %+v, want the one at Acme Valves in Pune", matches[0].Candidate)
+	}
+	if matches[0].Score != 1 {
+		t.Errorf("best score %v, want 1", matches[0].Score)
+	}
+	if !confidentMatch(matches) {
+		t.Error("a full match well ahead of the rest is not confident")
+	}
+
+	// Two people of the same name and no company to tell them apart.
+	common := rankLookupResults(LookupRequest{Name: "Jane Doe"}, candidates[:2])
+	if confidentMatch(common) {
+		t.Error("two equal matches of a common name are confident")
+	}
+	if confidentMatch(nil) {
+		t.Error("no results are a confident match")
+	}
+	if weak := rankLookupResults(LookupRequest{Name: "Jane Doe", Company: "Siemens", Location: "Delhi"}, candidates[:1]); confidentMatch(weak) {
+		t.Errorf("a lone result at another company and place (score %v) is confident", weak[0].Score)
+	}
+}
+
+func TestBuildLookupURLQuotesTerms(t *testing.T) {
+	u, err := url.Parse(buildLookupURL(LookupRequest{Name: `Jane "JD" Doe`, Company: "Acme Valves"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := u.Query().Get("q"), `site:linkedin.com/in "Jane JD Doe" "Acme Valves"`; got != want {
+		t.Errorf("query %q, want %q", got, want)
+	}
+}
+
+func TestReadLookupRequests(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "people.csv")
+	content := "Location,Name,Company\nPune,Jane Doe,Acme\n,,Nobody\nMumbai,John Roe\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	requests, err := readLookupRequests(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []LookupRequest{{"Jane Doe", "Acme", "Pune"}, {"John Roe", "", "Mumbai"}}
+	if len(requests) != len(want) || requests[0] != want[0] || requests[1] != want[1] {
+		t.Errorf("requests %+v, want %+v", requests, want)
+	}
+
+	if err := os.WriteFile(path, []byte("company\nAcme\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readLookupRequests(path); err == nil {
+		t.Error("a batch file without a name column was read")
+	}
+}
+
+func TestLookupEnrichesConfidentMatchOnly(t *testing.T) {
+	stats = newRunStats()
+	web, addr := startFakeWeb(t, fakeRoster(3))
+	output := filepath.Join(t.TempDir(), "lookup.csv")
+	if err := runLookupCommand(context.Background(), []string{"-fake-web", addr, "-name", "Member 2", "-alternates", "1", "-output", output}); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	rows, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A header, the top match, and one alternate.
+	if len(rows) != 3 {
+		t.Fatalf("lookup wrote %d rows, want 3:\n%q", len(rows), rows)
+	}
+	if rows[1][1] != lookupMatchTop || rows[1][4] != "https://www.linkedin.com/in/member-2" {
+		t.Errorf("first row %q, want the top match member-2", rows[1])
+	}
+	if rows[2][1] != lookupMatchAlternate {
+		t.Errorf("second row %q, want an alternate", rows[2])
+	}
+	if got := web.requests("profile"); got != 1 {
+		t.Errorf("%d profiles fetched, want only the top match's", got)
+	}
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// metricsContentType is the Prometheus text exposition format.
+const metricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// startMetricsServer serves the run's metrics on addr at /metrics, in the
+// Prometheus text format, until the returned stop is called. The stages of
+// stalls, when not nil, give the queue depths.
+func startMetricsServer(addr string, stalls *stallWatchdog) (stop func(), err error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for -metrics-addr: %w", err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metricsHandler(stalls))
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := srv.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("Metrics server failed: %v", err)
+		}
+	}()
+	log.Printf("Serving metrics on http://%s/metrics", listener.Addr())
+	return func() { srv.Close() }, nil
+}
+
+// metricsHandler serves the metrics of the run so far.
+func metricsHandler(stalls *stallWatchdog) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var snaps []stageSnapshot
+		if stalls != nil {
+			now := time.Now()
+			for _, s := range stalls.stages {
+				snaps = append(snaps, s.snapshot(now))
+			}
+		}
+		w.Header().Set("Content-Type", metricsContentType)
+		writeMetrics(w, stats.outcomes(), snaps, runtime.NumGoroutine())
+	})
+}
+
+// writeMetrics writes the request counts and the latencyPercentiles of each
+// request phase, per host and per proxy, from records, and the counters and
+// queue depth of each stage in snaps.
+func writeMetrics(w io.Writer, records []outcomeRecord, snaps []stageSnapshot, goroutines int) error {
+	m := &metricsWriter{w: bufio.NewWriter(w)}
+
+	requests := make(map[[2]string]int)
+	for _, r := range records {
+		requests[[2]string{r.Host, r.Outcome}]++
+	}
+	m.family("profilesearch_requests_total", "counter", "Requests sent, by target host and outcome.")
+	for _, key := range sortedPairs(requests) {
+		m.sample("profilesearch_requests_total", float64(requests[key]), "host", key[0], "outcome", key[1])
+	}
+
+	byHost := timingsBy(records, func(r outcomeRecord) string { return r.Host })
+	m.phaseSummary("profilesearch_host_request_phase_seconds", "Duration of each request phase, by target host.", "host", byHost)
+	byProxy := timingsBy(records, func(r outcomeRecord) string {
+		// The proxy's credentials stay out of the label.
+		return firstNonEmpty(redactURLCredentials(r.Proxy), "direct")
+	})
+	m.phaseSummary("profilesearch_proxy_request_phase_seconds", "Duration of each request phase, by proxy, or direct.", "proxy", byProxy)
+
+	if len(snaps) > 0 {
+		m.family("profilesearch_stage_items_in_total", "counter", "Items that went into each pipeline stage.")
+		for _, s := range snaps {
+			m.sample("profilesearch_stage_items_in_total", float64(s.In), "stage", s.Name)
+		}
+		m.family("profilesearch_stage_items_out_total", "counter", "Items that came out of each pipeline stage.")
+		for _, s := range snaps {
+			m.sample("profilesearch_stage_items_out_total", float64(s.Out), "stage", s.Name)
+		}
+		m.family("profilesearch_stage_queue_depth", "gauge", "Items in each pipeline stage that have not come out.")
+		for _, s := range snaps {
+			m.sample("profilesearch_stage_queue_depth", float64(s.pending()), "stage", s.Name)
+		}
+		m.family("profilesearch_stage_last_progress_timestamp_seconds", "gauge", "When each pipeline stage last made progress, in Unix time.")
+		for _, s := range snaps {
+			m.sample("profilesearch_stage_last_progress_timestamp_seconds", float64(s.LastProgress.UnixNano())/1e9, "stage", s.Name)
+		}
+		m.family("profilesearch_stage_oldest_item_age_seconds", "gauge", "How long the oldest item in flight in each pipeline stage has been, or 0.")
+		for _, s := range snaps {
+			age := time.Duration(0)
+			if len(s.InFlight) > 0 {
+				age = s.InFlight[0].Age
+			}
+			m.sample("profilesearch_stage_oldest_item_age_seconds", age.Seconds(), "stage", s.Name)
+		}
+	}
+
+	m.family("profilesearch_goroutines", "gauge", "Goroutines of the run.")
+	m.sample("profilesearch_goroutines", float64(goroutines))
+	if m.err != nil {
+		return m.err
+	}
+	return m.w.Flush()
+}
+
+// timingsBy groups the timings of records by key.
+func timingsBy(records []outcomeRecord, key func(outcomeRecord) string) map[string][]requestTiming {
+	groups := make(map[string][]requestTiming)
+	for _, r := range records {
+		if r.Timing != nil {
+			groups[key(r)] = append(groups[key(r)], *r.Timing)
+		}
+	}
+	return groups
+}
+
+// phaseValues returns the durations, in nanoseconds, of a phase in timings,
+// or of the total for a nil phase. Requests the phase did not happen in are
+// left out.
+func phaseValues(timings []requestTiming, phase func(requestTiming) *time.Duration) []float64 {
+	var values []float64
+	for _, t := range timings {
+		switch {
+		case phase == nil:
+			values = append(values, float64(t.Total))
+		case phase(t) != nil:
+			values = append(values, float64(*phase(t)))
+		}
+	}
+	return values
+}
+
+// metricsWriter writes samples in the Prometheus text format, keeping the
+// first error.
+type metricsWriter struct {
+	w   *bufio.Writer
+	err error
+}
+
+// family writes the HELP and TYPE lines opening a metric family.
+func (m *metricsWriter) family(name, kind, help string) {
+	m.printf("# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// sample writes a sample of name with labels given as name/value pairs.
+func (m *metricsWriter) sample(name string, value float64, labels ...string) {
+	var b strings.Builder
+	b.WriteString(name)
+	if len(labels) > 0 {
+		b.WriteByte('{')
+		for i := 0; i < len(labels); i += 2 {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(labels[i] + `="` + escapeLabelValue(labels[i+1]) + `"`)
+		}
+		b.WriteByte('}')
+	}
+	m.printf("%s %s\n", b.String(), strconv.FormatFloat(value, 'g', -1, 64))
+}
+
+// phaseSummary writes a summary family of the latencyPercentiles, sum, and
+// count of each phase, and the total, of the timings of each group, labeled
+// with label.
+func (m *metricsWriter) phaseSummary(name, help, label string, groups map[string][]requestTiming) {
+	m.family(name, "summary", help)
+	keys := make([]string, 0, len(groups))
+	for key := range groups {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	phases := append([]struct {
+		name  string
+		value func(t requestTiming) *time.Duration
+	}{{"total", nil}}, timingPhases...)
+	for _, key := range keys {
+		for _, phase := range phases {
+			values := phaseValues(groups[key], phase.value)
+			if len(values) == 0 {
+				// A phase that never happened, as DNS behind a proxy, has
+				// no samples rather than zeros.
+				continue
+			}
+			sum := 0.0
+			for _, v := range values {
+				sum += v
+			}
+			for _, p := range latencyPercentiles {
+				m.sample(name, percentile(values, p)/1e9, label, key, "phase", phase.name, "quantile", strconv.FormatFloat(p, 'g', -1, 64))
+			}
+			m.sample(name+"_sum", sum/1e9, label, key, "phase", phase.name)
+			m.sample(name+"_count", float64(len(values)), label, key, "phase", phase.name)
+		}
+	}
+}
+
+func (m *metricsWriter) printf(format string, args ...any) {
+	if m.err == nil {
+		_, m.err = fmt.Fprintf(m.w, format, args...)
+	}
+}
+
+// escapeLabelValue escapes a label value for the text format.
+func escapeLabelValue(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+// sortedPairs returns the keys of m in order.
+func sortedPairs(m map[[2]string]int) [][2]string {
+	keys := make([][2]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i][0] != keys[j][0] {
+			return keys[i][0] < keys[j][0]
+		}
+		return keys[i][1] < keys[j][1]
+	})
+	return keys
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Delays the slow server injects into the phases of each request.
+const (
+	ttfbDelay = 150 * time.Millisecond // Before the response headers.
+	bodyDelay = 100 * time.Millisecond // Between two halves of the body.
+
+	// minBody is the least body phase a client measures: it sees the
+	// headers a little after the server flushes them.
+	minBody = bodyDelay - 10*time.Millisecond
+)
+
+// startSlowServer starts a server, also usable as a proxy that answers every
+// request itself, delaying each response by ttfbDelay and the second half of
+// its body by bodyDelay. It returns the server's URL on localhost, so that
+// reaching it takes a DNS lookup.
+func startSlowServer(t *testing.T) string {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(ttfbDelay)
+		io.WriteString(w, "<html><body>")
+		w.(http.Flusher).Flush()
+		time.Sleep(bodyDelay)
+		io.WriteString(w, "</body></html>")
+	}))
+	t.Cleanup(srv.Close)
+	return strings.Replace(srv.URL, "127.0.0.1", "localhost", 1)
+}
+
+// scrapeMetrics returns the samples /metrics serves, by series, with the
+// stages of stalls.
+func scrapeMetrics(t *testing.T, stalls *stallWatchdog) map[string]float64 {
+	t.Helper()
+	srv := httptest.NewServer(metricsHandler(stalls))
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if got := resp.Header.Get("Content-Type"); got != metricsContentType {
+		t.Errorf("Content-Type %q, want %q", got, metricsContentType)
+	}
+	samples := make(map[string]float64)
+	for sc := bufio.NewScanner(resp.Body); sc.Scan(); {
+		line := sc.Text()
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.LastIndexByte(line, ' ')
+		value, err := strconv.ParseFloat(line[i+1:], 64)
+		if err != nil {
+			t.Fatalf("bad sample line %q: %v", line, err)
+		}
+		samples[line[:i]] = value
+	}
+	return samples
+}
+
+// directFetcher returns a fetcher without proxies, delays, or robots.txt
+// checks.
+func directFetcher(t *testing.T) *httpFetcher {
+	t.Helper()
+	identities, err := newIdentities(isolationShared, nil, 0, 0, rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &httpFetcher{identities: identities, clients: newClientManager()}
+}
+
+func TestMetricsPhasePercentilesPerHostAndProxy(t *testing.T) {
+	stats = newRunStats()
+	target := startSlowServer(t)
+	proxy := strings.Replace(startSlowServer(t), "http://", "http://user:secret@", 1)
+
+	direct := directFetcher(t)
+	proxied := proxiedFetcher(t, proxy)
+	for i := 0; i < 2; i++ {
+		if _, err := direct.Fetch(context.Background(), target+"/page"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := proxied.Fetch(context.Background(), "http://www.example.com/page"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	samples := scrapeMetrics(t, nil)
+	host := strings.TrimPrefix(target, "http://")
+	redacted := "http://REDACTED@" + strings.TrimPrefix(proxy, "http://user:secret@")
+	atLeast := func(series string, want time.Duration) {
+		t.Helper()
+		got, ok := samples[series]
+		if !ok {
+			t.Errorf("no sample %s", series)
+			return
+		}
+		if got < want.Seconds() {
+			t.Errorf("%s = %vs, want at least %v", series, got, want)
+		}
+	}
+	for _, q := range []string{"0.5", "0.9", "0.99"} {
+		atLeast(`profilesearch_host_request_phase_seconds{host="`+host+`",phase="ttfb",quantile="`+q+`"}`, ttfbDelay)
+		atLeast(`profilesearch_host_request_phase_seconds{host="`+host+`",phase="body",quantile="`+q+`"}`, minBody)
+		atLeast(`profilesearch_host_request_phase_seconds{host="www.example.com",phase="ttfb",quantile="`+q+`"}`, ttfbDelay)
+		atLeast(`profilesearch_proxy_request_phase_seconds{proxy="`+redacted+`",phase="total",quantile="`+q+`"}`, ttfbDelay+bodyDelay)
+		atLeast(`profilesearch_proxy_request_phase_seconds{proxy="direct",phase="body",quantile="`+q+`"}`, minBody)
+	}
+
+	if got := samples[`profilesearch_requests_total{host="`+host+`",outcome="ok"}`]; got != 2 {
+		t.Errorf("%v requests counted to %s, want 2", got, host)
+	}
+	if got := samples[`profilesearch_proxy_request_phase_seconds_count{proxy="direct",phase="dns"}`]; got != 1 {
+		t.Errorf("%v direct DNS lookups counted, want 1 for two requests on one connection", got)
+	}
+	// Behind the proxy, DNS is not applicable: the series is absent, not 0.
+	if _, ok := samples[`profilesearch_proxy_request_phase_seconds_count{proxy="`+redacted+`",phase="dns"}`]; ok {
+		t.Error("proxied requests have DNS samples")
+	}
+	// A reused connection has no connect phase.
+	if got := samples[`profilesearch_proxy_request_phase_seconds_count{proxy="direct",phase="connect"}`]; got != 1 {
+		t.Errorf("%v direct connects counted, want 1", got)
+	}
+	for series := range samples {
+		if strings.Contains(series, "secret") {
+			t.Errorf("series %s holds the proxy password", series)
+		}
+	}
+}
+
+func TestRequestTimingRecordedPerPhase(t *testing.T) {
+	stats = newRunStats()
+	proxy := startSlowServer(t)
+	f := proxiedFetcher(t, proxy)
+	if _, err := f.Fetch(context.Background(), "http://www.example.com/page"); err != nil {
+		t.Fatal(err)
+	}
+	f = directFetcher(t)
+	if _, err := f.Fetch(context.Background(), startSlowServer(t)+"/page"); err != nil {
+		t.Fatal(err)
+	}
+
+	records := stats.outcomes()
+	if len(records) != 2 || records[0].Timing == nil || records[1].Timing == nil {
+		t.Fatalf("outcomes %+v, want two with timings", records)
+	}
+	proxied, direct := *records[0].Timing, *records[1].Timing
+	if proxied.DNS != nil {
+		t.Errorf("proxied request has DNS time %v, want none", *proxied.DNS)
+	}
+	if direct.DNS == nil {
+		t.Error("direct request to localhost has no DNS time")
+	}
+	for _, timing := range []requestTiming{proxied, direct} {
+		if timing.Connect == nil || timing.TLS != nil {
+			t.Errorf("timing %s, want a connect phase and no TLS", timing)
+		}
+		if timing.TTFB == nil || *timing.TTFB < ttfbDelay || timing.Body == nil || *timing.Body < minBody {
+			t.Errorf("timing %s, want ttfb of at least %v and body of at least %v", timing, ttfbDelay, minBody)
+		}
+		if timing.Total < ttfbDelay+bodyDelay {
+			t.Errorf("total %v, want at least %v", timing.Total, ttfbDelay+bodyDelay)
+		}
+	}
+}
+
+func TestSlowRequestThresholdWarns(t *testing.T) {
+	stats = newRunStats()
+	logged := captureLog(t)
+
+	proxy := strings.Replace(startSlowServer(t), "http://", "http://user:secret@", 1)
+	f := proxiedFetcher(t, proxy)
+	f.slowRequest = ttfbDelay + bodyDelay + time.Second
+	if _, err := f.Fetch(context.Background(), "http://www.example.com/fast"); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(logged.String(), "WARN") {
+		t.Errorf("request under the threshold warned:\n%s", logged.String())
+	}
+
+	f.slowRequest = ttfbDelay
+	if _, err := f.Fetch(context.Background(), "http://www.example.com/slow"); err != nil {
+		t.Fatal(err)
+	}
+	out := logged.String()
+	if !strings.Contains(out, "WARN: slow request to http://www.example.com/slow through http://REDACTED@") || !strings.Contains(out, "(dns n/a, ") {
+		t.Errorf("log lacks the slow request's breakdown:\n%s", out)
+	}
+	if strings.Contains(out, "secret") {
+		t.Errorf("warning holds the proxy password:\n%s", out)
+	}
+}
+
+func TestMetricsLabelEscaping(t *testing.T) {
+	var b bytes.Buffer
+	m := &metricsWriter{w: bufio.NewWriter(&b)}
+	m.sample("x", 1.5, "label", "a\"b\\c\nd")
+	m.w.Flush()
+	if got, want := b.String(), "x{label=\"a\\\"b\\\\c\\nd\"} 1.5\n"; got != want {
+		t.Errorf("sample %q, want %q", got, want)
+	}
+}
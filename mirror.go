@@ -0,0 +1,240 @@
+package main
+
+import (
+	"hash/fnv"
+	"net/url"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// defaultMirrorThreshold is the snippet similarity above which two results
+// are taken for one profile and a mirror of it, unless -mirror-threshold is
+// set.
+const defaultMirrorThreshold = 0.9
+
+// Shingling of snippets for mirror detection.
+const (
+	mirrorShingleWords = 3 // Words per shingle.
+	mirrorMinShingles  = 6 // Shorter snippets say too little to tell people apart.
+	mirrorBands        = 8 // Simhash bands; two snippets are compared when one band matches.
+)
+
+// snippetShingles returns the set of word shingles of a snippet, normalized
+// so that case, punctuation, and Google's ellipses do not count.
+func snippetShingles(snippet string) map[string]bool {
+	words := strings.FieldsFunc(strings.ToLower(snippet), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	shingles := make(map[string]bool)
+	for i := 0; i+mirrorShingleWords <= len(words); i++ {
+		shingles[strings.Join(words[i:i+mirrorShingleWords], " ")] = true
+	}
+	return shingles
+}
+
+// jaccard returns the share of shingles a and b have in common.
+func jaccard(a, b map[string]bool) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 1
+	}
+	common := 0
+	for s := range a {
+		if b[s] {
+			common++
+		}
+	}
+	return float64(common) / float64(len(a)+len(b)-common)
+}
+
+// simhash returns the 64-bit simhash of a shingle set: near-identical sets
+// get hashes differing in few bits.
+func simhash(shingles map[string]bool) uint64 {
+	var weights [64]int
+	for s := range shingles {
+		h := fnv.New64a()
+		h.Write([]byte(s))
+		sum := h.Sum64()
+		for bit := 0; bit < 64; bit++ {
+			if sum&(1<<bit) != 0 {
+				weights[bit]++
+			} else {
+				weights[bit]--
+			}
+		}
+	}
+	var hash uint64
+	for bit, w := range weights {
+		if w > 0 {
+			hash |= 1 << bit
+		}
+	}
+	return hash
+}
+
+// simhashBand returns band i of a simhash, tagged with i so that equal
+// values of different bands do not collide.
+func simhashBand(hash uint64, i int) uint64 {
+	width := 64 / mirrorBands
+	return uint64(i)<<56 | (hash>>(i*width))&(1<<width-1)
+}
+
+// mirrorPairs returns the pairs of candidates, by index, whose snippets are
+// at least threshold similar. Only candidates sharing a simhash band are
+// compared, so a few thousand candidates take far fewer than n² comparisons;
+// near-duplicates differ in few bits and so share a band.
+func mirrorPairs(candidates []Candidate, threshold float64) [][2]int {
+	shingles := make([]map[string]bool, len(candidates))
+	buckets := make(map[uint64][]int)
+	for i, c := range candidates {
+		shingles[i] = snippetShingles(c.Snippet)
+		if len(shingles[i]) < mirrorMinShingles {
+			continue
+		}
+		hash := simhash(shingles[i])
+		for band := 0; band < mirrorBands; band++ {
+			key := simhashBand(hash, band)
+			buckets[key] = append(buckets[key], i)
+		}
+	}
+	compared := make(map[[2]int]bool)
+	var pairs [][2]int
+	for _, bucket := range buckets {
+		for x := 0; x < len(bucket); x++ {
+			for y := x + 1; y < len(bucket); y++ {
+				pair := [2]int{bucket[x], bucket[y]}
+				if compared[pair] {
+					continue
+				}
+				compared[pair] = true
+				a, b := candidates[pair[0]], candidates[pair[1]]
+				if profileHost(a.ProfileURL) != profileHost(b.ProfileURL) && namesAgree(a.Name, b.Name) && jaccard(shingles[pair[0]], shingles[pair[1]]) >= threshold {
+					pairs = append(pairs, pair)
+				}
+			}
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		return pairs[i][0] < pairs[j][0] || pairs[i][0] == pairs[j][0] && pairs[i][1] < pairs[j][1]
+	})
+	return pairs
+}
+
+// profileHost returns the host of a profile URL, in lower case. Mirrors are
+// looked for across hosts only: two profiles on one site are two people, at
+// most with a boilerplate snippet in common.
+func profileHost(profileURL string) string {
+	u, err := url.Parse(profileURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+// namesAgree reports whether two result names could be one person's: equal
+// but for case and spacing, or either unknown.
+func namesAgree(a, b string) bool {
+	a, b = strings.Join(strings.Fields(strings.ToLower(a)), " "), strings.Join(strings.Fields(strings.ToLower(b)), " ")
+	return a == "" || b == "" || a == b
+}
+
+// profileSiteRank orders the URLs a profile was found under, lowest first:
+// LinkedIn's canonical host, then its other hosts, then everything else,
+// such as aggregator sites mirroring the profile.
+func profileSiteRank(profileURL string) int {
+	u, err := url.Parse(profileURL)
+	if err != nil {
+		return 2
+	}
+	host := strings.ToLower(u.Hostname())
+	switch {
+	case !strings.HasPrefix(u.Path, "/in/"):
+		return 2
+	case host == "www.linkedin.com":
+		return 0
+	case strings.HasSuffix(host, ".linkedin.com") || host == "linkedin.com":
+		return 1
+	}
+	return 2
+}
+
+// suppressMirrors merges candidates on different hosts whose snippets are
+// near-identical, at least threshold similar, into one per group. The kept record is the one
+// on the preferred profile site, or else the first found; the others'
+// URLs are listed in its AlternateURLs and their fields fill its gaps under
+// the -merge-strategy. A threshold of 0 keeps every candidate.
+func suppressMirrors(candidates []Candidate, threshold float64) []Candidate {
+	if threshold <= 0 || len(candidates) < 2 {
+		return candidates
+	}
+	// Group the pairs with a union-find, rooted at the record to keep.
+	parent := make([]int, len(candidates))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	prefer := func(a, b int) bool {
+		ra, rb := profileSiteRank(candidates[a].ProfileURL), profileSiteRank(candidates[b].ProfileURL)
+		return ra < rb || ra == rb && a < b
+	}
+	for _, pair := range mirrorPairs(candidates, threshold) {
+		a, b := find(pair[0]), find(pair[1])
+		if a == b {
+			continue
+		}
+		if prefer(b, a) {
+			a, b = b, a
+		}
+		parent[b] = a
+	}
+	for i := range candidates {
+		root := find(i)
+		if root == i {
+			continue
+		}
+		keep, mirror := &candidates[root], candidates[i]
+		verbosef("Suppressing %s, a mirror of %s", mirror.ProfileURL, keep.ProfileURL)
+		stats.countMirror()
+		mergeDuplicate(keep, mirror)
+		keep.AlternateURLs = append(append(keep.AlternateURLs, mirror.ProfileURL), mirror.AlternateURLs...)
+	}
+	kept := candidates[:0]
+	for i, c := range candidates {
+		if find(i) == i {
+			kept = append(kept, c)
+		}
+	}
+	return kept
+}
+
+// withoutMirrors returns the candidates of fresh that are not mirrors of one
+// in prior or of an earlier one in fresh. It is the suppression a stream of
+// candidates can do, where the rows of prior are already written: the first
+// found of a group is kept, rather than the one on the preferred site.
+func withoutMirrors(prior, fresh []Candidate, threshold float64) []Candidate {
+	if threshold <= 0 || len(fresh) == 0 {
+		return fresh
+	}
+	all := append(append([]Candidate(nil), prior...), fresh...)
+	mirrors := make(map[int]bool)
+	for _, pair := range mirrorPairs(all, threshold) {
+		if pair[1] >= len(prior) { // Pairs are ordered, so pair[1] is the later.
+			mirrors[pair[1]-len(prior)] = true
+		}
+	}
+	var kept []Candidate
+	for i, c := range fresh {
+		if mirrors[i] {
+			verbosef("Not streaming %s, a mirror of a candidate already written", c.ProfileURL)
+			continue
+		}
+		kept = append(kept, c)
+	}
+	return kept
+}
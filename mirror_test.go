@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// mirrorProfile is the scenario entry of a profile, all with one snippet.
+const mirrorProfile = "    name: Jane Doe\n    title: Senior Valve Design Engineer\n    company: Acme Valves\n    location: Bangalore, Karnataka, India\n    experience: 12\n"
+
+func TestMirrorsMergedWhenRunStopsEarly(t *testing.T) {
+	// The same profile under two hosts, the mirror found first, and the
+	// budget spent on the results page before either profile is fetched.
+	_, addr := startFakeWeb(t, "robots: \"User-agent: *\\nAllow: /\\n\"\nprofiles:\n"+
+		"  - slug: jane-doe-valves\n    host: in.linkedin.com\n"+mirrorProfile+
+		"  - slug: jane-doe\n"+mirrorProfile)
+	output, err := runFakeSearch(t, addr, "-max-requests", "2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	candidates := readFakeSearch(t, output)
+	if len(candidates) != 1 {
+		t.Fatalf("%d candidates written, want the mirrors merged into 1", len(candidates))
+	}
+	if got := candidates[0].ProfileURL; got != "https://www.linkedin.com/in/jane-doe" {
+		t.Errorf("kept %s, want the one on www.linkedin.com", got)
+	}
+}
+
+func TestMirrorsOnOneHostKeptApart(t *testing.T) {
+	_, addr := startFakeWeb(t, "robots: \"User-agent: *\\nAllow: /\\n\"\nprofiles:\n"+
+		"  - slug: jane-doe\n"+mirrorProfile+
+		"  - slug: jane-doe-valves\n"+mirrorProfile)
+	output, err := runFakeSearch(t, addr, "-max-requests", "2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if candidates := readFakeSearch(t, output); len(candidates) != 2 {
+		t.Errorf("%d candidates written, want two profiles of www.linkedin.com kept apart", len(candidates))
+	}
+}
+
+func TestMirrorsLeftOutOfStream(t *testing.T) {
+	// One result per page, so the mirror is streamed after the original.
+	_, addr := startFakeWeb(t, "robots: \"User-agent: *\\nAllow: /\\n\"\nresults_per_page: 1\nprofiles:\n"+
+		"  - slug: jane-doe\n"+mirrorProfile+
+		"  - slug: jane-doe-valves\n    host: in.linkedin.com\n"+mirrorProfile)
+	output, err := runFakeSearch(t, addr, "-flush-every", "1", "-max-pages", "2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	candidates := readFakeSearch(t, output)
+	if len(candidates) != 1 || candidates[0].ProfileURL != "https://www.linkedin.com/in/jane-doe" {
+		t.Errorf("streamed %d candidates, want only https://www.linkedin.com/in/jane-doe", len(candidates))
+	}
+}
+
+func TestJaccard(t *testing.T) {
+	set := func(words ...string) map[string]bool {
+		s := make(map[string]bool)
+		for _, w := range words {
+			s[w] = true
+		}
+		return s
+	}
+	tests := []struct {
+		a, b map[string]bool
+		want float64
+	}{
+		{set(), set(), 1},
+		{set("a"), set(), 0},
+		{set("a", "b"), set("a", "b"), 1},
+		{set("a", "b"), set("c", "d"), 0},
+		{set("a", "b", "c"), set("b", "c", "d"), 0.5},
+		{set("a", "b", "c", "d"), set("a"), 0.25},
+	}
+	for _, tt := range tests {
+		if got := jaccard(tt.a, tt.b); got != tt.want {
+			t.Errorf("jaccard(%v, %v) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+		if got := jaccard(tt.b, tt.a); got != tt.want {
+			t.Errorf("jaccard(%v, %v) = %v, want %v", tt.b, tt.a, got, tt.want)
+		}
+	}
+}
+
+func TestSimhashBandingRecall(t *testing.T) {
+	// Each snippet and a copy differing in one word, at the end, must
+	// share a band, or the pair is never compared.
+	base := "Senior Valve Design Engineer at Acme Valves in Bangalore with twelve years of experience in control valves, actuators, and pressure relief systems for refineries"
+	missed := 0
+	const n = 200
+	for i := 0; i < n; i++ {
+		a := fmt.Sprintf("%s member %d", base, i)
+		b := a + " and more"
+		ha, hb := simhash(snippetShingles(a)), simhash(snippetShingles(b))
+		shared := false
+		for band := 0; band < mirrorBands; band++ {
+			shared = shared || simhashBand(ha, band) == simhashBand(hb, band)
+		}
+		if !shared {
+			missed++
+		}
+	}
+	if missed > n/20 {
+		t.Errorf("%d of %d near-duplicate pairs share no band, want at most %d", missed, n, n/20)
+	}
+
+	// Bands are tagged, so equal bits in different bands do not collide.
+	if simhashBand(0, 0) == simhashBand(0, 1) {
+		t.Error("band 0 and band 1 of one hash collide")
+	}
+}
+
+func TestProfileSiteRankPreference(t *testing.T) {
+	urls := []string{
+		"https://mirror.example/linkedin.com/in/jane-doe",
+		"https://in.linkedin.com/in/jane-doe",
+		"https://www.linkedin.com/in/jane-doe",
+		"https://www.linkedin.com/company/acme",
+	}
+	want := []int{2, 1, 0, 2}
+	for i, u := range urls {
+		if got := profileSiteRank(u); got != want[i] {
+			t.Errorf("profileSiteRank(%s) = %d, want %d", u, got, want[i])
+		}
+	}
+
+	snippet := "Jane Doe. Senior Valve Design Engineer at Acme Valves, Bangalore. Twelve years of control valve design and testing."
+	stats = newRunStats()
+	var candidates []Candidate
+	for _, u := range urls[:3] {
+		candidates = append(candidates, Candidate{Name: "Jane Doe", Snippet: snippet, ProfileURL: u})
+	}
+	kept := suppressMirrors(candidates, defaultMirrorThreshold)
+	if len(kept) != 1 {
+		t.Fatalf("%d candidates kept, want 1", len(kept))
+	}
+	if kept[0].ProfileURL != urls[2] {
+		t.Errorf("kept %s, want %s", kept[0].ProfileURL, urls[2])
+	}
+	if got := strings.Join(kept[0].AlternateURLs, " "); got != urls[0]+" "+urls[1] {
+		t.Errorf("alternate URLs %q, want the other two in order found", got)
+	}
+}
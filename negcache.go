@@ -0,0 +1,203 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Classes of profile fetch failures remembered across runs. Only failures
+// that a retry within days would repeat are remembered; errors a run retries
+// itself, network errors, and server errors are not.
+const (
+	failureGone     = "gone"     // 404 or 410, or a ghost account with nothing on it.
+	failureAuthwall = "authwall" // LinkedIn demanded a login.
+	failureBlocked  = "blocked"  // CAPTCHA or rate limit.
+)
+
+// negativeTTLs is how long each failure class suppresses a profile's fetch.
+var negativeTTLs = map[string]time.Duration{
+	failureGone:     90 * 24 * time.Hour,
+	failureAuthwall: 7 * 24 * time.Hour,
+	failureBlocked:  24 * time.Hour,
+}
+
+// negativeResult is a profile whose fetch failed, as kept in a -store file.
+type negativeResult struct {
+	URL   string    `json:"url"` // canonicalProfileURL of the profile.
+	Class string    `json:"class"`
+	Time  time.Time `json:"time"`
+}
+
+// expired reports whether the entry no longer suppresses a fetch at now.
+func (n negativeResult) expired(now time.Time) bool {
+	ttl, ok := negativeTTLs[n.Class]
+	return !ok || now.Sub(n.Time) >= ttl
+}
+
+// canonicalProfileURL keys a profile by its slug, so the forms of its URL
+// that results link to share one entry.
+func canonicalProfileURL(profileURL string) string {
+	if slug := profileSlug(profileURL); slug != "" {
+		return "https://www.linkedin.com/in/" + slug
+	}
+	return profileURL
+}
+
+// failureClass maps a profile fetch error to the class remembered for it, or
+// "" when it is not remembered.
+func failureClass(err error) string {
+	var se *statusError
+	switch {
+	case err == nil || stopsRun(err):
+		return ""
+	case errors.Is(err, errAuthwall):
+		return failureAuthwall
+	case errors.Is(err, errBlocked):
+		return failureBlocked
+	case errors.As(err, &se) && (se.code == http.StatusNotFound || se.code == http.StatusGone):
+		return failureGone
+	case errors.As(err, &se) && se.code == 999: // LinkedIn answers bots with 999.
+		return failureBlocked
+	}
+	return ""
+}
+
+// isGhostAccount reports whether a fetched profile has nothing identifying
+// on it: no name, no headline, and the default avatar.
+func isGhostAccount(c Candidate) bool {
+	return c.Name == "" && c.Headline == "" && isGhostAvatar(c.PhotoURL)
+}
+
+// negativeCache holds the profile fetch failures recorded in the store, and
+// those of this run to save back. It is safe for concurrent use; a nil
+// negativeCache suppresses and records nothing.
+type negativeCache struct {
+	now    time.Time
+	ignore bool // Fetch suppressed profiles anyway, for -ignore-negative-cache.
+
+	mu      sync.Mutex
+	entries map[string]negativeResult // Unexpired entries by canonical URL.
+	changes map[string]*negativeResult
+}
+
+// newNegativeCache reads the unexpired failures recorded in store.
+func newNegativeCache(store *candidateStore, ignore bool) *negativeCache {
+	n := &negativeCache{now: clockNow().UTC(), ignore: ignore, entries: make(map[string]negativeResult), changes: make(map[string]*negativeResult)}
+	for _, e := range store.negativeResults() {
+		if !e.expired(n.now) {
+			n.entries[e.URL] = e
+		}
+	}
+	return n
+}
+
+// suppressed returns the class of a recorded failure that rules out fetching
+// profileURL again yet.
+func (n *negativeCache) suppressed(profileURL string) (string, bool) {
+	if n == nil || n.ignore {
+		return "", false
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	e, ok := n.entries[canonicalProfileURL(profileURL)]
+	return e.Class, ok
+}
+
+// record notes how a fetch of profileURL ended: a remembered failure adds or
+// refreshes its entry, and a successful fetch clears any.
+func (n *negativeCache) record(profileURL string, err error, profile Candidate) {
+	if n == nil {
+		return
+	}
+	class := failureClass(err)
+	if err == nil && isGhostAccount(profile) {
+		class = failureGone
+	}
+	if err != nil && class == "" {
+		return // Not telling either way.
+	}
+	key := canonicalProfileURL(profileURL)
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if class == "" {
+		if _, ok := n.entries[key]; ok {
+			delete(n.entries, key)
+			n.changes[key] = nil
+		}
+		return
+	}
+	e := negativeResult{URL: key, Class: class, Time: n.now}
+	n.entries[key] = e
+	n.changes[key] = &e
+}
+
+// changed reports whether the run recorded anything to save.
+func (n *negativeCache) changed() bool {
+	if n == nil {
+		return false
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return len(n.changes) > 0
+}
+
+// apply writes the run's changes to store, dropping entries that expired.
+func (n *negativeCache) apply(store *candidateStore) {
+	if n == nil {
+		return
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	merged := make(map[string]negativeResult)
+	for _, e := range store.negativeResults() {
+		merged[e.URL] = e
+	}
+	for key, e := range n.changes {
+		if e == nil {
+			delete(merged, key)
+		} else {
+			merged[key] = *e
+		}
+	}
+	var kept []negativeResult
+	for _, e := range merged {
+		if !e.expired(n.now) {
+			kept = append(kept, e)
+		}
+	}
+	sort.Slice(kept, func(i, j int) bool { return kept[i].URL < kept[j].URL })
+	store.setNegativeResults(kept)
+}
+
+// describe prints how many profiles the cache suppresses.
+func (n *negativeCache) describe() {
+	if n == nil {
+		return
+	}
+	if n.ignore {
+		fmt.Printf("Retrying %d profiles that failed recently (-ignore-negative-cache)\n", len(n.entries))
+		return
+	}
+	if len(n.entries) > 0 {
+		fmt.Printf("Skipping %d profiles that failed recently\n", len(n.entries))
+	}
+}
+
+// reportSuppressedFetches prints the profile fetches the cache saved.
+func reportSuppressedFetches() {
+	stats.mu.Lock()
+	defer stats.mu.Unlock()
+	total := 0
+	for _, n := range stats.SuppressedFetches {
+		total += n
+	}
+	if total == 0 {
+		return
+	}
+	fmt.Printf("Skipped %d profile fetches that failed recently (%d gone, %d authwall, %d blocked)\n", total,
+		stats.SuppressedFetches[failureGone], stats.SuppressedFetches[failureAuthwall], stats.SuppressedFetches[failureBlocked])
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFailureClass(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{nil, ""},
+		{&statusError{code: 404}, failureGone},
+		{fmt.Errorf("failed to fetch profile: %w", &statusError{code: 410}), failureGone},
+		{errAuthwall, failureAuthwall},
+		{errBlocked, failureBlocked},
+		{&statusError{code: 999}, failureBlocked},
+		// Failures a run retries itself, or that say nothing about the
+		// profile, are not remembered.
+		{&statusError{code: 503}, ""},
+		{&statusError{code: 500}, ""},
+		{errors.New("connection reset by peer"), ""},
+		{context.Canceled, ""},
+		{fmt.Errorf("fetch: %w", errBudgetExhausted), ""},
+	}
+	for _, tt := range tests {
+		if got := failureClass(tt.err); got != tt.want {
+			t.Errorf("failureClass(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestNegativeResultTTLs(t *testing.T) {
+	now := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	tests := []struct {
+		class   string
+		age     time.Duration
+		expired bool
+	}{
+		{failureGone, 89 * day, false},
+		{failureGone, 90 * day, true},
+		{failureAuthwall, 6 * day, false},
+		{failureAuthwall, 7 * day, true},
+		{failureBlocked, 23 * time.Hour, false},
+		{failureBlocked, day, true},
+		{"unknown", 0, true},
+	}
+	for _, tt := range tests {
+		e := negativeResult{URL: "https://www.linkedin.com/in/jane-doe", Class: tt.class, Time: now.Add(-tt.age)}
+		if got := e.expired(now); got != tt.expired {
+			t.Errorf("%s entry %v old: expired %v, want %v", tt.class, tt.age, got, tt.expired)
+		}
+	}
+}
+
+func TestNegativeCacheRecordAndApply(t *testing.T) {
+	fixedNow = time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	t.Cleanup(func() { fixedNow = time.Time{} })
+	store, err := openStore(filepath.Join(t.TempDir(), "candidates.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.setNegativeResults([]negativeResult{
+		{URL: "https://www.linkedin.com/in/old-block", Class: failureBlocked, Time: fixedNow.Add(-48 * time.Hour)},
+		{URL: "https://www.linkedin.com/in/back-again", Class: failureAuthwall, Time: fixedNow.Add(-time.Hour)},
+	})
+
+	n := newNegativeCache(store, false)
+	if _, ok := n.suppressed("https://www.linkedin.com/in/old-block"); ok {
+		t.Error("an expired entry suppressed a fetch")
+	}
+	n.record("https://in.linkedin.com/in/jane-doe/?trk=public", &statusError{code: 404}, Candidate{})
+	n.record("https://www.linkedin.com/in/john-roe", errAuthwall, Candidate{})
+	n.record("https://www.linkedin.com/in/ghost", nil, Candidate{}) // Fetched, but nothing on it.
+	n.record("https://www.linkedin.com/in/flaky", &statusError{code: 503}, Candidate{})
+	n.record("https://www.linkedin.com/in/back-again", nil, Candidate{Name: "Back Again"})
+	if !n.changed() {
+		t.Fatal("the cache recorded no changes")
+	}
+	n.apply(store)
+
+	got := make(map[string]string)
+	for _, e := range store.negativeResults() {
+		got[e.URL] = e.Class
+	}
+	want := map[string]string{
+		"https://www.linkedin.com/in/jane-doe": failureGone,
+		"https://www.linkedin.com/in/john-roe": failureAuthwall,
+		"https://www.linkedin.com/in/ghost":    failureGone,
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("entries %v, want %v", got, want)
+	}
+
+	// The next run skips them, under any form of their URLs.
+	next := newNegativeCache(store, false)
+	if class, ok := next.suppressed("https://www.linkedin.com/in/jane-doe?utm_source=x"); !ok || class != failureGone {
+		t.Errorf("jane-doe suppressed %v as %q, want gone", ok, class)
+	}
+	if _, ok := next.suppressed("https://www.linkedin.com/in/flaky"); ok {
+		t.Error("a retryable failure suppressed a fetch")
+	}
+	if _, ok := newNegativeCache(store, true).suppressed("https://www.linkedin.com/in/jane-doe"); ok {
+		t.Error("-ignore-negative-cache suppressed a fetch")
+	}
+
+	var none *negativeCache
+	none.record("https://www.linkedin.com/in/jane-doe", errBlocked, Candidate{})
+	if _, ok := none.suppressed("https://www.linkedin.com/in/jane-doe"); ok || none.changed() {
+		t.Error("a nil cache suppressed or recorded a fetch")
+	}
+}
+
+func TestNegativeCacheSkipsFetches(t *testing.T) {
+	storePath := filepath.Join(t.TempDir(), "candidates.db")
+	scenario := `profiles:
+  - slug: jane-doe
+    name: Jane Doe
+    behavior: gone
+  - slug: john-roe
+    name: John Roe
+    behavior: authwall
+  - slug: ann-poe
+    name: Ann Poe
+`
+	web, addr := startFakeWeb(t, scenario)
+	if _, err := runFakeSearch(t, addr, "-max-pages", "1", "-store", storePath); err != nil {
+		t.Fatal(err)
+	}
+	first := web.requests("profile")
+
+	web, addr = startFakeWeb(t, scenario)
+	if _, err := runFakeSearch(t, addr, "-max-pages", "1", "-store", storePath); err != nil {
+		t.Fatal(err)
+	}
+	if n := web.requests("profile"); n != 1 {
+		t.Errorf("%d profiles fetched, want only ann-poe", n)
+	}
+	if stats.SuppressedFetches[failureGone] != 1 || stats.SuppressedFetches[failureAuthwall] != 1 {
+		t.Errorf("suppressed fetches %v, want one gone and one authwall", stats.SuppressedFetches)
+	}
+
+	web, addr = startFakeWeb(t, scenario)
+	if _, err := runFakeSearch(t, addr, "-max-pages", "1", "-store", storePath, "-ignore-negative-cache"); err != nil {
+		t.Fatal(err)
+	}
+	if n := web.requests("profile"); n != first {
+		t.Errorf("-ignore-negative-cache fetched %d profiles, want all %d of the first run", n, first)
+	}
+}
+
+func TestIgnoreNegativeCacheNeedsStore(t *testing.T) {
+	if _, err := parseFlags([]string{"-ignore-negative-cache"}); err == nil {
+		t.Error("-ignore-negative-cache accepted without -store")
+	}
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"html"
+	"io/fs"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// errInvalidOptOutToken is returned for a token that is malformed or was not
+// signed with the secret.
+var errInvalidOptOutToken = errors.New("invalid opt-out token")
+
+// optOutMACBytes is how much of the HMAC-SHA256 a token keeps, to keep links
+// short.
+const optOutMACBytes = 16
+
+// optOutToken signs a candidate's ID, the slug of their profile URL, as
+// "<ID>.<MAC>", both base64url-encoded.
+func optOutToken(secret []byte, id string) string {
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString([]byte(id)) + "." + enc.EncodeToString(optOutMAC(secret, id))
+}
+
+// optOutMAC returns the truncated MAC of id.
+func optOutMAC(secret []byte, id string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("optout:" + id))
+	return mac.Sum(nil)[:optOutMACBytes]
+}
+
+// verifyOptOutToken returns the candidate ID a token was signed for, or
+// errInvalidOptOutToken.
+func verifyOptOutToken(secret []byte, token string) (string, error) {
+	enc := base64.RawURLEncoding
+	rawID, rawMAC, ok := strings.Cut(token, ".")
+	if !ok {
+		return "", errInvalidOptOutToken
+	}
+	id, err := enc.DecodeString(rawID)
+	if err != nil || len(id) == 0 {
+		return "", errInvalidOptOutToken
+	}
+	mac, err := enc.DecodeString(rawMAC)
+	if err != nil || !hmac.Equal(mac, optOutMAC(secret, string(id))) {
+		return "", errInvalidOptOutToken
+	}
+	return string(id), nil
+}
+
+// optOutSigner builds the opt-out links of -optout-base-url. A nil
+// optOutSigner builds none.
+type optOutSigner struct {
+	baseURL string
+	secret  []byte
+}
+
+// newOptOutSigner returns a signer for links under baseURL, or nil when
+// baseURL is empty.
+func newOptOutSigner(baseURL, secret string) (*optOutSigner, error) {
+	if baseURL == "" {
+		return nil, nil
+	}
+	if secret == "" {
+		return nil, errors.New("-optout-base-url needs -optout-secret to sign tokens with")
+	}
+	if u, err := url.Parse(baseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid -optout-base-url %q: want an http or https URL", baseURL)
+	}
+	return &optOutSigner{baseURL: strings.TrimSuffix(baseURL, "/") + "/", secret: []byte(secret)}, nil
+}
+
+// link returns the opt-out URL of c, or "" for a candidate without an email,
+// who is never written to, or without a profile slug to identify them by.
+func (s *optOutSigner) link(c Candidate) string {
+	id := profileSlug(c.ProfileURL)
+	if s == nil || c.Email == "" || id == "" {
+		return ""
+	}
+	return s.baseURL + optOutToken(s.secret, id)
+}
+
+// optOutRecord is one line of an -optout-list file.
+type optOutRecord struct {
+	Profile string    `json:"profile"` // The candidate ID: their profile slug.
+	At      time.Time `json:"at"`
+}
+
+// optOutList is the suppression list of candidates who opted out, read from
+// an -optout-list file of JSON lines. A nil optOutList holds no one.
+type optOutList struct {
+	path string
+
+	mu       sync.Mutex
+	profiles map[string]time.Time
+}
+
+// loadOptOutList reads path; a missing file is an empty list.
+func loadOptOutList(path string) (*optOutList, error) {
+	l := &optOutList{path: path, profiles: make(map[string]time.Time)}
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return l, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open opt-out list: %w", err)
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var r optOutRecord
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil || r.Profile == "" {
+			return nil, fmt.Errorf("opt-out list line %d: not an opt-out record", line)
+		}
+		l.profiles[r.Profile] = r.At
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read opt-out list: %w", err)
+	}
+	return l, nil
+}
+
+// size returns the number of candidates on the list.
+func (l *optOutList) size() int {
+	if l == nil {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.profiles)
+}
+
+// contains reports whether the candidate with profile URL opted out.
+func (l *optOutList) contains(profileURL string) bool {
+	if l == nil {
+		return false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, ok := l.profiles[profileSlug(profileURL)]
+	return ok
+}
+
+// add appends the candidate id to the list file, unless it is already on
+// the list, and reports whether it was added.
+func (l *optOutList) add(id string, at time.Time) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.profiles[id]; ok {
+		return false, nil
+	}
+	line, err := json.Marshal(optOutRecord{Profile: id, At: at.UTC()})
+	if err != nil {
+		return false, err
+	}
+	file, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return false, fmt.Errorf("failed to open opt-out list: %w", err)
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return false, fmt.Errorf("failed to write opt-out list: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return false, fmt.Errorf("failed to write opt-out list: %w", err)
+	}
+	l.profiles[id] = at
+	return true, nil
+}
+
+// filter returns a filter dropping the candidates on the list.
+func (l *optOutList) filter() candidateFilter {
+	return func(c Candidate) filterDecision {
+		return filterDecision{Filter: "optout", Passed: !l.contains(c.ProfileURL), Expected: "not on the opt-out list", Actual: profileSlug(c.ProfileURL)}
+	}
+}
+
+// optOutPath is where opt-out links point, followed by the token.
+const optOutPath = "/optout/"
+
+// optOutHandler serves GET /optout/{token}: a valid token puts its candidate
+// on list.
+func optOutHandler(secret []byte, list *optOutList) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		id, err := verifyOptOutToken(secret, strings.TrimPrefix(r.URL.Path, optOutPath))
+		if err != nil {
+			http.Error(w, "This opt-out link is not valid.", http.StatusNotFound)
+			return
+		}
+		added, err := list.add(id, clockNow())
+		if err != nil {
+			log.Printf("Failed to record the opt-out of %s: %v", id, err)
+			http.Error(w, "Your opt-out could not be recorded; please try again later.", http.StatusInternalServerError)
+			return
+		}
+		if added {
+			log.Printf("Recorded the opt-out of %s", id)
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprintf(w, "<!DOCTYPE html><title>Unsubscribed</title><p>You will not be contacted again about this profile (%s).</p>\n", html.EscapeString(id))
+	})
+}
+
+// runOptOutServerCommand serves opt-out links until interrupted.
+func runOptOutServerCommand(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("optout-server", flag.ExitOnError)
+	addr := fs.String("addr", "127.0.0.1:8080", "address to listen on")
+	secret := fs.String("optout-secret", "", "secret the opt-out tokens were signed with, as given to the search")
+	listPath := fs.String("optout-list", "", "JSON lines file to append the candidates who opt out to; pass it to searches with -optout-list to drop them")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: profilesearch optout-server -optout-secret S -optout-list optouts.jsonl [-addr host:port]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if *secret == "" || *listPath == "" {
+		fs.Usage()
+		return errors.New("optout-server needs -optout-secret and -optout-list")
+	}
+	list, err := loadOptOutList(*listPath)
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.Handle(optOutPath, optOutHandler([]byte(*secret), list))
+	listener, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen: %w", err)
+	}
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+	fmt.Printf("Serving opt-out links on %s%s; %d candidates opted out so far\n", listener.Addr(), optOutPath, list.size())
+	if err := srv.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	log.Print("optout-server stopped")
+	return nil
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOptOutTokenRoundTrip(t *testing.T) {
+	secret := []byte("s3cret")
+	for _, id := range []string{"jane-doe", "rahul-sharma-4821", "josé-müller", "a"} {
+		token := optOutToken(secret, id)
+		if strings.ContainsAny(token, "/+=?&") {
+			t.Errorf("token %q of %s is not URL-safe", token, id)
+		}
+		got, err := verifyOptOutToken(secret, token)
+		if err != nil || got != id {
+			t.Errorf("verifyOptOutToken(%q) = %q, %v; want %q", token, got, err, id)
+		}
+	}
+}
+
+func TestOptOutTokenTampering(t *testing.T) {
+	secret := []byte("s3cret")
+	token := optOutToken(secret, "jane-doe")
+	rawID, rawMAC, _ := strings.Cut(token, ".")
+	enc := base64.RawURLEncoding
+	flipped := []byte(rawMAC)
+	flipped[0] ^= 'A' ^ 'B'
+	if flipped[0] == rawMAC[0] {
+		t.Fatal("MAC not changed")
+	}
+	for name, bad := range map[string]string{
+		"another secret":   optOutToken([]byte("other"), "jane-doe"),
+		"another ID":       enc.EncodeToString([]byte("john-roe")) + "." + rawMAC,
+		"changed MAC":      rawID + "." + string(flipped),
+		"truncated MAC":    rawID + "." + rawMAC[:len(rawMAC)-2],
+		"no MAC":           rawID,
+		"empty ID":         "." + rawMAC,
+		"not base64":       "jane doe!." + rawMAC,
+		"empty":            "",
+		"extra separator":  token + ".x",
+		"padded signature": rawID + "." + enc.EncodeToString(append(optOutMAC(secret, "jane-doe"), 0)),
+	} {
+		if id, err := verifyOptOutToken(secret, bad); err != errInvalidOptOutToken {
+			t.Errorf("%s: verified as %q, %v; want errInvalidOptOutToken", name, id, err)
+		}
+	}
+}
+
+func TestOptOutSigner(t *testing.T) {
+	s, err := newOptOutSigner("https://example.com/optout", "s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	jane := Candidate{ProfileURL: "https://www.linkedin.com/in/jane-doe", Email: "jane@example.com"}
+	if got, want := s.link(jane), "https://example.com/optout/"+optOutToken([]byte("s3cret"), "jane-doe"); got != want {
+		t.Errorf("link %s, want %s", got, want)
+	}
+	if got := s.link(Candidate{ProfileURL: jane.ProfileURL}); got != "" {
+		t.Errorf("link %s for a candidate without an email", got)
+	}
+	if got := (*optOutSigner)(nil).link(jane); got != "" {
+		t.Errorf("link %s without -optout-base-url", got)
+	}
+	for _, tt := range [][2]string{{"https://example.com/optout", ""}, {"example.com/optout", "s3cret"}, {"ftp://example.com/", "s3cret"}} {
+		if _, err := newOptOutSigner(tt[0], tt[1]); err == nil {
+			t.Errorf("newOptOutSigner(%q, %q) accepted", tt[0], tt[1])
+		}
+	}
+	if s, err := newOptOutSigner("", ""); s != nil || err != nil {
+		t.Errorf("newOptOutSigner without a base URL = %v, %v; want nil", s, err)
+	}
+}
+
+func TestOptOutHandlerWritesList(t *testing.T) {
+	fixedNow = time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	t.Cleanup(func() { fixedNow = time.Time{} })
+	captureLog(t)
+	secret := []byte("s3cret")
+	path := filepath.Join(t.TempDir(), "optouts.jsonl")
+	list, err := loadOptOutList(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(optOutHandler(secret, list))
+	defer srv.Close()
+	get := func(method, token string) int {
+		t.Helper()
+		req, err := http.NewRequest(method, srv.URL+optOutPath+token, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	token := optOutToken(secret, "jane-doe")
+	for i := 0; i < 2; i++ {
+		if status := get(http.MethodGet, token); status != http.StatusOK {
+			t.Fatalf("opt-out %d answered %d, want 200", i+1, status)
+		}
+	}
+	if status := get(http.MethodGet, optOutToken([]byte("other"), "john-roe")); status != http.StatusNotFound {
+		t.Errorf("tampered token answered %d, want 404", status)
+	}
+	if status := get(http.MethodPost, token); status != http.StatusMethodNotAllowed {
+		t.Errorf("POST answered %d, want 405", status)
+	}
+
+	// Jane is on the list once; the tampered token added no one.
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"profile":"jane-doe","at":"2026-03-01T09:00:00Z"}` + "\n"; string(data) != want {
+		t.Errorf("opt-out list %q, want %q", data, want)
+	}
+	reloaded, err := loadOptOutList(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reloaded.size() != 1 || !reloaded.contains("https://in.linkedin.com/in/jane-doe?trk=x") || reloaded.contains("https://www.linkedin.com/in/john-roe") {
+		t.Errorf("reloaded list of %d, want only jane-doe", reloaded.size())
+	}
+
+	if err := os.WriteFile(path, []byte("not json\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadOptOutList(path); err == nil {
+		t.Error("a malformed opt-out list loaded")
+	}
+}
+
+func TestOptOutSuppressesLaterRuns(t *testing.T) {
+	captureLog(t)
+	_, addr := startFakeWeb(t, `profiles:
+  - slug: jane-doe
+    name: Jane Doe
+    email: jane@example.com
+  - slug: john-roe
+    name: John Roe
+`)
+	secret := "s3cret"
+	output, err := runFakeSearch(t, addr, "-max-pages", "1", "-format", "json", "-optout-base-url", "https://example.com/optout/", "-optout-secret", secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := readJSONCandidates(t, output)
+	if len(got) != 2 || got[0].OptOutURL == "" || got[1].OptOutURL != "" {
+		t.Fatalf("opt-out links %+v, want one for jane-doe, who has an email, only", got)
+	}
+
+	// Jane follows her link.
+	list := filepath.Join(t.TempDir(), "optouts.jsonl")
+	optouts, err := loadOptOutList(list)
+	if err != nil {
+		t.Fatal(err)
+	}
+	link, err := url.Parse(got[0].OptOutURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	optOutHandler([]byte(secret), optouts).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, link.Path, nil))
+	if rec.Code != http.StatusOK || !bytes.Contains(rec.Body.Bytes(), []byte("jane-doe")) {
+		t.Fatalf("opt-out answered %d: %s", rec.Code, rec.Body)
+	}
+
+	// The next run given the list drops her.
+	if output, err = runFakeSearch(t, addr, "-max-pages", "1", "-optout-list", list); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFakeSearch(t, output); len(got) != 1 || got[0].Name != "John Roe" {
+		t.Errorf("kept %+v, want jane-doe dropped", got)
+	}
+}
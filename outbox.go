@@ -0,0 +1,510 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Values of outboxRecord.Op.
+const (
+	outboxAdd     = "add"     // An entry is queued.
+	outboxAttempt = "attempt" // A delivery of the entry failed.
+	outboxDone    = "done"    // The entry was delivered.
+	outboxDiscard = "discard" // The entry was given up on by hand.
+)
+
+const (
+	// deliveryKeyHeader carries an entry's delivery key, so a receiver can
+	// ignore a candidate it was already sent.
+	deliveryKeyHeader  = "Idempotency-Key"
+	webhookTimeout     = 30 * time.Second
+	outboxCloseTimeout = time.Minute // Longest the final delivery pass may take.
+)
+
+// outboxEntry is one candidate or alert waiting to be delivered to one
+// destination.
+type outboxEntry struct {
+	Key         string          `json:"key"`
+	Destination string          `json:"destination"` // The webhook URL.
+	ProfileURL  string          `json:"profile_url"`
+	Payload     json.RawMessage `json:"payload"` // The candidate, or an -alert-rules alert, as JSON.
+	Queued      time.Time       `json:"queued"`
+	Attempts    int             `json:"attempts,omitempty"`
+	LastError   string          `json:"last_error,omitempty"`
+}
+
+// outboxRecord is one line of the outbox journal.
+type outboxRecord struct {
+	Op    string       `json:"op"`
+	Key   string       `json:"key"`
+	Time  time.Time    `json:"time"`
+	Entry *outboxEntry `json:"entry,omitempty"` // Set for add.
+	Error string       `json:"error,omitempty"` // Set for attempt.
+}
+
+// deliveryKey identifies the delivery of what parts describe, such as a
+// candidate's profile URL and payload, to a destination. It depends only on
+// those, so a candidate queued again unchanged, by a retry or a later run,
+// reaches the receiver under the same key, while one whose data changed is
+// delivered anew.
+func deliveryKey(destination string, parts ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(append([]string{destination}, parts...), "\n")))
+	return hex.EncodeToString(sum[:16])
+}
+
+// outbox is an append-only journal of deliveries. Every change is written and
+// synced before it takes effect, so entries queued before a crash are still
+// pending when the outbox is opened again.
+type outbox struct {
+	path string
+
+	mu      sync.Mutex
+	file    *os.File
+	pending map[string]*outboxEntry
+}
+
+// openOutbox opens the journal at path, creating it if needed. A final line
+// cut short by a crash is dropped; any other unreadable line is an error.
+// When the journal holds finished entries or a cut line, it is rewritten
+// with just the pending entries.
+func openOutbox(path string) (*outbox, error) {
+	o := &outbox{path: path, pending: make(map[string]*outboxEntry)}
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read outbox: %w", err)
+	}
+	records, torn, err := parseOutboxJournal(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read outbox %s: %w", path, err)
+	}
+	if torn {
+		log.Printf("Outbox %s ends in a partial record, probably from a crash; dropping it.", path)
+	}
+	for _, r := range records {
+		o.apply(r)
+	}
+	if torn || len(records) > len(o.pending) {
+		if err := o.compact(); err != nil {
+			return nil, err
+		}
+	}
+	if o.file, err = os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644); err != nil {
+		return nil, fmt.Errorf("failed to open outbox: %w", err)
+	}
+	return o, nil
+}
+
+// parseOutboxJournal reads journal lines. torn reports a final line without
+// its newline, which is what an append interrupted by a crash leaves.
+func parseOutboxJournal(data []byte) (records []outboxRecord, torn bool, err error) {
+	for line := 1; len(data) > 0; line++ {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			return records, true, nil
+		}
+		raw := bytes.TrimSpace(data[:i])
+		data = data[i+1:]
+		if len(raw) == 0 {
+			continue
+		}
+		var r outboxRecord
+		if err := json.Unmarshal(raw, &r); err != nil {
+			return nil, false, fmt.Errorf("line %d: %w", line, err)
+		}
+		if r.Op == outboxAdd && r.Entry == nil {
+			return nil, false, fmt.Errorf("line %d: add record without an entry", line)
+		}
+		records = append(records, r)
+	}
+	return records, false, nil
+}
+
+// apply updates the pending entries for a journal record. Records for keys
+// no longer pending are ignored.
+func (o *outbox) apply(r outboxRecord) {
+	switch r.Op {
+	case outboxAdd:
+		if _, ok := o.pending[r.Key]; !ok {
+			e := *r.Entry
+			o.pending[r.Key] = &e
+		}
+	case outboxAttempt:
+		if e := o.pending[r.Key]; e != nil {
+			e.Attempts++
+			e.LastError = r.Error
+		}
+	case outboxDone, outboxDiscard:
+		delete(o.pending, r.Key)
+	}
+}
+
+// compact replaces the journal with one add record per pending entry, which
+// carries its attempts so far.
+func (o *outbox) compact() error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, e := range o.sortedPending() {
+		if err := enc.Encode(outboxRecord{Op: outboxAdd, Key: e.Key, Time: e.Queued, Entry: &e}); err != nil {
+			return fmt.Errorf("failed to encode outbox: %w", err)
+		}
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(o.path), filepath.Base(o.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to compact outbox: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to compact outbox: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to compact outbox: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to compact outbox: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), o.path); err != nil {
+		return fmt.Errorf("failed to compact outbox: %w", err)
+	}
+	return nil
+}
+
+// record appends r to the journal, syncs it, and applies it.
+func (o *outbox) record(r outboxRecord) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("failed to encode outbox record: %w", err)
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if _, err := o.file.Write(append(b, '\n')); err != nil {
+		return fmt.Errorf("failed to write outbox: %w", err)
+	}
+	if err := o.file.Sync(); err != nil {
+		return fmt.Errorf("failed to write outbox: %w", err)
+	}
+	o.apply(r)
+	return nil
+}
+
+// enqueue queues every candidate for every destination, skipping deliveries
+// already pending. It returns how many entries were queued.
+func (o *outbox) enqueue(candidates []Candidate, destinations []string) (int, error) {
+	now := time.Now().UTC()
+	queued := 0
+	for _, dest := range destinations {
+		for _, c := range candidates {
+			payload, err := json.Marshal(c)
+			if err != nil {
+				return queued, fmt.Errorf("failed to encode candidate %s: %w", c.ProfileURL, err)
+			}
+			key := deliveryKey(dest, c.ProfileURL, string(payload))
+			if o.isPending(key) {
+				continue
+			}
+			e := &outboxEntry{Key: key, Destination: dest, ProfileURL: c.ProfileURL, Payload: payload, Queued: now}
+			if err := o.record(outboxRecord{Op: outboxAdd, Key: key, Time: now, Entry: e}); err != nil {
+				return queued, err
+			}
+			queued++
+		}
+	}
+	return queued, nil
+}
+
+// isPending reports whether the delivery with key is waiting.
+func (o *outbox) isPending(key string) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	_, ok := o.pending[key]
+	return ok
+}
+
+// sortedPending returns copies of the pending entries, oldest first. The
+// caller must hold o.mu or own o exclusively.
+func (o *outbox) sortedPending() []outboxEntry {
+	entries := make([]outboxEntry, 0, len(o.pending))
+	for _, e := range o.pending {
+		entries = append(entries, *e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if !entries[i].Queued.Equal(entries[j].Queued) {
+			return entries[i].Queued.Before(entries[j].Queued)
+		}
+		return entries[i].Key < entries[j].Key
+	})
+	return entries
+}
+
+// entries returns the pending entries, oldest first.
+func (o *outbox) entries() []outboxEntry {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.sortedPending()
+}
+
+// deliverFunc sends one entry to its destination.
+type deliverFunc func(ctx context.Context, e outboxEntry) error
+
+// drain makes one delivery attempt for each pending entry selected by keys,
+// or for all of them when keys is empty, marking each done or failed. Only a
+// journal write error is returned; failed deliveries stay pending.
+func (o *outbox) drain(ctx context.Context, deliver deliverFunc, keys ...string) (delivered, failed int, err error) {
+	for _, e := range o.entries() {
+		if len(keys) > 0 && !containsString(keys, e.Key) {
+			continue
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		r := outboxRecord{Op: outboxDone, Key: e.Key}
+		if deliverErr := deliver(ctx, e); deliverErr != nil {
+			r.Op, r.Error = outboxAttempt, deliverErr.Error()
+			failed++
+		} else {
+			delivered++
+		}
+		r.Time = time.Now().UTC()
+		if err := o.record(r); err != nil {
+			return delivered, failed, err
+		}
+	}
+	return delivered, failed, nil
+}
+
+// discard gives up on the pending deliveries with keys. It returns how many
+// were pending.
+func (o *outbox) discard(keys []string) (int, error) {
+	n := 0
+	for _, key := range keys {
+		if !o.isPending(key) {
+			continue
+		}
+		if err := o.record(outboxRecord{Op: outboxDiscard, Key: key, Time: time.Now().UTC()}); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// Close closes the journal.
+func (o *outbox) Close() error {
+	return o.file.Close()
+}
+
+// webhookDeliverer posts an entry's payload to its destination with the
+// delivery key in deliveryKeyHeader. Any status outside 2xx is a failure.
+func webhookDeliverer(client *http.Client) deliverFunc {
+	return func(ctx context.Context, e outboxEntry) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.Destination, bytes.NewReader(e.Payload))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(deliveryKeyHeader, e.Key)
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		io.Copy(io.Discard, resp.Body)
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+		}
+		return nil
+	}
+}
+
+// outboxWorker drains an outbox in the background while a run adds to it:
+// once at the start, for entries left by earlier runs, and again after each
+// enqueue.
+type outboxWorker struct {
+	box          *outbox
+	destinations []string
+	deliver      deliverFunc
+	ctx          context.Context
+	wake         chan struct{}
+	quit         chan struct{}
+	done         chan struct{}
+
+	mu        sync.Mutex
+	delivered int
+	failed    int
+}
+
+// startOutboxWorker starts draining box to deliver until stop is called.
+func startOutboxWorker(ctx context.Context, box *outbox, destinations []string, deliver deliverFunc) *outboxWorker {
+	w := &outboxWorker{
+		box: box, destinations: destinations, deliver: deliver, ctx: ctx,
+		wake: make(chan struct{}, 1), quit: make(chan struct{}), done: make(chan struct{}),
+	}
+	w.notify()
+	go w.run()
+	return w
+}
+
+func (w *outboxWorker) run() {
+	defer close(w.done)
+	for {
+		select {
+		case <-w.wake:
+			w.pass(w.ctx)
+		case <-w.quit:
+			return
+		}
+	}
+}
+
+// pass drains the outbox once and tallies the result.
+func (w *outboxWorker) pass(ctx context.Context) {
+	delivered, failed, err := w.box.drain(ctx, w.deliver)
+	if err != nil {
+		log.Printf("Outbox: %v", err)
+	}
+	w.mu.Lock()
+	w.delivered += delivered
+	w.failed += failed
+	w.mu.Unlock()
+}
+
+// notify asks for a drain without waiting for it.
+func (w *outboxWorker) notify() {
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// enqueue queues candidates for every destination and wakes the worker. It
+// does nothing on a nil worker, so callers need not check for -webhook.
+func (w *outboxWorker) enqueue(candidates []Candidate) error {
+	if w == nil {
+		return nil
+	}
+	queued, err := w.box.enqueue(candidates, w.destinations)
+	if queued > 0 {
+		w.notify()
+	}
+	return err
+}
+
+// stop makes a final delivery pass, unless the run was interrupted, then
+// closes the outbox and prints what is left. It is meant for defer.
+func (w *outboxWorker) stop() {
+	if w == nil {
+		return
+	}
+	close(w.quit)
+	<-w.done
+	if w.ctx.Err() == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), outboxCloseTimeout)
+		w.pass(ctx)
+		cancel()
+	}
+	pending := len(w.box.entries())
+	if err := w.box.Close(); err != nil {
+		log.Printf("Outbox: %v", err)
+	}
+	if w.delivered+w.failed+pending > 0 {
+		fmt.Printf("Outbox: %d delivered, %d failed attempts, %d pending in %s\n", w.delivered, w.failed, pending, w.box.path)
+	}
+	if pending > 0 {
+		fmt.Println("Pending deliveries are retried by the next run; see profilesearch outbox list.")
+	}
+}
+
+// parseWebhooks splits a comma-separated -webhook list, checking each URL.
+func parseWebhooks(spec string) ([]string, error) {
+	var hooks []string
+	for _, u := range strings.Split(spec, ",") {
+		u = strings.TrimSpace(u)
+		if u == "" {
+			continue
+		}
+		if !strings.HasPrefix(u, "http://") && !strings.HasPrefix(u, "https://") {
+			return nil, fmt.Errorf("webhook %q is not an http(s) URL", u)
+		}
+		hooks = append(hooks, u)
+	}
+	return hooks, nil
+}
+
+// runOutboxCommand lists, retries, or discards the pending deliveries of an
+// outbox.
+func runOutboxCommand(ctx context.Context, args []string) error {
+	action := "list"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		action, args = args[0], args[1:]
+	}
+	fs := flag.NewFlagSet("outbox", flag.ExitOnError)
+	path := fs.String("outbox", "outbox.jsonl", "outbox journal")
+	all := fs.Bool("all", false, "discard every pending delivery")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: profilesearch outbox [list|retry|discard] [-outbox file] [-all] [key...]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	keys := fs.Args()
+
+	if _, err := os.Stat(*path); err != nil {
+		return fmt.Errorf("no outbox: %w", err)
+	}
+	box, err := openOutbox(*path)
+	if err != nil {
+		return err
+	}
+	defer box.Close()
+
+	switch action {
+	case "list":
+		entries := box.entries()
+		if len(entries) == 0 {
+			fmt.Println("No pending deliveries.")
+			return nil
+		}
+		for _, e := range entries {
+			fmt.Printf("%s  %s  %s  queued %s, %d attempts", e.Key, e.Destination, e.ProfileURL, e.Queued.Format(time.RFC3339), e.Attempts)
+			if e.LastError != "" {
+				fmt.Printf(", last error: %s", e.LastError)
+			}
+			fmt.Println()
+		}
+	case "retry":
+		delivered, failed, err := box.drain(ctx, webhookDeliverer(&http.Client{Timeout: webhookTimeout}), keys...)
+		fmt.Printf("Delivered %d, failed %d.\n", delivered, failed)
+		return err
+	case "discard":
+		if len(keys) == 0 && !*all {
+			return errors.New("discard needs delivery keys or -all")
+		}
+		if *all {
+			keys = keys[:0]
+			for _, e := range box.entries() {
+				keys = append(keys, e.Key)
+			}
+		}
+		n, err := box.discard(keys)
+		fmt.Printf("Discarded %d deliveries.\n", n)
+		return err
+	default:
+		return fmt.Errorf("unknown outbox action %q: want list, retry, or discard", action)
+	}
+	return nil
+}
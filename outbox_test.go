@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// openTestOutbox opens the outbox at path, closing it when the test ends.
+func openTestOutbox(t *testing.T, path string) *outbox {
+	t.Helper()
+	o, err := openOutbox(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { o.Close() })
+	return o
+}
+
+func TestDeliveryKeyDeterministic(t *testing.T) {
+	const dest = "https://hooks.example.com/candidates"
+	jane := Candidate{Name: "Jane Doe", ProfileURL: "https://www.linkedin.com/in/jane-doe", Title: "Valve Engineer"}
+	keyOf := func(c Candidate, dest string) string {
+		t.Helper()
+		o := openTestOutbox(t, filepath.Join(t.TempDir(), "outbox.jsonl"))
+		if _, err := o.enqueue([]Candidate{c}, []string{dest}); err != nil {
+			t.Fatal(err)
+		}
+		return o.entries()[0].Key
+	}
+
+	key := keyOf(jane, dest)
+	if again := keyOf(jane, dest); again != key {
+		t.Errorf("an unchanged candidate queued again got key %s, want %s", again, key)
+	}
+	if other := keyOf(jane, "https://hooks.example.com/other"); other == key {
+		t.Error("another destination got the same key")
+	}
+	changed := jane
+	changed.Title = "Senior Valve Engineer"
+	if other := keyOf(changed, dest); other == key {
+		t.Error("a candidate whose data changed got the same key")
+	}
+}
+
+func TestOutboxDropsTornAppend(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.jsonl")
+	o := openTestOutbox(t, path)
+	candidates := []Candidate{{ProfileURL: "https://www.linkedin.com/in/jane-doe"}, {ProfileURL: "https://www.linkedin.com/in/john-roe"}}
+	if _, err := o.enqueue(candidates, []string{"https://hooks.example.com/a"}); err != nil {
+		t.Fatal(err)
+	}
+	o.Close()
+
+	// A crash mid-append leaves a record without its newline.
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"op":"add","key":"abc","entry":{"key":"ab`)
+	f.Close()
+
+	o = openTestOutbox(t, path)
+	if got := len(o.entries()); got != 2 {
+		t.Fatalf("%d entries pending after the torn append, want 2", got)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(string(data), "\n"); len(lines) != 3 || lines[2] != "" {
+		t.Errorf("journal not rewritten without the torn record:\n%s", data)
+	}
+
+	// Anything unreadable before the last line is not a crash's doing.
+	o.Close()
+	if err := os.WriteFile(path, append([]byte("not json\n"), data...), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := openOutbox(path); err == nil {
+		t.Error("a corrupt journal line was accepted")
+	}
+}
+
+func TestOutboxResumesAfterRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.jsonl")
+	const dest = "https://hooks.example.com/a"
+	candidates := []Candidate{
+		{ProfileURL: "https://www.linkedin.com/in/jane-doe"},
+		{ProfileURL: "https://www.linkedin.com/in/john-roe"},
+		{ProfileURL: "https://www.linkedin.com/in/ann-poe"},
+	}
+	o := openTestOutbox(t, path)
+	if queued, err := o.enqueue(candidates, []string{dest}); err != nil || queued != 3 {
+		t.Fatalf("queued %d, %v; want 3", queued, err)
+	}
+	// John's receiver is down.
+	failJohn := func(ctx context.Context, e outboxEntry) error {
+		if e.ProfileURL == candidates[1].ProfileURL {
+			return errors.New("webhook returned status 503")
+		}
+		return nil
+	}
+	if delivered, failed, err := o.drain(context.Background(), failJohn); err != nil || delivered != 2 || failed != 1 {
+		t.Fatalf("drain = %d delivered, %d failed, %v; want 2, 1", delivered, failed, err)
+	}
+	o.Close()
+
+	// The next run resumes with John's delivery alone, and does not queue
+	// it twice.
+	o = openTestOutbox(t, path)
+	pending := o.entries()
+	if len(pending) != 1 || pending[0].ProfileURL != candidates[1].ProfileURL || pending[0].Attempts != 1 {
+		t.Fatalf("pending after restart = %+v, want John's entry after 1 attempt", pending)
+	}
+	if queued, err := o.enqueue(candidates[1:2], []string{dest}); err != nil || queued != 0 {
+		t.Errorf("queued %d, %v for a pending delivery; want 0", queued, err)
+	}
+	var sent []outboxEntry
+	record := func(ctx context.Context, e outboxEntry) error {
+		sent = append(sent, e)
+		return nil
+	}
+	if _, _, err := o.drain(context.Background(), record); err != nil {
+		t.Fatal(err)
+	}
+	if len(sent) != 1 || sent[0].Key != pending[0].Key {
+		t.Errorf("delivered %+v after restart, want John's entry under its key", sent)
+	}
+	o.Close()
+
+	if o = openTestOutbox(t, path); len(o.entries()) != 0 {
+		t.Errorf("entries still pending after delivery: %+v", o.entries())
+	}
+}
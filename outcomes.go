@@ -0,0 +1,221 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"time"
+)
+
+// Values of outcomeRecord.Outcome.
+const (
+	outcomeOK      = "ok"
+	outcomeBlocked = "blocked" // A CAPTCHA or rate-limit response.
+	outcomeStatus  = "status"  // Any other non-200 response.
+	outcomeError   = "error"   // No response at all.
+)
+
+// preBlockWindow is how far before the first block the request rate is
+// measured.
+const preBlockWindow = 5 * time.Minute
+
+// outcomeRecord is one outbound request and how it ended.
+type outcomeRecord struct {
+	URL     string         `json:"url"`
+	Host    string         `json:"host"`
+	Proxy   string         `json:"proxy,omitempty"`  // Empty for a direct connection.
+	Region  string         `json:"region,omitempty"` // Of the proxy, from its -proxy-file label.
+	Attempt int            `json:"attempt"`          // 1 for the first request of URL in the run.
+	Time    time.Time      `json:"time"`
+	Status  int            `json:"status,omitempty"`
+	Outcome string         `json:"outcome"`
+	Error   string         `json:"error,omitempty"`
+	Backoff time.Duration  `json:"backoff_ns,omitempty"` // Delay applied before the next attempt.
+	Timing  *requestTiming `json:"timing,omitempty"`     // Phases of the request, once its body is closed.
+}
+
+// classifyOutcome names how a request ended.
+func classifyOutcome(status int, err error) string {
+	switch {
+	case err != nil:
+		return outcomeError
+	case status == http.StatusOK:
+		return outcomeOK
+	case status == http.StatusTooManyRequests || status == http.StatusFound || status == 999:
+		return outcomeBlocked
+	}
+	return outcomeStatus
+}
+
+// recordOutcome adds a request sent at sent, through proxy of region, to the
+// outcome timeline.
+func (s *RunStats) recordOutcome(pageURL, host, proxy, region string, sent time.Time, status int, err error) {
+	r := outcomeRecord{URL: pageURL, Host: host, Proxy: proxy, Region: region, Time: sent, Status: status, Outcome: classifyOutcome(status, err)}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attempts[pageURL]++
+	r.Attempt = s.attempts[pageURL]
+	s.Outcomes = append(s.Outcomes, r)
+}
+
+// recordBackoff notes the delay applied after the latest request of pageURL.
+func (s *RunStats) recordBackoff(pageURL string, d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := len(s.Outcomes) - 1; i >= 0; i-- {
+		if s.Outcomes[i].URL == pageURL {
+			s.Outcomes[i].Backoff = d
+			return
+		}
+	}
+}
+
+// outcomes returns a copy of the outcome timeline.
+func (s *RunStats) outcomes() []outcomeRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]outcomeRecord(nil), s.Outcomes...)
+}
+
+// proxyFailures is the failure rate of one proxy.
+type proxyFailures struct {
+	Proxy    string
+	Requests int
+	Failures int // Blocked or errored requests.
+}
+
+// rate returns the share of failed requests.
+func (p proxyFailures) rate() float64 {
+	if p.Requests == 0 {
+		return 0
+	}
+	return float64(p.Failures) / float64(p.Requests)
+}
+
+// outcomeAnalysis is what the outcome timeline says about blocking.
+type outcomeAnalysis struct {
+	Requests   int
+	Blocked    int
+	FirstBlock *outcomeRecord
+	// PreBlockRate is the requests per minute in the preBlockWindow before
+	// FirstBlock, the block itself excluded.
+	PreBlockRate float64
+	WorstProxy   *proxyFailures // Nil when no proxy failed.
+	BlocksByHost map[string]int
+}
+
+// analyzeOutcomes finds the first block, the proxy with the worst failure
+// rate, and the request rate leading up to the block.
+func analyzeOutcomes(records []outcomeRecord) outcomeAnalysis {
+	a := outcomeAnalysis{Requests: len(records), BlocksByHost: make(map[string]int)}
+	sorted := append([]outcomeRecord(nil), records...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Time.Before(sorted[j].Time) })
+
+	proxies := make(map[string]*proxyFailures)
+	for i, r := range sorted {
+		if r.Outcome == outcomeBlocked {
+			a.Blocked++
+			a.BlocksByHost[r.Host]++
+			if a.FirstBlock == nil {
+				a.FirstBlock = &sorted[i]
+			}
+		}
+		name := r.Proxy
+		if name == "" {
+			name = "direct"
+		}
+		p := proxies[name]
+		if p == nil {
+			p = &proxyFailures{Proxy: name}
+			proxies[name] = p
+		}
+		p.Requests++
+		if r.Outcome == outcomeBlocked || r.Outcome == outcomeError {
+			p.Failures++
+		}
+	}
+
+	// Ties go to the alphabetically first proxy, so results do not depend on
+	// map order.
+	for _, p := range proxies {
+		if p.Failures == 0 {
+			continue
+		}
+		if w := a.WorstProxy; w == nil || p.rate() > w.rate() || (p.rate() == w.rate() && p.Proxy < w.Proxy) {
+			a.WorstProxy = p
+		}
+	}
+
+	if a.FirstBlock != nil {
+		from := a.FirstBlock.Time.Add(-preBlockWindow)
+		n := 0
+		for _, r := range sorted {
+			if !r.Time.Before(from) && r.Time.Before(a.FirstBlock.Time) {
+				n++
+			}
+		}
+		a.PreBlockRate = float64(n) / preBlockWindow.Minutes()
+	}
+	return a
+}
+
+// print writes the analysis to stdout.
+func (a outcomeAnalysis) print() {
+	fmt.Printf("Block analysis: %d of %d requests blocked\n", a.Blocked, a.Requests)
+	if a.FirstBlock != nil {
+		fmt.Printf("  First block: %s at %s (attempt %d, status %d, proxy %s)\n",
+			a.FirstBlock.URL, a.FirstBlock.Time.Format(time.RFC3339), a.FirstBlock.Attempt, a.FirstBlock.Status, firstNonEmpty(a.FirstBlock.Proxy, "direct"))
+		fmt.Printf("  Request rate in the %s before it: %.1f per minute\n", preBlockWindow, a.PreBlockRate)
+	}
+	hosts := make([]string, 0, len(a.BlocksByHost))
+	for host := range a.BlocksByHost {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	for _, host := range hosts {
+		fmt.Printf("  Blocks on %s: %d\n", host, a.BlocksByHost[host])
+	}
+	if a.WorstProxy != nil {
+		fmt.Printf("  Worst proxy: %s (%d of %d requests failed)\n", a.WorstProxy.Proxy, a.WorstProxy.Failures, a.WorstProxy.Requests)
+	}
+}
+
+// writeOutcomes writes records as JSON lines.
+func writeOutcomes(records []outcomeRecord, filename string) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create outcomes file: %w", err)
+	}
+	defer file.Close()
+	enc := json.NewEncoder(file)
+	for _, r := range records {
+		if err := enc.Encode(r); err != nil {
+			return fmt.Errorf("failed to write outcomes file: %w", err)
+		}
+	}
+	return file.Close()
+}
+
+// reportOutcomes writes the run's outcome timeline to filename and prints the
+// block analysis when any request failed. It is meant for defer.
+func reportOutcomes(outputs *outputDispatcher, filename string) {
+	records := stats.outcomes()
+	if len(records) == 0 {
+		return
+	}
+	if err := outputs.deliver(outputOutcomes, func() error { return writeOutcomes(records, filename) }); err != nil {
+		log.Print(err)
+	}
+	if a := analyzeOutcomes(records); a.Blocked > 0 || a.WorstProxy != nil {
+		a.print()
+	}
+	for _, line := range latencySummary(records) {
+		verbosef("%s", line)
+	}
+}
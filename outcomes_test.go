@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestClassifyOutcome(t *testing.T) {
+	tests := []struct {
+		status int
+		err    error
+		want   string
+	}{
+		{200, nil, outcomeOK},
+		{429, nil, outcomeBlocked},
+		{302, nil, outcomeBlocked},
+		{999, nil, outcomeBlocked},
+		{404, nil, outcomeStatus},
+		{0, errors.New("connection reset"), outcomeError},
+	}
+	for _, tt := range tests {
+		if got := classifyOutcome(tt.status, tt.err); got != tt.want {
+			t.Errorf("classifyOutcome(%d, %v) = %s, want %s", tt.status, tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestOutcomeAttemptsAndBackoff(t *testing.T) {
+	s := newRunStats()
+	sent := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	s.recordOutcome("https://www.google.com/search?q=a", "www.google.com", "", "", sent, 429, nil)
+	s.recordBackoff("https://www.google.com/search?q=a", 30*time.Second)
+	s.recordOutcome("https://www.linkedin.com/in/jane-doe", "www.linkedin.com", "", "", sent, 200, nil)
+	s.recordOutcome("https://www.google.com/search?q=a", "www.google.com", "", "", sent.Add(30*time.Second), 200, nil)
+
+	records := s.outcomes()
+	if len(records) != 3 {
+		t.Fatalf("%d records, want 3", len(records))
+	}
+	if records[0].Attempt != 1 || records[0].Backoff != 30*time.Second || records[0].Outcome != outcomeBlocked {
+		t.Errorf("first record %+v, want attempt 1, blocked, with a 30s backoff", records[0])
+	}
+	if records[1].Attempt != 1 || records[2].Attempt != 2 || records[2].Backoff != 0 {
+		t.Errorf("records %+v, want the retry as attempt 2 and no backoff on it", records[1:])
+	}
+}
+
+func TestAnalyzeOutcomes(t *testing.T) {
+	start := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	at := func(minutes int, host, proxy, outcome string) outcomeRecord {
+		return outcomeRecord{URL: "https://" + host + "/", Host: host, Proxy: proxy, Time: start.Add(time.Duration(minutes) * time.Minute), Outcome: outcome}
+	}
+	records := []outcomeRecord{
+		// Out of order: the analysis goes by time.
+		at(9, "www.linkedin.com", "http://b:1", outcomeBlocked),
+		at(0, "www.google.com", "http://a:1", outcomeOK),
+		at(4, "www.google.com", "http://a:1", outcomeOK),
+		at(5, "www.google.com", "http://a:1", outcomeOK),
+		at(6, "www.google.com", "http://a:1", outcomeOK),
+		at(7, "www.google.com", "http://b:1", outcomeBlocked),
+		at(8, "www.linkedin.com", "", outcomeError),
+		at(8, "www.linkedin.com", "", outcomeOK),
+	}
+	a := analyzeOutcomes(records)
+	if a.Requests != 8 || a.Blocked != 2 {
+		t.Errorf("analysis %+v, want 2 of 8 blocked", a)
+	}
+	if a.FirstBlock == nil || !a.FirstBlock.Time.Equal(start.Add(7*time.Minute)) {
+		t.Fatalf("first block %+v, want the one at 9:07", a.FirstBlock)
+	}
+	// 9:04, 9:05, and 9:06 fall in the five minutes before it.
+	if want := 3 / preBlockWindow.Minutes(); a.PreBlockRate != want {
+		t.Errorf("pre-block rate %v, want %v", a.PreBlockRate, want)
+	}
+	if a.WorstProxy == nil || a.WorstProxy.Proxy != "http://b:1" || a.WorstProxy.rate() != 1 {
+		t.Errorf("worst proxy %+v, want http://b:1 failing every request", a.WorstProxy)
+	}
+	if a.BlocksByHost["www.google.com"] != 1 || a.BlocksByHost["www.linkedin.com"] != 1 {
+		t.Errorf("blocks by host %v", a.BlocksByHost)
+	}
+
+	if a := analyzeOutcomes(records[1:5]); a.FirstBlock != nil || a.WorstProxy != nil {
+		t.Errorf("a clean run analyzed as %+v", a)
+	}
+}
+
+func TestOutcomesWrittenToRunDirectory(t *testing.T) {
+	_, addr := startFakeWeb(t, `profiles:
+  - slug: jane-doe
+    name: Jane Doe
+  - slug: john-roe
+    name: John Roe
+    behavior: captcha
+`)
+	output, err := runFakeSearch(t, addr, "-max-pages", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(filepath.Join(filepath.Dir(output), "outcomes.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	outcomes := make(map[string]string)
+	for sc := bufio.NewScanner(file); sc.Scan(); {
+		var r outcomeRecord
+		if err := json.Unmarshal(sc.Bytes(), &r); err != nil {
+			t.Fatalf("bad outcome line %q: %v", sc.Text(), err)
+		}
+		if r.Attempt == 1 {
+			outcomes[r.URL] = r.Outcome
+		}
+	}
+	if got := outcomes["https://www.linkedin.com/in/jane-doe"]; got != outcomeOK {
+		t.Errorf("jane-doe fetched with outcome %q, want ok", got)
+	}
+	if got := outcomes["https://www.linkedin.com/in/john-roe"]; got != outcomeBlocked {
+		t.Errorf("john-roe fetched with outcome %q, want blocked", got)
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Outputs a run can write besides its log, named as in -critical-outputs.
+const (
+	outputCSV          = "csv"
+	outputStore        = "store"
+	outputHTMLReport   = "html-report"
+	outputExplain      = "explain"
+	outputFieldSources = "field-sources"
+	outputOutcomes     = "outcomes"
+	outputRunConfig    = "run-config"
+	outputDomains      = "domains"
+	outputWebhook      = "webhook" // Queuing deliveries in the outbox; failed deliveries stay queued.
+	outputAlerts       = "alerts"  // Queuing -alert-rules alerts in the outbox.
+)
+
+// knownOutputs lists every output name.
+var knownOutputs = []string{outputCSV, outputStore, outputHTMLReport, outputExplain, outputFieldSources, outputOutcomes, outputRunConfig, outputDomains, outputWebhook, outputAlerts}
+
+// defaultCriticalOutputs are the outputs whose failure fails the run: the
+// results themselves. The rest are best-effort.
+const defaultCriticalOutputs = outputCSV + "," + outputStore
+
+const (
+	bestEffortAttempts   = 2 // Tries of a best-effort output before it is disabled.
+	bestEffortRetryDelay = time.Second
+)
+
+// exitOutputFailure is the exit code of a run that failed to write a critical
+// output.
+const exitOutputFailure = 3
+
+// outputError is the failure of a critical output.
+type outputError struct {
+	output string
+	err    error
+}
+
+func (e *outputError) Error() string {
+	return fmt.Sprintf("writing %s failed: %v", e.output, e.err)
+}
+
+func (e *outputError) Unwrap() error { return e.err }
+
+// outputDispatcher applies the degradation policy to every output write. A
+// critical output's failure is returned as an *outputError; a best-effort
+// output is retried briefly, then disabled for the rest of the run with a
+// single warning and listed in the summary.
+type outputDispatcher struct {
+	critical map[string]bool
+
+	mu       sync.Mutex
+	disabled map[string]error // Best-effort outputs disabled, with their last error.
+}
+
+// newOutputDispatcher builds a dispatcher treating the comma-separated
+// outputs in criticalSpec as critical.
+func newOutputDispatcher(criticalSpec string) (*outputDispatcher, error) {
+	d := &outputDispatcher{critical: make(map[string]bool), disabled: make(map[string]error)}
+	for _, name := range strings.Split(criticalSpec, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !containsString(knownOutputs, name) {
+			return nil, fmt.Errorf("unknown output %q (want one of %s)", name, strings.Join(knownOutputs, ", "))
+		}
+		d.critical[name] = true
+	}
+	return d, nil
+}
+
+// containsString reports whether list contains s.
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// enabled reports whether output has not been disabled.
+func (d *outputDispatcher) enabled(output string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	_, off := d.disabled[output]
+	return !off
+}
+
+// deliver runs write for output under the policy. A disabled output is
+// skipped; a best-effort write is retried before the output is disabled.
+func (d *outputDispatcher) deliver(output string, write func() error) error {
+	if !d.enabled(output) {
+		return nil
+	}
+	err := write()
+	if err != nil && !d.critical[output] {
+		for attempt := 1; attempt < bestEffortAttempts && err != nil; attempt++ {
+			time.Sleep(bestEffortRetryDelay)
+			err = write()
+		}
+	}
+	return d.fail(output, err)
+}
+
+// fail applies the policy to a failure that cannot be retried, such as an
+// error found when closing a file. It returns nil for a nil err.
+func (d *outputDispatcher) fail(output string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if d.critical[output] {
+		return &outputError{output: output, err: err}
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, off := d.disabled[output]; !off {
+		log.Printf("WARN: %s output failed and is disabled for the rest of the run: %v", output, err)
+		d.disabled[output] = err
+	}
+	return nil
+}
+
+// summary prints the outputs disabled during the run, if any.
+func (d *outputDispatcher) summary() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.disabled) == 0 {
+		return
+	}
+	names := make([]string, 0, len(d.disabled))
+	for name := range d.disabled {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Println("Outputs disabled during the run:")
+	for _, name := range names {
+		fmt.Printf("  %s: %v\n", name, d.disabled[name])
+	}
+}
+
+// exitCode returns the process exit code for a failed command.
+func exitCode(err error) int {
+	var oe *outputError
+	if errors.As(err, &oe) {
+		return exitOutputFailure
+	}
+	return 1
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+// skipWithoutFullDevice skips a test on systems lacking /dev/full, whose
+// writes fail as on a full disk.
+func skipWithoutFullDevice(t *testing.T) {
+	t.Helper()
+	if _, err := os.Stat("/dev/full"); err != nil {
+		t.Skip("no /dev/full")
+	}
+}
+
+func TestWriteToCSVReportsFlushError(t *testing.T) {
+	skipWithoutFullDevice(t)
+	columns, err := selectCSVColumns(defaultColumns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	candidates := []Candidate{{Name: "Jane Doe", ProfileURL: "https://www.linkedin.com/in/jane-doe"}}
+	for _, format := range []csvFormat{{}, {quoteAll: true}} {
+		if err := writeToCSV(candidates, "/dev/full", columns, nil, format); err == nil {
+			t.Errorf("writing %+v to a full disk succeeded", format)
+		}
+	}
+}
+
+func TestFullDiskFailsRunOnlyForCriticalOutput(t *testing.T) {
+	skipWithoutFullDevice(t)
+	_, addr := startFakeWeb(t, e2eRoster)
+	_, err := runFakeSearch(t, addr, "-output", "/dev/full")
+	if code := commandExitCode(err); code != exitOutputFailure {
+		t.Errorf("CSV on a full disk: exit code %d (%v), want %d", code, err, exitOutputFailure)
+	}
+	_, err = runFakeSearch(t, addr, "-output", "/dev/full", "-critical-outputs", outputStore)
+	if code := commandExitCode(err); code != 0 {
+		t.Errorf("best-effort CSV on a full disk: exit code %d (%v), want 0", code, err)
+	}
+}
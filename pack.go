@@ -0,0 +1,494 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"gopkg.in/yaml.v3"
+)
+
+// packFormat is the version of the pack archive layout. unpack refuses
+// archives of a later format.
+const packFormat = 1
+
+// Members of a pack archive besides the packed files.
+const (
+	packManifestName = "manifest.json"
+	packConfigName   = "config.yaml"
+	packFilesDir     = "files" // Holds one entry per packed flag, named after it.
+)
+
+// Kinds of packed flags.
+const (
+	packInput = "input" // A file the run reads; the archive is incomplete without it.
+	packState = "state" // A file or directory the run keeps; absent, the run starts it fresh.
+	packPages = "pages" // Recorded pages; packed only with -with-pages.
+)
+
+// packedFlags are the flags whose files pack bundles.
+var packedFlags = []struct {
+	flag string
+	kind string
+}{
+	{"jobs", packInput},
+	{"extractors", packInput},
+	{"alert-rules", packInput},
+	{"company-domains", packInput},
+	{"contacted", packInput},
+	{"blocklist-names", packInput},
+	{"template-file", packInput},
+	{"result-selectors", packInput},
+	{"optout-list", packState},
+	{"store", packState}, // Also holds the negative cache and the learned rates.
+	{"run-dir", packState},
+	{"outbox", packState},
+	{"record", packPages},
+	{"replay", packPages},
+}
+
+// packManifest describes a pack archive. It is the first member.
+type packManifest struct {
+	Format  int               `json:"format"`
+	Version string            `json:"version"` // Of the profilesearch that packed it.
+	Created time.Time         `json:"created"`
+	Flags   []packFlag        `json:"flags"`
+	Secrets map[string]string `json:"secrets,omitempty"` // Environment variable holding each secret flag's value.
+	Members []packMember      `json:"members"`
+}
+
+// packFlag is a flag whose value is a path in the archive.
+type packFlag struct {
+	Flag string `json:"flag"`
+	Kind string `json:"kind"`
+	Path string `json:"path"` // Relative to the archive root.
+}
+
+// packMember is a file in the archive, with its checksum.
+type packMember struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+
+	source string // Packed from this file.
+}
+
+// secretEnvVar names the environment variable standing in for a secret flag.
+func secretEnvVar(flagName string) string {
+	return "PROFILESEARCH_" + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// isSecretFlag reports whether a flag's value must not be packed: a
+// secret-looking name, credentials in a URL, or the proxy file, whose proxy
+// URLs usually carry them.
+func isSecretFlag(name, value string) bool {
+	return name == "proxy-file" || secretFlagPattern.MatchString(name) || urlCredentialsPattern.MatchString(value)
+}
+
+// hashFile returns the size and SHA-256 of a file.
+func hashFile(filename string) (int64, string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return 0, "", err
+	}
+	defer file.Close()
+	h := sha256.New()
+	n, err := io.Copy(h, file)
+	if err != nil {
+		return 0, "", err
+	}
+	return n, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// packMembers lists the files under source, a file or a directory, as
+// members under name.
+func packMembers(source, name string) ([]packMember, error) {
+	var members []packMember
+	err := filepath.WalkDir(source, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(source, p)
+		if err != nil {
+			return err
+		}
+		size, sum, err := hashFile(p)
+		if err != nil {
+			return err
+		}
+		members = append(members, packMember{Name: path.Join(name, filepath.ToSlash(rel)), Size: size, SHA256: sum, source: p})
+		return nil
+	})
+	return members, err
+}
+
+// buildPack collects what an archive of the run configured by flags holds:
+// the manifest and the config file of the flags set.
+func buildPack(flags *flag.FlagSet, withPages bool) (*packManifest, []byte, error) {
+	m := &packManifest{Format: packFormat, Version: version, Created: clockNow().UTC(), Secrets: make(map[string]string)}
+	settings := make(map[string]string)
+	var setErr error
+	flags.Visit(func(f *flag.Flag) {
+		value := f.Value.String()
+		switch {
+		case setErr != nil || f.Name == "config":
+			return // Its settings are applied to flags already.
+		case isSecretFlag(f.Name, value):
+			m.Secrets[f.Name] = secretEnvVar(f.Name)
+			return
+		}
+		settings[f.Name] = value
+		for _, pf := range packedFlags {
+			if pf.flag != f.Name || value == "" {
+				continue
+			}
+			if pf.kind == packPages && !withPages {
+				log.Printf("Not packing the pages in %s without -with-pages.", value)
+				continue
+			}
+			// Formats of some files are chosen by extension.
+			entry := packFlag{Flag: f.Name, Kind: pf.kind, Path: path.Join(packFilesDir, f.Name) + filepath.Ext(value)}
+			if _, err := os.Stat(value); err == nil || pf.kind == packInput {
+				members, err := packMembers(value, entry.Path)
+				if err != nil {
+					setErr = fmt.Errorf("failed to pack -%s: %w", f.Name, err)
+					return
+				}
+				m.Members = append(m.Members, members...)
+			} else if !errors.Is(err, fs.ErrNotExist) {
+				setErr = fmt.Errorf("failed to pack -%s: %w", f.Name, err)
+				return
+			}
+			m.Flags = append(m.Flags, entry)
+			settings[f.Name] = entry.Path
+		}
+	})
+	if setErr != nil {
+		return nil, nil, setErr
+	}
+	config, err := yaml.Marshal(settings)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to encode config: %w", err)
+	}
+	sum := sha256.Sum256(config)
+	m.Members = append([]packMember{{Name: packConfigName, Size: int64(len(config)), SHA256: hex.EncodeToString(sum[:])}}, m.Members...)
+	return m, config, nil
+}
+
+// writePack writes the archive of m to w: the manifest, the config file,
+// then the packed files, as a zstd-compressed tar.
+func writePack(w io.Writer, m *packManifest, config []byte) error {
+	zw, err := zstd.NewWriter(w)
+	if err != nil {
+		return err
+	}
+	defer zw.Close()
+	tw := tar.NewWriter(zw)
+	manifest, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	writeBytes := func(name string, b []byte) error {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(b)), ModTime: m.Created}); err != nil {
+			return err
+		}
+		_, err := tw.Write(b)
+		return err
+	}
+	if err := writeBytes(packManifestName, manifest); err != nil {
+		return err
+	}
+	for _, member := range m.Members {
+		if member.source == "" {
+			if err := writeBytes(member.Name, config); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := writePackFile(tw, member, m.Created); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// zstdMagic opens every zstd frame.
+var zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+// decompressPack returns a reader of the tar in a pack archive, which is
+// zstd-compressed, or gzip-compressed when an earlier profilesearch packed
+// it. The caller closes the reader.
+func decompressPack(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	magic, _ := br.Peek(len(zstdMagic))
+	if bytes.Equal(magic, zstdMagic) {
+		zr, err := zstd.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		return zr.IOReadCloser(), nil
+	}
+	return gzip.NewReader(br)
+}
+
+// writePackFile adds a packed file to tw. A file that changed since it was
+// checksummed fails the pack.
+func writePackFile(tw *tar.Writer, member packMember, modTime time.Time) error {
+	file, err := os.Open(member.source)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err := tw.WriteHeader(&tar.Header{Name: member.Name, Mode: 0o644, Size: member.Size, ModTime: modTime}); err != nil {
+		return err
+	}
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tw, h), io.LimitReader(file, member.Size)); err != nil {
+		return fmt.Errorf("failed to pack %s: %w", member.source, err)
+	}
+	if hex.EncodeToString(h.Sum(nil)) != member.SHA256 {
+		return fmt.Errorf("%s changed while packing it", member.source)
+	}
+	return nil
+}
+
+// runPackCommand implements `profilesearch pack`: it bundles the settings and
+// files of the run the search flags after "--" configure into one archive.
+func runPackCommand(args []string) error {
+	fs := flag.NewFlagSet("pack", flag.ExitOnError)
+	output := fs.String("o", "run.tar.zst", "archive to write")
+	withPages := fs.Bool("with-pages", false, "also pack the recorded pages of -record and -replay")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: profilesearch pack [-o run.tar.zst] [-with-pages] -- search flags")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	cfg, err := parseFlags(fs.Args())
+	if err != nil {
+		return err
+	}
+	if cfg.storePath != "" {
+		// A run saving to the store meanwhile would tear the copy.
+		lock, err := lockStore(cfg.storePath)
+		if err != nil {
+			return err
+		}
+		defer lock.unlock()
+	}
+	m, config, err := buildPack(cfg.flags, *withPages)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(*output, func(w io.Writer) error { return writePack(w, m, config) }); err != nil {
+		return fmt.Errorf("failed to write %s: %w", *output, err)
+	}
+	fmt.Printf("Packed %d files into %s\n", len(m.Members), *output)
+	for _, name := range sortedKeys(m.Secrets) {
+		fmt.Printf("  -%s is not packed; set %s where the archive is unpacked\n", name, m.Secrets[name])
+	}
+	return nil
+}
+
+// sortedKeys returns the keys of m in order.
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// safeMemberName reports whether an archive member name stays inside the
+// directory it is unpacked to.
+func safeMemberName(name string) bool {
+	clean := path.Clean(name)
+	return clean == name && !path.IsAbs(clean) && clean != ".." && !strings.HasPrefix(clean, "../")
+}
+
+// unpack restores the archive read from r into dir and returns its manifest
+// and the names of the members it lacked. A member whose checksum does not
+// match, or one the manifest does not list, fails the unpack.
+func unpack(r io.Reader, dir string) (*packManifest, map[string]bool, error) {
+	zr, err := decompressPack(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("not a pack archive: %w", err)
+	}
+	defer zr.Close()
+	tr := tar.NewReader(zr)
+	hdr, err := tr.Next()
+	if err != nil || hdr.Name != packManifestName {
+		return nil, nil, errors.New("not a pack archive: no manifest")
+	}
+	var m packManifest
+	if err := json.NewDecoder(tr).Decode(&m); err != nil {
+		return nil, nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+	if m.Format > packFormat {
+		return nil, nil, fmt.Errorf("archive format %d is newer than this profilesearch (%s) reads; upgrade it", m.Format, version)
+	}
+	if m.Version != version {
+		log.Printf("The archive was packed by profilesearch %s; this is %s.", m.Version, version)
+	}
+	missing := make(map[string]bool, len(m.Members))
+	members := make(map[string]packMember, len(m.Members))
+	for _, member := range m.Members {
+		if !safeMemberName(member.Name) {
+			return nil, nil, fmt.Errorf("invalid manifest: member %q", member.Name)
+		}
+		members[member.Name] = member
+		missing[member.Name] = true
+	}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read archive: %w", err)
+		}
+		member, ok := members[hdr.Name]
+		if !ok || !missing[hdr.Name] {
+			return nil, nil, fmt.Errorf("archive member %s is not in the manifest", hdr.Name)
+		}
+		if err := unpackFile(tr, filepath.Join(dir, filepath.FromSlash(member.Name)), member); err != nil {
+			return nil, nil, err
+		}
+		delete(missing, hdr.Name)
+	}
+	return &m, missing, nil
+}
+
+// unpackFile writes a member to filename, checking its checksum. A file
+// that fails the check is removed.
+func unpackFile(r io.Reader, filename string, member packMember) error {
+	if err := os.MkdirAll(filepath.Dir(filename), 0o755); err != nil {
+		return err
+	}
+	h := sha256.New()
+	err := writeFileAtomic(filename, func(w io.Writer) error {
+		n, err := io.Copy(io.MultiWriter(w, h), r)
+		if err == nil && n != member.Size {
+			err = fmt.Errorf("%s is %d bytes, not %d", member.Name, n, member.Size)
+		}
+		if err == nil && hex.EncodeToString(h.Sum(nil)) != member.SHA256 {
+			err = fmt.Errorf("%s does not match its checksum; the archive is damaged or was altered", member.Name)
+		}
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to unpack %s: %w", member.Name, err)
+	}
+	return nil
+}
+
+// resumeSettings rewrites the packed config for an archive unpacked into dir:
+// packed paths point into dir, and flags whose members are missing are
+// dropped or fail the unpack, by kind.
+func resumeSettings(config []byte, m *packManifest, missing map[string]bool, dir string) (map[string]string, error) {
+	settings := make(map[string]string)
+	if err := yaml.Unmarshal(config, &settings); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", packConfigName, err)
+	}
+	for _, pf := range m.Flags {
+		lacking := 0
+		for name := range missing {
+			if name == pf.Path || strings.HasPrefix(name, pf.Path+"/") {
+				lacking++
+			}
+		}
+		switch {
+		case lacking > 0 && pf.Kind == packInput:
+			return nil, fmt.Errorf("the archive lacks %s, which -%s needs", pf.Path, pf.Flag)
+		case lacking > 0 && pf.Kind == packPages:
+			log.Printf("The archive lacks %d of the pages of -%s; leaving it out.", lacking, pf.Flag)
+			delete(settings, pf.Flag)
+			continue
+		case lacking > 0:
+			log.Printf("The archive lacks %d files of -%s; the run continues from what is there.", lacking, pf.Flag)
+		}
+		settings[pf.Flag] = filepath.Join(dir, filepath.FromSlash(pf.Path))
+	}
+	return settings, nil
+}
+
+// runUnpackCommand implements `profilesearch unpack`: it restores a pack
+// archive and prints the command line resuming the run.
+func runUnpackCommand(args []string) error {
+	fs := flag.NewFlagSet("unpack", flag.ExitOnError)
+	dir := fs.String("d", ".", "directory to unpack into")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: profilesearch unpack [-d dir] run.tar.zst")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("unpack needs one archive")
+	}
+	absDir, err := filepath.Abs(*dir)
+	if err != nil {
+		return err
+	}
+	file, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer file.Close()
+
+	m, missing, err := unpack(file, absDir)
+	if err != nil {
+		return err
+	}
+	configPath := filepath.Join(absDir, packConfigName)
+	if missing[packConfigName] {
+		return fmt.Errorf("the archive lacks %s", packConfigName)
+	}
+	config, err := os.ReadFile(configPath)
+	if err != nil {
+		return err
+	}
+	settings, err := resumeSettings(config, m, missing, absDir)
+	if err != nil {
+		return err
+	}
+	if config, err = yaml.Marshal(settings); err != nil {
+		return fmt.Errorf("failed to encode config: %w", err)
+	}
+	if err := writeFileAtomic(configPath, func(w io.Writer) error {
+		_, err := w.Write(config)
+		return err
+	}); err != nil {
+		return fmt.Errorf("failed to write %s: %w", configPath, err)
+	}
+
+	fmt.Printf("Unpacked %d files into %s\n", len(m.Members)-len(missing), absDir)
+	command := "profilesearch -config " + configPath
+	for _, name := range sortedKeys(m.Secrets) {
+		command += fmt.Sprintf(` -%s "$%s"`, name, m.Secrets[name])
+	}
+	fmt.Println("Resume the run with:")
+	fmt.Println("  " + command)
+	return nil
+}
@@ -0,0 +1,452 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"time"
+)
+
+// Output formats for -format.
+const (
+	formatCSV      = "csv"
+	formatParquet  = "parquet"
+	formatAtom     = "atom"     // New candidates added to an Atom feed, in atom.go.
+	formatTemplate = "template" // A -template-file, in template.go.
+	formatJSON     = "json"     // One object of the run info and the candidates, in jsonoutput.go.
+	formatJSONL    = "jsonl"    // The run info, then a candidate per line, in jsonoutput.go.
+	formatXLSX     = "xlsx"     // A spreadsheet of -columns, in xlsx.go.
+)
+
+// parquetMagic opens and closes every Parquet file.
+const parquetMagic = "PAR1"
+
+// Parquet physical types, repetitions, converted types, and encodings, as
+// numbered in the format's Thrift definitions.
+const (
+	parquetBoolean   = 0
+	parquetInt64     = 2
+	parquetDouble    = 5
+	parquetByteArray = 6
+
+	parquetRequired = 0
+	parquetOptional = 1
+
+	parquetUTF8            = 0
+	parquetTimestampMillis = 9
+	parquetJSON            = 19
+
+	parquetPlain = 0
+	parquetRLE   = 3
+)
+
+// parquetKind is the Go type of a Parquet column's values.
+type parquetKind int
+
+const (
+	parquetString   parquetKind = iota // string, as UTF-8 text.
+	parquetInt                         // int, as INT64.
+	parquetFloat                       // float64, as DOUBLE.
+	parquetBool                        // bool.
+	parquetTime                        // *time.Time, as milliseconds; null when nil.
+	parquetJSONText                    // Any value, as JSON text; lists become JSON arrays.
+)
+
+// parquetColumn describes a single column of the Parquet output.
+type parquetColumn struct {
+	name  string
+	kind  parquetKind
+	value func(c Candidate) any // Of the type kind names.
+}
+
+// parquetColumns lists every column of the Parquet output, in order. Unlike
+// the CSV, a Parquet file always holds every field, typed, for pipelines
+// to pick from.
+var parquetColumns = []parquetColumn{
+	{"name", parquetString, func(c Candidate) any { return c.Name }},
+	{"email", parquetString, func(c Candidate) any { return c.Email }},
+	{"email_guess", parquetString, func(c Candidate) any { return c.EmailGuess }},
+	{"phone", parquetString, func(c Candidate) any { return c.Phone }},
+	{"profile_url", parquetString, func(c Candidate) any { return c.ProfileURL }},
+	{"experience", parquetInt, func(c Candidate) any { return legacyExperience(c.experienceYears()) }}, // Deprecated: whole years of experience_years.
+	{"experience_years", parquetFloat, func(c Candidate) any { return c.experienceYears() }},
+	{"rank", parquetInt, func(c Candidate) any { return c.Rank }},
+	{"page_position", parquetInt, func(c Candidate) any { return c.PagePosition }},
+	{"overall_position", parquetInt, func(c Candidate) any { return c.OverallPosition }},
+	{"job", parquetString, func(c Candidate) any { return c.Job }},
+	{"title", parquetString, func(c Candidate) any { return c.Title }},
+	{"company", parquetString, func(c Candidate) any { return c.Company }},
+	{"website", parquetString, func(c Candidate) any { return c.Website }},
+	{"twitter", parquetString, func(c Candidate) any { return c.Twitter }},
+	{"location", parquetString, func(c Candidate) any { return c.Location }},
+	{"city", parquetString, func(c Candidate) any { return c.City }},
+	{"state", parquetString, func(c Candidate) any { return c.State }},
+	{"country", parquetString, func(c Candidate) any { return c.Country }},
+	{"profile_language", parquetString, func(c Candidate) any { return c.ProfileLanguage }},
+	{"page_language", parquetString, func(c Candidate) any { return c.PageLanguage }},
+	{"name_slug_mismatch", parquetBool, func(c Candidate) any { return c.NameSlugMismatch }},
+	{"anonymized", parquetBool, func(c Candidate) any { return c.Anonymized }},
+	{"result_type", parquetString, func(c Candidate) any { return c.ResultType }},
+	{"alternate_urls", parquetJSONText, func(c Candidate) any { return c.AlternateURLs }},
+	{"contacted_by", parquetString, func(c Candidate) any { return c.MatchedContactedBy }},
+	{"optout_url", parquetString, func(c Candidate) any { return c.OptOutURL }},
+	{"rediscovered", parquetBool, func(c Candidate) any { return c.Rediscovered }},
+	{"previously_seen", parquetTime, func(c Candidate) any { return c.PreviouslySeen }},
+	{"company_size_band", parquetString, func(c Candidate) any { return c.CompanySizeBand }},
+	{"company_type", parquetString, func(c Candidate) any { return c.CompanyType }},
+	{"employment_match", parquetString, func(c Candidate) any { return c.EmploymentMatch }},
+	{"relaxation_level", parquetInt, func(c Candidate) any { return c.RelaxationLevel }},
+	{"photo_url", parquetString, func(c Candidate) any { return c.PhotoURL }},
+	{"headline", parquetString, func(c Candidate) any { return c.Headline }},
+	{"skills_count", parquetInt, func(c Candidate) any { return c.SkillsCount }},
+	{"has_education", parquetBool, func(c Candidate) any { return c.HasEducation }},
+	{"connections", parquetInt, func(c Candidate) any { return c.Connections }},
+	{"positions", parquetJSONText, func(c Candidate) any { return c.Positions }},
+	{"profile_completeness", parquetInt, func(c Candidate) any { return c.ProfileCompleteness }},
+	{"result_title", parquetString, func(c Candidate) any { return c.ResultTitle }},
+	{"snippet", parquetString, func(c Candidate) any { return c.Snippet }},
+	{"summary", parquetString, func(c Candidate) any { return c.Summary }},
+	{"matched_terms", parquetJSONText, func(c Candidate) any { return c.MatchedTerms }},
+	{"extra", parquetJSONText, func(c Candidate) any {
+		if c.Extra == nil {
+			return map[string]string{} // An empty object, not a list.
+		}
+		return c.Extra
+	}},
+	{"score", parquetInt, func(c Candidate) any { return c.Score }},
+	{"lookup_score", parquetFloat, func(c Candidate) any { return c.LookupScore }},
+	{"lookup_match", parquetString, func(c Candidate) any { return c.LookupMatch }},
+}
+
+// deprecatedColumns explains, by name, the columns kept only for readers of
+// an older format.
+var deprecatedColumns = map[string]string{
+	"experience": "whole years of experience_years, rounded down; removed in the next release",
+}
+
+// parquetKindNames name the kinds for -print-schema.
+var parquetKindNames = map[parquetKind]string{
+	parquetString:   "string",
+	parquetInt:      "int64",
+	parquetFloat:    "double",
+	parquetBool:     "boolean",
+	parquetTime:     "timestamp, null when unknown",
+	parquetJSONText: "JSON text",
+}
+
+// printSchema writes the fields of a candidate, as the Parquet output holds
+// them, one per line with its type and any deprecation.
+func printSchema(w io.Writer) {
+	for _, col := range parquetColumns {
+		line := fmt.Sprintf("%-22s %s", col.name, parquetKindNames[col.kind])
+		if note := deprecatedColumns[col.name]; note != "" {
+			line += " (deprecated: " + note + ")"
+		}
+		fmt.Fprintln(w, line)
+	}
+}
+
+// schema returns the column's physical type, repetition, and converted type
+// (-1 for none).
+func (col parquetColumn) schema() (physical, repetition, converted int) {
+	switch col.kind {
+	case parquetInt:
+		return parquetInt64, parquetRequired, -1
+	case parquetFloat:
+		return parquetDouble, parquetRequired, -1
+	case parquetBool:
+		return parquetBoolean, parquetRequired, -1
+	case parquetTime:
+		return parquetInt64, parquetOptional, parquetTimestampMillis
+	case parquetJSONText:
+		return parquetByteArray, parquetRequired, parquetJSON
+	}
+	return parquetByteArray, parquetRequired, parquetUTF8
+}
+
+// encode returns the PLAIN encoding of the column's non-null values and
+// whether each row has a value.
+func (col parquetColumn) encode(candidates []Candidate) ([]byte, []bool, error) {
+	var buf bytes.Buffer
+	present := make([]bool, len(candidates))
+	var bits []bool
+	for i, c := range candidates {
+		present[i] = true
+		switch v := col.value(c).(type) {
+		case string:
+			writeByteArray(&buf, []byte(v))
+		case int:
+			binary.Write(&buf, binary.LittleEndian, int64(v))
+		case float64:
+			binary.Write(&buf, binary.LittleEndian, math.Float64bits(v))
+		case bool:
+			bits = append(bits, v)
+		case *time.Time:
+			if v == nil {
+				present[i] = false
+				continue
+			}
+			binary.Write(&buf, binary.LittleEndian, v.UnixMilli())
+		default:
+			if col.kind != parquetJSONText {
+				return nil, nil, fmt.Errorf("column %s: unexpected value %T", col.name, v)
+			}
+			b, err := json.Marshal(v)
+			if err != nil {
+				return nil, nil, fmt.Errorf("column %s: %w", col.name, err)
+			}
+			if string(b) == "null" {
+				b = []byte("[]") // An empty list, not a missing one.
+			}
+			writeByteArray(&buf, b)
+		}
+	}
+	if col.kind == parquetBool {
+		buf.Write(packBits(bits))
+	}
+	return buf.Bytes(), present, nil
+}
+
+// writeByteArray writes a PLAIN BYTE_ARRAY value: its length, then its bytes.
+func writeByteArray(buf *bytes.Buffer, b []byte) {
+	binary.Write(buf, binary.LittleEndian, uint32(len(b)))
+	buf.Write(b)
+}
+
+// packBits packs bits eight to a byte, least significant first.
+func packBits(bits []bool) []byte {
+	packed := make([]byte, (len(bits)+7)/8)
+	for i, b := range bits {
+		if b {
+			packed[i/8] |= 1 << (i % 8)
+		}
+	}
+	return packed
+}
+
+// definitionLevels encodes which rows of an optional column have a value,
+// as a length-prefixed run of bit-packed levels of width 1.
+func definitionLevels(present []bool) []byte {
+	var run bytes.Buffer
+	groups := (len(present) + 7) / 8
+	run.Write(binary.AppendUvarint(nil, uint64(groups)<<1|1))
+	run.Write(packBits(present))
+	out := binary.LittleEndian.AppendUint32(nil, uint32(run.Len()))
+	return append(out, run.Bytes()...)
+}
+
+// writeToParquet writes the list of candidates to a Parquet file of
+// parquetColumns, in one uncompressed row group. The run info block, when
+// info is not nil, goes in the file's key/value metadata.
+func writeToParquet(candidates []Candidate, filename string, info *runInfo) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create Parquet file: %w", err)
+	}
+	defer file.Close()
+
+	var body bytes.Buffer
+	body.WriteString(parquetMagic)
+	var chunks thriftWriter // The row group's column chunks, as list elements.
+	var total int64
+	for _, col := range parquetColumns {
+		values, present, err := col.encode(candidates)
+		if err != nil {
+			return fmt.Errorf("failed to write Parquet: %w", err)
+		}
+		physical, repetition, _ := col.schema()
+		var page []byte
+		if repetition == parquetOptional {
+			page = definitionLevels(present)
+		}
+		page = append(page, values...)
+
+		var header thriftWriter
+		header.i32(1, 0) // DATA_PAGE
+		header.i32(2, int32(len(page)))
+		header.i32(3, int32(len(page)))
+		header.beginStruct(5)
+		header.i32(1, int32(len(candidates)))
+		header.i32(2, parquetPlain)
+		header.i32(3, parquetRLE)
+		header.i32(4, parquetRLE)
+		header.endStruct()
+		header.stop()
+
+		offset := int64(body.Len())
+		size := int64(header.buf.Len() + len(page))
+		body.Write(header.buf.Bytes())
+		body.Write(page)
+		total += size
+
+		chunks.beginElement()
+		chunks.i64(2, offset)
+		chunks.beginStruct(3)
+		chunks.i32(1, int32(physical))
+		chunks.listHeader(2, thriftI32, 2)
+		chunks.varint(zigzag(parquetPlain))
+		chunks.varint(zigzag(parquetRLE))
+		chunks.listHeader(3, thriftBinary, 1)
+		chunks.binary(col.name)
+		chunks.i32(4, 0) // UNCOMPRESSED
+		chunks.i64(5, int64(len(candidates)))
+		chunks.i64(6, size)
+		chunks.i64(7, size)
+		chunks.i64(9, offset)
+		chunks.endStruct()
+		chunks.endElement()
+	}
+
+	var meta thriftWriter
+	meta.i32(1, 1)
+	meta.listHeader(2, thriftStruct, len(parquetColumns)+1)
+	meta.beginElement()
+	meta.str(4, "schema")
+	meta.i32(5, int32(len(parquetColumns)))
+	meta.endElement()
+	for _, col := range parquetColumns {
+		physical, repetition, converted := col.schema()
+		meta.beginElement()
+		meta.i32(1, int32(physical))
+		meta.i32(3, int32(repetition))
+		meta.str(4, col.name)
+		if converted >= 0 {
+			meta.i32(6, int32(converted))
+		}
+		meta.endElement()
+	}
+	meta.i64(3, int64(len(candidates)))
+	rowGroups := 0
+	if len(candidates) > 0 {
+		rowGroups = 1
+	}
+	meta.listHeader(4, thriftStruct, rowGroups)
+	if rowGroups > 0 {
+		meta.beginElement()
+		meta.listHeader(1, thriftStruct, len(parquetColumns))
+		meta.buf.Write(chunks.buf.Bytes())
+		meta.i64(2, total)
+		meta.i64(3, int64(len(candidates)))
+		meta.endElement()
+	}
+	if info != nil {
+		fields := info.fields()
+		meta.listHeader(5, thriftStruct, len(fields))
+		for _, kv := range fields {
+			meta.beginElement()
+			meta.str(1, "profilesearch."+kv[0])
+			meta.str(2, kv[1])
+			meta.endElement()
+		}
+	}
+	meta.str(6, "profilesearch version "+version)
+	meta.stop()
+
+	body.Write(meta.buf.Bytes())
+	body.Write(binary.LittleEndian.AppendUint32(nil, uint32(meta.buf.Len())))
+	body.WriteString(parquetMagic)
+	if _, err := file.Write(body.Bytes()); err != nil {
+		return fmt.Errorf("failed to write Parquet: %w", err)
+	}
+	return file.Close()
+}
+
+// Thrift compact protocol types used by Parquet metadata.
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter encodes Thrift structs in the compact protocol, which
+// Parquet uses for its page headers and footer. Fields must be written in
+// increasing id order within a struct.
+type thriftWriter struct {
+	buf   bytes.Buffer
+	last  int16   // Id of the last field written in the current struct.
+	outer []int16 // last of the enclosing structs.
+}
+
+// zigzag maps signed integers to unsigned ones for varint encoding.
+func zigzag(n int64) uint64 {
+	return uint64(n<<1) ^ uint64(n>>63)
+}
+
+func (w *thriftWriter) varint(v uint64) {
+	w.buf.Write(binary.AppendUvarint(nil, v))
+}
+
+func (w *thriftWriter) field(id int16, typ byte) {
+	if delta := id - w.last; delta > 0 && delta <= 15 {
+		w.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		w.buf.WriteByte(typ)
+		w.varint(zigzag(int64(id)))
+	}
+	w.last = id
+}
+
+func (w *thriftWriter) i32(id int16, v int32) {
+	w.field(id, thriftI32)
+	w.varint(zigzag(int64(v)))
+}
+
+func (w *thriftWriter) i64(id int16, v int64) {
+	w.field(id, thriftI64)
+	w.varint(zigzag(v))
+}
+
+func (w *thriftWriter) str(id int16, s string) {
+	w.field(id, thriftBinary)
+	w.binary(s)
+}
+
+// binary writes a string value without a field header, as in lists.
+func (w *thriftWriter) binary(s string) {
+	w.varint(uint64(len(s)))
+	w.buf.WriteString(s)
+}
+
+// listHeader starts a list field of n elements of type elem.
+func (w *thriftWriter) listHeader(id int16, elem byte, n int) {
+	w.field(id, thriftList)
+	if n < 15 {
+		w.buf.WriteByte(byte(n)<<4 | elem)
+		return
+	}
+	w.buf.WriteByte(0xf0 | elem)
+	w.varint(uint64(n))
+}
+
+// beginStruct starts a struct field; endStruct ends it.
+func (w *thriftWriter) beginStruct(id int16) {
+	w.field(id, thriftStruct)
+	w.beginElement()
+}
+
+func (w *thriftWriter) endStruct() {
+	w.endElement()
+}
+
+// beginElement starts a struct that is a list element; endElement ends it.
+func (w *thriftWriter) beginElement() {
+	w.outer = append(w.outer, w.last)
+	w.last = 0
+}
+
+func (w *thriftWriter) endElement() {
+	w.stop()
+	w.last = w.outer[len(w.outer)-1]
+	w.outer = w.outer[:len(w.outer)-1]
+}
+
+// stop ends the current struct.
+func (w *thriftWriter) stop() {
+	w.buf.WriteByte(0)
+}
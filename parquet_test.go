@@ -0,0 +1,308 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// The reader below decodes Parquet from the format specification alone,
+// independently of writeToParquet's encoder: the Thrift compact protocol of
+// the footer and page headers, then each column's PLAIN values and
+// definition levels.
+
+// thriftStructValue is a decoded Thrift struct, by field id.
+type thriftStructValue map[int16]any
+
+// thriftReader decodes the Thrift compact protocol.
+type thriftReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *thriftReader) byte() byte {
+	b := r.data[r.pos]
+	r.pos++
+	return b
+}
+
+func (r *thriftReader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.data[r.pos:])
+	if n <= 0 {
+		panic(fmt.Sprintf("bad varint at %d", r.pos))
+	}
+	r.pos += n
+	return v
+}
+
+func (r *thriftReader) varint() int64 {
+	v := r.uvarint()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+// value decodes a value of compact type typ.
+func (r *thriftReader) value(typ byte) any {
+	switch typ {
+	case 1, 2: // Booleans, in a struct field header.
+		return typ == 1
+	case 3:
+		return int8(r.byte())
+	case 4, 5, 6:
+		return r.varint()
+	case 7:
+		v := math.Float64frombits(binary.LittleEndian.Uint64(r.data[r.pos:]))
+		r.pos += 8
+		return v
+	case 8:
+		n := int(r.uvarint())
+		b := r.data[r.pos : r.pos+n]
+		r.pos += n
+		return string(b)
+	case 9, 10:
+		head := r.byte()
+		n, elem := int(head>>4), head&0x0f
+		if n == 15 {
+			n = int(r.uvarint())
+		}
+		list := make([]any, n)
+		for i := range list {
+			if elem == 1 || elem == 2 {
+				list[i] = r.byte() == 1
+				continue
+			}
+			list[i] = r.value(elem)
+		}
+		return list
+	case 12:
+		return r.structValue()
+	}
+	panic(fmt.Sprintf("unsupported Thrift type %d at %d", typ, r.pos))
+}
+
+func (r *thriftReader) structValue() thriftStructValue {
+	s := make(thriftStructValue)
+	var id int16
+	for {
+		head := r.byte()
+		if head == 0 {
+			return s
+		}
+		if delta := int16(head >> 4); delta != 0 {
+			id += delta
+		} else {
+			id = int16(r.varint())
+		}
+		s[id] = r.value(head & 0x0f)
+	}
+}
+
+// readParquet decodes a Parquet file of one row group into its rows, by
+// column name, and its key/value metadata.
+func readParquet(t *testing.T, data []byte) (rows []map[string]any, meta map[string]string) {
+	t.Helper()
+	if !bytes.HasPrefix(data, []byte("PAR1")) || !bytes.HasSuffix(data, []byte("PAR1")) {
+		t.Fatal("missing PAR1 magic")
+	}
+	footerLen := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	footer := &thriftReader{data: data[len(data)-8-footerLen : len(data)-8]}
+	file := footer.structValue()
+	if footer.pos != footerLen {
+		t.Fatalf("footer decoded %d of %d bytes", footer.pos, footerLen)
+	}
+
+	meta = make(map[string]string)
+	if kvs, ok := file[5].([]any); ok {
+		for _, kv := range kvs {
+			meta[kv.(thriftStructValue)[1].(string)] = kv.(thriftStructValue)[2].(string)
+		}
+	}
+	numRows := int(file[3].(int64))
+	rows = make([]map[string]any, numRows)
+	for i := range rows {
+		rows[i] = make(map[string]any)
+	}
+	groups := file[4].([]any)
+	if numRows == 0 {
+		return rows, meta
+	}
+	if len(groups) != 1 {
+		t.Fatalf("%d row groups, want 1", len(groups))
+	}
+
+	schema := file[2].([]any)
+	chunks := groups[0].(thriftStructValue)[1].([]any)
+	if len(chunks) != len(schema)-1 {
+		t.Fatalf("%d column chunks for %d schema columns", len(chunks), len(schema)-1)
+	}
+	for c, chunk := range chunks {
+		element := schema[c+1].(thriftStructValue)
+		name, physical := element[4].(string), element[1].(int64)
+		optional := element[3].(int64) == 1
+		cm := chunk.(thriftStructValue)[3].(thriftStructValue)
+		if path := cm[3].([]any); len(path) != 1 || path[0] != name {
+			t.Fatalf("chunk %d has path %v, want %s", c, path, name)
+		}
+		pages := &thriftReader{data: data, pos: int(cm[9].(int64))}
+		header := pages.structValue()
+		page := data[pages.pos : pages.pos+int(header[3].(int64))]
+		if got := header[5].(thriftStructValue)[1].(int64); got != int64(numRows) {
+			t.Fatalf("column %s: page of %d values, want %d", name, got, numRows)
+		}
+
+		present := make([]bool, numRows)
+		for i := range present {
+			present[i] = true
+		}
+		if optional {
+			n := int(binary.LittleEndian.Uint32(page))
+			present = readBitPackedLevels(t, page[4:4+n], numRows)
+			page = page[4+n:]
+		}
+		values := &thriftReader{data: page} // For its cursor over the PLAIN values.
+		bit := 0
+		for i := range rows {
+			if !present[i] {
+				rows[i][name] = nil
+				continue
+			}
+			switch physical {
+			case 0: // BOOLEAN
+				rows[i][name] = page[bit/8]&(1<<(bit%8)) != 0
+				bit++
+			case 2: // INT64
+				rows[i][name] = int64(binary.LittleEndian.Uint64(page[values.pos:]))
+				values.pos += 8
+			case 5: // DOUBLE
+				rows[i][name] = math.Float64frombits(binary.LittleEndian.Uint64(page[values.pos:]))
+				values.pos += 8
+			case 6: // BYTE_ARRAY
+				n := int(binary.LittleEndian.Uint32(page[values.pos:]))
+				rows[i][name] = string(page[values.pos+4 : values.pos+4+n])
+				values.pos += 4 + n
+			default:
+				t.Fatalf("column %s: unexpected physical type %d", name, physical)
+			}
+		}
+	}
+	return rows, meta
+}
+
+// readBitPackedLevels decodes n definition levels of width 1 in the RLE /
+// bit-packed hybrid encoding.
+func readBitPackedLevels(t *testing.T, data []byte, n int) []bool {
+	t.Helper()
+	r := &thriftReader{data: data}
+	var levels []bool
+	for r.pos < len(data) {
+		head := r.uvarint()
+		if head&1 == 0 { // An RLE run.
+			v := r.byte()
+			for i := uint64(0); i < head>>1; i++ {
+				levels = append(levels, v == 1)
+			}
+			continue
+		}
+		for g := uint64(0); g < head>>1; g++ {
+			b := r.byte()
+			for i := 0; i < 8; i++ {
+				levels = append(levels, b&(1<<i) != 0)
+			}
+		}
+	}
+	if len(levels) < n {
+		t.Fatalf("%d definition levels, want %d", len(levels), n)
+	}
+	return levels[:n]
+}
+
+func TestParquetRoundTrip(t *testing.T) {
+	seen := time.Date(2026, 3, 4, 5, 6, 7, 890e6, time.UTC)
+	candidates := []Candidate{
+		{
+			Name: "Jane Doe", Email: "jane@example.com", ProfileURL: "https://www.linkedin.com/in/jane-doe",
+			ExperienceYears: 8.5, Rank: 1, PagePosition: 1, OverallPosition: 1, Title: "Valve Engineer",
+			Anonymized: false, Rediscovered: true, PreviouslySeen: &seen,
+			AlternateURLs: []string{"https://in.linkedin.com/in/jane-doe"}, MatchedTerms: []string{"valve"},
+			Extra: map[string]string{"github": "janedoe"}, Score: 25, LookupScore: 0.75,
+			Snippet: "Bangalore · Valve Engineer at Acme — “quoted”",
+		},
+		{Name: "John Roe", ProfileURL: "https://www.linkedin.com/in/john-roe", Rank: 2, Anonymized: true, Score: -5},
+		{Name: "", ProfileURL: "https://www.linkedin.com/in/ghost", Rank: 3, NameSlugMismatch: true},
+	}
+	info := &runInfo{Criteria: SearchCriteria{Keywords: "valve"}}
+	path := filepath.Join(t.TempDir(), "candidates.parquet")
+	if err := writeToParquet(candidates, path, info); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, meta := readParquet(t, data)
+	if len(rows) != len(candidates) {
+		t.Fatalf("%d rows, want %d", len(rows), len(candidates))
+	}
+
+	want := map[string]any{
+		"name":             "Jane Doe",
+		"email":            "jane@example.com",
+		"profile_url":      "https://www.linkedin.com/in/jane-doe",
+		"experience":       int64(8),
+		"experience_years": 8.5,
+		"rank":             int64(1),
+		"title":            "Valve Engineer",
+		"anonymized":       false,
+		"rediscovered":     true,
+		"previously_seen":  seen.UnixMilli(),
+		"alternate_urls":   `["https://in.linkedin.com/in/jane-doe"]`,
+		"positions":        "[]",
+		"matched_terms":    `["valve"]`,
+		"extra":            `{"github":"janedoe"}`,
+		"score":            int64(25),
+		"lookup_score":     0.75,
+		"snippet":          "Bangalore · Valve Engineer at Acme — “quoted”",
+	}
+	for name, v := range want {
+		if got := rows[0][name]; !reflect.DeepEqual(got, v) {
+			t.Errorf("row 1 %s = %#v, want %#v", name, got, v)
+		}
+	}
+	for i, row := range rows {
+		if len(row) != len(parquetColumns) {
+			t.Errorf("row %d has %d columns, want %d", i+1, len(row), len(parquetColumns))
+		}
+		if got := row["profile_url"]; got != candidates[i].ProfileURL {
+			t.Errorf("row %d profile_url = %v, want %s", i+1, got, candidates[i].ProfileURL)
+		}
+	}
+	// The booleans of later rows, and a null for a nil time.
+	if rows[1]["anonymized"] != true || rows[2]["name_slug_mismatch"] != true || rows[1]["previously_seen"] != nil {
+		t.Errorf("rows 2 and 3 = %v, %v", rows[1], rows[2])
+	}
+	if rows[1]["score"] != int64(-5) || rows[1]["extra"] != "{}" {
+		t.Errorf("row 2 score and extra = %v, %v", rows[1]["score"], rows[1]["extra"])
+	}
+	if meta["profilesearch.keywords"] != "valve" {
+		t.Errorf("key/value metadata = %v, want the run info", meta)
+	}
+}
+
+func TestParquetEmpty(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "empty.parquet")
+	if err := writeToParquet(nil, path, nil); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows, _ := readParquet(t, data); len(rows) != 0 {
+		t.Errorf("%d rows, want 0", len(rows))
+	}
+}
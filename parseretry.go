@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// parseFailureDir receives the HTML of pages that failed to parse twice. It
+// is set from -parse-failure-dir; empty saves nothing.
+var parseFailureDir string
+
+// parseFunc extracts what it needs from a fetched page.
+type parseFunc func(body []byte, doc *goquery.Document) error
+
+// parsePage parses body and runs parse over it, turning a panic in the
+// extraction into an error.
+func parsePage(body []byte, parse parseFunc) (err error) {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error parsing page: %w", err)
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("extraction failed: %v", r)
+		}
+	}()
+	return parse(body, doc)
+}
+
+// parseWithRetry runs parse over the fetched body of pageURL. A page that
+// fails to parse is fetched again with refetch and parsed once more, since
+// a truncated or garbled response is usually a one-off; when that fails
+// too, the HTML is saved to parseFailureDir for debugging.
+func parseWithRetry(ctx context.Context, pageURL string, body []byte, refetch func(context.Context) ([]byte, error), parse parseFunc) error {
+	err := parsePage(body, parse)
+	if err == nil {
+		return nil
+	}
+	log.Printf("Failed to parse %s, retrying once: %v", pageURL, err)
+	retried, fetchErr := refetch(ctx)
+	if fetchErr != nil {
+		saveParseFailure(pageURL, body)
+		return fmt.Errorf("%w (retry failed: %w)", err, fetchErr)
+	}
+	if err = parsePage(retried, parse); err == nil {
+		return nil
+	}
+	saveParseFailure(pageURL, retried)
+	return err
+}
+
+// saveParseFailure writes the HTML of a page that failed to parse to
+// parseFailureDir, logging where.
+func saveParseFailure(pageURL string, body []byte) {
+	if parseFailureDir == "" {
+		return
+	}
+	sum := sha256.Sum256([]byte(pageURL))
+	name := fmt.Sprintf("%s-%s.html", time.Now().UTC().Format("20060102T150405"), hex.EncodeToString(sum[:4]))
+	path := filepath.Join(parseFailureDir, name)
+	if err := os.MkdirAll(parseFailureDir, 0o755); err != nil {
+		log.Printf("Error saving unparsable page %s: %v", pageURL, err)
+		return
+	}
+	content := append([]byte(fmt.Sprintf("<!-- %s -->\n", pageURL)), body...)
+	if err := os.WriteFile(path, content, 0o644); err != nil {
+		log.Printf("Error saving unparsable page %s: %v", pageURL, err)
+		return
+	}
+	log.Printf("Saved the HTML of %s to %s", pageURL, path)
+}
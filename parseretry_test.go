@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// useParseFailureDir points parseFailureDir at a fresh directory for the test.
+func useParseFailureDir(t *testing.T) string {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), "parse-failures")
+	old := parseFailureDir
+	parseFailureDir = dir
+	t.Cleanup(func() { parseFailureDir = old })
+	return dir
+}
+
+// savedFailures returns the contents of the pages saved to dir.
+func savedFailures(t *testing.T, dir string) []string {
+	t.Helper()
+	files, _ := filepath.Glob(filepath.Join(dir, "*.html"))
+	var pages []string
+	for _, f := range files {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pages = append(pages, string(b))
+	}
+	return pages
+}
+
+// extractTitle fails, by panicking as a careless extractor would, on pages
+// without a title.
+func extractTitle(title *string) parseFunc {
+	return func(_ []byte, doc *goquery.Document) error {
+		*title = doc.Find("title").Nodes[0].FirstChild.Data
+		return nil
+	}
+}
+
+func TestParseRetrySucceedsOnSecondFetch(t *testing.T) {
+	dir := useParseFailureDir(t)
+	captureLog(t)
+	refetches := 0
+	refetch := func(context.Context) ([]byte, error) {
+		refetches++
+		return []byte("<html><title>Results</title></html>"), nil
+	}
+	var title string
+	err := parseWithRetry(context.Background(), "https://www.google.com/search?q=a", []byte("<html><body>truncat"), refetch, extractTitle(&title))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if title != "Results" || refetches != 1 {
+		t.Errorf("title %q after %d refetches, want Results after 1", title, refetches)
+	}
+	if pages := savedFailures(t, dir); len(pages) != 0 {
+		t.Errorf("%d pages saved after a successful retry", len(pages))
+	}
+
+	// A page that parses is not fetched again.
+	if err := parseWithRetry(context.Background(), "https://www.google.com/search?q=a", []byte("<title>Ok</title>"), refetch, extractTitle(&title)); err != nil || refetches != 1 {
+		t.Errorf("got %v after %d refetches, want no retry", err, refetches)
+	}
+}
+
+func TestParseRetrySavesHTMLWhenRetryFails(t *testing.T) {
+	dir := useParseFailureDir(t)
+	captureLog(t)
+	const pageURL = "https://www.linkedin.com/in/jane-doe"
+	refetch := func(context.Context) ([]byte, error) { return []byte("<html><body>still no title</body></html>"), nil }
+	var title string
+	err := parseWithRetry(context.Background(), pageURL, []byte("<html>first</html>"), refetch, extractTitle(&title))
+	if err == nil || !strings.Contains(err.Error(), "extraction failed") {
+		t.Fatalf("got %v, want the extraction failure", err)
+	}
+	pages := savedFailures(t, dir)
+	if len(pages) != 1 || !strings.HasPrefix(pages[0], "<!-- "+pageURL+" -->\n") || !strings.Contains(pages[0], "still no title") {
+		t.Errorf("saved %q, want the retried page under its URL", pages)
+	}
+
+	// When the retry cannot be fetched, the first page is saved.
+	dir = useParseFailureDir(t)
+	refetchErr := errors.New("connection reset")
+	err = parseWithRetry(context.Background(), pageURL, []byte("<html>first</html>"), func(context.Context) ([]byte, error) { return nil, refetchErr }, extractTitle(&title))
+	if !errors.Is(err, refetchErr) {
+		t.Errorf("got %v, want it to wrap the refetch error", err)
+	}
+	if pages := savedFailures(t, dir); len(pages) != 1 || !strings.Contains(pages[0], "first") {
+		t.Errorf("saved %q, want the first page", pages)
+	}
+}
@@ -0,0 +1,425 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// phoneContextWindow is how many characters either side of a phone match are
+// checked for blocklisted context words.
+const phoneContextWindow = 30
+
+// phoneContextBlocklist lists words that mark a nearby number as something
+// other than a phone number: postal codes, tax IDs, company facts.
+var phoneContextBlocklist = []string{
+	"pincode", "pin code", "postal code", "zip",
+	"gst", "gstin", "cin", "pan",
+	"established", "estd", "founded", "since",
+	"employees", "employee", "staff",
+	"turnover", "revenue", "crore", "lakh",
+}
+
+// Phone number shapes besides the North American phoneRegex. At each
+// position the first shape that matches wins.
+const (
+	indianPhoneRegex        = `(?:\+91[\s.-]?)?[6-9]\d{4}[\s.-]?\d{5}` // Mobiles: "+91 98765 43210", "+919876543210", "98765 43210".
+	internationalPhoneRegex = `\+\d{1,3}(?:[\s.-]?\d){6,12}`           // Any calling code: "+44 20 7946 0958".
+)
+
+var phoneMatcher = regexp.MustCompile(indianPhoneRegex + "|" + internationalPhoneRegex + "|" + phoneRegex)
+
+// phoneMatch is a candidate phone number found in text.
+type phoneMatch struct {
+	text       string // The full text searched.
+	start, end int    // Byte offsets of the match in text.
+}
+
+func (m phoneMatch) value() string { return m.text[m.start:m.end] }
+
+// digits returns only the digits of the match.
+func (m phoneMatch) digits() string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, m.value())
+}
+
+// callingCode splits a match written with a calling code, such as
+// "+44 20 7946 0958", into the code and the national number. ok is false
+// when the match has no calling code or an unknown one.
+func (m phoneMatch) callingCode() (code, national string, ok bool) {
+	if m.text[m.start] != '+' {
+		return "", "", false
+	}
+	d := m.digits()
+	for n := 1; n <= 3 && n < len(d); n++ { // Calling codes are prefix-free.
+		if _, known := callingCodeRegions[d[:n]]; known {
+			return d[:n], d[n:], true
+		}
+	}
+	return "", "", false
+}
+
+// context returns up to phoneContextWindow characters either side of the match,
+// lower-cased.
+func (m phoneMatch) context() string {
+	from := m.start - phoneContextWindow
+	if from < 0 {
+		from = 0
+	}
+	to := m.end + phoneContextWindow
+	if to > len(m.text) {
+		to = len(m.text)
+	}
+	return strings.ToLower(m.text[from:m.start] + " " + m.text[m.end:to])
+}
+
+// phoneRule rejects phone matches that are probably some other number.
+type phoneRule struct {
+	name   string
+	reject func(m phoneMatch) bool
+}
+
+// phoneRejectRules are applied in order; the first rule that rejects a match
+// is the one counted.
+var phoneRejectRules = []phoneRule{
+	{"adjacent_digits", rejectAdjacentDigits},
+	{"alphanumeric_token", rejectAlphanumericToken},
+	{"impossible_number", rejectImpossibleNumber},
+	{"context_word", rejectContextWord},
+}
+
+// rejectAdjacentDigits rejects matches cut out of a longer run of digits, such
+// as GST numbers or account numbers.
+func rejectAdjacentDigits(m phoneMatch) bool {
+	before := m.start > 0 && isDigitByte(m.text[m.start-1])
+	after := m.end < len(m.text) && isDigitByte(m.text[m.end])
+	return before || after
+}
+
+// rejectAlphanumericToken rejects matches inside a token that also contains
+// letters, such as IDs and URL fragments.
+func rejectAlphanumericToken(m phoneMatch) bool {
+	start, end := m.start, m.end
+	for start > 0 && !isTokenBoundary(m.text[start-1]) {
+		start--
+	}
+	for end < len(m.text) && !isTokenBoundary(m.text[end]) {
+		end++
+	}
+	return strings.IndexFunc(m.text[start:end], unicode.IsLetter) >= 0
+}
+
+// rejectImpossibleNumber rejects numbers no region would issue. A number
+// with a calling code must have a national number of that region's length:
+// ten digits for the US and India, 7 to 12 elsewhere. Without one it must
+// have ten digits, valid either as a North American number or as an Indian
+// mobile number; when the text around it names a region, only that
+// region's rule applies. Only the context window counts, as one "India"
+// elsewhere on a long page says nothing about this number.
+func rejectImpossibleNumber(m phoneMatch) bool {
+	if m.text[m.start] == '+' {
+		code, national, ok := m.callingCode()
+		if !ok {
+			return true
+		}
+		switch callingCodeRegions[code] {
+		case "US":
+			return !validNationalNumber("US", national)
+		case "IN":
+			return !validNationalNumber("IN", national)
+		}
+		return len(national) < 7 || len(national) > 12
+	}
+	d := m.digits()
+	if len(d) != 10 {
+		return true
+	}
+	switch region := inferPhoneRegion(m.context()); region {
+	case "US", "IN":
+		return !validNationalNumber(region, d)
+	default:
+		return !validNationalNumber("US", d) && !validNationalNumber("IN", d)
+	}
+}
+
+// validNationalNumber reports whether d, the digits of a national number,
+// is one region issues: for the US an area code and exchange starting 2-9,
+// for India a mobile number starting 6-9.
+func validNationalNumber(region, d string) bool {
+	if len(d) != 10 {
+		return false
+	}
+	switch region {
+	case "US":
+		return d[0] >= '2' && d[3] >= '2'
+	case "IN":
+		return d[0] >= '6'
+	}
+	return false
+}
+
+// rejectContextWord rejects matches near words such as "pincode" or "employees".
+func rejectContextWord(m phoneMatch) bool {
+	ctx := m.context()
+	for _, word := range phoneContextBlocklist {
+		if containsWord(ctx, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// inferPhoneRegion guesses the phone region from country markers in text,
+// such as the context of a match.
+func inferPhoneRegion(text string) string {
+	lower := strings.ToLower(text)
+	switch {
+	case strings.Contains(lower, "+91") || strings.Contains(lower, "india"):
+		return "IN"
+	case strings.Contains(lower, "+1 ") || strings.Contains(lower, "+1-") || strings.Contains(lower, "united states"):
+		return "US"
+	}
+	return ""
+}
+
+// containsWord reports whether word appears in text on word boundaries.
+func containsWord(text, word string) bool {
+	for i := 0; ; {
+		j := strings.Index(text[i:], word)
+		if j < 0 {
+			return false
+		}
+		start, end := i+j, i+j+len(word)
+		if (start == 0 || !isWordByte(text[start-1])) && (end == len(text) || !isWordByte(text[end])) {
+			return true
+		}
+		i = start + 1
+	}
+}
+
+func isDigitByte(b byte) bool { return b >= '0' && b <= '9' }
+
+func isWordByte(b byte) bool {
+	return isDigitByte(b) || (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || b == '_'
+}
+
+// isTokenBoundary reports whether b separates tokens. Phone punctuation is not
+// a boundary so that "(555) 123-4567" stays one token.
+func isTokenBoundary(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r' || b == ',' || b == ';' || b == '|' || b == '<' || b == '>' || b == '"' || b == '\''
+}
+
+// extractPhone returns the first phone number in text that survives the
+// rejection rules. Rejections are counted in the run stats and logged at
+// verbose level for tuning.
+func extractPhone(text string) string {
+	for _, loc := range phoneMatcher.FindAllStringIndex(text, -1) {
+		m := phoneMatch{text: text, start: loc[0], end: loc[1]}
+		if rule := rejectingPhoneRule(m); rule != "" {
+			stats.countPhoneRejection(rule)
+			verbosef("Rejected phone %q (%s) in %q", m.value(), rule, m.context())
+			continue
+		}
+		return withCountryCode(m)
+	}
+	return ""
+}
+
+// rejectingPhoneRule returns the name of the first rule rejecting m, or "".
+func rejectingPhoneRule(m phoneMatch) string {
+	for _, rule := range phoneRejectRules {
+		if rule.reject(m) {
+			return rule.name
+		}
+	}
+	return ""
+}
+
+// Phone output formats for -phone-format.
+const (
+	phoneFormatRaw      = "raw"
+	phoneFormatE164     = "e164"
+	phoneFormatNational = "national"
+)
+
+// phoneRegions maps supported regions to their country calling codes.
+var phoneRegions = map[string]string{
+	"US": "1",
+	"IN": "91",
+}
+
+// phoneNumber is a parsed phone number.
+type phoneNumber struct {
+	Region      string // ISO country code, e.g. "IN".
+	CountryCode string // Calling code without "+", e.g. "91".
+	National    string // National significant number, digits only.
+}
+
+var internationalPrefix = regexp.MustCompile(`^\s*(?:\+|00)`)
+
+// countryCodeBefore matches a calling code written just before a phone match.
+var countryCodeBefore = regexp.MustCompile(`(?:\+|00)\d{1,3}[\s.-]?$`)
+
+// withCountryCode extends an accepted match backwards to include a calling
+// code such as "+91 " written directly before it. A number written without
+// one gets the code of the region its context names, if it is valid there,
+// so that its country is known later on.
+func withCountryCode(m phoneMatch) string {
+	if loc := countryCodeBefore.FindStringIndex(m.text[:m.start]); loc != nil {
+		return m.text[loc[0]:m.end]
+	}
+	if m.text[m.start] != '+' {
+		if region := inferPhoneRegion(m.context()); region != "" && validNationalNumber(region, m.digits()) {
+			return "+" + phoneRegions[region] + " " + m.value()
+		}
+	}
+	return m.value()
+}
+
+// parsePhone parses a raw phone number. A leading "+<code>" selects the
+// region, any of callingCodeRegions; otherwise defaultRegion is assumed.
+func parsePhone(raw, defaultRegion string) (phoneNumber, bool) {
+	var n phoneNumber
+	digits := phoneMatch{text: raw, start: 0, end: len(raw)}.digits()
+	if internationalPrefix.MatchString(raw) {
+		if strings.HasPrefix(strings.TrimSpace(raw), "00") {
+			digits = digits[2:]
+		}
+		for size := 1; size <= 3 && size < len(digits); size++ { // Calling codes are prefix-free.
+			if region, ok := callingCodeRegions[digits[:size]]; ok {
+				n.Region, n.CountryCode, n.National = region, digits[:size], digits[size:]
+				break
+			}
+		}
+		switch n.Region {
+		case "":
+			return n, false
+		case "US", "IN":
+			return n, len(n.National) == 10
+		}
+		return n, len(n.National) >= 7 && len(n.National) <= 12
+	}
+
+	n.Region = strings.ToUpper(defaultRegion)
+	n.CountryCode = phoneRegions[n.Region]
+	if n.CountryCode == "" {
+		return n, false
+	}
+	n.National = digits
+	// A national trunk prefix is not part of the number.
+	if n.Region == "IN" && len(n.National) == 11 && n.National[0] == '0' {
+		n.National = n.National[1:]
+	}
+	if n.Region == "US" && len(n.National) == 11 && n.National[0] == '1' {
+		n.National = n.National[1:]
+	}
+	// A number defaultRegion would never issue is of the region that would.
+	if len(n.National) == 10 && !validNationalNumber(n.Region, n.National) {
+		for _, region := range []string{"US", "IN"} {
+			if validNationalNumber(region, n.National) {
+				n.Region, n.CountryCode = region, phoneRegions[region]
+				break
+			}
+		}
+	}
+	return n, len(n.National) == 10
+}
+
+// callingCodeRegions maps country calling codes to their regions, for
+// telling the country of international numbers in formats parsePhone does
+// not know. +1 is shared by the North American countries and counts as US.
+var callingCodeRegions = map[string]string{
+	"1": "US", "7": "RU", "20": "EG", "27": "ZA", "31": "NL", "32": "BE", "33": "FR", "34": "ES", "39": "IT",
+	"41": "CH", "44": "GB", "46": "SE", "48": "PL", "49": "DE", "52": "MX", "55": "BR", "60": "MY", "61": "AU",
+	"62": "ID", "63": "PH", "64": "NZ", "65": "SG", "81": "JP", "82": "KR", "84": "VN", "86": "CN", "90": "TR",
+	"91": "IN", "92": "PK", "94": "LK", "234": "NG", "254": "KE", "353": "IE", "880": "BD", "966": "SA",
+	"971": "AE", "977": "NP",
+}
+
+// knownPhoneCountry reports whether phoneCountry can return region.
+func knownPhoneCountry(region string) bool {
+	for _, r := range callingCodeRegions {
+		if r == region {
+			return true
+		}
+	}
+	return false
+}
+
+// phoneCountry returns the region of a raw phone number: from its calling
+// code when it has one, or else defaultRegion when it is a valid number of
+// that region, or the region it is valid in when it is not.
+func phoneCountry(raw, defaultRegion string) (string, bool) {
+	if n, ok := parsePhone(raw, defaultRegion); ok {
+		return n.Region, true
+	}
+	if !internationalPrefix.MatchString(raw) {
+		return "", false
+	}
+	digits := phoneMatch{text: raw, start: 0, end: len(raw)}.digits()
+	if strings.HasPrefix(strings.TrimSpace(raw), "00") {
+		digits = digits[2:]
+	}
+	for n := 3; n >= 1; n-- { // Calling codes are prefix-free.
+		if len(digits) > n {
+			if region, ok := callingCodeRegions[digits[:n]]; ok {
+				return region, true
+			}
+		}
+	}
+	return "", false
+}
+
+// E164 formats the number as +<code><number>.
+func (n phoneNumber) E164() string {
+	return "+" + n.CountryCode + n.National
+}
+
+// NationalFormat formats the number the way it is written within its region.
+func (n phoneNumber) NationalFormat() string {
+	d := n.National
+	switch n.Region {
+	case "US":
+		return fmt.Sprintf("(%s) %s-%s", d[:3], d[3:6], d[6:])
+	case "IN":
+		return d[:5] + " " + d[5:]
+	}
+	return d
+}
+
+// defaultPhoneRegion is the region of phone numbers written without a
+// calling code in a search of location: its country when phoneRegions has
+// it, else the US.
+func defaultPhoneRegion(location string) string {
+	for _, loc := range locationTerms(location) {
+		if _, ok := phoneRegions[loc.Country]; ok {
+			return loc.Country
+		}
+	}
+	return "US"
+}
+
+// formatPhone reformats a raw phone number. Numbers that cannot be parsed are
+// returned unchanged, as is everything in raw format and, in national
+// format, the numbers of regions other than phoneRegions.
+func formatPhone(raw, format, defaultRegion string) string {
+	if raw == "" || format == phoneFormatRaw {
+		return raw
+	}
+	n, ok := parsePhone(raw, defaultRegion)
+	if !ok {
+		return raw
+	}
+	if format == phoneFormatE164 {
+		return n.E164()
+	}
+	if _, known := phoneRegions[n.Region]; !known {
+		return raw
+	}
+	return n.NationalFormat()
+}
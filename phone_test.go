@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestExtractPhone(t *testing.T) {
+	tests := []struct{ text, want string }{
+		{"Call (415) 555-0134 today", "(415) 555-0134"},
+		{"Phone: 415.555.0134", "415.555.0134"},
+		{"Mobile +91 98765 43210", "+91 98765 43210"},
+		{"Mobile +919876543210", "+919876543210"},
+		{"Mobile +91-9876543210", "+91-9876543210"},
+		{"Reach me at 98765 43210", "98765 43210"},
+		{"Reach me at 9876543210", "9876543210"},
+		{"London office +44 20 7946 0958", "+44 20 7946 0958"},
+		{"US line +1 415-555-0134", "+1 415-555-0134"},
+		{"US line +1 (415) 555-0134", "+1 (415) 555-0134"},
+		{"No number here", ""},
+	}
+	for _, tt := range tests {
+		if got := extractPhone(tt.text); got != tt.want {
+			t.Errorf("extractPhone(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestFormatPhone(t *testing.T) {
+	tests := []struct{ raw, format, region, want string }{
+		{"+91 98765 43210", phoneFormatE164, "US", "+919876543210"},
+		{"+91 98765 43210", phoneFormatNational, "US", "98765 43210"},
+		{"+919876543210", phoneFormatNational, "US", "98765 43210"},
+		{"98765 43210", phoneFormatE164, "IN", "+919876543210"},
+		{"098765 43210", phoneFormatE164, "IN", "+919876543210"},
+		{"(415) 555-0134", phoneFormatE164, "US", "+14155550134"},
+		{"1-415-555-0134", phoneFormatNational, "US", "(415) 555-0134"},
+		{"+1 415-555-0134", phoneFormatNational, "IN", "(415) 555-0134"},
+		{"+44 20 7946 0958", phoneFormatE164, "US", "+442079460958"},
+		{"+44 20 7946 0958", phoneFormatNational, "US", "+44 20 7946 0958"}, // No national format for GB.
+		{"0044 20 7946 0958", phoneFormatE164, "US", "+442079460958"},
+		{"+91 98765", phoneFormatE164, "US", "+91 98765"}, // Too short to parse.
+		{"98765 43210", phoneFormatRaw, "IN", "98765 43210"},
+	}
+	for _, tt := range tests {
+		if got := formatPhone(tt.raw, tt.format, tt.region); got != tt.want {
+			t.Errorf("formatPhone(%q, %s, %s) = %q, want %q", tt.raw, tt.format, tt.region, got, tt.want)
+		}
+	}
+}
+
+// phoneTable holds texts, the phone extracted from each, and the rule that
+// rejected its first match, if any.
+var phoneTable = []struct {
+	text, want, rule string
+}{
+	// Accepted.
+	{"Call (415) 555-0134 today", "(415) 555-0134", ""},
+	{"Mobile: +91 98765 43210", "+91 98765 43210", ""},
+	{"WhatsApp 98765-43210", "98765-43210", ""},
+	{"Cell 212.555.0187, evenings", "212.555.0187", ""},
+	{"Tel +44 20 7946 0958 (London)", "+44 20 7946 0958", ""},
+	{"Ph: 001 415 555 0134", "001 415 555 0134", ""},
+	// adjacent_digits: cut out of a longer run.
+	{"Account 123456789012345", "", "adjacent_digits"},
+	{"Ref 98765432101", "", "adjacent_digits"},
+	// alphanumeric_token: inside an ID.
+	{"GSTIN AB4155550134", "", "alphanumeric_token"},
+	{"see /profile/x4155550134y", "", "alphanumeric_token"},
+	// impossible_number: no region issues it.
+	{"Call 123-456-7890", "", "impossible_number"},
+	{"Call 555-012-3456", "", "impossible_number"},
+	{"Mobile +91 12345 67890", "", "impossible_number"},
+	{"Dial +1 415 555 013", "", "impossible_number"},
+	{"Dial +999 1234567", "", "impossible_number"},
+	// impossible_number, judged by the region named around the match.
+	{"India office: 2125550134", "", "impossible_number"},
+	{"United States: 9870123456", "", "impossible_number"},
+	// context_word: a figure, not a phone.
+	{"Turnover 9876543210 INR", "", "context_word"},
+	{"4155550134 employees worldwide", "", "context_word"},
+	{"Established 2155550134", "", "context_word"},
+	{"GST 9876543210 registered", "", "context_word"},
+	// A rejected match does not stop the search for a later one.
+	{"Pincode 9876543210. For enquiries in office hours, call (415) 555-0134", "(415) 555-0134", "context_word"},
+}
+
+func TestPhoneRejectionTable(t *testing.T) {
+	for _, tt := range phoneTable {
+		stats = newRunStats()
+		if got := extractPhone(tt.text); got != tt.want {
+			t.Errorf("extractPhone(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+		var rules []string
+		for rule, n := range stats.PhoneRejections {
+			for range n {
+				rules = append(rules, rule)
+			}
+		}
+		if want := strings.Fields(tt.rule); strings.Join(rules, " ") != strings.Join(want, " ") {
+			t.Errorf("extractPhone(%q) rejected by %v, want %v", tt.text, rules, want)
+		}
+	}
+}
+
+func TestPhoneRegionFromContextOnly(t *testing.T) {
+	// 2125550134 is a US number but no Indian mobile; a mention of India
+	// far from it must not reject it.
+	text := "Based in India. " + strings.Repeat("Works on control valves. ", 20) + "US desk 2125550134"
+	if got := extractPhone(text); got != "2125550134" {
+		t.Errorf("extractPhone = %q, want the US number", got)
+	}
+}
+
+func TestPhoneCountriesFilter(t *testing.T) {
+	phones := []string{
+		"+91 98765 43210",  // IN, written with its code.
+		"98401 12345",      // No US number: IN.
+		"9876543210",       // Valid in both regions; the search's decides.
+		"(415) 555-0134",   // No Indian mobile: US.
+		"+1 212 555 0134",  // US.
+		"+44 20 7946 0958", // GB.
+	}
+	tests := []struct {
+		args []string
+		want []string
+	}{
+		{[]string{"-location", "Bangalore", "-phone-countries", "IN"}, []string{"+91 98765 43210", "98401 12345", "9876543210"}},
+		{[]string{"-location", "New York", "-phone-countries", "IN"}, []string{"+91 98765 43210", "98401 12345"}},
+		{[]string{"-location", "New York", "-phone-countries", "US"}, []string{"9876543210", "(415) 555-0134", "+1 212 555 0134"}},
+		{[]string{"-location", "Bangalore", "-phone-countries", "US,GB"}, []string{"(415) 555-0134", "+1 212 555 0134", "+44 20 7946 0958"}},
+		{[]string{"-location", "New York", "-phone-region", "IN", "-phone-countries", "IN"}, []string{"+91 98765 43210", "98401 12345", "9876543210"}},
+	}
+	for _, tt := range tests {
+		stats = newRunStats()
+		cfg, err := parseFlags(append([]string{"-keywords", "valve"}, tt.args...))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var candidates []Candidate
+		for i, phone := range phones {
+			candidates = append(candidates, Candidate{
+				Name:       "Jane Doe",
+				Phone:      phone,
+				ProfileURL: fmt.Sprintf("https://www.linkedin.com/in/jane-doe-%d", i),
+			})
+		}
+		var got []string
+		for _, c := range finalizeCandidates(cfg, cfg.criteria, candidates) {
+			if c.Phone != "" {
+				got = append(got, c.Phone)
+			}
+		}
+		if strings.Join(got, "; ") != strings.Join(tt.want, "; ") {
+			t.Errorf("%v kept phones %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestExtractPhoneAddsContextCallingCode(t *testing.T) {
+	tests := []struct{ text, want string }{
+		{"Mobile (India): 98765 43210", "+91 98765 43210"},
+		{"Call me at 98765 43210", "98765 43210"},
+		{"Office (United States): 212 555 0134", "+1 212 555 0134"},
+	}
+	for _, tt := range tests {
+		if got := extractPhone(tt.text); got != tt.want {
+			t.Errorf("extractPhone(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Selectors within one position of a profile's experience section.
+const (
+	positionTitleSelector   = ".experience-item__title, h3"
+	positionCompanySelector = ".experience-item__subtitle, h4"
+	positionDatesSelector   = ".date-range, .experience-item__duration"
+)
+
+// Position is one entry of a profile's experience history.
+type Position struct {
+	Title   string `json:"title,omitempty"`
+	Company string `json:"company,omitempty"`
+	Dates   string `json:"dates,omitempty"` // As written, e.g. "Jan 2019 - Present"
+	Current bool   `json:"current,omitempty"`
+}
+
+// String formats the position as "Title at Company (Dates)".
+func (p Position) String() string {
+	s := p.Title
+	if p.Company != "" {
+		if s != "" {
+			s += " at "
+		}
+		s += p.Company
+	}
+	if p.Dates != "" {
+		s += " (" + p.Dates + ")"
+	}
+	return s
+}
+
+// cleanText trims text and collapses its runs of whitespace.
+func cleanText(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// extractPositions parses a profile's experience section, most recent
+// position first as LinkedIn lists them. Roles grouped under one company
+// take the company from the group's heading.
+func extractPositions(doc *goquery.Document) []Position {
+	var positions []Position
+	for _, sel := range experienceCompanySelectors {
+		doc.Find(sel).Each(func(i int, s *goquery.Selection) {
+			if s.Find("li").Length() > 0 {
+				return // A company group; its roles are visited on their own.
+			}
+			p := Position{
+				Title:   cleanText(s.Find(positionTitleSelector).First().Text()),
+				Company: cleanText(s.Find(positionCompanySelector).First().Text()),
+				Dates:   cleanText(s.Find(positionDatesSelector).First().Text()),
+			}
+			if p.Company == "" {
+				p.Company = cleanText(s.ParentsFiltered("li").First().Find(positionCompanySelector).First().Text())
+			}
+			if p.Title == "" && p.Company == "" {
+				return
+			}
+			p.Current = openEndedRangeRegex.MatchString(p.Dates)
+			positions = append(positions, p)
+		})
+		if len(positions) > 0 {
+			break
+		}
+	}
+	return positions
+}
+
+// currentPosition returns the first open-ended position, or else the most
+// recent one. ok is false when there are no positions.
+func currentPosition(positions []Position) (Position, bool) {
+	for _, p := range positions {
+		if p.Current {
+			return p, true
+		}
+	}
+	if len(positions) == 0 {
+		return Position{}, false
+	}
+	return positions[0], true
+}
+
+// Values of -experience-source.
+const (
+	experienceFromPositionDates = "positions" // Position dates when parsed, else the snippet.
+	experienceFromSnippet       = "snippet"   // The snippet, else position dates.
+)
+
+// positionDateRegex matches a date of a position range, "Jan 2019" or "2019".
+var positionDateRegex = regexp.MustCompile(`(?i)\b(?:(jan|feb|mar|apr|may|jun|jul|aug|sep|oct|nov|dec)[a-z]*\.?\s+)?((?:19|20)\d{2})\b`)
+
+var positionMonths = map[string]int{
+	"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+	"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+}
+
+// monthRange is an inclusive range of months, counted from year 0.
+type monthRange struct {
+	start, end int
+}
+
+// parsePositionDates parses a position's date range, such as "Jan 2019 -
+// Present" or "2015 - 2018 · 3 yrs". A start without a month is January, an
+// end without one December, and an open end the month of now. ok is false
+// when no date is found.
+func parsePositionDates(dates string, now time.Time) (monthRange, bool) {
+	matches := positionDateRegex.FindAllStringSubmatch(dates, 2)
+	if len(matches) == 0 {
+		return monthRange{}, false
+	}
+	month := func(m []string, missing int) int {
+		year, _ := strconv.Atoi(m[2])
+		if mon, ok := positionMonths[strings.ToLower(m[1])]; ok {
+			return year*12 + mon - 1
+		}
+		return year*12 + missing - 1
+	}
+	current := now.Year()*12 + int(now.Month()) - 1
+	r := monthRange{start: month(matches[0], 1)}
+	switch {
+	case openEndedRangeRegex.MatchString(dates):
+		r.end = current
+	case len(matches) > 1:
+		r.end = month(matches[1], 12)
+	default:
+		r.end = month(matches[0], 12) // A position within one year.
+	}
+	r.end = min(r.end, current)
+	if r.end < r.start {
+		return monthRange{}, false
+	}
+	return r, true
+}
+
+// experienceFromPositions returns the years covered by the date ranges of
+// positions, counting months in overlapping positions once. ok is false when
+// no position's dates parse.
+func experienceFromPositions(positions []Position, now time.Time) (float64, bool) {
+	var ranges []monthRange
+	for _, p := range positions {
+		if r, ok := parsePositionDates(p.Dates, now); ok {
+			ranges = append(ranges, r)
+		}
+	}
+	if len(ranges) == 0 {
+		return 0, false
+	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].start < ranges[j].start })
+	months := 0
+	cur := ranges[0]
+	for _, r := range ranges[1:] {
+		if r.start <= cur.end+1 {
+			cur.end = max(cur.end, r.end)
+			continue
+		}
+		months += cur.end - cur.start + 1
+		cur = r
+	}
+	months += cur.end - cur.start + 1
+	return float64(months) / 12, true
+}
+
+// formatPositions flattens positions into one CSV cell.
+func formatPositions(positions []Position) string {
+	parts := make([]string, len(positions))
+	for i, p := range positions {
+		parts[i] = p.String()
+	}
+	return strings.Join(parts, "; ")
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// twoPositions is a profile with a current and a past position.
+const twoPositions = `<body>
+<h1 class="top-card-layout__title">Jane Doe</h1>
+<section class="experience"><ul>
+  <li>
+    <h3 class="experience-item__title">Senior Valve Engineer</h3>
+    <h4 class="experience-item__subtitle">Emerson</h4>
+    <span class="date-range">Jan 2019 - Present</span>
+  </li>
+  <li>
+    <h3 class="experience-item__title">Design Engineer</h3>
+    <h4 class="experience-item__subtitle">Acme   Valves</h4>
+    <span class="date-range">Jun 2014 - Dec 2018</span>
+  </li>
+</ul></section>
+</body>`
+
+func TestExtractPositions(t *testing.T) {
+	want := []Position{
+		{Title: "Senior Valve Engineer", Company: "Emerson", Dates: "Jan 2019 - Present", Current: true},
+		{Title: "Design Engineer", Company: "Acme Valves", Dates: "Jun 2014 - Dec 2018"},
+	}
+	if got := extractPositions(parseHTML(t, twoPositions)); !reflect.DeepEqual(got, want) {
+		t.Errorf("positions %+v, want %+v", got, want)
+	}
+}
+
+func TestExtractPositionsOfACompanyGroup(t *testing.T) {
+	// Roles grouped under one company heading take the company from it.
+	page := `<section class="experience"><ul><li>
+  <h4 class="experience-item__subtitle">Emerson</h4>
+  <ul>
+    <li><h3>Lead Engineer</h3><span class="date-range">2021 - Present</span></li>
+    <li><h3>Engineer</h3><span class="date-range">2017 - 2021</span></li>
+  </ul>
+</li></ul></section>`
+	got := extractPositions(parseHTML(t, page))
+	if len(got) != 2 || got[0].Company != "Emerson" || got[1].Company != "Emerson" || got[1].Title != "Engineer" {
+		t.Errorf("positions %+v, want two Emerson roles", got)
+	}
+}
+
+func TestCurrentPosition(t *testing.T) {
+	past := Position{Title: "Engineer", Company: "Acme"}
+	current := Position{Title: "Lead", Company: "Emerson", Current: true}
+	if p, _ := currentPosition([]Position{past, current}); p != current {
+		t.Errorf("current position %+v, want the open-ended one", p)
+	}
+	if p, _ := currentPosition([]Position{past}); p != past {
+		t.Errorf("current position %+v, want the most recent", p)
+	}
+	if _, ok := currentPosition(nil); ok {
+		t.Error("a current position of no positions")
+	}
+}
+
+func TestProfilePositionsSurfacedAndRendered(t *testing.T) {
+	page := twoPositions
+	c := parseProfilePage(context.Background(), &pageFetcher{}, "https://www.linkedin.com/in/jane-doe", []byte(page), parseHTML(t, page), profileOptions{})
+	if c.Title != "Senior Valve Engineer" || c.Company != "Emerson" {
+		t.Errorf("title %q at %q, want the current position", c.Title, c.Company)
+	}
+	if len(c.Positions) != 2 {
+		t.Fatalf("%d positions, want 2", len(c.Positions))
+	}
+
+	b, err := json.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded struct {
+		Positions []Position `json:"positions"`
+	}
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded.Positions, c.Positions) {
+		t.Errorf("JSON positions %+v, want %+v", decoded.Positions, c.Positions)
+	}
+
+	want := "Senior Valve Engineer at Emerson (Jan 2019 - Present); Design Engineer at Acme Valves (Jun 2014 - Dec 2018)"
+	if got := formatPositions(c.Positions); got != want {
+		t.Errorf("CSV positions %q, want %q", got, want)
+	}
+	if got := (Position{Company: "Emerson"}).String(); strings.Contains(got, " at ") {
+		t.Errorf("a position without a title formatted as %q", got)
+	}
+}
+
+func TestParsePositionDates(t *testing.T) {
+	now := time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC)
+	month := func(year, month int) int { return year*12 + month - 1 }
+	tests := []struct {
+		dates string
+		want  monthRange
+		ok    bool
+	}{
+		{"Jan 2019 - Present", monthRange{month(2019, 1), month(2026, 3)}, true},
+		{"Sept. 2019 – Current", monthRange{month(2019, 9), month(2026, 3)}, true},
+		{"2015 - 2018 · 3 yrs", monthRange{month(2015, 1), month(2018, 12)}, true},
+		{"Jun 2014 - Dec 2018", monthRange{month(2014, 6), month(2018, 12)}, true},
+		{"2020", monthRange{month(2020, 1), month(2020, 12)}, true},
+		{"Jun 2025 - 2027", monthRange{month(2025, 6), month(2026, 3)}, true},
+		{"", monthRange{}, false},
+		{"3 yrs 2 mos", monthRange{}, false},
+		{"2020 - 2018", monthRange{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := parsePositionDates(tt.dates, now)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parsePositionDates(%q) = %+v, %v; want %+v, %v", tt.dates, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestExperienceFromOverlappingPositions(t *testing.T) {
+	now := time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		dates []string
+		want  float64
+		ok    bool
+	}{
+		// Two overlapping positions count 2015 to 2021 once: 7 years, not 9.
+		{[]string{"Jan 2018 - Dec 2021", "Jan 2015 - Dec 2019"}, 7, true},
+		// One within the other adds nothing.
+		{[]string{"2010 - 2019", "Mar 2012 - Sep 2014"}, 10, true},
+		// Back-to-back positions join; a gap is left out.
+		{[]string{"Jan 2015 - Dec 2016", "Jan 2017 - Jun 2017", "Jan 2020 - Dec 2020"}, 3.5, true},
+		// Undated positions are skipped.
+		{[]string{"Jan 2024 - Present", "", "3 yrs"}, 27.0 / 12, true},
+		{[]string{"", "3 yrs"}, 0, false},
+		{nil, 0, false},
+	}
+	for _, tt := range tests {
+		var positions []Position
+		for _, d := range tt.dates {
+			positions = append(positions, Position{Title: "Engineer", Dates: d})
+		}
+		got, ok := experienceFromPositions(positions, now)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("experience of %q = %v, %v; want %v, %v", tt.dates, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestExperienceSource(t *testing.T) {
+	resetFieldPriorities(t)
+	fixedNow = time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC)
+	t.Cleanup(func() { fixedNow = time.Time{} })
+	profileURL := "https://www.linkedin.com/in/jane-doe"
+	page := twoPositions
+	enrich := func() Candidate {
+		c := snippetCandidate(resultTypeOrganic, profileURL, "Jane Doe", "Jane Doe - Valve Engineer", "Valve engineer with 12 years of experience.")
+		profile := parseProfilePage(context.Background(), &pageFetcher{}, profileURL, []byte(page), parseHTML(t, page), profileOptions{})
+		mergeFields("profile", &c, fixedNow, profile, fixedNow)
+		return c
+	}
+
+	// Jun 2014 to Present, the positions back to back: 11 years 10 months.
+	if c := enrich(); c.ExperienceYears != 11.8 || c.Sources["experience"] != sourcePositionDates {
+		t.Errorf("experience %v from %s, want 11.8 from the position dates", c.ExperienceYears, c.Sources["experience"])
+	}
+	if _, err := parseFlags([]string{"-experience-source", experienceFromSnippet}); err != nil {
+		t.Fatal(err)
+	}
+	if c := enrich(); c.ExperienceYears != 12 || c.Sources["experience"] != sourceSnippet {
+		t.Errorf("experience %v from %s under -experience-source snippet, want 12 from the snippet", c.ExperienceYears, c.Sources["experience"])
+	}
+	if _, err := parseFlags([]string{"-experience-source", "profile"}); err == nil {
+		t.Error("-experience-source profile accepted")
+	}
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// maxSummaryLength caps the About text stored on a candidate.
+const maxSummaryLength = 2000
+
+// summarySelectors locate the About section of a public profile, in priority order.
+var summarySelectors = []string{
+	"section.summary .core-section-container__content",
+	".core-section-container.summary .core-section-container__content",
+	"section[data-section='summary'] .core-section-container__content",
+}
+
+// extractSummary returns the profile's About text, preferring the JSON-LD
+// description, then og:description, then the summary section markup, and
+// the source it came from.
+func extractSummary(doc *goquery.Document) (summary, source string) {
+	summary, source = jsonLDDescription(doc), sourceJSONLD
+	if summary == "" {
+		summary, _ = doc.Find(`meta[property="og:description"]`).Attr("content")
+		source = sourceOGDescription
+	}
+	if strings.TrimSpace(summary) == "" {
+		for _, sel := range summarySelectors {
+			if text := doc.Find(sel).First().Text(); strings.TrimSpace(text) != "" {
+				summary, source = text, sourceSummarySection
+				break
+			}
+		}
+	}
+	return truncateRunes(strings.Join(strings.Fields(summary), " "), maxSummaryLength), source
+}
+
+// jsonLDDescription returns the description of the first Person found in the
+// page's JSON-LD blocks.
+func jsonLDDescription(doc *goquery.Document) string {
+	var description string
+	doc.Find(`script[type="application/ld+json"]`).EachWithBreak(func(i int, s *goquery.Selection) bool {
+		var data interface{}
+		if err := json.Unmarshal([]byte(s.Text()), &data); err != nil {
+			return true
+		}
+		description = findPersonDescription(data)
+		return description == ""
+	})
+	return description
+}
+
+// pageLanguage returns the language a profile page declares: the
+// inLanguage of its JSON-LD, or else its html lang attribute.
+func pageLanguage(doc *goquery.Document) string {
+	var lang string
+	doc.Find(`script[type="application/ld+json"]`).EachWithBreak(func(i int, s *goquery.Selection) bool {
+		var data interface{}
+		if err := json.Unmarshal([]byte(s.Text()), &data); err != nil {
+			return true
+		}
+		lang = normalizeLanguageTag(findInLanguage(data))
+		return lang == ""
+	})
+	if lang == "" {
+		lang = normalizeLanguageTag(doc.Find("html").AttrOr("lang", ""))
+	}
+	return lang
+}
+
+// findInLanguage walks decoded JSON-LD for the first inLanguage, given as a
+// tag or as a Language with the tag in alternateName.
+func findInLanguage(v interface{}) string {
+	switch node := v.(type) {
+	case []interface{}:
+		for _, item := range node {
+			if lang := findInLanguage(item); lang != "" {
+				return lang
+			}
+		}
+	case map[string]interface{}:
+		switch lang := node["inLanguage"].(type) {
+		case string:
+			return lang
+		case map[string]interface{}:
+			if tag, _ := lang["alternateName"].(string); tag != "" {
+				return tag
+			}
+		}
+		if graph, ok := node["@graph"]; ok {
+			return findInLanguage(graph)
+		}
+	}
+	return ""
+}
+
+// findPersonDescription walks decoded JSON-LD (objects, arrays, and @graph
+// containers) looking for a Person with a description.
+func findPersonDescription(v interface{}) string {
+	switch node := v.(type) {
+	case []interface{}:
+		for _, item := range node {
+			if d := findPersonDescription(item); d != "" {
+				return d
+			}
+		}
+	case map[string]interface{}:
+		if t, _ := node["@type"].(string); t == "Person" {
+			if d, _ := node["description"].(string); d != "" {
+				return d
+			}
+		}
+		if graph, ok := node["@graph"]; ok {
+			return findPersonDescription(graph)
+		}
+	}
+	return ""
+}
+
+// truncateRunes shortens s to at most n runes.
+func truncateRunes(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n])
+}
+
+// obfuscatedAt and obfuscatedDot match the bracketed separators people use to
+// hide addresses from scrapers, e.g. "jane [at] example [dot] com".
+// spelledEmail matches the bare form "jane at example dot com".
+var (
+	obfuscatedAt  = regexp.MustCompile(`(?i)\s*[\[\(\{]\s*at\s*[\]\)\}]\s*`)
+	obfuscatedDot = regexp.MustCompile(`(?i)\s*[\[\(\{]\s*dot\s*[\]\)\}]\s*`)
+	spelledEmail  = regexp.MustCompile(`(?i)([a-z0-9._%+-]+)\s+at\s+([a-z0-9-]+(?:\s+dot\s+[a-z0-9-]+)*\s+dot\s+[a-z]{2,})\b`)
+	spelledDot    = regexp.MustCompile(`(?i)\s+dot\s+`)
+)
+
+// extractObfuscatedEmail finds an email written with spelled-out separators.
+func extractObfuscatedEmail(text string) string {
+	normalized := obfuscatedAt.ReplaceAllString(text, "@")
+	normalized = obfuscatedDot.ReplaceAllString(normalized, ".")
+	if email := emailMatcher.FindString(normalized); email != "" {
+		return email
+	}
+	if m := spelledEmail.FindStringSubmatch(text); m != nil {
+		domain := spelledDot.ReplaceAllString(m[2], ".")
+		return m[1] + "@" + domain
+	}
+	return ""
+}
+
+// extractContactFromText runs the email, obfuscated-email, and phone extractors
+// over a block of free text. obfuscated reports that the email was spelled out.
+func extractContactFromText(text string) (email, phone string, obfuscated bool) {
+	email = emailMatcher.FindString(text)
+	if email == "" {
+		email = extractObfuscatedEmail(text)
+		obfuscated = email != ""
+	}
+	phone = extractPhone(text)
+	return email, phone, obfuscated
+}
+
+// matchTerms returns the search keywords that appear in any of the texts,
+// compared case-insensitively.
+func matchTerms(keywords string, texts ...string) []string {
+	haystack := strings.ToLower(strings.Join(texts, " "))
+	var matched []string
+	seen := make(map[string]bool)
+	for _, term := range strings.Fields(strings.ToLower(keywords)) {
+		// Strip query syntax: phrase quotes, OR groups, and the loose marker.
+		term = strings.Trim(term, `"(),*`)
+		if term == "" || term == "or" || seen[term] {
+			continue
+		}
+		seen[term] = true
+		if strings.Contains(haystack, term) {
+			matched = append(matched, term)
+		}
+	}
+	return matched
+}
+
+// contactInfoSectionSelector matches each entry of a profile's contact-info
+// overlay, which is embedded in some public profiles and served on its own at
+// <profile>/overlay/contact-info/.
+const contactInfoSectionSelector = "section.pv-contact-info__contact-type"
+
+// contactInfo holds the structured fields of a contact-info overlay.
+type contactInfo struct {
+	Website string
+	Twitter string
+	Email   string
+	Phone   string
+}
+
+// empty reports whether no contact field was found.
+func (ci contactInfo) empty() bool {
+	return ci == contactInfo{}
+}
+
+// contactInfoType identifies a contact-info section from its ci-* class or,
+// failing that, its header text.
+func contactInfoType(s *goquery.Selection) string {
+	class, _ := s.Attr("class")
+	header := strings.ToLower(s.Find(".pv-contact-info__header").Text())
+	switch {
+	case strings.Contains(class, "ci-websites") || strings.Contains(header, "website"):
+		return "website"
+	case strings.Contains(class, "ci-twitter") || strings.Contains(header, "twitter"):
+		return "twitter"
+	case strings.Contains(class, "ci-email") || strings.Contains(header, "email"):
+		return "email"
+	case strings.Contains(class, "ci-phone") || strings.Contains(header, "phone"):
+		return "phone"
+	}
+	return ""
+}
+
+// parseContactInfo extracts the contact-info overlay fields from a page.
+func parseContactInfo(doc *goquery.Document) contactInfo {
+	var ci contactInfo
+	doc.Find(contactInfoSectionSelector).Each(func(i int, s *goquery.Selection) {
+		href, _ := s.Find("a[href]").First().Attr("href")
+		text := strings.TrimSpace(s.Find(".pv-contact-info__ci-container").Text())
+		if text == "" {
+			text = strings.TrimSpace(s.Find("a").First().Text())
+		}
+		switch contactInfoType(s) {
+		case "website":
+			if ci.Website == "" {
+				ci.Website = firstNonEmpty(href, text)
+			}
+		case "twitter":
+			if ci.Twitter == "" {
+				ci.Twitter = firstNonEmpty(href, text)
+			}
+		case "email":
+			if ci.Email == "" {
+				ci.Email = emailMatcher.FindString(strings.TrimPrefix(href, "mailto:") + " " + text)
+			}
+		case "phone":
+			if ci.Phone == "" {
+				ci.Phone = extractPhone(text)
+			}
+		}
+	})
+	return ci
+}
+
+// contactInfoURL returns the URL of a profile's contact-info overlay.
+func contactInfoURL(profileURL string) string {
+	return strings.TrimSuffix(profileURL, "/") + "/overlay/contact-info/"
+}
+
+// firstNonEmpty returns the first of values that is not blank.
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+	return ""
+}
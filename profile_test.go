@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// parseHTML parses a page for extraction tests.
+func parseHTML(t *testing.T, html string) *goquery.Document {
+	t.Helper()
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func TestExtractSummary(t *testing.T) {
+	section := `<section class="summary"><div class="core-section-container__content">
+		Valve   engineer
+		at Acme</div></section>`
+	tests := []struct {
+		name, html, want, source string
+	}{
+		{
+			"JSON-LD first",
+			`<head><script type="application/ld+json">{"@type":"Person","description":"From JSON-LD"}</script>
+			<meta property="og:description" content="From og"></head><body>` + section + `</body>`,
+			"From JSON-LD", sourceJSONLD,
+		},
+		{
+			"JSON-LD graph",
+			`<script type="application/ld+json">{"@graph":[{"@type":"WebPage"},{"@type":"Person","description":"In a graph"}]}</script>`,
+			"In a graph", sourceJSONLD,
+		},
+		{
+			"og:description",
+			`<head><script type="application/ld+json">not json</script><meta property="og:description" content="From og"></head>`,
+			"From og", sourceOGDescription,
+		},
+		{"summary section, whitespace collapsed", `<body>` + section + `</body>`, "Valve engineer at Acme", sourceSummarySection},
+		{"none", `<body><p>Nothing here</p></body>`, "", sourceOGDescription},
+	}
+	for _, tt := range tests {
+		summary, source := extractSummary(parseHTML(t, tt.html))
+		if summary != tt.want || (tt.want != "" && source != tt.source) {
+			t.Errorf("%s: extractSummary = %q from %s, want %q from %s", tt.name, summary, source, tt.want, tt.source)
+		}
+	}
+}
+
+func TestExtractSummaryTruncatesRunes(t *testing.T) {
+	long := strings.Repeat("é", maxSummaryLength+10)
+	summary, _ := extractSummary(parseHTML(t, `<meta property="og:description" content="`+long+`">`))
+	if n := len([]rune(summary)); n != maxSummaryLength {
+		t.Errorf("summary of %d runes, want %d", n, maxSummaryLength)
+	}
+}
+
+func TestMatchTerms(t *testing.T) {
+	tests := []struct {
+		keywords string
+		texts    []string
+		want     []string
+	}{
+		{"valve engineer", []string{"Senior Valve Engineer"}, []string{"valve", "engineer"}},
+		{`"control valve" OR (desuperheater)`, []string{"Desuperheater sizing", "control loops"}, []string{"control", "desuperheater"}},
+		{"valve valve pump*", []string{"valve", "pumps"}, []string{"valve", "pump"}},
+		{"turbine", []string{"Valve engineer"}, nil},
+	}
+	for _, tt := range tests {
+		if got := matchTerms(tt.keywords, tt.texts...); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("matchTerms(%q, %q) = %q, want %q", tt.keywords, tt.texts, got, tt.want)
+		}
+	}
+}
+
+func TestExtractContactFromSummaryText(t *testing.T) {
+	tests := []struct {
+		text, email string
+		obfuscated  bool
+	}{
+		{"Reach me at jane.doe@example.com for valve work", "jane.doe@example.com", false},
+		{"Mail: jane [at] example [dot] co [dot] in", "jane@example.co.in", true},
+		{"jane at example dot com", "jane@example.com", true},
+		{"Valve engineer at Acme", "", false},
+	}
+	for _, tt := range tests {
+		email, _, obfuscated := extractContactFromText(tt.text)
+		if email != tt.email || obfuscated != tt.obfuscated {
+			t.Errorf("extractContactFromText(%q) = %q, %v; want %q, %v", tt.text, email, obfuscated, tt.email, tt.obfuscated)
+		}
+	}
+}
+
+// pageFetcher serves pages from a map by URL, recording the URLs requested.
+type pageFetcher struct {
+	pages     map[string]string
+	requested []string
+}
+
+func (f *pageFetcher) Fetch(ctx context.Context, pageURL string) ([]byte, error) {
+	f.requested = append(f.requested, pageURL)
+	page, ok := f.pages[pageURL]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return []byte(page), nil
+}
+
+// contactInfoOverlay is a contact-info overlay with every field the parser
+// knows, some identified by class and some by header only.
+const contactInfoOverlay = `<section class="pv-contact-info__contact-type ci-websites">
+	<a href="https://acme.example.com">acme.example.com</a></section>
+<section class="pv-contact-info__contact-type"><h3 class="pv-contact-info__header">Twitter</h3>
+	<a href="https://twitter.com/janedoe">janedoe</a></section>
+<section class="pv-contact-info__contact-type ci-email">
+	<a href="mailto:jane@example.com">jane@example.com</a></section>
+<section class="pv-contact-info__contact-type ci-phone">
+	<span class="pv-contact-info__ci-container">+91 98765 43210 (Mobile)</span></section>`
+
+func TestParseContactInfo(t *testing.T) {
+	ci := parseContactInfo(parseHTML(t, contactInfoOverlay))
+	want := contactInfo{
+		Website: "https://acme.example.com",
+		Twitter: "https://twitter.com/janedoe",
+		Email:   "jane@example.com",
+		Phone:   "+91 98765 43210",
+	}
+	if ci != want {
+		t.Errorf("parseContactInfo = %+v, want %+v", ci, want)
+	}
+	if ci := parseContactInfo(parseHTML(t, `<body><p>jane@example.com</p></body>`)); !ci.empty() {
+		t.Errorf("page without an overlay gave %+v", ci)
+	}
+	if got, want := contactInfoURL("https://www.linkedin.com/in/jane-doe/"), "https://www.linkedin.com/in/jane-doe/overlay/contact-info/"; got != want {
+		t.Errorf("contactInfoURL = %s, want %s", got, want)
+	}
+}
+
+func TestFetchContactInfoOnlyWhenNotEmbedded(t *testing.T) {
+	profileURL := "https://www.linkedin.com/in/jane-doe"
+	f := &pageFetcher{pages: map[string]string{contactInfoURL(profileURL): contactInfoOverlay}}
+	page := `<body><h1 class="top-card-layout__title">Jane Doe</h1></body>`
+
+	c := parseProfilePage(context.Background(), f, profileURL, []byte(page), parseHTML(t, page), profileOptions{fetchContactInfo: true})
+	if c.Website != "https://acme.example.com" || c.Twitter != "https://twitter.com/janedoe" {
+		t.Errorf("candidate %+v, want the overlay's website and Twitter", c)
+	}
+	if len(f.requested) != 1 {
+		t.Errorf("requests %q, want the overlay once", f.requested)
+	}
+
+	// An embedded overlay saves the request; so does leaving the option off.
+	f.requested = nil
+	embedded := `<body>` + contactInfoOverlay + `</body>`
+	if c := parseProfilePage(context.Background(), f, profileURL, []byte(embedded), parseHTML(t, embedded), profileOptions{fetchContactInfo: true}); c.Website == "" {
+		t.Error("the embedded overlay was not parsed")
+	}
+	parseProfilePage(context.Background(), f, profileURL, []byte(page), parseHTML(t, page), profileOptions{})
+	if len(f.requested) != 0 {
+		t.Errorf("requests %q, want none", f.requested)
+	}
+
+	// A failed overlay request leaves the profile as it was.
+	f.pages = nil
+	if c := parseProfilePage(context.Background(), f, profileURL, []byte(page), parseHTML(t, page), profileOptions{fetchContactInfo: true}); c.Name != "Jane Doe" || c.Website != "" {
+		t.Errorf("candidate %+v after a failed overlay request", c)
+	}
+}
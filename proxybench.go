@@ -0,0 +1,365 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Quality labels proxy-bench gives proxies, best first.
+const (
+	qualityGood = "good" // Fast, and Google serves it results.
+	qualityFair = "fair"
+	qualityPoor = "poor" // Slow; use only when the better ones are busy.
+	qualityBad  = "bad"  // Failed, or Google answers it with a CAPTCHA. The pool skips it.
+)
+
+// proxyQualityRank orders quality labels for the pool. Unrated proxies rank
+// with fair ones.
+var proxyQualityRank = map[string]int{qualityGood: 0, qualityFair: 1, "": 1, qualityPoor: 2, qualityBad: 3}
+
+// Latency limits of the good and fair ratings, for the neutral fetch.
+const (
+	goodFetchLatency = time.Second
+	fairFetchLatency = 3 * time.Second
+)
+
+const (
+	defaultBenchURL     = "https://www.gstatic.com/generate_204"
+	defaultIPInfoURL    = "https://ipinfo.io/json"
+	benchCaptchaQuery   = "weather" // Harmless enough not to be a bot signal itself.
+	defaultBenchTimeout = 15 * time.Second
+)
+
+// proxyBenchResult is what proxy-bench measured for one proxy. Times are in
+// milliseconds; zero when the step did not happen.
+type proxyBenchResult struct {
+	Proxy     string `json:"proxy"`
+	Class     string `json:"class,omitempty"`
+	ConnectMS int64  `json:"connect_ms"` // TCP connect to the proxy.
+	TLSMS     int64  `json:"tls_ms"`     // TLS handshake with the target, through the proxy.
+	FetchMS   int64  `json:"fetch_ms"`   // The whole neutral fetch.
+	Status    int    `json:"status,omitempty"`
+	ExitIP    string `json:"exit_ip,omitempty"`
+	Country   string `json:"country,omitempty"`
+	City      string `json:"city,omitempty"`
+	Captcha   *bool  `json:"captcha,omitempty"` // Unset unless -captcha-check.
+	Error     string `json:"error,omitempty"`
+	Quality   string `json:"quality"`
+	Rank      int    `json:"rank"` // Recommended order, from 1.
+
+	entry proxyEntry
+}
+
+// proxyBenchOptions configure a proxy-bench run.
+type proxyBenchOptions struct {
+	benchURL     string
+	ipInfoURL    string
+	captchaCheck bool
+	timeout      time.Duration
+}
+
+// benchClient returns a client that goes through proxy without reusing
+// connections, so every request pays, and measures, its own handshakes.
+func benchClient(proxy string, timeout time.Duration) (*http.Client, error) {
+	proxyURL, err := url.Parse(proxy)
+	if err != nil || proxyURL.Host == "" {
+		return nil, fmt.Errorf("invalid proxy URL %q", proxy)
+	}
+	transport := &http.Transport{Proxy: http.ProxyURL(proxyURL), DisableKeepAlives: true}
+	return &http.Client{Transport: transport, Timeout: timeout}, nil
+}
+
+// timedGet fetches target with client, filling in the connect, TLS, and
+// total times of r. It returns the response status and body.
+func timedGet(ctx context.Context, client *http.Client, target string, r *proxyBenchResult) (int, []byte, error) {
+	var connectStart, tlsStart time.Time
+	trace := &httptrace.ClientTrace{
+		ConnectStart: func(string, string) { connectStart = time.Now() },
+		ConnectDone: func(_, _ string, err error) {
+			if err == nil {
+				r.ConnectMS = time.Since(connectStart).Milliseconds()
+			}
+		},
+		TLSHandshakeStart: func() { tlsStart = time.Now() },
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			if err == nil {
+				r.TLSMS = time.Since(tlsStart).Milliseconds()
+			}
+		},
+	}
+	req, err := http.NewRequestWithContext(httptrace.WithClientTrace(ctx, trace), http.MethodGet, target, nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header = headerProfiles[0].headers()
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	r.FetchMS = time.Since(start).Milliseconds()
+	return resp.StatusCode, body, err
+}
+
+// parseIPInfo reads the exit IP and location from an IP-info response. The
+// field names of ipinfo.io, ip-api.com, and ipapi.co are understood.
+func parseIPInfo(body []byte) (ip, country, city string, err error) {
+	var info map[string]any
+	if err := json.Unmarshal(body, &info); err != nil {
+		return "", "", "", fmt.Errorf("invalid IP info: %w", err)
+	}
+	field := func(names ...string) string {
+		for _, name := range names {
+			if s, ok := info[name].(string); ok && s != "" {
+				return s
+			}
+		}
+		return ""
+	}
+	return field("ip", "query"), field("country_code", "countryCode", "country"), field("city"), nil
+}
+
+// isCaptchaResponse reports whether Google answered a search with its
+// CAPTCHA or rate limit rather than results.
+func isCaptchaResponse(status int, finalURL *url.URL, body []byte) bool {
+	return status == http.StatusTooManyRequests ||
+		finalURL != nil && strings.HasPrefix(finalURL.Path, "/sorry/") ||
+		strings.Contains(string(body), "unusual traffic")
+}
+
+// benchProxy measures one proxy.
+func benchProxy(ctx context.Context, e proxyEntry, opts proxyBenchOptions) proxyBenchResult {
+	r := proxyBenchResult{Proxy: e.url, Class: e.class, entry: e}
+	client, err := benchClient(e.url, opts.timeout)
+	if err != nil {
+		r.Error = err.Error()
+		return r
+	}
+	status, _, err := timedGet(ctx, client, opts.benchURL, &r)
+	r.Status = status
+	switch {
+	case err != nil:
+		r.Error = err.Error()
+		return r
+	case status >= 400:
+		r.Error = fmt.Sprintf("status %d from %s", status, opts.benchURL)
+		return r
+	}
+
+	if opts.ipInfoURL != "" {
+		var info proxyBenchResult // Its times are not the proxy's.
+		if _, body, err := timedGet(ctx, client, opts.ipInfoURL, &info); err != nil {
+			r.Error = fmt.Sprintf("IP info: %v", err)
+		} else if r.ExitIP, r.Country, r.City, err = parseIPInfo(body); err != nil {
+			r.Error = err.Error()
+		}
+	}
+
+	if opts.captchaCheck {
+		target := googleSearchURL() + "?" + url.Values{"q": {benchCaptchaQuery}}.Encode()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+		if err == nil {
+			req.Header = headerProfiles[0].headers()
+			var resp *http.Response
+			if resp, err = client.Do(req); err == nil {
+				body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+				resp.Body.Close()
+				captcha := isCaptchaResponse(resp.StatusCode, resp.Request.URL, body)
+				r.Captcha = &captcha
+			}
+		}
+		if err != nil {
+			r.Error = fmt.Sprintf("captcha check: %v", err)
+		}
+	}
+	return r
+}
+
+// rate gives a measured proxy its quality label.
+func (r *proxyBenchResult) rate() {
+	fetch := time.Duration(r.FetchMS) * time.Millisecond
+	switch {
+	case r.Status == 0 || r.Status >= 400 || r.Captcha != nil && *r.Captcha:
+		r.Quality = qualityBad
+	case fetch <= goodFetchLatency:
+		r.Quality = qualityGood
+	case fetch <= fairFetchLatency:
+		r.Quality = qualityFair
+	default:
+		r.Quality = qualityPoor
+	}
+}
+
+// runProxyBench measures every proxy, at most concurrency at once, and
+// returns the results in recommended order: best quality first, then
+// fastest.
+func runProxyBench(ctx context.Context, entries []proxyEntry, opts proxyBenchOptions, concurrency int) []proxyBenchResult {
+	results := make([]proxyBenchResult, len(entries))
+	sem := make(chan struct{}, max(concurrency, 1))
+	var wg sync.WaitGroup
+	for i, e := range entries {
+		wg.Add(1)
+		go func(i int, e proxyEntry) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			results[i] = benchProxy(ctx, e, opts)
+			results[i].rate()
+		}(i, e)
+	}
+	wg.Wait()
+	sort.SliceStable(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if ra, rb := proxyQualityRank[a.Quality], proxyQualityRank[b.Quality]; ra != rb {
+			return ra < rb
+		}
+		return a.FetchMS < b.FetchMS
+	})
+	for i := range results {
+		results[i].Rank = i + 1
+	}
+	return results
+}
+
+// writeBenchTable prints results as an aligned table.
+func writeBenchTable(w io.Writer, results []proxyBenchResult) error {
+	rows := [][]string{{"RANK", "QUALITY", "PROXY", "CONNECT", "TLS", "FETCH", "EXIT IP", "REGION", "CAPTCHA", "ERROR"}}
+	for _, r := range results {
+		captcha := "-"
+		if r.Captcha != nil {
+			captcha = fmt.Sprint(*r.Captcha)
+		}
+		rows = append(rows, []string{
+			fmt.Sprint(r.Rank), r.Quality, r.Proxy,
+			fmt.Sprintf("%dms", r.ConnectMS), fmt.Sprintf("%dms", r.TLSMS), fmt.Sprintf("%dms", r.FetchMS),
+			firstNonEmpty(r.ExitIP, "-"), firstNonEmpty(strings.TrimSpace(r.Country+" "+r.City), "-"), captcha, r.Error,
+		})
+	}
+	widths := make([]int, len(rows[0]))
+	for _, row := range rows {
+		for i, cell := range row {
+			widths[i] = max(widths[i], len(cell))
+		}
+	}
+	for _, row := range rows {
+		var b strings.Builder
+		for i, cell := range row {
+			if i == len(row)-1 {
+				b.WriteString(cell)
+				break
+			}
+			fmt.Fprintf(&b, "%-*s  ", widths[i], cell)
+		}
+		if _, err := fmt.Fprintln(w, strings.TrimRight(b.String(), " ")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeAnnotatedProxyFile writes results in recommended order as a
+// -proxy-file, each labeled with its region and quality, keeping its class
+// and rpm.
+func writeAnnotatedProxyFile(filename string, results []proxyBenchResult) error {
+	return writeFileAtomic(filename, func(w io.Writer) error {
+		fmt.Fprintf(w, "# Rated by profilesearch proxy-bench at %s, best first.\n", clockNow().UTC().Format(time.RFC3339))
+		for _, r := range results {
+			e := r.entry
+			note := fmt.Sprintf("fetch %dms", r.FetchMS)
+			if r.ExitIP != "" {
+				note += ", exit " + r.ExitIP
+			}
+			if r.Error != "" {
+				note += ", " + r.Error
+			}
+			fields := []string{e.url}
+			if e.class != "" {
+				fields = append([]string{e.class}, fields...)
+			}
+			if e.rpm > 0 {
+				fields = append(fields, fmt.Sprintf("rpm=%d", e.rpm))
+			}
+			if region := firstNonEmpty(r.Country, e.region); region != "" {
+				fields = append(fields, "region="+region)
+			}
+			fields = append(fields, "quality="+r.Quality)
+			if _, err := fmt.Fprintf(w, "# %s\n%s\n", strings.ReplaceAll(note, "\n", " "), strings.Join(fields, " ")); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// runProxyBenchCommand measures the proxies of a -proxy-file for this
+// workload and reports them best first.
+func runProxyBenchCommand(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("proxy-bench", flag.ExitOnError)
+	proxyFile := fs.String("proxy-file", "", "proxy file to measure, as for searches")
+	var opts proxyBenchOptions
+	fs.StringVar(&opts.benchURL, "url", defaultBenchURL, "neutral URL fetched through each proxy")
+	fs.StringVar(&opts.ipInfoURL, "ip-info-url", defaultIPInfoURL, "JSON endpoint reporting the caller's IP and location, fetched through each proxy (empty skips it)")
+	fs.BoolVar(&opts.captchaCheck, "captcha-check", false, "make one harmless Google search through each proxy to see whether it gets a CAPTCHA")
+	fs.DurationVar(&opts.timeout, "timeout", defaultBenchTimeout, "timeout of each request")
+	concurrency := fs.Int("concurrency", 4, "proxies measured at once")
+	format := fs.String("format", "text", "report format: text or json")
+	annotate := fs.String("write-annotated", "", "write the proxies, best first and labeled with region and quality, to this proxy file")
+	fs.Var(&googleDomain, "google-domain", "Google domain searched by -captcha-check")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: profilesearch proxy-bench -proxy-file file [-captcha-check] [-format text|json] [-write-annotated file]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if *proxyFile == "" {
+		fs.Usage()
+		return errors.New("proxy-bench needs -proxy-file")
+	}
+	if *format != "text" && *format != "json" {
+		return fmt.Errorf("invalid -format %q: want text or json", *format)
+	}
+	if *concurrency < 1 {
+		return errors.New("invalid -concurrency: must be at least 1")
+	}
+	// Proxies rated bad before are measured again, since they may recover.
+	entries, err := loadProxyFile(*proxyFile)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return fmt.Errorf("no proxies in %s", *proxyFile)
+	}
+
+	results := runProxyBench(ctx, entries, opts, *concurrency)
+	if *format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(results)
+	} else {
+		err = writeBenchTable(os.Stdout, results)
+	}
+	if err != nil {
+		return err
+	}
+	if *annotate != "" {
+		if err := writeAnnotatedProxyFile(*annotate, results); err != nil {
+			return fmt.Errorf("failed to write annotated proxy file: %w", err)
+		}
+		fmt.Fprintf(os.Stderr, "Wrote %d rated proxies to %s\n", len(results), *annotate)
+	}
+	return nil
+}
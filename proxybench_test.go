@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Targets of the fake upstreams, which every fake proxy answers itself.
+const (
+	benchTarget  = "http://bench.example/generate_204"
+	ipInfoTarget = "http://ipinfo.example/json"
+)
+
+// startBenchProxy starts a fake forward proxy whose exit IP is ip, in
+// country. It answers the IP-info endpoint for that exit, and any other
+// request with status after delay.
+func startBenchProxy(t *testing.T, ip, country string, status int, delay time.Duration) string {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.String() == ipInfoTarget {
+			fmt.Fprintf(w, `{"ip":%q,"country":%q,"city":"Pune"}`, ip, country)
+			return
+		}
+		time.Sleep(delay)
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+func TestProxyBenchRate(t *testing.T) {
+	yes, no := true, false
+	tests := []struct {
+		r    proxyBenchResult
+		want string
+	}{
+		{proxyBenchResult{Status: 204, FetchMS: 300}, qualityGood},
+		{proxyBenchResult{Status: 204, FetchMS: 300, Captcha: &no}, qualityGood},
+		{proxyBenchResult{Status: 204, FetchMS: 2000}, qualityFair},
+		{proxyBenchResult{Status: 204, FetchMS: 5000}, qualityPoor},
+		{proxyBenchResult{Status: 204, FetchMS: 300, Captcha: &yes}, qualityBad},
+		{proxyBenchResult{Status: 503, FetchMS: 300}, qualityBad},
+		{proxyBenchResult{Error: "connection refused"}, qualityBad},
+	}
+	for _, tt := range tests {
+		r := tt.r
+		if r.rate(); r.Quality != tt.want {
+			t.Errorf("%+v rated %s, want %s", tt.r, r.Quality, tt.want)
+		}
+	}
+}
+
+func TestParseIPInfo(t *testing.T) {
+	tests := []struct {
+		body              string
+		ip, country, city string
+	}{
+		{`{"ip":"203.0.113.7","country":"IN","city":"Pune"}`, "203.0.113.7", "IN", "Pune"},                                // ipinfo.io
+		{`{"query":"203.0.113.7","countryCode":"DE","country":"Germany","city":"Berlin"}`, "203.0.113.7", "DE", "Berlin"}, // ip-api.com
+		{`{"ip":"203.0.113.7","country_code":"US","country":"United States"}`, "203.0.113.7", "US", ""},                   // ipapi.co
+	}
+	for _, tt := range tests {
+		ip, country, city, err := parseIPInfo([]byte(tt.body))
+		if err != nil || ip != tt.ip || country != tt.country || city != tt.city {
+			t.Errorf("parseIPInfo(%s) = %s, %s, %s, %v", tt.body, ip, country, city, err)
+		}
+	}
+	if _, _, _, err := parseIPInfo([]byte("<html>")); err == nil {
+		t.Error("parsed IP info from HTML")
+	}
+}
+
+func TestIsCaptchaResponse(t *testing.T) {
+	sorry, _ := url.Parse("https://www.google.com/sorry/index?continue=x")
+	results, _ := url.Parse("https://www.google.com/search?q=weather")
+	tests := []struct {
+		status int
+		final  *url.URL
+		body   string
+		want   bool
+	}{
+		{200, results, "<html>Weather in Pune</html>", false},
+		{429, results, "", true},
+		{200, sorry, "", true},
+		{200, results, "Our systems have detected unusual traffic from your computer network.", true},
+	}
+	for _, tt := range tests {
+		if got := isCaptchaResponse(tt.status, tt.final, []byte(tt.body)); got != tt.want {
+			t.Errorf("isCaptchaResponse(%d, %s, %q) = %v, want %v", tt.status, tt.final, tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestProxyBenchReportAndAnnotatedFile(t *testing.T) {
+	dir := t.TempDir()
+	fast := startBenchProxy(t, "203.0.113.1", "IN", http.StatusNoContent, 0)
+	slower := startBenchProxy(t, "203.0.113.2", "DE", http.StatusNoContent, 60*time.Millisecond)
+	failing := startBenchProxy(t, "203.0.113.3", "US", http.StatusBadGateway, 0)
+	dead := httptest.NewServer(http.NotFoundHandler())
+	dead.Close()
+
+	proxyFile := filepath.Join(dir, "proxies.txt")
+	list := fmt.Sprintf("# Proxies to rate.\n%s region=US\nprofile %s rpm=30\n%s\n%s\n", failing, slower, dead.URL, fast)
+	if err := os.WriteFile(proxyFile, []byte(list), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	annotated := filepath.Join(dir, "rated.txt")
+	out, err := captureStdout(t, func() error {
+		return runProxyBenchCommand(context.Background(), []string{"-proxy-file", proxyFile, "-url", benchTarget,
+			"-ip-info-url", ipInfoTarget, "-format", "json", "-concurrency", "2", "-timeout", "5s", "-write-annotated", annotated})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var results []proxyBenchResult
+	if err := json.Unmarshal([]byte(out), &results); err != nil {
+		t.Fatalf("bad JSON report %q: %v", out, err)
+	}
+	var order []string
+	for i, r := range results {
+		order = append(order, r.Proxy)
+		if r.Rank != i+1 {
+			t.Errorf("%s ranked %d at position %d", r.Proxy, r.Rank, i+1)
+		}
+	}
+	if len(results) != 4 || order[0] != fast || order[1] != slower {
+		t.Fatalf("report order %q, want the fast proxy, then the slower one, then the failures", order)
+	}
+	if r := results[0]; r.Quality != qualityGood || r.ExitIP != "203.0.113.1" || r.Country != "IN" || r.City != "Pune" || r.Status != http.StatusNoContent {
+		t.Errorf("fast proxy measured as %+v", r)
+	}
+	if r := results[1]; r.FetchMS < 60 || r.Class != hostClassProfile {
+		t.Errorf("slower proxy measured as %+v, want a fetch of at least 60ms", r)
+	}
+	for _, r := range results[2:] {
+		if r.Quality != qualityBad || r.Error == "" {
+			t.Errorf("%s rated %s with error %q, want bad with the reason", r.Proxy, r.Quality, r.Error)
+		}
+	}
+
+	// The annotated file reads back as a proxy file, best first, keeping each
+	// proxy's class and rate and labeled with its region and quality.
+	entries, err := loadProxyFile(annotated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []proxyEntry{
+		{url: fast, region: "IN", quality: qualityGood},
+		{class: hostClassProfile, url: slower, rpm: 30, region: "DE", quality: qualityGood},
+	}
+	if len(entries) != 4 || entries[0] != want[0] || entries[1] != want[1] {
+		t.Errorf("annotated entries %+v, want %+v first", entries, want)
+	}
+	for _, e := range entries[2:] {
+		if e.quality != qualityBad {
+			t.Errorf("%s annotated %q, want bad", e.url, e.quality)
+		}
+		if e.url == failing && e.region != "US" {
+			t.Errorf("failing proxy lost its region label: %+v", e)
+		}
+	}
+
+	// A search skips the proxies rated bad.
+	captureLog(t)
+	f, err := newHTTPFetcher(fetchFlags(t, "-proxy-file", annotated))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for class, id := range f.identities {
+		if n := id.proxies.size(); n > 2 {
+			t.Errorf("%s identity pools %d proxies, want the bad ones skipped", class, n)
+		}
+	}
+
+	// The text report is a table of the same ranking.
+	out, err = captureStdout(t, func() error {
+		return runProxyBenchCommand(context.Background(), []string{"-proxy-file", annotated, "-url", benchTarget, "-ip-info-url", ""})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 5 || !strings.HasPrefix(lines[0], "RANK") || !strings.Contains(lines[1], fast) || !strings.Contains(lines[1], qualityGood) {
+		t.Errorf("text report %q, want a header and the fast proxy first", out)
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// poolProxy is a proxy with its own request spacing, from the rpm= option of
+// its -proxy-file line.
+type poolProxy struct {
+	url      string
+	interval time.Duration // Least time between requests through the proxy; 0 is unlimited.
+	next     time.Time     // Earliest time of the next request.
+	rank     int           // proxyQualityRank of its quality label; lower is better.
+	region   string
+}
+
+// proxyPool hands out proxies so that each stays within its own rate limit.
+// The identity's limiter still paces every request; a proxy's limit can only
+// slow requests down further. It is safe for concurrent use; an empty pool
+// connects directly.
+type proxyPool struct {
+	mu      sync.Mutex
+	proxies []*poolProxy
+	rng     *rand.Rand // Breaks ties between equally good proxies.
+	last    string     // The proxy acquired last.
+	retired string     // A proxy the next acquire avoids; see rotate.
+}
+
+// newProxyPool builds a pool of entries, choosing among them with rng.
+func newProxyPool(entries []proxyEntry, rng *rand.Rand) *proxyPool {
+	p := &proxyPool{rng: rng}
+	for _, e := range entries {
+		pp := &poolProxy{url: e.url, rank: proxyQualityRank[e.quality], region: e.region}
+		if e.rpm > 0 {
+			pp.interval = time.Minute / time.Duration(e.rpm)
+		}
+		p.proxies = append(p.proxies, pp)
+	}
+	return p
+}
+
+// size returns the number of proxies in the pool.
+func (p *proxyPool) size() int {
+	if p == nil {
+		return 0
+	}
+	return len(p.proxies)
+}
+
+// region returns the region every proxy in the pool is labeled with, or ""
+// for an empty pool or one mixing regions.
+func (p *proxyPool) region() string {
+	if p.size() == 0 {
+		return ""
+	}
+	region := p.proxies[0].region
+	for _, pp := range p.proxies[1:] {
+		if pp.region != region {
+			return ""
+		}
+	}
+	return region
+}
+
+// regionOf returns the region label of the proxy with URL proxy.
+func (p *proxyPool) regionOf(proxy string) string {
+	if p == nil {
+		return ""
+	}
+	for _, pp := range p.proxies {
+		if pp.url == proxy {
+			return pp.region
+		}
+	}
+	return ""
+}
+
+// rotate makes the next acquire avoid the proxy acquired last, so that a new
+// session starts from another exit IP than the one before it.
+func (p *proxyPool) rotate() {
+	if p.size() == 0 {
+		return
+	}
+	p.mu.Lock()
+	p.retired = p.last
+	p.mu.Unlock()
+}
+
+// acquire picks the proxy free soonest, the best rated among those, at random
+// among equals, reserves its next slot, and waits for it. The proxy avoid, or
+// else the one retired by rotate, is only picked when it is the only one. It
+// returns "" for an empty pool.
+func (p *proxyPool) acquire(ctx context.Context, avoid string) (string, error) {
+	if p.size() == 0 {
+		return "", nil
+	}
+	p.mu.Lock()
+	if avoid == "" {
+		avoid = p.retired
+	}
+	p.retired = ""
+	now := time.Now()
+	var best []*poolProxy
+	var bestStart time.Time
+	for _, pp := range p.proxies {
+		if pp.url == avoid && len(p.proxies) > 1 {
+			continue
+		}
+		start := pp.next
+		if start.Before(now) {
+			start = now
+		}
+		switch {
+		case len(best) == 0 || start.Before(bestStart) || start.Equal(bestStart) && pp.rank < best[0].rank:
+			best, bestStart = []*poolProxy{pp}, start
+		case start.Equal(bestStart) && pp.rank == best[0].rank:
+			best = append(best, pp)
+		}
+	}
+	chosen := best[p.rng.Intn(len(best))]
+	chosen.next = bestStart.Add(chosen.interval)
+	p.last = chosen.url
+	p.mu.Unlock()
+
+	if err := sleepContext(ctx, bestStart.Sub(now)); err != nil {
+		return "", err
+	}
+	return chosen.url, nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadProxyFileRPM(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "proxies.txt")
+	if err := os.WriteFile(path, []byte("http://fast.example:8080\nsearch http://slow.example:8080 rpm=6\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := loadProxyFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].rpm != 0 || entries[1].rpm != 6 || entries[1].class != hostClassSearch || entries[1].url != "http://slow.example:8080" {
+		t.Errorf("entries %+v, want the slow proxy limited to 6 rpm", entries)
+	}
+
+	for _, bad := range []string{"http://p.example:8080 rpm=0\n", "http://p.example:8080 rpm=fast\n"} {
+		if err := os.WriteFile(path, []byte(bad), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := loadProxyFile(path); err == nil {
+			t.Errorf("proxy file %q accepted", bad)
+		}
+	}
+}
+
+func TestProxyPoolHonorsEachProxysRate(t *testing.T) {
+	const slow, fast = "http://slow.example:8080", "http://fast.example:8080"
+	pool := newProxyPool([]proxyEntry{{url: slow, rpm: 600}, {url: fast, rpm: 6000}}, rand.New(rand.NewSource(1)))
+
+	used := make(map[string]int)
+	start := time.Now()
+	for time.Since(start) < 500*time.Millisecond {
+		proxy, err := pool.acquire(context.Background(), "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		used[proxy]++
+	}
+	// 600 rpm is one request per 100ms, 6000 rpm one per 10ms.
+	if used[slow] > 6 || used[slow]*4 > used[fast] {
+		t.Errorf("slow proxy used %d times and fast %d in 500ms, want the slow one at most 6 times", used[slow], used[fast])
+	}
+}
+
+func TestProxyPoolAcquireCancelled(t *testing.T) {
+	pool := newProxyPool([]proxyEntry{{url: "http://slow.example:8080", rpm: 1}}, rand.New(rand.NewSource(1)))
+	if _, err := pool.acquire(context.Background(), ""); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := pool.acquire(ctx, ""); err == nil {
+		t.Error("acquire waited out a minute instead of returning when cancelled")
+	}
+
+	var empty *proxyPool
+	if proxy, err := empty.acquire(context.Background(), ""); proxy != "" || err != nil {
+		t.Errorf("an empty pool gave %q, %v; want a direct connection", proxy, err)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"strings"
+)
+
+// linkedInProfileSite is the site and path every search is restricted to.
+const linkedInProfileSite = "linkedin.com/in"
+
+// queryTerm is a word, several loose words, or an exact phrase.
+type queryTerm struct {
+	Text   string
+	Phrase bool
+}
+
+// queryPart is a required part of a search: one term, or alternatives of
+// which a result must match at least one.
+type queryPart []queryTerm
+
+// searchQuery is a search in no engine's syntax. Each engine renders it as
+// far as its operators allow and emulates or reports the rest.
+type searchQuery struct {
+	Site        string      // Restrict results to this site and path.
+	Parts       []queryPart // All required.
+	Verbatim    []string    // The user's own query syntax, passed through unchanged.
+	Exclude     []string    // Terms results must not mention.
+	URLContains string      // Restrict results to URLs containing this, e.g. "in/a".
+}
+
+// buildSearchQuery turns search criteria into an engine-neutral query.
+func buildSearchQuery(c SearchCriteria) searchQuery {
+	q := searchQuery{Site: linkedInProfileSite}
+	if keywords := keywordsPart(c.Keywords, c.LooseKeywords); keywords != nil {
+		q.Parts = append(q.Parts, keywords)
+	} else if k := phraseKeywords(c.Keywords, c.LooseKeywords); k != "" {
+		q.Verbatim = append(q.Verbatim, k)
+	}
+	for _, loose := range []string{c.Location, c.Industry, c.ExperienceRange} {
+		if loose = strings.Join(strings.Fields(loose), " "); loose != "" {
+			q.Parts = append(q.Parts, queryPart{{Text: loose}})
+		}
+	}
+	if company := strings.TrimSpace(c.CurrentCompany); company != "" {
+		// An exact company phrase favors results whose title names it, which
+		// is where LinkedIn puts the current employer. A title operator is
+		// not used, since it would hide past employees that are still worth
+		// marking.
+		q.Parts = append(q.Parts, queryPart{{Text: strings.ReplaceAll(company, `"`, ""), Phrase: true}})
+	}
+	q.Exclude = splitTermList(c.ExcludeKeywords)
+	switch d := c.Discriminator; {
+	case strings.HasPrefix(d, "inurl:"):
+		q.URLContains = strings.TrimPrefix(d, "inurl:")
+	case d != "":
+		q.Parts = append(q.Parts, queryPart{{Text: strings.Trim(d, `"`), Phrase: strings.HasPrefix(d, `"`)}})
+	}
+	return q
+}
+
+// splitTermList splits a comma-separated list of terms.
+func splitTermList(spec string) []string {
+	var terms []string
+	for _, t := range strings.Split(spec, ",") {
+		if t = strings.Join(strings.Fields(t), " "); t != "" {
+			terms = append(terms, t)
+		}
+	}
+	return terms
+}
+
+// excludeTerm returns the -exclude-keywords syntax of term: quoted when it
+// has several words.
+func excludeTerm(term string) string {
+	if strings.Contains(term, " ") {
+		return "-" + quoteTerm(term)
+	}
+	return "-" + term
+}
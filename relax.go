@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// relaxationWidenYears is how far each end of the experience range moves when
+// the range is widened.
+const relaxationWidenYears = 2
+
+// relaxationStep loosens search criteria. Steps are pure so each level of the
+// ladder can be recomputed from the original criteria.
+type relaxationStep struct {
+	name  string
+	apply func(c SearchCriteria) SearchCriteria
+}
+
+// relaxationSteps is the -min-results ladder; level n applies the first n
+// steps, cheapest loss of precision first.
+var relaxationSteps = []relaxationStep{
+	{"drop industry", dropIndustry},
+	{"widen experience", widenExperience},
+	{"expand location to metro area", expandLocation},
+	{"unquote keywords", unquoteKeywords},
+}
+
+// relaxCriteria returns the criteria relaxed to level.
+func relaxCriteria(c SearchCriteria, level int) SearchCriteria {
+	for i := 0; i < level && i < len(relaxationSteps); i++ {
+		c = relaxationSteps[i].apply(c)
+	}
+	return c
+}
+
+func dropIndustry(c SearchCriteria) SearchCriteria {
+	c.Industry = ""
+	return c
+}
+
+// widenExperience moves each end of the experience range out by
+// relaxationWidenYears, never below zero.
+func widenExperience(c SearchCriteria) SearchCriteria {
+	min, max, ok := parseExperienceRange(c.ExperienceRange)
+	if !ok {
+		return c
+	}
+	min -= relaxationWidenYears
+	if min < 0 {
+		min = 0
+	}
+	c.ExperienceRange = fmt.Sprintf("%d-%d years", min, max+relaxationWidenYears)
+	return c
+}
+
+// expandLocation replaces a known place with an OR group of its metro
+// area: its spellings and those of its satellite places, e.g.
+// "(Bangalore OR Bengaluru)".
+func expandLocation(c SearchCriteria) SearchCriteria {
+	p, ok := lookupPlace(c.Location)
+	if !ok {
+		return c
+	}
+	names := p.Names
+	for _, city := range p.Metro {
+		if m, ok := lookupPlace(city); ok {
+			names = append(names[:len(names):len(names)], m.Names...)
+		}
+	}
+	if len(names) < 2 {
+		return c // Nothing to add.
+	}
+	terms := make([]string, len(names))
+	for i, name := range names {
+		if strings.Contains(name, " ") {
+			name = quoteTerm(name)
+		}
+		terms[i] = name
+	}
+	c.Location = "(" + strings.Join(terms, " OR ") + ")"
+	return c
+}
+
+// unquoteKeywords turns quoted phrases, the user's own or those the query
+// builder would add, into bare keywords.
+func unquoteKeywords(c SearchCriteria) SearchCriteria {
+	bare := strings.Join(strings.Fields(strings.ReplaceAll(c.Keywords, `"`, " ")), " ")
+	if phraseKeywords(bare, true) == phraseKeywords(c.Keywords, c.LooseKeywords) {
+		return c // Already loose.
+	}
+	c.Keywords = bare
+	c.LooseKeywords = true
+	return c
+}
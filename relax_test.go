@@ -0,0 +1,97 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRelaxCriteriaLadder(t *testing.T) {
+	base := SearchCriteria{Keywords: "control valve", Location: "Delhi", Industry: "Machinery Manufacturing", ExperienceRange: "7-12 years"}
+	want := []SearchCriteria{
+		base,
+		{Keywords: "control valve", Location: "Delhi", ExperienceRange: "7-12 years"},
+		{Keywords: "control valve", Location: "Delhi", ExperienceRange: "5-14 years"},
+		{Keywords: "control valve", Location: `(Delhi OR "New Delhi" OR Gurgaon OR Gurugram OR Noida)`, ExperienceRange: "5-14 years"},
+		{Keywords: "control valve", LooseKeywords: true, Location: `(Delhi OR "New Delhi" OR Gurgaon OR Gurugram OR Noida)`, ExperienceRange: "5-14 years"},
+	}
+	if len(want) != len(relaxationSteps)+1 {
+		t.Fatalf("ladder has %d steps, the test %d", len(relaxationSteps), len(want)-1)
+	}
+	for level, w := range want {
+		if got := relaxCriteria(base, level); got != w {
+			t.Errorf("level %d = %+v, want %+v", level, got, w)
+		}
+	}
+	// Levels beyond the ladder stay at its top.
+	if got := relaxCriteria(base, len(relaxationSteps)+3); got != want[len(want)-1] {
+		t.Errorf("level past the ladder = %+v", got)
+	}
+}
+
+func TestRelaxationSteps(t *testing.T) {
+	if got := widenExperience(SearchCriteria{ExperienceRange: "1-3 years"}).ExperienceRange; got != "0-5 years" {
+		t.Errorf("widened 1-3 years = %q, want 0-5 years", got)
+	}
+	if c := widenExperience(SearchCriteria{}); c.ExperienceRange != "" {
+		t.Errorf("widened no range = %q", c.ExperienceRange)
+	}
+	for location, want := range map[string]string{
+		"Bangalore": "(Bangalore OR Bengaluru)",
+		"mumbai":    `(Mumbai OR Bombay OR "Navi Mumbai" OR Thane)`,
+		"Gurgaon":   "(Gurgaon OR Gurugram OR Delhi OR \"New Delhi\")",
+		"Paris":     "Paris",    // No other names.
+		"Timbuktu":  "Timbuktu", // Unknown.
+		"":          "",
+	} {
+		if got := expandLocation(SearchCriteria{Location: location}).Location; got != want {
+			t.Errorf("expanded %q = %q, want %q", location, got, want)
+		}
+	}
+	if c := unquoteKeywords(SearchCriteria{Keywords: "valve"}); c.LooseKeywords {
+		t.Error("unquoting a single word changed the criteria")
+	}
+}
+
+// relaxedSearches runs a search against a roster of three profiles with
+// args and returns the queries of the search requests it made.
+func relaxedSearches(t *testing.T, args ...string) []string {
+	t.Helper()
+	_, addr := startFakeWeb(t, fakeRoster(3))
+	args = append([]string{"-keywords", "valve", "-location", "Bangalore", "-max-pages", "1"}, args...)
+	if _, err := runFakeSearch(t, addr, args...); err != nil {
+		t.Fatal(err)
+	}
+	var queries []string
+	for _, o := range stats.Outcomes {
+		if strings.Contains(o.URL, "/search?") {
+			queries = append(queries, o.URL)
+		}
+	}
+	return queries
+}
+
+func TestRelaxationStops(t *testing.T) {
+	// Enough results at level 0: no relaxation.
+	if q := relaxedSearches(t, "-min-results", "3"); len(q) != 1 {
+		t.Errorf("-min-results met: %d searches, want 1", len(q))
+	}
+	// Too few at every level: each level that changes the query is
+	// searched once; unquoting the single keyword changes nothing.
+	q := relaxedSearches(t, "-min-results", "5")
+	if len(q) != 4 {
+		t.Fatalf("-min-results never met: %d searches, want 4: %v", len(q), q)
+	}
+	if strings.Contains(q[1], "Machinery") || !strings.Contains(q[0], "Machinery") {
+		t.Errorf("level 1 did not drop the industry: %s", q[1])
+	}
+	if !strings.Contains(q[2], "5-14") {
+		t.Errorf("level 2 did not widen experience: %s", q[2])
+	}
+	if !strings.Contains(q[3], "Bengaluru") {
+		t.Errorf("level 3 did not expand the location: %s", q[3])
+	}
+	// -max-relaxation caps the ladder.
+	if q := relaxedSearches(t, "-min-results", "5", "-max-relaxation", "1"); len(q) != 2 {
+		t.Errorf("-max-relaxation 1: %d searches, want 2", len(q))
+	}
+}
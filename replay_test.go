@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata with the current output")
+
+// checkGolden compares got with the golden file testdata/golden/name, or
+// rewrites the file with -update.
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", "golden", name)
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s differs from %s; rerun with -update if the change is intended:\n%s", name, path, got)
+	}
+}
+
+// deterministicOutputs are the files a -deterministic run over
+// testdata/replay writes.
+var deterministicOutputs = []string{"candidates.csv", "store.json", "report.html"}
+
+// runDeterministic runs the whole pipeline over the fixtures in
+// testdata/replay, from a fresh working directory so that the run's
+// arguments are the same every time, and returns its outputs by name.
+func runDeterministic(t *testing.T) map[string][]byte {
+	t.Helper()
+	fixtures, err := filepath.Abs(filepath.Join("testdata", "replay"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats = newRunStats()
+	t.Cleanup(func() { fixedNow = time.Time{} })
+	outputs := make(map[string][]byte)
+	t.Run("run", func(t *testing.T) {
+		t.Chdir(t.TempDir())
+		err := runSearchCommand(context.Background(), []string{
+			"-replay", fixtures, "-deterministic", "-seed", "7", "-max-pages", "2", "-columns", columnKeys(),
+			"-output", "candidates.csv", "-store", "store.json", "-html-report", "report.html",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range deterministicOutputs {
+			if outputs[name], err = os.ReadFile(name); err != nil {
+				t.Fatal(err)
+			}
+		}
+	})
+	return outputs
+}
+
+func TestDeterministicReplayIsByteIdentical(t *testing.T) {
+	first, second := runDeterministic(t), runDeterministic(t)
+	for _, name := range deterministicOutputs {
+		if !bytes.Equal(first[name], second[name]) {
+			t.Errorf("%s differs between two runs:\n%s\n---\n%s", name, first[name], second[name])
+		}
+	}
+	// The arguments name the fixtures by their absolute path, which is the
+	// same from run to run but not from checkout to checkout.
+	csv := regexp.MustCompile(`(?m)^# args: .*\n`).ReplaceAll(first["candidates.csv"], nil)
+	checkGolden(t, "deterministic.csv", csv)
+}
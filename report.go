@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// reportTemplate renders the -html-report page. html/template escapes every
+// field for its context, including the profile and mailto links.
+var reportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Profile search report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; }
+th { background: #f0f0f0; }
+dt { font-weight: bold; float: left; clear: left; width: 8em; }
+dd { margin-left: 9em; }
+</style>
+</head>
+<body>
+<h1>Profile search report</h1>
+<dl>
+{{- if .Job}}
+<dt>Job</dt><dd>{{.Job}}</dd>
+{{- end}}
+<dt>Keywords</dt><dd>{{.Criteria.Keywords}}</dd>
+<dt>Location</dt><dd>{{.Criteria.Location}}</dd>
+<dt>Industry</dt><dd>{{.Criteria.Industry}}</dd>
+<dt>Experience</dt><dd>{{.Criteria.ExperienceRange}}</dd>
+<dt>Candidates</dt><dd>{{len .Candidates}}</dd>
+<dt>With email</dt><dd>{{.WithEmail}}</dd>
+<dt>With phone</dt><dd>{{.WithPhone}}</dd>
+<dt>Generated</dt><dd>{{.Generated.Format "2006-01-02 15:04:05 MST"}}</dd>
+</dl>
+{{- if .Cities}}
+<h2>By city</h2>
+<table>
+<tr><th>City</th><th>Candidates</th></tr>
+{{- range .Cities}}
+<tr><td>{{.City}}</td><td>{{.Count}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+<h2>Candidates</h2>
+<table>
+<tr><th>Rank</th><th>Name</th><th>Email</th><th>Phone</th><th>City</th><th>Experience</th><th>Score</th></tr>
+{{- range .Candidates}}
+<tr>
+<td>{{.Rank}}</td>
+<td><a href="{{.ProfileURL}}">{{if .Name}}{{.Name}}{{else}}{{.ProfileURL}}{{end}}</a></td>
+<td>{{if .Email}}<a href="mailto:{{.Email}}">{{.Email}}</a>{{end}}</td>
+<td>{{.Phone}}</td>
+<td>{{.City}}</td>
+<td>{{if .ExperienceYears}}{{.ExperienceYears}} years{{end}}</td>
+<td>{{.Score}}</td>
+</tr>
+{{- end}}
+</table>
+{{- if .RunFields}}
+<footer id="run-info">
+<h2>Run info</h2>
+<dl>
+{{- range .RunFields}}
+<dt>{{index . 0}}</dt><dd data-key="{{index . 0}}">{{index . 1}}</dd>
+{{- end}}
+</dl>
+</footer>
+{{- end}}
+</body>
+</html>
+`))
+
+// reportData is the input of reportTemplate.
+type reportData struct {
+	Job        string
+	Criteria   SearchCriteria
+	Candidates []Candidate
+	WithEmail  int
+	WithPhone  int
+	Cities     []cityCount
+	Generated  time.Time
+	RunFields  [][2]string
+}
+
+// cityCount is one row of the report's by-city summary.
+type cityCount struct {
+	City  string
+	Count int
+}
+
+// countByCity groups candidates by normalized city, most common first.
+// Candidates without a known city are counted as "Unknown".
+func countByCity(candidates []Candidate) []cityCount {
+	counts := make(map[string]int)
+	for _, c := range candidates {
+		city := c.City
+		if city == "" {
+			city = "Unknown"
+		}
+		counts[city]++
+	}
+	cities := make([]cityCount, 0, len(counts))
+	for city, n := range counts {
+		cities = append(cities, cityCount{city, n})
+	}
+	sort.Slice(cities, func(i, j int) bool {
+		if cities[i].Count != cities[j].Count {
+			return cities[i].Count > cities[j].Count
+		}
+		return cities[i].City < cities[j].City
+	})
+	return cities
+}
+
+// writeHTMLReport writes an HTML summary of a run for sharing with people who
+// will not open a CSV file. The run info block, when info is not nil, goes in
+// the footer.
+func writeHTMLReport(candidates []Candidate, filename string, criteria SearchCriteria, job string, info *runInfo) error {
+	data := reportData{Job: job, Criteria: criteria, Candidates: candidates, Cities: countByCity(candidates), Generated: outputTime(clockNow())}
+	if info != nil {
+		data.RunFields = info.fields()
+	}
+	for _, c := range candidates {
+		if c.Email != "" {
+			data.WithEmail++
+		}
+		if c.Phone != "" {
+			data.WithPhone++
+		}
+	}
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create HTML report: %w", err)
+	}
+	defer file.Close()
+
+	if err := reportTemplate.Execute(file, data); err != nil {
+		return fmt.Errorf("failed to write HTML report: %w", err)
+	}
+	return nil
+}
+
+// readHTMLRunInfo parses the run info block from the footer of an HTML
+// report.
+func readHTMLRunInfo(filename string) (*runInfo, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", filename, err)
+	}
+	defer file.Close()
+
+	doc, err := goquery.NewDocumentFromReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", filename, err)
+	}
+	fields := make(map[string]string)
+	doc.Find("#run-info dd[data-key]").Each(func(_ int, dd *goquery.Selection) {
+		fields[dd.AttrOr("data-key", "")] = dd.Text()
+	})
+	return runInfoFromFields(filename, fields)
+}
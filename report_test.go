@@ -0,0 +1,81 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestHTMLReportLinksAndEscapes(t *testing.T) {
+	fixedNow = time.Date(2026, 3, 1, 9, 30, 0, 0, time.UTC)
+	t.Cleanup(func() { fixedNow = time.Time{} })
+	candidates := []Candidate{
+		{Rank: 1, Name: "Jane Doe", ProfileURL: "https://www.linkedin.com/in/jane-doe", Email: "jane@example.com", City: "Pune", Score: 25},
+		{Rank: 2, Name: `<script>alert("x")</script>`, ProfileURL: "javascript:alert(1)", Phone: "+91 98765 43210", City: "Pune"},
+		{Rank: 3, ProfileURL: "https://www.linkedin.com/in/john-roe"},
+	}
+	filename := filepath.Join(t.TempDir(), "report.html")
+	criteria := SearchCriteria{Keywords: "valve engineer", Location: "Pune"}
+	if err := writeHTMLReport(candidates, filename, criteria, "valves", nil); err != nil {
+		t.Fatal(err)
+	}
+	page, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(page), "<script>") {
+		t.Errorf("report holds an unescaped name:\n%s", page)
+	}
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(string(page)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var links []string
+	doc.Find("td a").Each(func(i int, s *goquery.Selection) {
+		href, _ := s.Attr("href")
+		links = append(links, href+" "+s.Text())
+	})
+	want := []string{
+		"https://www.linkedin.com/in/jane-doe Jane Doe",
+		"mailto:jane@example.com jane@example.com",
+		`#ZgotmplZ <script>alert("x")</script>`,
+		"https://www.linkedin.com/in/john-roe https://www.linkedin.com/in/john-roe",
+	}
+	if !reflect.DeepEqual(links, want) {
+		t.Errorf("links %q, want %q", links, want)
+	}
+
+	summary := make(map[string]string)
+	doc.Find("dl").First().Find("dt").Each(func(i int, s *goquery.Selection) {
+		summary[s.Text()] = s.Next().Text()
+	})
+	for key, value := range map[string]string{
+		"Job":        "valves",
+		"Keywords":   "valve engineer",
+		"Candidates": "3",
+		"With email": "1",
+		"With phone": "1",
+		"Generated":  "2026-03-01 09:30:00 UTC",
+	} {
+		if summary[key] != value {
+			t.Errorf("summary %s = %q, want %q", key, summary[key], value)
+		}
+	}
+	if doc.Find("footer#run-info").Length() != 0 {
+		t.Error("report without run info has a run info footer")
+	}
+}
+
+func TestCountByCity(t *testing.T) {
+	got := countByCity([]Candidate{{City: "Pune"}, {City: "Mumbai"}, {}, {City: "Pune"}, {City: "Delhi"}})
+	want := []cityCount{{"Pune", 2}, {"Delhi", 1}, {"Mumbai", 1}, {"Unknown", 1}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("countByCity = %v, want %v", got, want)
+	}
+}
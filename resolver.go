@@ -0,0 +1,237 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// defaultFieldPriorities rank the sources of each sourced field, most
+// trusted first. A source missing from a field's list ranks after every
+// listed one, and a value whose source was never recorded, such as one
+// stored by an older version, ranks with those.
+var defaultFieldPriorities = map[string][]string{
+	"name":              {sourceProfileMarkup, sourceResultName},
+	"email":             {sourceContactInfo, sourceAboutText, sourceAboutObfuscated, sourcePageRegex, sourceSnippet},
+	"phone":             {sourceContactInfo, sourceAboutText, sourcePageRegex, sourceSnippet},
+	"experience":        {sourcePositionDates, sourceSnippet},
+	"title":             {sourceExperience},
+	"company":           {sourceExperience, sourceResultTitle},
+	"location":          {sourceProfileMarkup, sourceSnippet},
+	"summary":           {sourceJSONLD, sourceOGDescription, sourceSummarySection},
+	"website":           {sourceContactInfo},
+	"twitter":           {sourceContactInfo},
+	"company_size_band": {sourceExperience, sourceSnippet},
+	"company_type":      {sourceExperience, sourceSnippet},
+}
+
+// knownSources are the sources a -field-priority list may name.
+var knownSources = []string{
+	sourceResultName, sourceResultTitle, sourceSnippet, sourceProfileMarkup, sourceContactInfo, sourceJSONLD,
+	sourceOGDescription, sourceSummarySection, sourceAboutText, sourceAboutObfuscated, sourcePageRegex, sourceExperience,
+	sourcePositionDates,
+}
+
+// fieldPriorityFlag is the -field-priority value: per field, its sources
+// most trusted first, as "email=contact_info,about_text;name=result_name".
+// Fields it does not name keep their default order.
+type fieldPriorityFlag map[string][]string
+
+// fieldPriorities is the resolution policy every merge of candidate fields
+// follows.
+var fieldPriorities = func() fieldPriorityFlag {
+	p := make(fieldPriorityFlag, len(defaultFieldPriorities))
+	for field, sources := range defaultFieldPriorities {
+		p[field] = sources
+	}
+	return p
+}()
+
+func (p *fieldPriorityFlag) String() string {
+	if p == nil {
+		return ""
+	}
+	fields := make([]string, 0, len(*p))
+	for field := range *p {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	parts := make([]string, len(fields))
+	for i, field := range fields {
+		parts[i] = field + "=" + strings.Join((*p)[field], ",")
+	}
+	return strings.Join(parts, ";")
+}
+
+func (p *fieldPriorityFlag) Set(s string) error {
+	for _, part := range strings.Split(s, ";") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+		field, list, ok := strings.Cut(part, "=")
+		field = strings.TrimSpace(field)
+		if !ok {
+			return fmt.Errorf("want field=source,source, not %q", part)
+		}
+		if !isSourcedField(field) {
+			return fmt.Errorf("%q is not a field with more than one source", field)
+		}
+		var sources []string
+		for _, source := range strings.Split(list, ",") {
+			source = strings.TrimSpace(source)
+			if !slices.Contains(knownSources, source) {
+				return fmt.Errorf("field %s: unknown source %q", field, source)
+			}
+			sources = append(sources, source)
+		}
+		(*p)[field] = sources
+	}
+	return nil
+}
+
+// isSourcedField reports whether field is one of sourcedFields.
+func isSourcedField(field string) bool {
+	for _, f := range sourcedFields {
+		if f.name == field {
+			return true
+		}
+	}
+	return false
+}
+
+// sourceRank returns the position of source in field's priority list.
+func sourceRank(field, source string) int {
+	sources := fieldPriorities[field]
+	if i := slices.Index(sources, source); i >= 0 {
+		return i
+	}
+	return len(sources)
+}
+
+// fieldClaim is a value of a field, with where and when it was found.
+type fieldClaim struct {
+	value  string
+	source string
+	at     time.Time
+}
+
+// resolveField decides between the current value of field and an incoming
+// one. An empty value never wins. Otherwise the value from the source ranked
+// higher for the field wins; between equally ranked sources the more recent
+// value wins, and on a full tie the current one stays. conflict reports that
+// both were populated and differed.
+func resolveField(field string, current, incoming fieldClaim) (winner fieldClaim, conflict bool) {
+	switch {
+	case incoming.value == "" || incoming.value == current.value:
+		return current, false
+	case current.value == "":
+		return incoming, false
+	}
+	cr, ir := sourceRank(field, current.source), sourceRank(field, incoming.source)
+	switch {
+	case ir < cr:
+		return incoming, true
+	case ir == cr && incoming.at.After(current.at):
+		return incoming, true
+	}
+	return current, true
+}
+
+// mergeFields resolves the sourced fields of from, found at fromAt, into
+// into, found at intoAt, keeping the sources of the values that win. With
+// fields given, only those are merged. site names the merge for the log.
+func mergeFields(site string, into *Candidate, intoAt time.Time, from Candidate, fromAt time.Time, fields ...string) {
+	for _, f := range sourcedFields {
+		if len(fields) > 0 && !slices.Contains(fields, f.name) {
+			continue
+		}
+		current := fieldClaim{value: f.value(*into), source: into.Sources[f.name], at: intoAt}
+		incoming := fieldClaim{value: f.value(from), source: from.Sources[f.name], at: fromAt}
+		winner, conflict := resolveField(f.name, current, incoming)
+		if conflict {
+			loser := current
+			if winner == current {
+				loser = incoming
+			}
+			verbosef("%s merge of %s: %s %q (%s) wins over %q (%s)", site, into.ProfileURL, f.name,
+				winner.value, firstNonEmpty(winner.source, "unknown"), loser.value, firstNonEmpty(loser.source, "unknown"))
+			stats.countFieldConflict(f.name)
+		}
+		if winner != current {
+			f.set(into, winner.value)
+			delete(into.Sources, f.name)
+			into.noteSource(f.name, winner.value, winner.source)
+		}
+	}
+}
+
+// Strategies of -merge-strategy, deciding the fields of a profile found twice.
+const (
+	mergePriority     = "priority"      // Per field, the value from the source -field-priority ranks higher.
+	mergeFirst        = "first"         // The record found first.
+	mergeLast         = "last"          // The record found last.
+	mergeMostComplete = "most-complete" // The record with more fields filled.
+)
+
+// mergeStrategyFlag is the -merge-strategy value.
+type mergeStrategyFlag string
+
+// duplicateMergeStrategy is how de-duplication merges the records of one
+// profile.
+var duplicateMergeStrategy = mergeStrategyFlag(mergePriority)
+
+func (s *mergeStrategyFlag) String() string {
+	return string(*s)
+}
+
+func (s *mergeStrategyFlag) Set(v string) error {
+	switch v = strings.TrimSpace(v); v {
+	case mergePriority, mergeFirst, mergeLast, mergeMostComplete:
+		*s = mergeStrategyFlag(v)
+		return nil
+	}
+	return fmt.Errorf("unknown strategy %q (want %s, %s, %s, or %s)", v, mergePriority, mergeFirst, mergeLast, mergeMostComplete)
+}
+
+// mergeDuplicate merges from, a later record of into's profile, into into
+// with the -merge-strategy. Under every strategy but priority, the winning
+// record's populated fields win, and the other record only fills its empty
+// ones.
+func mergeDuplicate(into *Candidate, from Candidate) {
+	now := clockNow()
+	if duplicateMergeStrategy == mergePriority {
+		mergeFields("duplicate", into, now, from, now)
+		return
+	}
+	incomingWins := duplicateMergeStrategy == mergeLast ||
+		duplicateMergeStrategy == mergeMostComplete && filledFields(from) > filledFields(*into)
+	for _, f := range sourcedFields {
+		current, incoming := f.value(*into), f.value(from)
+		if incoming == "" || incoming == current {
+			continue
+		}
+		if current != "" {
+			stats.countFieldConflict(f.name)
+			if !incomingWins {
+				continue
+			}
+			verbosef("duplicate merge of %s: %s %q wins over %q (%s)", into.ProfileURL, f.name, incoming, current, duplicateMergeStrategy)
+		}
+		f.set(into, incoming)
+		delete(into.Sources, f.name)
+		into.noteSource(f.name, incoming, from.Sources[f.name])
+	}
+}
+
+// filledFields counts the sourced fields of c that have a value.
+func filledFields(c Candidate) int {
+	n := 0
+	for _, f := range sourcedFields {
+		if f.value(c) != "" {
+			n++
+		}
+	}
+	return n
+}
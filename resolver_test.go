@@ -0,0 +1,294 @@
+package main
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// resetFieldPriorities restores the default resolution policy once the test
+// is done, since -field-priority changes it for the process.
+func resetFieldPriorities(t *testing.T) {
+	t.Helper()
+	t.Cleanup(func() {
+		fieldPriorities = make(fieldPriorityFlag, len(defaultFieldPriorities))
+		for field, sources := range defaultFieldPriorities {
+			fieldPriorities[field] = sources
+		}
+	})
+}
+
+func TestResolveField(t *testing.T) {
+	earlier := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	later := earlier.Add(time.Hour)
+	claim := func(value, source string, at time.Time) fieldClaim {
+		return fieldClaim{value: value, source: source, at: at}
+	}
+	tests := []struct {
+		name              string
+		field             string
+		current, incoming fieldClaim
+		wantIncoming      bool
+		wantConflict      bool
+	}{
+		{"empty incoming", "email", claim("a@x.com", sourceSnippet, earlier), claim("", sourceContactInfo, later), false, false},
+		{"empty current", "email", claim("", sourceContactInfo, later), claim("a@x.com", sourceSnippet, earlier), true, false},
+		{"same value", "email", claim("a@x.com", sourceSnippet, earlier), claim("a@x.com", sourceContactInfo, later), false, false},
+		{"higher source wins", "email", claim("a@x.com", sourceSnippet, later), claim("b@x.com", sourceContactInfo, earlier), true, true},
+		{"lower source loses", "email", claim("a@x.com", sourceContactInfo, earlier), claim("b@x.com", sourcePageRegex, later), false, true},
+		{"same source, newer wins", "phone", claim("+91 1", sourceAboutText, earlier), claim("+91 2", sourceAboutText, later), true, true},
+		{"same source, older loses", "phone", claim("+91 1", sourceAboutText, later), claim("+91 2", sourceAboutText, earlier), false, true},
+		{"full tie keeps current", "phone", claim("+91 1", sourceAboutText, earlier), claim("+91 2", sourceAboutText, earlier), false, true},
+		{"unlisted source ranks last", "name", claim("Jane D.", sourceJSONLD, later), claim("Jane Doe", sourceResultName, earlier), true, true},
+		{"unknown source ranks last", "name", claim("Jane D.", "", later), claim("Jane Doe", sourceResultName, earlier), true, true},
+		{"unknown sources, newer wins", "name", claim("Jane D.", "", earlier), claim("Jane Doe", "", later), true, true},
+		{"title from experience", "title", claim("Engineer", sourceExperience, earlier), claim("Lead", sourceSnippet, later), false, true},
+		{"summary JSON-LD over og", "summary", claim("About me", sourceOGDescription, later), claim("About Jane", sourceJSONLD, earlier), true, true},
+		{"experience positions over snippet", "experience", claim("12", sourceSnippet, later), claim("11.5", sourcePositionDates, earlier), true, true},
+	}
+	for _, tt := range tests {
+		winner, conflict := resolveField(tt.field, tt.current, tt.incoming)
+		want := tt.current
+		if tt.wantIncoming {
+			want = tt.incoming
+		}
+		if winner != want || conflict != tt.wantConflict {
+			t.Errorf("%s: winner %+v, conflict %v; want %+v, %v", tt.name, winner, conflict, want, tt.wantConflict)
+		}
+	}
+}
+
+func TestFieldPriorityFlag(t *testing.T) {
+	resetFieldPriorities(t)
+	if _, err := parseFlags([]string{"-field-priority", "email=snippet,contact_info; name=result_name"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := fieldPriorities["email"]; !slices.Equal(got, []string{sourceSnippet, sourceContactInfo}) {
+		t.Errorf("email priority %q", got)
+	}
+	if got := fieldPriorities["phone"]; !slices.Equal(got, defaultFieldPriorities["phone"]) {
+		t.Errorf("phone priority %q changed, want the default", got)
+	}
+	// Under the new policy the snippet's email wins.
+	winner, _ := resolveField("email", fieldClaim{value: "a@x.com", source: sourceContactInfo}, fieldClaim{value: "b@x.com", source: sourceSnippet})
+	if winner.value != "b@x.com" {
+		t.Errorf("email %s won, want the snippet's", winner.value)
+	}
+	if s := fieldPriorities.String(); !strings.Contains(s, "email=snippet,contact_info;") || !strings.Contains(s, "name=result_name;") {
+		t.Errorf("policy printed as %q", s)
+	}
+
+	for _, bad := range []string{"email", "headline=snippet", "email=snippet,archive"} {
+		p := make(fieldPriorityFlag)
+		if err := p.Set(bad); err == nil {
+			t.Errorf("-field-priority %q accepted", bad)
+		}
+	}
+}
+
+func TestFieldPriorityFromConfigFile(t *testing.T) {
+	resetFieldPriorities(t)
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	config := "field-priority:\n  email: [about_text, contact_info]\n  company: result_title\n"
+	if err := os.WriteFile(path, []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parseFlags([]string{"-config", path}); err != nil {
+		t.Fatal(err)
+	}
+	if got := fieldPriorities["email"]; !slices.Equal(got, []string{sourceAboutText, sourceContactInfo}) {
+		t.Errorf("email priority %q from the config file", got)
+	}
+	if got := fieldPriorities["company"]; !slices.Equal(got, []string{sourceResultTitle}) {
+		t.Errorf("company priority %q from the config file", got)
+	}
+}
+
+func TestMergeFieldsKeepsWinningSources(t *testing.T) {
+	stats = newRunStats()
+	now := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	into := Candidate{ProfileURL: "https://www.linkedin.com/in/jane-doe", Name: "Jane D.", Email: "jane@example.com", Phone: "+91 98765 43210"}
+	into.noteSource("name", into.Name, sourceResultName)
+	into.noteSource("email", into.Email, sourceContactInfo)
+	into.noteSource("phone", into.Phone, sourceSnippet)
+	from := Candidate{Name: "Jane Doe", Email: "j.doe@example.com", Phone: "+91 91234 56789", Title: "Valve Engineer"}
+	from.noteSource("name", from.Name, sourceProfileMarkup)
+	from.noteSource("email", from.Email, sourcePageRegex)
+	from.noteSource("phone", from.Phone, sourceAboutText)
+	from.noteSource("title", from.Title, sourceExperience)
+
+	mergeFields("profile", &into, now, from, now)
+	if into.Name != "Jane Doe" || into.Email != "jane@example.com" || into.Phone != "+91 91234 56789" || into.Title != "Valve Engineer" {
+		t.Errorf("merged %+v", into)
+	}
+	want := map[string]string{"name": sourceProfileMarkup, "email": sourceContactInfo, "phone": sourceAboutText, "title": sourceExperience}
+	for field, source := range want {
+		if into.Sources[field] != source {
+			t.Errorf("%s from %q, want %s", field, into.Sources[field], source)
+		}
+	}
+	// Name, email, and phone conflicted; the title only filled a gap.
+	for field, n := range map[string]int{"name": 1, "email": 1, "phone": 1, "title": 0} {
+		if got := stats.FieldConflicts[field]; got != n {
+			t.Errorf("%d %s conflicts counted, want %d", got, field, n)
+		}
+	}
+
+	// Limited to some fields, the others are left alone.
+	other := Candidate{Name: "J. Doe", Email: "jd@example.com"}
+	other.noteSource("name", other.Name, sourceProfileMarkup)
+	other.noteSource("email", other.Email, sourceContactInfo)
+	mergeFields("verify", &into, now, other, now.Add(time.Hour), "email")
+	if into.Name != "Jane Doe" || into.Email != "jd@example.com" {
+		t.Errorf("email-only merge gave %+v", into)
+	}
+}
+
+func TestStoreUpsertResolvesBySource(t *testing.T) {
+	stats = newRunStats()
+	store, err := openStore(filepath.Join(t.TempDir(), "candidates.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	url := "https://www.linkedin.com/in/jane-doe"
+	profiled := Candidate{ProfileURL: url, Name: "Jane Doe", Email: "jane@example.com"}
+	profiled.noteSource("name", profiled.Name, sourceProfileMarkup)
+	profiled.noteSource("email", profiled.Email, sourceContactInfo)
+	store.upsert([]Candidate{profiled}, first)
+
+	// A later run only has the result's data: its values rank lower, or fill
+	// nothing, so the stored ones stay.
+	later := Candidate{ProfileURL: url, Name: "Jane D.", Email: "jd@gmail.com", Location: "Pune"}
+	later.noteSource("name", later.Name, sourceResultName)
+	later.noteSource("email", later.Email, sourceSnippet)
+	later.noteSource("location", later.Location, sourceSnippet)
+	store.upsert([]Candidate{later}, first.Add(24*time.Hour))
+
+	c := store.candidates()[0]
+	if c.Name != "Jane Doe" || c.Email != "jane@example.com" || c.Location != "Pune" {
+		t.Errorf("stored %+v, want the profile's name and email and the new location", c.Candidate)
+	}
+	if c.FieldSources["email"] != sourceContactInfo || c.FieldSources["location"] != sourceSnippet {
+		t.Errorf("stored sources %v", c.FieldSources)
+	}
+}
+
+// setMergeStrategy sets -merge-strategy to s for the test.
+func setMergeStrategy(t *testing.T, s string) {
+	t.Helper()
+	t.Cleanup(func() { duplicateMergeStrategy = mergePriority })
+	if err := duplicateMergeStrategy.Set(s); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMergeDuplicateStrategies(t *testing.T) {
+	url := "https://www.linkedin.com/in/jane-doe"
+	// The earlier record has the better email, the later one more fields.
+	earlier := Candidate{ProfileURL: url, Name: "Jane D.", Email: "jane@example.com", Title: "Valve Engineer"}
+	earlier.noteSource("name", earlier.Name, sourceResultName)
+	earlier.noteSource("email", earlier.Email, sourceContactInfo)
+	earlier.noteSource("title", earlier.Title, sourceExperience)
+	later := Candidate{ProfileURL: url, Name: "Jane Doe", Email: "jd@gmail.com", Phone: "+91 98765 43210", Company: "Emerson", Location: "Pune"}
+	later.noteSource("name", later.Name, sourceProfileMarkup)
+	later.noteSource("email", later.Email, sourceSnippet)
+	later.noteSource("phone", later.Phone, sourceContactInfo)
+	later.noteSource("company", later.Company, sourceExperience)
+	later.noteSource("location", later.Location, sourceProfileMarkup)
+
+	tests := []struct {
+		strategy    string
+		into, from  Candidate
+		name, email string
+		emailSource string
+	}{
+		// Each field by its sources: the profile's name, the contact info's email.
+		{mergePriority, earlier, later, "Jane Doe", "jane@example.com", sourceContactInfo},
+		{mergeFirst, earlier, later, "Jane D.", "jane@example.com", sourceContactInfo},
+		{mergeLast, earlier, later, "Jane Doe", "jd@gmail.com", sourceSnippet},
+		// The later record fills five fields to the earlier one's three.
+		{mergeMostComplete, earlier, later, "Jane Doe", "jd@gmail.com", sourceSnippet},
+		{mergeMostComplete, later, earlier, "Jane Doe", "jd@gmail.com", sourceSnippet},
+		{mergeLast, later, earlier, "Jane D.", "jane@example.com", sourceContactInfo},
+	}
+	for _, tt := range tests {
+		t.Run(tt.strategy, func(t *testing.T) {
+			setMergeStrategy(t, tt.strategy)
+			stats = newRunStats()
+			into := tt.into
+			into.Sources = maps.Clone(tt.into.Sources)
+			mergeDuplicate(&into, tt.from)
+			if into.Name != tt.name || into.Email != tt.email {
+				t.Errorf("merged to %q <%s>, want %q <%s>", into.Name, into.Email, tt.name, tt.email)
+			}
+			if into.Sources["email"] != tt.emailSource {
+				t.Errorf("email from %q, want %s", into.Sources["email"], tt.emailSource)
+			}
+			// Whichever record wins, the other fills its gaps.
+			if into.Title != "Valve Engineer" || into.Phone != later.Phone || into.Company != "Emerson" || into.Location != "Pune" {
+				t.Errorf("merged %+v, want the gaps filled", into)
+			}
+			if into.Sources["title"] != sourceExperience || into.Sources["phone"] != sourceContactInfo {
+				t.Errorf("sources %v, want those of the filling values", into.Sources)
+			}
+			for field, n := range map[string]int{"name": 1, "email": 1, "title": 0, "phone": 0} {
+				if got := stats.FieldConflicts[field]; got != n {
+					t.Errorf("%d %s conflicts counted, want %d", got, field, n)
+				}
+			}
+		})
+	}
+
+	var s mergeStrategyFlag
+	if err := s.Set("newest"); err == nil {
+		t.Error("-merge-strategy newest accepted")
+	}
+}
+
+func TestCleanExistingMergeStrategy(t *testing.T) {
+	captureLog(t)
+	dir := t.TempDir()
+	input := filepath.Join(dir, "candidates.csv")
+	rows := "Name,Email,Phone,Profile URL,Title\n" +
+		"Jane D.,jane@example.com,,https://www.linkedin.com/in/jane-doe,\n" +
+		"John Roe,john@example.com,,https://www.linkedin.com/in/john-roe,Designer\n" +
+		"Jane Doe,jd@gmail.com,,https://in.linkedin.com/in/jane-doe/,Valve Engineer\n"
+	if err := os.WriteFile(input, []byte(rows), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		strategy    string
+		name, email string
+	}{
+		// CSV rows carry no sources, so priority keeps the first row's values.
+		{mergePriority, "Jane D.", "jane@example.com"},
+		{mergeFirst, "Jane D.", "jane@example.com"},
+		{mergeLast, "Jane Doe", "jd@gmail.com"},
+		{mergeMostComplete, "Jane Doe", "jd@gmail.com"},
+	} {
+		t.Run(tt.strategy, func(t *testing.T) {
+			t.Cleanup(func() { duplicateMergeStrategy = mergePriority })
+			output := filepath.Join(dir, tt.strategy+".csv")
+			cfg, err := parseFlags([]string{"-clean-existing", input, "-output", output, "-merge-strategy", tt.strategy, "-columns", "name,email,profile_url,title"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := captureStdout(t, func() error { return runCleanExisting(cfg) }); err != nil {
+				t.Fatal(err)
+			}
+			got := readFakeSearch(t, output)
+			if len(got) != 2 {
+				t.Fatalf("%d rows after cleaning, want the two of Jane merged", len(got))
+			}
+			jane := got[0]
+			if jane.Name != tt.name || jane.Email != tt.email || jane.Title != "Valve Engineer" {
+				t.Errorf("Jane merged to %q <%s> %q, want %q <%s> with the later row's title", jane.Name, jane.Email, jane.Title, tt.name, tt.email)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"gopkg.in/yaml.v3"
+)
+
+// resultParser extracts the candidates on a search results page.
+type resultParser func(doc *goquery.Document) ([]Candidate, error)
+
+// Built-in result parsers.
+const (
+	defaultResultParser   = "google"    // scrapeGoogleSearchResults.
+	selectorsResultParser = "selectors" // The built-in extraction with the CSS selectors of -result-selectors.
+)
+
+// resultParsers are the parsers -result-parser can select, by name. A
+// patched parser is added by a file that registers it in an init function,
+// without touching the built-in one:
+//
+//	func init() {
+//		registerResultParser("google-2026", func(doc *goquery.Document) ([]Candidate, error) {
+//			...
+//		})
+//	}
+var resultParsers = map[string]resultParser{
+	defaultResultParser: scrapeGoogleSearchResults,
+}
+
+// registerResultParser makes p selectable as name. It panics when name is
+// taken, as two parsers of one name are a build mistake.
+func registerResultParser(name string, p resultParser) {
+	if _, ok := resultParsers[name]; ok || name == selectorsResultParser {
+		panic(fmt.Sprintf("result parser %q registered twice", name))
+	}
+	resultParsers[name] = p
+}
+
+// resultParserNames returns the registered names, sorted, with selectors.
+func resultParserNames() []string {
+	names := []string{selectorsResultParser}
+	for name := range resultParsers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// findResultParser returns the parser called name. The selectors parser
+// needs the -result-selectors file.
+func findResultParser(name, selectorsFile string) (resultParser, error) {
+	if name == selectorsResultParser {
+		if selectorsFile == "" {
+			return nil, errors.New("the selectors parser needs -result-selectors")
+		}
+		s, err := loadResultSelectors(selectorsFile)
+		if err != nil {
+			return nil, err
+		}
+		return s.scrape, nil
+	}
+	if selectorsFile != "" {
+		return nil, fmt.Errorf("-result-selectors applies to the %s parser, not %s", selectorsResultParser, name)
+	}
+	p, ok := resultParsers[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown parser %q (want one of %s)", name, strings.Join(resultParserNames(), ", "))
+	}
+	return p, nil
+}
+
+// resultSelectors are the CSS selectors the built-in extraction finds the
+// parts of each result with.
+type resultSelectors struct {
+	Result       string `yaml:"result"`        // Each organic result.
+	Link         string `yaml:"link"`          // The profile link, within a result or card.
+	Name         string `yaml:"name"`          // Within a result.
+	Title        string `yaml:"title"`         // Within a result.
+	Snippet      string `yaml:"snippet"`       // Within a result.
+	Card         string `yaml:"card"`          // Each profile card of a carousel or people panel.
+	CardName     string `yaml:"card_name"`     // Within a card.
+	CardHeadline string `yaml:"card_headline"` // Within a card.
+}
+
+// googleResultSelectors are the selectors of Google's current layout.
+var googleResultSelectors = resultSelectors{
+	Result:  ".tF2Cxc",
+	Link:    profileLinkSelector,
+	Name:    nameSelector,
+	Title:   resultTitleSelector,
+	Snippet: googleSnippetSelector,
+
+	Card:         googleCardSelector,
+	CardName:     googleCardNameSelector,
+	CardHeadline: googleCardHeadlineSelector,
+}
+
+// loadResultSelectors reads a -result-selectors file, a YAML map of the
+// selectors that replace Google's, such as
+//
+//	result: div.g
+//	snippet: div[data-sncf]
+//
+// Selectors the file leaves out keep their built-in value.
+func loadResultSelectors(filename string) (resultSelectors, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return resultSelectors{}, fmt.Errorf("failed to open result selectors: %w", err)
+	}
+	defer file.Close()
+	s := googleResultSelectors
+	dec := yaml.NewDecoder(file)
+	dec.KnownFields(true) // A misspelled key would otherwise keep the built-in selector.
+	if err := dec.Decode(&s); err != nil && !errors.Is(err, io.EOF) {
+		return resultSelectors{}, fmt.Errorf("failed to parse result selectors: %w", err)
+	}
+	for _, sel := range []struct{ key, value string }{{"result", s.Result}, {"link", s.Link}, {"name", s.Name}, {"title", s.Title}, {"snippet", s.Snippet}, {"card", s.Card}, {"card_name", s.CardName}, {"card_headline", s.CardHeadline}} {
+		if strings.TrimSpace(sel.value) == "" {
+			return resultSelectors{}, fmt.Errorf("result selector %s is empty", sel.key)
+		}
+	}
+	return s, nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// relaidSERP is a results page after a layout change: the built-in
+// selectors find none of its results.
+const relaidSERP = `<html><body>
+<div class="g"><a href="https://www.linkedin.com/in/jane-doe"><span class="LC20lb">Jane Doe - Valve Engineer - Emerson | LinkedIn</span></a>
+<div data-sncf="1">Jane Doe is a valve engineer in Pune.</div></div>
+<div class="g"><a href="https://www.linkedin.com/in/john-roe"><span class="LC20lb">John Roe - Design Engineer | LinkedIn</span></a>
+<div data-sncf="1">John Roe designs actuators.</div></div>
+</body></html>`
+
+// registerTestParser registers p as name for the test.
+func registerTestParser(t *testing.T, name string, p resultParser) {
+	t.Helper()
+	registerResultParser(name, p)
+	t.Cleanup(func() { delete(resultParsers, name) })
+}
+
+func TestRegisteredResultParserSelected(t *testing.T) {
+	// A patched parser: the built-in one, keeping only the top result.
+	registerTestParser(t, "top-only", func(doc *goquery.Document) ([]Candidate, error) {
+		candidates, err := scrapeGoogleSearchResults(doc)
+		return candidates[:min(len(candidates), 1)], err
+	})
+	if names := strings.Join(resultParserNames(), ","); names != "google,selectors,top-only" {
+		t.Errorf("parsers %s, want the registered one listed", names)
+	}
+
+	_, addr := startFakeWeb(t, fakeRoster(3))
+	output, err := runFakeSearch(t, addr, "-max-pages", "1", "-result-parser", "top-only")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readFakeSearch(t, output); len(got) != 1 || got[0].Name != "Member 1" {
+		t.Errorf("candidates %+v, want only the top result the selected parser kept", got)
+	}
+	// The built-in parser stays the default.
+	if output, err = runFakeSearch(t, addr, "-max-pages", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFakeSearch(t, output); len(got) != 3 {
+		t.Errorf("%d candidates with the default parser, want 3", len(got))
+	}
+}
+
+func TestRegisterResultParserTwicePanics(t *testing.T) {
+	for _, name := range []string{defaultResultParser, selectorsResultParser} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("registering %s again did not panic", name)
+				}
+			}()
+			registerResultParser(name, scrapeGoogleSearchResults)
+		}()
+	}
+	if _, err := parseFlags([]string{"-result-parser", "bing-2019"}); err == nil || !strings.Contains(err.Error(), "google, selectors") {
+		t.Errorf("unknown parser: %v, want an error listing the known ones", err)
+	}
+}
+
+func TestSelectorsResultParser(t *testing.T) {
+	if got := parseResultsFixture(t, []byte(relaidSERP)); len(got) != 0 {
+		t.Fatalf("built-in parser found %d results in the new layout, want none", len(got))
+	}
+	dir := t.TempDir()
+	file := filepath.Join(dir, "selectors.yaml")
+	if err := os.WriteFile(file, []byte("result: div.g\ntitle: span.LC20lb\nsnippet: \"div[data-sncf]\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// -result-selectors alone selects the selectors parser.
+	cfg, err := parseFlags([]string{"-result-selectors", file})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := cfg.resultParser(parseHTML(t, relaidSERP))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].ProfileURL != "https://www.linkedin.com/in/jane-doe" || got[0].Snippet != "Jane Doe is a valve engineer in Pune." || resultName(got[1]) != "John Roe" {
+		t.Errorf("selectors parser extracted %+v", got)
+	}
+
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	for _, args := range [][]string{
+		{"-result-parser", selectorsResultParser},
+		{"-result-parser", defaultResultParser, "-result-selectors", file},
+		{"-result-selectors", write("typo.yaml", "reslut: div.g\n")},
+		{"-result-selectors", write("empty.yaml", "snippet: \"\"\n")},
+		{"-result-selectors", filepath.Join(dir, "missing.yaml")},
+	} {
+		if _, err := parseFlags(args); err == nil {
+			t.Errorf("%q accepted", args)
+		}
+	}
+}
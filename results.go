@@ -0,0 +1,22 @@
+package main
+
+import "checker/resultstore"
+
+// ResultStore collects the candidates a search keeps, without duplicates by
+// profile URL, in the order they were added. It is safe for concurrent use,
+// so callers may take snapshots while the pipeline is still adding to it.
+type ResultStore = resultstore.Store[Candidate]
+
+func newResultStore() *ResultStore {
+	return resultstore.New(func(c Candidate) string { return c.ProfileURL }, mergeFoundAgain)
+}
+
+// mergeFoundAgain merges c, found again by another page or query, into the
+// stored candidate, keeping the better values.
+func mergeFoundAgain(stored *Candidate, c Candidate) {
+	if stored.ResultType == resultTypeCard && c.ResultType == resultTypeOrganic {
+		// An organic result's snippet says more than a card's headline.
+		stored.ResultType, stored.ResultTitle, stored.Snippet = c.ResultType, c.ResultTitle, c.Snippet
+	}
+	mergeDuplicate(stored, c)
+}
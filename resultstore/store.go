@@ -0,0 +1,55 @@
+// Package resultstore collects the records a search keeps, without
+// duplicates, for callers that read them while the search is still running.
+package resultstore
+
+import "sync"
+
+// Store holds records in the order they were added, one per key. It is safe
+// for concurrent use, so callers may take snapshots while a pipeline is
+// still adding to it.
+type Store[T any] struct {
+	key   func(T) string
+	merge func(stored *T, found T)
+
+	mu      sync.RWMutex
+	records []T
+	index   map[string]int // Key to position in records.
+}
+
+// New returns an empty store identifying records by key. A record added
+// under a key already stored is passed to merge with the stored one, which
+// may update it in place; merge may be nil to keep the first record as is.
+func New[T any](key func(T) string, merge func(stored *T, found T)) *Store[T] {
+	return &Store[T]{key: key, merge: merge, index: make(map[string]int)}
+}
+
+// Add stores r unless a record with the same key is already stored, in
+// which case it is merged into that one, and reports whether r was added.
+func (s *Store[T]) Add(r T) bool {
+	k := s.key(r)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i, ok := s.index[k]; ok {
+		if s.merge != nil {
+			s.merge(&s.records[i], r)
+		}
+		return false
+	}
+	s.index[k] = len(s.records)
+	s.records = append(s.records, r)
+	return true
+}
+
+// Snapshot returns a copy of the stored records, in the order added.
+func (s *Store[T]) Snapshot() []T {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]T(nil), s.records...)
+}
+
+// Len returns the number of stored records.
+func (s *Store[T]) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.records)
+}
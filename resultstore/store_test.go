@@ -0,0 +1,97 @@
+package resultstore
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+type record struct {
+	key   string
+	found int // Times added.
+}
+
+func newRecordStore() *Store[record] {
+	return New(func(r record) string { return r.key }, func(stored *record, found record) { stored.found += found.found })
+}
+
+func TestAddMergesDuplicates(t *testing.T) {
+	s := newRecordStore()
+	for _, k := range []string{"a", "b", "a", "c", "a"} {
+		s.Add(record{key: k, found: 1})
+	}
+	got := s.Snapshot()
+	want := []record{{"a", 3}, {"b", 1}, {"c", 1}}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("stored %v, want %v", got, want)
+	}
+	if s.Len() != 3 {
+		t.Errorf("Len = %d, want 3", s.Len())
+	}
+
+	// Without a merge, the first record stays as it is.
+	first := New[record](func(r record) string { return r.key }, nil)
+	first.Add(record{"a", 1})
+	if first.Add(record{"a", 5}) {
+		t.Error("duplicate reported as added")
+	}
+	if got := first.Snapshot(); got[0].found != 1 {
+		t.Errorf("stored %v, want the first record", got)
+	}
+}
+
+func TestConcurrentAddAndSnapshot(t *testing.T) {
+	const writers, keys = 8, 500
+	s := newRecordStore()
+	var added sync.WaitGroup
+	for w := range writers {
+		added.Add(1)
+		go func() {
+			defer added.Done()
+			for i := range keys {
+				// Every writer adds every key, in its own order.
+				s.Add(record{key: fmt.Sprint((i + w*keys/writers) % keys), found: 1})
+			}
+		}()
+	}
+
+	// Snapshots taken meanwhile never hold a key twice, and each is a
+	// prefix of every later one.
+	done := make(chan struct{})
+	go func() {
+		added.Wait()
+		close(done)
+	}()
+	var snapshots [][]record
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+		}
+		snapshots = append(snapshots, s.Snapshot())
+	}
+	final := s.Snapshot()
+	if len(final) != keys {
+		t.Fatalf("stored %d records, want %d", len(final), keys)
+	}
+	total := 0
+	for _, r := range final {
+		total += r.found
+	}
+	if total != writers*keys {
+		t.Errorf("records found %d times in all, want %d", total, writers*keys)
+	}
+	for _, snap := range snapshots {
+		seen := make(map[string]bool)
+		for i, r := range snap {
+			if seen[r.key] {
+				t.Fatalf("snapshot holds %s twice", r.key)
+			}
+			seen[r.key] = true
+			if r.key != final[i].key {
+				t.Fatalf("snapshot record %d is %s, the final one %s", i, r.key, final[i].key)
+			}
+		}
+	}
+}
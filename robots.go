@@ -0,0 +1,332 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// errRobotsDisallowed is returned for a request robots.txt rules out.
+var errRobotsDisallowed = errors.New("disallowed by robots.txt")
+
+// Values of -robots.
+const (
+	robotsProfile = "profile" // Only profile sites.
+	robotsAll     = "all"     // Search engines too.
+)
+
+// Values of -robots-fail-policy.
+const (
+	robotsFailOpen   = "open"   // Allow every URL of a host whose robots.txt cannot be fetched.
+	robotsFailClosed = "closed" // Allow none.
+)
+
+const (
+	defaultRobotsAgent = "profilesearch"
+	robotsTTL          = 24 * time.Hour   // How long a fetched robots.txt is used.
+	robotsFailureTTL   = 10 * time.Minute // How long a failed fetch stands before it is tried again.
+	maxRobotsBytes     = 500 << 10        // Rules beyond this are ignored, as crawlers do.
+)
+
+// robotsRule is one Allow or Disallow line.
+type robotsRule struct {
+	allow   bool
+	pattern string // Path pattern; * matches any run of characters, a final $ the end.
+}
+
+// matches reports whether the rule's pattern matches path.
+func (r robotsRule) matches(path string) bool {
+	pattern := r.pattern
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	rest := path[len(parts[0]):]
+	for i, part := range parts[1:] {
+		if i == len(parts)-2 && anchored {
+			return strings.HasSuffix(rest, part)
+		}
+		j := strings.Index(rest, part)
+		if j < 0 {
+			return false
+		}
+		rest = rest[j+len(part):]
+	}
+	return !anchored || rest == "" || strings.HasSuffix(pattern, "*")
+}
+
+// robotsGroup is the rules robots.txt gives a set of user agents.
+type robotsGroup struct {
+	agents     []string // Lower-cased product tokens; "*" for any.
+	rules      []robotsRule
+	crawlDelay time.Duration
+}
+
+// robotsPolicy is a host's parsed robots.txt.
+type robotsPolicy struct {
+	groups []robotsGroup
+}
+
+// parseRobots parses a robots.txt. Consecutive User-agent lines share the
+// rules that follow them; unknown lines are ignored.
+func parseRobots(text string) robotsPolicy {
+	var p robotsPolicy
+	var cur *robotsGroup
+	inAgents := false
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key, value = strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(value)
+		switch key {
+		case "user-agent":
+			if !inAgents {
+				p.groups = append(p.groups, robotsGroup{})
+				cur = &p.groups[len(p.groups)-1]
+			}
+			cur.agents = append(cur.agents, strings.ToLower(value))
+			inAgents = true
+			continue
+		case "allow", "disallow":
+			// An empty Disallow allows everything, which is no rule at all.
+			if cur != nil && value != "" {
+				cur.rules = append(cur.rules, robotsRule{allow: key == "allow", pattern: value})
+			}
+		case "crawl-delay":
+			if seconds, err := strconv.ParseFloat(value, 64); cur != nil && err == nil && seconds > 0 {
+				cur.crawlDelay = time.Duration(seconds * float64(time.Second))
+			}
+		}
+		inAgents = false
+	}
+	return p
+}
+
+// group returns the group for agent: the one naming it, or else the "*" one.
+func (p robotsPolicy) group(agent string) *robotsGroup {
+	agent = strings.ToLower(agent)
+	var fallback *robotsGroup
+	for i := range p.groups {
+		for _, a := range p.groups[i].agents {
+			switch {
+			case a == agent:
+				return &p.groups[i]
+			case a == "*" && fallback == nil:
+				fallback = &p.groups[i]
+			}
+		}
+	}
+	return fallback
+}
+
+// allowed reports whether agent may fetch path, which includes any query.
+// The longest matching pattern decides; on a tie, Allow wins.
+func (p robotsPolicy) allowed(agent, path string) bool {
+	g := p.group(agent)
+	if g == nil {
+		return true
+	}
+	best, allow := -1, true
+	for _, r := range g.rules {
+		if !r.matches(path) {
+			continue
+		}
+		if n := len(r.pattern); n > best || (n == best && r.allow) {
+			best, allow = n, r.allow
+		}
+	}
+	return allow
+}
+
+// crawlDelay returns agent's Crawl-delay, or 0.
+func (p robotsPolicy) crawlDelay(agent string) time.Duration {
+	if g := p.group(agent); g != nil {
+		return g.crawlDelay
+	}
+	return 0
+}
+
+// robotsEntry is the cached robots.txt of a host.
+type robotsEntry struct {
+	mu      sync.Mutex // Held while fetching, so a host's file is fetched once.
+	policy  robotsPolicy
+	status  string // As reported: fetched, none, or failed with the reason.
+	failed  bool
+	expires time.Time
+
+	allowed, disallowed int
+}
+
+// robotsChecker evaluates requests against the robots.txt of their hosts,
+// fetching each once per robotsTTL. It is safe for concurrent use; a nil
+// robotsChecker allows everything.
+type robotsChecker struct {
+	agent      string
+	classes    map[string]bool // Host classes checked.
+	failClosed bool
+
+	mu    sync.Mutex
+	hosts map[string]*robotsEntry
+}
+
+// newRobotsChecker builds a checker from the -robots flags, or returns nil
+// when ignore is set.
+func newRobotsChecker(scope, failPolicy, agent string, ignore bool) (*robotsChecker, error) {
+	c := &robotsChecker{agent: agent, classes: map[string]bool{hostClassProfile: true}, hosts: make(map[string]*robotsEntry)}
+	switch scope {
+	case robotsProfile:
+	case robotsAll:
+		c.classes[hostClassSearch] = true
+	default:
+		return nil, fmt.Errorf("invalid -robots %q: want %s or %s", scope, robotsProfile, robotsAll)
+	}
+	switch failPolicy {
+	case robotsFailOpen:
+	case robotsFailClosed:
+		c.failClosed = true
+	default:
+		return nil, fmt.Errorf("invalid -robots-fail-policy %q: want %s or %s", failPolicy, robotsFailOpen, robotsFailClosed)
+	}
+	if ignore {
+		log.Print("WARNING: -ignore-robots is set; robots.txt rules are not honored, and you take responsibility for the requests made.")
+		return nil, nil
+	}
+	return c, nil
+}
+
+// check reports whether the request for u may be made, fetching the host's
+// robots.txt through f first when it is not cached. That fetch is charged to
+// the request budget like any other request, and a spent budget returns
+// errBudgetExhausted rather than counting as a failed fetch. A crawl delay
+// the host asks for is applied to the limiter of its identity.
+func (c *robotsChecker) check(ctx context.Context, f *httpFetcher, u *url.URL) error {
+	if c == nil || !c.classes[classifyHost(u.Host)] {
+		return nil
+	}
+	c.mu.Lock()
+	e := c.hosts[u.Host]
+	if e == nil {
+		e = &robotsEntry{}
+		c.hosts[u.Host] = e
+	}
+	c.mu.Unlock()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if now := clockNow(); now.After(e.expires) {
+		if err := c.fetch(ctx, f, u, e, now); err != nil {
+			return err
+		}
+	}
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+	switch {
+	case e.failed && c.failClosed:
+	case e.failed || e.policy.allowed(c.agent, path):
+		e.allowed++
+		return nil
+	}
+	e.disallowed++
+	stats.countRobotsDisallowed()
+	return fmt.Errorf("%s: %w", u, errRobotsDisallowed)
+}
+
+// fetch loads the host's robots.txt into e. A missing file, or any other
+// client error, allows everything; a server error or a failed request is a
+// failure, tried again after robotsFailureTTL. Only errors that stop the run
+// are returned.
+func (c *robotsChecker) fetch(ctx context.Context, f *httpFetcher, u *url.URL, e *robotsEntry, now time.Time) error {
+	robotsURL := u.Scheme + "://" + u.Host + "/robots.txt"
+	resp, err := f.do(ctx, "GET", robotsURL, nil)
+	if stopsRun(err) {
+		return err
+	}
+	var body strings.Builder
+	if err == nil {
+		defer resp.Body.Close()
+		switch {
+		case resp.StatusCode == http.StatusOK:
+			_, err = bufio.NewReader(resp.Body).WriteTo(&limitedBuilder{&body, maxRobotsBytes})
+		case resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests:
+			err = fmt.Errorf("status %d", resp.StatusCode)
+		default:
+			e.policy, e.failed, e.status, e.expires = robotsPolicy{}, false, fmt.Sprintf("none (status %d)", resp.StatusCode), now.Add(robotsTTL)
+			return nil
+		}
+	}
+	if err != nil {
+		e.failed, e.status, e.expires = true, "failed: "+err.Error(), now.Add(robotsFailureTTL)
+		log.Printf("Failed to fetch %s (%v); %s.", robotsURL, err, map[bool]string{true: "not fetching from the host for now", false: "allowing every URL of the host for now"}[c.failClosed])
+		return nil
+	}
+	e.policy, e.failed, e.status, e.expires = parseRobots(body.String()), false, "fetched", now.Add(robotsTTL)
+	if delay := e.policy.crawlDelay(c.agent); delay > 0 {
+		f.identityFor(u.Host).limiter.raiseFloor(delay)
+		verbosef("%s asks for a crawl delay of %s", u.Host, delay)
+	}
+	return nil
+}
+
+// limitedBuilder keeps the first max bytes written to it and discards the
+// rest.
+type limitedBuilder struct {
+	b   *strings.Builder
+	max int
+}
+
+func (l *limitedBuilder) Write(p []byte) (int, error) {
+	if room := l.max - l.b.Len(); room > 0 {
+		l.b.Write(p[:min(len(p), room)])
+	}
+	return len(p), nil
+}
+
+// report prints the robots.txt policy met on each host.
+func (c *robotsChecker) report() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.hosts) == 0 {
+		return
+	}
+	hosts := make([]string, 0, len(c.hosts))
+	for host := range c.hosts {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	fmt.Printf("robots.txt policy (user agent %s):\n", c.agent)
+	for _, host := range hosts {
+		e := c.hosts[host]
+		e.mu.Lock()
+		line := fmt.Sprintf("  %s: %s; %d requests allowed, %d disallowed", host, e.status, e.allowed, e.disallowed)
+		if delay := e.policy.crawlDelay(c.agent); delay > 0 {
+			line += fmt.Sprintf("; crawl delay %s", delay)
+		}
+		e.mu.Unlock()
+		fmt.Println(line)
+	}
+}
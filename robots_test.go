@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRobotsAllowed(t *testing.T) {
+	policy := parseRobots(`
+# Comments and unknown lines are ignored.
+Sitemap: https://example.com/sitemap.xml
+
+User-agent: otherbot
+User-agent: ProfileSearch
+Disallow: /private
+Allow: /private/ok
+Disallow: /*.json$
+Disallow: /search*q=
+Allow: /tie
+Disallow: /tie
+Crawl-delay: 1.5
+
+User-agent: *
+Disallow: /
+`)
+	for _, tc := range []struct {
+		agent, path string
+		want        bool
+	}{
+		{"profilesearch", "/", true},
+		{"profilesearch", "/private", false},
+		{"profilesearch", "/private/page", false},
+		{"profilesearch", "/private/ok", true},        // The longer Allow wins.
+		{"profilesearch", "/private/ok/deeper", true}, // Patterns are prefixes.
+		{"profilesearch", "/data.json", false},        // * and an end anchor.
+		{"profilesearch", "/data.json?v=1", true},     // $ anchors the whole path.
+		{"profilesearch", "/a/b/c.json", false},       // * crosses slashes.
+		{"profilesearch", "/search?hl=en&q=x", false}, // Queries are part of the path.
+		{"profilesearch", "/search?hl=en", true},      // The pattern needs q=.
+		{"profilesearch", "/tie", true},               // Allow wins a tie.
+		{"OtherBot", "/private", false},               // Agents match without case, sharing a group.
+		{"somebot", "/anything", false},               // The * group is the fallback.
+		{"somebot", "/", false},
+	} {
+		if got := policy.allowed(tc.agent, tc.path); got != tc.want {
+			t.Errorf("allowed(%q, %q) = %v, want %v", tc.agent, tc.path, got, tc.want)
+		}
+	}
+	if got := policy.crawlDelay("profilesearch"); got != 1500*time.Millisecond {
+		t.Errorf("crawlDelay = %s, want 1.5s", got)
+	}
+	if got := policy.crawlDelay("somebot"); got != 0 {
+		t.Errorf("crawlDelay of the * group = %s, want 0", got)
+	}
+	if !parseRobots("User-agent: *\nDisallow:\n").allowed("profilesearch", "/in/x") {
+		t.Error("an empty Disallow disallows")
+	}
+	if !parseRobots("").allowed("profilesearch", "/in/x") {
+		t.Error("an empty file disallows")
+	}
+}
+
+func TestRobotsFetchSpendsBudget(t *testing.T) {
+	web, addr := startFakeWeb(t, "robots: \"User-agent: *\\nAllow: /\\n\"\n"+fakeRoster(1))
+	opts := fakeFetcherOptions(t, addr)
+	opts.maxRequests = 1
+	f, err := newHTTPFetcher(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	// robots.txt takes the only request, leaving none for the profile.
+	if _, err := f.Fetch(ctx, "https://www.linkedin.com/in/member-1"); !errors.Is(err, errBudgetExhausted) {
+		t.Fatalf("Fetch = %v, want %v", err, errBudgetExhausted)
+	}
+	if got, want := web.requests(), web.requests("robots"); got != 1 || want != 1 {
+		t.Errorf("%d requests made, %d of them for robots.txt; want just the one", got, want)
+	}
+
+	// A spent budget stops a robots.txt fetch rather than failing it.
+	if _, err := f.Fetch(ctx, "https://uk.linkedin.com/in/member-1"); !errors.Is(err, errBudgetExhausted) {
+		t.Fatalf("Fetch on a new host = %v, want %v", err, errBudgetExhausted)
+	}
+	if e := f.robots.hosts["uk.linkedin.com"]; e == nil || e.failed {
+		t.Errorf("robots.txt of a new host recorded as %+v, want unfetched", e)
+	}
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Layout of a -run-dir.
+const (
+	runDirPages    = "pending"        // One segment per parsed results page.
+	runDirEnriched = "enriched.jsonl" // Journal of candidates whose enrichment finished.
+)
+
+// segmentHeader is the first line of a page segment.
+type segmentHeader struct {
+	PageURL  string    `json:"page_url"`
+	Query    string    `json:"query"`
+	Page     int       `json:"page"` // 1-based, within the query.
+	Start    int       `json:"start"`
+	Found    int       `json:"found"`
+	ParsedAt time.Time `json:"parsed_at"`
+}
+
+// segmentCandidate is a candidate line of a page segment, as parsed from the
+// page and before enrichment.
+type segmentCandidate struct {
+	Position int `json:"position"` // 1-based, on the page.
+	Candidate
+}
+
+// enrichedRecord is one line of the enriched journal.
+type enrichedRecord struct {
+	Keywords string    `json:"keywords"` // Matched terms depend on the query.
+	Time     time.Time `json:"time"`
+	Candidate
+}
+
+// pendingRun persists a run's work between parsing results pages and
+// enriching their candidates in a -run-dir, so a run restarted with the same
+// directory after a crash neither fetches nor parses those pages again, and
+// fetches only the profiles it had not finished. It is safe for concurrent
+// use; a nil pendingRun persists nothing.
+type pendingRun struct {
+	dir string
+
+	mu       sync.Mutex
+	journal  *os.File
+	enriched map[string]Candidate // By enrichedKey.
+}
+
+// openPendingRun opens the run directory dir, creating it if needed. A final
+// journal line cut short by a crash is dropped, so that candidate is enriched
+// again.
+func openPendingRun(dir string) (*pendingRun, error) {
+	if err := os.MkdirAll(filepath.Join(dir, runDirPages), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create run directory: %w", err)
+	}
+	p := &pendingRun{dir: dir, enriched: make(map[string]Candidate)}
+	path := filepath.Join(dir, runDirEnriched)
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	valid := 0
+	for line := 1; len(data[valid:]) > 0; line++ {
+		i := bytes.IndexByte(data[valid:], '\n')
+		if i < 0 {
+			log.Printf("%s ends in a partial record, probably from a crash; dropping it.", path)
+			break
+		}
+		raw := bytes.TrimSpace(data[valid : valid+i])
+		valid += i + 1
+		if len(raw) == 0 {
+			continue
+		}
+		var r enrichedRecord
+		if err := json.Unmarshal(raw, &r); err != nil {
+			return nil, fmt.Errorf("failed to read %s: line %d: %w", path, line, err)
+		}
+		p.enriched[enrichedKey(r.Keywords, r.ProfileURL)] = r.Candidate
+	}
+	if p.journal, err = os.OpenFile(path, os.O_WRONLY|os.O_CREATE, 0o644); err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	// Appends start after the last whole line, overwriting a cut one.
+	if err := p.journal.Truncate(int64(valid)); err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	if _, err := p.journal.Seek(int64(valid), io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	return p, nil
+}
+
+// describe prints what an earlier run left in the directory.
+func (p *pendingRun) describe() {
+	if p == nil {
+		return
+	}
+	segments, _ := filepath.Glob(filepath.Join(p.dir, runDirPages, "*.jsonl"))
+	if len(segments) > 0 || len(p.enriched) > 0 {
+		fmt.Printf("Resuming from %s: %d results pages parsed and %d profiles enriched already\n", p.dir, len(segments), len(p.enriched))
+	}
+}
+
+// segmentPath returns the file of the segment of pageURL.
+func (p *pendingRun) segmentPath(pageURL string) string {
+	sum := sha256.Sum256([]byte(pageURL))
+	return filepath.Join(p.dir, runDirPages, hex.EncodeToString(sum[:12])+".jsonl")
+}
+
+// loadSegment returns the candidates parsed from pageURL by an earlier run,
+// if it saved them.
+func (p *pendingRun) loadSegment(pageURL string) ([]Candidate, bool, error) {
+	if p == nil {
+		return nil, false, nil
+	}
+	file, err := os.Open(p.segmentPath(pageURL))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to open segment: %w", err)
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 16<<20)
+	var header segmentHeader
+	var candidates []Candidate
+	for line := 1; scanner.Scan(); line++ {
+		var err error
+		if line == 1 {
+			err = json.Unmarshal(scanner.Bytes(), &header)
+		} else {
+			var sc segmentCandidate
+			err = json.Unmarshal(scanner.Bytes(), &sc)
+			candidates = append(candidates, sc.Candidate)
+		}
+		if err != nil {
+			return nil, false, fmt.Errorf("segment of %s: line %d: %w", pageURL, line, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, false, fmt.Errorf("failed to read segment of %s: %w", pageURL, err)
+	}
+	if header.PageURL != pageURL {
+		return nil, false, fmt.Errorf("segment of %s holds %s", pageURL, header.PageURL)
+	}
+	return candidates, true, nil
+}
+
+// saveSegment records the candidates parsed from a page. The segment is
+// written whole or not at all, so a page is either done or fetched again.
+func (p *pendingRun) saveSegment(header segmentHeader, candidates []Candidate) error {
+	if p == nil {
+		return nil
+	}
+	header.Found = len(candidates)
+	return writeFileAtomic(p.segmentPath(header.PageURL), func(w io.Writer) error {
+		enc := json.NewEncoder(w)
+		if err := enc.Encode(header); err != nil {
+			return err
+		}
+		for i, c := range candidates {
+			if err := enc.Encode(segmentCandidate{Position: i + 1, Candidate: c}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// enrichedKey identifies a candidate's enrichment for a query.
+func enrichedKey(keywords, profileURL string) string {
+	return keywords + "\n" + profileURL
+}
+
+// lookup returns the enriched form of a candidate an earlier run finished.
+func (p *pendingRun) lookup(keywords, profileURL string) (Candidate, bool) {
+	if p == nil {
+		return Candidate{}, false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	c, ok := p.enriched[enrichedKey(keywords, profileURL)]
+	return c, ok
+}
+
+// markEnriched journals a candidate whose enrichment finished. The line is
+// synced before returning, so a crash after it never enriches c again.
+func (p *pendingRun) markEnriched(keywords string, c Candidate) error {
+	if p == nil {
+		return nil
+	}
+	b, err := json.Marshal(enrichedRecord{Keywords: keywords, Time: clockNow().UTC(), Candidate: c})
+	if err != nil {
+		return fmt.Errorf("failed to encode enriched candidate: %w", err)
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, err := p.journal.Write(append(b, '\n')); err != nil {
+		return fmt.Errorf("failed to journal enriched candidate: %w", err)
+	}
+	if err := p.journal.Sync(); err != nil {
+		return fmt.Errorf("failed to journal enriched candidate: %w", err)
+	}
+	p.enriched[enrichedKey(keywords, c.ProfileURL)] = c
+	return nil
+}
+
+// close closes the journal.
+func (p *pendingRun) close() {
+	if p == nil {
+		return
+	}
+	if err := p.journal.Close(); err != nil {
+		log.Printf("Failed to close %s: %v", runDirEnriched, err)
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPendingRunSegments(t *testing.T) {
+	p, err := openPendingRun(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.close()
+	pageURL := "https://www.google.com/search?q=valve&start=10"
+	if _, ok, err := p.loadSegment(pageURL); ok || err != nil {
+		t.Fatalf("unsaved page loaded: %v, %v", ok, err)
+	}
+	header := segmentHeader{PageURL: pageURL, Query: "valve", Page: 2, Start: 10, ParsedAt: time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)}
+	saved := []Candidate{
+		{Name: "Jane Doe", ProfileURL: "https://www.linkedin.com/in/jane-doe", Snippet: "Valve engineer"},
+		{Name: "John Roe", ProfileURL: "https://www.linkedin.com/in/john-roe"},
+	}
+	if err := p.saveSegment(header, saved); err != nil {
+		t.Fatal(err)
+	}
+	got, ok, err := p.loadSegment(pageURL)
+	if err != nil || !ok {
+		t.Fatalf("saved page not loaded: %v, %v", ok, err)
+	}
+	if len(got) != 2 || got[0].Name != "Jane Doe" || got[0].Snippet != "Valve engineer" || got[1].ProfileURL != saved[1].ProfileURL {
+		t.Errorf("loaded %+v, want %+v", got, saved)
+	}
+
+	// A segment holding another page is not trusted.
+	if err := os.Rename(p.segmentPath(pageURL), p.segmentPath("https://www.google.com/search?q=valve")); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := p.loadSegment("https://www.google.com/search?q=valve"); err == nil {
+		t.Error("a segment of another page loaded")
+	}
+}
+
+func TestPendingRunJournalDropsCutLine(t *testing.T) {
+	dir := t.TempDir()
+	p, err := openPendingRun(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jane := Candidate{Name: "Jane Doe", ProfileURL: "https://www.linkedin.com/in/jane-doe", Email: "jane@example.com"}
+	if err := p.markEnriched("valve", jane); err != nil {
+		t.Fatal(err)
+	}
+	p.close()
+	// A crash while journaling john-roe leaves half a line.
+	journal := filepath.Join(dir, runDirEnriched)
+	f, err := os.OpenFile(journal, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString(`{"keywords":"valve","time":"2026-03-01T09:00:00Z","name":"John R`); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	p, err = openPendingRun(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c, ok := p.lookup("valve", jane.ProfileURL); !ok || c.Email != jane.Email {
+		t.Errorf("jane-doe looked up as %+v, %v; want her enriched record", c, ok)
+	}
+	if _, ok := p.lookup("other keywords", jane.ProfileURL); ok {
+		t.Error("an enrichment for other keywords reused")
+	}
+	john := Candidate{Name: "John Roe", ProfileURL: "https://www.linkedin.com/in/john-roe"}
+	if err := p.markEnriched("valve", john); err != nil {
+		t.Fatal(err)
+	}
+	p.close()
+	data, err := os.ReadFile(journal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := bytes.Split(bytes.TrimSpace(data), []byte("\n")); len(lines) != 2 || bytes.Contains(data, []byte(`"John R"`)) {
+		t.Errorf("journal %s, want jane-doe's line and john-roe's whole one", data)
+	}
+}
+
+func TestRunDirResumesAfterCrash(t *testing.T) {
+	runDir := filepath.Join(t.TempDir(), "run")
+
+	// The first run parses the results page, enriches two profiles, and is
+	// cut off by its request budget as a crash would cut it off. The last
+	// journal line is cut short too.
+	_, addr := startFakeWeb(t, fakeRoster(5))
+	if _, err := runFakeSearch(t, addr, "-max-pages", "1", "-run-dir", runDir, "-max-requests", "4"); err != nil {
+		t.Fatal(err)
+	}
+	journal := filepath.Join(runDir, runDirEnriched)
+	data, err := os.ReadFile(journal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := bytes.Count(data, []byte("\n")); n != 2 {
+		t.Fatalf("%d profiles journaled before the crash, want 2", n)
+	}
+	if err := os.WriteFile(journal, append(data, `{"keywords":"`...), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Restarted with the same directory, the run neither fetches nor parses
+	// the results page, and fetches only the three profiles left.
+	web, addr := startFakeWeb(t, fakeRoster(5))
+	output, err := runFakeSearch(t, addr, "-max-pages", "1", "-run-dir", runDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := web.requests("search"); n != 0 {
+		t.Errorf("%d results pages fetched again, want none", n)
+	}
+	if n := web.requests("profile"); n != 3 {
+		t.Errorf("%d profiles fetched, want the 3 not enriched before the crash", n)
+	}
+	got := readFakeSearch(t, output)
+	if len(got) != 5 {
+		t.Fatalf("%d candidates written, want 5", len(got))
+	}
+	for _, c := range got {
+		if c.Name == "" {
+			t.Errorf("%s written without its profile's name", c.ProfileURL)
+		}
+	}
+	if data, err = os.ReadFile(journal); err != nil {
+		t.Fatal(err)
+	}
+	if n := bytes.Count(data, []byte("\n")); n != 5 {
+		t.Errorf("%d profiles journaled, want all 5", n)
+	}
+}
@@ -0,0 +1,344 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// version is the tool version, set at build time with
+// -ldflags "-X main.version=...".
+var version = "dev"
+
+// runInfoMarker is the first comment line of the run info block in CSV output.
+const runInfoMarker = "profilesearch run info"
+
+// runInfo is the reproducibility block embedded in output files. It records
+// what produced the file so the search can be re-run later.
+type runInfo struct {
+	Version   string
+	Timestamp time.Time
+	Engine    string
+	Job       string
+	Criteria  SearchCriteria
+	MaxPages  int
+	Args      []string // Command-line arguments, with secrets redacted.
+}
+
+// newRunInfo captures the settings of a search.
+func newRunInfo(cfg *config, criteria SearchCriteria, job string) *runInfo {
+	return &runInfo{
+		Version:   version,
+		Timestamp: clockNow().UTC(),
+		Engine:    "google",
+		Job:       job,
+		Criteria:  criteria,
+		MaxPages:  cfg.maxPages,
+		Args:      redactArgs(cfg.args),
+	}
+}
+
+// fields returns the block as ordered key/value pairs.
+func (ri *runInfo) fields() [][2]string {
+	return [][2]string{
+		{"version", ri.Version},
+		{"timestamp", outputTime(ri.Timestamp).Format(time.RFC3339)},
+		{"engine", ri.Engine},
+		{"job", ri.Job},
+		{"keywords", ri.Criteria.Keywords},
+		{"location", ri.Criteria.Location},
+		{"industry", ri.Criteria.Industry},
+		{"experience", ri.Criteria.ExperienceRange},
+		{"current_company", ri.Criteria.CurrentCompany},
+		{"loose_keywords", strconv.FormatBool(ri.Criteria.LooseKeywords)},
+		{"exclude_keywords", ri.Criteria.ExcludeKeywords},
+		{"max_pages", strconv.Itoa(ri.MaxPages)},
+		{"args", strings.Join(ri.Args, " ")},
+	}
+}
+
+// set sets the field named key, as in fields, from its value. Unknown keys,
+// perhaps of a later version, are ignored.
+func (ri *runInfo) set(key, value string) {
+	switch key {
+	case "version":
+		ri.Version = value
+	case "timestamp":
+		ri.Timestamp, _ = time.Parse(time.RFC3339, value)
+	case "engine":
+		ri.Engine = value
+	case "job":
+		ri.Job = value
+	case "keywords":
+		ri.Criteria.Keywords = value
+	case "location":
+		ri.Criteria.Location = value
+	case "industry":
+		ri.Criteria.Industry = value
+	case "experience":
+		ri.Criteria.ExperienceRange = value
+	case "current_company":
+		ri.Criteria.CurrentCompany = value
+	case "loose_keywords":
+		ri.Criteria.LooseKeywords, _ = strconv.ParseBool(value)
+	case "exclude_keywords":
+		ri.Criteria.ExcludeKeywords = value
+	case "max_pages":
+		ri.MaxPages, _ = strconv.Atoi(value)
+	case "args":
+		ri.Args = strings.Fields(value)
+	}
+}
+
+// MarshalJSON encodes the block as one object of its fields, in order.
+func (ri *runInfo) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, kv := range ri.fields() {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		key, _ := json.Marshal(kv[0])
+		value, _ := json.Marshal(kv[1])
+		b.Write(key)
+		b.WriteByte(':')
+		b.Write(value)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+// runInfoFromFields builds a block from the fields of its JSON, XLSX, or
+// HTML form, or returns an error naming filename when there are none.
+func runInfoFromFields(filename string, fields map[string]string) (*runInfo, error) {
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("%s has no run info block", filename)
+	}
+	ri := &runInfo{}
+	for key, value := range fields {
+		ri.set(key, value)
+	}
+	return ri, nil
+}
+
+// readRunInfo parses the run info block of an output file of any format
+// that embeds one, told apart by its extension.
+func readRunInfo(filename string) (*runInfo, error) {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".json", ".jsonl":
+		return readJSONRunInfo(filename)
+	case ".xlsx":
+		return readXLSXRunInfo(filename)
+	case ".html", ".htm":
+		return readHTMLRunInfo(filename)
+	}
+	return readCSVRunInfo(filename)
+}
+
+// writeCSVComment writes the block as "# key: value" lines.
+func (ri *runInfo) writeCSVComment(w io.Writer) error {
+	if _, err := fmt.Fprintf(w, "# %s\n", runInfoMarker); err != nil {
+		return err
+	}
+	for _, kv := range ri.fields() {
+		// Values never span lines, so the block stays one comment per field.
+		value := strings.NewReplacer("\n", " ", "\r", " ").Replace(kv[1])
+		if _, err := fmt.Fprintf(w, "# %s: %s\n", kv[0], value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readCSVRunInfo parses the run info block at the top of a CSV file.
+func readCSVRunInfo(filename string) (*runInfo, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", filename, err)
+	}
+	defer file.Close()
+
+	ri := &runInfo{}
+	found := false
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimPrefix(scanner.Text(), "\ufeff")
+		if !strings.HasPrefix(line, "#") {
+			break
+		}
+		line = strings.TrimSpace(strings.TrimPrefix(line, "#"))
+		if line == runInfoMarker {
+			found = true
+			continue
+		}
+		if key, value, ok := strings.Cut(line, ":"); ok {
+			ri.set(strings.TrimSpace(key), strings.TrimSpace(value))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", filename, err)
+	}
+	if !found {
+		return nil, fmt.Errorf("%s has no run info block", filename)
+	}
+	return ri, nil
+}
+
+// runConfigFile is written next to the output of every search, recording the
+// effective configuration of the run.
+const runConfigFile = "run-config.json"
+
+// runConfig is the effective configuration of a run: the value of every
+// flag once the -config file is applied, with secrets redacted.
+type runConfig struct {
+	Version   string            `json:"version"`
+	Timestamp time.Time         `json:"timestamp"`
+	Args      []string          `json:"args"`  // Command-line arguments.
+	Flags     map[string]string `json:"flags"` // Every flag, defaulted or not, by name.
+	Set       []string          `json:"set"`   // Flags given on the command line or in the -config file.
+}
+
+// newRunConfig captures the flags of fs, parsed from args.
+func newRunConfig(fs *flag.FlagSet, args []string) *runConfig {
+	rc := &runConfig{Version: version, Timestamp: clockNow().UTC(), Args: redactArgs(args), Flags: make(map[string]string), Set: []string{}}
+	fs.VisitAll(func(f *flag.Flag) { rc.Flags[f.Name] = redactFlagValue(f.Name, f.Value.String()) })
+	fs.Visit(func(f *flag.Flag) { rc.Set = append(rc.Set, f.Name) })
+	return rc
+}
+
+// printSettings writes the flags that were set, with secrets redacted, as a
+// -config file would give them, for -print-config.
+func (rc *runConfig) printSettings(w io.Writer) error {
+	settings := make(map[string]string)
+	for _, name := range rc.Set {
+		switch name {
+		case "config", "profile", "print-config":
+			continue
+		}
+		settings[name] = rc.Flags[name]
+	}
+	if len(settings) == 0 {
+		_, err := fmt.Fprintln(w, "# Every setting has its default.")
+		return err
+	}
+	return yaml.NewEncoder(w).Encode(settings)
+}
+
+// write writes the configuration as JSON to filename.
+func (rc *runConfig) write(filename string) error {
+	b, err := json.MarshalIndent(rc, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode run config: %w", err)
+	}
+	return writeFileAtomic(filename, func(w io.Writer) error {
+		_, err := w.Write(append(b, '\n'))
+		return err
+	})
+}
+
+// redactFlagValue masks the value of a secret-looking flag and the
+// credentials of any URLs in a value, which may be a list of them.
+func redactFlagValue(name, value string) string {
+	if value != "" && secretFlagPattern.MatchString(name) {
+		return "REDACTED"
+	}
+	return urlCredentialsPattern.ReplaceAllString(value, "://REDACTED@")
+}
+
+// secretFlagPattern matches flag names whose values must never be recorded.
+// "auth" counts only as a word of its own, not in -authwall-backoff.
+var secretFlagPattern = regexp.MustCompile(`(?i)(secret|token|password|passwd|api-?key|(^|-)auth(-|$)|authorization|cred)`)
+
+// redactArgs masks the values of secret-looking flags and any credentials
+// embedded in URLs, such as proxy user:password pairs.
+func redactArgs(args []string) []string {
+	redacted := make([]string, len(args))
+	maskNext := false
+	for i, arg := range args {
+		switch {
+		case maskNext:
+			redacted[i] = "REDACTED"
+			maskNext = false
+		case strings.HasPrefix(arg, "-"):
+			name, _, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+			if secretFlagPattern.MatchString(name) {
+				if hasValue {
+					redacted[i] = arg[:strings.Index(arg, "=")+1] + "REDACTED"
+				} else {
+					redacted[i] = arg
+					maskNext = true
+				}
+				continue
+			}
+			redacted[i] = redactURLCredentials(arg)
+		default:
+			redacted[i] = redactURLCredentials(arg)
+		}
+	}
+	return redacted
+}
+
+// urlCredentialsPattern finds user:password@ in URLs.
+var urlCredentialsPattern = regexp.MustCompile(`://[^/@\s]+@`)
+
+// redactURLCredentials masks the userinfo of any URL inside s.
+func redactURLCredentials(s string) string {
+	if !strings.Contains(s, "://") {
+		return s
+	}
+	if u, err := url.Parse(s); err == nil && u.User != nil {
+		u.User = url.User("REDACTED")
+		return u.String()
+	}
+	return urlCredentialsPattern.ReplaceAllString(s, "://REDACTED@")
+}
+
+// runRerunCommand implements `profilesearch rerun -from output.csv`: it reads
+// the run info block of a previous output, CSV, JSON, JSONL, XLSX, or HTML
+// report, and runs the same search again.
+// Arguments after the flags override the recorded settings.
+func runRerunCommand(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("rerun", flag.ExitOnError)
+	from := fs.String("from", "", "output of a previous run: CSV, JSON, JSONL, XLSX, or HTML report")
+	fs.Parse(args)
+	if *from == "" {
+		return errors.New("rerun needs -from")
+	}
+
+	ri, err := readRunInfo(*from)
+	if err != nil {
+		return err
+	}
+	if ri.Engine != "" && ri.Engine != "google" {
+		return fmt.Errorf("cannot rerun a search made with engine %q", ri.Engine)
+	}
+	fmt.Printf("Re-running search from %s (version %s, %s)\n", *from, ri.Version, ri.Timestamp.Format(time.RFC3339))
+
+	rerunArgs := []string{
+		"-keywords", ri.Criteria.Keywords,
+		"-location", ri.Criteria.Location,
+		"-industry", ri.Criteria.Industry,
+		"-experience", ri.Criteria.ExperienceRange,
+		"-current-company", ri.Criteria.CurrentCompany,
+		"-loose-keywords=" + strconv.FormatBool(ri.Criteria.LooseKeywords),
+		"-exclude-keywords", ri.Criteria.ExcludeKeywords,
+	}
+	if ri.MaxPages > 0 {
+		rerunArgs = append(rerunArgs, "-max-pages", strconv.Itoa(ri.MaxPages))
+	}
+	return runSearchCommand(ctx, append(rerunArgs, fs.Args()...))
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Values of -sample-stratify.
+const (
+	stratifyNone       = ""
+	stratifyExperience = "experience"
+)
+
+// experienceBandLimits are the upper bounds, in years, of the experience
+// bands used for stratified sampling; a last band holds everyone above.
+var experienceBandLimits = []int{2, 5, 10, 15}
+
+// experienceBand names the band of a candidate's parsed experience. Unknown
+// experience is a band of its own.
+func experienceBand(years float64) string {
+	if years <= 0 {
+		return "unknown"
+	}
+	low := 1
+	for _, limit := range experienceBandLimits {
+		// Bands are of completed years, so 5.5 is in 3-5.
+		if legacyExperience(years) <= limit {
+			return fmt.Sprintf("%d-%d", low, limit)
+		}
+		low = limit + 1
+	}
+	return fmt.Sprintf("%d+", low)
+}
+
+// sampler selects a uniform random share of the candidates discovered across
+// a run, so that only the sample is enriched and written. Within each stratum
+// the number selected tracks rate times the number seen, starting from a
+// random offset, so the sample stays proportional however the candidates
+// arrive page by page. A nil sampler keeps every candidate.
+type sampler struct {
+	rate     float64
+	stratify string
+	known    map[string]bool // Profile URLs already in the store, never sampled.
+
+	mu       sync.Mutex
+	rng      *rand.Rand
+	offsets  map[string]float64 // Random start in [0, 1) per stratum.
+	seen     map[string]int
+	selected map[string]int
+}
+
+// newSampler returns a sampler keeping rate of the candidates, or nil when
+// rate is 0. A seed of 0 picks one from the clock, which is logged so the
+// sample can be reproduced.
+func newSampler(rate float64, seed int64, stratify string, known map[string]bool) *sampler {
+	if rate <= 0 {
+		return nil
+	}
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+		fmt.Printf("Sampling %.0f%% of candidates with -seed %d\n", 100*rate, seed)
+	}
+	return &sampler{
+		rate: rate, stratify: stratify, known: known,
+		rng:      rand.New(rand.NewSource(seed)),
+		offsets:  make(map[string]float64),
+		seen:     make(map[string]int),
+		selected: make(map[string]int),
+	}
+}
+
+// stratum returns the stratum of c.
+func (s *sampler) stratum(c Candidate) string {
+	if s.stratify == stratifyExperience {
+		return experienceBand(c.experienceYears())
+	}
+	return ""
+}
+
+// sample returns the candidates of one page selected for the sample, in
+// their original order, and records the counts in stats. Candidates already
+// in the store are dropped without using up the sample.
+func (s *sampler) sample(candidates []Candidate) []Candidate {
+	if s == nil {
+		return candidates
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	byStratum := make(map[string][]int)
+	var strata []string // In order of first appearance, for a deterministic draw.
+	known := 0
+	for i, c := range candidates {
+		if s.known[c.ProfileURL] {
+			known++
+			continue
+		}
+		key := s.stratum(c)
+		if _, ok := byStratum[key]; !ok {
+			strata = append(strata, key)
+		}
+		byStratum[key] = append(byStratum[key], i)
+	}
+
+	keep := make([]bool, len(candidates))
+	for _, key := range strata {
+		indexes := byStratum[key]
+		offset, ok := s.offsets[key]
+		if !ok {
+			offset = s.rng.Float64()
+			s.offsets[key] = offset
+		}
+		s.seen[key] += len(indexes)
+		take := int(s.rate*float64(s.seen[key])+offset) - s.selected[key]
+		take = min(max(take, 0), len(indexes))
+		s.selected[key] += take
+		s.rng.Shuffle(len(indexes), func(i, j int) { indexes[i], indexes[j] = indexes[j], indexes[i] })
+		for _, i := range indexes[:take] {
+			keep[i] = true
+		}
+	}
+
+	var sampled []Candidate
+	for i, c := range candidates {
+		if keep[i] {
+			sampled = append(sampled, c)
+		}
+	}
+	stats.countSample(len(candidates)-known, known, len(sampled))
+	return sampled
+}
+
+// report prints how the sample relates to what was discovered, so that counts
+// from it can be extrapolated.
+func (s *sampler) report() {
+	if s == nil {
+		return
+	}
+	from, known, sampled := stats.sample()
+	fmt.Printf("Sampled %d of %d new candidates discovered (%d already in the store were skipped)\n", sampled, from, known)
+	if sampled > 0 {
+		fmt.Printf("Each sampled candidate stands for %.2f discovered\n", float64(from)/float64(sampled))
+	}
+}
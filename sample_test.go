@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// samplePages returns n candidates in pages of 10, each with the experience
+// years(i) gives the i-th.
+func samplePages(n int, years func(i int) float64) [][]Candidate {
+	var pages [][]Candidate
+	for i := 0; i < n; i++ {
+		if i%10 == 0 {
+			pages = append(pages, nil)
+		}
+		c := Candidate{ProfileURL: fmt.Sprintf("https://www.linkedin.com/in/member-%d", i), ExperienceYears: years(i)}
+		pages[len(pages)-1] = append(pages[len(pages)-1], c)
+	}
+	return pages
+}
+
+// runSampler samples every page with s and returns the profile URLs kept.
+func runSampler(s *sampler, pages [][]Candidate) []string {
+	var kept []string
+	for _, page := range pages {
+		for _, c := range s.sample(page) {
+			kept = append(kept, c.ProfileURL)
+		}
+	}
+	return kept
+}
+
+func noExperience(int) float64 { return 0 }
+
+func TestExperienceBand(t *testing.T) {
+	for years, want := range map[float64]string{0: "unknown", 1: "1-2", 2.9: "1-2", 5.5: "3-5", 10: "6-10", 15: "11-15", 22: "16+"} {
+		if got := experienceBand(years); got != want {
+			t.Errorf("experienceBand(%v) = %s, want %s", years, got, want)
+		}
+	}
+}
+
+func TestSampleDeterministicBySeed(t *testing.T) {
+	stats = newRunStats()
+	pages := samplePages(200, noExperience)
+	a := runSampler(newSampler(0.25, 42, stratifyNone, nil), pages)
+	b := runSampler(newSampler(0.25, 42, stratifyNone, nil), pages)
+	if !reflect.DeepEqual(a, b) {
+		t.Error("one seed drew two different samples")
+	}
+	if c := runSampler(newSampler(0.25, 43, stratifyNone, nil), pages); reflect.DeepEqual(a, c) {
+		t.Error("two seeds drew the same sample")
+	}
+	if len(a) != 50 {
+		t.Errorf("sampled %d of 200 at 0.25, want 50", len(a))
+	}
+}
+
+func TestSampleIsUniform(t *testing.T) {
+	stats = newRunStats()
+	// Over many seeds, every position of every page is picked about as often.
+	const seeds, rate = 2000, 0.25
+	pages := samplePages(40, noExperience)
+	picked := make(map[string]int)
+	for seed := int64(1); seed <= seeds; seed++ {
+		for _, u := range runSampler(newSampler(rate, seed, stratifyNone, nil), pages) {
+			picked[u]++
+		}
+	}
+	// Four standard deviations of a binomial draw: with 40 positions, three
+	// would flag a fair sampler too often.
+	tolerance := 4 * math.Sqrt(seeds*rate*(1-rate))
+	for _, page := range pages {
+		for _, c := range page {
+			if n := float64(picked[c.ProfileURL]); math.Abs(n-seeds*rate) > tolerance {
+				t.Errorf("%s picked %v times of %d, want %v±%.0f", c.ProfileURL, n, seeds, seeds*rate, tolerance)
+			}
+		}
+	}
+}
+
+func TestSampleStratifiedProportions(t *testing.T) {
+	stats = newRunStats()
+	// Bands of 600, 300, and 100 candidates, interleaved across pages.
+	years := func(i int) float64 {
+		switch {
+		case i%10 < 6:
+			return 1
+		case i%10 < 9:
+			return 8
+		}
+		return 20
+	}
+	pages := samplePages(1000, years)
+	for seed := int64(1); seed <= 20; seed++ {
+		s := newSampler(0.1, seed, stratifyExperience, nil)
+		perBand := make(map[string]int)
+		for _, page := range pages {
+			for _, c := range s.sample(page) {
+				perBand[experienceBand(c.ExperienceYears)]++
+			}
+		}
+		for band, want := range map[string]int{"1-2": 60, "6-10": 30, "16+": 10} {
+			if got := perBand[band]; got < want-1 || got > want+1 {
+				t.Errorf("seed %d: %d sampled from band %s, want %d±1", seed, got, band, want)
+			}
+		}
+	}
+}
+
+func TestSampleSkipsKnownCandidates(t *testing.T) {
+	stats = newRunStats()
+	pages := samplePages(100, noExperience)
+	known := make(map[string]bool)
+	for _, page := range pages {
+		for _, c := range page[:5] {
+			known[c.ProfileURL] = true
+		}
+	}
+	kept := runSampler(newSampler(0.5, 7, stratifyNone, known), pages)
+	for _, u := range kept {
+		if known[u] {
+			t.Errorf("stored candidate %s sampled", u)
+		}
+	}
+	// The sample is of the 50 new candidates, not the 100 discovered.
+	if len(kept) != 25 {
+		t.Errorf("sampled %d, want half of the 50 new candidates", len(kept))
+	}
+	if from, skipped, sampled := stats.sample(); from != 50 || skipped != 50 || sampled != 25 {
+		t.Errorf("stats sampled %d from %d with %d known, want 25 from 50 with 50 known", sampled, from, skipped)
+	}
+
+	var none *sampler
+	if got := none.sample(pages[0]); len(got) != len(pages[0]) {
+		t.Error("a nil sampler dropped candidates")
+	}
+}
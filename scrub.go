@@ -0,0 +1,341 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"math/rand"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Generated names are drawn from these lists, first name then last name, so
+// fixtures read like real pages without naming anyone in them.
+var (
+	fakeFirstNames = []string{"Asha", "Bruno", "Carla", "Dev", "Elena", "Farid", "Greta", "Hiro", "Ines", "Jonas", "Kavya", "Liam", "Mira", "Nikhil", "Olga", "Pablo"}
+	fakeLastNames  = []string{"Arden", "Brook", "Castell", "Dunmore", "Eskil", "Fenwick", "Galloway", "Holt", "Iverson", "Jarrow", "Kestrel", "Lindqvist", "Marsh", "Norcott", "Orrin", "Pellew"}
+)
+
+// trackingParams are query parameters dropped from link URLs: Google's click
+// tracking and the usual campaign and referral tags.
+var trackingParams = map[string]bool{
+	"ved": true, "ei": true, "sa": true, "usg": true, "sxsrf": true, "oq": true, "gs_lcp": true,
+	"sclient": true, "uact": true, "gclid": true, "fbclid": true, "trk": true, "trackingId": true, "lipi": true,
+}
+
+// urlAttributes are the attributes whose values are URLs.
+var urlAttributes = map[string]bool{"href": true, "src": true, "action": true, "data-href": true}
+
+var profileSlugRef = regexp.MustCompile(`(linkedin\.com/in/)([^/?&#"'\s<>]+)`)
+
+// fixtureScrubber rewrites the personal data of one captured page. Every
+// replacement is remembered, so a name or address reads the same wherever it
+// appears on the page.
+type fixtureScrubber struct {
+	rng     *rand.Rand
+	names   map[string]string // Lower-cased name token to generated token.
+	slugs   map[string]string
+	emails  map[string]string
+	phones  map[string]string
+	people  int            // Generated names handed out so far.
+	renamer *regexp.Regexp // Matches any name token as a whole word.
+}
+
+func newFixtureScrubber(seed int64) *fixtureScrubber {
+	return &fixtureScrubber{
+		rng:    rand.New(rand.NewSource(seed)),
+		names:  make(map[string]string),
+		slugs:  make(map[string]string),
+		emails: make(map[string]string),
+		phones: make(map[string]string),
+	}
+}
+
+// pageNames finds the names of the people on a page with the scraper's own
+// extraction: the result names of a search page, and the heading and
+// og:title of a profile page.
+func pageNames(doc *goquery.Document) []string {
+	var names []string
+	candidates, _ := scrapeGoogleSearchResults(doc)
+	for _, c := range candidates {
+		names = append(names, resultName(c))
+	}
+	names = append(names, strings.TrimSpace(doc.Find(".top-card-layout__title").First().Text()))
+	if title, ok := doc.Find(`meta[property="og:title"]`).Attr("content"); ok {
+		names = append(names, resultName(Candidate{ResultTitle: title}))
+	}
+	return names
+}
+
+// addPerson assigns a generated name to each token of name. The first token
+// becomes a first name and the rest last names.
+func (s *fixtureScrubber) addPerson(name string) {
+	tokens := strings.Fields(name)
+	if len(tokens) == 0 {
+		return
+	}
+	n := s.people
+	s.people++
+	for i, t := range tokens {
+		t = strings.ToLower(strings.Trim(t, ".,()"))
+		if len([]rune(t)) < 2 || s.names[t] != "" {
+			continue
+		}
+		if i == 0 {
+			s.names[t] = fakeFirstNames[n%len(fakeFirstNames)]
+		} else {
+			s.names[t] = fakeLastNames[(n+i-1)%len(fakeLastNames)]
+		}
+	}
+}
+
+// buildRenamer prepares the matcher of name tokens, longest first so a token
+// is never replaced inside a longer one. Matching ignores case, since
+// headings and slugs change it.
+func (s *fixtureScrubber) buildRenamer() {
+	var tokens []string
+	for t := range s.names {
+		tokens = append(tokens, regexp.QuoteMeta(t))
+	}
+	sort.Slice(tokens, func(i, j int) bool {
+		if len(tokens[i]) != len(tokens[j]) {
+			return len(tokens[i]) > len(tokens[j])
+		}
+		return tokens[i] < tokens[j]
+	})
+	if len(tokens) > 0 {
+		s.renamer = regexp.MustCompile(`(?i)\b(?:` + strings.Join(tokens, "|") + `)\b`)
+	}
+}
+
+// rename replaces a matched name token, in the case it was written in.
+func (s *fixtureScrubber) rename(token string) string {
+	fake := s.names[strings.ToLower(token)]
+	switch token {
+	case strings.ToUpper(token):
+		return strings.ToUpper(fake)
+	case strings.ToLower(token):
+		return strings.ToLower(fake)
+	}
+	return fake
+}
+
+// scramble replaces every letter and digit of value with a different one of
+// the same kind and case, keeping punctuation, so the result has the
+// original's format.
+func (s *fixtureScrubber) scramble(value string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= '0' && r <= '9':
+			return '0' + (r-'0'+1+rune(s.rng.Intn(9)))%10
+		case r >= 'a' && r <= 'z':
+			return 'a' + (r-'a'+1+rune(s.rng.Intn(25)))%26
+		case r >= 'A' && r <= 'Z':
+			return 'A' + (r-'A'+1+rune(s.rng.Intn(25)))%26
+		}
+		return r
+	}, value)
+}
+
+// fakeEmail keeps the shape of an address and its top-level domain.
+func (s *fixtureScrubber) fakeEmail(email string) string {
+	if fake, ok := s.emails[email]; ok {
+		return fake
+	}
+	local, domain, _ := strings.Cut(email, "@")
+	tld := ""
+	if i := strings.LastIndex(domain, "."); i >= 0 {
+		domain, tld = domain[:i], domain[i:]
+	}
+	fake := s.scramble(local) + "@" + s.scramble(domain) + tld
+	s.emails[email] = fake
+	return fake
+}
+
+func (s *fixtureScrubber) fakePhone(phone string) string {
+	if fake, ok := s.phones[phone]; ok {
+		return fake
+	}
+	fake := s.scramble(phone)
+	s.phones[phone] = fake
+	return fake
+}
+
+// fakeSlug returns a slug made of a generated name and a serial number.
+func (s *fixtureScrubber) fakeSlug(slug string) string {
+	if fake, ok := s.slugs[slug]; ok {
+		return fake
+	}
+	n := len(s.slugs)
+	fake := fmt.Sprintf("%s-%s-%04x", strings.ToLower(fakeFirstNames[n%len(fakeFirstNames)]), strings.ToLower(fakeLastNames[(n/len(fakeFirstNames))%len(fakeLastNames)]), 0x1a2b+n)
+	s.slugs[slug] = fake
+	return fake
+}
+
+// scrubText rewrites slugs, then addresses, then phone numbers, then names,
+// so that a name inside a slug or address goes with it.
+func (s *fixtureScrubber) scrubText(text string) string {
+	text = profileSlugRef.ReplaceAllStringFunc(text, func(m string) string {
+		sub := profileSlugRef.FindStringSubmatch(m)
+		return sub[1] + s.fakeSlug(sub[2])
+	})
+	text = emailMatcher.ReplaceAllStringFunc(text, s.fakeEmail)
+	text = phoneMatcher.ReplaceAllStringFunc(text, s.fakePhone)
+	if s.renamer == nil {
+		return text
+	}
+	return s.renamer.ReplaceAllStringFunc(text, s.rename)
+}
+
+// scrubURL drops tracking parameters from a URL and scrubs the rest of it,
+// including parameters such as Google's q= that wrap another URL. The query
+// keeps its original encoding, since the scraper matches links as written.
+func (s *fixtureScrubber) scrubURL(raw string) string {
+	base, query, ok := strings.Cut(raw, "?")
+	if !ok {
+		return s.scrubText(raw)
+	}
+	query, fragment, hasFragment := strings.Cut(query, "#")
+	var kept []string
+	for _, pair := range strings.Split(query, "&") {
+		key, value, _ := strings.Cut(pair, "=")
+		if trackingParams[key] || strings.HasPrefix(key, "utm_") {
+			continue
+		}
+		// An encoded URL only shows its slug once decoded.
+		if decoded, err := url.QueryUnescape(value); err == nil && decoded != value {
+			value = url.QueryEscape(s.scrubText(decoded))
+		} else {
+			value = s.scrubText(value)
+		}
+		if strings.Contains(pair, "=") {
+			pair = key + "=" + value
+		}
+		kept = append(kept, pair)
+	}
+	scrubbed := s.scrubText(base)
+	if len(kept) > 0 {
+		scrubbed += "?" + strings.Join(kept, "&")
+	}
+	if hasFragment {
+		scrubbed += "#" + s.scrubText(fragment)
+	}
+	return scrubbed
+}
+
+// scrub rewrites the personal data of doc in place: the text, comments, and
+// attribute values of every node. Script bodies are emptied. Elements,
+// their order, and their class names are left exactly as they were, so
+// selectors match the scrubbed page as they matched the original.
+func (s *fixtureScrubber) scrub(doc *goquery.Document) {
+	for _, name := range pageNames(doc) {
+		s.addPerson(name)
+	}
+	s.buildRenamer()
+
+	doc.Find("script").Empty()
+	doc.Find("*").Each(func(_ int, sel *goquery.Selection) {
+		node := sel.Get(0)
+		for i, a := range node.Attr {
+			switch {
+			case a.Key == "class" || a.Key == "id":
+				// Selectors depend on these.
+			case urlAttributes[a.Key]:
+				node.Attr[i].Val = s.scrubURL(a.Val)
+			default:
+				node.Attr[i].Val = s.scrubText(a.Val)
+			}
+		}
+		sel.Contents().Each(func(_ int, child *goquery.Selection) {
+			if name := goquery.NodeName(child); name == "#text" || name == "#comment" {
+				n := child.Get(0)
+				n.Data = s.scrubText(n.Data)
+			}
+		})
+	})
+}
+
+// survivors returns the original emails, phone numbers, and slugs that still
+// appear in page, which a complete scrub leaves none of.
+func (s *fixtureScrubber) survivors(page string) []string {
+	var left []string
+	for _, m := range []map[string]string{s.emails, s.phones} {
+		for original := range m {
+			if strings.Contains(page, original) {
+				left = append(left, original)
+			}
+		}
+	}
+	for slug := range s.slugs {
+		if strings.Contains(page, "linkedin.com/in/"+slug) {
+			left = append(left, slug)
+		}
+	}
+	sort.Strings(left)
+	return left
+}
+
+// scrubFixture reads a captured page from in and returns it with its personal
+// data replaced. It fails if scrubbing changed how many search results the
+// scraper extracts, or left any of the original emails, phone numbers, or
+// slugs behind.
+func scrubFixture(in []byte, seed int64) ([]byte, error) {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(in))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse page: %w", err)
+	}
+	before, _ := scrapeGoogleSearchResults(doc)
+
+	s := newFixtureScrubber(seed)
+	s.scrub(doc)
+	page, err := doc.Html()
+	if err != nil {
+		return nil, fmt.Errorf("failed to render page: %w", err)
+	}
+
+	out, err := goquery.NewDocumentFromReader(strings.NewReader(page))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse scrubbed page: %w", err)
+	}
+	if after, _ := scrapeGoogleSearchResults(out); len(after) != len(before) {
+		return nil, fmt.Errorf("scrubbed page yields %d results instead of %d", len(after), len(before))
+	}
+	if left := s.survivors(page); len(left) > 0 {
+		return nil, fmt.Errorf("%d original values survived scrubbing, e.g. %q", len(left), left[0])
+	}
+	return []byte(page), nil
+}
+
+// runScrubFixtureCommand writes an anonymized copy of a captured page for use
+// as a test fixture.
+func runScrubFixtureCommand(args []string) error {
+	fs := flag.NewFlagSet("scrub-fixture", flag.ExitOnError)
+	seed := fs.Int64("seed", 1, "seed of the generated values; the same seed scrubs a page the same way")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: profilesearch scrub-fixture [-seed n] in.html out.html")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return fmt.Errorf("scrub-fixture needs an input and an output file")
+	}
+	in, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("failed to read page: %w", err)
+	}
+	out, err := scrubFixture(in, *seed)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(fs.Arg(1), out, 0o644); err != nil {
+		return fmt.Errorf("failed to write fixture: %w", err)
+	}
+	fmt.Printf("Wrote scrubbed fixture to %s\n", fs.Arg(1))
+	return nil
+}
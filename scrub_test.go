@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// capturedSERP is a results page as captured, with the personal data a
+// fixture must not keep.
+const capturedSERP = `<html><head><script>var user = "jane.doe@emerson.com";</script></head><body>
+<div class="tF2Cxc"><a href="https://www.linkedin.com/in/jane-doe-4821?trk=public_profile&amp;utm_source=share"><h3>Jane Doe - Valve Engineer - Emerson | LinkedIn</h3></a>
+<div class="VwiC3b yXK7lf MUxGbd yDYNvb lyLwlc lEBKkf">Jane Doe is a valve engineer in Bangalore. Contact: jane.doe@emerson.com, +91 98450 12345.</div></div>
+<div class="tF2Cxc"><a href="https://in.linkedin.com/in/rahul-sharma"><h3>Rahul Sharma - Design Lead | LinkedIn</h3></a>
+<div class="VwiC3b yXK7lf MUxGbd yDYNvb lyLwlc lEBKkf">Rahul Sharma leads design. Ask Jane or RAHUL.</div></div>
+<a class="fl" href="/url?q=https%3A%2F%2Fwww.linkedin.com%2Fin%2Fjane-doe-4821&amp;ved=2ahUKEwi&amp;sa=U">More</a>
+</body></html>`
+
+func scrubbedDoc(t *testing.T, page []byte) *goquery.Document {
+	t.Helper()
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(page))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func TestScrubFixtureRemovesPersonalData(t *testing.T) {
+	out, err := scrubFixture([]byte(capturedSERP), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	page := string(out)
+	for _, original := range []string{"jane.doe@emerson.com", "98450 12345", "jane-doe-4821", "rahul-sharma", "Jane", "Doe", "Rahul", "RAHUL", "Sharma", "trk=", "utm_source", "ved=", "var user"} {
+		if strings.Contains(page, original) {
+			t.Errorf("scrubbed page still contains %q:\n%s", original, page)
+		}
+	}
+	// Structure, class names, and what is not personal are kept.
+	for _, kept := range []string{`class="VwiC3b yXK7lf MUxGbd yDYNvb lyLwlc lEBKkf"`, `class="fl"`, "Valve Engineer", "Emerson | LinkedIn", "Bangalore", "<script></script>"} {
+		if !strings.Contains(page, kept) {
+			t.Errorf("scrubbed page lost %q:\n%s", kept, page)
+		}
+	}
+
+	before, _ := scrapeGoogleSearchResults(scrubbedDoc(t, []byte(capturedSERP)))
+	after, _ := scrapeGoogleSearchResults(scrubbedDoc(t, out))
+	if len(after) != len(before) || len(after) != 2 {
+		t.Fatalf("%d results extracted after scrubbing, %d before", len(after), len(before))
+	}
+	// A person's generated name is the same in the title and the snippet.
+	name := resultName(after[0])
+	if !strings.HasPrefix(after[0].Snippet, name+" is a valve engineer") {
+		t.Errorf("title names %q but snippet reads %q", name, after[0].Snippet)
+	}
+	if email := emailMatcher.FindString(after[0].Snippet); len(email) != len("jane.doe@emerson.com") || !strings.HasSuffix(email, ".com") {
+		t.Errorf("snippet %q lost the email's format", after[0].Snippet)
+	}
+	// So is a slug, wherever it appears, even URL-encoded.
+	slug := strings.TrimPrefix(after[0].ProfileURL, "https://www.linkedin.com/in/")
+	if !strings.Contains(page, "%2Fin%2F"+slug+`"`) {
+		t.Errorf("the redirect link does not carry the profile's slug %s:\n%s", slug, page)
+	}
+}
+
+func TestScrubFixtureIsDeterministic(t *testing.T) {
+	a, err := scrubFixture([]byte(capturedSERP), 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := scrubFixture([]byte(capturedSERP), 7)
+	if !bytes.Equal(a, b) {
+		t.Error("one seed scrubbed a page two ways")
+	}
+}
+
+func TestScrubFixtureKeepsResultCount(t *testing.T) {
+	page := readFixture(t, "serp-100.html")
+	out, err := scrubFixture(page, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(parseResultsFixture(t, out)); got != 100 {
+		t.Errorf("%d results extracted from the scrubbed serp-100.html, want 100", got)
+	}
+}
+
+func TestScrubFormatsMatch(t *testing.T) {
+	s := newFixtureScrubber(1)
+	if got := s.fakePhone("+91 98450-12345"); len(got) != len("+91 98450-12345") || got[0] != '+' || got[3] != ' ' || got[9] != '-' || got == "+91 98450-12345" {
+		t.Errorf("phone scrubbed to %q, want another number of the same format", got)
+	}
+	if got := s.fakeEmail("Jane.Doe@emerson.co.in"); !strings.HasSuffix(got, ".in") || strings.Index(got, ".") != 4 || got == "Jane.Doe@emerson.co.in" {
+		t.Errorf("email scrubbed to %q, want another address of the same format", got)
+	}
+	if s.fakeSlug("jane-doe") != s.fakeSlug("jane-doe") || s.fakeSlug("jane-doe") == s.fakeSlug("john-roe") {
+		t.Error("slugs not replaced consistently")
+	}
+}
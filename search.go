@@ -1,341 +1,2164 @@
 package main
 
 import (
-	"encoding/csv"
+	"bytes"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
-	"math/rand"
 	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"text/template"
 	"time"
 
 	"github.com/PuerkitoBio/goquery"
+
+	"checker/transform"
 )
 
 // --- Constants ---
 const (
-	// Use a clean base URL.
-	googleSearchURLBase   = "https://www.google.com/search"
-	maxPagesToScrape      = 2 // Keep it VERY low to avoid being blocked
+	maxPagesToScrape      = 2   // Keep it VERY low to avoid being blocked
+	resultsPerPage        = 10  // Google's default page size; -max-pages counts pages of this size
+	singlePageNum         = 100 // The most results Google serves on one page, requested by -single-page
 	retryAttempts         = 3
-	retryDelay            = 5 * time.Second
-	nameSelector          = ".e2BEnf.hAyfcb .AP7Wnd"             // Selector for name (needs refining)
-	profileLinkSelector   = "a[href*='linkedin.com/in/']"         // Robust profile link selector
+	nameSelector          = ".e2BEnf.hAyfcb .AP7Wnd"                     // Selector for name (needs refining)
+	profileLinkSelector   = "a[href*='linkedin.com/in/']"                // Robust profile link selector
+	resultTitleSelector   = "h3"                                         // Selector for the result title
+	resultStatsSelector   = "#result-stats"                              // Selector for "About X results"
 	googleSnippetSelector = ".VwiC3b.yXK7lf.MUxGbd.yDYNvb.lyLwlc.lEBKkf" // Selector for Google snippet
 
+	googleCardSelector         = "g-scrolling-carousel g-inner-card, .dZfnKc" // Profile cards of the carousel and the people panel
+	googleCardNameSelector     = "[role='heading']"                           // Selector for the name on a card
+	googleCardHeadlineSelector = ".zz3gNc"                                    // Selector for the headline on a card
+
 	// Regex patterns
 	emailRegex      = `[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}`
 	phoneRegex      = `\(?\d{3}\)?[-.\s]?\d{3}[-.\s]?\d{4}` // Basic US phone number regex (adapt as needed)
-	experienceRegex = `(\d+)\s+year[s]?`                     // Regex to extract experience in years
+	experienceRegex = `(\d+)\+?\s*(?:years?|yrs?)\b`        // Regex to extract experience in years
 
 	outputFilename = "linkedin_candidates.csv" // CSV output filename
 )
 
+// retryDelay is the wait between attempts at a results page. It is a
+// variable so that tests of blocked runs need not wait it out.
+var retryDelay = 5 * time.Second
+
+// How Google showed a result, the ResultType of its candidate.
+const (
+	resultTypeOrganic = "organic" // A regular result, with a title and snippet.
+	resultTypeCard    = "card"    // A card of a profiles carousel or people panel.
+)
+
 // --- Structs ---
 type Candidate struct {
 	Name       string `json:"name"`
 	Email      string `json:"email"`
 	Phone      string `json:"phone"`
 	ProfileURL string `json:"profile_url"`
-	Experience int    `json:"experience"` // Experience in years, if found
+	Experience int    `json:"experience"` // Deprecated: ExperienceYears in whole years, rounded down; read experienceYears instead
+	Rank       int    `json:"rank"`       // Position in discovery order across all result pages, from 1
+	// Where Google showed the result, from 1: on its page, and across the
+	// pages of its query. Unlike Rank, they count results that were dropped
+	// as duplicates, so they match the positions Google serves.
+	PagePosition    int    `json:"page_position,omitempty"`
+	OverallPosition int    `json:"overall_position,omitempty"`
+	Job             string `json:"job,omitempty"`         // Name of the job that found the candidate, in batch runs
+	Title           string `json:"title,omitempty"`       // Current job title, from the profile's experience section
+	Company         string `json:"company,omitempty"`     // Current company, from the profile or else the result title
+	EmailGuess      string `json:"email_guess,omitempty"` // Guessed first.last address, when no email was found
+	Website         string `json:"website,omitempty"`
+	Twitter         string `json:"twitter,omitempty"`
+
+	Location string `json:"location,omitempty"` // As written on the profile or in the snippet
+	City     string `json:"city,omitempty"`     // Normalized from Location, e.g. "Bengaluru"
+	State    string `json:"state,omitempty"`
+	Country  string `json:"country,omitempty"` // ISO code, e.g. "IN"
+
+	ProfileLanguage  string `json:"profile_language,omitempty"`   // ISO 639-1 code detected from the candidate's own text, e.g. "de"
+	PageLanguage     string `json:"page_language,omitempty"`      // ISO 639-1 code the profile page declares, e.g. "hi"
+	NameSlugMismatch bool   `json:"name_slug_mismatch,omitempty"` // The name shares nothing with the profile URL's slug
+	Anonymized       bool   `json:"anonymized,omitempty"`         // A "LinkedIn Member" result hiding the member's name
+	ResultType       string `json:"result_type,omitempty"`        // resultTypeOrganic or resultTypeCard: how Google showed the result
+
+	AlternateURLs []string `json:"alternate_urls,omitempty"` // URLs of mirrors of the profile merged into this record
+
+	MatchedContactedBy string `json:"matched_contacted_by,omitempty"` // email, url, or phone when the -contacted export lists the candidate
+	OptOutURL          string `json:"optout_url,omitempty"`           // Signed link for outreach emails to let the candidate opt out, with -optout-base-url
+
+	ExperienceYears float64 `json:"experience_years,omitempty"` // Experience in years, to tenths, if found; set with setExperienceYears
+
+	Rediscovered   bool       `json:"rediscovered,omitempty"`    // Found again by an -incremental run after -seen-ttl expired
+	PreviouslySeen *time.Time `json:"previously_seen,omitempty"` // Prior sighting of a Rediscovered candidate; nil otherwise
+
+	CompanySizeBand string `json:"company_size_band,omitempty"` // One of companySizeBands, e.g. "51-200"
+	CompanyType     string `json:"company_type,omitempty"`      // e.g. public, private, self-employed
+	EmploymentMatch string `json:"employment_match,omitempty"`  // current or past, for -current-company searches
+	RelaxationLevel int    `json:"relaxation_level,omitempty"`  // How far -min-results relaxed the query that found the candidate
+
+	PhotoURL            string     `json:"photo_url,omitempty"`
+	Headline            string     `json:"headline,omitempty"`
+	SkillsCount         int        `json:"skills_count,omitempty"`
+	HasEducation        bool       `json:"has_education,omitempty"`
+	Connections         int        `json:"connections,omitempty"` // Connections or followers, when shown
+	Positions           []Position `json:"positions,omitempty"`   // Experience history, most recent first
+	ProfileCompleteness int        `json:"profile_completeness"`  // 0 for a ghost profile to 100
+
+	ResultTitle  string   `json:"result_title,omitempty"`  // Google result title, e.g. "Name - Title - Company | LinkedIn"
+	Snippet      string   `json:"snippet,omitempty"`       // Google result snippet
+	Summary      string   `json:"summary,omitempty"`       // Profile About text, truncated to maxSummaryLength
+	MatchedTerms []string `json:"matched_terms,omitempty"` // Search keywords found in the snippet or summary
+
+	Extra map[string]string `json:"extra,omitempty"` // Fields found by -extractors, by name
+	Score int               `json:"score"`
+
+	LookupScore float64 `json:"lookup_score,omitempty"` // Similarity to the requested person, in lookup mode
+	LookupMatch string  `json:"lookup_match,omitempty"` // top, ambiguous, or alternate, in lookup mode
+
+	Sources map[string]string `json:"-"` // Field to the extractor that filled it, for -field-sources
+}
+
+// SearchCriteria describes a single LinkedIn profile search.
+type SearchCriteria struct {
+	Keywords        string `yaml:"keywords" json:"keywords"`
+	Location        string `yaml:"location" json:"location"`
+	Industry        string `yaml:"industry" json:"industry"`
+	ExperienceRange string `yaml:"experience" json:"experience"`
+	CurrentCompany  string `yaml:"current_company" json:"current_company,omitempty"`   // Employer the candidate should work at now
+	LooseKeywords   bool   `yaml:"loose_keywords" json:"loose_keywords,omitempty"`     // Search multi-word keywords as loose words, not a phrase
+	ExcludeKeywords string `yaml:"exclude_keywords" json:"exclude_keywords,omitempty"` // Comma-separated terms results must not mention
+	Discriminator   string `yaml:"-" json:"-"`                                         // Narrows a -deep-coverage chunk of the search
+}
+
+// config holds the options resolved from the command line.
+type config struct {
+	criteria   SearchCriteria
+	maxPages   int
+	chunks     []queryChunk // The queries of each search, from -max-pages and -deep-coverage.
+	singlePage bool         // Request singlePageNum results per page instead of paginating by ten.
+	output     string
+	format     string             // formatCSV, formatParquet, formatAtom, or formatTemplate.
+	template   *template.Template // The -template-file, for -format template.
+	feedMax    int                // Entries an Atom feed keeps.
+	jobsFile   string
+	jobsOutput string
+	columns    []csvColumn
+
+	columnsGiven  bool   // -columns was set, rather than defaulted.
+	cleanExisting string // CSV to clean instead of searching.
+
+	jobCooldown     time.Duration
+	jobResetSession bool
+
+	minCandidatesPerPage int
+	maxEmptyPages        int
+	profileOptions       profileOptions
+	profileBlockLimit    int
+
+	filterExperience    bool
+	experienceTolerance int
+	requireEmail        bool
+	dropSlugMismatch    bool
+	blockedNames        *nameBlocklist // Names of junk candidates, from -blocklist-names or the defaults.
+	anonymized          string         // What to do with anonymized results: anonymizedSkip or anonymizedTag.
+	contacted           *contactedSet  // People already contacted, from -contacted.
+	optOut              *optOutSigner  // Builds opt-out links; nil without -optout-base-url.
+	optOuts             *optOutList    // Candidates who opted out, from -optout-list.
+	dropContacted       bool
+	requireLocation     bool
+	minCompleteness     int
+	completenessWeights completenessWeights
+	minScore            int
+	scoreWeights        scoreWeights
+	explain             *jsonLinesLog // Receives every keep/drop decision when -explain is set.
+	explainEnabled      bool
+	fieldSources        *jsonLinesLog // Receives every candidate's field sources when -field-sources is set.
+	fieldSourcesEnabled bool
+	showQuery           bool
+	explainQuery        bool
+	printSchema         bool
+	printConfig         bool
+	engine              string // Query syntax of -show-query; searches always run on Google.
+
+	htmlReport string        // Also write an HTML summary to this file when set.
+	webhooks   []string      // Also deliver candidates to these URLs, through the outbox.
+	outboxPath string        // The outbox journal of -webhook deliveries.
+	outbox     *outboxWorker // Delivers to webhooks; nil without -webhook or -alert-rules.
+	alertRules []alertRule   // Evaluated against the -store as each run saves to it.
+	storePath  string        // Add results to this candidate store when set.
+
+	guessEmails    bool
+	companyDomains companyDomains // From -company-domains; consulted before guessing a domain from the name.
+
+	args      []string // Command-line arguments, recorded in the run info block.
+	noRunInfo bool
+	effective *runConfig    // Every flag's value after -config, for run-config.json.
+	flags     *flag.FlagSet // As parsed, for pack.
+
+	phoneFormat    string
+	phoneRegion    string
+	phoneCountries map[string]bool // -phone-countries; phones of other countries are dropped.
+
+	mirrorThreshold float64
+
+	minCompanySize string
+	maxCompanySize string
+
+	maxIdle        time.Duration
+	watchdog       *idleWatchdog // Set while a run is guarded by -max-idle.
+	stallTimeout   time.Duration
+	stallAction    string
+	stalls         *stallWatchdog // Set while a run is guarded by -stall-timeout or serves -metrics-addr.
+	metricsAddr    string
+	fetcherOptions fetcherOptions
+
+	replayDir     string // Fixtures served instead of fetching.
+	recordDir     string // Fetched pages are saved here for -replay.
+	deterministic bool
+
+	minResults    int
+	maxRelaxation int
+
+	timeWindow        time.Duration // Time the run must finish in, for the feasibility estimate.
+	strictFeasibility bool
+
+	flushEvery int // Stream output, flushing every this many rows, when positive.
+	csv        csvFormat
+	stream     *csvStream // Receives kept candidates as they are found when streaming.
+	outputs    *outputDispatcher
+
+	profileLanguages []string // Wanted -profile-language codes.
+	strictLanguage   bool     // Drop candidates of unknown language too.
+	languageJobs     []Job    // One search per -keywords-lang language, with -keywords-lang-mode split.
+	domainsOut       string
+	excludeFreemail  bool
+	freemailDomains  string
+
+	transforms   transform.Chain // Run on each results page before extraction.
+	resultParser resultParser    // Extracts the candidates of each results page.
+
+	sampleRate     float64
+	sampleSeed     int64
+	sampleStratify string
+	sampler        *sampler // Set when -sample is.
+
+	incrementalEnabled bool
+	seenTTL            dayDuration
+	incremental        *incrementalRun // Set when -incremental is.
+
+	ignoreNegativeCache bool
+
+	runDir string
+
+	shortlinks *shortlinkResolver // Set when -resolve-shortlinks is.
+}
+
+// runInfo returns the run info block for output of a search, or nil when
+// -no-run-info is set.
+func (cfg *config) runInfo(criteria SearchCriteria, job string) *runInfo {
+	if cfg.noRunInfo {
+		return nil
+	}
+	return newRunInfo(cfg, criteria, job)
 }
 
 // buildGoogleSearchURL constructs the Google search URL using the provided criteria.
-func buildGoogleSearchURL(keywords, location, industry, experienceRange string) string {
-	// Build the query string.
-	query := fmt.Sprintf("site:linkedin.com/in %s %s %s %s", keywords, location, industry, experienceRange)
+func buildGoogleSearchURL(c SearchCriteria) string {
 	params := url.Values{}
-	params.Add("q", query)
-	searchURL := googleSearchURLBase + "?" + params.Encode()
+	params.Add("q", renderQuery(engineGoogle, c).Query)
+	searchURL := googleSearchURL() + "?" + params.Encode()
 	return searchURL
 }
 
 // scrapeGoogleSearchResults processes the Google search results page and extracts candidate data.
 func scrapeGoogleSearchResults(doc *goquery.Document) ([]Candidate, error) {
+	return googleResultSelectors.scrape(doc)
+}
+
+// scrape extracts candidate data from a results page, finding the parts of
+// each result with sel: the organic results, then the profile cards of
+// carousels and people panels. A profile with both keeps its organic result,
+// whose snippet says more.
+func (sel resultSelectors) scrape(doc *goquery.Document) ([]Candidate, error) {
 	var candidates []Candidate
+	found := make(map[string]bool)
 
-	doc.Find(".tF2Cxc").Each(func(i int, s *goquery.Selection) {
-		// Get the LinkedIn profile link.
-		profileLink, ok := s.Find(profileLinkSelector).Attr("href")
+	doc.Find(sel.Result).Each(func(i int, s *goquery.Selection) {
+		profileLink, ok := cleanProfileLink(s.Find(sel.Link))
 		if !ok {
 			return
 		}
 
-		// Clean the profile link using regex.
-		re := regexp.MustCompile(`(https:\/\/www\.linkedin\.com\/in\/[^&?]+)`)
-		match := re.FindStringSubmatch(profileLink)
-		if len(match) > 1 {
-			profileLink = match[1]
-		} else {
+		// Extract the name using the specified selector.
+		name := strings.TrimSpace(s.Find(sel.Name).Text())
+		title := strings.TrimSpace(s.Find(sel.Title).First().Text())
+		snippet := s.Find(sel.Snippet).Text()
+
+		found[profileLink] = true
+		candidates = append(candidates, snippetCandidate(resultTypeOrganic, profileLink, name, title, snippet))
+	})
+
+	doc.Find(sel.Card).Each(func(i int, s *goquery.Selection) {
+		profileLink, ok := cleanProfileLink(s.Find(sel.Link).AddSelection(s.Filter(sel.Link)))
+		if !ok || found[profileLink] {
 			return
 		}
-
-		// Extract the name using the specified selector.
-		name := strings.TrimSpace(s.Find(nameSelector).Text())
-
-		// Extract email, phone, and experience from the snippet.
-		snippet := s.Find(googleSnippetSelector).Text()
-		email := extractRegex(snippet, emailRegex)
-		phone := extractRegex(snippet, phoneRegex)
-		experience, _ := parseExperience(snippet)
-
-		candidate := Candidate{
-			Name:       name,
-			ProfileURL: profileLink,
-			Email:      email,
-			Phone:      phone,
-			Experience: experience,
-		}
-		candidates = append(candidates, candidate)
+		name := strings.TrimSpace(s.Find(sel.CardName).First().Text())
+		headline := strings.TrimSpace(s.Find(sel.CardHeadline).First().Text())
+		// The headline, such as "Engineer · Acme · Berlin", stands in for both
+		// the title and the snippet, so the title's slots and the snippet's
+		// patterns read it.
+		title := name
+		for _, part := range strings.Split(headline, "·") {
+			if part = strings.TrimSpace(part); part != "" {
+				title += " - " + part
+			}
+		}
+		found[profileLink] = true
+		candidates = append(candidates, snippetCandidate(resultTypeCard, profileLink, name, title, headline))
 	})
 
 	return candidates, nil
 }
 
-// scrapeProfileDetails visits the LinkedIn profile page to extract additional details.
-func scrapeProfileDetails(profileURL string) (Candidate, error) {
-	var candidate Candidate
-	candidate.ProfileURL = profileURL
+// cleanProfileLink returns the profile URL the first link of links points
+// to, without Google's tracking parameters.
+func cleanProfileLink(links *goquery.Selection) (string, bool) {
+	profileLink, ok := links.First().Attr("href")
+	if !ok {
+		return "", false
+	}
+	match := profileLinkRegex.FindStringSubmatch(profileLink)
+	if len(match) < 2 {
+		return "", false
+	}
+	return match[1], true
+}
 
-	// Use random delay to mimic human behavior.
-	delay := time.Duration(rand.Intn(10)+5) * time.Second // Delay between 5 and 14 seconds.
-	time.Sleep(delay)
-	client := getProxyClient() // Use proxy client if available.
+// snippetCandidate builds the candidate of one search result of resultType,
+// extracting email, phone, experience, and more from its snippet.
+func snippetCandidate(resultType, profileLink, name, title, snippet string) Candidate {
+	experience, _ := parseExperience(snippet) // 0 when not found.
+	candidate := Candidate{
+		Name:        name,
+		ProfileURL:  profileLink,
+		Email:       emailMatcher.FindString(snippet),
+		Phone:       extractPhone(snippet),
+		Snippet:     strings.TrimSpace(snippet),
+		ResultTitle: title,
+		ResultType:  resultType,
+		Location:    extractSnippetLocation(snippet),
+		Company:     titleCompanySlot(title),
 
-	req, err := http.NewRequest("GET", profileURL, nil)
-	if err != nil {
-		return candidate, fmt.Errorf("failed to create request: %w", err)
+		CompanySizeBand: parseCompanySize(snippet),
+		CompanyType:     parseCompanyType(snippet),
+	}
+	candidate.setExperienceYears(experience)
+	candidate.Anonymized = isAnonymizedResult(candidate)
+	candidate.Extra = extractExtra([]byte(snippet))
+	candidate.noteSource("name", candidate.Name, sourceResultName)
+	candidate.noteSource("company", candidate.Company, sourceResultTitle)
+	for _, field := range []string{"email", "phone", "experience", "location", "company_size_band", "company_type"} {
+		candidate.noteSource(field, fieldValue(candidate, field), sourceSnippet)
 	}
+	return candidate
+}
 
-	// Set headers.
-	req.Header = getHeaders()
-	req.Header.Set("Referer", "https://www.google.com/")
+// scrapeProfileDetails visits the LinkedIn profile page to extract additional details.
+func scrapeProfileDetails(ctx context.Context, f Fetcher, profileURL string, opts profileOptions) (Candidate, error) {
+	var candidate Candidate
+	candidate.ProfileURL = profileURL
 
-	resp, err := client.Do(req)
+	body, err := f.Fetch(ctx, profileURL)
 	if err != nil {
-		return candidate, fmt.Errorf("failed to fetch profile: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		if resp.StatusCode == 429 || resp.StatusCode == 302 {
-			log.Println("Encountered potential CAPTCHA or rate limit. Stopping.")
-			return candidate, fmt.Errorf("captcha or rate limit")
+		if errors.Is(err, errBlocked) {
+			log.Println("Encountered potential CAPTCHA or rate limit.")
+			return candidate, err
 		}
-		return candidate, fmt.Errorf("profile request failed with status: %d", resp.StatusCode)
+		return candidate, fmt.Errorf("failed to fetch profile: %w", err)
 	}
 
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	refetch := func(ctx context.Context) ([]byte, error) { return f.Fetch(ctx, profileURL) }
+	err = parseWithRetry(ctx, profileURL, body, refetch, func(body []byte, doc *goquery.Document) error {
+		candidate = parseProfilePage(ctx, f, profileURL, body, doc, opts)
+		return nil
+	})
 	if err != nil {
-		return candidate, fmt.Errorf("failed to parse profile HTML: %w", err)
+		return Candidate{ProfileURL: profileURL}, fmt.Errorf("failed to parse profile HTML: %w", err)
 	}
+	return candidate, nil
+}
+
+// parseProfilePage extracts a candidate from a fetched profile page.
+func parseProfilePage(ctx context.Context, f Fetcher, profileURL string, body []byte, doc *goquery.Document, opts profileOptions) Candidate {
+	candidate := Candidate{ProfileURL: profileURL}
 
 	// For public profiles, the selector might be different.
 	nameSelectorPublic := ".top-card-layout__title" // Example selector (adjust as needed).
 	candidate.Name = strings.TrimSpace(doc.Find(nameSelectorPublic).Text())
+	candidate.Location = strings.TrimSpace(doc.Find(profileLocationSelector).First().Text())
+	candidate.noteSource("name", candidate.Name, sourceProfileMarkup)
+	candidate.noteSource("location", candidate.Location, sourceProfileMarkup)
+	extractProfileSignals(doc, &candidate)
+
+	// The contact-info overlay is structured and most trustworthy. It is only
+	// sometimes embedded in the page; fetching it separately costs a request.
+	ci := parseContactInfo(doc)
+	if ci.empty() && opts.fetchContactInfo {
+		ci = fetchContactInfo(ctx, f, profileURL)
+	}
+	candidate.Website = ci.Website
+	candidate.Twitter = ci.Twitter
+	candidate.CompanySizeBand, candidate.CompanyType = extractCompanyFacts(doc)
+	candidate.Positions = extractPositions(doc)
+	if p, ok := currentPosition(candidate.Positions); ok {
+		candidate.Title, candidate.Company = p.Title, p.Company
+	}
+	if years, ok := experienceFromPositions(candidate.Positions, clockNow()); ok {
+		candidate.setExperienceYears(years)
+		candidate.noteSource("experience", fieldValue(candidate, "experience"), sourcePositionDates)
+	}
+	for _, field := range []string{"website", "twitter"} {
+		candidate.noteSource(field, fieldValue(candidate, field), sourceContactInfo)
+	}
+	for _, field := range []string{"title", "company", "company_size_band", "company_type"} {
+		candidate.noteSource(field, fieldValue(candidate, field), sourceExperience)
+	}
+	if opts.currentCompany != "" {
+		candidate.EmploymentMatch = profileEmployment(doc, opts.currentCompany)
+	}
 
-	// Attempt to extract email and phone via regex from the entire page HTML.
-	html, _ := doc.Html()
-	candidate.Email = extractRegex(html, emailRegex)
-	candidate.Phone = extractRegex(html, phoneRegex)
+	// Contact details written in the About section are more trustworthy than
+	// anything matched elsewhere on the page, so scan it next.
+	var summarySource string
+	candidate.Summary, summarySource = extractSummary(doc)
+	candidate.PageLanguage = pageLanguage(doc)
+	candidate.noteSource("summary", candidate.Summary, summarySource)
+	summaryEmail, summaryPhone, obfuscated := extractContactFromText(candidate.Summary)
+	emailSource := sourceAboutText
+	if obfuscated {
+		emailSource = sourceAboutObfuscated
+	}
+	candidate.Email = firstNonEmpty(ci.Email, summaryEmail)
+	candidate.Phone = firstNonEmpty(ci.Phone, summaryPhone)
+	candidate.noteSource("email", summaryEmail, emailSource)
+	candidate.noteSource("email", ci.Email, sourceContactInfo)
+	candidate.noteSource("phone", summaryPhone, sourceAboutText)
+	candidate.noteSource("phone", ci.Phone, sourceContactInfo)
 
-	return candidate, nil
+	// Fall back to regex over the page HTML, as fetched rather than
+	// re-rendered from the document.
+	region := profileScanRegion(body)
+	candidate.Extra = extractExtra(region)
+	if candidate.Email == "" {
+		candidate.Email = string(emailMatcher.Find(region))
+		candidate.noteSource("email", candidate.Email, sourcePageRegex)
+	}
+	if candidate.Phone == "" {
+		candidate.Phone = extractPhone(string(region))
+		candidate.noteSource("phone", candidate.Phone, sourcePageRegex)
+	}
+	return candidate
 }
 
-// extractRegex extracts a substring matching the regex from the provided text.
-func extractRegex(text, regex string) string {
-	re := regexp.MustCompile(regex)
-	return re.FindString(text)
+// profileOptions control what scrapeProfileDetails fetches beyond the profile page.
+type profileOptions struct {
+	fetchContactInfo bool   // Request the contact-info overlay when the page does not embed it.
+	currentCompany   string // Classify the profile's positions at this company as current or past.
+	breaker          *profileBreaker
+	negative         *negativeCache // Profiles not to fetch again yet.
+	pending          *pendingRun    // Enrichment an interrupted run finished, for -run-dir.
+	concurrency      int            // Profiles fetched at once; result pages are always fetched one at a time.
+	grace            time.Duration  // How long fetches in flight may finish once the run is stopped.
+	stage            *pipelineStage // Where profile fetches are counted for -stall-timeout.
 }
 
-// parseExperience extracts the experience in years from a text snippet.
-func parseExperience(experienceStr string) (int, error) {
-	re := regexp.MustCompile(experienceRegex)
-	match := re.FindStringSubmatch(experienceStr)
-	if len(match) > 1 {
-		years, err := strconv.Atoi(match[1])
-		if err != nil {
-			return 0, fmt.Errorf("error parsing experience years: %w", err)
+// fetchContactInfo requests a profile's contact-info overlay. Failures are
+// logged and yield no contact info, since the profile page itself succeeded.
+func fetchContactInfo(ctx context.Context, f Fetcher, profileURL string) contactInfo {
+	body, err := f.Fetch(ctx, contactInfoURL(profileURL))
+	if err != nil {
+		log.Printf("Error fetching contact info for %s: %v", profileURL, err)
+		return contactInfo{}
+	}
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		log.Printf("Error parsing contact info for %s: %v", profileURL, err)
+		return contactInfo{}
+	}
+	return parseContactInfo(doc)
+}
+
+var (
+	emailMatcher = regexp.MustCompile(emailRegex)
+	// Profiles are also served under country hosts such as in.linkedin.com,
+	// which Google lists alongside, and as mirrors of, the www ones.
+	profileLinkRegex = regexp.MustCompile(`(https:\/\/(?:www|[a-z]{2})\.linkedin\.com\/in\/[^&?]+)`)
+)
+
+// maxProfileScanBytes caps the HTML the fallback regexes scan on a profile
+// page; contact details sit near the top of the content.
+const maxProfileScanBytes = 512 << 10
+
+// profileScanRegion returns the part of a profile page worth scanning for
+// contact details: the <main> element when there is one, else everything
+// after <head>, capped at maxProfileScanBytes. Head metadata and the
+// scripts around the content only yield false matches.
+func profileScanRegion(page []byte) []byte {
+	region := page
+	if start := bytes.Index(region, []byte("<main")); start >= 0 {
+		region = region[start:]
+		if end := bytes.Index(region, []byte("</main>")); end >= 0 {
+			region = region[:end]
 		}
-		return years, nil
+	} else if start := bytes.Index(region, []byte("<body")); start >= 0 {
+		region = region[start:]
+	}
+	if len(region) > maxProfileScanBytes {
+		region = region[:maxProfileScanBytes]
 	}
-	return 0, fmt.Errorf("experience not found in string: %s", experienceStr)
+	return region
 }
 
-// getProxyClient returns an HTTP client configured to use a proxy if valid proxies are provided.
-// If no valid proxy is available, it returns the default HTTP client.
-func getProxyClient() *http.Client {
-	// If you have proxies, add valid proxy URLs here.
-	proxyList := []string{} // Leave empty if you don't need a proxy.
-	if len(proxyList) == 0 {
-		return &http.Client{Timeout: 10 * time.Second}
+// stopAtAuthwall follows redirects as the default client does, except that a
+// redirect to LinkedIn's login wall is returned as is, for Fetch to report.
+// Requests made under noRedirects are never redirected.
+func stopAtAuthwall(req *http.Request, via []*http.Request) error {
+	if strings.Contains(req.URL.Path, "/authwall") || req.Context().Value(noRedirectsKey{}) != nil {
+		return http.ErrUseLastResponse
 	}
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	}
+	return nil
+}
 
-	proxyURL, err := url.Parse(proxyList[rand.Intn(len(proxyList))])
-	if err != nil {
-		log.Println("Invalid proxy URL:", err)
-		return &http.Client{Timeout: 10 * time.Second}
+// csvColumn describes a single column of the CSV output.
+type csvColumn struct {
+	key    string // Name used to select the column with -columns.
+	header string
+	value  func(c Candidate) string
+}
+
+// csvColumns lists every column that can be written, in output order.
+var csvColumns = []csvColumn{
+	{"rank", "Rank", func(c Candidate) string { return strconv.Itoa(c.Rank) }},
+	{"page_position", "Page Position", func(c Candidate) string { return strconv.Itoa(c.PagePosition) }},
+	{"overall_position", "Overall Position", func(c Candidate) string { return strconv.Itoa(c.OverallPosition) }},
+	{"name", "Name", func(c Candidate) string { return c.Name }},
+	{"email", "Email", func(c Candidate) string { return c.Email }},
+	{"email_guess", "Email Guess", func(c Candidate) string { return c.EmailGuess }},
+	{"phone", "Phone", func(c Candidate) string { return c.Phone }},
+	{"title", "Title", func(c Candidate) string { return c.Title }},
+	{"company", "Company", func(c Candidate) string { return c.Company }},
+	{"positions", "Positions", func(c Candidate) string { return formatPositions(c.Positions) }},
+	{"profile_url", "Profile URL", func(c Candidate) string { return c.ProfileURL }},
+	{"rediscovered", "Rediscovered", func(c Candidate) string { return strconv.FormatBool(c.Rediscovered) }},
+	{"previously_seen", "Previously Seen", func(c Candidate) string {
+		if c.PreviouslySeen == nil {
+			return ""
+		}
+		return outputTime(*c.PreviouslySeen).Format("2006-01-02")
+	}},
+	{"name_slug_mismatch", "Name Slug Mismatch", func(c Candidate) string { return strconv.FormatBool(c.NameSlugMismatch) }},
+	{"anonymized", "Anonymized", func(c Candidate) string { return strconv.FormatBool(c.Anonymized) }},
+	{"result_type", "Result Type", func(c Candidate) string { return c.ResultType }},
+	{"alternate_urls", "Alternate URLs", func(c Candidate) string { return strings.Join(c.AlternateURLs, " ") }},
+	{"contacted_by", "Contacted By", func(c Candidate) string { return c.MatchedContactedBy }},
+	{"optout_url", "Opt-out URL", func(c Candidate) string { return c.OptOutURL }},
+	{"experience", "Experience", func(c Candidate) string { return strconv.Itoa(legacyExperience(c.experienceYears())) }}, // Deprecated: whole years of experience_years.
+	{"experience_years", "Experience Years", func(c Candidate) string { return formatExperienceYears(c.experienceYears()) }},
+	{"company_size", "Company Size", func(c Candidate) string { return c.CompanySizeBand }},
+	{"company_type", "Company Type", func(c Candidate) string { return c.CompanyType }},
+	{"employment_match", "Employment Match", func(c Candidate) string { return c.EmploymentMatch }},
+	{"relaxation_level", "Relaxation Level", func(c Candidate) string { return strconv.Itoa(c.RelaxationLevel) }},
+	{"location", "Location", func(c Candidate) string { return c.Location }},
+	{"city", "City", func(c Candidate) string { return c.City }},
+	{"state", "State", func(c Candidate) string { return c.State }},
+	{"country", "Country", func(c Candidate) string { return c.Country }},
+	{"website", "Website", func(c Candidate) string { return c.Website }},
+	{"twitter", "Twitter", func(c Candidate) string { return c.Twitter }},
+	{"matched_terms", "Matched Terms", func(c Candidate) string { return strings.Join(c.MatchedTerms, "; ") }},
+	{"profile_language", "Profile Language", func(c Candidate) string { return c.ProfileLanguage }},
+	{"page_language", "Page Language", func(c Candidate) string { return c.PageLanguage }},
+	{"completeness", "Profile Completeness", func(c Candidate) string { return strconv.Itoa(c.ProfileCompleteness) }},
+	{"score", "Score", func(c Candidate) string { return strconv.Itoa(c.Score) }},
+	{"summary", "Summary", func(c Candidate) string { return c.Summary }},
+	{"job", "Job", func(c Candidate) string { return c.Job }},
+	{"lookup_match", "Lookup Match", func(c Candidate) string { return c.LookupMatch }},
+	{"lookup_score", "Lookup Score", func(c Candidate) string { return strconv.FormatFloat(c.LookupScore, 'f', 2, 64) }},
+}
+
+// defaultColumns are written when -columns is not given. The long Summary
+// column is opt-in.
+const defaultColumns = "name,email,phone,profile_url,experience"
+
+// selectCSVColumns resolves a comma-separated list of column keys.
+func selectCSVColumns(keys string) ([]csvColumn, error) {
+	var columns []csvColumn
+	for _, key := range strings.Split(keys, ",") {
+		key = strings.ToLower(strings.TrimSpace(key))
+		if key == "" {
+			continue
+		}
+		col, ok := findCSVColumn(key)
+		if !ok {
+			return nil, fmt.Errorf("unknown column %q", key)
+		}
+		columns = append(columns, col)
 	}
+	if len(columns) == 0 {
+		return nil, errors.New("no columns selected")
+	}
+	return columns, nil
+}
 
-	transport := &http.Transport{Proxy: http.ProxyURL(proxyURL)}
-	client := &http.Client{Transport: transport, Timeout: 10 * time.Second}
-	return client
+// findCSVColumn looks up a column by key.
+func findCSVColumn(key string) (csvColumn, bool) {
+	for _, col := range csvColumns {
+		if col.key == key {
+			return col, true
+		}
+	}
+	return csvColumn{}, false
 }
 
-// getHeaders returns HTTP headers including a random User-Agent.
-func getHeaders() http.Header {
-	userAgents := []string{
-		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36",
-		"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/15.0 Safari/605.1.15",
-		"Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:89.0) Gecko/20100101 Firefox/89.0",
+// hasCSVColumn reports whether columns includes the column with key.
+func hasCSVColumn(columns []csvColumn, key string) bool {
+	for _, col := range columns {
+		if col.key == key {
+			return true
+		}
 	}
-	headers := http.Header{}
-	headers.Set("User-Agent", userAgents[rand.Intn(len(userAgents))])
-	return headers
+	return false
 }
 
-// writeToCSV writes the list of candidates to a CSV file.
-func writeToCSV(candidates []Candidate, filename string) error {
+// writeToCSV writes the list of candidates to a CSV file in format, preceded
+// by the run info block as comment lines when info is not nil.
+func writeToCSV(candidates []Candidate, filename string, columns []csvColumn, info *runInfo, format csvFormat) error {
 	file, err := os.Create(filename)
 	if err != nil {
 		return fmt.Errorf("failed to create CSV file: %w", err)
 	}
 	defer file.Close()
 
-	writer := csv.NewWriter(file)
-	defer writer.Flush()
+	out, err := encodeCSV(file, format)
+	if err != nil {
+		return fmt.Errorf("failed to write CSV file: %w", err)
+	}
+	if info != nil {
+		if err := info.writeCSVComment(out); err != nil {
+			return fmt.Errorf("failed to write run info: %w", err)
+		}
+	}
+
+	writer := newCSVWriter(out, format)
 
 	// Write header row.
-	header := []string{"Name", "Email", "Phone", "Profile URL", "Experience"}
+	header := make([]string, len(columns))
+	for i, col := range columns {
+		header[i] = col.header
+	}
 	if err := writer.Write(header); err != nil {
 		return fmt.Errorf("failed to write header row: %w", err)
 	}
 
 	// Write candidate rows.
 	for _, candidate := range candidates {
-		row := []string{
-			candidate.Name,
-			candidate.Email,
-			candidate.Phone,
-			candidate.ProfileURL,
-			strconv.Itoa(candidate.Experience),
+		row := make([]string, len(columns))
+		for i, col := range columns {
+			row[i] = col.value(candidate)
 		}
 		if err := writer.Write(row); err != nil {
 			return fmt.Errorf("failed to write data row: %w", err)
 		}
 	}
 
+	// Rows are buffered, so a full disk shows only now.
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("failed to write CSV file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write CSV file: %w", err)
+	}
 	return nil
 }
 
-func main() {
-	rand.Seed(time.Now().UnixNano())
+// writeOutput writes candidates found for criteria, by job in batch runs, to
+// filename in the -format: a CSV or spreadsheet of columns, JSON, a Parquet
+// file, an Atom feed, or the output of a template.
+func writeOutput(cfg *config, candidates []Candidate, filename string, columns []csvColumn, criteria SearchCriteria, job string) error {
+	info := cfg.runInfo(criteria, job)
+	switch cfg.format {
+	case formatTemplate:
+		return writeToTemplate(cfg.template, candidates, filename, criteria, info)
+	case formatParquet:
+		return writeToParquet(candidates, filename, info)
+	case formatAtom:
+		return writeToAtom(candidates, filename, cfg.feedMax, info)
+	case formatJSON, formatJSONL:
+		return writeToJSON(candidates, filename, info, cfg.format == formatJSONL)
+	case formatXLSX:
+		return writeToXLSX(candidates, filename, columns, info)
+	}
+	return writeToCSV(candidates, filename, columns, info, cfg.csv)
+}
 
-	// --- Configuration ---
-	// Searching for LinkedIn profiles of professionals who:
-	// - Work with "control valve desuperheater"
-	// - Are based in Bangalore
-	// - Operate in the "Machinery Manufacturing" industry
-	// - Have 7-12 years of experience
-	keywords := "control valve desuperheater"
-	location := "Bangalore"
-	industry := "Machinery Manufacturing"
-	experienceRange := "7-12 years"
+// csvStream appends candidates to a CSV file as they are found, flushing every
+// flushEvery rows so that an interrupted run keeps what it found. A single
+// goroutine owns the file; writers hand it rows over a channel and wait for
+// them to be written, so concurrent writers neither interleave rows nor
+// buffer without bound. A nil stream discards writes.
+type csvStream struct {
+	file       *os.File
+	writer     csvRowWriter
+	columns    []csvColumn
+	flushEvery int
+	pending    int // Rows written since the last flush.
+	rows       int
 
-	// Build the Google search URL.
-	searchURL := buildGoogleSearchURL(keywords, location, industry, experienceRange)
-	fmt.Printf("Searching Google with URL: %s\n", searchURL)
+	requests  chan streamRequest
+	queued    atomic.Int64 // Rows handed to the writer goroutine but not yet written.
+	done      chan struct{}
+	closeOnce sync.Once
+	closeErr  error
+}
 
-	var allCandidates []Candidate
-	for page := 0; page < maxPagesToScrape; page++ {
-		fmt.Printf("Scraping Google page %d...\n", page+1)
-		pageURL := searchURL
-		if page > 0 {
-			// Google uses the 'start' parameter for pagination.
-			pageURL = fmt.Sprintf("%s&start=%d", searchURL, page*10)
+// streamRequest is one batch of rows for the writer goroutine, which reports
+// the outcome on ack.
+type streamRequest struct {
+	candidates []Candidate
+	ack        chan error
+}
+
+const (
+	streamQueue        = 16               // Batches that may wait for the writer goroutine.
+	streamCloseTimeout = 30 * time.Second // How long Close waits for queued batches.
+)
+
+// openCSVStream creates filename, writes the run info block and header in
+// format, and starts the writer goroutine.
+func openCSVStream(filename string, columns []csvColumn, info *runInfo, flushEvery int, format csvFormat) (*csvStream, error) {
+	file, err := os.Create(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create CSV file: %w", err)
+	}
+	out, err := encodeCSV(file, format)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to write CSV file: %w", err)
+	}
+	if info != nil {
+		if err := info.writeCSVComment(out); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to write run info: %w", err)
 		}
+	}
+	s := &csvStream{
+		file: file, writer: newCSVWriter(out, format), columns: columns, flushEvery: flushEvery,
+		requests: make(chan streamRequest, streamQueue), done: make(chan struct{}),
+	}
+	header := make([]string, len(columns))
+	for i, col := range columns {
+		header[i] = col.header
+	}
+	if err := s.writer.Write(header); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to write header row: %w", err)
+	}
+	if err := s.flush(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	go s.run()
+	return s, nil
+}
 
-		// Random delay between requests.
-		delay := time.Duration(rand.Intn(10)+5) * time.Second
-		fmt.Printf("Waiting for %.0f seconds before scraping page %d\n", delay.Seconds(), page+1)
-		time.Sleep(delay)
+// write appends candidates and returns once they are written, flushing once
+// flushEvery rows are pending. The rows of one call are never split by
+// another writer's.
+func (s *csvStream) write(candidates []Candidate) error {
+	if s == nil || len(candidates) == 0 {
+		return nil
+	}
+	ack := make(chan error, 1)
+	s.queued.Add(int64(len(candidates)))
+	s.requests <- streamRequest{candidates: candidates, ack: ack}
+	return <-ack
+}
 
-		client := getProxyClient()
-		var resp *http.Response
-		var err error
+// run is the writer goroutine. It writes each batch in the order received
+// and, once the stream is closed, flushes and closes the file.
+func (s *csvStream) run() {
+	defer close(s.done)
+	for req := range s.requests {
+		err := s.writeRows(req.candidates)
+		s.queued.Add(-int64(len(req.candidates)))
+		req.ack <- err
+	}
+	s.closeErr = s.flush()
+	if err := s.file.Close(); s.closeErr == nil {
+		s.closeErr = err
+	}
+}
 
-		// Retry logic for fetching the page.
-		for attempt := 0; attempt < retryAttempts; attempt++ {
-			req, reqErr := http.NewRequest("GET", pageURL, nil)
-			if reqErr != nil {
-				log.Printf("Error creating request: %v", reqErr)
+// writeRows writes candidates to the CSV writer. Only run calls it.
+func (s *csvStream) writeRows(candidates []Candidate) error {
+	for _, candidate := range candidates {
+		row := make([]string, len(s.columns))
+		for i, col := range s.columns {
+			row[i] = col.value(candidate)
+		}
+		if err := s.writer.Write(row); err != nil {
+			return fmt.Errorf("failed to write data row: %w", err)
+		}
+		s.rows++
+		if s.pending++; s.pending >= s.flushEvery {
+			if err := s.flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// flush writes buffered rows through to disk.
+func (s *csvStream) flush() error {
+	s.writer.Flush()
+	if err := s.writer.Error(); err != nil {
+		return fmt.Errorf("failed to write CSV file: %w", err)
+	}
+	s.pending = 0
+	return s.file.Sync()
+}
+
+// Close stops accepting rows, waits up to streamCloseTimeout for queued rows
+// to be written, then flushes and closes the file. No writes may follow.
+func (s *csvStream) Close() error {
+	s.closeOnce.Do(func() { close(s.requests) })
+	select {
+	case <-s.done:
+		return s.closeErr
+	case <-time.After(streamCloseTimeout):
+		return fmt.Errorf("timed out closing CSV file with %d rows unwritten", s.queued.Load())
+	}
+}
+
+// closeCSVStream closes s, logging any error, for use with defer.
+func closeCSVStream(s *csvStream) {
+	if err := s.Close(); err != nil {
+		log.Printf("Error writing CSV: %v", err)
+	}
+}
+
+// fetchSearchPage fetches a single Google results page, retrying transient failures.
+func fetchSearchPage(ctx context.Context, f Fetcher, pageURL string) ([]byte, error) {
+	var lastErr error
+	for attempt := 0; attempt < retryAttempts; attempt++ {
+		body, err := f.Fetch(ctx, pageURL)
+		if err == nil {
+			return body, nil
+		}
+		if stopsRun(err) || errors.Is(err, errNoFixture) {
+			return nil, err
+		}
+		lastErr = err
+		log.Printf("Error fetching page: %v. Retrying in %.0f seconds", err, retryDelay.Seconds())
+		stats.recordBackoff(pageURL, retryDelay)
+		if err := sleepContext(ctx, retryDelay); err != nil {
+			return nil, err
+		}
+	}
+	return nil, lastErr
+}
+
+// showQueries prints the query of every search the run would make, level by
+// level of relaxation, in the syntax of -engine.
+func showQueries(cfg *config) error {
+	jobs := []Job{{SearchCriteria: cfg.criteria}}
+	if len(cfg.languageJobs) > 0 {
+		jobs = cfg.languageJobs
+	}
+	if cfg.jobsFile != "" {
+		var err error
+		if jobs, err = loadJobs(cfg.jobsFile); err != nil {
+			return fmt.Errorf("error loading jobs: %w", err)
+		}
+	}
+	for _, job := range jobs {
+		if job.Name != "" {
+			fmt.Printf("Job %s:\n", job.Name)
+		}
+		criteria := cfg.jobCriteria(job)
+		for level := 0; level <= cfg.maxRelaxation; level++ {
+			relaxed := relaxCriteria(criteria, level)
+			if level > 0 && relaxed == relaxCriteria(criteria, level-1) {
 				continue
 			}
-			req.Header = getHeaders()
-			req.Header.Set("Referer", "https://www.google.com/")
+			for _, chunk := range cfg.chunks {
+				relaxed.Discriminator = chunk.Discriminator
+				r := renderQuery(cfg.engine, relaxed)
+				fmt.Printf("  level %d, %d pages: %s\n", level, chunk.Pages, r.Query)
+				if len(r.Params) > 0 {
+					fmt.Printf("    parameters: %s\n", r.Params.Encode())
+				}
+				for _, e := range r.Emulated {
+					fmt.Printf("    emulated by filtering results: %s\n", e)
+				}
+				for _, w := range r.Warnings {
+					fmt.Printf("    warning: %s\n", w)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// jobCriteria returns a job's criteria with the run-wide options applied.
+func (cfg *config) jobCriteria(job Job) SearchCriteria {
+	c := job.SearchCriteria
+	c.LooseKeywords = c.LooseKeywords || cfg.criteria.LooseKeywords
+	return c
+}
+
+// runSearch scrapes up to maxPages of Google results for the job's criteria, enriches
+// each candidate from its LinkedIn profile, and returns those kept by the
+// filters. With -min-results, a search yielding too few is retried with
+// progressively relaxed criteria; see relaxCriteria.
+func runSearch(ctx context.Context, cfg *config, f Fetcher, job Job) ([]Candidate, error) {
+	criteria := cfg.jobCriteria(job)
+	results := newResultStore()
+	seen := make(map[string]bool) // Profiles found at any level, so retries never enrich one twice.
+	nextRank := 1
+	for level := 0; level <= cfg.maxRelaxation; level++ {
+		relaxed := relaxCriteria(criteria, level)
+		if level > 0 {
+			if relaxed == relaxCriteria(criteria, level-1) {
+				continue // This step changes nothing for these criteria.
+			}
+			log.Printf("Only %d candidates (minimum %d); relaxing search to level %d: %s.", results.Len(), cfg.minResults, level, relaxationSteps[level-1].name)
+		}
 
-			resp, err = client.Do(req)
+		for _, chunk := range cfg.chunks {
+			relaxed.Discriminator = chunk.Discriminator
+			discovered, err := discoverCandidates(ctx, cfg, f, results, job.Name, relaxed, level, chunk.Pages, seen, nextRank)
+			nextRank += discovered
 			if err != nil {
-				log.Printf("Error fetching page: %v. Retrying in %.0f seconds", err, retryDelay.Seconds())
-				time.Sleep(retryDelay)
-				continue
+				return suppressMirrors(results.Snapshot(), cfg.mirrorThreshold), err
 			}
-			if resp.StatusCode != http.StatusOK {
-				log.Printf("Received status code %d. Retrying in %.0f seconds", resp.StatusCode, retryDelay.Seconds())
-				resp.Body.Close()
-				time.Sleep(retryDelay)
-				continue
+		}
+		if cfg.minResults == 0 || results.Len() >= cfg.minResults {
+			return suppressMirrors(results.Snapshot(), cfg.mirrorThreshold), nil
+		}
+	}
+	return suppressMirrors(results.Snapshot(), cfg.mirrorThreshold), nil
+}
+
+// discoverCandidates scrapes up to pages result pages of one query, enriches each
+// candidate not already in seen, ranking them from firstRank, and finalizes
+// them page by page. Kept candidates are added to results and written to the
+// -flush-every stream, less mirrors of those already written. It returns how many candidates were discovered.
+func discoverCandidates(ctx context.Context, cfg *config, f Fetcher, results *ResultStore, jobName string, criteria SearchCriteria, level, pages int, seen map[string]bool, firstRank int) (int, error) {
+	// Build the Google search URL.
+	searchURL := buildGoogleSearchURL(criteria)
+	fmt.Printf("Searching Google with URL: %s\n", searchURL)
+
+	// Candidates stay in discovery order, page then position on the page,
+	// through enrichment and filtering.
+	profileOpts := cfg.profileOptions
+	profileOpts.currentCompany = criteria.CurrentCompany
+	profileOpts.stage = cfg.stalls.stage(stageProfiles)
+
+	discovered := 0
+	keep := func(candidates []Candidate) error {
+		_, done := cfg.stalls.stage(stageOutput).begin(ctx, fmt.Sprintf("%d candidates of %s", len(candidates), searchURL))
+		defer done()
+		for i := range candidates {
+			candidates[i].Job = jobName
+			candidates[i].RelaxationLevel = level
+		}
+		candidates = finalizeCandidates(cfg, criteria, candidates)
+		var prior []Candidate
+		if cfg.stream != nil {
+			prior = results.Snapshot()
+		}
+		for _, c := range candidates {
+			if results.Add(c) {
+				cfg.watchdog.touch()
 			}
-			break
 		}
-		if err != nil {
-			log.Printf("Failed to fetch page %d: %v", page+1, err)
-			continue
+		if !cfg.outputs.enabled(outputCSV) {
+			return nil
 		}
+		return cfg.outputs.fail(outputCSV, cfg.stream.write(withoutMirrors(prior, candidates, cfg.mirrorThreshold)))
+	}
 
-		doc, err := goquery.NewDocumentFromReader(resp.Body)
-		resp.Body.Close()
-		if err != nil {
-			log.Printf("Error parsing page %d: %v", page+1, err)
-			continue
+	// wanted is the results the pages would hold at Google's default size.
+	// The next page starts after the results actually served, so a -single-page
+	// request Google caps below singlePageNum is simply followed by another.
+	wanted, start := pages*resultsPerPage, 0
+	emptyPages := 0 // Consecutive pages served without a single result.
+	var err error
+	for page := 0; page < pages && start < wanted; page++ {
+		if err = ctx.Err(); err != nil {
+			break
 		}
+		fmt.Printf("Scraping Google page %d...\n", page+1)
+		num := 0
+		if cfg.singlePage {
+			num = min(singlePageNum, wanted-start)
+		}
+		pageURL := resultPageURL(searchURL, start, num)
 
-		candidates, err := scrapeGoogleSearchResults(doc)
-		if err != nil {
-			log.Printf("Error scraping candidates from page %d: %v", page+1, err)
+		pageCtx, pageDone := cfg.stalls.stage(stageResults).begin(ctx, pageURL)
+		candidates, pageErr := parsedResultsPage(pageCtx, cfg, f, pageURL, segmentHeader{PageURL: pageURL, Query: criteria.Keywords, Page: page + 1, Start: start})
+		stalled := pageDone()
+		if pageErr != nil {
+			log.Printf("Page %d: %v", page+1, pageErr)
+			if stopsRun(pageErr) && !stalled {
+				err = pageErr
+				break
+			}
+			start += max(num, resultsPerPage)
 			continue
 		}
+		found := len(candidates)
+		if cfg.singlePage && found == 0 {
+			break // Google has no more results for this query.
+		}
+		for i := range candidates {
+			candidates[i].PagePosition = i + 1
+			candidates[i].OverallPosition = start + i + 1
+		}
+		start += max(found, resultsPerPage)
+		// A page that was served and parsed but holds no results means the
+		// query is exhausted: Google pads its tail with empty pages. Blocked
+		// or failed pages end up above and never count.
+		if found == 0 {
+			emptyPages++
+		} else {
+			emptyPages = 0
+		}
+		stats.countPage(candidates)
+		candidates = rankNewCandidates(candidates, seen, firstRank+discovered)
+		discovered += len(candidates)
+		candidates = skipAnonymized(candidates, cfg.anonymized)
+		candidates = cfg.incremental.skipKnown(candidates)
+		candidates = cfg.sampler.sample(candidates)
 
-		// Optionally, scrape additional details from each candidate's LinkedIn profile.
-		for i, cand := range candidates {
-			fmt.Printf("Scraping details for candidate %d: %s\n", i+1, cand.ProfileURL)
-			detailedCandidate, err := scrapeProfileDetails(cand.ProfileURL)
-			if err != nil {
-				log.Printf("Error scraping profile details for %s: %v", cand.ProfileURL, err)
-			} else {
-				// Update candidate details if profile scraping succeeds.
-				cand.Name = detailedCandidate.Name
-				cand.Email = detailedCandidate.Email
-				cand.Phone = detailedCandidate.Phone
+		if enrichErr := enrichCandidates(ctx, f, criteria.Keywords, candidates, profileOpts); enrichErr != nil {
+			// Keep what was found on this page, then stop.
+			if err = keep(candidates); err == nil {
+				err = enrichErr
 			}
-			candidates[i] = cand
+			break
+		}
+		if err = keep(candidates); err != nil {
+			break
 		}
 
-		allCandidates = append(allCandidates, candidates...)
+		// A page far thinner than a results page should be is the mark of a
+		// partial block: Google serves a cut-down page rather than a captcha,
+		// and later pages would fare no better.
+		if cfg.minCandidatesPerPage > 0 && found < cfg.minCandidatesPerPage {
+			fmt.Printf("Page %d yielded %d candidates (minimum %d), likely a partial block; stopping early.\n", page+1, found, cfg.minCandidatesPerPage)
+			break
+		}
+		if cfg.maxEmptyPages > 0 && emptyPages >= cfg.maxEmptyPages {
+			fmt.Printf("%d consecutive pages yielded no candidates; results are exhausted, stopping.\n", emptyPages)
+			break
+		}
 	}
 
-	if len(allCandidates) == 0 {
-		log.Println("No candidates found.")
-		return
+	return discovered, err
+}
+
+// resultPageURL returns the URL of the results page starting at result start,
+// requesting num results or Google's default when num is 0.
+func resultPageURL(searchURL string, start, num int) string {
+	if num > 0 {
+		searchURL += "&num=" + strconv.Itoa(num)
 	}
+	if start > 0 {
+		// Google uses the 'start' parameter for pagination.
+		searchURL += "&start=" + strconv.Itoa(start)
+	}
+	return searchURL
+}
+
+// totalResultsRegex matches the result count in "About 12,300 results" and
+// its localized forms, whose thousands separators may be commas, dots, or
+// (narrow) spaces.
+var totalResultsRegex = regexp.MustCompile(`\d(?:[\d,.'\s\x{a0}\x{202f}]*\d)?`)
+
+// parseTotalResults parses Google's approximate result count. The timing in
+// parentheses, "(0.45 seconds)", is ignored.
+func parseTotalResults(text string) (int, bool) {
+	if i := strings.Index(text, "("); i >= 0 {
+		text = text[:i]
+	}
+	// Later pages read "Page 2 of about 12,300 results", so the count is the
+	// last number.
+	matches := totalResultsRegex.FindAllString(text, -1)
+	if len(matches) == 0 {
+		return 0, false
+	}
+	m := matches[len(matches)-1]
+	n, err := strconv.Atoi(strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, m))
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
 
-	if err := writeToCSV(allCandidates, outputFilename); err != nil {
-		log.Fatalf("Error writing CSV: %v", err)
+// rankNewCandidates drops candidates whose profile was already seen on an
+// earlier page or earlier on this one, keeping the first occurrence, and
+// numbers the rest in order starting at next.
+func rankNewCandidates(candidates []Candidate, seen map[string]bool, next int) []Candidate {
+	fresh := candidates[:0]
+	for _, c := range candidates {
+		if seen[c.ProfileURL] {
+			continue
+		}
+		seen[c.ProfileURL] = true
+		c.Rank = next
+		next++
+		fresh = append(fresh, c)
 	}
+	return fresh
+}
 
-	fmt.Printf("Successfully wrote %d candidates to %s\n", len(allCandidates), outputFilename)
+// parsedResultsPage returns the candidates on a results page, read back from
+// the -run-dir when an earlier run parsed the page, or scraped and saved there.
+func parsedResultsPage(ctx context.Context, cfg *config, f Fetcher, pageURL string, header segmentHeader) ([]Candidate, error) {
+	pending := cfg.profileOptions.pending
+	candidates, ok, err := pending.loadSegment(pageURL)
+	if err != nil {
+		log.Printf("Ignoring saved results page: %v", err)
+	} else if ok {
+		verbosef("Read page %d from %s", header.Page, cfg.runDir)
+		return candidates, nil
+	}
+	candidates, err = scrapeResultsPage(ctx, f, pageURL, cfg.transforms, cfg.resultParser)
+	if err != nil {
+		return nil, err
+	}
+	header.ParsedAt = clockNow().UTC()
+	if err := pending.saveSegment(header, candidates); err != nil {
+		log.Printf("Failed to save results page %d: %v", header.Page, err)
+	}
+	return candidates, nil
+}
+
+// scrapeResultsPage fetches one Google results page and extracts its
+// candidates with parse, applying transforms first.
+func scrapeResultsPage(ctx context.Context, f Fetcher, pageURL string, transforms transform.Chain, parse resultParser) ([]Candidate, error) {
+	body, err := fetchSearchPage(ctx, f, pageURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch page: %w", err)
+	}
+
+	var candidates []Candidate
+	refetch := func(ctx context.Context) ([]byte, error) { return fetchSearchPage(ctx, f, pageURL) }
+	err = parseWithRetry(ctx, pageURL, body, refetch, func(_ []byte, doc *goquery.Document) error {
+		transforms.Apply(doc)
+
+		if total, ok := parseTotalResults(doc.Find(resultStatsSelector).First().Text()); ok {
+			stats.setApproxTotalResults(total)
+			log.Printf("Google reports about %d results.", total)
+		}
+
+		var err error
+		if candidates, err = parse(doc); err != nil {
+			return fmt.Errorf("error scraping candidates: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return candidates, nil
+}
+
+// enrichCandidates scrapes additional details from each candidate's LinkedIn
+// profile and records which search keywords it matches, with up to
+// opts.concurrency profiles in flight at once. It returns an error only when
+// the run must stop, such as when the request budget is spent; candidates not
+// yet enriched keep their snippet data. Once ctx is cancelled no further
+// profile is started, and those in flight get opts.grace to finish.
+func enrichCandidates(ctx context.Context, f Fetcher, keywords string, candidates []Candidate, opts profileOptions) error {
+	inflight, release := withShutdownGrace(ctx, opts.grace)
+	defer release()
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		stopErr  error
+		stopped  atomic.Bool
+		indexes  = make(chan int)
+		poolSize = min(max(opts.concurrency, 1), max(len(candidates), 1))
+	)
+	for w := 0; w < poolSize; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Each index is handed to one worker, so candidates[i] needs no lock.
+			for i := range indexes {
+				if err := enrichCandidate(ctx, inflight, f, keywords, i, &candidates[i], opts); err != nil {
+					mu.Lock()
+					if stopErr == nil {
+						stopErr = err
+					}
+					mu.Unlock()
+					stopped.Store(true)
+				}
+			}
+		}()
+	}
+	for i := range candidates {
+		if stopped.Load() || ctx.Err() != nil {
+			break
+		}
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+	if stopErr == nil {
+		stopErr = ctx.Err() // Stopped while the last profiles were in flight.
+	}
+	return stopErr
+}
+
+// enrichCandidate enriches the i-th candidate of a page in place, unless ctx
+// is cancelled already; its profile is fetched with inflight. It returns an
+// error only when the run must stop.
+func enrichCandidate(ctx, inflight context.Context, f Fetcher, keywords string, i int, cand *Candidate, opts profileOptions) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if done, ok := opts.pending.lookup(keywords, cand.ProfileURL); ok {
+		verbosef("Candidate %d was enriched before the run was interrupted: %s", i+1, cand.ProfileURL)
+		*cand = done
+		return nil
+	}
+	if opts.breaker.open() || cand.Anonymized {
+		// An anonymized member's profile only shows the sign-in wall.
+		cand.MatchedTerms = matchTerms(keywords, cand.Snippet)
+		return nil
+	}
+	if class, ok := opts.negative.suppressed(cand.ProfileURL); ok {
+		verbosef("Not fetching %s, which failed recently (%s)", cand.ProfileURL, class)
+		stats.countSuppressedFetch(class)
+		cand.MatchedTerms = matchTerms(keywords, cand.Snippet)
+		return nil
+	}
+	fmt.Printf("Scraping details for candidate %d: %s\n", i+1, cand.ProfileURL)
+	fetchCtx, done := opts.stage.begin(inflight, cand.ProfileURL)
+	detailedCandidate, err := scrapeProfileDetails(fetchCtx, f, cand.ProfileURL, opts)
+	if done() {
+		// The watchdog gave up on the fetch; it says nothing about the profile.
+		log.Printf("Gave up on the stalled fetch of %s; keeping the result's data", cand.ProfileURL)
+		cand.MatchedTerms = matchTerms(keywords, cand.Snippet)
+		return nil
+	}
+	opts.breaker.record(err)
+	opts.negative.record(cand.ProfileURL, err, detailedCandidate)
+	switch {
+	case errors.Is(err, errRobotsDisallowed):
+		// The result's snippet data is kept.
+		log.Printf("Not fetching %s, which robots.txt disallows", cand.ProfileURL)
+	case err != nil:
+		log.Printf("Error scraping profile details for %s: %v", cand.ProfileURL, err)
+		if stopsRun(err) {
+			return err
+		}
+	default:
+		// Update candidate details if profile scraping succeeds. Fields both
+		// the result and the profile fill go to the -field-priority winner.
+		now := clockNow()
+		mergeFields("profile", cand, now, detailedCandidate, now)
+		cand.EmploymentMatch = detailedCandidate.EmploymentMatch
+		cand.PhotoURL = detailedCandidate.PhotoURL
+		cand.Headline = detailedCandidate.Headline
+		cand.SkillsCount = detailedCandidate.SkillsCount
+		cand.HasEducation = detailedCandidate.HasEducation
+		cand.Connections = detailedCandidate.Connections
+		cand.Positions = detailedCandidate.Positions
+		cand.Extra = mergeExtra(detailedCandidate.Extra, cand.Extra)
+		cand.PageLanguage = detailedCandidate.PageLanguage
+	}
+	cand.MatchedTerms = matchTerms(keywords, cand.Snippet, cand.Summary)
+	if err := opts.pending.markEnriched(keywords, *cand); err != nil {
+		log.Print(err)
+	}
+	return nil
+}
+
+// parseFlags resolves the command-line options into a config.
+func parseFlags(args []string) (*config, error) {
+	cfg := &config{args: args}
+	fs := flag.NewFlagSet("profilesearch", flag.ExitOnError)
+
+	// Default search: LinkedIn profiles of professionals who
+	// - Work with "control valve desuperheater"
+	// - Are based in Bangalore
+	// - Operate in the "Machinery Manufacturing" industry
+	// - Have 7-12 years of experience
+	fs.StringVar(&cfg.criteria.Keywords, "keywords", "control valve desuperheater", "search keywords")
+	fs.StringVar(&cfg.criteria.ExcludeKeywords, "exclude-keywords", "", "comma-separated terms results must not mention, e.g. recruiter,intern")
+	fs.BoolVar(&cfg.criteria.LooseKeywords, "loose-keywords", false, "search multi-word keywords as separate words instead of an exact phrase (a trailing * does the same for one argument)")
+	keywordsLang := fs.String("keywords-lang", "", `keywords per language, e.g. en:"control valve";de:"Regelventil"; replaces -keywords`)
+	keywordsLangMode := fs.String("keywords-lang-mode", "or", "or: one search with an OR group of every language's keywords; split: one search per language")
+	fs.StringVar(&cfg.criteria.Location, "location", "Bangalore", "candidate location")
+	fs.StringVar(&cfg.criteria.Industry, "industry", "Machinery Manufacturing", "candidate industry")
+	fs.StringVar(&cfg.criteria.ExperienceRange, "experience", "7-12 years", "experience range, e.g. \"7-12 years\"")
+	fs.StringVar(&cfg.criteria.CurrentCompany, "current-company", "", "employer candidates should work at now; those who only worked there before are marked past and down-scored")
+	fs.IntVar(&cfg.maxPages, "max-pages", maxPagesToScrape, "number of Google result pages to scrape per search")
+	deepCoverage := fs.Bool("deep-coverage", false, fmt.Sprintf("reach -max-pages beyond Google's %d-page ceiling by splitting the search into narrower queries", googleMaxPages))
+	fs.BoolVar(&cfg.singlePage, "single-page", false, fmt.Sprintf("ask Google for up to %d results per page, covering -max-pages in fewer requests; pages Google caps lower are followed by more", singlePageNum))
+	chunkTerms := fs.String("chunk-terms", "", "comma-separated terms narrowing each -deep-coverage query; single letters select profile URLs starting with them (default a-z)")
+	fs.StringVar(&cfg.output, "output", outputFilename, "CSV output filename")
+	fs.StringVar(&cfg.format, "format", formatCSV, "output format: csv, xlsx, json or jsonl for every candidate field, parquet for every candidate field with its type, atom to add new candidates to a feed, or template to execute -template-file (-columns applies to csv and xlsx only)")
+	templateFile := fs.String("template-file", "", "text/template file to write the output with, for -format template")
+	fs.IntVar(&cfg.feedMax, "feed-max-entries", defaultFeedMaxEntries, "with -format atom, the newest entries the feed keeps; older ones are dropped (0 keeps all)")
+	csvBOM := fs.Bool("csv-bom", false, "start the CSV with a UTF-8 byte order mark, so Excel on Windows reads accented names correctly")
+	fs.StringVar(&cfg.csv.encoding, "csv-encoding", encodingUTF8, "CSV encoding: utf-8, or utf-16 for older Excel (tab-separated UTF-16LE with a byte order mark)")
+	csvDelimiter := fs.String("csv-delimiter", "", `CSV field delimiter, such as ";" or tab (default "," or tab for -csv-encoding utf-16)`)
+	fs.BoolVar(&cfg.csv.quoteAll, "csv-quote-all", false, "quote every CSV field, not only those containing delimiters, quotes, or line breaks")
+	fs.IntVar(&cfg.flushEvery, "flush-every", 0, "write candidates to the CSV as they are found, flushing to disk every this many rows (0 writes everything at the end)")
+	fs.StringVar(&cfg.storePath, "store", "", "also add results to this candidate store, for later runs of verify")
+	fs.StringVar(&cfg.htmlReport, "html-report", "", "also write an HTML report of the run to this file")
+	webhooks := fs.String("webhook", "", "comma-separated URLs each kept candidate is POSTed to as JSON, with an Idempotency-Key header; undelivered candidates are retried by later runs")
+	fs.StringVar(&cfg.outboxPath, "outbox", "", "outbox journal of -webhook deliveries (default outbox.jsonl next to -output)")
+	alertRules := fs.String("alert-rules", "", "YAML file of alert rules, such as company_changed, checked against the -store as each run saves to it; alerts are POSTed to each rule's webhooks through the outbox")
+	fs.StringVar(&cfg.domainsOut, "domains-out", "", "also write the unique email domains of the candidates to this file, one per line")
+	fs.BoolVar(&cfg.excludeFreemail, "exclude-freemail", false, "leave free-mail providers out of -domains-out")
+	fs.StringVar(&cfg.freemailDomains, "freemail-domains", defaultFreemailDomains, "comma-separated free-mail domains skipped by -exclude-freemail")
+	fs.StringVar(&cfg.jobsFile, "jobs", "", "YAML file listing multiple searches to run in one invocation")
+	fs.StringVar(&cfg.jobsOutput, "jobs-output", jobsOutputPerJob, "batch output mode: per-job or combined")
+	addFetcherFlags(fs, &cfg.fetcherOptions)
+	fs.DurationVar(&cfg.timeWindow, "time-window", 0, "time the run must finish in; the pre-run estimate warns when it will not (0 disables)")
+	fs.BoolVar(&cfg.strictFeasibility, "strict-feasibility", false, "abort before fetching when the pre-run estimate exceeds -max-requests or -time-window")
+	fs.StringVar(&cfg.replayDir, "replay", "", "serve pages from this directory of -record fixtures instead of fetching them")
+	fs.StringVar(&cfg.recordDir, "record", "", "save every fetched page to this directory as a fixture for -replay")
+	fs.BoolVar(&cfg.deterministic, "deterministic", false, "with -replay, make output byte-identical across runs: fixed clock from the fixtures, -seed (default 1) for all randomness, no delays, and profiles fetched in order")
+	fs.IntVar(&cfg.fetcherOptions.maxRequests, "max-requests", 0, "abort with partial results after this many outbound requests in total (0 disables)")
+	fs.DurationVar(&cfg.maxIdle, "max-idle", 0, "abort with partial results when no new candidate is found for this long, e.g. 20m (0 disables)")
+	fs.DurationVar(&cfg.stallTimeout, "stall-timeout", 0, "log where the run is stuck when a stage (results, profiles, output) holds work but makes no progress for this long, e.g. 2m (0 disables)")
+	fs.StringVar(&cfg.stallAction, "stall-action", stallLog, "what to do about a -stall-timeout stall besides logging it: log, cancel the stalled items and go on, or abort with partial results")
+	fs.StringVar(&cfg.metricsAddr, "metrics-addr", "", "serve Prometheus metrics on this address at /metrics while the run lasts, e.g. 127.0.0.1:9090: requests, per-host and per-proxy latency percentiles of each request phase, and stage queue depths")
+	fs.IntVar(&cfg.minResults, "min-results", 0, "retry with relaxed criteria while a search keeps fewer candidates than this (0 disables)")
+	fs.IntVar(&cfg.maxRelaxation, "max-relaxation", len(relaxationSteps), "most relaxation steps -min-results may apply")
+	fs.BoolVar(&cfg.incrementalEnabled, "incremental", false, "write only candidates no earlier run saved to -store, skipping the others before their profiles are fetched")
+	fs.BoolVar(&cfg.fetcherOptions.learnedLimits, "learned-limits", false, "start each host class at 70% of the request rate earlier runs in -store sustained at this time of day through proxies of the same region, slowing down on blocks; default delays with fewer than 3 such runs")
+	fs.BoolVar(&cfg.ignoreNegativeCache, "ignore-negative-cache", false, "fetch profiles that -store records as recently gone (90 days), behind the authwall (7 days), or blocked (1 day) anyway")
+	fs.StringVar(&cfg.runDir, "run-dir", "", "keep parsed results pages and enriched profiles in this directory as the run goes, so a rerun with it after a crash resumes without fetching them again")
+	fs.Var(&cfg.seenTTL, "seen-ttl", "with -incremental, write a stored candidate again, marked rediscovered, once it was last seen this long ago, e.g. 180d (0 never)")
+	fs.Float64Var(&cfg.sampleRate, "sample", 0, "enrich and write only this random share of new candidates, e.g. 0.25, skipping those already in -store (0 disables)")
+	fs.Int64Var(&cfg.sampleSeed, "seed", 0, "random seed for -sample and -deterministic, for a reproducible sample (0 picks one and prints it)")
+	fs.StringVar(&cfg.sampleStratify, "sample-stratify", stratifyNone, "keep strata proportionally represented in the -sample: experience")
+	fs.IntVar(&cfg.minCandidatesPerPage, "min-candidates-per-page", 0, "stop paginating when a page yields fewer candidates than this (0 disables)")
+	fs.IntVar(&cfg.maxEmptyPages, "max-consecutive-empty-pages", 0, "stop paginating after this many consecutive pages yield no candidates; blocked pages do not count (0 disables)")
+	fs.IntVar(&cfg.profileBlockLimit, "no-profile-fetch-on-block", 0, "stop fetching profiles for the rest of the run after this many consecutive blocked profile fetches (0 disables)")
+	fs.IntVar(&cfg.profileOptions.concurrency, "profiles-concurrency", 1, "profiles fetched at once while enriching a page, still spaced by the rate limit; result pages are always fetched one at a time")
+	fs.DurationVar(&cfg.profileOptions.grace, "shutdown-grace", defaultShutdownGrace, "once a run is interrupted or stopped by -max-idle, let profile fetches in flight finish for up to this long and keep their results (0 abandons them at once)")
+	fs.BoolVar(&cfg.profileOptions.fetchContactInfo, "fetch-contact-info", false, "request each profile's contact-info overlay when the page does not embed it (one extra request per profile)")
+	experienceSource := fs.String("experience-source", experienceFromPositionDates, "where experience comes from when both have it: positions, the years the profile's position dates cover with overlaps counted once, or snippet; snippet is short for -field-priority experience=snippet,position_dates")
+	fs.BoolVar(&cfg.filterExperience, "filter-experience", false, "drop candidates whose parsed experience is outside the -experience range")
+	fs.IntVar(&cfg.experienceTolerance, "experience-tolerance", 0, "years of slack applied to each end of the range by -filter-experience")
+	fs.StringVar(&cfg.minCompanySize, "min-company-size", "", "drop candidates at companies smaller than this size band, e.g. 201-500")
+	fs.StringVar(&cfg.maxCompanySize, "max-company-size", "", "drop candidates at companies larger than this size band, e.g. 10001+")
+	fs.BoolVar(&cfg.guessEmails, "guess-emails", false, "guess a first.last@company address for candidates without an email")
+	domainsFile := fs.String("company-domains", "", "CSV (company,domain) or JSON map of company email domains used for guessing; implies -guess-emails")
+	fs.BoolVar(&cfg.requireLocation, "require-location", false, "drop candidates whose normalized city differs from -location's, or any place of a relaxed search's metro area (unknown locations pass, as do those naming only the state or country)")
+	profileLanguages := fs.String("profile-language", "", "comma-separated language codes to keep, e.g. en,hi, by the language the profile page declares or else the detected one (unknown ones pass unless -strict-lang)")
+	fs.StringVar(profileLanguages, "profile-lang", "", "short for -profile-language")
+	fs.BoolVar(&cfg.strictLanguage, "strict-lang", false, "with -profile-language, also drop candidates whose language is unknown")
+	fs.IntVar(&cfg.minCompleteness, "min-completeness", 0, "drop candidates whose profile completeness (0-100) is below this")
+	completeness := fs.String("completeness-weights", "", "override profile completeness weights, e.g. photo=25,headline=15,snippet=15,skills=15,education=15,connections=15")
+	fs.BoolVar(&cfg.requireEmail, "require-email", false, "drop candidates without an email address")
+	blocklistFile := fs.String("blocklist-names", "", `file of names to drop, such as "LinkedIn Member", one per line and matched case-insensitively, with * matching any run of characters; replaces the built-in list`)
+	fs.StringVar(&cfg.anonymized, "anonymized", anonymizedSkip, `what to do with anonymized "LinkedIn Member" results: skip them, or tag them in the anonymized column`)
+	fs.BoolVar(&cfg.dropSlugMismatch, "drop-name-slug-mismatch", false, "drop candidates whose name shares nothing with their profile URL, a sign of crossed extraction")
+	optOutBaseURL := fs.String("optout-base-url", "", "base URL of the optout-server's /optout/ path; every candidate with an email gets a signed opt-out link under it in the optout_url column")
+	optOutSecret := fs.String("optout-secret", "", "secret opt-out tokens are signed with, shared with the optout-server")
+	optOutListPath := fs.String("optout-list", "", "JSON lines file of candidates who opted out, as the optout-server writes it; they are dropped")
+	contactedFile := fs.String("contacted", "", "CSV export of people already contacted; candidates it lists by email, profile URL, or phone are marked in the contacted_by column")
+	contactedMap := fs.String("contacted-map", defaultContactedMap, "comma-separated field=column pairs naming the -contacted columns holding email, url, and phone; headers match case-insensitively")
+	fs.BoolVar(&cfg.dropContacted, "drop-contacted", false, "drop candidates the -contacted export lists instead of marking them")
+	fs.IntVar(&cfg.minScore, "min-score", 0, "drop candidates scoring below this")
+	weights := fs.String("score-weights", "", "override scoring weights, e.g. matched_term=10,email=5,phone=3,past_employer=-10,relaxation=-5,completeness=10")
+	fs.BoolVar(&cfg.showQuery, "show-query", false, "print the Google query of each search, including relaxed levels, and exit without fetching")
+	fs.BoolVar(&cfg.explainQuery, "explain-query", false, "print the query of each search broken down by component, with warnings about likely mistakes such as unbalanced quotes, and exit without fetching")
+	fs.BoolVar(&cfg.printSchema, "print-schema", false, "print the candidate fields written to CSV and Parquet, with their types and deprecations, and exit")
+	fs.StringVar(&cfg.engine, "engine", engineGoogle, "search engine whose syntax -show-query and -explain-query print queries in: "+strings.Join(engineNames(), ", "))
+	fs.BoolVar(&cfg.explainEnabled, "explain", false, "write every candidate's filter and score decisions to explain.jsonl next to the output")
+	fs.Float64Var(&cfg.mirrorThreshold, "mirror-threshold", defaultMirrorThreshold, "snippet similarity, 0 to 1, above which results on different hosts are taken for one profile and its mirrors, and merged into the one on www.linkedin.com; with -flush-every, later mirrors are left out of the stream instead (0 keeps them apart)")
+	fs.Var(&duplicateMergeStrategy, "merge-strategy", "how the records of a profile found twice are merged: priority picks each field's value by -field-priority; first, last, or most-complete prefer that record's fields and fill its gaps from the other")
+	fs.Var(&fieldPriorities, "field-priority", "sources of a field most trusted first, deciding which value wins when two differ, e.g. \"email=contact_info,about_text;name=result_name\"")
+	fs.BoolVar(&cfg.fieldSourcesEnabled, "field-sources", false, "write which selector or pattern filled each field of every candidate to field-sources.jsonl next to the output")
+	fs.IntVar(&experienceContextWindow, "experience-context-window", experienceContextWindow, "characters either side of an \"N years\" phrase searched for experience context words")
+	resolveShortlinks := fs.Bool("resolve-shortlinks", false, "replace short links in results, such as lnkd.in ones, with the URLs they redirect to before extraction")
+	shortlinkDomains := fs.String("shortlink-domains", defaultShortlinkDomains, "comma-separated domains of the short links -resolve-shortlinks follows")
+	shortlinkRPM := fs.Int("shortlink-rpm", defaultShortlinkRPM, "most short-link requests per minute with -resolve-shortlinks")
+	removeSelectors := fs.String("remove-selectors", "", "CSS selector, or comma-separated group of them, of noise nodes removed from results pages before extraction")
+	fs.Var(&googleDomain, "google-domain", "Google domain to search, such as google.co.in, for results localized to its country")
+	fs.Var(&outputZone, "tz", "time zone of the dates and times written to CSV, reports, and run info, as an IANA name such as Asia/Kolkata, or Local")
+	fs.BoolVar(&verbose, "verbose", false, "log extraction details, such as rejected phone matches")
+	fs.StringVar(&parseFailureDir, "parse-failure-dir", "", "directory receiving the HTML of pages that fail to parse even when fetched again (default parse-failures next to -output)")
+	fs.DurationVar(&cfg.jobCooldown, "job-cooldown", 0, "pause between jobs in a -jobs run, e.g. 2m")
+	fs.BoolVar(&cfg.jobResetSession, "job-reset-session", false, "discard cookies between jobs in a -jobs run")
+	columns := fs.String("columns", defaultColumns, "comma-separated CSV columns to write (available: "+columnKeys()+")")
+	resultParserName := fs.String("result-parser", "", "parser of search result pages: "+strings.Join(resultParserNames(), ", ")+"; defaults to "+defaultResultParser+", or "+selectorsResultParser+" with -result-selectors")
+	resultSelectorsFile := fs.String("result-selectors", "", "YAML file of CSS selectors replacing Google's for the selectors parser, with keys result, link, name, title, and snippet; keys left out keep the built-in selector")
+	extractorsFile := fs.String("extractors", "", "YAML file mapping extra field names to regular expressions run over each snippet and profile page, e.g. github: 'github\\.com/[\\w-]+'; each field becomes a column after -columns")
+	fs.StringVar(&cfg.cleanExisting, "clean-existing", "", "instead of searching, re-validate, de-duplicate, normalize, and filter this CSV from an earlier run and write it to -output, keeping its columns unless -columns is set")
+	fs.StringVar(&cfg.phoneFormat, "phone-format", phoneFormatRaw, "phone output format: raw, e164, or national")
+	fs.StringVar(&cfg.phoneRegion, "phone-region", "", "region assumed for phone numbers without a country code, US or IN (default: -location's country when it is one of those, else US)")
+	phoneCountries := fs.String("phone-countries", "", "comma-separated countries whose phone numbers are kept, e.g. IN,US; other numbers, and those of no recognizable country, are dropped (numbers without a calling code count as -phone-region)")
+	fs.BoolVar(&cfg.noRunInfo, "no-run-info", false, "omit the run info block from outputs: the CSV comment, JSON run_info, XLSX Run Info sheet, and report footer, for strict parsers")
+	criticalOutputs := fs.String("critical-outputs", defaultCriticalOutputs, "outputs whose failure fails the run with exit code 3; the others are retried, then disabled with a warning (available: "+strings.Join(knownOutputs, ",")+")")
+	configFile := fs.String("config", "", "YAML file of flag settings, such as the one written by `profilesearch init`; command-line flags take precedence")
+	configProfile := fs.String("profile", "", "named profile of the -config file to apply over its top-level settings, so several people can share one file; store, run-dir, and outbox paths inherited from the top level become the profile's own")
+	fs.BoolVar(&cfg.printConfig, "print-config", false, "print the settings the -config file, with its -profile, and the command line resolve to, and exit")
+	fs.Parse(args)
+	if *configFile != "" {
+		if err := applyConfigFile(fs, *configFile, *configProfile); err != nil {
+			return nil, err
+		}
+	} else if *configProfile != "" {
+		return nil, errors.New("-profile selects a profile of the -config file, so it needs -config")
+	}
+
+	fs.Visit(func(f *flag.Flag) { cfg.columnsGiven = cfg.columnsGiven || f.Name == "columns" })
+	cfg.effective = newRunConfig(fs, args)
+	cfg.flags = fs
+
+	// One breaker spans every search in the run, so a block in one job
+	// protects the next.
+	cfg.profileOptions.breaker = newProfileBreaker(cfg.profileBlockLimit)
+
+	var err error
+	if cfg.columns, err = selectCSVColumns(*columns); err != nil {
+		return nil, fmt.Errorf("invalid -columns: %w", err)
+	}
+	if *resultParserName == "" {
+		*resultParserName = defaultResultParser
+		if *resultSelectorsFile != "" {
+			*resultParserName = selectorsResultParser
+		}
+	}
+	if cfg.resultParser, err = findResultParser(*resultParserName, *resultSelectorsFile); err != nil {
+		return nil, fmt.Errorf("invalid -result-parser: %w", err)
+	}
+	if *extractorsFile != "" {
+		if fieldExtractors, err = loadExtractors(*extractorsFile); err != nil {
+			return nil, err
+		}
+		cfg.columns = append(cfg.columns, extraColumns()...)
+	}
+	if cfg.scoreWeights, err = parseScoreWeights(*weights); err != nil {
+		return nil, fmt.Errorf("invalid -score-weights: %w", err)
+	}
+	if cfg.completenessWeights, err = parseCompletenessWeights(*completeness); err != nil {
+		return nil, fmt.Errorf("invalid -completeness-weights: %w", err)
+	}
+	switch *experienceSource {
+	case experienceFromPositionDates:
+	case experienceFromSnippet:
+		fieldPriorities["experience"] = []string{sourceSnippet, sourcePositionDates}
+	default:
+		return nil, fmt.Errorf("invalid -experience-source %q: want %s or %s", *experienceSource, experienceFromPositionDates, experienceFromSnippet)
+	}
+	if cfg.experienceTolerance < 0 {
+		return nil, errors.New("invalid -experience-tolerance: must not be negative")
+	}
+	if cfg.maxRelaxation < 0 || cfg.maxRelaxation > len(relaxationSteps) {
+		return nil, fmt.Errorf("invalid -max-relaxation: must be between 0 and %d", len(relaxationSteps))
+	}
+	if cfg.minResults == 0 {
+		cfg.maxRelaxation = 0
+	}
+	if *removeSelectors != "" {
+		cfg.transforms.Add(transform.RemoveNodes(*removeSelectors))
+	}
+	if *resolveShortlinks {
+		if cfg.shortlinks, err = newShortlinkResolver(*shortlinkDomains, *shortlinkRPM); err != nil {
+			return nil, fmt.Errorf("invalid -resolve-shortlinks: %w", err)
+		}
+	}
+	if *blocklistFile != "" {
+		if cfg.blockedNames, err = loadNameBlocklist(*blocklistFile); err != nil {
+			return nil, fmt.Errorf("invalid -blocklist-names: %w", err)
+		}
+		verbosef("Loaded %d blocked names from %s.", cfg.blockedNames.size(), *blocklistFile)
+	} else if cfg.blockedNames, err = parseNameBlocklist(strings.NewReader(defaultBlockedNames)); err != nil {
+		return nil, err
+	}
+	if *domainsFile != "" {
+		if cfg.companyDomains, err = loadCompanyDomains(*domainsFile); err != nil {
+			return nil, fmt.Errorf("invalid -company-domains: %w", err)
+		}
+		cfg.guessEmails = true
+	}
+	for _, lang := range strings.Split(*profileLanguages, ",") {
+		if lang = strings.ToLower(strings.TrimSpace(lang)); lang != "" {
+			if !languageCodeRegex.MatchString(lang) {
+				return nil, fmt.Errorf("invalid -profile-language: %q is not a language code such as en", lang)
+			}
+			if _, ok := languageProfiles[lang]; !ok {
+				verbosef("%s is not a detected language; only pages declaring it will match -profile-language.", lang)
+			}
+			cfg.profileLanguages = append(cfg.profileLanguages, lang)
+		}
+	}
+	if cfg.strictLanguage && len(cfg.profileLanguages) == 0 {
+		return nil, errors.New("-strict-lang needs -profile-language")
+	}
+	if *keywordsLang != "" {
+		sets, err := parseKeywordsLang(*keywordsLang)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -keywords-lang: %w", err)
+		}
+		switch *keywordsLangMode {
+		case "or":
+			// phraseKeywords turns the comma-separated sets into an OR group.
+			keywords := make([]string, len(sets))
+			for i, set := range sets {
+				keywords[i] = set.Keywords
+			}
+			cfg.criteria.Keywords = strings.Join(keywords, ", ")
+		case "split":
+			if cfg.jobsFile != "" {
+				return nil, errors.New("-keywords-lang-mode split cannot be combined with -jobs")
+			}
+			for _, set := range sets {
+				job := Job{Name: set.Lang, SearchCriteria: cfg.criteria}
+				job.Keywords = set.Keywords
+				cfg.languageJobs = append(cfg.languageJobs, job)
+			}
+		default:
+			return nil, fmt.Errorf("invalid -keywords-lang-mode %q: want or or split", *keywordsLangMode)
+		}
+	}
+	if _, err := findEngine(cfg.engine); err != nil {
+		return nil, fmt.Errorf("invalid -engine: %w", err)
+	}
+	if cfg.engine != engineGoogle && !cfg.showQuery && !cfg.explainQuery {
+		return nil, fmt.Errorf("invalid -engine %s: only Google results are scraped, so other engines work with -show-query and -explain-query", cfg.engine)
+	}
+	if cfg.webhooks, err = parseWebhooks(*webhooks); err != nil {
+		return nil, fmt.Errorf("invalid -webhook: %w", err)
+	}
+	switch cfg.csv.encoding {
+	case encodingUTF8:
+		if *csvBOM {
+			cfg.csv.encoding = encodingUTF8BOM
+		}
+	case encodingUTF16:
+		// UTF-16 always starts with its byte order mark.
+	default:
+		return nil, fmt.Errorf("invalid -csv-encoding %q: want %s or %s", cfg.csv.encoding, encodingUTF8, encodingUTF16)
+	}
+	if *csvDelimiter != "" {
+		if cfg.csv.comma, err = parseCSVDelimiter(*csvDelimiter); err != nil {
+			return nil, fmt.Errorf("invalid -csv-delimiter: %w", err)
+		}
+	}
+	if cfg.csv != (csvFormat{encoding: encodingUTF8}) && cfg.format != formatCSV {
+		return nil, fmt.Errorf("-csv-bom, -csv-encoding, -csv-delimiter, and -csv-quote-all apply to CSV output, not -format %s", cfg.format)
+	}
+	switch cfg.format {
+	case formatCSV:
+	case formatParquet:
+		if cfg.flushEvery > 0 {
+			return nil, errors.New("-flush-every streams CSV rows, so it cannot be used with -format parquet")
+		}
+		if cfg.output == outputFilename {
+			cfg.output = strings.TrimSuffix(outputFilename, filepath.Ext(outputFilename)) + ".parquet"
+		}
+	case formatAtom:
+		if cfg.flushEvery > 0 {
+			return nil, errors.New("-flush-every streams CSV rows, so it cannot be used with -format atom")
+		}
+		if cfg.output == outputFilename {
+			cfg.output = strings.TrimSuffix(outputFilename, filepath.Ext(outputFilename)) + ".xml"
+		}
+	case formatJSON, formatJSONL, formatXLSX:
+		if cfg.flushEvery > 0 {
+			return nil, fmt.Errorf("-flush-every streams CSV rows, so it cannot be used with -format %s", cfg.format)
+		}
+		if cfg.output == outputFilename {
+			cfg.output = strings.TrimSuffix(outputFilename, filepath.Ext(outputFilename)) + "." + cfg.format
+		}
+	case formatTemplate:
+		if cfg.flushEvery > 0 {
+			return nil, errors.New("-flush-every streams CSV rows, so it cannot be used with -format template")
+		}
+		if *templateFile == "" {
+			return nil, errors.New("-format template needs -template-file")
+		}
+		if cfg.output == outputFilename {
+			// out.tsv.tmpl writes linkedin_candidates.tsv.
+			ext := filepath.Ext(strings.TrimSuffix(*templateFile, filepath.Ext(*templateFile)))
+			cfg.output = strings.TrimSuffix(outputFilename, filepath.Ext(outputFilename)) + firstNonEmpty(ext, ".txt")
+		}
+	default:
+		return nil, fmt.Errorf("invalid -format %q: want %s, %s, %s, %s, %s, %s, or %s", cfg.format, formatCSV, formatXLSX, formatJSON, formatJSONL, formatParquet, formatAtom, formatTemplate)
+	}
+	if *templateFile != "" && cfg.format != formatTemplate {
+		return nil, fmt.Errorf("-template-file applies to -format template, not -format %s", cfg.format)
+	}
+	if parseFailureDir == "" {
+		parseFailureDir = filepath.Join(filepath.Dir(cfg.output), "parse-failures")
+	}
+	if cfg.outboxPath == "" {
+		cfg.outboxPath = filepath.Join(filepath.Dir(cfg.output), "outbox.jsonl")
+	}
+	if *alertRules != "" {
+		if cfg.storePath == "" {
+			return nil, errors.New("-alert-rules needs -store, the history alerts are checked against")
+		}
+		if cfg.alertRules, err = loadAlertRules(*alertRules, cfg.webhooks); err != nil {
+			return nil, fmt.Errorf("invalid -alert-rules: %w", err)
+		}
+	}
+	if cfg.outputs, err = newOutputDispatcher(*criticalOutputs); err != nil {
+		return nil, fmt.Errorf("invalid -critical-outputs: %w", err)
+	}
+	if cfg.maxPages < 1 {
+		return nil, errors.New("invalid -max-pages: must be at least 1")
+	}
+	if cfg.maxPages > googleMaxPages && !*deepCoverage {
+		log.Printf("Warning: Google serves at most %d pages per query; scraping %d instead of -max-pages %d (use -deep-coverage to go deeper).", googleMaxPages, googleMaxPages, cfg.maxPages)
+	}
+	cfg.chunks = planChunks(cfg.maxPages, *deepCoverage, parseChunkTerms(*chunkTerms))
+	if cfg.profileOptions.concurrency < 1 {
+		return nil, errors.New("invalid -profiles-concurrency: must be at least 1")
+	}
+	if cfg.profileOptions.grace < 0 {
+		return nil, errors.New("invalid -shutdown-grace: must not be negative")
+	}
+	if cfg.mirrorThreshold < 0 || cfg.mirrorThreshold > 1 {
+		return nil, errors.New("invalid -mirror-threshold: must be between 0 and 1")
+	}
+	if cfg.stallTimeout < 0 {
+		return nil, errors.New("invalid -stall-timeout: must not be negative")
+	}
+	if !validStallAction(cfg.stallAction) {
+		return nil, fmt.Errorf("invalid -stall-action %q: want log, cancel, or abort", cfg.stallAction)
+	}
+	if cfg.incrementalEnabled && cfg.storePath == "" {
+		return nil, errors.New("-incremental needs -store, where earlier runs are recorded")
+	}
+	if cfg.seenTTL != 0 && !cfg.incrementalEnabled {
+		return nil, errors.New("-seen-ttl needs -incremental")
+	}
+	if cfg.fetcherOptions.learnedLimits && cfg.storePath == "" {
+		return nil, errors.New("-learned-limits needs -store, where the request rates of earlier runs are recorded")
+	}
+	if cfg.ignoreNegativeCache && cfg.storePath == "" {
+		return nil, errors.New("-ignore-negative-cache needs -store, where failed fetches are recorded")
+	}
+	if cfg.sampleRate < 0 || cfg.sampleRate > 1 {
+		return nil, errors.New("invalid -sample: must be between 0 and 1")
+	}
+	if cfg.anonymized != anonymizedSkip && cfg.anonymized != anonymizedTag {
+		return nil, fmt.Errorf("invalid -anonymized %q: want %s or %s", cfg.anonymized, anonymizedSkip, anonymizedTag)
+	}
+	if cfg.sampleStratify != stratifyNone && cfg.sampleStratify != stratifyExperience {
+		return nil, fmt.Errorf("invalid -sample-stratify %q: want %s", cfg.sampleStratify, stratifyExperience)
+	}
+	if experienceContextWindow < 0 {
+		return nil, errors.New("invalid -experience-context-window: must not be negative")
+	}
+	if cfg.replayDir != "" && cfg.recordDir != "" {
+		return nil, errors.New("-replay and -record cannot be combined")
+	}
+	if cfg.deterministic {
+		// Live pages differ from run to run, so only a replay can repeat.
+		if cfg.replayDir == "" {
+			return nil, errors.New("-deterministic needs -replay")
+		}
+		if fixedNow, err = replayClock(cfg.replayDir); err != nil {
+			return nil, err
+		}
+		if cfg.sampleSeed == 0 {
+			cfg.sampleSeed = 1
+		}
+		// The seed picks header profiles and proxies, and the breaker and
+		// budget count fetches in the order they finish.
+		cfg.fetcherOptions.seed = cfg.sampleSeed
+		cfg.profileOptions.concurrency = 1
+		cfg.jobCooldown = 0
+	}
+	if err := validateCompanySizeBand(cfg.minCompanySize); err != nil {
+		return nil, fmt.Errorf("invalid -min-company-size: %w", err)
+	}
+	if err := validateCompanySizeBand(cfg.maxCompanySize); err != nil {
+		return nil, fmt.Errorf("invalid -max-company-size: %w", err)
+	}
+	switch cfg.phoneFormat {
+	case phoneFormatRaw, phoneFormatE164, phoneFormatNational:
+	default:
+		return nil, fmt.Errorf("invalid -phone-format %q: want raw, e164, or national", cfg.phoneFormat)
+	}
+	if cfg.phoneRegion == "" {
+		cfg.phoneRegion = defaultPhoneRegion(cfg.criteria.Location)
+	}
+	cfg.phoneRegion = strings.ToUpper(cfg.phoneRegion)
+	if _, ok := phoneRegions[cfg.phoneRegion]; !ok {
+		return nil, fmt.Errorf("invalid -phone-region %q: want US or IN", cfg.phoneRegion)
+	}
+	for _, country := range strings.Split(*phoneCountries, ",") {
+		if country = strings.ToUpper(strings.TrimSpace(country)); country == "" {
+			continue
+		}
+		if !knownPhoneCountry(country) {
+			return nil, fmt.Errorf("invalid -phone-countries: unknown country %q", country)
+		}
+		if cfg.phoneCountries == nil {
+			cfg.phoneCountries = make(map[string]bool)
+		}
+		cfg.phoneCountries[country] = true
+	}
+	if cfg.format == formatTemplate {
+		if cfg.template, err = loadOutputTemplate(*templateFile, cfg.phoneRegion); err != nil {
+			return nil, fmt.Errorf("invalid -template-file: %w", err)
+		}
+	}
+	if *contactedFile != "" {
+		mapping, err := parseContactedMap(*contactedMap)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -contacted-map: %w", err)
+		}
+		// Phones are read in -phone-region, so it is resolved above.
+		if cfg.contacted, err = loadContacted(*contactedFile, mapping, *contactedMap != defaultContactedMap, cfg.phoneRegion); err != nil {
+			return nil, err
+		}
+		log.Printf("Loaded %d contacted identifiers from %s.", cfg.contacted.size(), *contactedFile)
+	} else if cfg.dropContacted {
+		return nil, errors.New("-drop-contacted needs -contacted")
+	}
+	if cfg.optOut, err = newOptOutSigner(*optOutBaseURL, *optOutSecret); err != nil {
+		return nil, err
+	}
+	if *optOutListPath != "" {
+		if cfg.optOuts, err = loadOptOutList(*optOutListPath); err != nil {
+			return nil, err
+		}
+		log.Printf("Loaded %d opted-out candidates from %s.", cfg.optOuts.size(), *optOutListPath)
+	}
+
+	return cfg, nil
+}
+
+// columnKeys lists the keys accepted by -columns.
+func columnKeys() string {
+	keys := make([]string, len(csvColumns))
+	for i, col := range csvColumns {
+		keys[i] = col.key
+	}
+	return strings.Join(keys, ",")
+}
+
+func main() {
+	ctx, stop := interruptContext()
+	defer stop()
+
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "lookup":
+			if err := runLookupCommand(ctx, os.Args[2:]); err != nil {
+				log.Fatalf("Lookup failed: %v", err)
+			}
+			return
+		case "verify":
+			if err := runVerifyCommand(ctx, os.Args[2:]); err != nil {
+				log.Fatalf("Verify failed: %v", err)
+			}
+			return
+		case "init":
+			if err := runInitCommand(ctx, os.Args[2:]); err != nil {
+				log.Fatalf("Init failed: %v", err)
+			}
+			return
+		case "proxy-bench":
+			if err := runProxyBenchCommand(ctx, os.Args[2:]); err != nil {
+				log.Fatalf("Proxy bench failed: %v", err)
+			}
+			return
+		case "fakeweb":
+			if err := runFakeWebCommand(ctx, os.Args[2:]); err != nil {
+				log.Fatalf("Fakeweb failed: %v", err)
+			}
+			return
+		case "optout-server":
+			if err := runOptOutServerCommand(ctx, os.Args[2:]); err != nil {
+				log.Fatalf("Opt-out server failed: %v", err)
+			}
+			return
+		case "scrub-fixture":
+			if err := runScrubFixtureCommand(os.Args[2:]); err != nil {
+				log.Fatalf("Scrub failed: %v", err)
+			}
+			return
+		case "outbox":
+			if err := runOutboxCommand(ctx, os.Args[2:]); err != nil {
+				log.Fatalf("Outbox failed: %v", err)
+			}
+			return
+		case "state":
+			if err := runStateCommand(os.Args[2:]); err != nil {
+				log.Fatalf("State failed: %v", err)
+			}
+			return
+		case "export-changes":
+			if err := runExportChangesCommand(os.Args[2:]); err != nil {
+				log.Fatalf("Export failed: %v", err)
+			}
+			return
+		case "pack":
+			if err := runPackCommand(os.Args[2:]); err != nil {
+				log.Fatalf("Pack failed: %v", err)
+			}
+			return
+		case "unpack":
+			if err := runUnpackCommand(os.Args[2:]); err != nil {
+				log.Fatalf("Unpack failed: %v", err)
+			}
+			return
+		case "rerun":
+			if err := runRerunCommand(ctx, os.Args[2:]); err != nil {
+				log.Printf("Rerun failed: %v", err)
+				os.Exit(exitCode(err))
+			}
+			return
+		}
+	}
+
+	if err := runSearchCommand(ctx, os.Args[1:]); err != nil {
+		log.Print(err)
+		os.Exit(exitCode(err))
+	}
+}
+
+// runSearchCommand runs the default search, or the jobs in a -jobs file, and
+// writes the results.
+func runSearchCommand(ctx context.Context, args []string) error {
+	cfg, err := parseFlags(args)
+	if err != nil {
+		return err
+	}
+	if cfg.showQuery {
+		return showQueries(cfg)
+	}
+	if cfg.explainQuery {
+		return explainQueries(cfg)
+	}
+	if cfg.printSchema {
+		printSchema(os.Stdout)
+		return nil
+	}
+	if cfg.printConfig {
+		return cfg.effective.printSettings(os.Stdout)
+	}
+	if cfg.cleanExisting != "" {
+		defer cfg.outputs.summary()
+		return runCleanExisting(cfg)
+	}
+	defer stats.logSummary()
+	defer cfg.outputs.summary()
+	defer reportOutcomes(cfg.outputs, filepath.Join(filepath.Dir(cfg.output), "outcomes.jsonl"))
+
+	if cfg.fetcherOptions.learnedLimits {
+		store, err := openStore(cfg.storePath)
+		if err != nil {
+			return err
+		}
+		cfg.fetcherOptions.rateHistory = store.rateSamples()
+	}
+
+	// A single fetcher (and so a single set of rate limiters) is shared by every search in the run.
+	var fetcher Fetcher
+	var hf *httpFetcher // Nil when replaying.
+	if cfg.replayDir != "" {
+		fetcher = &replayFetcher{dir: cfg.replayDir}
+	} else {
+		if hf, err = newHTTPFetcher(cfg.fetcherOptions); err != nil {
+			return err
+		}
+		defer hf.robots.report()
+		defer hf.close()
+		fetcher = hf
+	}
+	if cfg.recordDir != "" {
+		if fetcher, err = newRecordingFetcher(fetcher, cfg.recordDir); err != nil {
+			return err
+		}
+	}
+
+	if cfg.shortlinks != nil {
+		if hf == nil {
+			log.Print("Not resolving short links: replayed runs make no requests.")
+		} else {
+			// After the other transforms, so removed nodes are not resolved.
+			cfg.transforms.Add(cfg.shortlinks.transform(ctx, hf))
+		}
+	}
+
+	if cfg.storePath != "" {
+		// Held for the whole run, so profilesearch state cannot change the
+		// store between reading it and saving to it.
+		lock, err := lockStore(cfg.storePath)
+		if err != nil {
+			return err
+		}
+		defer lock.unlock()
+	}
+
+	if cfg.incrementalEnabled {
+		store, err := openStore(cfg.storePath)
+		if err != nil {
+			return err
+		}
+		cfg.incremental = newIncrementalRun(store, time.Duration(cfg.seenTTL))
+		cfg.incremental.describe()
+	}
+
+	if cfg.storePath != "" {
+		store, err := openStore(cfg.storePath)
+		if err != nil {
+			return err
+		}
+		cfg.profileOptions.negative = newNegativeCache(store, cfg.ignoreNegativeCache)
+		cfg.profileOptions.negative.describe()
+		defer reportSuppressedFetches()
+	}
+
+	if cfg.runDir != "" {
+		pending, err := openPendingRun(cfg.runDir)
+		if err != nil {
+			return err
+		}
+		pending.describe()
+		cfg.profileOptions.pending = pending
+		defer pending.close()
+	}
+
+	if cfg.sampleRate > 0 {
+		known := make(map[string]bool)
+		if cfg.storePath != "" {
+			store, err := openStore(cfg.storePath)
+			if err != nil {
+				return err
+			}
+			for _, sc := range store.candidates() {
+				known[sc.ProfileURL] = true
+			}
+		}
+		cfg.sampler = newSampler(cfg.sampleRate, cfg.sampleSeed, cfg.sampleStratify, known)
+		defer cfg.sampler.report()
+	}
+
+	if len(cfg.webhooks) > 0 || len(cfg.alertRules) > 0 {
+		// Opening the outbox also queues deliveries an earlier run left pending.
+		if err := cfg.outputs.deliver(outputWebhook, func() error {
+			box, err := openOutbox(cfg.outboxPath)
+			if err != nil {
+				return err
+			}
+			cfg.outbox = startOutboxWorker(ctx, box, cfg.webhooks, webhookDeliverer(&http.Client{Timeout: webhookTimeout}))
+			return nil
+		}); err != nil {
+			return err
+		}
+		defer cfg.outbox.stop()
+	}
+
+	ctx, cfg.watchdog = startIdleWatchdog(ctx, cfg.maxIdle)
+	defer cfg.watchdog.stop()
+	ctx, cfg.stalls = startStallWatchdog(ctx, cfg.stallTimeout, cfg.stallAction, cfg.metricsAddr != "")
+	defer cfg.stalls.stop()
+	if cfg.metricsAddr != "" {
+		stop, err := startMetricsServer(cfg.metricsAddr, cfg.stalls)
+		if err != nil {
+			return err
+		}
+		defer stop()
+	}
+
+	if cfg.explainEnabled {
+		explainFile := filepath.Join(filepath.Dir(cfg.output), "explain.jsonl")
+		if err := cfg.outputs.deliver(outputExplain, func() (err error) {
+			cfg.explain, err = openJSONLinesLog(explainFile, "explain")
+			return err
+		}); err != nil {
+			return err
+		}
+		if cfg.explain != nil {
+			defer func() {
+				if err := cfg.outputs.fail(outputExplain, cfg.explain.Close()); err != nil {
+					log.Print(err)
+				}
+			}()
+		}
+	}
+
+	if cfg.fieldSourcesEnabled {
+		sourcesFile := filepath.Join(filepath.Dir(cfg.output), "field-sources.jsonl")
+		if err := cfg.outputs.deliver(outputFieldSources, func() (err error) {
+			cfg.fieldSources, err = openJSONLinesLog(sourcesFile, "field sources")
+			return err
+		}); err != nil {
+			return err
+		}
+		if cfg.fieldSources != nil {
+			defer func() {
+				if err := cfg.outputs.fail(outputFieldSources, cfg.fieldSources.Close()); err != nil {
+					log.Print(err)
+				}
+			}()
+		}
+	}
+
+	if err := cfg.outputs.deliver(outputRunConfig, func() error {
+		return cfg.effective.write(filepath.Join(filepath.Dir(cfg.output), runConfigFile))
+	}); err != nil {
+		return err
+	}
+
+	if cfg.jobsFile != "" {
+		jobs, err := loadJobs(cfg.jobsFile)
+		if err != nil {
+			return fmt.Errorf("error loading jobs: %w", err)
+		}
+		if err := checkFeasibility(cfg, len(jobs)); err != nil {
+			return err
+		}
+		if err := runJobs(ctx, cfg, fetcher, jobs); err != nil {
+			return fmt.Errorf("error running jobs: %w", err)
+		}
+		return nil
+	}
+
+	if len(cfg.languageJobs) > 0 {
+		if err := checkFeasibility(cfg, len(cfg.languageJobs)); err != nil {
+			return err
+		}
+		if err := runJobs(ctx, cfg, fetcher, cfg.languageJobs); err != nil {
+			return fmt.Errorf("error running language searches: %w", err)
+		}
+		return nil
+	}
+
+	if err := checkFeasibility(cfg, 1); err != nil {
+		return err
+	}
+
+	if cfg.flushEvery > 0 {
+		if cfg.stream, err = openCSVStream(cfg.output, cfg.columns, cfg.runInfo(cfg.criteria, ""), cfg.flushEvery, cfg.csv); err != nil {
+			return fmt.Errorf("error writing CSV: %w", err)
+		}
+		// Closing flushes, so rows found before an interrupt are kept.
+		defer closeCSVStream(cfg.stream)
+	}
+
+	allCandidates, err := runSearch(ctx, cfg, fetcher, Job{SearchCriteria: cfg.criteria})
+	var outErr *outputError
+	if errors.As(err, &outErr) {
+		return err
+	}
+	if err != nil {
+		log.Printf("Search stopped early: %v", err)
+	}
+
+	if len(allCandidates) == 0 {
+		log.Println("No candidates found.")
+		return recordEmptyRun(cfg)
+	}
+
+	if cfg.stream == nil {
+		if err := cfg.outputs.deliver(outputCSV, func() error {
+			return writeOutput(cfg, allCandidates, cfg.output, cfg.columns, cfg.criteria, "")
+		}); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("Successfully wrote %d candidates to %s\n", len(allCandidates), cfg.output)
+	return writeSecondaryOutputs(cfg, allCandidates, cfg.criteria, "")
+}
+
+// recordEmptyRun records an -incremental run that found no new candidates in
+// the store, so the next run compares against it, along with the profile
+// fetch failures of any run.
+func recordEmptyRun(cfg *config) error {
+	if cfg.incremental == nil && !cfg.profileOptions.negative.changed() {
+		return nil
+	}
+	return cfg.outputs.deliver(outputStore, func() error { return saveToStore(cfg.storePath, nil, cfg.incremental, cfg.profileOptions.negative) })
+}
+
+// writeSecondaryOutputs writes a run's candidates to the -store, the
+// -domains-out list, and the -html-report, and queues them for the -webhook
+// URLs and the alerts they raise under -alert-rules, when set.
+func writeSecondaryOutputs(cfg *config, candidates []Candidate, criteria SearchCriteria, job string) error {
+	if cfg.outbox != nil {
+		if err := cfg.outputs.deliver(outputWebhook, func() error { return cfg.outbox.enqueue(candidates) }); err != nil {
+			return err
+		}
+	}
+	if len(cfg.alertRules) > 0 && cfg.outbox != nil {
+		// Alerts compare with the store, so they are raised before saving to it.
+		if err := cfg.outputs.deliver(outputAlerts, func() error { return queueAlerts(cfg, candidates) }); err != nil {
+			return err
+		}
+	}
+	if cfg.storePath != "" {
+		if err := cfg.outputs.deliver(outputStore, func() error {
+			return saveToStore(cfg.storePath, candidates, cfg.incremental, cfg.profileOptions.negative)
+		}); err != nil {
+			return err
+		}
+	}
+	if cfg.domainsOut != "" {
+		var exclude map[string]bool
+		if cfg.excludeFreemail {
+			exclude = parseDomainList(cfg.freemailDomains)
+		}
+		domains := emailDomains(candidates, exclude)
+		if err := cfg.outputs.deliver(outputDomains, func() error {
+			if err := writeDomains(domains, cfg.domainsOut); err != nil {
+				return err
+			}
+			fmt.Printf("Wrote %d email domains to %s\n", len(domains), cfg.domainsOut)
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+	if cfg.htmlReport != "" && len(candidates) > 0 {
+		return cfg.outputs.deliver(outputHTMLReport, func() error {
+			if err := writeHTMLReport(candidates, cfg.htmlReport, criteria, job, cfg.runInfo(criteria, job)); err != nil {
+				return err
+			}
+			fmt.Printf("Wrote HTML report to %s\n", cfg.htmlReport)
+			return nil
+		})
+	}
+	return nil
 }
@@ -0,0 +1,493 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func TestMinCandidatesPerPageStopsAtThinPage(t *testing.T) {
+	// Ten results on page one, three on page two: a partial block.
+	for _, tc := range []struct {
+		min, searches int
+	}{
+		{0, 3},
+		{5, 2},
+	} {
+		web, addr := startFakeWeb(t, fakeRoster(13))
+		output, err := runFakeSearch(t, addr, "-max-pages", "3", "-min-candidates-per-page", fmt.Sprint(tc.min))
+		if err != nil {
+			t.Fatalf("min %d: %v", tc.min, err)
+		}
+		if got := web.requests("search"); got != tc.searches {
+			t.Errorf("min %d: %d results pages fetched, want %d", tc.min, got, tc.searches)
+		}
+		if got := len(readFakeSearch(t, output)); got != 13 {
+			t.Errorf("min %d: %d candidates written, want 13", tc.min, got)
+		}
+	}
+}
+
+// readFixture returns the contents of a file in testdata.
+func readFixture(tb testing.TB, name string) []byte {
+	tb.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return data
+}
+
+// parseResultsFixture parses a results page as a search does.
+func parseResultsFixture(tb testing.TB, page []byte) []Candidate {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(page))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	candidates, err := scrapeGoogleSearchResults(doc)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return candidates
+}
+
+// parseProfileFixture parses a profile page as profile enrichment does.
+func parseProfileFixture(tb testing.TB, page []byte) Candidate {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(page))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return parseProfilePage(context.Background(), nil, "https://www.linkedin.com/in/priya-iyer-valves", page, doc, profileOptions{})
+}
+
+func BenchmarkParseResultsPage(b *testing.B) {
+	page := readFixture(b, "serp-100.html")
+	b.ReportAllocs()
+	b.SetBytes(int64(len(page)))
+	for b.Loop() {
+		parseResultsFixture(b, page)
+	}
+}
+
+func BenchmarkParseProfilePage(b *testing.B) {
+	page := readFixture(b, "profile.html")
+	b.ReportAllocs()
+	b.SetBytes(int64(len(page)))
+	for b.Loop() {
+		parseProfileFixture(b, page)
+	}
+}
+
+// Allocations per parse of the testdata fixtures, with headroom over what
+// they measure, that TestParseAllocations holds the parsing path to. Lower
+// them when a change makes parsing leaner.
+const (
+	maxResultsPageAllocs = 45000 // serp-100.html: 100 organic results.
+	maxProfilePageAllocs = 9500  // profile.html: a full public profile.
+)
+
+func TestParseAllocations(t *testing.T) {
+	serp, profile := readFixture(t, "serp-100.html"), readFixture(t, "profile.html")
+	if got := len(parseResultsFixture(t, serp)); got != 100 {
+		t.Fatalf("%d candidates parsed from serp-100.html, want 100", got)
+	}
+	if c := parseProfileFixture(t, profile); c.Name != "Priya Iyer" || c.Company != "Acme Valves" {
+		t.Fatalf("profile.html parsed as %q at %q", c.Name, c.Company)
+	}
+
+	if n := testing.AllocsPerRun(5, func() { parseResultsFixture(t, serp) }); n > maxResultsPageAllocs {
+		t.Errorf("parsing a results page took %.0f allocations, want at most %d", n, maxResultsPageAllocs)
+	}
+	if n := testing.AllocsPerRun(5, func() { parseProfileFixture(t, profile) }); n > maxProfilePageAllocs {
+		t.Errorf("parsing a profile page took %.0f allocations, want at most %d", n, maxProfilePageAllocs)
+	}
+}
+
+func TestMaxConsecutiveEmptyPagesStopsPagination(t *testing.T) {
+	// Ten results fill page one; every later page is empty.
+	for _, tc := range []struct {
+		max, searches int
+	}{
+		{0, 5},
+		{2, 3},
+	} {
+		web, addr := startFakeWeb(t, fakeRoster(10))
+		output, err := runFakeSearch(t, addr, "-max-pages", "5", "-max-consecutive-empty-pages", fmt.Sprint(tc.max))
+		if err != nil {
+			t.Fatalf("max %d: %v", tc.max, err)
+		}
+		if got := web.requests("search"); got != tc.searches {
+			t.Errorf("max %d: %d results pages fetched, want %d", tc.max, got, tc.searches)
+		}
+		if got := len(readFakeSearch(t, output)); got != 10 {
+			t.Errorf("max %d: %d candidates written, want 10", tc.max, got)
+		}
+	}
+}
+
+func TestResultPageURL(t *testing.T) {
+	base := "https://www.google.com/search?q=valve"
+	for _, tc := range []struct {
+		start, num int
+		want       string
+	}{
+		{0, 0, base},
+		{20, 0, base + "&start=20"},
+		{0, 100, base + "&num=100"},
+		{60, 40, base + "&num=40&start=60"},
+	} {
+		if got := resultPageURL(base, tc.start, tc.num); got != tc.want {
+			t.Errorf("resultPageURL(%d, %d) = %s, want %s", tc.start, tc.num, got, tc.want)
+		}
+	}
+}
+
+func TestSinglePageCoversMaxPages(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		scenario string
+		args     []string
+		searches int
+		found    int
+	}{
+		{"paginated", fakeRoster(60), nil, 3, 30},
+		{"single page", fakeRoster(60), []string{"-single-page"}, 1, 30},
+		// A page capped below num is followed by one for the rest.
+		{"capped", "serp:\n  max_num: 20\n" + fakeRoster(60), []string{"-single-page"}, 2, 30},
+		// An empty page ends the search early.
+		{"short roster", fakeRoster(25), []string{"-single-page"}, 2, 25},
+	} {
+		web, addr := startFakeWeb(t, tc.scenario)
+		output, err := runFakeSearch(t, addr, append([]string{"-max-pages", "3"}, tc.args...)...)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if got := web.requests("search"); got != tc.searches {
+			t.Errorf("%s: %d results pages fetched, want %d", tc.name, got, tc.searches)
+		}
+		if got := len(readFakeSearch(t, output)); got != tc.found {
+			t.Errorf("%s: %d candidates written, want %d", tc.name, got, tc.found)
+		}
+	}
+}
+
+func TestParseTotalResults(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+		ok   bool
+	}{
+		{"About 12,300 results (0.45 seconds)", 12300, true},
+		{"Page 2 of about 12,300 results (0.31 seconds)", 12300, true},
+		{"Ungefähr 12.300 Ergebnisse (0,45 Sekunden)", 12300, true},
+		{"Environ 12\u202f300 résultats", 12300, true},
+		{"1 result", 1, true},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		if got, ok := parseTotalResults(tt.text); got != tt.want || ok != tt.ok {
+			t.Errorf("parseTotalResults(%q) = %d, %v; want %d, %v", tt.text, got, ok, tt.want, tt.ok)
+		}
+	}
+
+	_, addr := startFakeWeb(t, fakeRoster(3))
+	if _, err := runFakeSearch(t, addr, "-max-pages", "1"); err != nil {
+		t.Fatal(err)
+	}
+	if stats.ApproxTotalResults != 3 {
+		t.Errorf("run recorded about %d results, want 3", stats.ApproxTotalResults)
+	}
+}
+
+// streamRows returns the rows of the candidates a CSV stream has put on disk
+// so far.
+func streamRows(t *testing.T, filename string) int {
+	t.Helper()
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		t.Fatalf("partial stream unreadable: %v\n%s", err, data)
+	}
+	return len(rows) - 1
+}
+
+func TestCSVStreamFlushesEveryNRows(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "candidates.csv")
+	s, err := openCSVStream(filename, csvColumns, nil, 2, csvFormat{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	candidate := func(i int) Candidate {
+		return Candidate{Name: fmt.Sprintf("Member %d", i), ProfileURL: fmt.Sprintf("https://www.linkedin.com/in/member-%d", i)}
+	}
+	if err := s.write([]Candidate{candidate(1)}); err != nil {
+		t.Fatal(err)
+	}
+	if got := streamRows(t, filename); got != 0 {
+		t.Errorf("%d rows on disk before the first flush, want 0", got)
+	}
+	if err := s.write([]Candidate{candidate(2), candidate(3)}); err != nil {
+		t.Fatal(err)
+	}
+	// The flush comes at the second row; the third waits for the fourth.
+	if got := streamRows(t, filename); got != 2 {
+		t.Errorf("%d rows on disk after 3 writes, want 2", got)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := streamRows(t, filename); got != 3 {
+		t.Errorf("%d rows on disk after Close, want 3", got)
+	}
+}
+
+func TestCSVStreamKeepsBatchesTogether(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "candidates.csv")
+	s, err := openCSVStream(filename, csvColumns, nil, 1, csvFormat{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	const writers, batch = 8, 5
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			candidates := make([]Candidate, batch)
+			for i := range candidates {
+				candidates[i] = Candidate{Name: fmt.Sprintf("Writer %d", w), ProfileURL: fmt.Sprintf("https://www.linkedin.com/in/w%d-%d", w, i)}
+			}
+			if err := s.write(candidates); err != nil {
+				t.Error(err)
+			}
+		}(w)
+	}
+	wg.Wait()
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	candidates := readFakeSearch(t, filename)
+	if len(candidates) != writers*batch {
+		t.Fatalf("%d rows written, want %d", len(candidates), writers*batch)
+	}
+	for i := 0; i < len(candidates); i += batch {
+		for _, c := range candidates[i : i+batch] {
+			if c.Name != candidates[i].Name {
+				t.Fatalf("rows %d to %d mix %s and %s", i, i+batch-1, candidates[i].Name, c.Name)
+			}
+		}
+	}
+}
+
+func TestCSVStreamHammeredFromManyWriters(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "candidates.csv")
+	s, err := openCSVStream(filename, csvColumns, nil, 7, csvFormat{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	const sources, batches = 50, 20
+	var wg sync.WaitGroup
+	for src := 0; src < sources; src++ {
+		wg.Add(1)
+		go func(src int) {
+			defer wg.Done()
+			for b := 0; b < batches; b++ {
+				candidates := make([]Candidate, 1+b%3)
+				for i := range candidates {
+					candidates[i] = Candidate{Name: fmt.Sprintf("Source %d", src), Rank: b}
+				}
+				if err := s.write(candidates); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(src)
+	}
+	wg.Wait()
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Each source's rows come out in the order it wrote them.
+	perSource := make(map[string]int)
+	last := make(map[string]int)
+	for _, c := range readFakeSearch(t, filename) {
+		if n := perSource[c.Name]; n > 0 && c.Rank < last[c.Name] {
+			t.Fatalf("%s wrote batch %d after batch %d", c.Name, c.Rank, last[c.Name])
+		}
+		perSource[c.Name]++
+		last[c.Name] = c.Rank
+	}
+	want := 0
+	for b := 0; b < batches; b++ {
+		want += 1 + b%3
+	}
+	if len(perSource) != sources {
+		t.Errorf("rows of %d sources written, want %d", len(perSource), sources)
+	}
+	for src, n := range perSource {
+		if n != want {
+			t.Errorf("%s: %d rows written, want %d", src, n, want)
+		}
+	}
+}
+
+// concurrencyFetcher serves an empty profile page after a pause, recording
+// the most fetches it had in flight at once.
+type concurrencyFetcher struct {
+	mu                sync.Mutex
+	inflight, most, n int
+}
+
+func (f *concurrencyFetcher) Fetch(ctx context.Context, pageURL string) ([]byte, error) {
+	f.mu.Lock()
+	f.inflight++
+	f.n++
+	f.most = max(f.most, f.inflight)
+	f.mu.Unlock()
+	time.Sleep(20 * time.Millisecond)
+	f.mu.Lock()
+	f.inflight--
+	f.mu.Unlock()
+	return []byte("<html><body></body></html>"), nil
+}
+
+func TestProfilesConcurrencySizesThePool(t *testing.T) {
+	stats = newRunStats()
+	for _, tc := range []struct {
+		concurrency, candidates, want int
+	}{
+		{1, 8, 1},
+		{3, 8, 3},
+		{10, 4, 4}, // No more workers than profiles.
+	} {
+		candidates := make([]Candidate, tc.candidates)
+		for i := range candidates {
+			candidates[i].ProfileURL = fmt.Sprintf("https://www.linkedin.com/in/member-%d", i+1)
+		}
+		f := &concurrencyFetcher{}
+		if err := enrichCandidates(context.Background(), f, "valve", candidates, profileOptions{concurrency: tc.concurrency}); err != nil {
+			t.Fatal(err)
+		}
+		if f.n != tc.candidates || f.most != tc.want {
+			t.Errorf("-profiles-concurrency %d: %d fetches, at most %d at once; want %d, at most %d", tc.concurrency, f.n, f.most, tc.candidates, tc.want)
+		}
+	}
+	if _, err := parseFlags([]string{"-keywords", "valve", "-profiles-concurrency", "0"}); err == nil {
+		t.Error("-profiles-concurrency 0 was accepted")
+	}
+}
+
+func TestPreviouslySeenOmittedWhenUnknown(t *testing.T) {
+	seen := time.Date(2026, 3, 4, 0, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		c    Candidate
+		want string
+	}{
+		{Candidate{ProfileURL: "https://www.linkedin.com/in/jane-doe"}, ""},
+		{Candidate{ProfileURL: "https://www.linkedin.com/in/jane-doe", Rediscovered: true, PreviouslySeen: &seen}, `"previously_seen":"2026-03-04T00:00:00Z"`},
+	} {
+		b, err := json.Marshal(tc.c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Contains(string(b), `"previously_seen"`); got != (tc.want != "") || !strings.Contains(string(b), tc.want) {
+			t.Errorf("%s, want previously_seen %q", b, tc.want)
+		}
+	}
+}
+
+func TestRemoveSelectorsChangesExtractedCount(t *testing.T) {
+	_, addr := startFakeWeb(t, fakeRoster(5))
+	output, err := runFakeSearch(t, addr, "-max-pages", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(readFakeSearch(t, output)); n != 5 {
+		t.Fatalf("%d candidates without a transform, want 5", n)
+	}
+	// One selector group, whose comma inside :has() must not split it.
+	output, err = runFakeSearch(t, addr, "-max-pages", "1", "-remove-selectors", `div.tF2Cxc:has(a[href$="/member-1"], a[href$="/member-2"])`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(readFakeSearch(t, output)); n != 3 {
+		t.Errorf("%d candidates with two results removed, want 3", n)
+	}
+}
+
+func TestResultPositionsAcrossPages(t *testing.T) {
+	_, addr := startFakeWeb(t, fakeRoster(13))
+	// Dropping Member 2 and Member 12 leaves Google's positions as they were.
+	blocked := filepath.Join(t.TempDir(), "blocked.txt")
+	if err := os.WriteFile(blocked, []byte("Member 2\nMember 12\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	output, err := runFakeSearch(t, addr, "-max-pages", "2", "-blocklist-names", blocked,
+		"-columns", "rank,page_position,overall_position,name,profile_url")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := readFakeSearch(t, output)
+	if len(got) != 11 {
+		t.Fatalf("%d candidates, want 11", len(got))
+	}
+	for _, c := range got {
+		var n int
+		fmt.Sscanf(c.Name, "Member %d", &n)
+		page := (n-1)%resultsPerPage + 1
+		if c.PagePosition != page || c.OverallPosition != n {
+			t.Errorf("%s at page position %d, overall %d; want %d and %d", c.Name, c.PagePosition, c.OverallPosition, page, n)
+		}
+	}
+
+	// -clean-existing reads the positions back.
+	cleaned := filepath.Join(t.TempDir(), "cleaned.csv")
+	cfg, err := parseFlags([]string{"-clean-existing", output, "-output", cleaned})
+	if err != nil {
+		t.Fatal(err)
+	}
+	captureLog(t)
+	if _, err := captureStdout(t, func() error { return runCleanExisting(cfg) }); err != nil {
+		t.Fatal(err)
+	}
+	again := readFakeSearch(t, cleaned)
+	if len(again) != len(got) {
+		t.Fatalf("%d candidates after cleaning, want %d", len(again), len(got))
+	}
+	for i, c := range again {
+		if c.PagePosition != got[i].PagePosition || c.OverallPosition != got[i].OverallPosition {
+			t.Errorf("%s cleaned to positions %d and %d, want %d and %d", c.Name, c.PagePosition, c.OverallPosition, got[i].PagePosition, got[i].OverallPosition)
+		}
+	}
+}
+
+func TestResultPositionsOnSinglePage(t *testing.T) {
+	_, addr := startFakeWeb(t, fakeRoster(13))
+	output, err := runFakeSearch(t, addr, "-max-pages", "2", "-single-page", "-columns", "page_position,overall_position,name,profile_url")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := readFakeSearch(t, output)
+	if len(got) != 13 {
+		t.Fatalf("%d candidates, want 13", len(got))
+	}
+	for i, c := range got {
+		if c.PagePosition != i+1 || c.OverallPosition != i+1 {
+			t.Errorf("%s at page position %d, overall %d; want both %d on the one page", c.Name, c.PagePosition, c.OverallPosition, i+1)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+
+	"checker/transform"
+)
+
+// Defaults of -shortlink-domains and -shortlink-rpm.
+const (
+	defaultShortlinkDomains = "lnkd.in,bit.ly"
+	defaultShortlinkRPM     = 30
+)
+
+// shortlinkMaxHops bounds the redirects followed from one short link.
+const shortlinkMaxHops = 10
+
+// shortlinkResolver replaces short links, such as lnkd.in ones, with the URLs
+// they redirect to, found with HEAD requests sent through the run's fetcher.
+// At most one link is resolved per interval, and each link once per run. It
+// is safe for concurrent use.
+type shortlinkResolver struct {
+	pattern  *regexp.Regexp // Matches links on the short-link domains.
+	interval time.Duration
+
+	mu       sync.Mutex
+	next     time.Time         // Earliest time of the next request.
+	resolved map[string]string // Final URL by short link without scheme; the link itself when it failed.
+}
+
+// newShortlinkResolver returns a resolver of links on the comma-separated
+// domains, sending at most rpm requests per minute.
+func newShortlinkResolver(domains string, rpm int) (*shortlinkResolver, error) {
+	if rpm <= 0 {
+		return nil, fmt.Errorf("rate must be positive, not %d", rpm)
+	}
+	var quoted []string
+	for _, d := range strings.Split(domains, ",") {
+		d = strings.ToLower(strings.TrimSpace(d))
+		if d == "" {
+			continue
+		}
+		if strings.ContainsAny(d, "/:") {
+			return nil, fmt.Errorf("%q is not a domain", d)
+		}
+		quoted = append(quoted, regexp.QuoteMeta(d))
+	}
+	if len(quoted) == 0 {
+		return nil, fmt.Errorf("no domains in %q", domains)
+	}
+	pattern := regexp.MustCompile(`(?i)\b(?:https?://)?(?:www\.)?(?:` + strings.Join(quoted, "|") + `)/[\w\-]+`)
+	return &shortlinkResolver{
+		pattern:  pattern,
+		interval: time.Minute / time.Duration(rpm),
+		resolved: make(map[string]string),
+	}, nil
+}
+
+// resolve returns the URL link finally redirects to, or link itself when it
+// cannot be resolved.
+func (r *shortlinkResolver) resolve(ctx context.Context, f *httpFetcher, link string) string {
+	key := shortlinkKey(link)
+	r.mu.Lock()
+	if final, ok := r.resolved[key]; ok {
+		r.mu.Unlock()
+		return final
+	}
+	now := clockNow()
+	wait := r.next.Sub(now)
+	if wait < 0 {
+		r.next = now
+	}
+	r.next = r.next.Add(r.interval)
+	r.mu.Unlock()
+
+	final := link
+	if err := sleepContext(ctx, wait); err == nil {
+		if resolved, err := r.follow(ctx, f, link); err != nil {
+			verbosef("Failed to resolve short link %s: %v", link, err)
+		} else {
+			verbosef("Resolved short link %s to %s", link, resolved)
+			final = resolved
+		}
+	}
+	r.mu.Lock()
+	r.resolved[key] = final
+	r.mu.Unlock()
+	return final
+}
+
+// shortlinkKey returns link without its scheme or "www.", so "lnkd.in/x" and
+// "https://www.lnkd.in/x" are resolved once.
+func shortlinkKey(link string) string {
+	if _, rest, ok := strings.Cut(link, "://"); ok {
+		link = rest
+	}
+	host, path, _ := strings.Cut(link, "/")
+	return strings.TrimPrefix(strings.ToLower(host), "www.") + "/" + path
+}
+
+// follow follows link's redirects with HEAD requests through f, so under the
+// proxies, identities, robots.txt rules, rate limits, and request budget of
+// every other request, until one leads off the short-link domains, and
+// returns where it leads.
+func (r *shortlinkResolver) follow(ctx context.Context, f *httpFetcher, link string) (string, error) {
+	if !strings.Contains(link, "://") {
+		link = "https://" + link
+	}
+	for hop := 0; hop < shortlinkMaxHops; hop++ {
+		next, err := f.redirect(ctx, link)
+		if err != nil {
+			return "", err
+		}
+		if next == nil {
+			return "", errors.New("not a redirect")
+		}
+		if base, err := url.Parse(link); err == nil {
+			next = base.ResolveReference(next)
+		}
+		link = next.String()
+		if !r.pattern.MatchString(link) {
+			return link, nil
+		}
+	}
+	return "", fmt.Errorf("still a short link after %d redirects", shortlinkMaxHops)
+}
+
+// rewrite replaces the short links in text with their final URLs.
+func (r *shortlinkResolver) rewrite(ctx context.Context, f *httpFetcher, text string) string {
+	return r.pattern.ReplaceAllStringFunc(text, func(link string) string {
+		return r.resolve(ctx, f, link)
+	})
+}
+
+// transform returns a document transform replacing the short links in result
+// snippets and links, resolved through f, so extraction sees the URLs they
+// stand for.
+func (r *shortlinkResolver) transform(ctx context.Context, f *httpFetcher) transform.Func {
+	return func(doc *goquery.Document) {
+		doc.Find(googleSnippetSelector).Each(func(_ int, s *goquery.Selection) {
+			text := s.Text()
+			if rewritten := r.rewrite(ctx, f, text); rewritten != text {
+				s.SetText(rewritten)
+			}
+		})
+		doc.Find(".tF2Cxc a[href]").Each(func(_ int, s *goquery.Selection) {
+			href := s.AttrOr("href", "")
+			if u, err := url.Parse(href); err == nil && r.pattern.MatchString(u.Host+u.Path) {
+				s.SetAttr("href", r.rewrite(ctx, f, href))
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestShortlinkResolvesThroughFetcher(t *testing.T) {
+	web, addr := startFakeWeb(t, `
+robots: "User-agent: *\nAllow: /\n"
+shortlinks:
+  /3xYz: https://bit.ly/jane
+  /jane: https://www.linkedin.com/in/jane-doe
+  /john: https://www.linkedin.com/in/john-roe
+`)
+	opts := fakeFetcherOptions(t, addr)
+	opts.maxRequests = 4
+	f, err := newHTTPFetcher(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := newShortlinkResolver("lnkd.in,bit.ly", 60000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	// Two hops, each host's robots.txt checked first, and the profile the
+	// chain leads to is not requested.
+	const text = "Contact me at lnkd.in/3xYz or lnkd.in/3xYz."
+	const want = "Contact me at https://www.linkedin.com/in/jane-doe or https://www.linkedin.com/in/jane-doe."
+	if got := r.rewrite(ctx, f, text); got != want {
+		t.Errorf("rewrite(%q) = %q, want %q", text, got, want)
+	}
+	if got := web.requests("shortlink"); got != 2 {
+		t.Errorf("%d short-link requests, want 2", got)
+	}
+	if got := web.requests("robots"); got != 2 {
+		t.Errorf("%d robots.txt requests, want 2", got)
+	}
+	if got := web.requests("profile"); got != 0 {
+		t.Errorf("%d profile requests, want 0", got)
+	}
+
+	// The four requests spent the budget, so a new link stays as it is.
+	if got := r.rewrite(ctx, f, "bit.ly/john"); got != "bit.ly/john" {
+		t.Errorf("rewrite past the budget = %q, want the link unresolved", got)
+	}
+	if got := web.requests(); got != 4 {
+		t.Errorf("%d requests made, want 4", got)
+	}
+}
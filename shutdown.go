@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"time"
+)
+
+// defaultShutdownGrace is how long profile fetches in flight when a run is
+// interrupted may take to finish, unless -shutdown-grace is set.
+const defaultShutdownGrace = 10 * time.Second
+
+// withShutdownGrace returns a context for work already under way when ctx is
+// cancelled. It is cancelled grace after ctx is, so a profile fetch in flight
+// can finish and its result be kept, while new work checks ctx and does not
+// start. With a grace of 0 it returns ctx. The returned cancel releases the
+// context once the work is done.
+func withShutdownGrace(ctx context.Context, grace time.Duration) (context.Context, context.CancelFunc) {
+	if grace <= 0 {
+		return ctx, func() {}
+	}
+	inflight, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	go func() {
+		select {
+		case <-inflight.Done():
+			return
+		case <-ctx.Done():
+		}
+		timer := time.NewTimer(grace)
+		defer timer.Stop()
+		select {
+		case <-inflight.Done():
+		case <-timer.C:
+			log.Printf("Fetches in flight did not finish within %s of the stop; abandoning them.", grace)
+			cancel()
+		}
+	}()
+	return inflight, cancel
+}
+
+// interruptContext returns a context cancelled by the first interrupt. The
+// default handling is restored then, so a second interrupt exits at once
+// instead of waiting for the shutdown to finish.
+func interruptContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	go func() {
+		defer signal.Stop(interrupts)
+		select {
+		case <-interrupts:
+			log.Print("Interrupted; finishing fetches in flight and writing partial results. Interrupt again to exit now.")
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// slowFetcher serves every profile after delay, unless its context is
+// cancelled first, and reports each fetch it starts.
+type slowFetcher struct {
+	delay   time.Duration
+	started chan string
+	fetched atomic.Int64
+}
+
+func (f *slowFetcher) Fetch(ctx context.Context, pageURL string) ([]byte, error) {
+	f.started <- pageURL
+	select {
+	case <-time.After(f.delay):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	f.fetched.Add(1)
+	return []byte(`<body><h1 class="top-card-layout__title">Fetched Name</h1></body>`), nil
+}
+
+// shutdownCandidates returns n results whose names come from their snippets.
+func shutdownCandidates(n int) []Candidate {
+	candidates := make([]Candidate, n)
+	for i := range candidates {
+		candidates[i] = snippetCandidate(resultTypeOrganic, fmt.Sprintf("https://www.linkedin.com/in/member-%d", i+1), fmt.Sprintf("Member %d", i+1), "", "")
+	}
+	return candidates
+}
+
+// stopOnceStarted cancels the run once n fetches are in flight.
+func stopOnceStarted(t *testing.T, f *slowFetcher, n int, cancel context.CancelFunc) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		select {
+		case <-f.started:
+		case <-time.After(5 * time.Second):
+			t.Fatal("fetches did not start")
+		}
+	}
+	cancel()
+}
+
+func TestShutdownGraceKeepsInFlightResults(t *testing.T) {
+	captureLog(t)
+	f := &slowFetcher{delay: 100 * time.Millisecond, started: make(chan string, 10)}
+	candidates := shutdownCandidates(4)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- enrichCandidates(ctx, f, "valve", candidates, profileOptions{concurrency: 2, grace: 5 * time.Second})
+	}()
+	stopOnceStarted(t, f, 2, cancel)
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("enrichment returned %v, want the cancellation", err)
+	}
+	// The two in flight finish within the grace and keep their profiles'
+	// data; no further profile is started once the run is stopped.
+	if n := f.fetched.Load(); n != 2 {
+		t.Errorf("%d fetches finished, want the 2 in flight", n)
+	}
+	for i, c := range candidates {
+		want := fmt.Sprintf("Member %d", i+1)
+		if i < 2 {
+			want = "Fetched Name"
+		}
+		if c.Name != want {
+			t.Errorf("candidate %d named %q, want %q", i+1, c.Name, want)
+		}
+	}
+}
+
+func TestShutdownGraceAbandonsSlowFetches(t *testing.T) {
+	logged := captureLog(t)
+	f := &slowFetcher{delay: time.Minute, started: make(chan string, 10)}
+	candidates := shutdownCandidates(2)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- enrichCandidates(ctx, f, "valve", candidates, profileOptions{concurrency: 2, grace: 50 * time.Millisecond})
+	}()
+	stopOnceStarted(t, f, 2, cancel)
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("enrichment returned %v, want the cancellation", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("enrichment waited past the grace")
+	}
+	// The abandoned candidates keep their snippet data.
+	if candidates[0].Name != "Member 1" || candidates[1].Name != "Member 2" {
+		t.Errorf("candidates %+v, want their results' names", candidates)
+	}
+	if !strings.Contains(logged.String(), "did not finish within 50ms") {
+		t.Errorf("log %q, want the abandoned fetches noted", logged)
+	}
+}
+
+func TestShutdownGraceDisabled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if got, release := withShutdownGrace(ctx, 0); got != ctx {
+		t.Error("a grace of 0 gave a separate context")
+	} else {
+		release()
+	}
+	if _, err := parseFlags([]string{"-shutdown-grace", "-1s"}); err == nil {
+		t.Error("a negative -shutdown-grace was accepted")
+	}
+}
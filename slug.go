@@ -0,0 +1,90 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+	"unicode"
+)
+
+// minSlugTokenMatch is the shortest name token matched inside a slug written
+// without separators, such as "janedoe"; shorter tokens could match by chance.
+const minSlugTokenMatch = 3
+
+// latinFolds maps accented Latin letters to the plain letters LinkedIn puts
+// in slugs.
+var latinFolds = strings.NewReplacer(
+	"á", "a", "à", "a", "â", "a", "ä", "a", "ã", "a", "å", "a", "ā", "a",
+	"ç", "c", "č", "c", "ć", "c",
+	"é", "e", "è", "e", "ê", "e", "ë", "e", "ē", "e", "ę", "e",
+	"í", "i", "ì", "i", "î", "i", "ï", "i", "ī", "i",
+	"ñ", "n", "ń", "n",
+	"ó", "o", "ò", "o", "ô", "o", "ö", "o", "õ", "o", "ø", "o", "ō", "o",
+	"ú", "u", "ù", "u", "û", "u", "ü", "u", "ū", "u",
+	"ý", "y", "ÿ", "y", "ś", "s", "š", "s", "ß", "ss", "ž", "z", "ź", "z", "ż", "z", "ł", "l",
+)
+
+// profileSlug returns the slug of a LinkedIn profile URL, the path segment
+// after /in/ without any query or fragment, decoded and lower-cased.
+func profileSlug(profileURL string) string {
+	_, slug, ok := strings.Cut(profileURL, "/in/")
+	if !ok {
+		return ""
+	}
+	if i := strings.IndexAny(slug, "/?#"); i >= 0 {
+		slug = slug[:i]
+	}
+	if decoded, err := url.PathUnescape(slug); err == nil {
+		slug = decoded
+	}
+	return strings.ToLower(slug)
+}
+
+// slugTokens splits a slug at its separators and drops the parts holding
+// digits, which are LinkedIn's disambiguating suffixes such as "1a2b3c4".
+func slugTokens(slug string) []string {
+	var tokens []string
+	for _, t := range strings.FieldsFunc(latinFolds.Replace(slug), func(r rune) bool { return r == '-' || r == '_' || r == '.' }) {
+		if strings.IndexFunc(t, unicode.IsDigit) < 0 {
+			tokens = append(tokens, t)
+		}
+	}
+	return tokens
+}
+
+// nameMatchesSlug reports whether a profile slug plausibly belongs to name.
+// It is lenient: tokens may come in any order, a single letter matches an
+// initial, and a name token may sit inside a slug written without
+// separators. known is false when there is nothing to compare, such as a
+// name in a non-Latin script or an opaque slug.
+func nameMatchesSlug(name, slug string) (matches, known bool) {
+	var names []string
+	for _, t := range nameTokens(latinFolds.Replace(strings.ToLower(name))) {
+		if strings.IndexFunc(t, func(r rune) bool { return r > unicode.MaxASCII }) < 0 {
+			names = append(names, t)
+		}
+	}
+	slugs := slugTokens(slug)
+	if len(names) == 0 || len(slugs) == 0 {
+		return false, false
+	}
+	joined := strings.Join(slugs, "")
+	for _, n := range names {
+		for _, s := range slugs {
+			if s == n || (len(s) == 1 && n[0] == s[0]) || (len(n) == 1 && s[0] == n[0]) {
+				return true, true
+			}
+		}
+		if len(n) >= minSlugTokenMatch && strings.Contains(joined, n) {
+			return true, true
+		}
+	}
+	return false, true
+}
+
+// nameSlugMismatch reports whether a candidate's name and profile slug
+// clearly disagree, which suggests extraction paired a result's name with
+// another result's link.
+func nameSlugMismatch(c Candidate) bool {
+	matches, known := nameMatchesSlug(resultName(c), profileSlug(c.ProfileURL))
+	return known && !matches
+}
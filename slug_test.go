@@ -0,0 +1,82 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestProfileSlug(t *testing.T) {
+	for u, want := range map[string]string{
+		"https://www.linkedin.com/in/Jane-Doe-1a2b3c":         "jane-doe-1a2b3c",
+		"https://in.linkedin.com/in/jane-doe/?trk=public":     "jane-doe",
+		"https://www.linkedin.com/in/j%C3%BCrgen-m%C3%BCller": "jürgen-müller",
+		"https://www.linkedin.com/company/emerson":            "",
+	} {
+		if got := profileSlug(u); got != want {
+			t.Errorf("profileSlug(%s) = %q, want %q", u, got, want)
+		}
+	}
+}
+
+func TestNameMatchesSlug(t *testing.T) {
+	tests := []struct {
+		name, slug     string
+		matches, known bool
+	}{
+		{"Jane Doe", "jane-doe-1a2b3c", true, true},
+		{"Jane Doe", "doe-jane", true, true},           // Any order.
+		{"Jane Doe", "j-doe-42", true, true},           // An initial.
+		{"J. Doe", "jane-doe", true, true},             // An initial in the name.
+		{"Jane Doe", "janedoe", true, true},            // No separators.
+		{"Jürgen Müller", "jurgen-muller", true, true}, // Accents folded.
+		{"Jane Doe", "bob-smith-123", false, true},
+		{"Jane Doe", "1a2b3c4", false, false}, // An opaque slug.
+		{"张伟", "zhang-wei", false, false},     // A name in another script.
+	}
+	for _, tt := range tests {
+		matches, known := nameMatchesSlug(tt.name, tt.slug)
+		if matches != tt.matches || known != tt.known {
+			t.Errorf("nameMatchesSlug(%q, %q) = %v, %v; want %v, %v", tt.name, tt.slug, matches, known, tt.matches, tt.known)
+		}
+	}
+}
+
+func TestNameSlugMismatch(t *testing.T) {
+	c := Candidate{ProfileURL: "https://www.linkedin.com/in/bob-smith-123", ResultTitle: "Jane Doe - Valve Engineer | LinkedIn"}
+	if !nameSlugMismatch(c) {
+		t.Error("Jane Doe at bob-smith-123 not flagged")
+	}
+	c.ProfileURL = "https://www.linkedin.com/in/jane-doe-4821"
+	if nameSlugMismatch(c) {
+		t.Error("Jane Doe at jane-doe-4821 flagged")
+	}
+}
+
+func TestDropNameSlugMismatch(t *testing.T) {
+	scenario := `profiles:
+  - slug: jane-doe
+    name: Jane Doe
+  - slug: bob-smith-123
+    name: Jane Roe
+`
+	for _, tc := range []struct {
+		args []string
+		want []string
+	}{
+		{nil, []string{"Jane Doe", "Jane Roe"}}, // Flagged, but kept.
+		{[]string{"-drop-name-slug-mismatch"}, []string{"Jane Doe"}},
+	} {
+		_, addr := startFakeWeb(t, scenario)
+		output, err := runFakeSearch(t, addr, append([]string{"-max-pages", "1"}, tc.args...)...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, c := range readFakeSearch(t, output) {
+			names = append(names, c.Name)
+		}
+		if !slices.Equal(names, tc.want) {
+			t.Errorf("%v: wrote %q, want %q", tc.args, names, tc.want)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Actions of -stall-action, taken when a stage stalls.
+const (
+	stallLog    = "log"    // Log the diagnostic dump only.
+	stallCancel = "cancel" // Also cancel the stalled stage's items in flight, which are given up on.
+	stallAbort  = "abort"  // Also stop the run, which writes its partial results.
+)
+
+// The stages of a search, in pipeline order.
+const (
+	stageResults  = "results"  // Fetching and parsing result pages.
+	stageProfiles = "profiles" // Enriching candidates from their profiles.
+	stageOutput   = "output"   // Finalizing and writing a page's candidates.
+)
+
+// errStalled is the cause of a run stopped by -stall-action abort.
+var errStalled = errors.New("a stage made no progress within -stall-timeout")
+
+// pipelineStage counts the items going into and out of one stage of a run,
+// and when it last made progress, for the stall watchdog. A nil
+// pipelineStage counts nothing.
+type pipelineStage struct {
+	name string
+
+	mu       sync.Mutex
+	in, out  int
+	last     time.Time // When an item last finished, or the idle stage got one.
+	inflight map[*stageItem]bool
+}
+
+// stageItem is an item in flight in a stage, such as a profile fetch.
+type stageItem struct {
+	label   string // The URL fetched, or what else the item is.
+	started time.Time
+	cancel  context.CancelCauseFunc
+	stalled bool // Cancelled by the watchdog.
+}
+
+func newPipelineStage(name string) *pipelineStage {
+	return &pipelineStage{name: name, last: time.Now(), inflight: make(map[*stageItem]bool)}
+}
+
+// begin records an item going into the stage. The item's work runs under the
+// returned context, which the watchdog cancels under -stall-action cancel;
+// done records the item coming out and reports whether it was cancelled so.
+func (s *pipelineStage) begin(ctx context.Context, label string) (context.Context, func() (stalled bool)) {
+	if s == nil {
+		return ctx, func() bool { return false }
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	item := &stageItem{label: label, started: time.Now(), cancel: cancel}
+	s.mu.Lock()
+	if len(s.inflight) == 0 {
+		s.last = item.started // An idle stage has not been stalling.
+	}
+	s.in++
+	s.inflight[item] = true
+	s.mu.Unlock()
+	return ctx, func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.inflight[item] {
+			delete(s.inflight, item)
+			s.out++
+			s.last = time.Now()
+		}
+		cancel(nil)
+		return item.stalled
+	}
+}
+
+// stageSnapshot is the state of a stage at one moment.
+type stageSnapshot struct {
+	Name         string
+	In, Out      int
+	LastProgress time.Time
+	InFlight     []inFlightItem // Oldest first.
+}
+
+// inFlightItem is an item in flight and how long it has been.
+type inFlightItem struct {
+	Label string
+	Age   time.Duration
+}
+
+// pending returns the items in the stage that have not come out.
+func (s stageSnapshot) pending() int {
+	return s.In - s.Out
+}
+
+// snapshot returns the state of the stage at now.
+func (s *pipelineStage) snapshot(now time.Time) stageSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snap := stageSnapshot{Name: s.name, In: s.in, Out: s.out, LastProgress: s.last}
+	for item := range s.inflight {
+		snap.InFlight = append(snap.InFlight, inFlightItem{item.label, now.Sub(item.started)})
+	}
+	sort.Slice(snap.InFlight, func(i, j int) bool { return snap.InFlight[i].Age > snap.InFlight[j].Age })
+	return snap
+}
+
+// cancelOlder cancels the items in flight for at least age, which are
+// abandoned, and returns how many it cancelled.
+func (s *pipelineStage) cancelOlder(now time.Time, age time.Duration) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for item := range s.inflight {
+		if now.Sub(item.started) >= age && !item.stalled {
+			item.stalled = true
+			item.cancel(errStalled)
+			n++
+		}
+	}
+	return n
+}
+
+// stallWatchdog watches the stages of a run and, when one holds items but has
+// made no progress for its timeout, logs where the run is stuck and takes the
+// -stall-action. A nil stallWatchdog watches nothing.
+type stallWatchdog struct {
+	timeout time.Duration
+	action  string
+	abort   context.CancelCauseFunc
+	stages  []*pipelineStage
+
+	reported map[string]time.Time // The LastProgress of each stage's latest reported stall, so a stall is reported once.
+}
+
+// startStallWatchdog returns a context that -stall-action abort cancels, and
+// the watchdog of the search stages. With a timeout of 0 it returns ctx
+// unchanged and a nil watchdog, unless counted, when the watchdog counts the
+// stages for -metrics-addr but never acts.
+func startStallWatchdog(ctx context.Context, timeout time.Duration, action string, counted bool) (context.Context, *stallWatchdog) {
+	if timeout <= 0 && !counted {
+		return ctx, nil
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	w := &stallWatchdog{timeout: timeout, action: action, abort: cancel, reported: make(map[string]time.Time)}
+	for _, name := range []string{stageResults, stageProfiles, stageOutput} {
+		w.stages = append(w.stages, newPipelineStage(name))
+	}
+	if timeout > 0 {
+		go w.watch(ctx)
+	}
+	return ctx, w
+}
+
+// stage returns the stage called name, or nil for a nil watchdog.
+func (w *stallWatchdog) stage(name string) *pipelineStage {
+	if w == nil {
+		return nil
+	}
+	for _, s := range w.stages {
+		if s.name == name {
+			return s
+		}
+	}
+	return nil
+}
+
+// watch checks the stages a few times per timeout until ctx is done.
+func (w *stallWatchdog) watch(ctx context.Context) {
+	for sleepContext(ctx, max(w.timeout/4, 100*time.Millisecond)) == nil {
+		w.check(time.Now())
+	}
+}
+
+// check logs the stages that stalled by now and acts on them, returning
+// their names.
+func (w *stallWatchdog) check(now time.Time) []string {
+	snaps := make([]stageSnapshot, len(w.stages))
+	for i, s := range w.stages {
+		snaps[i] = s.snapshot(now)
+	}
+	var stalled []string
+	for i, snap := range snaps {
+		if snap.pending() == 0 || now.Sub(snap.LastProgress) < w.timeout || w.reported[snap.Name].Equal(snap.LastProgress) {
+			continue
+		}
+		w.reported[snap.Name] = snap.LastProgress
+		stalled = append(stalled, snap.Name)
+		log.Printf("Stage %s has made no progress for %s with %d items pending.", snap.Name, now.Sub(snap.LastProgress).Round(time.Second), snap.pending())
+		for _, line := range stallDump(snaps, runtime.NumGoroutine()) {
+			log.Print(line)
+		}
+		switch w.action {
+		case stallCancel:
+			n := w.stages[i].cancelOlder(now, w.timeout)
+			log.Printf("Cancelled %d stalled %s items; the run goes on without them.", n, snap.Name)
+		case stallAbort:
+			log.Print("Stopping the run.")
+			w.abort(errStalled)
+		}
+	}
+	return stalled
+}
+
+// stallDump describes every stage for the log: its counters and queue depth,
+// then its items in flight and their ages.
+func stallDump(snaps []stageSnapshot, goroutines int) []string {
+	var lines []string
+	for _, s := range snaps {
+		lines = append(lines, fmt.Sprintf("  stage %s: %d in, %d out, %d pending", s.Name, s.In, s.Out, s.pending()))
+		for _, item := range s.InFlight {
+			lines = append(lines, fmt.Sprintf("    in flight for %s: %s", item.Age.Round(time.Second), item.Label))
+		}
+	}
+	return append(lines, fmt.Sprintf("  goroutines: %d", goroutines))
+}
+
+// stop releases the watchdog.
+func (w *stallWatchdog) stop() {
+	if w != nil {
+		w.abort(nil)
+	}
+}
+
+// validStallAction reports whether action is a -stall-action.
+func validStallAction(action string) bool {
+	switch action {
+	case stallLog, stallCancel, stallAbort:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestWatchdog returns a watchdog of the search stages that is only
+// checked when the test calls check, and the context its abort cancels.
+func newTestWatchdog(t *testing.T, timeout time.Duration, action string) (*stallWatchdog, context.Context) {
+	t.Helper()
+	ctx, cancel := context.WithCancelCause(context.Background())
+	t.Cleanup(func() { cancel(nil) })
+	w := &stallWatchdog{timeout: timeout, action: action, abort: cancel, reported: make(map[string]time.Time)}
+	for _, name := range []string{stageResults, stageProfiles, stageOutput} {
+		w.stages = append(w.stages, newPipelineStage(name))
+	}
+	return w, ctx
+}
+
+// captureLog sends the log to a buffer for the rest of the test.
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	return &logged
+}
+
+func TestStallWatchdogReportsStalledStageOnce(t *testing.T) {
+	logged := captureLog(t)
+	w, _ := newTestWatchdog(t, time.Minute, stallLog)
+
+	// The results stage moves on; one of two profile fetches hangs.
+	_, pageDone := w.stage(stageResults).begin(context.Background(), "https://www.google.com/search?q=valve")
+	pageDone()
+	_, fetchDone := w.stage(stageProfiles).begin(context.Background(), "https://www.linkedin.com/in/jane-doe")
+	fetchDone()
+	w.stage(stageProfiles).begin(context.Background(), "https://www.linkedin.com/in/stuck")
+
+	if stalled := w.check(time.Now()); len(stalled) != 0 {
+		t.Errorf("stages %v stalled before the timeout", stalled)
+	}
+	later := time.Now().Add(2 * time.Minute)
+	if stalled := w.check(later); len(stalled) != 1 || stalled[0] != stageProfiles {
+		t.Fatalf("stalled stages %v, want [profiles]", stalled)
+	}
+	out := logged.String()
+	for _, want := range []string{
+		"Stage profiles has made no progress for 2m0s with 1 items pending.",
+		"stage results: 1 in, 1 out, 0 pending",
+		"stage profiles: 2 in, 1 out, 1 pending",
+		"in flight for 2m0s: https://www.linkedin.com/in/stuck",
+		"stage output: 0 in, 0 out, 0 pending",
+		"goroutines: ",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("dump lacks %q:\n%s", want, out)
+		}
+	}
+
+	if stalled := w.check(later.Add(time.Minute)); len(stalled) != 0 {
+		t.Errorf("stall reported again as %v", stalled)
+	}
+}
+
+func TestStallWatchdogIdleStageStartsClockAfresh(t *testing.T) {
+	w, _ := newTestWatchdog(t, 50*time.Millisecond, stallLog)
+	time.Sleep(100 * time.Millisecond)
+
+	// The stage had nothing to do for longer than the timeout, then got an
+	// item: it has not been stalling.
+	w.stage(stageOutput).begin(context.Background(), "10 candidates")
+	if stalled := w.check(time.Now()); len(stalled) != 0 {
+		t.Errorf("stages %v stalled as soon as they got work", stalled)
+	}
+	if stalled := w.check(time.Now().Add(time.Second)); len(stalled) != 1 {
+		t.Errorf("stalled stages %v, want [output] once the item hangs", stalled)
+	}
+}
+
+func TestStallCancelGivesUpOnStuckItemsOnly(t *testing.T) {
+	captureLog(t)
+	w, runCtx := newTestWatchdog(t, 50*time.Millisecond, stallCancel)
+	stage := w.stage(stageProfiles)
+
+	stuckCtx, stuckDone := stage.begin(context.Background(), "https://www.linkedin.com/in/stuck")
+	time.Sleep(100 * time.Millisecond)
+	freshCtx, freshDone := stage.begin(context.Background(), "https://www.linkedin.com/in/fresh")
+
+	if stalled := w.check(time.Now()); len(stalled) != 1 {
+		t.Fatalf("stalled stages %v, want [profiles]", stalled)
+	}
+	select {
+	case <-stuckCtx.Done():
+	default:
+		t.Fatal("the stuck item was not cancelled")
+	}
+	if cause := context.Cause(stuckCtx); !errors.Is(cause, errStalled) {
+		t.Errorf("stuck item cancelled by %v, want errStalled", cause)
+	}
+	if freshCtx.Err() != nil {
+		t.Error("the item younger than the timeout was cancelled")
+	}
+	if runCtx.Err() != nil {
+		t.Error("-stall-action cancel stopped the run")
+	}
+	if !stuckDone() || freshDone() {
+		t.Error("done reports the wrong items as given up on")
+	}
+}
+
+func TestStallAbortStopsRun(t *testing.T) {
+	captureLog(t)
+	ctx, w := startStallWatchdog(context.Background(), 100*time.Millisecond, stallAbort, false)
+	defer w.stop()
+	w.stage(stageResults).begin(ctx, "https://www.google.com/search?q=valve")
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("the run was not stopped")
+	}
+	if cause := context.Cause(ctx); !errors.Is(cause, errStalled) {
+		t.Errorf("run stopped by %v, want errStalled", cause)
+	}
+}
+
+func TestStallWatchdogDisabled(t *testing.T) {
+	ctx := context.Background()
+	if got, w := startStallWatchdog(ctx, 0, stallLog, false); w != nil || got != ctx {
+		t.Error("a watchdog started without -stall-timeout or -metrics-addr")
+	}
+	// A nil watchdog's stages count nothing and never cancel.
+	var w *stallWatchdog
+	itemCtx, done := w.stage(stageProfiles).begin(ctx, "https://www.linkedin.com/in/jane-doe")
+	if itemCtx != ctx || done() {
+		t.Error("a nil stage changed the item")
+	}
+	w.stop()
+}
+
+func TestStageQueueDepthMetrics(t *testing.T) {
+	stats = newRunStats()
+	// Counted for -metrics-addr only: the stages are watched by nobody.
+	ctx, w := startStallWatchdog(context.Background(), 0, stallLog, true)
+	defer w.stop()
+
+	profiles := w.stage(stageProfiles)
+	_, done := profiles.begin(ctx, "https://www.linkedin.com/in/member-1")
+	profiles.begin(ctx, "https://www.linkedin.com/in/member-2")
+	profiles.begin(ctx, "https://www.linkedin.com/in/member-3")
+	done()
+	_, pageDone := w.stage(stageResults).begin(ctx, "https://www.google.com/search?q=valve")
+	pageDone()
+	time.Sleep(10 * time.Millisecond)
+
+	samples := scrapeMetrics(t, w)
+	want := map[string]float64{
+		`profilesearch_stage_items_in_total{stage="profiles"}`:        3,
+		`profilesearch_stage_items_out_total{stage="profiles"}`:       1,
+		`profilesearch_stage_queue_depth{stage="profiles"}`:           2,
+		`profilesearch_stage_items_in_total{stage="results"}`:         1,
+		`profilesearch_stage_queue_depth{stage="results"}`:            0,
+		`profilesearch_stage_queue_depth{stage="output"}`:             0,
+		`profilesearch_stage_oldest_item_age_seconds{stage="output"}`: 0,
+	}
+	for series, value := range want {
+		if got, ok := samples[series]; !ok || got != value {
+			t.Errorf("%s = %v, want %v", series, got, value)
+		}
+	}
+	if age := samples[`profilesearch_stage_oldest_item_age_seconds{stage="profiles"}`]; age < 0.01 {
+		t.Errorf("oldest profile fetch %vs old, want at least 0.01s", age)
+	}
+	last := samples[`profilesearch_stage_last_progress_timestamp_seconds{stage="profiles"}`]
+	if d := time.Since(time.Unix(0, int64(last*1e9))); d < 0 || d > time.Minute {
+		t.Errorf("profiles last progressed %v ago, want just now", d)
+	}
+	if samples["profilesearch_goroutines"] < 1 {
+		t.Error("no goroutines counted")
+	}
+}
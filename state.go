@@ -0,0 +1,387 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Formats of state import and export.
+const (
+	stateFormatText = "text" // One profile URL per line.
+	stateFormatJSON = "json" // The stored entries as a JSON array.
+)
+
+// storeLock is an exclusive claim on a candidate store, held by a run that
+// saves to it and by state commands that change it. It is a file next to the
+// store, so it is seen by every process.
+type storeLock struct {
+	path string
+}
+
+// storeLockedError reports a store locked by another process.
+type storeLockedError struct {
+	store  string
+	holder string // The lock file's contents: who holds it and since when.
+}
+
+func (e *storeLockedError) Error() string {
+	return fmt.Sprintf("store %s is locked by %s; if no run is using it, remove %s.lock", e.store, e.holder, e.store)
+}
+
+// lockStore claims the store at storePath, failing with a storeLockedError
+// when another process holds it.
+func lockStore(storePath string) (*storeLock, error) {
+	path := storePath + ".lock"
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if errors.Is(err, fs.ErrExist) {
+		holder, _ := os.ReadFile(path)
+		e := &storeLockedError{store: storePath, holder: strings.TrimSpace(string(holder))}
+		if e.holder == "" {
+			e.holder = "another process"
+		}
+		return nil, e
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock store: %w", err)
+	}
+	fmt.Fprintf(f, "pid %d since %s\n", os.Getpid(), time.Now().Format(time.RFC3339))
+	if err := f.Close(); err != nil {
+		os.Remove(path)
+		return nil, fmt.Errorf("failed to lock store: %w", err)
+	}
+	return &storeLock{path: path}, nil
+}
+
+// unlock releases the store. It does nothing on a nil lock, so callers can
+// defer it after a forced change that took none.
+func (l *storeLock) unlock() {
+	if l == nil {
+		return
+	}
+	if err := os.Remove(l.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		fmt.Fprintf(os.Stderr, "Failed to unlock store: %v\n", err)
+	}
+}
+
+// remove deletes the entries with profileURLs, leaving a tombstone of
+// reason at now for each. It returns how many were stored.
+func (s *candidateStore) remove(profileURLs []string, reason string, now time.Time) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	drop := make(map[string]bool)
+	for _, u := range profileURLs {
+		if _, ok := s.index[u]; ok {
+			drop[u] = true
+			delete(s.index, u)
+			s.data.Sequence++
+			s.data.Tombstones = append(s.data.Tombstones, storeTombstone{ProfileURL: u, Version: s.data.Sequence, At: now, Reason: reason})
+		}
+	}
+	kept := s.data.Candidates[:0]
+	for _, sc := range s.data.Candidates {
+		if !drop[sc.ProfileURL] {
+			kept = append(kept, sc)
+		}
+	}
+	s.data.Candidates = kept
+	return len(drop)
+}
+
+// compact merges entries stored more than once under the same profile URL,
+// which hand edits and imports can leave. The merged entry keeps the most
+// recently seen data and the earliest first sighting. It returns how many
+// entries were merged away.
+func (s *candidateStore) compact() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	merged := make(map[string]*StoredCandidate)
+	var kept []*StoredCandidate
+	for _, sc := range s.data.Candidates {
+		prev, ok := merged[sc.ProfileURL]
+		if !ok {
+			merged[sc.ProfileURL] = sc
+			kept = append(kept, sc)
+			continue
+		}
+		firstSeen := prev.FirstSeen
+		if !sc.FirstSeen.IsZero() && (firstSeen.IsZero() || sc.FirstSeen.Before(firstSeen)) {
+			firstSeen = sc.FirstSeen
+		}
+		if sc.LastSeen.After(prev.LastSeen) {
+			*prev = *sc
+		}
+		prev.FirstSeen = firstSeen
+		s.bump(prev)
+	}
+	removed := len(s.data.Candidates) - len(kept)
+	s.data.Candidates = kept
+	s.index = merged
+	return removed
+}
+
+// add stores entries whose profile URL is not stored yet. It returns how
+// many were added.
+func (s *candidateStore) add(entries []*StoredCandidate) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	added := 0
+	for _, sc := range entries {
+		if sc.ProfileURL == "" || s.index[sc.ProfileURL] != nil {
+			continue
+		}
+		s.bump(sc)
+		s.data.Candidates = append(s.data.Candidates, sc)
+		s.index[sc.ProfileURL] = sc
+		added++
+	}
+	return added
+}
+
+// stateAgeBuckets are the upper bounds of the age groups state stats prints.
+var stateAgeBuckets = []struct {
+	label string
+	max   time.Duration
+}{
+	{"under a day", 24 * time.Hour},
+	{"under a week", 7 * 24 * time.Hour},
+	{"under 30 days", 30 * 24 * time.Hour},
+	{"under 180 days", 180 * 24 * time.Hour},
+	{"180 days or more", 1<<63 - 1},
+}
+
+// printStoreStats prints entry counts and how long ago entries were last
+// seen.
+func printStoreStats(store *candidateStore, now time.Time) {
+	entries := store.candidates()
+	urls := make(map[string]bool)
+	counts := make([]int, len(stateAgeBuckets))
+	unknown := 0
+	for _, sc := range entries {
+		urls[sc.ProfileURL] = true
+		if sc.LastSeen.IsZero() {
+			unknown++
+			continue
+		}
+		age := now.Sub(sc.LastSeen)
+		for i, b := range stateAgeBuckets {
+			if age < b.max {
+				counts[i]++
+				break
+			}
+		}
+	}
+	fmt.Printf("Entries: %d (%d profiles", len(entries), len(urls))
+	if dup := len(entries) - len(urls); dup > 0 {
+		fmt.Printf(", %d duplicates; run state compact", dup)
+	}
+	fmt.Println(")")
+	fmt.Printf("Runs recorded: %d\n", store.runCount())
+	if last, ok := store.lastRun(); ok {
+		fmt.Printf("Last run: %s\n", last.Time.Local().Format("2006-01-02 15:04"))
+	}
+	if len(entries) == 0 {
+		return
+	}
+	fmt.Println("Last seen:")
+	for i, b := range stateAgeBuckets {
+		fmt.Printf("  %-18s %d\n", b.label, counts[i])
+	}
+	if unknown > 0 {
+		fmt.Printf("  %-18s %d\n", "never recorded", unknown)
+	}
+}
+
+// exportStore writes the stored entries in format.
+func exportStore(w io.Writer, entries []*StoredCandidate, format string) error {
+	if format == stateFormatJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(entries)
+	}
+	bw := bufio.NewWriter(w)
+	for _, sc := range entries {
+		fmt.Fprintln(bw, sc.ProfileURL)
+	}
+	return bw.Flush()
+}
+
+// readStateImport reads entries in format. Plain profile URLs become entries
+// first seen at now, with no search data.
+func readStateImport(r io.Reader, format string, now time.Time) ([]*StoredCandidate, error) {
+	if format == stateFormatJSON {
+		var entries []*StoredCandidate
+		if err := json.NewDecoder(r).Decode(&entries); err != nil {
+			return nil, fmt.Errorf("failed to parse import: %w", err)
+		}
+		return entries, nil
+	}
+	var entries []*StoredCandidate
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		u := strings.TrimSpace(scanner.Text())
+		if u == "" || strings.HasPrefix(u, "#") {
+			continue
+		}
+		entries = append(entries, &StoredCandidate{Candidate: Candidate{ProfileURL: u}, FirstSeen: now, LastSeen: now})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read import: %w", err)
+	}
+	return entries, nil
+}
+
+// stateFormat returns the import or export format: the -format flag, or
+// else json for a .json file and text otherwise.
+func stateFormat(flagValue, filename string) (string, error) {
+	switch flagValue {
+	case "":
+		if strings.HasSuffix(strings.ToLower(filename), ".json") {
+			return stateFormatJSON, nil
+		}
+		return stateFormatText, nil
+	case stateFormatText, stateFormatJSON:
+		return flagValue, nil
+	}
+	return "", fmt.Errorf("invalid -format %q: want %s or %s", flagValue, stateFormatText, stateFormatJSON)
+}
+
+// runStateCommand inspects and repairs a candidate store.
+func runStateCommand(args []string) error {
+	action := "stats"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		action, args = args[0], args[1:]
+	}
+	fs := flag.NewFlagSet("state", flag.ExitOnError)
+	storePath := fs.String("store", "", "candidate store")
+	match := fs.String("match", "", "list only profile URLs containing this")
+	force := fs.Bool("force", false, "change the store even while a run holds its lock")
+	format := fs.String("format", "", "import/export format: text (one URL per line) or json (default from the file extension)")
+	optOutListPath := fs.String("optout-list", "", "with remove, remove the candidates on this opt-out list as opted out")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: profilesearch state [stats|list|remove|compact|import|export] -store file [-match s] [-force] [-format text|json] [-optout-list file] [url...|file]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if *storePath == "" {
+		return errors.New("state needs -store")
+	}
+
+	mutates := action == "remove" || action == "compact" || action == "import"
+	var lock *storeLock
+	if mutates && !*force {
+		var err error
+		if lock, err = lockStore(*storePath); err != nil {
+			return fmt.Errorf("%w (or pass -force)", err)
+		}
+	}
+	defer lock.unlock()
+
+	store, err := openStore(*storePath)
+	if err != nil {
+		return err
+	}
+
+	switch action {
+	case "stats":
+		printStoreStats(store, time.Now().UTC())
+	case "list":
+		entries := store.candidates()
+		sort.SliceStable(entries, func(i, j int) bool { return entries[i].LastSeen.After(entries[j].LastSeen) })
+		for _, sc := range entries {
+			if !strings.Contains(sc.ProfileURL, *match) {
+				continue
+			}
+			fmt.Printf("%s  %s  first seen %s, last seen %s\n", sc.ProfileURL, sc.Name, stateDate(sc.FirstSeen), stateDate(sc.LastSeen))
+		}
+	case "remove":
+		if *optOutListPath != "" {
+			if fs.NArg() > 0 {
+				return errors.New("remove takes profile URLs or -optout-list, not both")
+			}
+			list, err := loadOptOutList(*optOutListPath)
+			if err != nil {
+				return err
+			}
+			var optedOut []string
+			for _, sc := range store.candidates() {
+				if list.contains(sc.ProfileURL) {
+					optedOut = append(optedOut, sc.ProfileURL)
+				}
+			}
+			n := store.remove(optedOut, tombstoneOptedOut, time.Now().UTC())
+			if err := store.save(); err != nil {
+				return err
+			}
+			fmt.Printf("Removed %d entries on the opt-out list of %d.\n", n, list.size())
+			break
+		}
+		if fs.NArg() == 0 {
+			return errors.New("remove needs profile URLs")
+		}
+		n := store.remove(fs.Args(), tombstoneRemoved, time.Now().UTC())
+		if err := store.save(); err != nil {
+			return err
+		}
+		fmt.Printf("Removed %d of %d entries.\n", n, fs.NArg())
+	case "compact":
+		n := store.compact()
+		if err := store.save(); err != nil {
+			return err
+		}
+		fmt.Printf("Merged %d duplicate entries; %d remain.\n", n, len(store.candidates()))
+	case "import":
+		if fs.NArg() != 1 {
+			return errors.New("import needs one file")
+		}
+		f, err := stateFormat(*format, fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		file, err := os.Open(fs.Arg(0))
+		if err != nil {
+			return fmt.Errorf("failed to open import: %w", err)
+		}
+		defer file.Close()
+		entries, err := readStateImport(file, f, time.Now().UTC())
+		if err != nil {
+			return err
+		}
+		n := store.add(entries)
+		if err := store.save(); err != nil {
+			return err
+		}
+		fmt.Printf("Imported %d new entries of %d.\n", n, len(entries))
+	case "export":
+		if fs.NArg() > 1 {
+			return errors.New("export takes at most one file")
+		}
+		f, err := stateFormat(*format, fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		if fs.NArg() == 0 {
+			return exportStore(os.Stdout, store.candidates(), f)
+		}
+		return writeFileAtomic(fs.Arg(0), func(w io.Writer) error { return exportStore(w, store.candidates(), f) })
+	default:
+		return fmt.Errorf("unknown state action %q: want stats, list, remove, compact, import, or export", action)
+	}
+	return nil
+}
+
+// stateDate formats a sighting for state list.
+func stateDate(t time.Time) string {
+	if t.IsZero() {
+		return "unknown"
+	}
+	return t.Local().Format("2006-01-02")
+}
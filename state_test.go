@@ -0,0 +1,215 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// seedStore writes a store holding Jane twice, as a hand edit can leave it,
+// and John, and returns its path.
+func seedStore(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "candidates.db")
+	seed := `{"candidates": [
+  {"profile_url": "https://www.linkedin.com/in/jane-doe", "name": "Jane Doe", "company": "Emerson", "first_seen": "2026-01-05T00:00:00Z", "last_seen": "2026-02-01T00:00:00Z"},
+  {"profile_url": "https://www.linkedin.com/in/john-roe", "name": "John Roe", "first_seen": "2026-01-10T00:00:00Z", "last_seen": "2026-01-10T00:00:00Z"},
+  {"profile_url": "https://www.linkedin.com/in/jane-doe", "name": "Jane Doe", "company": "Acme Valves", "first_seen": "2026-02-20T00:00:00Z", "last_seen": "2026-02-28T00:00:00Z"}
+]}`
+	if err := os.WriteFile(path, []byte(seed), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// captureStdout runs fn and returns what it printed to standard output.
+func captureStdout(t *testing.T, fn func() error) (string, error) {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := os.Stdout
+	os.Stdout = w
+	done := make(chan string)
+	go func() {
+		b, _ := io.ReadAll(r)
+		done <- string(b)
+	}()
+	fnErr := fn()
+	os.Stdout = old
+	w.Close()
+	return <-done, fnErr
+}
+
+func TestStateStatsAndList(t *testing.T) {
+	path := seedStore(t)
+	out, err := captureStdout(t, func() error { return runStateCommand([]string{"stats", "-store", path}) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "Entries: 3 (2 profiles, 1 duplicates; run state compact)") {
+		t.Errorf("stats printed:\n%s", out)
+	}
+
+	out, err = captureStdout(t, func() error { return runStateCommand([]string{"list", "-store", path, "-match", "john"}) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(out), "\n"); len(lines) != 1 || !strings.HasPrefix(lines[0], "https://www.linkedin.com/in/john-roe  John Roe  first seen ") {
+		t.Errorf("list -match john printed:\n%s", out)
+	}
+}
+
+func TestStateCompact(t *testing.T) {
+	path := seedStore(t)
+	if _, err := captureStdout(t, func() error { return runStateCommand([]string{"compact", "-store", path}) }); err != nil {
+		t.Fatal(err)
+	}
+	store, err := openStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := store.candidates()
+	if len(entries) != 2 {
+		t.Fatalf("%d entries after compact, want 2", len(entries))
+	}
+	jane := entries[0]
+	if jane.Company != "Acme Valves" || !jane.FirstSeen.Equal(time.Date(2026, 1, 5, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("merged entry %s first seen %s, want the latest data and the earliest sighting", jane.Company, jane.FirstSeen)
+	}
+	if _, err := os.Stat(path + ".lock"); !errors.Is(err, os.ErrNotExist) {
+		t.Error("compact left the store locked")
+	}
+}
+
+func TestStateRemove(t *testing.T) {
+	path := seedStore(t)
+	if _, err := captureStdout(t, func() error {
+		return runStateCommand([]string{"compact", "-store", path})
+	}); err != nil {
+		t.Fatal(err)
+	}
+	out, err := captureStdout(t, func() error {
+		return runStateCommand([]string{"remove", "-store", path, "https://www.linkedin.com/in/john-roe", "https://www.linkedin.com/in/nobody"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "Removed 1 of 2 entries.") {
+		t.Errorf("remove printed %q", out)
+	}
+	store, err := openStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entries := store.candidates(); len(entries) != 1 || entries[0].Name != "Jane Doe" {
+		t.Errorf("entries after remove %+v, want Jane only", entries)
+	}
+}
+
+func TestStateImportExport(t *testing.T) {
+	path := seedStore(t)
+	dir := t.TempDir()
+
+	// Export as JSON and import into an empty store.
+	exported := filepath.Join(dir, "export.json")
+	if err := runStateCommand([]string{"export", "-store", path, exported}); err != nil {
+		t.Fatal(err)
+	}
+	copyPath := filepath.Join(dir, "copy.db")
+	if _, err := captureStdout(t, func() error { return runStateCommand([]string{"import", "-store", copyPath, exported}) }); err != nil {
+		t.Fatal(err)
+	}
+	store, err := openStore(copyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entries := store.candidates(); len(entries) != 2 || entries[0].Company != "Emerson" {
+		t.Errorf("imported %d entries, want both profiles with their data", len(entries))
+	}
+
+	// Plain text adds only URLs not yet stored.
+	text := filepath.Join(dir, "urls.txt")
+	if err := os.WriteFile(text, []byte("# from the spreadsheet\nhttps://www.linkedin.com/in/jane-doe\n\nhttps://www.linkedin.com/in/asha-rao\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out, err := captureStdout(t, func() error { return runStateCommand([]string{"import", "-store", copyPath, text}) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "Imported 1 new entries of 2.") {
+		t.Errorf("text import printed %q", out)
+	}
+
+	exportedText := filepath.Join(dir, "export.txt")
+	if err := runStateCommand([]string{"export", "-store", copyPath, exportedText}); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(exportedText)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "https://www.linkedin.com/in/jane-doe\nhttps://www.linkedin.com/in/john-roe\nhttps://www.linkedin.com/in/asha-rao\n"
+	if string(b) != want {
+		t.Errorf("text export %q, want %q", b, want)
+	}
+}
+
+func TestSearchLocksItsStore(t *testing.T) {
+	path := seedStore(t)
+	lock, err := lockStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, addr := startFakeWeb(t, fakeRoster(1))
+	var locked *storeLockedError
+	if _, err := runFakeSearch(t, addr, "-max-pages", "1", "-store", path); !errors.As(err, &locked) {
+		t.Errorf("search saving to a locked store: got %v, want a lock error", err)
+	}
+	lock.unlock()
+	if _, err := runFakeSearch(t, addr, "-max-pages", "1", "-store", path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path + ".lock"); !errors.Is(err, os.ErrNotExist) {
+		t.Error("the search left its store locked")
+	}
+}
+
+func TestStateRefusesLockedStore(t *testing.T) {
+	path := seedStore(t)
+	lock, err := lockStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lock.unlock()
+
+	for _, action := range []string{"remove", "compact", "import"} {
+		err := runStateCommand([]string{action, "-store", path, "https://www.linkedin.com/in/john-roe"})
+		var locked *storeLockedError
+		if !errors.As(err, &locked) || !strings.Contains(err.Error(), "-force") {
+			t.Errorf("%s of a locked store: got %v, want a lock error suggesting -force", action, err)
+		}
+	}
+	if store, _ := openStore(path); len(store.candidates()) != 3 {
+		t.Error("a locked store was changed")
+	}
+
+	// Reading needs no lock, and -force overrides it.
+	if _, err := captureStdout(t, func() error { return runStateCommand([]string{"stats", "-store", path}) }); err != nil {
+		t.Errorf("stats of a locked store: %v", err)
+	}
+	if _, err := captureStdout(t, func() error { return runStateCommand([]string{"compact", "-store", path, "-force"}) }); err != nil {
+		t.Fatal(err)
+	}
+	if store, _ := openStore(path); len(store.candidates()) != 2 {
+		t.Error("compact -force did not change the locked store")
+	}
+	if _, err := os.Stat(path + ".lock"); err != nil {
+		t.Error("-force released the lock it did not take")
+	}
+}
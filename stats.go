@@ -0,0 +1,188 @@
+package main
+
+import (
+	"log"
+	"sort"
+	"sync"
+)
+
+// verbose enables detailed logging for tuning extraction rules.
+var verbose bool
+
+// verbosef logs only when -verbose is set.
+func verbosef(format string, args ...interface{}) {
+	if verbose {
+		log.Printf(format, args...)
+	}
+}
+
+// RunStats collects counters across a whole run. It is safe for concurrent use.
+type RunStats struct {
+	mu                 sync.Mutex
+	PhoneRejections    map[string]int // Rejected phone matches by rule name.
+	ApproxTotalResults int            // Google's "About X results" for the latest search.
+	PagesScraped       int
+	CandidatesFound    int             // Candidates on the scraped pages, before de-duplication.
+	ResultTypes        map[string]int  // Candidates found, by the ResultType of their result.
+	MirrorsSuppressed  int             // Candidates merged into another as mirrors of its profile.
+	Outcomes           []outcomeRecord // Every outbound request, in the order sent.
+	SampledFrom        int             // New candidates that -sample drew from.
+	SampleKnown        int             // Candidates -sample skipped as already stored.
+	Sampled            int
+	SuppressedFetches  map[string]int // Profile fetches skipped by the negative cache, by failure class.
+	FieldConflicts     map[string]int // Differing values of a field the resolver decided between, by field.
+	RobotsDisallowed   int            // Requests not made because robots.txt disallows them.
+	AnonymizedSkipped  int            // Anonymized results dropped under -anonymized skip.
+	AuthwallRetries    int            // Profiles fetched again after an authwall, under -profile-retry-on-authwall.
+	AuthwallRecovered  int            // Of those, the ones the second fetch got.
+
+	attempts map[string]int // Requests so far by URL.
+}
+
+// stats is the process-wide run statistics.
+var stats = newRunStats()
+
+func newRunStats() *RunStats {
+	return &RunStats{PhoneRejections: make(map[string]int), ResultTypes: make(map[string]int), SuppressedFetches: make(map[string]int), FieldConflicts: make(map[string]int), attempts: make(map[string]int)}
+}
+
+// countFieldConflict records that two differing values of field met.
+func (s *RunStats) countFieldConflict(field string) {
+	s.mu.Lock()
+	s.FieldConflicts[field]++
+	s.mu.Unlock()
+}
+
+// countAnonymizedSkip records an anonymized result dropped.
+func (s *RunStats) countAnonymizedSkip() {
+	s.mu.Lock()
+	s.AnonymizedSkipped++
+	s.mu.Unlock()
+}
+
+// countRobotsDisallowed records a request robots.txt ruled out.
+func (s *RunStats) countRobotsDisallowed() {
+	s.mu.Lock()
+	s.RobotsDisallowed++
+	s.mu.Unlock()
+}
+
+// countAuthwallRetry records a fetch made again after an authwall, and
+// whether it got the page.
+func (s *RunStats) countAuthwallRetry(recovered bool) {
+	s.mu.Lock()
+	s.AuthwallRetries++
+	if recovered {
+		s.AuthwallRecovered++
+	}
+	s.mu.Unlock()
+}
+
+// countSuppressedFetch records a profile fetch skipped because it failed
+// recently with class.
+func (s *RunStats) countSuppressedFetch(class string) {
+	s.mu.Lock()
+	s.SuppressedFetches[class]++
+	s.mu.Unlock()
+}
+
+// countPhoneRejection records a phone match rejected by rule.
+func (s *RunStats) countPhoneRejection(rule string) {
+	s.mu.Lock()
+	s.PhoneRejections[rule]++
+	s.mu.Unlock()
+}
+
+// countMirror records a candidate merged away as a mirror.
+func (s *RunStats) countMirror() {
+	s.mu.Lock()
+	s.MirrorsSuppressed++
+	s.mu.Unlock()
+}
+
+// countPage records a scraped results page yielding candidates. A
+// -single-page page counts as the default-size pages it stands in for, so the
+// yield per page stays comparable across runs.
+func (s *RunStats) countPage(candidates []Candidate) {
+	found := len(candidates)
+	s.mu.Lock()
+	s.PagesScraped += max(1, (found+resultsPerPage-1)/resultsPerPage)
+	s.CandidatesFound += found
+	for _, c := range candidates {
+		s.ResultTypes[firstNonEmpty(c.ResultType, resultTypeOrganic)]++
+	}
+	s.mu.Unlock()
+}
+
+// yield returns the pages scraped and candidates found on them so far.
+func (s *RunStats) yield() (pages, found int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.PagesScraped, s.CandidatesFound
+}
+
+// countSample records a page's sampling: from new candidates drawn from,
+// known ones skipped, and sampled ones selected.
+func (s *RunStats) countSample(from, known, sampled int) {
+	s.mu.Lock()
+	s.SampledFrom += from
+	s.SampleKnown += known
+	s.Sampled += sampled
+	s.mu.Unlock()
+}
+
+// sample returns the sampling counts so far.
+func (s *RunStats) sample() (from, known, sampled int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.SampledFrom, s.SampleKnown, s.Sampled
+}
+
+// setApproxTotalResults records the result count reported by Google.
+func (s *RunStats) setApproxTotalResults(n int) {
+	s.mu.Lock()
+	s.ApproxTotalResults = n
+	s.mu.Unlock()
+}
+
+// logSummary logs the collected counters at verbose level.
+func (s *RunStats) logSummary() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rules := make([]string, 0, len(s.PhoneRejections))
+	for rule := range s.PhoneRejections {
+		rules = append(rules, rule)
+	}
+	sort.Strings(rules)
+	for _, rule := range rules {
+		verbosef("Phone matches rejected by %s: %d", rule, s.PhoneRejections[rule])
+	}
+	types := make([]string, 0, len(s.ResultTypes))
+	for t := range s.ResultTypes {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	for _, t := range types {
+		verbosef("Candidates from %s results: %d", t, s.ResultTypes[t])
+	}
+	fields := make([]string, 0, len(s.FieldConflicts))
+	for field := range s.FieldConflicts {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	for _, field := range fields {
+		verbosef("Conflicting %s values resolved: %d", field, s.FieldConflicts[field])
+	}
+	if s.MirrorsSuppressed > 0 {
+		verbosef("Mirrored results suppressed: %d", s.MirrorsSuppressed)
+	}
+	if s.AnonymizedSkipped > 0 {
+		verbosef("Anonymized results skipped: %d", s.AnonymizedSkipped)
+	}
+	if s.RobotsDisallowed > 0 {
+		verbosef("Requests disallowed by robots.txt: %d", s.RobotsDisallowed)
+	}
+	if s.AuthwallRetries > 0 {
+		verbosef("Authwalls retried: %d, of which %d got the page", s.AuthwallRetries, s.AuthwallRecovered)
+	}
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// StoredCandidate is a candidate as kept in a -store file, with the
+// bookkeeping needed to track it across runs.
+type StoredCandidate struct {
+	Candidate
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+
+	FieldSources map[string]string `json:"field_sources,omitempty"` // Candidate.Sources, for resolving the next run's values.
+
+	EmailStatus   string    `json:"email_status,omitempty"`   // One of the emailStatus values, set by verify
+	ProfileStatus string    `json:"profile_status,omitempty"` // One of the profileStatus values, set by verify
+	LastVerified  time.Time `json:"last_verified"`            // Zero until verified
+
+	Version int64 `json:"version,omitempty"` // The store's sequence number when the entry last changed; see export-changes
+}
+
+// storeFile is the on-disk layout of a candidate store.
+type storeFile struct {
+	Candidates      []*StoredCandidate `json:"candidates"`
+	Runs            []storedRun        `json:"runs,omitempty"`
+	NegativeResults []negativeResult   `json:"negative_results,omitempty"` // Profile fetches that failed recently.
+	RateSamples     []rateSample       `json:"rate_samples,omitempty"`     // Request rates of recent runs, for -learned-limits.
+
+	Sequence   int64            `json:"sequence,omitempty"`   // The latest version given to an entry or tombstone.
+	Tombstones []storeTombstone `json:"tombstones,omitempty"` // Entries removed from the store, for export-changes.
+}
+
+// Reasons an entry was removed from the store.
+const (
+	tombstoneRemoved  = "removed"   // By state remove.
+	tombstoneOptedOut = "opted_out" // By state remove -optout-list.
+)
+
+// storeTombstone records an entry removed from the store, so change feeds
+// can tell consumers to delete it.
+type storeTombstone struct {
+	ProfileURL string    `json:"profile_url"`
+	Version    int64     `json:"version"`
+	At         time.Time `json:"at"`
+	Reason     string    `json:"reason"`
+}
+
+// storedRun records the yield of one run that saved to the store.
+type storedRun struct {
+	Time       time.Time `json:"time"`
+	Pages      int       `json:"pages"`
+	Candidates int       `json:"candidates"` // Found on those pages.
+	Kept       int       `json:"kept"`
+
+	Incremental bool `json:"incremental,omitempty"`
+	SeenAgain   int  `json:"seen_again,omitempty"` // Stored candidates an -incremental run found again.
+}
+
+// candidateStore keeps candidates across runs in a JSON file, keyed by
+// profile URL. It is safe for concurrent use.
+type candidateStore struct {
+	path string
+
+	mu    sync.Mutex
+	data  storeFile
+	index map[string]*StoredCandidate
+}
+
+// openStore loads the store at path. A missing file is an empty store.
+func openStore(path string) (*candidateStore, error) {
+	s := &candidateStore{path: path, index: make(map[string]*StoredCandidate)}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read store: %w", err)
+	}
+	if err := json.Unmarshal(b, &s.data); err != nil {
+		return nil, fmt.Errorf("failed to parse store %s: %w", path, err)
+	}
+	for _, sc := range s.data.Candidates {
+		sc.upgradeExperience()
+		s.index[sc.ProfileURL] = sc
+		if sc.Version == 0 {
+			// Stored before versioning: the first change feed includes it.
+			s.bump(sc)
+		}
+	}
+	return s, nil
+}
+
+// bump gives sc the next version. The caller holds s.mu.
+func (s *candidateStore) bump(sc *StoredCandidate) {
+	s.data.Sequence++
+	sc.Version = s.data.Sequence
+}
+
+// versioned returns the parts of sc whose change bumps its version: all but
+// the version itself, the time it was last seen, and where the latest run
+// happened to find it.
+func (sc *StoredCandidate) versioned() []byte {
+	v := *sc
+	v.Version, v.LastSeen = 0, time.Time{}
+	v.Rank, v.PagePosition, v.OverallPosition, v.RelaxationLevel, v.Job = 0, 0, 0, 0, ""
+	b, _ := json.Marshal(v)
+	return b
+}
+
+// upsert records candidates found at now. Existing entries take the new
+// search data but keep their history and verification results; a stored
+// value the new data lacks, or whose source -field-priority ranks higher,
+// is kept. New entries, and existing ones whose data changed, get a new
+// version.
+func (s *candidateStore) upsert(candidates []Candidate, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range candidates {
+		if sc, ok := s.index[c.ProfileURL]; ok {
+			before := sc.versioned()
+			mergeFields("store", &c, now, sc.stored(), sc.LastSeen)
+			sc.Candidate = c
+			sc.FieldSources = c.Sources
+			sc.LastSeen = now
+			if !bytes.Equal(before, sc.versioned()) {
+				s.bump(sc)
+			}
+			continue
+		}
+		sc := &StoredCandidate{Candidate: c, FieldSources: c.Sources, FirstSeen: now, LastSeen: now}
+		s.bump(sc)
+		s.data.Candidates = append(s.data.Candidates, sc)
+		s.index[c.ProfileURL] = sc
+	}
+}
+
+// stored returns the stored candidate with the sources of its fields.
+func (sc *StoredCandidate) stored() Candidate {
+	c := sc.Candidate
+	c.Sources = sc.FieldSources
+	return c
+}
+
+// addRun records a run's yield.
+func (s *candidateStore) addRun(run storedRun) {
+	s.mu.Lock()
+	s.data.Runs = append(s.data.Runs, run)
+	s.mu.Unlock()
+}
+
+// lastRun returns the most recently recorded run.
+func (s *candidateStore) lastRun() (storedRun, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.data.Runs) == 0 {
+		return storedRun{}, false
+	}
+	return s.data.Runs[len(s.data.Runs)-1], true
+}
+
+// runCount returns how many runs are recorded.
+func (s *candidateStore) runCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.data.Runs)
+}
+
+// touch records that the stored candidates with profileURLs were found again
+// at now, without changing their data.
+func (s *candidateStore) touch(profileURLs []string, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, u := range profileURLs {
+		if sc, ok := s.index[u]; ok {
+			sc.LastSeen = now
+		}
+	}
+}
+
+// yieldHistory totals the yield of the recorded runs.
+func (s *candidateStore) yieldHistory() yieldHistory {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var h yieldHistory
+	for _, r := range s.data.Runs {
+		h.Pages += r.Pages
+		h.Candidates += r.Candidates
+	}
+	return h
+}
+
+// negativeResults returns the recorded profile fetch failures.
+func (s *candidateStore) negativeResults() []negativeResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]negativeResult(nil), s.data.NegativeResults...)
+}
+
+// setNegativeResults replaces the recorded profile fetch failures.
+func (s *candidateStore) setNegativeResults(entries []negativeResult) {
+	s.mu.Lock()
+	s.data.NegativeResults = entries
+	s.mu.Unlock()
+}
+
+// rateSamples returns the recorded request rates, oldest first.
+func (s *candidateStore) rateSamples() []rateSample {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]rateSample(nil), s.data.RateSamples...)
+}
+
+// addRateSamples records a run's request rates, dropping the oldest beyond
+// maxStoredRateSamples.
+func (s *candidateStore) addRateSamples(samples []rateSample) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.RateSamples = append(s.data.RateSamples, samples...)
+	if n := len(s.data.RateSamples); n > maxStoredRateSamples {
+		s.data.RateSamples = s.data.RateSamples[n-maxStoredRateSamples:]
+	}
+}
+
+// candidates returns the stored entries in insertion order. The entries are
+// shared with the store, so changes to them are written by the next save.
+func (s *candidateStore) candidates() []*StoredCandidate {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*StoredCandidate(nil), s.data.Candidates...)
+}
+
+// save writes the store, replacing the file atomically so that an
+// interrupted save never leaves it truncated.
+func (s *candidateStore) save() error {
+	s.mu.Lock()
+	b, err := json.MarshalIndent(s.data, "", "  ")
+	s.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to encode store: %w", err)
+	}
+	if err := writeFileAtomic(s.path, func(w io.Writer) error {
+		_, err := w.Write(b)
+		return err
+	}); err != nil {
+		return fmt.Errorf("failed to write store: %w", err)
+	}
+	return nil
+}
+
+// writeFileAtomic writes path through a temporary file synced and renamed
+// over it, so that an interrupted write or a crash never leaves it
+// truncated.
+func writeFileAtomic(path string, write func(w io.Writer) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := write(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	// Without the sync, the rename can reach the disk before the data.
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// saveToStore adds a run's candidates, yield, profile fetch failures, and
+// request rates to the -store file, if one is set. For an -incremental run, candidates are the
+// new ones and seenAgain the stored ones found again.
+func saveToStore(path string, candidates []Candidate, incremental *incrementalRun, negative *negativeCache) error {
+	if path == "" {
+		return nil
+	}
+	store, err := openStore(path)
+	if err != nil {
+		return err
+	}
+	now := clockNow().UTC()
+	store.upsert(candidates, now)
+	seenAgain := incremental.seen()
+	store.touch(seenAgain, now)
+	negative.apply(store)
+	if fakeWebAddr == "" {
+		store.addRateSamples(rateSamples(stats.outcomes()))
+	}
+	pages, found := stats.yield()
+	store.addRun(storedRun{Time: now, Pages: pages, Candidates: found, Kept: len(candidates), Incremental: incremental != nil, SeenAgain: len(seenAgain)})
+	if err := store.save(); err != nil {
+		return err
+	}
+	fmt.Printf("Stored %d candidates in %s\n", len(candidates), path)
+	return nil
+}
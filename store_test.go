@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStoreConcurrentUpserts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "candidates.db")
+	store, err := openStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	// Workers upsert overlapping profiles and save as they go.
+	const workers, profiles = 20, 30
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < profiles; i++ {
+				url := fmt.Sprintf("https://www.linkedin.com/in/member-%d", (w+i)%profiles)
+				store.upsert([]Candidate{{ProfileURL: url, Name: fmt.Sprintf("Worker %d", w)}}, now)
+				if i%10 == 0 {
+					if err := store.save(); err != nil {
+						t.Error(err)
+					}
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	if err := store.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := openStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	candidates := reopened.candidates()
+	if len(candidates) != profiles {
+		t.Errorf("%d entries stored, want one per profile: %d", len(candidates), profiles)
+	}
+	versions := make(map[int64]bool)
+	for _, sc := range candidates {
+		if versions[sc.Version] {
+			t.Errorf("version %d given twice", sc.Version)
+		}
+		versions[sc.Version] = true
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode/utf8"
+)
+
+// An output template for -format template is a text/template executed once
+// with templateData. For example, a tab-separated file:
+//
+//	name	title	company	profile_url
+//	{{range .Candidates}}{{.Name}}	{{.Title}}	{{.Company}}	{{.ProfileURL}}
+//	{{end}}
+//
+// or a fixed-width file for a legacy import, with the run's criteria first:
+//
+//	# {{.Criteria.Keywords}} in {{.Criteria.Location}}, {{len .Candidates}} candidates
+//	{{range .Candidates}}{{padRight 30 .Name}}{{padRight 40 .Company}}{{formatPhone "e164" .Phone}}
+//	{{end}}
+
+// templateData is what an output template is executed with.
+type templateData struct {
+	Candidates []Candidate
+	Stats      templateStats
+	Criteria   SearchCriteria // The job's, for a job's output; empty for combined output.
+	Run        *runInfo       // Nil under -no-run-info.
+}
+
+// templateStats is the part of RunStats a template can show.
+type templateStats struct {
+	PagesScraped       int
+	CandidatesFound    int // On the scraped pages, before de-duplication and filters.
+	ApproxTotalResults int
+	Requests           int
+}
+
+// templateStats returns a snapshot of s for an output template.
+func (s *RunStats) templateStats() templateStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return templateStats{PagesScraped: s.PagesScraped, CandidatesFound: s.CandidatesFound, ApproxTotalResults: s.ApproxTotalResults, Requests: len(s.Outcomes)}
+}
+
+// templateFuncs returns the helpers output templates may call. formatPhone
+// uses the -phone-region for numbers without a country code.
+func templateFuncs(phoneRegion string) template.FuncMap {
+	return template.FuncMap{
+		"csvQuote":   csvQuote,
+		"jsonEscape": jsonEscape,
+		"padRight":   padRight,
+		"padLeft":    padLeft,
+		"formatPhone": func(format, phone string) (string, error) {
+			switch format {
+			case phoneFormatRaw, phoneFormatE164, phoneFormatNational:
+			default:
+				return "", fmt.Errorf("invalid phone format %q: want %s, %s, or %s", format, phoneFormatRaw, phoneFormatE164, phoneFormatNational)
+			}
+			return formatPhone(phone, format, phoneRegion), nil
+		},
+	}
+}
+
+// csvQuote returns s as a quoted CSV field, doubling any quotes in it.
+func csvQuote(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
+
+// jsonEscape returns s escaped for use inside a JSON string, without the
+// surrounding quotes.
+func jsonEscape(s string) string {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s) // A string always encodes.
+	return strings.TrimSuffix(strings.TrimPrefix(strings.TrimSuffix(b.String(), "\n"), `"`), `"`)
+}
+
+// padRight pads s with spaces to width characters, or cuts it to width, for
+// fixed-width columns.
+func padRight(width int, s string) string {
+	n := utf8.RuneCountInString(s)
+	if n > width {
+		return string([]rune(s)[:max(width, 0)])
+	}
+	return s + strings.Repeat(" ", width-n)
+}
+
+// padLeft is padRight aligning s to the right, as for numbers.
+func padLeft(width int, s string) string {
+	n := utf8.RuneCountInString(s)
+	if n > width {
+		return string([]rune(s)[:max(width, 0)])
+	}
+	return strings.Repeat(" ", width-n) + s
+}
+
+// loadOutputTemplate parses a -template-file. A syntax error is reported
+// with the file, line, and column.
+func loadOutputTemplate(filename, phoneRegion string) (*template.Template, error) {
+	text, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template: %w", err)
+	}
+	funcs := templateFuncs(phoneRegion)
+	t, err := template.New(filepath.Base(filename)).Funcs(funcs).Parse(string(text))
+	if err != nil {
+		return nil, locateTemplateError(filename, string(text), funcs, err)
+	}
+	return t, nil
+}
+
+// templateParseError matches the syntax errors of text/template, which give
+// the line but no column.
+var templateParseError = regexp.MustCompile(`^template: .*:(\d+): (.*)$`)
+
+// locateTemplateError returns the parse error err of text as
+// "file:line:column: message", the column being that of the action the error
+// is in, counted in characters from 1. Errors of another form are returned
+// as they are.
+func locateTemplateError(filename, text string, funcs template.FuncMap, err error) error {
+	m := templateParseError.FindStringSubmatch(err.Error())
+	if m == nil {
+		return err
+	}
+	line, _ := strconv.Atoi(m[1])
+	col := 1
+	if l, c, ok := failingAction(text, funcs); ok && l == line {
+		col = c
+	}
+	return fmt.Errorf("%s:%d:%d: %s", filename, line, col, m[2])
+}
+
+// failingAction returns the line and column of the first action of text that
+// does not parse: the first whose text, taken up to its end, fails for a
+// reason other than blocks left open. ok is false when there is none, as
+// when a block is never closed.
+func failingAction(text string, funcs template.FuncMap) (line, col int, ok bool) {
+	for start := 0; ; {
+		i := strings.Index(text[start:], "{{")
+		if i < 0 {
+			return 0, 0, false
+		}
+		i += start
+		end := len(text) // An action never closed runs to the end.
+		if j := strings.Index(text[i+2:], "}}"); j >= 0 {
+			end = i + 2 + j + 2
+		}
+		_, err := template.New("").Funcs(funcs).Parse(text[:end])
+		if err != nil && !strings.HasSuffix(err.Error(), "unexpected EOF") {
+			lineStart := strings.LastIndex(text[:i], "\n") + 1
+			return 1 + strings.Count(text[:i], "\n"), 1 + utf8.RuneCountInString(text[lineStart:i]), true
+		}
+		start = end
+	}
+}
+
+// writeToTemplate executes t with candidates and writes the result to
+// filename, replacing it only once the template has run to the end.
+func writeToTemplate(t *template.Template, candidates []Candidate, filename string, criteria SearchCriteria, info *runInfo) error {
+	data := templateData{Candidates: candidates, Stats: stats.templateStats(), Criteria: criteria, Run: info}
+	err := writeFileAtomic(filename, func(w io.Writer) error {
+		return t.Execute(w, data)
+	})
+	if err == nil {
+		return nil
+	}
+	if i := failingCandidate(t, data); i >= 0 {
+		return fmt.Errorf("failed to execute template on candidate %d (%s): %w", i, candidates[i].ProfileURL, err)
+	}
+	return fmt.Errorf("failed to execute template: %w", err)
+}
+
+// failingCandidate returns the index of the first candidate on which t fails,
+// found by executing t on ever shorter prefixes of the candidates, or -1 when
+// t fails without any or succeeds with all of them.
+func failingCandidate(t *template.Template, data templateData) int {
+	fails := func(n int) bool {
+		prefix := data
+		prefix.Candidates = data.Candidates[:n]
+		return t.Execute(io.Discard, prefix) != nil
+	}
+	if fails(0) || !fails(len(data.Candidates)) {
+		return -1
+	}
+	// fails(lo) is false and fails(hi) true.
+	lo, hi := 0, len(data.Candidates)
+	for hi-lo > 1 {
+		mid := (lo + hi) / 2
+		if fails(mid) {
+			hi = mid
+		} else {
+			lo = mid
+		}
+	}
+	return lo
+}
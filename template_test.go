@@ -0,0 +1,125 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// templateCandidates is the fixed candidate set the example templates are
+// rendered against.
+var templateCandidates = []Candidate{
+	{
+		Rank: 1, Name: "Jane Doe", Title: "Valve Engineer", Company: "Acme Valves", Phone: "(415) 555-0134",
+		Email: "jane@example.com", ExperienceYears: 8.5, ProfileURL: "https://www.linkedin.com/in/jane-doe",
+	},
+	{
+		Rank: 2, Name: `Ravi "RK" Kumar`, Title: "Process Engineer", Company: "Kirloskar Brothers Limited", Phone: "+91 98765 43210",
+		ExperienceYears: 11, ProfileURL: "https://www.linkedin.com/in/ravi-kumar",
+	},
+	{
+		Rank: 3, Name: "Zoë Müller-Lüdenscheidt Ångström", Title: "Pump Designer", Company: "Flowline",
+		ProfileURL: "https://www.linkedin.com/in/zoe-muller",
+	},
+}
+
+func TestTemplateExamplesGolden(t *testing.T) {
+	for _, name := range []string{"fixed-width.txt", "contacts.jsonl"} {
+		t.Run(name, func(t *testing.T) {
+			stats = newRunStats()
+			stats.countPage(templateCandidates)
+			tmpl, err := loadOutputTemplate(filepath.Join("testdata", "templates", name+".tmpl"), "US")
+			if err != nil {
+				t.Fatal(err)
+			}
+			output := filepath.Join(t.TempDir(), name)
+			criteria := SearchCriteria{Keywords: "control valve", Location: "Bangalore"}
+			if err := writeToTemplate(tmpl, templateCandidates, output, criteria, nil); err != nil {
+				t.Fatal(err)
+			}
+			got, err := os.ReadFile(output)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkGolden(t, "template-"+name, got)
+		})
+	}
+}
+
+func TestTemplateParseErrorLocation(t *testing.T) {
+	tests := []struct {
+		text string
+		want string // After the file name.
+	}{
+		{"name\n{{range .Candidates}}{{.Name | nope}}{{end}}\n", `:2:22: function "nope" not defined`},
+		{"name\n  {{.Name}} {{end}}\n", ":2:13: unexpected {{end}}"},
+		{"name\n{{.Name}}\tü {{if}}x{{end}}\n", ":2:13: missing value for if"},
+		{"{{range .Candidates}}\n{{.Name}} {{\"Ravi}}\n{{end}}", ":2:11: unterminated quoted string"},
+		{"name\n{{.Name}} {{.Title", ":2:11: unclosed action"},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "out.tmpl")
+		if err := os.WriteFile(path, []byte(tt.text), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := loadOutputTemplate(path, "US")
+		if err == nil || err.Error() != path+tt.want {
+			t.Errorf("loading %q: error %v, want %s%s", tt.text, err, path, tt.want)
+		}
+	}
+
+	// A block never closed fails at the end, in no action of its own.
+	path := filepath.Join(t.TempDir(), "out.tmpl")
+	if err := os.WriteFile(path, []byte("{{range .Candidates}}\n{{.Name}}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadOutputTemplate(path, "US"); err == nil || err.Error() != path+":3:1: unexpected EOF" {
+		t.Errorf("unclosed range: error %v, want %s:3:1: unexpected EOF", err, path)
+	}
+}
+
+func TestTemplateExecutionErrorNamesCandidate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.tmpl")
+	text := `{{range .Candidates}}{{formatPhone (index .Extra "phone_format") .Phone}}` + "\n{{end}}"
+	if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tmpl, err := loadOutputTemplate(path, "US")
+	if err != nil {
+		t.Fatal(err)
+	}
+	candidates := []Candidate{
+		{ProfileURL: "https://www.linkedin.com/in/a", Extra: map[string]string{"phone_format": "e164"}},
+		{ProfileURL: "https://www.linkedin.com/in/b", Extra: map[string]string{"phone_format": "national"}},
+		{ProfileURL: "https://www.linkedin.com/in/c", Extra: map[string]string{"phone_format": "fancy"}},
+		{ProfileURL: "https://www.linkedin.com/in/d", Extra: map[string]string{"phone_format": "raw"}},
+	}
+	output := filepath.Join(t.TempDir(), "out.txt")
+	if err := os.WriteFile(output, []byte("previous\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	err = writeToTemplate(tmpl, candidates, output, SearchCriteria{}, nil)
+	if err == nil || !strings.Contains(err.Error(), "candidate 2 (https://www.linkedin.com/in/c)") {
+		t.Errorf("error %v, want one naming candidate 2", err)
+	}
+	if got, _ := os.ReadFile(output); string(got) != "previous\n" {
+		t.Errorf("failed template replaced the output with %q", got)
+	}
+}
+
+func TestTemplateHelpers(t *testing.T) {
+	tests := []struct{ got, want string }{
+		{csvQuote(`Ravi "RK" Kumar`), `"Ravi ""RK"" Kumar"`},
+		{jsonEscape("a \"b\"\n<c>\t"), `a \"b\"\n<c>\t`},
+		{padRight(6, "Zoë"), "Zoë   "},
+		{padRight(3, "Ångström"), "Ång"},
+		{padLeft(5, "8.5"), "  8.5"},
+		{padLeft(2, "123"), "12"},
+	}
+	for i, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("case %d = %q, want %q", i+1, tt.got, tt.want)
+		}
+	}
+}
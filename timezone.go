@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	_ "time/tzdata" // So -tz works on machines without a zoneinfo database.
+)
+
+// timezoneFlag is a -tz value: an IANA zone name such as Europe/Berlin, UTC,
+// or Local for the machine's zone.
+type timezoneFlag struct {
+	loc *time.Location
+}
+
+// outputZone is the zone timestamps are written in.
+var outputZone = timezoneFlag{time.UTC}
+
+func (z *timezoneFlag) String() string {
+	if z.loc == nil {
+		return "UTC"
+	}
+	return z.loc.String()
+}
+
+func (z *timezoneFlag) Set(s string) error {
+	name := strings.TrimSpace(s)
+	if name == "" {
+		return fmt.Errorf("empty time zone")
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return fmt.Errorf("unknown time zone %q: want an IANA name such as America/New_York, UTC, or Local", s)
+	}
+	z.loc = loc
+	return nil
+}
+
+// outputTime returns t in the -tz zone.
+func outputTime(t time.Time) time.Time {
+	return t.In(outputZone.loc)
+}
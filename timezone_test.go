@@ -0,0 +1,95 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setOutputZone sets -tz to name for the test.
+func setOutputZone(t *testing.T, name string) {
+	t.Helper()
+	t.Cleanup(func() { outputZone = timezoneFlag{time.UTC} })
+	if err := outputZone.Set(name); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTimezoneFlag(t *testing.T) {
+	t.Cleanup(func() { outputZone = timezoneFlag{time.UTC} })
+	if outputZone.String() != "UTC" {
+		t.Errorf("default zone %s, want UTC", outputZone.String())
+	}
+	if _, err := parseFlags([]string{"-tz", "Asia/Kolkata"}); err != nil {
+		t.Fatal(err)
+	}
+	if outputZone.String() != "Asia/Kolkata" {
+		t.Errorf("zone %s after -tz Asia/Kolkata", outputZone.String())
+	}
+	for _, name := range []string{"Mars/Olympus_Mons", "", "  ", "+05:30"} {
+		var z timezoneFlag
+		if err := z.Set(name); err == nil {
+			t.Errorf("-tz %q accepted", name)
+		}
+	}
+}
+
+func TestTimestampsInOutputZone(t *testing.T) {
+	// 20:00 UTC on 1 March is already 2 March in Kolkata.
+	at := time.Date(2026, 3, 1, 20, 0, 0, 0, time.UTC)
+	previouslySeen, _ := findCSVColumn("previously_seen")
+	c := Candidate{PreviouslySeen: &at}
+	if got := previouslySeen.value(c); got != "2026-03-01" {
+		t.Errorf("previously_seen %s in UTC, want 2026-03-01", got)
+	}
+	ri := &runInfo{Timestamp: at}
+	setOutputZone(t, "Asia/Kolkata")
+	if got := previouslySeen.value(c); got != "2026-03-02" {
+		t.Errorf("previously_seen %s in Asia/Kolkata, want 2026-03-02", got)
+	}
+	if got := ri.fields()[1]; got != [2]string{"timestamp", "2026-03-02T01:30:00+05:30"} {
+		t.Errorf("run info %q, want the timestamp in Asia/Kolkata", got)
+	}
+
+	fixedNow = at
+	t.Cleanup(func() { fixedNow = time.Time{} })
+	report := filepath.Join(t.TempDir(), "report.html")
+	if err := writeHTMLReport(nil, report, SearchCriteria{}, "", nil); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "<dd>2026-03-02 01:30:00 IST</dd>") {
+		t.Errorf("report lacks the generated time in Asia/Kolkata:\n%s", data)
+	}
+}
+
+func TestTimezoneInRunInfoBlock(t *testing.T) {
+	fixedNow = time.Date(2026, 3, 1, 20, 0, 0, 0, time.UTC)
+	t.Cleanup(func() { fixedNow = time.Time{} })
+	t.Cleanup(func() { outputZone = timezoneFlag{time.UTC} })
+	_, addr := startFakeWeb(t, fakeRoster(1))
+	output, err := runFakeSearch(t, addr, "-max-pages", "1", "-tz", "America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "# timestamp: 2026-03-01T15:00:00-05:00\n") {
+		t.Errorf("output lacks the run's time in America/New_York:\n%s", data)
+	}
+	// The block reads back as the same instant.
+	ri, err := readRunInfo(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ri.Timestamp.Equal(fixedNow) {
+		t.Errorf("run info timestamp %s, want %s", ri.Timestamp, fixedNow)
+	}
+}
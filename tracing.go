@@ -0,0 +1,210 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"log"
+	"net/http/httptrace"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// requestTiming is how long the phases of one request took, redirects
+// included. A nil phase did not happen in the request: DNS behind a proxy,
+// which resolves the target itself, and the connection phases on a reused
+// connection.
+type requestTiming struct {
+	DNS     *time.Duration `json:"dns_ns,omitempty"`
+	Connect *time.Duration `json:"connect_ns,omitempty"` // To the proxy, when there is one.
+	TLS     *time.Duration `json:"tls_ns,omitempty"`
+	TTFB    *time.Duration `json:"ttfb_ns,omitempty"` // From the request written to the first response byte.
+	Body    *time.Duration `json:"body_ns,omitempty"` // From the first response byte to the body closed.
+	Total   time.Duration  `json:"total_ns"`
+}
+
+// timingPhases name the phases of a requestTiming, in order, for reports.
+var timingPhases = []struct {
+	name  string
+	value func(t requestTiming) *time.Duration
+}{
+	{"dns", func(t requestTiming) *time.Duration { return t.DNS }},
+	{"connect", func(t requestTiming) *time.Duration { return t.Connect }},
+	{"tls", func(t requestTiming) *time.Duration { return t.TLS }},
+	{"ttfb", func(t requestTiming) *time.Duration { return t.TTFB }},
+	{"body", func(t requestTiming) *time.Duration { return t.Body }},
+}
+
+// String formats the breakdown, such as "total 1.2s (dns n/a, connect
+// 80ms, ...)".
+func (t requestTiming) String() string {
+	parts := make([]string, len(timingPhases))
+	for i, phase := range timingPhases {
+		parts[i] = phase.name + " " + formatPhase(phase.value(t))
+	}
+	return fmt.Sprintf("total %s (%s)", t.Total.Round(time.Millisecond), strings.Join(parts, ", "))
+}
+
+// formatPhase formats a phase's duration, or n/a when it did not happen.
+func formatPhase(d *time.Duration) string {
+	if d == nil {
+		return "n/a"
+	}
+	return d.Round(time.Millisecond).String()
+}
+
+// requestTrace collects the timing of one request through httptrace. Its
+// hooks may be called from the transport's goroutines.
+type requestTrace struct {
+	mu      sync.Mutex
+	proxied bool
+	start   time.Time
+	timing  requestTiming
+
+	dnsStart, connectStart, tlsStart, wrote, firstByte time.Time
+}
+
+// newRequestTrace starts timing a request sent now, through a proxy when
+// proxied.
+func newRequestTrace(proxied bool) *requestTrace {
+	return &requestTrace{proxied: proxied, start: time.Now()}
+}
+
+// mark records now as the time of an event.
+func (t *requestTrace) mark(at *time.Time) {
+	t.mu.Lock()
+	*at = time.Now()
+	t.mu.Unlock()
+}
+
+// add adds the time since from to a phase. A phase that happens more than
+// once, as on redirects, is summed.
+func (t *requestTrace) add(phase **time.Duration, from *time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if from.IsZero() {
+		return
+	}
+	d := time.Since(*from)
+	if *phase != nil {
+		d += **phase
+	}
+	*phase = &d
+	*from = time.Time{}
+}
+
+// clientTrace returns the hooks to attach to the request's context.
+func (t *requestTrace) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { t.mark(&t.dnsStart) },
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			// Only the proxy's own name is resolved here.
+			if !t.proxied {
+				t.add(&t.timing.DNS, &t.dnsStart)
+			}
+		},
+		ConnectStart: func(_, _ string) {
+			// Dialing several addresses at once is one phase, from the first.
+			t.mu.Lock()
+			if t.connectStart.IsZero() {
+				t.connectStart = time.Now()
+			}
+			t.mu.Unlock()
+		},
+		ConnectDone: func(_, _ string, err error) {
+			if err == nil {
+				t.add(&t.timing.Connect, &t.connectStart)
+			}
+		},
+		TLSHandshakeStart:    func() { t.mark(&t.tlsStart) },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { t.add(&t.timing.TLS, &t.tlsStart) },
+		WroteRequest:         func(httptrace.WroteRequestInfo) { t.mark(&t.wrote) },
+		GotFirstResponseByte: func() { t.mark(&t.firstByte); t.add(&t.timing.TTFB, &t.wrote) },
+	}
+}
+
+// finish returns the timing of a request whose body was just closed, or
+// which failed before a response.
+func (t *requestTrace) finish() requestTiming {
+	t.add(&t.timing.Body, &t.firstByte)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.timing.Total = time.Since(t.start)
+	return t.timing
+}
+
+// tracedBody calls done once, when the response body is closed.
+type tracedBody struct {
+	io.ReadCloser
+	once sync.Once
+	done func()
+}
+
+func (b *tracedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.done)
+	return err
+}
+
+// recordTiming attaches timing to the latest request of pageURL, and warns
+// when it took longer than slow (0 never warns).
+func (s *RunStats) recordTiming(pageURL, proxy string, timing requestTiming, slow time.Duration) {
+	s.mu.Lock()
+	for i := len(s.Outcomes) - 1; i >= 0; i-- {
+		if s.Outcomes[i].URL == pageURL {
+			s.Outcomes[i].Timing = &timing
+			break
+		}
+	}
+	s.mu.Unlock()
+	if slow > 0 && timing.Total > slow {
+		log.Printf("WARN: slow request to %s through %s: %s", pageURL, firstNonEmpty(redactURLCredentials(proxy), "a direct connection"), timing)
+	}
+}
+
+// latencyPercentiles are the percentiles the latency summary shows.
+var latencyPercentiles = []float64{0.5, 0.9, 0.99}
+
+// latencySummary returns a line per host and per proxy giving the
+// latencyPercentiles of each request phase.
+func latencySummary(records []outcomeRecord) []string {
+	groups := make(map[string][]requestTiming)
+	for _, r := range records {
+		if r.Timing == nil {
+			continue
+		}
+		groups["host "+r.Host] = append(groups["host "+r.Host], *r.Timing)
+		proxy := "proxy " + firstNonEmpty(redactURLCredentials(r.Proxy), "direct")
+		groups[proxy] = append(groups[proxy], *r.Timing)
+	}
+	keys := make([]string, 0, len(groups))
+	for key := range groups {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var lines []string
+	for _, key := range keys {
+		timings := groups[key]
+		parts := []string{"total " + formatPercentiles(phaseValues(timings, nil))}
+		for _, phase := range timingPhases {
+			parts = append(parts, phase.name+" "+formatPercentiles(phaseValues(timings, phase.value)))
+		}
+		lines = append(lines, fmt.Sprintf("Latency for %s, %d requests (p50/p90/p99): %s", key, len(timings), strings.Join(parts, ", ")))
+	}
+	return lines
+}
+
+// formatPercentiles formats the latencyPercentiles of durations, given in
+// nanoseconds, or n/a when there are none.
+func formatPercentiles(values []float64) string {
+	if len(values) == 0 {
+		return "n/a"
+	}
+	parts := make([]string, len(latencyPercentiles))
+	for i, p := range latencyPercentiles {
+		parts[i] = time.Duration(percentile(values, p)).Round(time.Millisecond).String()
+	}
+	return strings.Join(parts, "/")
+}
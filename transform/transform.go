@@ -0,0 +1,33 @@
+// Package transform rewrites parsed results pages before candidates are
+// extracted from them, for example to remove ad or noise nodes.
+package transform
+
+import "github.com/PuerkitoBio/goquery"
+
+// Func rewrites a parsed page in place.
+type Func func(*goquery.Document)
+
+// Chain is a list of transforms run in the order they were added. The zero
+// Chain runs none.
+type Chain []Func
+
+// Add appends f, to run after the transforms already in c.
+func (c *Chain) Add(f Func) {
+	*c = append(*c, f)
+}
+
+// Apply runs the transforms of c on doc in order.
+func (c Chain) Apply(doc *goquery.Document) {
+	for _, f := range c {
+		f(doc)
+	}
+}
+
+// RemoveNodes returns a transform deleting the nodes matching selector, a
+// CSS selector or a comma-separated group of them. An invalid selector
+// matches nothing.
+func RemoveNodes(selector string) Func {
+	return func(doc *goquery.Document) {
+		doc.Find(selector).Remove()
+	}
+}
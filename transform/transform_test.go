@@ -0,0 +1,59 @@
+package transform
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+func parse(t *testing.T, html string) *goquery.Document {
+	t.Helper()
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func TestRemoveNodes(t *testing.T) {
+	const page = `<div class="r"><a href="/in/a">A</a></div><div class="r ad"><a href="/in/b">B</a></div>` +
+		`<div class="r"><a href="/in/c" data-tags="x,y">C</a></div><span class="promo">P</span>`
+	tests := []struct {
+		selector string
+		want     int // Results left.
+	}{
+		{".ad", 2},
+		{".ad, .promo", 2},
+		{"div:not(.ad, .promo)", 1},
+		{`a[data-tags="x,y"]`, 3},
+		{`div:has(a[data-tags="x,y"]), .ad`, 1},
+		{"div:nope(", 3}, // Invalid: removes nothing.
+	}
+	for _, tt := range tests {
+		doc := parse(t, page)
+		RemoveNodes(tt.selector)(doc)
+		if got := doc.Find("div.r").Length(); got != tt.want {
+			t.Errorf("RemoveNodes(%q) left %d results, want %d", tt.selector, got, tt.want)
+		}
+	}
+}
+
+func TestChainAppliesInOrder(t *testing.T) {
+	var c Chain
+	c.Apply(parse(t, "<p></p>")) // The zero Chain runs none.
+
+	var order []string
+	c.Add(func(doc *goquery.Document) { order = append(order, "first") })
+	c.Add(RemoveNodes("p"))
+	c.Add(func(doc *goquery.Document) {
+		order = append(order, "third")
+		if doc.Find("p").Length() != 0 {
+			t.Error("a transform ran before the one added earlier")
+		}
+	})
+	c.Apply(parse(t, "<p></p>"))
+	if strings.Join(order, " ") != "first third" {
+		t.Errorf("ran %v, want first, then third", order)
+	}
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/mail"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Values of StoredCandidate.EmailStatus.
+const (
+	emailValid   = "valid"   // Well-formed, and the domain accepts mail.
+	emailInvalid = "invalid" // Not a well-formed address.
+	emailNoMX    = "no_mx"   // The domain has no mail servers.
+	emailUnknown = "unknown" // DNS could not be queried.
+)
+
+// Values of StoredCandidate.ProfileStatus.
+const (
+	profileLive    = "live"
+	profileDead    = "dead"
+	profileBlocked = "blocked"
+	profileUnknown = "unknown"
+
+	profileRobotsDisallowed = "robots_disallowed" // Not checked, as robots.txt disallows it.
+)
+
+// Orders accepted by verify -by.
+const (
+	verifyByScore        = "score"         // Highest score first.
+	verifyByLastVerified = "last_verified" // Least recently verified first.
+)
+
+// lookupMX resolves mail servers; replaced in tests.
+var lookupMX = net.LookupMX
+
+// verifyReport summarizes a verify run.
+type verifyReport struct {
+	Checked       int
+	StillValid    int      // Live profile, and a valid email or none.
+	NewlyDead     []string // Profile URLs that were not dead before.
+	ChangedEmails []emailChange
+}
+
+// emailChange is an email address that differs on re-fetch.
+type emailChange struct {
+	ProfileURL string
+	Old, New   string
+}
+
+// selectForVerify returns up to top candidates in the given order.
+func selectForVerify(candidates []*StoredCandidate, top int, by string) ([]*StoredCandidate, error) {
+	selected := append([]*StoredCandidate(nil), candidates...)
+	switch by {
+	case verifyByScore:
+		sort.SliceStable(selected, func(i, j int) bool { return selected[i].Score > selected[j].Score })
+	case verifyByLastVerified:
+		sort.SliceStable(selected, func(i, j int) bool { return selected[i].LastVerified.Before(selected[j].LastVerified) })
+	default:
+		return nil, fmt.Errorf("unknown order %q (want %s or %s)", by, verifyByScore, verifyByLastVerified)
+	}
+	if top > 0 && len(selected) > top {
+		selected = selected[:top]
+	}
+	return selected, nil
+}
+
+// checkEmail validates an address's syntax and its domain's MX records.
+func checkEmail(address string) string {
+	parsed, err := mail.ParseAddress(address)
+	if err != nil || parsed.Address != address {
+		return emailInvalid
+	}
+	domain := address[strings.LastIndex(address, "@")+1:]
+	mx, err := lookupMX(domain)
+	var dnsErr *net.DNSError
+	switch {
+	case errors.As(err, &dnsErr) && dnsErr.IsNotFound:
+		return emailNoMX
+	case err != nil:
+		return emailUnknown
+	case len(mx) == 0:
+		return emailNoMX
+	}
+	return emailValid
+}
+
+// checkProfile probes a profile URL. Only errors that stop the run are
+// returned; anything else is reported as a status.
+func checkProfile(ctx context.Context, f Fetcher, profileURL string) (string, error) {
+	code, err := probe(ctx, f, profileURL)
+	switch {
+	case stopsRun(err):
+		return "", err
+	case errors.Is(err, errBlocked):
+		return profileBlocked, nil
+	case errors.Is(err, errRobotsDisallowed):
+		return profileRobotsDisallowed, nil
+	case err != nil:
+		return profileUnknown, nil
+	}
+	switch code {
+	case http.StatusOK:
+		return profileLive, nil
+	case http.StatusNotFound, http.StatusGone:
+		return profileDead, nil
+	case http.StatusTooManyRequests, http.StatusFound, 999: // LinkedIn answers bots with 999.
+		return profileBlocked, nil
+	}
+	return profileUnknown, nil
+}
+
+// verifyCandidate re-checks one stored candidate in place. With deep set, a
+// live profile is re-fetched and its contact details refreshed.
+func verifyCandidate(ctx context.Context, f Fetcher, sc *StoredCandidate, deep bool, report *verifyReport, now time.Time) error {
+	status, err := checkProfile(ctx, f, sc.ProfileURL)
+	if err != nil {
+		return err
+	}
+	if status == profileDead && sc.ProfileStatus != profileDead {
+		report.NewlyDead = append(report.NewlyDead, sc.ProfileURL)
+	}
+	sc.ProfileStatus = status
+
+	if deep && status == profileLive {
+		fresh, err := scrapeProfileDetails(ctx, f, sc.ProfileURL, profileOptions{})
+		if stopsRun(err) {
+			return err
+		}
+		if err != nil {
+			log.Printf("Error re-fetching %s: %v", sc.ProfileURL, err)
+		} else {
+			old := sc.Email
+			refreshed := sc.stored()
+			mergeFields("verify", &refreshed, sc.LastSeen, fresh, now, "email", "phone")
+			sc.Email, sc.Phone, sc.FieldSources = refreshed.Email, refreshed.Phone, refreshed.Sources
+			if sc.Email != old {
+				report.ChangedEmails = append(report.ChangedEmails, emailChange{sc.ProfileURL, old, sc.Email})
+			}
+		}
+	}
+
+	sc.EmailStatus = ""
+	if sc.Email != "" {
+		sc.EmailStatus = checkEmail(sc.Email)
+	}
+	sc.LastVerified = now
+	report.Checked++
+	if status == profileLive && (sc.EmailStatus == "" || sc.EmailStatus == emailValid) {
+		report.StillValid++
+	}
+	return nil
+}
+
+// print writes the report to stdout.
+func (r *verifyReport) print() {
+	pct := 0.0
+	if r.Checked > 0 {
+		pct = 100 * float64(r.StillValid) / float64(r.Checked)
+	}
+	fmt.Printf("Verified %d candidates: %d still valid (%.0f%%)\n", r.Checked, r.StillValid, pct)
+	if len(r.NewlyDead) > 0 {
+		fmt.Printf("Newly dead profiles (%d):\n", len(r.NewlyDead))
+		for _, u := range r.NewlyDead {
+			fmt.Printf("  %s\n", u)
+		}
+	}
+	if len(r.ChangedEmails) > 0 {
+		fmt.Printf("Changed emails (%d):\n", len(r.ChangedEmails))
+		for _, c := range r.ChangedEmails {
+			fmt.Printf("  %s: %s -> %s\n", c.ProfileURL, c.Old, c.New)
+		}
+	}
+}
+
+// runVerifyCommand implements `profilesearch verify`: it re-checks the
+// contact data of stored candidates and records the results in the store.
+func runVerifyCommand(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	storePath := fs.String("store", "", "candidate store to verify")
+	top := fs.Int("top", 100, "number of candidates to verify (0 for all)")
+	by := fs.String("by", verifyByScore, "selection order: score or last_verified")
+	deep := fs.Bool("deep", false, "re-fetch live profiles and refresh their contact details")
+	var fetchOpts fetcherOptions
+	fs.IntVar(&fetchOpts.maxRequests, "max-requests", 0, "stop after this many outbound requests (0 disables)")
+	addFetcherFlags(fs, &fetchOpts)
+	fs.Parse(args)
+	if *storePath == "" {
+		return errors.New("verify needs -store")
+	}
+	lock, err := lockStore(*storePath)
+	if err != nil {
+		return err
+	}
+	defer lock.unlock()
+
+	store, err := openStore(*storePath)
+	if err != nil {
+		return err
+	}
+	selected, err := selectForVerify(store.candidates(), *top, *by)
+	if err != nil {
+		return fmt.Errorf("invalid -by: %w", err)
+	}
+
+	hf, err := newHTTPFetcher(fetchOpts)
+	if err != nil {
+		return err
+	}
+	defer hf.robots.report()
+	defer hf.close()
+
+	var report verifyReport
+	now := time.Now().UTC()
+	for _, sc := range selected {
+		if err := verifyCandidate(ctx, hf, sc, *deep, &report, now); err != nil {
+			log.Printf("Verification stopped early: %v", err)
+			break
+		}
+	}
+
+	// Save whatever was verified, even after an early stop.
+	if err := store.save(); err != nil {
+		return err
+	}
+	report.print()
+	return nil
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// fakeMX answers MX lookups without DNS: example.com accepts mail,
+// nomail.example has no such domain, and anything else fails to resolve.
+func fakeMX(t *testing.T) {
+	t.Helper()
+	lookupMX = func(domain string) ([]*net.MX, error) {
+		switch domain {
+		case "example.com":
+			return []*net.MX{{Host: "mx.example.com", Pref: 10}}, nil
+		case "nomail.example":
+			return nil, &net.DNSError{Err: "no such host", Name: domain, IsNotFound: true}
+		}
+		return nil, errors.New("server misbehaving")
+	}
+	t.Cleanup(func() { lookupMX = net.LookupMX })
+}
+
+// verifyRoster is a fakeweb scenario of a live profile with a new email, a
+// live one without, and a deleted one.
+const verifyRoster = `profiles:
+  - slug: member-1
+    name: Member 1
+    email: new@example.com
+  - slug: member-2
+    name: Member 2
+  - slug: member-3
+    name: Member 3
+    behavior: gone
+`
+
+// seedVerifyStore returns stored candidates member-1 to member-3 of
+// verifyRoster, scored 30, 20, and 10.
+func seedVerifyStore() []*StoredCandidate {
+	seen := time.Date(2026, 1, 5, 0, 0, 0, 0, time.UTC)
+	entry := func(slug, email string, score int) *StoredCandidate {
+		return &StoredCandidate{
+			Candidate: Candidate{ProfileURL: "https://www.linkedin.com/in/" + slug, Email: email, Score: score},
+			FirstSeen: seen, LastSeen: seen, ProfileStatus: profileLive,
+		}
+	}
+	return []*StoredCandidate{
+		entry("member-3", "", 10),
+		entry("member-1", "old@example.com", 30),
+		entry("member-2", "jane@nomail.example", 20),
+	}
+}
+
+func TestCheckEmail(t *testing.T) {
+	fakeMX(t)
+	for address, want := range map[string]string{
+		"jane@example.com":    emailValid,
+		"jane@nomail.example": emailNoMX,
+		"jane@flaky.example":  emailUnknown,
+		"jane.example.com":    emailInvalid,
+		"Jane <jane@example>": emailInvalid,
+	} {
+		if got := checkEmail(address); got != want {
+			t.Errorf("checkEmail(%q) = %s, want %s", address, got, want)
+		}
+	}
+}
+
+func TestSelectForVerify(t *testing.T) {
+	candidates := seedVerifyStore()
+	candidates[0].LastVerified = time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+	candidates[2].LastVerified = time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	slugs := func(selected []*StoredCandidate) (got []string) {
+		for _, sc := range selected {
+			got = append(got, profileSlug(sc.ProfileURL))
+		}
+		return got
+	}
+
+	byScore, err := selectForVerify(candidates, 2, verifyByScore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := slugs(byScore), []string{"member-1", "member-2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("top 2 by score %q, want %q", got, want)
+	}
+	byAge, _ := selectForVerify(candidates, 0, verifyByLastVerified)
+	if got, want := slugs(byAge), []string{"member-1", "member-3", "member-2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("all by last verified %q, want the never-verified first: %q", got, want)
+	}
+	if _, err := selectForVerify(candidates, 1, "name"); err == nil {
+		t.Error("an unknown order was accepted")
+	}
+}
+
+func TestVerifyShallowAndDeep(t *testing.T) {
+	fakeMX(t)
+	now := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	for _, deep := range []bool{false, true} {
+		stats = newRunStats()
+		web, addr := startFakeWeb(t, verifyRoster)
+		f, err := newHTTPFetcher(fakeFetcherOptions(t, addr))
+		if err != nil {
+			t.Fatal(err)
+		}
+		candidates := seedVerifyStore()
+		var report verifyReport
+		for _, sc := range candidates {
+			if err := verifyCandidate(context.Background(), f, sc, deep, &report, now); err != nil {
+				t.Fatal(err)
+			}
+		}
+		f.close()
+
+		dead, live, noMX := candidates[0], candidates[1], candidates[2]
+		if dead.ProfileStatus != profileDead || live.ProfileStatus != profileLive || noMX.ProfileStatus != profileLive {
+			t.Errorf("deep %v: profile statuses %s, %s, %s; want dead, live, live", deep, dead.ProfileStatus, live.ProfileStatus, noMX.ProfileStatus)
+		}
+		if noMX.EmailStatus != emailNoMX || dead.EmailStatus != "" {
+			t.Errorf("deep %v: email statuses %q, %q; want no_mx and none for no email", deep, noMX.EmailStatus, dead.EmailStatus)
+		}
+		for _, sc := range candidates {
+			if !sc.LastVerified.Equal(now) {
+				t.Errorf("deep %v: %s last verified %v, want %v", deep, sc.ProfileURL, sc.LastVerified, now)
+			}
+		}
+		if report.Checked != 3 || report.StillValid != 1 || !reflect.DeepEqual(report.NewlyDead, []string{dead.ProfileURL}) {
+			t.Errorf("deep %v: report %+v, want 3 checked, 1 still valid, and member-3 newly dead", deep, report)
+		}
+
+		// Only a deep run fetches the live profiles, finding the new email.
+		wantEmail, wantChanges, wantFetches := "old@example.com", 0, 3
+		if deep {
+			wantEmail, wantChanges, wantFetches = "new@example.com", 1, 5
+		}
+		if live.Email != wantEmail || live.EmailStatus != emailValid || len(report.ChangedEmails) != wantChanges {
+			t.Errorf("deep %v: email %s (%s), changes %+v; want %s", deep, live.Email, live.EmailStatus, report.ChangedEmails, wantEmail)
+		}
+		if got := web.requests("profile"); got != wantFetches {
+			t.Errorf("deep %v: %d profile requests, want %d", deep, got, wantFetches)
+		}
+	}
+}
+
+func TestVerifyCommandUpdatesStore(t *testing.T) {
+	fakeMX(t)
+	stats = newRunStats()
+	_, addr := startFakeWeb(t, verifyRoster)
+	path := filepath.Join(t.TempDir(), "candidates.db")
+	store, err := openStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, sc := range seedVerifyStore() {
+		store.upsert([]Candidate{sc.Candidate}, sc.FirstSeen)
+	}
+	if err := store.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := runVerifyCommand(context.Background(), []string{"-store", path, "-top", "2", "-fake-web", addr}); err != nil {
+		t.Fatal(err)
+	}
+	if store, err = openStore(path); err != nil {
+		t.Fatal(err)
+	}
+	verified := make(map[string]string)
+	for _, sc := range store.candidates() {
+		if !sc.LastVerified.IsZero() {
+			verified[profileSlug(sc.ProfileURL)] = sc.ProfileStatus + " " + sc.EmailStatus
+		}
+	}
+	want := map[string]string{"member-1": "live valid", "member-2": "live no_mx"}
+	if !reflect.DeepEqual(verified, want) {
+		t.Errorf("verified %v, want the top two by score: %v", verified, want)
+	}
+}
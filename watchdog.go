@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// errIdle is the cause of a run cancelled by the -max-idle watchdog.
+var errIdle = errors.New("no new candidates within -max-idle")
+
+// idleWatchdog cancels a run when no new candidate has been added for its
+// timeout, so a run whose every page is blocked gives up with partial output
+// instead of using up its whole budget. A nil watchdog does nothing.
+type idleWatchdog struct {
+	timeout time.Duration
+	cancel  context.CancelCauseFunc
+
+	mu   sync.Mutex
+	last time.Time // When the latest candidate was added, or the run started.
+}
+
+// startIdleWatchdog returns a context that is cancelled once timeout passes
+// without a call to touch, and the watchdog guarding it. With a timeout of 0
+// it returns ctx unchanged and a nil watchdog.
+func startIdleWatchdog(ctx context.Context, timeout time.Duration) (context.Context, *idleWatchdog) {
+	if timeout <= 0 {
+		return ctx, nil
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	w := &idleWatchdog{timeout: timeout, cancel: cancel, last: time.Now()}
+	go w.watch(ctx)
+	return ctx, w
+}
+
+// touch records that a new candidate was added.
+func (w *idleWatchdog) touch() {
+	if w == nil {
+		return
+	}
+	w.mu.Lock()
+	w.last = time.Now()
+	w.mu.Unlock()
+}
+
+// idle returns how long ago the latest candidate was added.
+func (w *idleWatchdog) idle() time.Duration {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return time.Since(w.last)
+}
+
+// watch cancels the run once it has been idle for the timeout, checking again
+// whenever the timeout could next expire.
+func (w *idleWatchdog) watch(ctx context.Context) {
+	for {
+		idle := w.idle()
+		if idle >= w.timeout {
+			log.Printf("No new candidate for %s; stopping the run.", idle.Round(time.Second))
+			w.cancel(errIdle)
+			return
+		}
+		if err := sleepContext(ctx, w.timeout-idle); err != nil {
+			return
+		}
+	}
+}
+
+// stop releases the watchdog.
+func (w *idleWatchdog) stop() {
+	if w != nil {
+		w.cancel(nil)
+	}
+}
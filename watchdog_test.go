@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestIdleWatchdogFiresOnceCandidatesStop(t *testing.T) {
+	captureLog(t)
+	ctx, w := startIdleWatchdog(context.Background(), 100*time.Millisecond)
+	defer w.stop()
+
+	// A stub producer finds a candidate every 20ms for a while, then stops.
+	producing := time.Now()
+	var stopped time.Time // When the last candidate was found.
+	for time.Since(producing) < 300*time.Millisecond {
+		if ctx.Err() != nil {
+			t.Fatalf("run stopped while candidates were coming in: %v", context.Cause(ctx))
+		}
+		stopped = time.Now()
+		w.touch()
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("the watchdog did not fire")
+	}
+	if cause := context.Cause(ctx); !errors.Is(cause, errIdle) {
+		t.Errorf("run stopped by %v, want errIdle", cause)
+	}
+	if waited := time.Since(stopped); waited < 100*time.Millisecond {
+		t.Errorf("watchdog fired %v after the last candidate, want about 100ms", waited)
+	}
+}
+
+func TestIdleWatchdogStopDoesNotFire(t *testing.T) {
+	ctx, w := startIdleWatchdog(context.Background(), 50*time.Millisecond)
+	w.stop()
+	<-ctx.Done()
+	if cause := context.Cause(ctx); errors.Is(cause, errIdle) {
+		t.Error("a stopped watchdog cancelled the run as idle")
+	}
+}
+
+func TestIdleWatchdogDisabled(t *testing.T) {
+	ctx := context.Background()
+	if got, w := startIdleWatchdog(ctx, 0); w != nil || got != ctx {
+		t.Error("a watchdog started without -max-idle")
+	}
+	// A nil watchdog ignores its calls.
+	var w *idleWatchdog
+	w.touch()
+	w.stop()
+}
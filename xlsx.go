@@ -0,0 +1,226 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// xlsxRunInfoSheet is the name of the hidden sheet holding the run info
+// block in -format xlsx output.
+const xlsxRunInfoSheet = "Run Info"
+
+// The fixed parts of a workbook of two sheets: the candidates, then the run
+// info, hidden so that it stays out of the way of people reading the file.
+const (
+	xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/><Override PartName="/xl/worksheets/sheet2.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/></Types>`
+	xlsxRootRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`
+	xlsxWorkbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/><Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet2.xml"/></Relationships>`
+	xlsxWorkbook = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="Candidates" sheetId="1" r:id="rId1"/><sheet name="` + xlsxRunInfoSheet + `" sheetId="2" state="hidden" r:id="rId2"/></sheets></workbook>`
+)
+
+// writeToXLSX writes candidates to filename as a workbook whose first sheet
+// holds the columns, as a CSV would, and whose hidden second sheet holds the
+// run info block, when info is not nil, as key/value rows.
+func writeToXLSX(candidates []Candidate, filename string, columns []csvColumn, info *runInfo) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create XLSX file: %w", err)
+	}
+	defer file.Close()
+
+	rows := make([][]string, 0, len(candidates)+1)
+	header := make([]string, len(columns))
+	for i, col := range columns {
+		header[i] = col.header
+	}
+	rows = append(rows, header)
+	for _, c := range candidates {
+		row := make([]string, len(columns))
+		for i, col := range columns {
+			row[i] = col.value(c)
+		}
+		rows = append(rows, row)
+	}
+	var infoRows [][]string
+	if info != nil {
+		for _, kv := range info.fields() {
+			infoRows = append(infoRows, []string{kv[0], kv[1]})
+		}
+	}
+
+	zw := zip.NewWriter(file)
+	parts := []struct {
+		name  string
+		write func(io.Writer) error
+	}{
+		{"[Content_Types].xml", writeString(xlsxContentTypes)},
+		{"_rels/.rels", writeString(xlsxRootRels)},
+		{"xl/workbook.xml", writeString(xlsxWorkbook)},
+		{"xl/_rels/workbook.xml.rels", writeString(xlsxWorkbookRels)},
+		{"xl/worksheets/sheet1.xml", func(w io.Writer) error { return writeXLSXSheet(w, rows) }},
+		{"xl/worksheets/sheet2.xml", func(w io.Writer) error { return writeXLSXSheet(w, infoRows) }},
+	}
+	for _, part := range parts {
+		w, err := zw.Create(part.name)
+		if err == nil {
+			err = part.write(w)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to write XLSX file: %w", err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to write XLSX file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write XLSX file: %w", err)
+	}
+	return nil
+}
+
+// writeString returns a part writer writing s.
+func writeString(s string) func(io.Writer) error {
+	return func(w io.Writer) error {
+		_, err := io.WriteString(w, s)
+		return err
+	}
+}
+
+// writeXLSXSheet writes a worksheet of rows of inline strings.
+func writeXLSXSheet(w io.Writer, rows [][]string) error {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n")
+	b.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	for r, row := range rows {
+		fmt.Fprintf(&b, `<row r="%d">`, r+1)
+		for c, value := range row {
+			fmt.Fprintf(&b, `<c r="%s%d" t="inlineStr"><is><t xml:space="preserve">`, xlsxColumnName(c), r+1)
+			xml.EscapeText(&b, []byte(value)) // Never fails on a strings.Builder.
+			b.WriteString(`</t></is></c>`)
+		}
+		b.WriteString(`</row>`)
+	}
+	b.WriteString(`</sheetData></worksheet>`)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// xlsxColumnName returns the letters of column i, from 0: A to Z, then AA.
+func xlsxColumnName(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
+
+// xlsxSheet is the part of a worksheet readXLSXRunInfo reads.
+type xlsxSheet struct {
+	Rows []struct {
+		Cells []struct {
+			Type   string `xml:"t,attr"`
+			Value  string `xml:"v"`
+			Inline string `xml:"is>t"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+// readXLSXRunInfo parses the run info block of -format xlsx output from its
+// sheet, found by name, so that a workbook resaved by a spreadsheet program,
+// with its strings moved to the shared table, still reads.
+func readXLSXRunInfo(filename string) (*runInfo, error) {
+	zr, err := zip.OpenReader(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", filename, err)
+	}
+	defer zr.Close()
+
+	var workbook struct {
+		Sheets []struct {
+			Name string `xml:"name,attr"`
+			ID   string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	var rels struct {
+		Relationships []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	var shared struct {
+		Items []struct {
+			Text string   `xml:"t"`
+			Runs []string `xml:"r>t"`
+		} `xml:"si"`
+	}
+	if err := readZipXML(&zr.Reader, "xl/workbook.xml", &workbook); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", filename, err)
+	}
+	if err := readZipXML(&zr.Reader, "xl/_rels/workbook.xml.rels", &rels); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", filename, err)
+	}
+	if err := readZipXML(&zr.Reader, "xl/sharedStrings.xml", &shared); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read %s: %w", filename, err)
+	}
+
+	target := ""
+	for _, s := range workbook.Sheets {
+		if s.Name != xlsxRunInfoSheet {
+			continue
+		}
+		for _, r := range rels.Relationships {
+			if r.ID == s.ID {
+				target = path.Join("xl", r.Target)
+			}
+		}
+	}
+	if target == "" {
+		return nil, fmt.Errorf("%s has no run info block", filename)
+	}
+	var sheet xlsxSheet
+	if err := readZipXML(&zr.Reader, target, &sheet); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", filename, err)
+	}
+
+	fields := make(map[string]string)
+	for _, row := range sheet.Rows {
+		var values []string
+		for _, c := range row.Cells {
+			value := c.Value
+			switch c.Type {
+			case "inlineStr":
+				value = c.Inline
+			case "s":
+				if i, err := strconv.Atoi(c.Value); err == nil && i >= 0 && i < len(shared.Items) {
+					value = shared.Items[i].Text + strings.Join(shared.Items[i].Runs, "")
+				}
+			}
+			values = append(values, value)
+		}
+		if len(values) >= 2 {
+			fields[values[0]] = values[1]
+		}
+	}
+	return runInfoFromFields(filename, fields)
+}
+
+// readZipXML decodes the XML part name of an archive into v. A missing part
+// is reported as an error satisfying os.IsNotExist.
+func readZipXML(zr *zip.Reader, name string, v any) error {
+	f, err := zr.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return xml.NewDecoder(f).Decode(v)
+}